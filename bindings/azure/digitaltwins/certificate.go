@@ -0,0 +1,90 @@
+// ------------------------------------------------------------
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT License.
+// ------------------------------------------------------------
+
+package digitaltwins
+
+import (
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"fmt"
+
+	"github.com/Azure/go-autorest/autorest"
+	"github.com/Azure/go-autorest/autorest/adal"
+)
+
+// certificateAuthorizer returns a service principal authorizer for the PEM client certificate and private key
+func (d *AzureDigitalTwins) certificateAuthorizer() (autorest.Authorizer, error) {
+	certificate, privateKey, err := parsePEMCertificate([]byte(d.certificate))
+	if err != nil {
+		return nil, err
+	}
+
+	oauthConfig, err := adal.NewOAuthConfig(d.environment.aadEndpoint, d.tenantID)
+	if err != nil {
+		return nil, err
+	}
+
+	token, err := adal.NewServicePrincipalTokenFromCertificate(*oauthConfig, d.clientID, certificate, privateKey, d.environment.resource)
+	if err != nil {
+		return nil, err
+	}
+
+	return autorest.NewBearerAuthorizer(token), nil
+}
+
+// parsePEMCertificate returns the first certificate and the RSA private key, in PKCS #1 or unencrypted PKCS #8 form, of the PEM data
+func parsePEMCertificate(data []byte) (*x509.Certificate, *rsa.PrivateKey, error) {
+	var certificate *x509.Certificate
+	var privateKey *rsa.PrivateKey
+	for {
+		var block *pem.Block
+		block, data = pem.Decode(data)
+		if block == nil {
+			break
+		}
+
+		switch block.Type {
+		case "CERTIFICATE":
+			if certificate != nil {
+				continue
+			}
+
+			cert, err := x509.ParseCertificate(block.Bytes)
+			if err != nil {
+				return nil, nil, fmt.Errorf("invalid certificate: %w", err)
+			}
+			certificate = cert
+		case "RSA PRIVATE KEY":
+			key, err := x509.ParsePKCS1PrivateKey(block.Bytes)
+			if err != nil {
+				return nil, nil, fmt.Errorf("invalid private key: %w", err)
+			}
+			privateKey = key
+		case "PRIVATE KEY":
+			key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+			if err != nil {
+				return nil, nil, fmt.Errorf("invalid private key: %w", err)
+			}
+
+			rsaKey, ok := key.(*rsa.PrivateKey)
+			if !ok {
+				return nil, nil, errors.New("invalid private key: only RSA keys are supported")
+			}
+			privateKey = rsaKey
+		}
+	}
+
+	if certificate == nil {
+		return nil, nil, errors.New("missing certificate in PEM data")
+	}
+
+	if privateKey == nil {
+		return nil, nil, errors.New("missing private key in PEM data")
+	}
+
+	return certificate, privateKey, nil
+}
@@ -0,0 +1,111 @@
+// ------------------------------------------------------------
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT License.
+// ------------------------------------------------------------
+
+package digitaltwins
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/dapr/components-contrib/bindings"
+	"github.com/dapr/dapr/pkg/logger"
+	"github.com/stretchr/testify/assert"
+)
+
+// testCertificate returns a self-signed certificate and its private key in PKCS #8 form, PEM encoded
+func testCertificate(t *testing.T) string {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	assert.NoError(t, err)
+
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "dapr-test"},
+		NotBefore:    time.Now(),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	assert.NoError(t, err)
+	keyDer, err := x509.MarshalPKCS8PrivateKey(key)
+	assert.NoError(t, err)
+
+	return string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})) +
+		string(pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: keyDer}))
+}
+
+func TestCertificateAuth(t *testing.T) {
+	d := NewAzureDigitalTwins(logger.NewLogger("test"))
+	certificate := testCertificate(t)
+
+	t.Run("inferred from certificate", func(t *testing.T) {
+		for _, property := range []string{"certificate", "certificateFile"} {
+			m := bindings.Metadata{Properties: map[string]string{
+				"adtInstanceUrl": "https://adt.example",
+				"clientId":       "client",
+				"tenantId":       "tenant",
+				property:         "value",
+			}}
+			meta, err := d.getAzureDigitalTwinsMetadata(m)
+			assert.NoError(t, err)
+			assert.Equal(t, authMethodClientCertificate, meta.authMethod)
+		}
+	})
+
+	t.Run("invalid metadata", func(t *testing.T) {
+		for _, properties := range []map[string]string{
+			{"azureAuthMethod": "clientCertificate", "clientId": "client", "tenantId": "tenant"},
+			{"certificate": "pem", "certificateFile": "cert.pfx", "clientId": "client", "tenantId": "tenant"},
+			{"certificate": "pem", "tenantId": "tenant"},
+			{"certificate": "pem", "clientId": "client"},
+			{"azureAuthMethod": "clientCertificate", "certificate": "pem", "clientSecret": "secret", "clientId": "client", "tenantId": "tenant"},
+		} {
+			properties["adtInstanceUrl"] = "https://adt.example"
+			_, err := d.getAzureDigitalTwinsMetadata(bindings.Metadata{Properties: properties})
+			assert.Error(t, err, properties)
+		}
+	})
+
+	t.Run("pem certificate", func(t *testing.T) {
+		d := NewAzureDigitalTwins(logger.NewLogger("test"))
+		err := d.Init(bindings.Metadata{Properties: map[string]string{
+			"adtInstanceUrl": "https://adt.example",
+			"clientId":       "client",
+			"tenantId":       "tenant",
+			"certificate":    certificate,
+		}})
+		assert.NoError(t, err)
+		assert.NotNil(t, d.authorizer)
+	})
+
+	t.Run("missing pfx file", func(t *testing.T) {
+		d := NewAzureDigitalTwins(logger.NewLogger("test"))
+		err := d.Init(bindings.Metadata{Properties: map[string]string{
+			"adtInstanceUrl":  "https://adt.example",
+			"clientId":        "client",
+			"tenantId":        "tenant",
+			"certificateFile": "/nonexistent/cert.pfx",
+		}})
+		assert.Error(t, err)
+	})
+
+	t.Run("parse pem", func(t *testing.T) {
+		cert, key, err := parsePEMCertificate([]byte(certificate))
+		assert.NoError(t, err)
+		assert.Equal(t, "dapr-test", cert.Subject.CommonName)
+		assert.NotNil(t, key)
+
+		block, _ := pem.Decode([]byte(certificate))
+		_, _, err = parsePEMCertificate(pem.EncodeToMemory(block))
+		assert.Error(t, err, "missing private key")
+
+		_, _, err = parsePEMCertificate([]byte("not pem"))
+		assert.Error(t, err)
+	})
+}
@@ -10,39 +10,318 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
-	"io/ioutil"
-	"log"
 	"net/http"
-	"os"
 	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
 
 	"github.com/dapr/components-contrib/bindings"
+	"github.com/dapr/components-contrib/pubsub"
 	"github.com/dapr/dapr/pkg/logger"
 
 	"github.com/Azure/go-autorest/autorest"
+	"github.com/Azure/go-autorest/autorest/azure"
 	"github.com/Azure/go-autorest/autorest/azure/auth"
+	"github.com/google/uuid"
 
 	"github.com/dapr/components-contrib/bindings/azure/digitaltwins/digitaltwinsrest"
 )
 
 const (
 	key = "partitionKey"
+
+	// azureAuthMethod metadata values
+	authMethodClientSecret      = "clientSecret"
+	authMethodClientCertificate = "clientCertificate"
+	authMethodManagedIdentity   = "managedIdentity"
+	authMethodEnvironment       = "environment"
+	authMethodAccessToken       = "accessToken"
+
+	// defaultAzureEnvironment is the azureEnvironment of the public cloud
+	defaultAzureEnvironment = "public"
+	// apiVersionQueryKey is the query parameter of the REST API version, see apiVersion
+	apiVersionQueryKey = "api-version"
+
+	// ifMatchKey is the request metadata key holding the ETag a twin update is conditional on.
+	// In multi-twin requests "ifMatch.<twinId>" sets the ETag for a single twin.
+	ifMatchKey = "ifMatch"
+	// etagKey is the response metadata key of the ETag of a patched twin, "etag.<twinId>" in multi-twin responses.
+	// It is accepted in request metadata as an alternative to ifMatch, so that the ETag can be passed back as is.
+	etagKey = "etag"
+	// durationKey prefixes the multi-twin response metadata keys holding the update latency of each twin,
+	// "durationMs.<twinId>" is the time in milliseconds spent updating the twin
+	durationKey = "durationMs"
+	// anyETag makes updates unconditional
+	anyETag = "*"
+	// correlationIDKey is the request metadata key of the correlation ID tying together the logs of an Invoke,
+	// returned in the response metadata under the same key
+	correlationIDKey = "correlationId"
+	// defaultMetadataPrefix prefixes component metadata properties that set default request metadata,
+	// e.g. "default.ifMatch" sets ifMatch on requests that do not specify it
+	defaultMetadataPrefix = "default."
+	// patchFormatKey is the request metadata key setting the format of the operations, jsonPatch or mergePatch.
+	// Without it the format is detected and requests mixing both formats are rejected.
+	patchFormatKey   = "patchFormat"
+	jsonPatchFormat  = "jsonPatch"
+	mergePatchFormat = "mergePatch"
+	// eventTimeKey is the request metadata key holding the RFC3339 time of the event a patch originates from
+	eventTimeKey = "time"
+
+	// twinIDKey is the request metadata key of the twin ID
+	twinIDKey = "twinID"
+	// continuationTokenKey is the request and response metadata key of the token resuming a query
+	continuationTokenKey = "continuationToken"
+	// relationshipIDKey is the request metadata key of the relationship ID of relationship operations
+	relationshipIDKey = "relationshipId"
+	// relationshipNameKey is the request metadata key filtering listed relationships by name
+	relationshipNameKey = "relationshipName"
+	// ifNoneMatchKey is the request metadata key preventing the creation of a relationship that exists when set to "*"
+	ifNoneMatchKey = "ifNoneMatch"
+	// telemetry request metadata keys: the ID of the message, generated when missing,
+	// the optional component the telemetry is sent from and the optional RFC3339 time the telemetry was produced
+	messageIDKey           = "messageId"
+	componentPathKey       = "componentPath"
+	telemetrySourceTimeKey = "telemetrySourceTime"
+	// modelIDKey is the request metadata key of the DTDL model ID of model operations,
+	// and of the model the patched twins must have when fetchModel is enabled
+	modelIDKey = "modelId"
+	// includeModelDefinitionKey is the request metadata key including the model definitions in listed models
+	includeModelDefinitionKey = "includeModelDefinition"
+	// maxPagesKey is the request metadata key limiting the number of result pages a query reads
+	maxPagesKey = "maxPages"
+
+	// queryOperation runs an Azure Digital Twins query, given in the request data
+	queryOperation bindings.OperationKind = "query"
+
+	// relationship operations, on the relationships of the twinID twin
+	createRelationshipOperation bindings.OperationKind = "createRelationship"
+	updateRelationshipOperation bindings.OperationKind = "updateRelationship"
+	listRelationshipsOperation  bindings.OperationKind = "listRelationships"
+	deleteRelationshipOperation bindings.OperationKind = "deleteRelationship"
+
+	// telemetryOperation sends the request data as telemetry of the twinID twin
+	telemetryOperation bindings.OperationKind = "telemetry"
+
+	// upsertOperation creates or replaces the twin with the twin document of the request data
+	upsertOperation bindings.OperationKind = "upsert"
+
+	// component operations read and patch the componentPath component of the twinID twin
+	getComponentOperation    bindings.OperationKind = "getComponent"
+	updateComponentOperation bindings.OperationKind = "updateComponent"
+
+	// DTDL model operations
+	createModelsOperation      bindings.OperationKind = "createModels"
+	listModelsOperation        bindings.OperationKind = "listModels"
+	decommissionModelOperation bindings.OperationKind = "decommissionModel"
+	deleteModelOperation       bindings.OperationKind = "deleteModel"
+
+	// operation names used in errors
+	patchOperation  = "patch"
+	getOperation    = "get"
+	deleteOperation = "delete"
+	notifyOperation = "notify"
+
+	// default limits of patch operation values, see maxPatchValueDepth and maxPatchValueSize
+	defaultMaxPatchValueDepth = 32
+	defaultMaxPatchValueSize  = 32 * 1024
+
+	// defaultMaxParallelism is the default number of twins of a multi-twin request patched concurrently
+	defaultMaxParallelism = 1
+	// defaultMaxIdleConnsPerHost is the default number of idle connections kept open to the instance, see maxIdleConnsPerHost
+	defaultMaxIdleConnsPerHost = 100
+
+	// defaultEventTypePrefix prefixes the operation in the type of notification events when no type is mapped
+	defaultEventTypePrefix = "com.dapr.binding.azure.digitaltwins."
 )
 
-// AzureDigitalTwins allows writing to a Azure Digital Twins instance
+// azureEnvironment is the AAD endpoint and the resource the access tokens are requested for in an Azure cloud
+type azureEnvironment struct {
+	aadEndpoint string
+	resource    string
+}
+
+// azureEnvironments maps the azureEnvironment metadata values to the public and sovereign clouds
+var azureEnvironments = map[string]azureEnvironment{
+	"public": {aadEndpoint: azure.PublicCloud.ActiveDirectoryEndpoint, resource: "https://digitaltwins.azure.net"},
+	"usgov":  {aadEndpoint: azure.USGovernmentCloud.ActiveDirectoryEndpoint, resource: "https://digitaltwins.azure.us"},
+	"china":  {aadEndpoint: azure.ChinaCloud.ActiveDirectoryEndpoint, resource: "https://digitaltwins.azure.cn"},
+}
+
+// apiVersionPattern matches Azure Digital Twins REST API versions, e.g. 2020-10-31 or 2021-06-30-preview
+var apiVersionPattern = regexp.MustCompile(`^\d{4}-\d{2}-\d{2}(-preview)?$`)
+
+// ErrPreconditionFailed is returned when a twin was modified since the ETag passed in ifMatch was read.
+// Callers should re-read the twin and retry with the fresh ETag.
+var ErrPreconditionFailed = errors.New("precondition failed, twin was modified")
+
+// ErrForbidden is returned when Azure Digital Twins denies the operation to the authenticated identity,
+// which usually lacks a data plane role assignment on the instance.
+var ErrForbidden = errors.New("forbidden, the identity lacks permission on the Azure Digital Twins instance")
+
+// ErrMixedPatchFormat is returned when a request mixes JSON Patch operations and merge patch documents
+// without setting the patchFormat metadata.
+var ErrMixedPatchFormat = errors.New("mixed JSON Patch and merge patch operations")
+
+// ErrPatchValueTooDeep is returned when the value of a patch operation is nested deeper than maxPatchValueDepth.
+var ErrPatchValueTooDeep = errors.New("patch value nesting too deep")
+
+// ErrPatchValueTooLarge is returned when the serialized value of a patch operation exceeds maxPatchValueSize bytes.
+var ErrPatchValueTooLarge = errors.New("patch value too large")
+
+// ErrModelMismatch is returned when fetchModel is enabled and the patched twin does not have the model of the request.
+var ErrModelMismatch = errors.New("twin model mismatch")
+
+// APIError is returned when Azure Digital Twins responds to an operation with an error status.
+// It carries the HTTP status and the error code and message of the response body.
+type APIError struct {
+	StatusCode int
+	Code       string
+	Message    string
+	err        error
+}
+
+func (e *APIError) Error() string {
+	if e.Code == "" && e.Message == "" {
+		return fmt.Sprintf("status %d: %s", e.StatusCode, e.err)
+	}
+
+	return fmt.Sprintf("status %d, %s: %s", e.StatusCode, e.Code, e.Message)
+}
+
+// Unwrap returns the REST client error
+func (e *APIError) Unwrap() error {
+	return e.err
+}
+
+// patchResult is the response data of patch requests
+type patchResult struct {
+	// Patched lists the twins that were patched
+	Patched []string `json:"patched"`
+	// Skipped lists the twins whose patch was skipped, see rejectStale
+	Skipped []string `json:"skipped,omitempty"`
+	// Failed lists the twins of multi-twin requests whose patch failed, so that they can be retried
+	Failed []twinFailure `json:"failed,omitempty"`
+}
+
+// twinFailure is the failure of the patch of a twin
+type twinFailure struct {
+	TwinID string `json:"twinId"`
+	// StatusCode is the HTTP status code of the failed Azure Digital Twins call, if any
+	StatusCode int    `json:"statusCode,omitempty"`
+	Error      string `json:"error"`
+}
+
+// add records the twin once, in the order of the operations
+func (r *patchResult) add(twins *[]string, twinID string) {
+	for _, t := range *twins {
+		if t == twinID {
+			return
+		}
+	}
+	*twins = append(*twins, twinID)
+}
+
+// response returns the patch result as an InvokeResponse
+func (r *patchResult) response() (*bindings.InvokeResponse, error) {
+	if r.Patched == nil {
+		r.Patched = []string{}
+	}
+
+	data, err := json.Marshal(r)
+	if err != nil {
+		return nil, wrapErr(patchOperation, "", err)
+	}
+
+	return &bindings.InvokeResponse{Data: data, Metadata: map[string]string{}}, nil
+}
+
+// ChangePublisher publishes the change notification event of an applied twin patch
+type ChangePublisher func(event *pubsub.CloudEvent) error
+
+// twinChange is the data of change notification events
+type twinChange struct {
+	TwinID     string        `json:"twinId"`
+	Operations []interface{} `json:"operations"`
+}
+
+// pathRewrite rewrites the twin relative paths of patch operations matching the regular expression
+type pathRewrite struct {
+	match   *regexp.Regexp
+	replace string
+}
+
+// pathRewriteRule is the pathRewrites metadata representation of a pathRewrite
+type pathRewriteRule struct {
+	Match   string `json:"match"`
+	Replace string `json:"replace"`
+}
+
+// AzureDigitalTwins allows writing to a Azure Digital Twins instance, and receiving its twin events as an input binding
 type AzureDigitalTwins struct {
-	clientID       string
-	clientSecret   string
-	tenantID       string
-	adtInstanceURL string
-	logger         logger.Logger
+	clientID     string
+	clientSecret string
+	tenantID     string
+	accessToken  string
+	authMethod   string
+	// certificate is the PEM client certificate and private key, certificateFile the path of a PFX file
+	certificate         string
+	certificateFile     string
+	certificatePassword string
+	adtInstanceURL      string
+	environment         azureEnvironment
+	// apiVersion overrides the REST API version of the generated client when set
+	apiVersion    string
+	rejectStale   bool
+	fetchModel    bool
+	eventTypes    map[bindings.OperationKind]string
+	maxValueDepth int
+	maxValueSize  int
+	// defaultMetadata is merged into the metadata of every request
+	defaultMetadata map[string]string
+	// failOnNotifyError makes change notification failures fail the patch, notifications are then published synchronously
+	failOnNotifyError bool
+	pathRewrites      []pathRewrite
+	maxParallelism    int
+	retry             retryPolicy
+	// eventPort is the port the input binding receives Event Grid deliveries on
+	eventPort string
+	publisher ChangePublisher
+	logger    logger.Logger
+
+	// authorizer and sender are shared by the REST clients of all invocations, created in Init unless already set.
+	// The AAD authorizers cache their token and refresh it before it expires,
+	// the sender pools the connections to the instance.
+	authorizer autorest.Authorizer
+	sender     autorest.Sender
 }
 
 type azureDigitalTwinsMetadata struct {
-	clientID       string `json:"clientId"`
-	clientSecret   string `json:"clientSecret"`
-	tenantID       string `json:"tenantId"`
-	adtInstanceURL string `json:"adtInstanceUrl"`
+	clientID            string `json:"clientId"`
+	clientSecret        string `json:"clientSecret"`
+	tenantID            string `json:"tenantId"`
+	accessToken         string `json:"accessToken"`
+	authMethod          string `json:"azureAuthMethod"`
+	certificate         string `json:"certificate"`
+	certificateFile     string `json:"certificateFile"`
+	certificatePassword string `json:"certificatePassword"`
+	environment         azureEnvironment
+	apiVersion          string `json:"apiVersion"`
+	adtInstanceURL      string `json:"adtInstanceUrl"`
+	rejectStale         bool   `json:"rejectStale"`
+	fetchModel          bool   `json:"fetchModel"`
+	eventTypes          map[bindings.OperationKind]string
+	maxValueDepth       int `json:"maxPatchValueDepth"`
+	maxValueSize        int `json:"maxPatchValueSize"`
+	defaultMetadata     map[string]string
+	failOnNotifyError   bool `json:"failOnNotifyError"`
+	pathRewrites        []pathRewrite
+	maxIdleConns        int `json:"maxIdleConnsPerHost"`
+	maxParallelism      int `json:"maxParallelism"`
+	retry               retryPolicy
+	eventPort           string `json:"eventPort"`
 }
 
 type jsonPatchOperation struct {
@@ -54,7 +333,7 @@ type jsonPatchOperation struct {
 
 // NewAzureDigitalTwins returns a new Azure Digital Twins binding instance
 func NewAzureDigitalTwins(logger logger.Logger) *AzureDigitalTwins {
-	return &AzureDigitalTwins{logger: logger}
+	return &AzureDigitalTwins{logger: logger, maxParallelism: defaultMaxParallelism, retry: newRetryPolicy()}
 }
 
 // Init does metadata parsing and connection establishment
@@ -69,56 +348,151 @@ func (d *AzureDigitalTwins) Init(metadata bindings.Metadata) error {
 	d.clientID = meta.clientID
 	d.clientSecret = meta.clientSecret
 	d.tenantID = meta.tenantID
+	d.accessToken = meta.accessToken
+	d.authMethod = meta.authMethod
+	d.certificate = meta.certificate
+	d.certificateFile = meta.certificateFile
+	d.certificatePassword = meta.certificatePassword
 	d.adtInstanceURL = meta.adtInstanceURL
+	d.environment = meta.environment
+	d.apiVersion = meta.apiVersion
+	d.rejectStale = meta.rejectStale
+	d.fetchModel = meta.fetchModel
+	d.eventTypes = meta.eventTypes
+	d.maxValueDepth = meta.maxValueDepth
+	d.maxValueSize = meta.maxValueSize
+	d.defaultMetadata = meta.defaultMetadata
+	d.failOnNotifyError = meta.failOnNotifyError
+	d.pathRewrites = meta.pathRewrites
+	d.maxParallelism = meta.maxParallelism
+	d.retry = meta.retry
+	d.eventPort = meta.eventPort
+
+	if d.authorizer == nil {
+		d.authorizer, err = d.getAuthorizer()
+		if err != nil {
+			return fmt.Errorf("azureDigitalTwins error: failed to create %s authorizer: %s", d.authMethod, err)
+		}
+	}
+
+	if d.sender == nil {
+		d.sender = newPooledSender(meta.maxIdleConns)
+	}
 
 	return nil
 }
 
-func (d *AzureDigitalTwins) patchSingleTwin(twinID string, req *bindings.InvokeRequest) (*bindings.InvokeResponse, error) {
+// newPooledSender returns an HTTP client keeping up to maxIdleConns idle connections per host open,
+// so that patches reuse connections instead of paying a TLS handshake each
+func newPooledSender(maxIdleConns int) *http.Client {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.MaxIdleConns = maxIdleConns
+	transport.MaxIdleConnsPerHost = maxIdleConns
+
+	return &http.Client{Transport: transport}
+}
 
-	d.logger.Debugf("Patching single twin")
+// SetChangePublisher enables change notifications: after each successful twin patch
+// the publisher is called with a CloudEvent describing the twin and the applied operations.
+func (d *AzureDigitalTwins) SetChangePublisher(publisher ChangePublisher) {
+	d.publisher = publisher
+}
+
+func (d *AzureDigitalTwins) patchSingleTwin(reqLogger logger.Logger, twinID string, req *bindings.InvokeRequest) (*bindings.InvokeResponse, error) {
+
+	reqLogger.Debugf("Patching single twin")
 	var operationDoc []jsonPatchOperation
 
 	err := json.Unmarshal(req.Data, &operationDoc)
 
 	if err != nil {
-		d.logger.Errorf("Request data json error: %s", err)
-		return nil, nil
+		return nil, wrapErr(patchOperation, twinID, fmt.Errorf("invalid request data: %w", err))
+	}
+
+	operationDoc, err = toJSONPatch(req.Data, operationDoc, req.Metadata[patchFormatKey])
+	if err != nil {
+		return nil, err
+	}
+
+	err = d.checkPatchValues(operationDoc)
+	if err != nil {
+		return nil, err
+	}
+
+	client, err := d.newClient()
+	if err != nil {
+		return nil, wrapErr(patchOperation, twinID, err)
 	}
 
-	ccc := auth.NewClientCredentialsConfig(d.clientID, d.clientSecret, d.tenantID)
-	ccc.Resource = "https://digitaltwins.azure.net"
+	result := &patchResult{}
+	skip, err := d.prepareTwin(reqLogger, newTwinCache(client), twinID, req.Metadata)
+	if err != nil {
+		return nil, err
+	}
 
-	client := digitaltwinsrest.NewDigitalTwinsClientWithBaseURI(d.adtInstanceURL)
-	authorizer, _ := ccc.Authorizer()
+	if skip {
+		result.add(&result.Skipped, twinID)
 
-	client.Authorizer = authorizer
+		return result.response()
+	}
 
 	s := make([]interface{}, len(operationDoc))
 	for i, v := range operationDoc {
+		v.Path = d.rewritePath(v.Path)
 		s[i] = v
 	}
 
-	client.Update(context.TODO(), twinID, s, "*", "", "")
+	etag, err := d.updateTwin(client, twinID, s, getIfMatch(req.Metadata, ifMatchKey, etagKey))
+	if err != nil {
+		return nil, err
+	}
+
+	result.add(&result.Patched, twinID)
+
+	err = d.notifyChange(reqLogger, req.Operation, twinID, s)
+	if err != nil {
+		return nil, err
+	}
+
+	response, err := result.response()
+	if err != nil {
+		return nil, err
+	}
+
+	if etag != "" {
+		response.Metadata[etagKey] = etag
+	}
 
-	return nil, nil
+	return response, nil
 }
 
-func (d *AzureDigitalTwins) patchMultipleTwin(req *bindings.InvokeRequest) (*bindings.InvokeResponse, error) {
+func (d *AzureDigitalTwins) patchMultipleTwin(reqLogger logger.Logger, req *bindings.InvokeRequest) (*bindings.InvokeResponse, error) {
 	var operationDoc []jsonPatchOperation
 
 	err := json.Unmarshal(req.Data, &operationDoc)
 
 	if err != nil {
-		d.logger.Errorf("Request data json error: %s", err)
-		return nil, nil
+		return nil, wrapErr(patchOperation, "", fmt.Errorf("invalid request data: %w", err))
+	}
+
+	format, err := patchFormat(req.Data, req.Metadata[patchFormatKey])
+	if err != nil {
+		return nil, err
+	}
+
+	if format != jsonPatchFormat {
+		return nil, wrapErr(patchOperation, "", errors.New("merge patch requires the twinID metadata"))
+	}
+
+	err = d.checkPatchValues(operationDoc)
+	if err != nil {
+		return nil, err
 	}
 
 	r, err := regexp.Compile("^/(.+?)\\/(.+)$")
 
 	if err != nil {
-		d.logger.Debugf("Regex compilation error: %s", err)
-		return nil, nil
+		return nil, wrapErr(patchOperation, "", err)
 	}
 
 	// First pass extracts twin id from patch operation path, fails entire request on error
@@ -126,196 +500,848 @@ func (d *AzureDigitalTwins) patchMultipleTwin(req *bindings.InvokeRequest) (*bin
 		matches := r.FindStringSubmatch(v.Path)
 
 		if len(matches) < 3 || len(matches) > 3 {
-			d.logger.Errorf("Invalid path in patch: %s", v.Path)
-			return nil, nil
+			return nil, wrapErr(patchOperation, "", fmt.Errorf("invalid path in operation %d: %s, expected /<twinId>/<property>", i, v.Path))
 		}
 
 		operationDoc[i].TwinID = matches[1]
-		operationDoc[i].Path = "/" + matches[2]
+		operationDoc[i].Path = d.rewritePath("/" + matches[2])
 
 		// Invoke
 	}
 
-	// Second pass invokes digital twins api
+	client, err := d.newClient()
+	if err != nil {
+		return nil, wrapErr(patchOperation, "", err)
+	}
+
+	// Second pass groups the operations by twin, in order, so that each twin is patched in a single call
+	var twinIDs []string
+	batches := map[string][]interface{}{}
+	for _, v := range operationDoc {
+		if _, ok := batches[v.TwinID]; !ok {
+			twinIDs = append(twinIDs, v.TwinID)
+		}
+		batches[v.TwinID] = append(batches[v.TwinID], v)
+	}
+
+	// Third pass patches up to maxParallelism twins concurrently
+	twins := newTwinCache(client)
+	outcomes := make([]twinOutcome, len(twinIDs))
+	sem := make(chan struct{}, d.maxParallelism)
+	var wg sync.WaitGroup
+	for i, twinID := range twinIDs {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(i int, twinID string) {
+			defer func() {
+				<-sem
+				wg.Done()
+			}()
+			outcomes[i] = d.patchTwin(reqLogger, client, twins, twinID, batches[twinID], req)
+		}(i, twinID)
+	}
+	wg.Wait()
+
+	// A request whose twins all failed fails, otherwise the failed twins are listed in the response
+	result := &patchResult{}
+	for i, twinID := range twinIDs {
+		o := outcomes[i]
+		switch {
+		case o.err != nil:
+			result.Failed = append(result.Failed, twinFailure{TwinID: twinID, StatusCode: errStatusCode(o.err), Error: o.err.Error()})
+		case o.skipped:
+			result.add(&result.Skipped, twinID)
+		default:
+			result.add(&result.Patched, twinID)
+		}
+	}
+
+	if len(result.Failed) == len(twinIDs) && len(twinIDs) > 0 {
+		return nil, outcomes[0].err
+	}
+
+	response, err := result.response()
+	if err != nil {
+		return nil, err
+	}
+
+	for i, twinID := range twinIDs {
+		if outcomes[i].updated {
+			response.Metadata[durationKey+"."+twinID] = strconv.FormatFloat(float64(outcomes[i].duration)/float64(time.Millisecond), 'f', 3, 64)
+		}
+
+		if outcomes[i].etag != "" {
+			response.Metadata[etagKey+"."+twinID] = outcomes[i].etag
+		}
+	}
+
+	return response, nil
+}
+
+// patchFormat returns the format of the operations, as set in the patchFormat metadata or detected.
+// Operations with string op and path members are JSON Patch operations, other objects are merge patch documents.
+func patchFormat(data []byte, format string) (string, error) {
+	switch format {
+	case jsonPatchFormat, mergePatchFormat:
+		return format, nil
+	case "":
+	default:
+		return "", wrapErr(patchOperation, "", fmt.Errorf("invalid %s %s, expected %s or %s", patchFormatKey, format, jsonPatchFormat, mergePatchFormat))
+	}
+
+	var items []map[string]interface{}
+	err := json.Unmarshal(data, &items)
+	if err != nil {
+		return "", wrapErr(patchOperation, "", err)
+	}
+
+	format = jsonPatchFormat
+	for i, item := range items {
+		itemFormat := mergePatchFormat
+		_, hasOp := item["op"].(string)
+		_, hasPath := item["path"].(string)
+		if hasOp && hasPath {
+			itemFormat = jsonPatchFormat
+		}
+
+		if i == 0 {
+			format = itemFormat
+		} else if itemFormat != format {
+			return "", wrapErr(patchOperation, "", fmt.Errorf("%w: operation 0 is %s, operation %d is %s, set %s to choose one", ErrMixedPatchFormat, format, i, itemFormat, patchFormatKey))
+		}
+	}
+
+	return format, nil
+}
+
+// toJSONPatch returns the operations as JSON Patch operations, converting merge patch documents:
+// each top level member is added, or removed when null.
+func toJSONPatch(data []byte, operationDoc []jsonPatchOperation, format string) ([]jsonPatchOperation, error) {
+	format, err := patchFormat(data, format)
+	if err != nil || format == jsonPatchFormat {
+		return operationDoc, err
+	}
+
+	var documents []map[string]interface{}
+	err = json.Unmarshal(data, &documents)
+	if err != nil {
+		return nil, wrapErr(patchOperation, "", err)
+	}
+
+	var operations []jsonPatchOperation
+	for _, document := range documents {
+		keys := make([]string, 0, len(document))
+		for k := range document {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+
+		for _, k := range keys {
+			path := "/" + strings.NewReplacer("~", "~0", "/", "~1").Replace(k)
+			if document[k] == nil {
+				operations = append(operations, jsonPatchOperation{Op: "remove", Path: path})
+			} else {
+				operations = append(operations, jsonPatchOperation{Op: "add", Path: path, Value: document[k]})
+			}
+		}
+	}
+
+	return operations, nil
+}
+
+// rewritePath applies the first path rewrite rule matching the twin relative path, if any
+func (d *AzureDigitalTwins) rewritePath(path string) string {
+	for _, rewrite := range d.pathRewrites {
+		if rewrite.match.MatchString(path) {
+			return rewrite.match.ReplaceAllString(path, rewrite.replace)
+		}
+	}
+
+	return path
+}
+
+// checkPatchValues rejects the patch when the value of an operation exceeds
+// the configured nesting depth or serialized size, naming the offending operation index.
+func (d *AzureDigitalTwins) checkPatchValues(operationDoc []jsonPatchOperation) error {
 	for i, v := range operationDoc {
-		patchDoc := []interface{}{v}
-		d.logger.Infof("[%d] Operation to submit to digital twin (%s): %s", i, v.TwinID, patchDoc)
-		b, err := json.Marshal(patchDoc)
-		if err != nil {
-			d.logger.Errorf("Error marshalling operation doc: %s", err)
-			return nil, nil
+		if d.maxValueDepth > 0 {
+			if depth := valueDepth(v.Value); depth > d.maxValueDepth {
+				return wrapErr(patchOperation, "", fmt.Errorf("operation %d: %w, depth %d exceeds maximum of %d", i, ErrPatchValueTooDeep, depth, d.maxValueDepth))
+			}
 		}
 
-		d.logger.Infof("Calling API for twin (%s) with patch: %s", v.TwinID, string(b))
+		if d.maxValueSize > 0 && v.Value != nil {
+			b, err := json.Marshal(v.Value)
+			if err != nil {
+				return wrapErr(patchOperation, "", fmt.Errorf("operation %d: %w", i, err))
+			}
 
-		//d.patchTwin(v)
+			if len(b) > d.maxValueSize {
+				return wrapErr(patchOperation, "", fmt.Errorf("operation %d: %w, %d bytes exceeds maximum of %d", i, ErrPatchValueTooLarge, len(b), d.maxValueSize))
+			}
+		}
+	}
 
-		ccc := auth.NewClientCredentialsConfig(d.clientID, d.clientSecret, d.tenantID)
-		ccc.Resource = "https://digitaltwins.azure.net"
+	return nil
+}
+
+// valueDepth returns the nesting depth of a decoded JSON value, scalars having a depth of 0
+func valueDepth(value interface{}) int {
+	depth := 0
+	switch v := value.(type) {
+	case map[string]interface{}:
+		for _, e := range v {
+			if d := valueDepth(e); d > depth {
+				depth = d
+			}
+		}
+	case []interface{}:
+		for _, e := range v {
+			if d := valueDepth(e); d > depth {
+				depth = d
+			}
+		}
+	default:
+		return 0
+	}
+
+	return depth + 1
+}
 
-		client := digitaltwinsrest.NewDigitalTwinsClientWithBaseURI(d.adtInstanceURL)
-		authorizer, _ := ccc.Authorizer()
+// twinOutcome is the outcome of the patch of a twin in a multi-twin request
+type twinOutcome struct {
+	skipped bool
+	// updated is set when the twin update was attempted, taking duration
+	updated  bool
+	duration time.Duration
+	etag     string
+	err      error
+}
+
+// patchTwin applies the operations of a multi-twin request to one of its twins
+func (d *AzureDigitalTwins) patchTwin(reqLogger logger.Logger, client digitaltwinsrest.DigitalTwinsClient, twins *twinCache, twinID string, patchDoc []interface{}, req *bindings.InvokeRequest) twinOutcome {
+	var o twinOutcome
+	b, err := json.Marshal(patchDoc)
+	if err != nil {
+		o.err = wrapErr(patchOperation, twinID, err)
+
+		return o
+	}
+
+	reqLogger.Infof("Calling API for twin (%s) with patch: %s", twinID, string(b))
 
-		client.Authorizer = authorizer
+	o.skipped, o.err = d.prepareTwin(reqLogger, twins, twinID, req.Metadata)
+	if o.err != nil || o.skipped {
+		return o
+	}
 
-		client.Update(context.TODO(), v.TwinID, patchDoc, "*", "", "")
+	start := time.Now()
+	o.etag, o.err = d.updateTwin(client, twinID, patchDoc, getIfMatch(req.Metadata, ifMatchKey+"."+twinID, etagKey+"."+twinID, ifMatchKey, etagKey))
+	o.duration = time.Since(start)
+	o.updated = true
+	if o.err != nil {
+		return o
 	}
 
-	return nil, nil
+	o.err = d.notifyChange(reqLogger, req.Operation, twinID, patchDoc)
+
+	return o
 }
 
-// Operations returns list of supported operations
-func (*AzureDigitalTwins) Operations() []bindings.OperationKind {
-	return []bindings.OperationKind{bindings.CreateOperation}
+// errStatusCode returns the HTTP status code of a failed Azure Digital Twins call, 0 when there is none
+func errStatusCode(err error) int {
+	var apiErr *APIError
+	switch {
+	case errors.As(err, &apiErr):
+		return apiErr.StatusCode
+	case errors.Is(err, ErrPreconditionFailed):
+		return http.StatusPreconditionFailed
+	case errors.Is(err, ErrForbidden):
+		return http.StatusForbidden
+	default:
+		return 0
+	}
 }
 
-// Invoke executes output binding
-// Expects twin id in path e.g., "path": "/myTwinId/property1"
-func (d *AzureDigitalTwins) Invoke(req *bindings.InvokeRequest) (*bindings.InvokeResponse, error) {
+// updateTwin applies the patch document to the twin, conditional on the given ETag, and returns the new ETag of the twin
+func (d *AzureDigitalTwins) updateTwin(client digitaltwinsrest.DigitalTwinsClient, twinID string, patchDoc []interface{}, ifMatch string) (string, error) {
+	res, err := client.Update(context.TODO(), twinID, patchDoc, ifMatch, "", "")
+	if err != nil {
+		return "", conditionalErr(patchOperation, twinID, res.Response, err, ifMatch)
+	}
 
-	d.logger.Infof("Invoke called with data: %s", req.Data)
-	d.logger.Infof("Invoke called with metadata: %s", req.Metadata)
+	return res.Header.Get("ETag"), nil
+}
 
-	if val, ok := req.Metadata["twinID"]; ok && val != "" {
-		d.logger.Infof("Metadata twinID: %s", val)
-		response, err := d.patchSingleTwin(val, req)
-		return response, err
-	} else {
-		d.logger.Infof("Metadata twinID not found.")
-		response, err := d.patchMultipleTwin(req)
-		return response, err
+// conditionalErr is responseErr for operations conditional on the ifMatch ETag,
+// a 412 returns ErrPreconditionFailed.
+func conditionalErr(op, twinID string, res *http.Response, err error, ifMatch string) error {
+	if res != nil && res.StatusCode == http.StatusPreconditionFailed {
+		return wrapErr(op, twinID, fmt.Errorf("%w: ETag %s", ErrPreconditionFailed, ifMatch))
+	}
+
+	return responseErr(op, twinID, res, err)
+}
+
+// responseErr maps the failed response of an operation to a typed error when possible.
+// A 403 means the identity was authenticated but is not authorized, so it points at the missing role.
+// Other error responses return an APIError with the status and error body.
+func responseErr(op, twinID string, res *http.Response, err error) error {
+	if res == nil {
+		return wrapErr(op, twinID, err)
+	}
+
+	if res.StatusCode == http.StatusForbidden {
+		return wrapErr(op, twinID, fmt.Errorf("%w: check that the identity is assigned the Azure Digital Twins Data Owner role on the instance, management plane roles such as Owner or Contributor do not grant data access", ErrForbidden))
 	}
 
-	// var operationDoc []jsonPatchOperation
+	apiErr := &APIError{StatusCode: res.StatusCode, err: err}
+	// The REST client wraps the parsed error body in a DetailedError, which does not support errors.Unwrap
+	var detailedErr autorest.DetailedError
+	if errors.As(err, &detailedErr) {
+		if requestErr, ok := detailedErr.Original.(*azure.RequestError); ok && requestErr.ServiceError != nil {
+			apiErr.Code = requestErr.ServiceError.Code
+			apiErr.Message = requestErr.ServiceError.Message
+		}
+	}
+
+	return wrapErr(op, twinID, apiErr)
+}
 
-	// err := json.Unmarshal(req.Data, &operationDoc)
+// notifyChange publishes the change notification of an applied patch when a publisher is set.
+// Unless failOnNotifyError is enabled, the notification is published in the background and failures are only logged.
+func (d *AzureDigitalTwins) notifyChange(reqLogger logger.Logger, op bindings.OperationKind, twinID string, patchDoc []interface{}) error {
+	if d.publisher == nil {
+		return nil
+	}
 
-	// if err != nil {
-	//	d.logger.Errorf("Request data json error: %s", err)
-	//	return nil, nil
-	// }
+	if op == "" {
+		op = bindings.CreateOperation
+	}
 
-	// r, err := regexp.Compile("^/(.+?)\\/(.+)$")
+	data, err := json.Marshal(twinChange{TwinID: twinID, Operations: patchDoc})
+	if err != nil {
+		return wrapErr(notifyOperation, twinID, err)
+	}
 
-	// if err != nil {
-	//	d.logger.Debugf("Regex compilation error: %s", err)
-	//	return nil, nil
-	// }
+	event := pubsub.NewCloudEvent("", d.adtInstanceURL, d.eventType(op), twinID, "", "", "application/json", data, "")
 
-	// First pass extracts twin id from patch operation path, fails entire request on error
-	// for i, v := range operationDoc {
-	// 	matches := r.FindStringSubmatch(v.Path)
+	if d.failOnNotifyError {
+		err = d.publisher(event)
+		if err != nil {
+			return wrapErr(notifyOperation, twinID, err)
+		}
 
-	//	if len(matches) < 3 || len(matches) > 3 {
-	//		d.logger.Errorf("Invalid path in patch: %s", v.Path)
-	//		return nil, nil
-	//	}
+		return nil
+	}
 
-	//	operationDoc[i].TwinID = matches[1]
-	//	operationDoc[i].Path = "/" + matches[2]
+	go func() {
+		err := d.publisher(event)
+		if err != nil {
+			reqLogger.Warnf("Error publishing change notification of twin (%s): %s", twinID, err)
+		}
+	}()
 
-	// Invoke
-	// }
+	return nil
+}
 
-	// Second pass invokes digital twins api
-	// for i, v := range operationDoc {
-	//	patchDoc := []interface{}{v}
-	//	d.logger.Infof("[%d] Operation to submit to digital twin (%s): %s", i, v.TwinID, patchDoc)
-	//	b, err := json.Marshal(patchDoc)
-	//	if err != nil {
-	//		d.logger.Errorf("Error marshalling operation doc: %s", err)
-	//		return nil, nil
-	//	}
+// wrapErr adds the operation and twin that failed to the error.
+// The original error remains available through errors.Unwrap, errors.Is and errors.As.
+func wrapErr(op, twinID string, err error) error {
+	if twinID == "" {
+		return fmt.Errorf("azureDigitalTwins error: %s: %w", op, err)
+	}
 
-	//	d.logger.Infof("Calling API for twin (%s) with patch: %s", v.TwinID, string(b))
-	//	d.patchTwin(v)
-	//}
+	return fmt.Errorf("azureDigitalTwins error: %s twin %s: %w", op, twinID, err)
+}
 
-	return nil, nil
+// twinCache fetches each twin at most once per request
+type twinCache struct {
+	client digitaltwinsrest.DigitalTwinsClient
+	lock   sync.Mutex
+	twins  map[string]map[string]interface{}
 }
 
-/*
-func (d *AzureDigitalTwins) patchTwin(patchOp jsonPatchOperation) {
-	ccc := auth.NewClientCredentialsConfig(d.clientID, d.clientSecret, d.tenantID)
-	ccc.Resource = "https://digitaltwins.azure.net"
+func newTwinCache(client digitaltwinsrest.DigitalTwinsClient) *twinCache {
+	return &twinCache{
+		client: client,
+		twins:  map[string]map[string]interface{}{},
+	}
+}
 
-	client := NewDigitalTwinsClientWithBaseURI(d.adtInstanceURL)
-	authorizer, _ := ccc.Authorizer()
+// get returns the twin, fetching it on first use
+func (c *twinCache) get(twinID string) (map[string]interface{}, error) {
+	c.lock.Lock()
+	twin, ok := c.twins[twinID]
+	c.lock.Unlock()
+	if ok {
+		return twin, nil
+	}
 
-	client.Authorizer = authorizer
+	res, err := c.client.GetByID(context.TODO(), twinID, "", "")
+	if err != nil {
+		return nil, responseErr(getOperation, twinID, res.Response.Response, err)
+	}
 
-	patchDoc := []interface{}{patchOp}
+	twin, _ = res.Value.(map[string]interface{})
+	c.lock.Lock()
+	c.twins[twinID] = twin
+	c.lock.Unlock()
 
-	client.Update(context.TODO(), patchOp.TwinID, patchDoc, "*", "", "")
+	return twin, nil
 }
-*/
 
-func (d *AzureDigitalTwins) digitalTwinUpdate(twinID string, patchDoc string) (bool, error) {
-	log.Printf("patchHttpTwin")
-	ccc := auth.NewClientCredentialsConfig(d.clientID, d.clientSecret, d.tenantID)
-	ccc.Resource = "https://digitaltwins.azure.net"
+// model returns the DTDL model ID of the twin, from its $metadata.$model
+func (c *twinCache) model(twinID string) (string, error) {
+	twin, err := c.get(twinID)
+	if err != nil {
+		return "", err
+	}
+
+	metadata, _ := twin["$metadata"].(map[string]interface{})
+	model, _ := metadata["$model"].(string)
+
+	return model, nil
+}
+
+// prepareTwin runs the optional steps preceding the patch of a twin
+// and returns true when the patch must be skipped.
+func (d *AzureDigitalTwins) prepareTwin(reqLogger logger.Logger, twins *twinCache, twinID string, metadata map[string]string) (bool, error) {
+	err := d.checkModel(twins, twinID, metadata)
+	if err != nil {
+		return false, err
+	}
+
+	stale, err := d.isStale(twins, twinID, metadata)
+	if err != nil {
+		return false, err
+	}
 
-	authorizer, _ := ccc.Authorizer()
+	if stale {
+		reqLogger.Infof("Skipping patch of twin (%s) older than the twin's last update", twinID)
+	}
 
-	baseURL := fmt.Sprintf("%s/digitaltwins/%s?api-version=2020-10-31", d.adtInstanceURL, twinID)
+	return stale, nil
+}
 
-	req, err := autorest.Prepare(&http.Request{},
-		authorizer.WithAuthorization(),
-		autorest.WithBaseURL(baseURL),
-		autorest.AsContentType("application/json-patch+json"),
-		autorest.AsPatch(),
-		autorest.WithHeader("If-Match", "*"),
-		autorest.WithString(patchDoc),
-	)
+// checkModel returns an error wrapping ErrModelMismatch, when fetchModel is enabled,
+// if the twin does not have the model set by the modelId metadata of the request.
+// The twin is not fetched when the request does not set a model.
+func (d *AzureDigitalTwins) checkModel(twins *twinCache, twinID string, metadata map[string]string) error {
+	expected := metadata[modelIDKey]
+	if !d.fetchModel || expected == "" {
+		return nil
+	}
 
+	model, err := twins.model(twinID)
 	if err != nil {
-		log.Printf("Error creating request: %s", err)
+		return err
+	}
+
+	if model != expected {
+		return wrapErr(patchOperation, twinID, fmt.Errorf("%w: twin has model %s, expected %s", ErrModelMismatch, model, expected))
 	}
 
-	logger := log.New(os.Stdout, "autorest: ", 0)
+	return nil
+}
+
+// isStale determines, when rejectStale is enabled, if the event a patch originates from
+// is older than the last update of the twin, so that delayed events do not overwrite newer state.
+// Patches without an event time in the request metadata are never stale.
+func (d *AzureDigitalTwins) isStale(twins *twinCache, twinID string, metadata map[string]string) (bool, error) {
+	if !d.rejectStale {
+		return false, nil
+	}
 
-	resp, err := autorest.Send(req,
-		autorest.WithLogging(logger),
-		autorest.DoErrorIfStatusCode(http.StatusInternalServerError),
-		autorest.DoCloseIfError(),
-	)
+	val, ok := metadata[eventTimeKey]
+	if !ok || val == "" {
+		return false, nil
+	}
 
+	eventTime, err := time.Parse(time.RFC3339, val)
 	if err != nil {
-		log.Printf("Error creating request: %s", err)
+		return false, wrapErr(patchOperation, twinID, fmt.Errorf("invalid %s metadata %s, expected RFC3339: %w", eventTimeKey, val, err))
 	}
 
-	bodyBytes, err := ioutil.ReadAll(resp.Body)
+	twin, err := twins.get(twinID)
 	if err != nil {
-		log.Fatal(err)
+		return false, err
 	}
-	bodyString := string(bodyBytes)
-	log.Println(bodyString)
 
-	err = autorest.Respond(resp,
-		autorest.ByClosing())
+	lastUpdateTime, ok := getLastUpdateTime(twin)
+	if !ok {
+		return false, nil
+	}
 
-	return true, nil
+	return eventTime.Before(lastUpdateTime), nil
 }
 
-func (*AzureDigitalTwins) getAzureDigitalTwinsMetadata(metadata bindings.Metadata) (*azureDigitalTwinsMetadata, error) {
-	meta := azureDigitalTwinsMetadata{}
+// getLastUpdateTime returns the most recent update time found in the twin's $metadata,
+// either the twin level $lastUpdateTime or the lastUpdateTime of any property
+func getLastUpdateTime(twin map[string]interface{}) (time.Time, bool) {
+	metadata, _ := twin["$metadata"].(map[string]interface{})
+
+	var lastUpdateTime time.Time
+	found := false
+	update := func(v interface{}) {
+		s, _ := v.(string)
+		updateTime, err := time.Parse(time.RFC3339Nano, s)
+		if err == nil && updateTime.After(lastUpdateTime) {
+			lastUpdateTime = updateTime
+			found = true
+		}
+	}
 
-	if val, ok := metadata.Properties["clientId"]; ok && val != "" {
-		meta.clientID = val
-	} else {
-		return nil, errors.New("azureDigitalTwins error: missing clientId")
+	update(metadata["$lastUpdateTime"])
+	for _, v := range metadata {
+		if property, ok := v.(map[string]interface{}); ok {
+			update(property["lastUpdateTime"])
+		}
 	}
 
-	if val, ok := metadata.Properties["clientSecret"]; ok && val != "" {
-		meta.clientSecret = val
-	} else {
-		return nil, errors.New("azureDigitalTwins error: missing clientSecret")
+	return lastUpdateTime, found
+}
+
+// eventType returns the CloudEvents type of notification events about the operation,
+// as mapped in the eventTypes metadata or derived from the operation name.
+func (d *AzureDigitalTwins) eventType(op bindings.OperationKind) string {
+	if eventType, ok := d.eventTypes[op]; ok {
+		return eventType
 	}
 
-	if val, ok := metadata.Properties["tenantId"]; ok && val != "" {
-		meta.tenantID = val
-	} else {
-		return nil, errors.New("azureDigitalTwins error: missing tenantId")
+	return defaultEventTypePrefix + string(op)
+}
+
+// mergeMetadata returns the request metadata completed with the defaults, request values taking precedence.
+// Neither map is modified.
+func mergeMetadata(defaults, metadata map[string]string) map[string]string {
+	merged := make(map[string]string, len(defaults)+len(metadata))
+	for k, v := range defaults {
+		merged[k] = v
+	}
+	for k, v := range metadata {
+		merged[k] = v
+	}
+
+	return merged
+}
+
+// getCorrelationID returns the correlation ID from the request metadata, or a new one
+func getCorrelationID(metadata map[string]string) string {
+	if val, ok := metadata[correlationIDKey]; ok && val != "" {
+		return val
+	}
+
+	return uuid.New().String()
+}
+
+// correlationLogger prefixes the formatted log lines of an Invoke with its correlation ID
+type correlationLogger struct {
+	logger.Logger
+	correlationID string
+}
+
+func (l *correlationLogger) Infof(format string, args ...interface{}) {
+	l.Logger.Infof("[%s=%s] "+format, l.args(args)...)
+}
+
+func (l *correlationLogger) Debugf(format string, args ...interface{}) {
+	l.Logger.Debugf("[%s=%s] "+format, l.args(args)...)
+}
+
+func (l *correlationLogger) Warnf(format string, args ...interface{}) {
+	l.Logger.Warnf("[%s=%s] "+format, l.args(args)...)
+}
+
+func (l *correlationLogger) Errorf(format string, args ...interface{}) {
+	l.Logger.Errorf("[%s=%s] "+format, l.args(args)...)
+}
+
+func (l *correlationLogger) args(args []interface{}) []interface{} {
+	return append([]interface{}{correlationIDKey, l.correlationID}, args...)
+}
+
+// getIfMatch returns the first non-empty ETag found under the given metadata keys, or "*"
+func getIfMatch(metadata map[string]string, keys ...string) string {
+	for _, k := range keys {
+		if val, ok := metadata[k]; ok && val != "" {
+			return val
+		}
+	}
+
+	return anyETag
+}
+
+// newClient creates a REST client for the twins of the configured Azure Digital Twins instance
+func (d *AzureDigitalTwins) newClient() (digitaltwinsrest.DigitalTwinsClient, error) {
+	client, err := d.newBaseClient()
+
+	return digitaltwinsrest.DigitalTwinsClient{BaseClient: client}, err
+}
+
+// newBaseClient creates a REST client for the configured Azure Digital Twins instance,
+// used by the clients of the different APIs. The clients are cheap, they share the authorizer and sender created in Init.
+func (d *AzureDigitalTwins) newBaseClient() (digitaltwinsrest.BaseClient, error) {
+	client := digitaltwinsrest.NewWithBaseURI(d.adtInstanceURL)
+	if d.authorizer == nil {
+		return client, errors.New("binding is not initialized")
+	}
+
+	client.Authorizer = d.authorizer
+	if d.sender != nil {
+		client.Sender = d.sender
+	}
+
+	if d.apiVersion != "" {
+		client.RequestInspector = withAPIVersion(d.apiVersion)
+	}
+
+	client.SendDecorators = []autorest.SendDecorator{d.retry.sendDecorator()}
+
+	return client, nil
+}
+
+// withAPIVersion replaces the REST API version the generated client requests were prepared with
+func withAPIVersion(apiVersion string) autorest.PrepareDecorator {
+	return func(p autorest.Preparer) autorest.Preparer {
+		return autorest.PreparerFunc(func(r *http.Request) (*http.Request, error) {
+			r, err := p.Prepare(r)
+			if err != nil {
+				return r, err
+			}
+
+			query := r.URL.Query()
+			query.Set(apiVersionQueryKey, apiVersion)
+			r.URL.RawQuery = query.Encode()
+
+			return r, nil
+		})
+	}
+}
+
+// Operations returns list of supported operations
+func (*AzureDigitalTwins) Operations() []bindings.OperationKind {
+	return []bindings.OperationKind{
+		bindings.CreateOperation,
+		bindings.GetOperation,
+		bindings.DeleteOperation,
+		queryOperation,
+		createRelationshipOperation,
+		updateRelationshipOperation,
+		listRelationshipsOperation,
+		deleteRelationshipOperation,
+		telemetryOperation,
+		upsertOperation,
+		getComponentOperation,
+		updateComponentOperation,
+		createModelsOperation,
+		listModelsOperation,
+		decommissionModelOperation,
+		deleteModelOperation,
+	}
+}
+
+// Invoke executes output binding
+// Expects twin id in path e.g., "path": "/myTwinId/property1"
+func (d *AzureDigitalTwins) Invoke(req *bindings.InvokeRequest) (*bindings.InvokeResponse, error) {
+
+	req = &bindings.InvokeRequest{
+		Data:      req.Data,
+		Metadata:  mergeMetadata(d.defaultMetadata, req.Metadata),
+		Operation: req.Operation,
+	}
+
+	correlationID := getCorrelationID(req.Metadata)
+	reqLogger := &correlationLogger{Logger: d.logger, correlationID: correlationID}
+
+	reqLogger.Infof("Invoke called with data: %s", req.Data)
+	reqLogger.Infof("Invoke called with metadata: %s", req.Metadata)
+
+	var response *bindings.InvokeResponse
+	var err error
+	switch req.Operation {
+	case bindings.GetOperation:
+		response, err = d.getTwin(req)
+	case bindings.DeleteOperation:
+		response, err = d.deleteTwin(req)
+	case queryOperation:
+		response, err = d.queryTwins(req)
+	case createRelationshipOperation:
+		response, err = d.createRelationship(req)
+	case updateRelationshipOperation:
+		response, err = d.updateRelationship(req)
+	case listRelationshipsOperation:
+		response, err = d.listRelationships(req)
+	case deleteRelationshipOperation:
+		response, err = d.deleteRelationship(req)
+	case telemetryOperation:
+		response, err = d.sendTelemetry(req)
+	case upsertOperation:
+		response, err = d.upsertTwin(req)
+	case getComponentOperation:
+		response, err = d.getComponent(req)
+	case updateComponentOperation:
+		response, err = d.updateComponent(req)
+	case createModelsOperation:
+		response, err = d.createModels(req)
+	case listModelsOperation:
+		response, err = d.listModels(req)
+	case decommissionModelOperation:
+		response, err = d.decommissionModel(req)
+	case deleteModelOperation:
+		response, err = d.deleteModel(req)
+	case bindings.CreateOperation, "":
+		if val, ok := req.Metadata[twinIDKey]; ok && val != "" {
+			reqLogger.Infof("Metadata twinID: %s", val)
+			response, err = d.patchSingleTwin(reqLogger, val, req)
+		} else {
+			reqLogger.Infof("Metadata twinID not found.")
+			response, err = d.patchMultipleTwin(reqLogger, req)
+		}
+	default:
+		err = fmt.Errorf("azureDigitalTwins error: unsupported operation %s", req.Operation)
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	if response == nil {
+		response = &bindings.InvokeResponse{}
+	}
+	if response.Metadata == nil {
+		response.Metadata = map[string]string{}
+	}
+	response.Metadata[correlationIDKey] = correlationID
+
+	return response, nil
+}
+
+// staticToken provides a fixed bearer token
+type staticToken string
+
+func (t staticToken) OAuthToken() string {
+	return string(t)
+}
+
+// getAuthorizer returns the authorizer of the configured auth method:
+// a static bearer token for emulators and tests, a service principal with a secret or certificate, the environment credentials
+// (see auth.NewAuthorizerFromEnvironment) or a managed identity (user-assigned when a client ID is configured).
+func (d *AzureDigitalTwins) getAuthorizer() (autorest.Authorizer, error) {
+	switch d.authMethod {
+	case authMethodAccessToken:
+		return autorest.NewBearerAuthorizer(staticToken(d.accessToken)), nil
+	case authMethodClientSecret:
+		ccc := auth.NewClientCredentialsConfig(d.clientID, d.clientSecret, d.tenantID)
+		ccc.AADEndpoint = d.environment.aadEndpoint
+		ccc.Resource = d.environment.resource
+
+		return ccc.Authorizer()
+	case authMethodClientCertificate:
+		if d.certificateFile != "" {
+			ccc := auth.NewClientCertificateConfig(d.certificateFile, d.certificatePassword, d.clientID, d.tenantID)
+			ccc.AADEndpoint = d.environment.aadEndpoint
+			ccc.Resource = d.environment.resource
+
+			return ccc.Authorizer()
+		}
+
+		return d.certificateAuthorizer()
+	case authMethodEnvironment:
+		// the AAD endpoint is that of the AZURE_ENVIRONMENT environment variable
+		return auth.NewAuthorizerFromEnvironmentWithResource(d.environment.resource)
+	default:
+		msi := auth.NewMSIConfig()
+		msi.Resource = d.environment.resource
+		msi.ClientID = d.clientID
+
+		return msi.Authorizer()
+	}
+}
+
+func (*AzureDigitalTwins) getAzureDigitalTwinsMetadata(metadata bindings.Metadata) (*azureDigitalTwinsMetadata, error) {
+	meta := azureDigitalTwinsMetadata{
+		clientID:     metadata.Properties["clientId"],
+		clientSecret: metadata.Properties["clientSecret"],
+		tenantID:     metadata.Properties["tenantId"],
+		accessToken:  metadata.Properties["accessToken"],
+		// secret store references of the certificate are resolved by the runtime, like those of clientSecret
+		certificate:         metadata.Properties["certificate"],
+		certificateFile:     metadata.Properties["certificateFile"],
+		certificatePassword: metadata.Properties["certificatePassword"],
+	}
+
+	// Without azureAuthMethod, the auth method is inferred from the credentials:
+	// an access token disables AAD auth, so that a local emulator or mock can be targeted through adtInstanceUrl,
+	// a client secret or certificate selects service principal auth, which needs the full credential set,
+	// otherwise managed identity is used and the optional clientId picks a user-assigned identity.
+	meta.authMethod = metadata.Properties["azureAuthMethod"]
+	if meta.authMethod == "" {
+		switch {
+		case meta.accessToken != "":
+			meta.authMethod = authMethodAccessToken
+		case meta.clientSecret != "":
+			meta.authMethod = authMethodClientSecret
+		case meta.certificate != "" || meta.certificateFile != "":
+			meta.authMethod = authMethodClientCertificate
+		default:
+			meta.authMethod = authMethodManagedIdentity
+		}
+	}
+
+	switch meta.authMethod {
+	case authMethodAccessToken:
+		if meta.accessToken == "" {
+			return nil, errors.New("azureDigitalTwins error: missing accessToken for access token auth")
+		}
+
+		if meta.clientSecret != "" {
+			return nil, errors.New("azureDigitalTwins error: accessToken and clientSecret are mutually exclusive")
+		}
+	case authMethodClientSecret:
+		if meta.clientSecret == "" {
+			return nil, errors.New("azureDigitalTwins error: missing clientSecret for service principal auth")
+		}
+
+		if meta.clientID == "" {
+			return nil, errors.New("azureDigitalTwins error: missing clientId for service principal auth")
+		}
+
+		if meta.tenantID == "" {
+			return nil, errors.New("azureDigitalTwins error: missing tenantId for service principal auth")
+		}
+	case authMethodClientCertificate:
+		if meta.certificate == "" && meta.certificateFile == "" {
+			return nil, errors.New("azureDigitalTwins error: missing certificate or certificateFile for certificate auth")
+		}
+
+		if meta.certificate != "" && meta.certificateFile != "" {
+			return nil, errors.New("azureDigitalTwins error: certificate and certificateFile are mutually exclusive")
+		}
+
+		if meta.clientSecret != "" {
+			return nil, errors.New("azureDigitalTwins error: clientSecret is not used by certificate auth")
+		}
+
+		if meta.clientID == "" {
+			return nil, errors.New("azureDigitalTwins error: missing clientId for certificate auth")
+		}
+
+		if meta.tenantID == "" {
+			return nil, errors.New("azureDigitalTwins error: missing tenantId for certificate auth")
+		}
+	case authMethodManagedIdentity:
+		if meta.clientSecret != "" {
+			return nil, errors.New("azureDigitalTwins error: clientSecret is not used by managed identity auth")
+		}
+
+		if meta.tenantID != "" {
+			return nil, errors.New("azureDigitalTwins error: missing clientSecret for service principal auth, remove tenantId to use managed identity")
+		}
+	case authMethodEnvironment:
+	default:
+		return nil, fmt.Errorf("azureDigitalTwins error: invalid azureAuthMethod %s, expected %s, %s, %s, %s or %s",
+			meta.authMethod, authMethodClientSecret, authMethodClientCertificate, authMethodManagedIdentity, authMethodEnvironment, authMethodAccessToken)
 	}
 
 	if val, ok := metadata.Properties["adtInstanceUrl"]; ok && val != "" {
@@ -324,5 +1350,156 @@ func (*AzureDigitalTwins) getAzureDigitalTwinsMetadata(metadata bindings.Metadat
 		return nil, errors.New("azureDigitalTwins error: missing adtInstanceUrl")
 	}
 
+	// azureEnvironment selects the cloud of the instance, public, usgov or china
+	environmentName := defaultAzureEnvironment
+	if val, ok := metadata.Properties["azureEnvironment"]; ok && val != "" {
+		environmentName = strings.ToLower(val)
+	}
+	environment, ok := azureEnvironments[environmentName]
+	if !ok {
+		return nil, fmt.Errorf("azureDigitalTwins error: invalid azureEnvironment %s, expected public, usgov or china", environmentName)
+	}
+	meta.environment = environment
+
+	if val, ok := metadata.Properties["apiVersion"]; ok && val != "" {
+		if !apiVersionPattern.MatchString(val) {
+			return nil, fmt.Errorf("azureDigitalTwins error: invalid apiVersion %s, expected YYYY-MM-DD or YYYY-MM-DD-preview", val)
+		}
+		meta.apiVersion = val
+	}
+
+	if val, ok := metadata.Properties["rejectStale"]; ok && val != "" {
+		rejectStale, err := strconv.ParseBool(val)
+		if err != nil {
+			return nil, fmt.Errorf("azureDigitalTwins error: invalid rejectStale %s: %s", val, err)
+		}
+		meta.rejectStale = rejectStale
+	}
+
+	if val, ok := metadata.Properties["fetchModel"]; ok && val != "" {
+		fetchModel, err := strconv.ParseBool(val)
+		if err != nil {
+			return nil, fmt.Errorf("azureDigitalTwins error: invalid fetchModel %s: %s", val, err)
+		}
+		meta.fetchModel = fetchModel
+	}
+
+	meta.maxValueDepth = defaultMaxPatchValueDepth
+	if val, ok := metadata.Properties["maxPatchValueDepth"]; ok && val != "" {
+		maxValueDepth, err := strconv.Atoi(val)
+		if err != nil {
+			return nil, fmt.Errorf("azureDigitalTwins error: invalid maxPatchValueDepth %s: %s", val, err)
+		}
+		meta.maxValueDepth = maxValueDepth
+	}
+
+	meta.maxValueSize = defaultMaxPatchValueSize
+	if val, ok := metadata.Properties["maxPatchValueSize"]; ok && val != "" {
+		maxValueSize, err := strconv.Atoi(val)
+		if err != nil {
+			return nil, fmt.Errorf("azureDigitalTwins error: invalid maxPatchValueSize %s: %s", val, err)
+		}
+		meta.maxValueSize = maxValueSize
+	}
+
+	meta.eventPort = metadata.Properties["eventPort"]
+	if meta.eventPort != "" {
+		port, err := strconv.Atoi(meta.eventPort)
+		if err != nil || port < 1 || port > 65535 {
+			return nil, fmt.Errorf("azureDigitalTwins error: invalid eventPort %s", meta.eventPort)
+		}
+	}
+
+	meta.maxParallelism = defaultMaxParallelism
+	if val, ok := metadata.Properties["maxParallelism"]; ok && val != "" {
+		maxParallelism, err := strconv.Atoi(val)
+		if err != nil || maxParallelism < 1 {
+			return nil, fmt.Errorf("azureDigitalTwins error: invalid maxParallelism %s, expected a positive integer", val)
+		}
+		meta.maxParallelism = maxParallelism
+	}
+
+	meta.retry = newRetryPolicy()
+	if val, ok := metadata.Properties["maxRetries"]; ok && val != "" {
+		maxRetries, err := strconv.Atoi(val)
+		if err != nil || maxRetries < 0 {
+			return nil, fmt.Errorf("azureDigitalTwins error: invalid maxRetries %s, expected a non-negative integer", val)
+		}
+		meta.retry.maxRetries = maxRetries
+	}
+
+	if val, ok := metadata.Properties["initialBackoff"]; ok && val != "" {
+		initialBackoff, err := time.ParseDuration(val)
+		if err != nil || initialBackoff <= 0 {
+			return nil, fmt.Errorf("azureDigitalTwins error: invalid initialBackoff %s, expected a positive duration", val)
+		}
+		meta.retry.initialBackoff = initialBackoff
+	}
+
+	if val, ok := metadata.Properties["maxBackoff"]; ok && val != "" {
+		maxBackoff, err := time.ParseDuration(val)
+		if err != nil || maxBackoff <= 0 {
+			return nil, fmt.Errorf("azureDigitalTwins error: invalid maxBackoff %s, expected a positive duration", val)
+		}
+		meta.retry.maxBackoff = maxBackoff
+	}
+
+	if meta.retry.maxBackoff < meta.retry.initialBackoff {
+		return nil, errors.New("azureDigitalTwins error: maxBackoff must not be less than initialBackoff")
+	}
+
+	meta.maxIdleConns = defaultMaxIdleConnsPerHost
+	if val, ok := metadata.Properties["maxIdleConnsPerHost"]; ok && val != "" {
+		maxIdleConns, err := strconv.Atoi(val)
+		if err != nil || maxIdleConns < 1 {
+			return nil, fmt.Errorf("azureDigitalTwins error: invalid maxIdleConnsPerHost %s, expected a positive integer", val)
+		}
+		meta.maxIdleConns = maxIdleConns
+	}
+
+	if val, ok := metadata.Properties["failOnNotifyError"]; ok && val != "" {
+		failOnNotifyError, err := strconv.ParseBool(val)
+		if err != nil {
+			return nil, fmt.Errorf("azureDigitalTwins error: invalid failOnNotifyError %s: %s", val, err)
+		}
+		meta.failOnNotifyError = failOnNotifyError
+	}
+
+	// pathRewrites is a JSON array of rules, e.g. [{"match": "^/sensors/temp$", "replace": "/temperature"}]
+	if val, ok := metadata.Properties["pathRewrites"]; ok && val != "" {
+		var rules []pathRewriteRule
+		err := json.Unmarshal([]byte(val), &rules)
+		if err != nil {
+			return nil, fmt.Errorf("azureDigitalTwins error: invalid pathRewrites: %s", err)
+		}
+
+		for _, rule := range rules {
+			match, err := regexp.Compile(rule.Match)
+			if err != nil {
+				return nil, fmt.Errorf("azureDigitalTwins error: invalid pathRewrites match %s: %s", rule.Match, err)
+			}
+			meta.pathRewrites = append(meta.pathRewrites, pathRewrite{match: match, replace: rule.Replace})
+		}
+	}
+
+	meta.defaultMetadata = map[string]string{}
+	for k, v := range metadata.Properties {
+		if strings.HasPrefix(k, defaultMetadataPrefix) && len(k) > len(defaultMetadataPrefix) {
+			meta.defaultMetadata[strings.TrimPrefix(k, defaultMetadataPrefix)] = v
+		}
+	}
+
+	// eventTypes maps operations to CloudEvents types, e.g. "create=com.acme.twin.created,delete=com.acme.twin.deleted"
+	meta.eventTypes = map[bindings.OperationKind]string{}
+	if val, ok := metadata.Properties["eventTypes"]; ok && val != "" {
+		for _, mapping := range strings.Split(val, ",") {
+			parts := strings.SplitN(mapping, "=", 2)
+			if len(parts) != 2 || strings.TrimSpace(parts[0]) == "" || strings.TrimSpace(parts[1]) == "" {
+				return nil, fmt.Errorf("azureDigitalTwins error: invalid eventTypes mapping %s, expected operation=type", mapping)
+			}
+			meta.eventTypes[bindings.OperationKind(strings.TrimSpace(parts[0]))] = strings.TrimSpace(parts[1])
+		}
+	}
+
 	return &meta, nil
 }
@@ -0,0 +1,75 @@
+// +build integration_test
+
+// ------------------------------------------------------------
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT License.
+// ------------------------------------------------------------
+
+package digitaltwins
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"github.com/dapr/components-contrib/bindings"
+	"github.com/dapr/dapr/pkg/logger"
+	"github.com/stretchr/testify/assert"
+)
+
+// adtEmulator is a minimal Azure Digital Twins data plane serving the twin update API
+type adtEmulator struct {
+	lock    sync.Mutex
+	patches map[string][]map[string]interface{}
+	tokens  []string
+}
+
+func (e *adtEmulator) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	e.lock.Lock()
+	defer e.lock.Unlock()
+
+	e.tokens = append(e.tokens, r.Header.Get("Authorization"))
+
+	if r.Method != http.MethodPatch || r.URL.Query().Get("api-version") == "" {
+		w.WriteHeader(http.StatusNotFound)
+
+		return
+	}
+
+	var patch []map[string]interface{}
+	err := json.NewDecoder(r.Body).Decode(&patch)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+
+		return
+	}
+
+	twinID := r.URL.Path[len("/digitaltwins/"):]
+	e.patches[twinID] = append(e.patches[twinID], patch...)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func TestEmulator(t *testing.T) {
+	emulator := &adtEmulator{patches: map[string][]map[string]interface{}{}}
+	server := httptest.NewServer(emulator)
+	defer server.Close()
+
+	d := NewAzureDigitalTwins(logger.NewLogger("test"))
+	err := d.Init(bindings.Metadata{Properties: map[string]string{
+		"adtInstanceUrl": server.URL,
+		"accessToken":    "emulator",
+	}})
+	assert.NoError(t, err)
+
+	_, err = d.Invoke(&bindings.InvokeRequest{
+		Data:     []byte(`[{"op": "replace", "path": "/twinA/temperature", "value": 20}, {"op": "add", "path": "/twinB/humidity", "value": 50}]`),
+		Metadata: map[string]string{},
+	})
+	assert.NoError(t, err)
+
+	assert.Equal(t, []map[string]interface{}{{"op": "replace", "path": "/temperature", "value": 20.0}}, emulator.patches["twinA"])
+	assert.Equal(t, []map[string]interface{}{{"op": "add", "path": "/humidity", "value": 50.0}}, emulator.patches["twinB"])
+	assert.Equal(t, []string{"Bearer emulator", "Bearer emulator"}, emulator.tokens)
+}
@@ -1 +1,1225 @@
-package digitaltwins
\ No newline at end of file
+// ------------------------------------------------------------
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT License.
+// ------------------------------------------------------------
+
+package digitaltwins
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Azure/go-autorest/autorest"
+	"github.com/dapr/components-contrib/bindings"
+	"github.com/dapr/components-contrib/pubsub"
+	"github.com/dapr/dapr/pkg/logger"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseMetadata(t *testing.T) {
+	d := NewAzureDigitalTwins(logger.NewLogger("test"))
+
+	t.Run("service principal", func(t *testing.T) {
+		m := bindings.Metadata{Properties: map[string]string{
+			"clientId":       "id",
+			"clientSecret":   "secret",
+			"tenantId":       "tenant",
+			"adtInstanceUrl": "https://adt.example",
+		}}
+		meta, err := d.getAzureDigitalTwinsMetadata(m)
+		assert.NoError(t, err)
+		assert.Equal(t, "id", meta.clientID)
+		assert.Equal(t, "secret", meta.clientSecret)
+		assert.Equal(t, "tenant", meta.tenantID)
+		assert.Equal(t, "https://adt.example", meta.adtInstanceURL)
+	})
+
+	t.Run("system-assigned managed identity", func(t *testing.T) {
+		m := bindings.Metadata{Properties: map[string]string{
+			"adtInstanceUrl": "https://adt.example",
+		}}
+		meta, err := d.getAzureDigitalTwinsMetadata(m)
+		assert.NoError(t, err)
+		assert.Empty(t, meta.clientID)
+		assert.Empty(t, meta.clientSecret)
+	})
+
+	t.Run("user-assigned managed identity", func(t *testing.T) {
+		m := bindings.Metadata{Properties: map[string]string{
+			"clientId":       "id",
+			"adtInstanceUrl": "https://adt.example",
+		}}
+		meta, err := d.getAzureDigitalTwinsMetadata(m)
+		assert.NoError(t, err)
+		assert.Equal(t, "id", meta.clientID)
+		assert.Empty(t, meta.clientSecret)
+	})
+
+	t.Run("secret without clientId", func(t *testing.T) {
+		m := bindings.Metadata{Properties: map[string]string{
+			"clientSecret":   "secret",
+			"tenantId":       "tenant",
+			"adtInstanceUrl": "https://adt.example",
+		}}
+		_, err := d.getAzureDigitalTwinsMetadata(m)
+		assert.Error(t, err)
+	})
+
+	t.Run("secret without tenantId", func(t *testing.T) {
+		m := bindings.Metadata{Properties: map[string]string{
+			"clientId":       "id",
+			"clientSecret":   "secret",
+			"adtInstanceUrl": "https://adt.example",
+		}}
+		_, err := d.getAzureDigitalTwinsMetadata(m)
+		assert.Error(t, err)
+	})
+
+	t.Run("tenantId without secret", func(t *testing.T) {
+		m := bindings.Metadata{Properties: map[string]string{
+			"clientId":       "id",
+			"tenantId":       "tenant",
+			"adtInstanceUrl": "https://adt.example",
+		}}
+		_, err := d.getAzureDigitalTwinsMetadata(m)
+		assert.Error(t, err)
+	})
+
+	t.Run("azureAuthMethod", func(t *testing.T) {
+		for _, tc := range []struct {
+			properties map[string]string
+			authMethod string
+		}{
+			{map[string]string{"clientId": "id", "clientSecret": "secret", "tenantId": "tenant"}, "clientSecret"},
+			{map[string]string{}, "managedIdentity"},
+			{map[string]string{"clientId": "id"}, "managedIdentity"},
+			{map[string]string{"accessToken": "token"}, "accessToken"},
+			{map[string]string{"azureAuthMethod": "managedIdentity", "clientId": "id"}, "managedIdentity"},
+			{map[string]string{"azureAuthMethod": "environment"}, "environment"},
+			{map[string]string{"azureAuthMethod": "clientSecret", "clientId": "id", "clientSecret": "secret", "tenantId": "tenant"}, "clientSecret"},
+		} {
+			tc.properties["adtInstanceUrl"] = "https://adt.example"
+			meta, err := d.getAzureDigitalTwinsMetadata(bindings.Metadata{Properties: tc.properties})
+			assert.NoError(t, err)
+			assert.Equal(t, tc.authMethod, meta.authMethod)
+		}
+
+		for _, properties := range []map[string]string{
+			{"azureAuthMethod": "certificate"},
+			{"azureAuthMethod": "clientSecret", "clientId": "id", "tenantId": "tenant"},
+			{"azureAuthMethod": "managedIdentity", "clientId": "id", "clientSecret": "secret", "tenantId": "tenant"},
+			{"azureAuthMethod": "accessToken"},
+		} {
+			properties["adtInstanceUrl"] = "https://adt.example"
+			_, err := d.getAzureDigitalTwinsMetadata(bindings.Metadata{Properties: properties})
+			assert.Error(t, err, properties)
+		}
+	})
+
+	t.Run("static access token", func(t *testing.T) {
+		m := bindings.Metadata{Properties: map[string]string{
+			"accessToken":    "token",
+			"adtInstanceUrl": "http://localhost:8080",
+		}}
+		meta, err := d.getAzureDigitalTwinsMetadata(m)
+		assert.NoError(t, err)
+		assert.Equal(t, "token", meta.accessToken)
+
+		m.Properties["clientSecret"] = "secret"
+		_, err = d.getAzureDigitalTwinsMetadata(m)
+		assert.Error(t, err)
+	})
+
+	t.Run("rejectStale", func(t *testing.T) {
+		m := bindings.Metadata{Properties: map[string]string{
+			"adtInstanceUrl": "https://adt.example",
+			"rejectStale":    "true",
+		}}
+		meta, err := d.getAzureDigitalTwinsMetadata(m)
+		assert.NoError(t, err)
+		assert.True(t, meta.rejectStale)
+
+		m.Properties["rejectStale"] = "maybe"
+		_, err = d.getAzureDigitalTwinsMetadata(m)
+		assert.Error(t, err)
+	})
+
+	t.Run("patch value limits", func(t *testing.T) {
+		m := bindings.Metadata{Properties: map[string]string{
+			"adtInstanceUrl": "https://adt.example",
+		}}
+		meta, err := d.getAzureDigitalTwinsMetadata(m)
+		assert.NoError(t, err)
+		assert.Equal(t, defaultMaxPatchValueDepth, meta.maxValueDepth)
+		assert.Equal(t, defaultMaxPatchValueSize, meta.maxValueSize)
+
+		m.Properties["maxPatchValueDepth"] = "4"
+		m.Properties["maxPatchValueSize"] = "1024"
+		meta, err = d.getAzureDigitalTwinsMetadata(m)
+		assert.NoError(t, err)
+		assert.Equal(t, 4, meta.maxValueDepth)
+		assert.Equal(t, 1024, meta.maxValueSize)
+
+		m.Properties["maxPatchValueSize"] = "large"
+		_, err = d.getAzureDigitalTwinsMetadata(m)
+		assert.Error(t, err)
+	})
+
+	t.Run("default metadata", func(t *testing.T) {
+		m := bindings.Metadata{Properties: map[string]string{
+			"adtInstanceUrl":  "https://adt.example",
+			"default.ifMatch": `W/"1"`,
+			"default.":        "ignored",
+		}}
+		meta, err := d.getAzureDigitalTwinsMetadata(m)
+		assert.NoError(t, err)
+		assert.Equal(t, map[string]string{"ifMatch": `W/"1"`}, meta.defaultMetadata)
+	})
+
+	t.Run("maxIdleConnsPerHost", func(t *testing.T) {
+		m := bindings.Metadata{Properties: map[string]string{
+			"adtInstanceUrl": "https://adt.example",
+		}}
+		meta, err := d.getAzureDigitalTwinsMetadata(m)
+		assert.NoError(t, err)
+		assert.Equal(t, defaultMaxIdleConnsPerHost, meta.maxIdleConns)
+
+		m.Properties["maxIdleConnsPerHost"] = "10"
+		meta, err = d.getAzureDigitalTwinsMetadata(m)
+		assert.NoError(t, err)
+		assert.Equal(t, 10, meta.maxIdleConns)
+
+		m.Properties["maxIdleConnsPerHost"] = "0"
+		_, err = d.getAzureDigitalTwinsMetadata(m)
+		assert.Error(t, err)
+	})
+	t.Run("eventPort", func(t *testing.T) {
+		m := bindings.Metadata{Properties: map[string]string{
+			"adtInstanceUrl": "https://adt.example",
+			"eventPort":      "8080",
+		}}
+		meta, err := d.getAzureDigitalTwinsMetadata(m)
+		assert.NoError(t, err)
+		assert.Equal(t, "8080", meta.eventPort)
+
+		m.Properties["eventPort"] = "http"
+		_, err = d.getAzureDigitalTwinsMetadata(m)
+		assert.Error(t, err)
+	})
+
+	t.Run("azureEnvironment", func(t *testing.T) {
+		m := bindings.Metadata{Properties: map[string]string{
+			"adtInstanceUrl": "https://adt.example",
+		}}
+		meta, err := d.getAzureDigitalTwinsMetadata(m)
+		assert.NoError(t, err)
+		assert.Equal(t, "https://digitaltwins.azure.net", meta.environment.resource)
+		assert.Equal(t, "https://login.microsoftonline.com/", meta.environment.aadEndpoint)
+
+		m.Properties["azureEnvironment"] = "USGov"
+		meta, err = d.getAzureDigitalTwinsMetadata(m)
+		assert.NoError(t, err)
+		assert.Equal(t, "https://digitaltwins.azure.us", meta.environment.resource)
+		assert.Equal(t, "https://login.microsoftonline.us/", meta.environment.aadEndpoint)
+
+		m.Properties["azureEnvironment"] = "china"
+		meta, err = d.getAzureDigitalTwinsMetadata(m)
+		assert.NoError(t, err)
+		assert.Equal(t, "https://digitaltwins.azure.cn", meta.environment.resource)
+
+		m.Properties["azureEnvironment"] = "germany"
+		_, err = d.getAzureDigitalTwinsMetadata(m)
+		assert.Error(t, err)
+	})
+
+	t.Run("apiVersion", func(t *testing.T) {
+		m := bindings.Metadata{Properties: map[string]string{
+			"adtInstanceUrl": "https://adt.example",
+			"apiVersion":     "2021-06-30-preview",
+		}}
+		meta, err := d.getAzureDigitalTwinsMetadata(m)
+		assert.NoError(t, err)
+		assert.Equal(t, "2021-06-30-preview", meta.apiVersion)
+
+		m.Properties["apiVersion"] = "latest"
+		_, err = d.getAzureDigitalTwinsMetadata(m)
+		assert.Error(t, err)
+	})
+
+	t.Run("missing adtInstanceUrl", func(t *testing.T) {
+		m := bindings.Metadata{Properties: map[string]string{}}
+		_, err := d.getAzureDigitalTwinsMetadata(m)
+		assert.Error(t, err)
+	})
+}
+
+func TestClientReuse(t *testing.T) {
+	t.Run("created once in init", func(t *testing.T) {
+		d := NewAzureDigitalTwins(logger.NewLogger("test"))
+		err := d.Init(bindings.Metadata{Properties: map[string]string{
+			"adtInstanceUrl":      "https://adt.example",
+			"accessToken":         "token",
+			"maxIdleConnsPerHost": "10",
+		}})
+		assert.NoError(t, err)
+		assert.NotNil(t, d.authorizer)
+		transport := d.sender.(*http.Client).Transport.(*http.Transport)
+		assert.Equal(t, 10, transport.MaxIdleConnsPerHost)
+
+		first, err := d.newClient()
+		assert.NoError(t, err)
+		second, err := d.newClient()
+		assert.NoError(t, err)
+		assert.Equal(t, first.Authorizer, second.Authorizer)
+		assert.Equal(t, first.Sender, second.Sender)
+	})
+
+	t.Run("overrides are kept", func(t *testing.T) {
+		sender := &mockSender{statusCode: http.StatusNoContent}
+		d := newTestBinding(sender)
+		err := d.Init(bindings.Metadata{Properties: map[string]string{
+			"adtInstanceUrl": "https://adt.example",
+			"accessToken":    "token",
+		}})
+		assert.NoError(t, err)
+		assert.Equal(t, autorest.NullAuthorizer{}, d.authorizer)
+		assert.Equal(t, sender, d.sender)
+	})
+
+	t.Run("invoke before init", func(t *testing.T) {
+		d := NewAzureDigitalTwins(logger.NewLogger("test"))
+		d.adtInstanceURL = "https://adt.example"
+		_, err := d.Invoke(&bindings.InvokeRequest{Data: []byte(`[{"op": "add", "path": "/temperature", "value": 1}]`), Metadata: map[string]string{"twinID": "twin1"}})
+		assert.Error(t, err)
+	})
+}
+
+func TestAPIVersion(t *testing.T) {
+	patch := []byte(`[{"op": "replace", "path": "/temperature", "value": 20}]`)
+
+	t.Run("generated client version by default", func(t *testing.T) {
+		sender := &mockSender{statusCode: http.StatusNoContent}
+		d := newTestBinding(sender)
+		_, err := d.Invoke(&bindings.InvokeRequest{Data: patch, Metadata: map[string]string{"twinID": "myTwin"}})
+		assert.NoError(t, err)
+		assert.Equal(t, "2020-10-31", sender.requests[0].URL.Query().Get("api-version"))
+	})
+
+	t.Run("configured version", func(t *testing.T) {
+		sender := &mockSender{statusCode: http.StatusNoContent}
+		d := newTestBinding(sender)
+		d.apiVersion = "2021-06-30-preview"
+		_, err := d.Invoke(&bindings.InvokeRequest{Data: patch, Metadata: map[string]string{"twinID": "myTwin"}})
+		assert.NoError(t, err)
+		assert.Equal(t, "2021-06-30-preview", sender.requests[0].URL.Query().Get("api-version"))
+	})
+}
+
+type mockSender struct {
+	lock       sync.Mutex
+	statusCode int
+	// statusCodes override statusCode for updates per twin ID
+	statusCodes map[string]int
+	// delays of updates per twin ID
+	delays map[string]time.Duration
+	// body is returned for other requests
+	body string
+	// pages are returned in order for query requests
+	pages []string
+	// twin is returned for GET requests
+	twin string
+	// etag is returned in the ETag header of other requests
+	etag     string
+	requests []*http.Request
+}
+
+func (m *mockSender) Do(req *http.Request) (*http.Response, error) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	m.requests = append(m.requests, req)
+
+	if req.Method == http.MethodGet {
+		return &http.Response{
+			StatusCode: http.StatusOK,
+			Header:     http.Header{"Etag": []string{`W/"1"`}},
+			Body:       ioutil.NopCloser(strings.NewReader(m.twin)),
+			Request:    req,
+		}, nil
+	}
+
+	if req.URL.Path == "/query" && len(m.pages) > 0 {
+		page := m.pages[0]
+		m.pages = m.pages[1:]
+
+		return &http.Response{
+			StatusCode: http.StatusOK,
+			Body:       ioutil.NopCloser(strings.NewReader(page)),
+			Request:    req,
+		}, nil
+	}
+
+	twinID := strings.TrimPrefix(req.URL.Path, "/digitaltwins/")
+	if delay := m.delays[twinID]; delay > 0 {
+		// concurrent updates are delayed concurrently
+		m.lock.Unlock()
+		time.Sleep(delay)
+		m.lock.Lock()
+	}
+
+	statusCode := m.statusCode
+	if code, ok := m.statusCodes[twinID]; ok {
+		statusCode = code
+	}
+
+	header := http.Header{}
+	if m.etag != "" {
+		header.Set("ETag", m.etag)
+	}
+
+	return &http.Response{
+		StatusCode: statusCode,
+		Header:     header,
+		Body:       ioutil.NopCloser(strings.NewReader(m.body)),
+		Request:    req,
+	}, nil
+}
+
+func (m *mockSender) methods() []string {
+	methods := make([]string, len(m.requests))
+	for i, req := range m.requests {
+		methods[i] = req.Method
+	}
+
+	return methods
+}
+
+func newTestBinding(sender autorest.Sender) *AzureDigitalTwins {
+	d := NewAzureDigitalTwins(logger.NewLogger("test"))
+	d.adtInstanceURL = "https://adt.example"
+	d.authorizer = autorest.NullAuthorizer{}
+	d.sender = sender
+
+	return d
+}
+
+func TestIfMatch(t *testing.T) {
+	patch := []byte(`[{"op": "replace", "path": "/myTwin/temperature", "value": 20}]`)
+
+	t.Run("unconditional by default", func(t *testing.T) {
+		sender := &mockSender{statusCode: http.StatusNoContent}
+		d := newTestBinding(sender)
+		_, err := d.Invoke(&bindings.InvokeRequest{Data: patch, Metadata: map[string]string{}})
+		assert.NoError(t, err)
+		assert.Len(t, sender.requests, 1)
+		assert.Equal(t, "*", sender.requests[0].Header.Get("If-Match"))
+	})
+
+	t.Run("single twin etag", func(t *testing.T) {
+		sender := &mockSender{statusCode: http.StatusNoContent}
+		d := newTestBinding(sender)
+		_, err := d.Invoke(&bindings.InvokeRequest{
+			Data:     []byte(`[{"op": "replace", "path": "/temperature", "value": 20}]`),
+			Metadata: map[string]string{"twinID": "myTwin", "ifMatch": `W/"1"`},
+		})
+		assert.NoError(t, err)
+		assert.Equal(t, `W/"1"`, sender.requests[0].Header.Get("If-Match"))
+	})
+
+	t.Run("per twin etag", func(t *testing.T) {
+		sender := &mockSender{statusCode: http.StatusNoContent}
+		d := newTestBinding(sender)
+		_, err := d.Invoke(&bindings.InvokeRequest{
+			Data: []byte(`[{"op": "replace", "path": "/twinA/temperature", "value": 20}, {"op": "replace", "path": "/twinB/temperature", "value": 21}]`),
+			Metadata: map[string]string{
+				"ifMatch":       `W/"default"`,
+				"ifMatch.twinB": `W/"2"`,
+			},
+		})
+		assert.NoError(t, err)
+		assert.Len(t, sender.requests, 2)
+		assert.Equal(t, `W/"default"`, sender.requests[0].Header.Get("If-Match"))
+		assert.Equal(t, `W/"2"`, sender.requests[1].Header.Get("If-Match"))
+	})
+
+	t.Run("etag alias", func(t *testing.T) {
+		sender := &mockSender{statusCode: http.StatusNoContent}
+		d := newTestBinding(sender)
+		_, err := d.Invoke(&bindings.InvokeRequest{
+			Data: []byte(`[{"op": "replace", "path": "/twinA/temperature", "value": 20}, {"op": "replace", "path": "/twinB/temperature", "value": 21}]`),
+			Metadata: map[string]string{
+				"etag":       `W/"default"`,
+				"etag.twinB": `W/"2"`,
+			},
+		})
+		assert.NoError(t, err)
+		assert.Equal(t, `W/"default"`, sender.requests[0].Header.Get("If-Match"))
+		assert.Equal(t, `W/"2"`, sender.requests[1].Header.Get("If-Match"))
+	})
+
+	t.Run("new etag returned", func(t *testing.T) {
+		sender := &mockSender{statusCode: http.StatusNoContent, etag: `W/"2"`}
+		d := newTestBinding(sender)
+		res, err := d.Invoke(&bindings.InvokeRequest{
+			Data:     []byte(`[{"op": "replace", "path": "/temperature", "value": 20}]`),
+			Metadata: map[string]string{"twinID": "myTwin", "etag": `W/"1"`},
+		})
+		assert.NoError(t, err)
+		assert.Equal(t, `W/"1"`, sender.requests[0].Header.Get("If-Match"))
+		assert.Equal(t, `W/"2"`, res.Metadata["etag"])
+	})
+
+	t.Run("new etags per twin", func(t *testing.T) {
+		sender := &mockSender{statusCode: http.StatusNoContent, etag: `W/"2"`}
+		d := newTestBinding(sender)
+		res, err := d.Invoke(&bindings.InvokeRequest{
+			Data:     []byte(`[{"op": "replace", "path": "/twinA/temperature", "value": 20}, {"op": "replace", "path": "/twinB/humidity", "value": 50}]`),
+			Metadata: map[string]string{"ifMatch": `W/"1"`},
+		})
+		assert.NoError(t, err)
+		assert.Equal(t, `W/"2"`, res.Metadata["etag.twinA"])
+		assert.Equal(t, `W/"2"`, res.Metadata["etag.twinB"])
+	})
+
+	t.Run("precondition failed", func(t *testing.T) {
+		sender := &mockSender{statusCode: http.StatusPreconditionFailed}
+		d := newTestBinding(sender)
+		_, err := d.Invoke(&bindings.InvokeRequest{Data: patch, Metadata: map[string]string{"ifMatch": `W/"1"`}})
+		assert.Error(t, err)
+		assert.True(t, errors.Is(err, ErrPreconditionFailed))
+	})
+
+	t.Run("other failures are not precondition failures", func(t *testing.T) {
+		sender := &mockSender{statusCode: http.StatusBadRequest}
+		d := newTestBinding(sender)
+		_, err := d.Invoke(&bindings.InvokeRequest{Data: patch, Metadata: map[string]string{}})
+		assert.Error(t, err)
+		assert.False(t, errors.Is(err, ErrPreconditionFailed))
+	})
+}
+
+func TestRejectStale(t *testing.T) {
+	twin := `{
+		"$dtId": "myTwin",
+		"$metadata": {
+			"$model": "dtmi:example:Sensor;1",
+			"temperature": {"lastUpdateTime": "2021-01-01T10:00:00.1234567Z"},
+			"humidity": {"lastUpdateTime": "2021-01-01T09:00:00Z"}
+		},
+		"temperature": 20,
+		"humidity": 50
+	}`
+	patch := []byte(`[{"op": "replace", "path": "/temperature", "value": 21}]`)
+
+	tests := []struct {
+		name            string
+		eventTime       string
+		expectedMethods []string
+	}{
+		{"newer event is applied", "2021-01-01T11:00:00Z", []string{http.MethodGet, http.MethodPatch}},
+		{"older event is skipped", "2021-01-01T09:30:00Z", []string{http.MethodGet}},
+		{"event at the same time is applied", "2021-01-01T10:00:00.1234567Z", []string{http.MethodGet, http.MethodPatch}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sender := &mockSender{statusCode: http.StatusNoContent, twin: twin}
+			d := newTestBinding(sender)
+			d.rejectStale = true
+			_, err := d.Invoke(&bindings.InvokeRequest{
+				Data:     patch,
+				Metadata: map[string]string{"twinID": "myTwin", "time": tt.eventTime},
+			})
+			assert.NoError(t, err)
+			assert.Equal(t, tt.expectedMethods, sender.methods())
+		})
+	}
+
+	t.Run("twin fetched once per request", func(t *testing.T) {
+		sender := &mockSender{statusCode: http.StatusNoContent, twin: twin}
+		d := newTestBinding(sender)
+		d.rejectStale = true
+		_, err := d.Invoke(&bindings.InvokeRequest{
+			Data:     []byte(`[{"op": "replace", "path": "/myTwin/temperature", "value": 21}, {"op": "replace", "path": "/myTwin/humidity", "value": 51}]`),
+			Metadata: map[string]string{"time": "2021-01-01T11:00:00Z"},
+		})
+		assert.NoError(t, err)
+		assert.Equal(t, []string{http.MethodGet, http.MethodPatch}, sender.methods())
+	})
+
+	t.Run("disabled", func(t *testing.T) {
+		sender := &mockSender{statusCode: http.StatusNoContent, twin: twin}
+		d := newTestBinding(sender)
+		_, err := d.Invoke(&bindings.InvokeRequest{
+			Data:     patch,
+			Metadata: map[string]string{"twinID": "myTwin", "time": "2021-01-01T09:30:00Z"},
+		})
+		assert.NoError(t, err)
+		assert.Equal(t, []string{http.MethodPatch}, sender.methods())
+	})
+
+	t.Run("invalid event time", func(t *testing.T) {
+		sender := &mockSender{statusCode: http.StatusNoContent, twin: twin}
+		d := newTestBinding(sender)
+		d.rejectStale = true
+		_, err := d.Invoke(&bindings.InvokeRequest{
+			Data:     patch,
+			Metadata: map[string]string{"twinID": "myTwin", "time": "yesterday"},
+		})
+		assert.Error(t, err)
+		assert.Empty(t, sender.requests)
+	})
+}
+
+func TestWrapErr(t *testing.T) {
+	original := errors.New("original error")
+
+	t.Run("with twin", func(t *testing.T) {
+		err := wrapErr("patch", "myTwin", original)
+		assert.Contains(t, err.Error(), "patch")
+		assert.Contains(t, err.Error(), "myTwin")
+		assert.Contains(t, err.Error(), original.Error())
+		assert.Equal(t, original, errors.Unwrap(err))
+	})
+
+	t.Run("without twin", func(t *testing.T) {
+		err := wrapErr("patch", "", original)
+		assert.Equal(t, "azureDigitalTwins error: patch: original error", err.Error())
+		assert.True(t, errors.Is(err, original))
+	})
+
+	t.Run("failure sites", func(t *testing.T) {
+		sender := &mockSender{statusCode: http.StatusPreconditionFailed}
+		d := newTestBinding(sender)
+		_, err := d.Invoke(&bindings.InvokeRequest{
+			Data:     []byte(`[{"op": "replace", "path": "/temperature", "value": 20}]`),
+			Metadata: map[string]string{"twinID": "myTwin"},
+		})
+		assert.Error(t, err)
+		assert.Contains(t, err.Error(), "patch twin myTwin")
+		assert.True(t, errors.Is(err, ErrPreconditionFailed))
+	})
+}
+
+func TestEventType(t *testing.T) {
+	t.Run("default mapping", func(t *testing.T) {
+		d := NewAzureDigitalTwins(logger.NewLogger("test"))
+		err := d.Init(bindings.Metadata{Properties: map[string]string{
+			"adtInstanceUrl": "https://adt.example",
+			"accessToken":    "token",
+		}})
+		assert.NoError(t, err)
+		assert.Equal(t, "com.dapr.binding.azure.digitaltwins.create", d.eventType(bindings.CreateOperation))
+		assert.Equal(t, "com.dapr.binding.azure.digitaltwins.delete", d.eventType(bindings.DeleteOperation))
+	})
+
+	t.Run("custom mapping", func(t *testing.T) {
+		d := NewAzureDigitalTwins(logger.NewLogger("test"))
+		err := d.Init(bindings.Metadata{Properties: map[string]string{
+			"adtInstanceUrl": "https://adt.example",
+			"eventTypes":     "create=com.acme.twin.created, delete = com.acme.twin.deleted",
+			"accessToken":    "token",
+		}})
+		assert.NoError(t, err)
+		assert.Equal(t, "com.acme.twin.created", d.eventType(bindings.CreateOperation))
+		assert.Equal(t, "com.acme.twin.deleted", d.eventType(bindings.DeleteOperation))
+		assert.Equal(t, "com.dapr.binding.azure.digitaltwins.get", d.eventType(bindings.GetOperation))
+	})
+
+	t.Run("invalid mapping", func(t *testing.T) {
+		d := NewAzureDigitalTwins(logger.NewLogger("test"))
+		err := d.Init(bindings.Metadata{Properties: map[string]string{
+			"adtInstanceUrl": "https://adt.example",
+			"eventTypes":     "create",
+		}})
+		assert.Error(t, err)
+	})
+}
+
+func TestFetchModel(t *testing.T) {
+	twin := `{"$dtId": "myTwin", "$metadata": {"$model": "dtmi:example:Sensor;1"}}`
+	patch := []byte(`[
+		{"op": "replace", "path": "/twinA/temperature", "value": 20},
+		{"op": "replace", "path": "/twinB/temperature", "value": 21},
+		{"op": "replace", "path": "/twinA/humidity", "value": 50}
+	]`)
+
+	t.Run("model fetched once per twin", func(t *testing.T) {
+		sender := &mockSender{statusCode: http.StatusNoContent, twin: twin}
+		d := newTestBinding(sender)
+		d.fetchModel = true
+		_, err := d.Invoke(&bindings.InvokeRequest{Data: patch, Metadata: map[string]string{modelIDKey: "dtmi:example:Sensor;1"}})
+		assert.NoError(t, err)
+
+		fetched := []string{}
+		for _, req := range sender.requests {
+			if req.Method == http.MethodGet {
+				fetched = append(fetched, req.URL.Path)
+			}
+		}
+		assert.Equal(t, []string{"/digitaltwins/twinA", "/digitaltwins/twinB"}, fetched)
+		assert.Equal(t, []string{http.MethodGet, http.MethodPatch, http.MethodGet, http.MethodPatch}, sender.methods())
+	})
+
+	t.Run("model mismatch", func(t *testing.T) {
+		sender := &mockSender{statusCode: http.StatusNoContent, twin: twin}
+		d := newTestBinding(sender)
+		d.fetchModel = true
+		_, err := d.Invoke(&bindings.InvokeRequest{Data: patch, Metadata: map[string]string{modelIDKey: "dtmi:example:Thermostat;1"}})
+		assert.True(t, errors.Is(err, ErrModelMismatch))
+		assert.NotContains(t, sender.methods(), http.MethodPatch)
+	})
+
+	t.Run("no model in the request", func(t *testing.T) {
+		sender := &mockSender{statusCode: http.StatusNoContent, twin: twin}
+		d := newTestBinding(sender)
+		d.fetchModel = true
+		_, err := d.Invoke(&bindings.InvokeRequest{Data: patch, Metadata: map[string]string{}})
+		assert.NoError(t, err)
+		assert.Equal(t, []string{http.MethodPatch, http.MethodPatch}, sender.methods())
+	})
+
+	t.Run("disabled", func(t *testing.T) {
+		sender := &mockSender{statusCode: http.StatusNoContent, twin: twin}
+		d := newTestBinding(sender)
+		_, err := d.Invoke(&bindings.InvokeRequest{Data: patch, Metadata: map[string]string{modelIDKey: "dtmi:example:Thermostat;1"}})
+		assert.NoError(t, err)
+		assert.Equal(t, []string{http.MethodPatch, http.MethodPatch}, sender.methods())
+	})
+
+	t.Run("model of the twin", func(t *testing.T) {
+		sender := &mockSender{twin: twin}
+		client, err := newTestBinding(sender).newClient()
+		assert.NoError(t, err)
+
+		twins := newTwinCache(client)
+		model, err := twins.model("myTwin")
+		assert.NoError(t, err)
+		assert.Equal(t, "dtmi:example:Sensor;1", model)
+
+		_, err = twins.model("myTwin")
+		assert.NoError(t, err)
+		assert.Len(t, sender.requests, 1)
+	})
+}
+
+func TestPatchValueLimits(t *testing.T) {
+	nested := `{"a": {"b": {"c": {"d": 1}}}}`
+
+	t.Run("deeply nested value", func(t *testing.T) {
+		sender := &mockSender{statusCode: http.StatusNoContent}
+		d := newTestBinding(sender)
+		d.maxValueDepth = 3
+		_, err := d.Invoke(&bindings.InvokeRequest{
+			Data:     []byte(`[{"op": "replace", "path": "/myTwin/a", "value": 1}, {"op": "replace", "path": "/myTwin/b", "value": ` + nested + `}]`),
+			Metadata: map[string]string{},
+		})
+		assert.True(t, errors.Is(err, ErrPatchValueTooDeep))
+		assert.Contains(t, err.Error(), "operation 1")
+		assert.Empty(t, sender.requests)
+	})
+
+	t.Run("nesting at the limit", func(t *testing.T) {
+		sender := &mockSender{statusCode: http.StatusNoContent}
+		d := newTestBinding(sender)
+		d.maxValueDepth = 4
+		_, err := d.Invoke(&bindings.InvokeRequest{
+			Data:     []byte(`[{"op": "replace", "path": "/b", "value": ` + nested + `}]`),
+			Metadata: map[string]string{"twinID": "myTwin"},
+		})
+		assert.NoError(t, err)
+		assert.Len(t, sender.requests, 1)
+	})
+
+	t.Run("oversized value", func(t *testing.T) {
+		sender := &mockSender{statusCode: http.StatusNoContent}
+		d := newTestBinding(sender)
+		d.maxValueSize = 16
+		_, err := d.Invoke(&bindings.InvokeRequest{
+			Data:     []byte(`[{"op": "replace", "path": "/description", "value": "` + strings.Repeat("x", 32) + `"}]`),
+			Metadata: map[string]string{"twinID": "myTwin"},
+		})
+		assert.True(t, errors.Is(err, ErrPatchValueTooLarge))
+		assert.Contains(t, err.Error(), "operation 0")
+		assert.Empty(t, sender.requests)
+	})
+
+	t.Run("depth of values", func(t *testing.T) {
+		assert.Equal(t, 0, valueDepth(nil))
+		assert.Equal(t, 0, valueDepth("a"))
+		assert.Equal(t, 1, valueDepth([]interface{}{1.0}))
+		assert.Equal(t, 2, valueDepth(map[string]interface{}{"a": []interface{}{}}))
+	})
+}
+
+// captureLogger records the formatted log lines
+type captureLogger struct {
+	logger.Logger
+	lines []string
+}
+
+func (l *captureLogger) Infof(format string, args ...interface{}) {
+	l.lines = append(l.lines, fmt.Sprintf(format, args...))
+}
+
+func (l *captureLogger) Debugf(format string, args ...interface{}) {
+	l.lines = append(l.lines, fmt.Sprintf(format, args...))
+}
+
+func (l *captureLogger) Errorf(format string, args ...interface{}) {
+	l.lines = append(l.lines, fmt.Sprintf(format, args...))
+}
+
+func TestCorrelationID(t *testing.T) {
+	patch := []byte(`[{"op": "replace", "path": "/twinA/temperature", "value": 20}, {"op": "replace", "path": "/twinB/temperature", "value": 21}]`)
+
+	t.Run("generated per invoke", func(t *testing.T) {
+		log := &captureLogger{Logger: logger.NewLogger("test")}
+		d := newTestBinding(&mockSender{statusCode: http.StatusNoContent})
+		d.logger = log
+
+		res, err := d.Invoke(&bindings.InvokeRequest{Data: patch, Metadata: map[string]string{}})
+		assert.NoError(t, err)
+		correlationID := res.Metadata["correlationId"]
+		assert.NotEmpty(t, correlationID)
+		assert.True(t, len(log.lines) > 2)
+		for _, line := range log.lines {
+			assert.Contains(t, line, "correlationId="+correlationID)
+		}
+
+		res, err = d.Invoke(&bindings.InvokeRequest{Data: patch, Metadata: map[string]string{}})
+		assert.NoError(t, err)
+		assert.NotEqual(t, correlationID, res.Metadata["correlationId"])
+	})
+
+	t.Run("reused from metadata", func(t *testing.T) {
+		log := &captureLogger{Logger: logger.NewLogger("test")}
+		d := newTestBinding(&mockSender{statusCode: http.StatusNoContent})
+		d.logger = log
+
+		res, err := d.Invoke(&bindings.InvokeRequest{Data: patch, Metadata: map[string]string{"correlationId": "abc"}})
+		assert.NoError(t, err)
+		assert.Equal(t, "abc", res.Metadata["correlationId"])
+		for _, line := range log.lines {
+			assert.Contains(t, line, "correlationId=abc")
+		}
+	})
+}
+
+func TestForbidden(t *testing.T) {
+	patch := []byte(`[{"op": "replace", "path": "/myTwin/temperature", "value": 20}]`)
+
+	t.Run("patch forbidden", func(t *testing.T) {
+		d := newTestBinding(&mockSender{statusCode: http.StatusForbidden})
+		_, err := d.Invoke(&bindings.InvokeRequest{Data: patch, Metadata: map[string]string{}})
+		assert.True(t, errors.Is(err, ErrForbidden))
+		assert.Contains(t, err.Error(), "Azure Digital Twins Data Owner")
+		assert.Contains(t, err.Error(), "myTwin")
+	})
+
+	t.Run("unauthorized is not forbidden", func(t *testing.T) {
+		d := newTestBinding(&mockSender{statusCode: http.StatusUnauthorized})
+		_, err := d.Invoke(&bindings.InvokeRequest{Data: patch, Metadata: map[string]string{}})
+		assert.Error(t, err)
+		assert.False(t, errors.Is(err, ErrForbidden))
+	})
+}
+
+func TestDefaultMetadata(t *testing.T) {
+	t.Run("merge precedence", func(t *testing.T) {
+		defaults := map[string]string{"ifMatch": "*", "time": "2021-01-01T10:00:00Z"}
+		metadata := map[string]string{"ifMatch": `W/"1"`, "twinID": "myTwin"}
+		merged := mergeMetadata(defaults, metadata)
+		assert.Equal(t, map[string]string{"ifMatch": `W/"1"`, "time": "2021-01-01T10:00:00Z", "twinID": "myTwin"}, merged)
+		assert.Len(t, metadata, 2)
+
+		assert.Equal(t, defaults, mergeMetadata(defaults, nil))
+	})
+
+	patch := []byte(`[{"op": "replace", "path": "/temperature", "value": 20}]`)
+
+	t.Run("unspecified keys fall back to defaults", func(t *testing.T) {
+		sender := &mockSender{statusCode: http.StatusNoContent}
+		d := newTestBinding(sender)
+		d.defaultMetadata = map[string]string{"ifMatch": `W/"default"`}
+		_, err := d.Invoke(&bindings.InvokeRequest{Data: patch, Metadata: map[string]string{"twinID": "myTwin"}})
+		assert.NoError(t, err)
+		assert.Equal(t, `W/"default"`, sender.requests[0].Header.Get("If-Match"))
+	})
+
+	t.Run("request values override defaults", func(t *testing.T) {
+		sender := &mockSender{statusCode: http.StatusNoContent}
+		d := newTestBinding(sender)
+		d.defaultMetadata = map[string]string{"ifMatch": `W/"default"`}
+		metadata := map[string]string{"twinID": "myTwin", "ifMatch": `W/"1"`}
+		_, err := d.Invoke(&bindings.InvokeRequest{Data: patch, Metadata: metadata})
+		assert.NoError(t, err)
+		assert.Equal(t, `W/"1"`, sender.requests[0].Header.Get("If-Match"))
+		assert.Len(t, metadata, 2)
+	})
+}
+
+func TestChangeNotification(t *testing.T) {
+	patch := []byte(`[{"op": "replace", "path": "/temperature", "value": 20}]`)
+	metadata := map[string]string{"twinID": "myTwin"}
+
+	t.Run("notification on success", func(t *testing.T) {
+		events := make(chan *pubsub.CloudEvent, 1)
+		d := newTestBinding(&mockSender{statusCode: http.StatusNoContent})
+		d.SetChangePublisher(func(event *pubsub.CloudEvent) error {
+			events <- event
+
+			return nil
+		})
+
+		_, err := d.Invoke(&bindings.InvokeRequest{Data: patch, Metadata: metadata, Operation: bindings.CreateOperation})
+		assert.NoError(t, err)
+
+		select {
+		case event := <-events:
+			assert.Equal(t, "com.dapr.binding.azure.digitaltwins.create", event.Type)
+			assert.Equal(t, "https://adt.example", event.Source)
+			assert.Equal(t, "myTwin", event.Subject)
+			assert.Equal(t, "application/json", event.DataContentType)
+
+			var change map[string]interface{}
+			err = json.Unmarshal([]byte(event.Data.(string)), &change)
+			assert.NoError(t, err)
+			assert.Equal(t, "myTwin", change["twinId"])
+			assert.Equal(t, []interface{}{map[string]interface{}{"op": "replace", "path": "/temperature", "value": 20.0}}, change["operations"])
+		case <-time.After(time.Second):
+			assert.Fail(t, "no change notification")
+		}
+	})
+
+	t.Run("suppressed on failure", func(t *testing.T) {
+		published := false
+		d := newTestBinding(&mockSender{statusCode: http.StatusBadRequest})
+		d.failOnNotifyError = true
+		d.SetChangePublisher(func(event *pubsub.CloudEvent) error {
+			published = true
+
+			return nil
+		})
+
+		_, err := d.Invoke(&bindings.InvokeRequest{Data: patch, Metadata: metadata})
+		assert.Error(t, err)
+		assert.False(t, published)
+	})
+
+	t.Run("publish failure does not fail the patch by default", func(t *testing.T) {
+		done := make(chan struct{})
+		d := newTestBinding(&mockSender{statusCode: http.StatusNoContent})
+		d.SetChangePublisher(func(event *pubsub.CloudEvent) error {
+			defer close(done)
+
+			return errors.New("unavailable")
+		})
+
+		_, err := d.Invoke(&bindings.InvokeRequest{Data: patch, Metadata: metadata})
+		assert.NoError(t, err)
+		<-done
+	})
+
+	t.Run("publish failure fails the patch when configured", func(t *testing.T) {
+		d := newTestBinding(&mockSender{statusCode: http.StatusNoContent})
+		d.failOnNotifyError = true
+		d.SetChangePublisher(func(event *pubsub.CloudEvent) error {
+			return errors.New("unavailable")
+		})
+
+		_, err := d.Invoke(&bindings.InvokeRequest{Data: patch, Metadata: metadata})
+		assert.Error(t, err)
+		assert.Contains(t, err.Error(), "unavailable")
+	})
+}
+
+func TestPathRewrites(t *testing.T) {
+	newBinding := func(sender *mockSender) *AzureDigitalTwins {
+		d := newTestBinding(sender)
+		meta, err := d.getAzureDigitalTwinsMetadata(bindings.Metadata{Properties: map[string]string{
+			"adtInstanceUrl": "https://adt.example",
+			"pathRewrites":   `[{"match": "^/sensors/temp$", "replace": "/temperature"}, {"match": "^/sensors/(.+)$", "replace": "/$1"}]`,
+		}})
+		assert.NoError(t, err)
+		d.pathRewrites = meta.pathRewrites
+
+		return d
+	}
+
+	body := func(req *http.Request) string {
+		b, _ := ioutil.ReadAll(req.Body)
+
+		return string(b)
+	}
+
+	t.Run("rule rewrites path", func(t *testing.T) {
+		sender := &mockSender{statusCode: http.StatusNoContent}
+		d := newBinding(sender)
+		_, err := d.Invoke(&bindings.InvokeRequest{
+			Data:     []byte(`[{"op": "replace", "path": "/myTwin/sensors/temp", "value": 20}, {"op": "replace", "path": "/myTwin/sensors/humidity", "value": 50}]`),
+			Metadata: map[string]string{},
+		})
+		assert.NoError(t, err)
+		patchDoc := body(sender.requests[0])
+		assert.Contains(t, patchDoc, `"path":"/temperature"`)
+		assert.Contains(t, patchDoc, `"path":"/humidity"`)
+
+		sender = &mockSender{statusCode: http.StatusNoContent}
+		d = newBinding(sender)
+		_, err = d.Invoke(&bindings.InvokeRequest{
+			Data:     []byte(`[{"op": "replace", "path": "/sensors/temp", "value": 20}]`),
+			Metadata: map[string]string{"twinID": "myTwin"},
+		})
+		assert.NoError(t, err)
+		assert.Contains(t, body(sender.requests[0]), `"path":"/temperature"`)
+	})
+
+	t.Run("no match passes through", func(t *testing.T) {
+		sender := &mockSender{statusCode: http.StatusNoContent}
+		d := newBinding(sender)
+		_, err := d.Invoke(&bindings.InvokeRequest{
+			Data:     []byte(`[{"op": "replace", "path": "/pressure", "value": 1}]`),
+			Metadata: map[string]string{"twinID": "myTwin"},
+		})
+		assert.NoError(t, err)
+		assert.Contains(t, body(sender.requests[0]), `"path":"/pressure"`)
+	})
+
+	t.Run("invalid rules", func(t *testing.T) {
+		for _, rules := range []string{`{"match": "a"}`, `[{"match": "(", "replace": "b"}]`} {
+			_, err := newTestBinding(nil).getAzureDigitalTwinsMetadata(bindings.Metadata{Properties: map[string]string{
+				"adtInstanceUrl": "https://adt.example",
+				"pathRewrites":   rules,
+			}})
+			assert.Error(t, err)
+		}
+	})
+}
+
+func TestPatchFormat(t *testing.T) {
+	mixed := []byte(`[{"op": "replace", "path": "/temperature", "value": 20}, {"humidity": 50}]`)
+
+	t.Run("homogeneous JSON Patch", func(t *testing.T) {
+		sender := &mockSender{statusCode: http.StatusNoContent}
+		d := newTestBinding(sender)
+		_, err := d.Invoke(&bindings.InvokeRequest{
+			Data:     []byte(`[{"op": "replace", "path": "/temperature", "value": 20}, {"op": "remove", "path": "/humidity"}]`),
+			Metadata: map[string]string{"twinID": "myTwin"},
+		})
+		assert.NoError(t, err)
+		assert.Len(t, sender.requests, 1)
+	})
+
+	t.Run("homogeneous merge patch", func(t *testing.T) {
+		sender := &mockSender{statusCode: http.StatusNoContent}
+		d := newTestBinding(sender)
+		_, err := d.Invoke(&bindings.InvokeRequest{
+			Data:     []byte(`[{"temperature": 20, "humidity": null}]`),
+			Metadata: map[string]string{"twinID": "myTwin"},
+		})
+		assert.NoError(t, err)
+		b, _ := ioutil.ReadAll(sender.requests[0].Body)
+		assert.JSONEq(t, `[{"op": "remove", "path": "/humidity"}, {"op": "add", "path": "/temperature", "value": 20}]`, string(b))
+	})
+
+	t.Run("mixed formats are rejected", func(t *testing.T) {
+		sender := &mockSender{statusCode: http.StatusNoContent}
+		d := newTestBinding(sender)
+		_, err := d.Invoke(&bindings.InvokeRequest{Data: mixed, Metadata: map[string]string{"twinID": "myTwin"}})
+		assert.True(t, errors.Is(err, ErrMixedPatchFormat))
+		assert.Contains(t, err.Error(), "operation 1")
+		assert.Empty(t, sender.requests)
+
+		_, err = d.Invoke(&bindings.InvokeRequest{Data: mixed, Metadata: map[string]string{}})
+		assert.True(t, errors.Is(err, ErrMixedPatchFormat))
+		assert.Empty(t, sender.requests)
+	})
+
+	t.Run("explicit format", func(t *testing.T) {
+		sender := &mockSender{statusCode: http.StatusNoContent}
+		d := newTestBinding(sender)
+		_, err := d.Invoke(&bindings.InvokeRequest{Data: mixed, Metadata: map[string]string{"twinID": "myTwin", "patchFormat": "jsonPatch"}})
+		assert.NoError(t, err)
+		assert.Len(t, sender.requests, 1)
+
+		_, err = d.Invoke(&bindings.InvokeRequest{Data: mixed, Metadata: map[string]string{"twinID": "myTwin", "patchFormat": "xml"}})
+		assert.Error(t, err)
+	})
+
+	t.Run("merge patch requires twinID", func(t *testing.T) {
+		d := newTestBinding(&mockSender{statusCode: http.StatusNoContent})
+		_, err := d.Invoke(&bindings.InvokeRequest{Data: []byte(`[{"temperature": 20}]`), Metadata: map[string]string{}})
+		assert.Error(t, err)
+	})
+}
+
+func TestTwinDurations(t *testing.T) {
+	sender := &mockSender{
+		statusCode: http.StatusNoContent,
+		delays:     map[string]time.Duration{"slowTwin": 50 * time.Millisecond},
+	}
+	d := newTestBinding(sender)
+	res, err := d.Invoke(&bindings.InvokeRequest{
+		Data:     []byte(`[{"op": "replace", "path": "/slowTwin/temperature", "value": 20}, {"op": "replace", "path": "/fastTwin/temperature", "value": 21}]`),
+		Metadata: map[string]string{},
+	})
+	assert.NoError(t, err)
+
+	slow, err := strconv.ParseFloat(res.Metadata["durationMs.slowTwin"], 64)
+	assert.NoError(t, err)
+	fast, err := strconv.ParseFloat(res.Metadata["durationMs.fastTwin"], 64)
+	assert.NoError(t, err)
+	assert.True(t, slow >= 50, slow)
+	assert.True(t, fast < slow, fast)
+}
+
+func TestSurfaceErrors(t *testing.T) {
+	t.Run("api error with status and body", func(t *testing.T) {
+		sender := &mockSender{
+			statusCode: http.StatusBadRequest,
+			body:       `{"error": {"code": "JsonPatchInvalid", "message": "The JSON Patch provided is invalid."}}`,
+		}
+		d := newTestBinding(sender)
+		_, err := d.Invoke(&bindings.InvokeRequest{
+			Data:     []byte(`[{"op": "replace", "path": "/myTwin/temperature", "value": 20}]`),
+			Metadata: map[string]string{},
+		})
+
+		var apiErr *APIError
+		assert.True(t, errors.As(err, &apiErr))
+		assert.Equal(t, http.StatusBadRequest, apiErr.StatusCode)
+		assert.Equal(t, "JsonPatchInvalid", apiErr.Code)
+		assert.Contains(t, err.Error(), "status 400")
+		assert.Contains(t, err.Error(), "The JSON Patch provided is invalid.")
+		assert.Contains(t, err.Error(), "myTwin")
+	})
+
+	t.Run("invalid request data", func(t *testing.T) {
+		d := newTestBinding(&mockSender{statusCode: http.StatusNoContent})
+		_, err := d.Invoke(&bindings.InvokeRequest{Data: []byte(`{`), Metadata: map[string]string{}})
+		assert.Error(t, err)
+
+		_, err = d.Invoke(&bindings.InvokeRequest{Data: []byte(`{`), Metadata: map[string]string{"twinID": "myTwin"}})
+		assert.Error(t, err)
+	})
+
+	t.Run("invalid path", func(t *testing.T) {
+		sender := &mockSender{statusCode: http.StatusNoContent}
+		d := newTestBinding(sender)
+		_, err := d.Invoke(&bindings.InvokeRequest{Data: []byte(`[{"op": "replace", "path": "/temperature", "value": 20}]`), Metadata: map[string]string{}})
+		assert.Error(t, err)
+		assert.Contains(t, err.Error(), "operation 0")
+		assert.Empty(t, sender.requests)
+	})
+
+	t.Run("response lists patched twins", func(t *testing.T) {
+		twin := `{"$metadata": {"$lastUpdateTime": "2021-01-01T10:00:00Z"}}`
+		d := newTestBinding(&mockSender{statusCode: http.StatusNoContent, twin: twin})
+		d.rejectStale = true
+		res, err := d.Invoke(&bindings.InvokeRequest{
+			Data:     []byte(`[{"op": "replace", "path": "/twinA/temperature", "value": 20}, {"op": "replace", "path": "/twinB/temperature", "value": 21}, {"op": "replace", "path": "/twinA/humidity", "value": 50}]`),
+			Metadata: map[string]string{"time": "2021-01-01T11:00:00Z"},
+		})
+		assert.NoError(t, err)
+		assert.JSONEq(t, `{"patched": ["twinA", "twinB"]}`, string(res.Data))
+
+		res, err = d.Invoke(&bindings.InvokeRequest{
+			Data:     []byte(`[{"op": "replace", "path": "/temperature", "value": 20}]`),
+			Metadata: map[string]string{"twinID": "myTwin", "time": "2021-01-01T09:00:00Z"},
+		})
+		assert.NoError(t, err)
+		assert.JSONEq(t, `{"patched": [], "skipped": ["myTwin"]}`, string(res.Data))
+	})
+}
+
+func TestBatchPatch(t *testing.T) {
+	patch := []byte(`[
+		{"op": "replace", "path": "/twinA/temperature", "value": 20},
+		{"op": "replace", "path": "/twinB/temperature", "value": 21},
+		{"op": "replace", "path": "/twinA/humidity", "value": 50}
+	]`)
+
+	t.Run("one call per twin", func(t *testing.T) {
+		sender := &mockSender{statusCode: http.StatusNoContent}
+		d := newTestBinding(sender)
+		_, err := d.Invoke(&bindings.InvokeRequest{Data: patch, Metadata: map[string]string{}})
+		assert.NoError(t, err)
+		assert.Len(t, sender.requests, 2)
+
+		assert.Equal(t, "/digitaltwins/twinA", sender.requests[0].URL.Path)
+		b, _ := ioutil.ReadAll(sender.requests[0].Body)
+		assert.JSONEq(t, `[{"op": "replace", "path": "/temperature", "value": 20}, {"op": "replace", "path": "/humidity", "value": 50}]`, string(b))
+	})
+
+	t.Run("partial failure", func(t *testing.T) {
+		sender := &mockSender{
+			statusCode:  http.StatusNoContent,
+			statusCodes: map[string]int{"twinB": http.StatusPreconditionFailed},
+		}
+		d := newTestBinding(sender)
+		res, err := d.Invoke(&bindings.InvokeRequest{Data: patch, Metadata: map[string]string{"ifMatch.twinB": `W/"1"`}})
+		assert.NoError(t, err)
+
+		var result patchResult
+		err = json.Unmarshal(res.Data, &result)
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"twinA"}, result.Patched)
+		assert.Len(t, result.Failed, 1)
+		assert.Equal(t, "twinB", result.Failed[0].TwinID)
+		assert.Equal(t, http.StatusPreconditionFailed, result.Failed[0].StatusCode)
+		assert.Contains(t, result.Failed[0].Error, "twinB")
+	})
+
+	t.Run("all twins failed", func(t *testing.T) {
+		sender := &mockSender{statusCode: http.StatusBadRequest}
+		d := newTestBinding(sender)
+		_, err := d.Invoke(&bindings.InvokeRequest{Data: patch, Metadata: map[string]string{}})
+		assert.Error(t, err)
+		assert.Len(t, sender.requests, 2)
+	})
+
+	t.Run("parallelism", func(t *testing.T) {
+		delay := 50 * time.Millisecond
+		sender := &mockSender{
+			statusCode: http.StatusNoContent,
+			delays:     map[string]time.Duration{"twinA": delay, "twinB": delay, "twinC": delay},
+		}
+		d := newTestBinding(sender)
+		d.maxParallelism = 3
+		start := time.Now()
+		res, err := d.Invoke(&bindings.InvokeRequest{
+			Data:     []byte(`[{"op": "add", "path": "/twinA/x", "value": 1}, {"op": "add", "path": "/twinB/x", "value": 1}, {"op": "add", "path": "/twinC/x", "value": 1}]`),
+			Metadata: map[string]string{},
+		})
+		elapsed := time.Since(start)
+		assert.NoError(t, err)
+		assert.True(t, elapsed < 3*delay, elapsed)
+		// the patched twins are listed in the order of the operations
+		assert.JSONEq(t, `{"patched": ["twinA", "twinB", "twinC"]}`, string(res.Data))
+	})
+
+	t.Run("maxParallelism metadata", func(t *testing.T) {
+		m := bindings.Metadata{Properties: map[string]string{"adtInstanceUrl": "https://adt.example"}}
+		d := NewAzureDigitalTwins(logger.NewLogger("test"))
+		meta, err := d.getAzureDigitalTwinsMetadata(m)
+		assert.NoError(t, err)
+		assert.Equal(t, defaultMaxParallelism, meta.maxParallelism)
+
+		m.Properties["maxParallelism"] = "8"
+		meta, err = d.getAzureDigitalTwinsMetadata(m)
+		assert.NoError(t, err)
+		assert.Equal(t, 8, meta.maxParallelism)
+
+		m.Properties["maxParallelism"] = "0"
+		_, err = d.getAzureDigitalTwinsMetadata(m)
+		assert.Error(t, err)
+	})
+}
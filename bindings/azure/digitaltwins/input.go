@@ -0,0 +1,198 @@
+// ------------------------------------------------------------
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT License.
+// ------------------------------------------------------------
+
+package digitaltwins
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+
+	"github.com/dapr/components-contrib/bindings"
+)
+
+const (
+	// eventsPath is the path Event Grid delivers twin events to
+	eventsPath = "/api/events"
+
+	// digitalTwinsEventTypePrefix prefixes the types of the twin and relationship change and lifecycle events,
+	// e.g. Microsoft.DigitalTwins.Twin.Update or Microsoft.DigitalTwins.Relationship.Create
+	digitalTwinsEventTypePrefix = "Microsoft.DigitalTwins."
+	// subscriptionValidationEventType is the type of the handshake Event Grid sends when a subscription is created
+	subscriptionValidationEventType = "Microsoft.EventGrid.SubscriptionValidationEvent"
+	// relationshipSubjectSeparator separates the twin from the relationship ID in the subject of relationship events
+	relationshipSubjectSeparator = "/relationships/"
+
+	// read response metadata keys, along with twinID, relationshipId and time (see eventTimeKey)
+	eventIDKey   = "eventId"
+	eventTypeKey = "eventType"
+	sourceKey    = "source"
+)
+
+// twinEvent is an Azure Digital Twins event, in Event Grid or CloudEvents schema
+type twinEvent struct {
+	// Event Grid schema
+	ID        string          `json:"id"`
+	Topic     string          `json:"topic"`
+	Subject   string          `json:"subject"`
+	EventType string          `json:"eventType"`
+	EventTime string          `json:"eventTime"`
+	Data      json.RawMessage `json:"data"`
+
+	// CloudEvents schema, sharing id, subject and data
+	SpecVersion string `json:"specversion"`
+	Source      string `json:"source"`
+	Type        string `json:"type"`
+	Time        string `json:"time"`
+}
+
+// eventType returns the type of the event in either schema
+func (e *twinEvent) eventType() string {
+	if e.SpecVersion != "" {
+		return e.Type
+	}
+
+	return e.EventType
+}
+
+// metadata returns the read response metadata of the event: the twin and, for relationship events,
+// the relationship the event is about, along with the event ID, type, time and source
+func (e *twinEvent) metadata() map[string]string {
+	metadata := map[string]string{
+		eventIDKey:   e.ID,
+		eventTypeKey: e.eventType(),
+		eventTimeKey: e.EventTime,
+		sourceKey:    e.Topic,
+	}
+	if e.SpecVersion != "" {
+		metadata[eventTimeKey] = e.Time
+		metadata[sourceKey] = e.Source
+	}
+
+	twinID := e.Subject
+	if i := strings.Index(twinID, relationshipSubjectSeparator); i >= 0 {
+		metadata[relationshipIDKey] = twinID[i+len(relationshipSubjectSeparator):]
+		twinID = twinID[:i]
+	}
+	metadata[twinIDKey] = twinID
+
+	return metadata
+}
+
+// Read receives the Azure Digital Twins events routed to an Event Grid subscription whose endpoint is
+// the eventsPath of the eventPort, and triggers the handler with the data of each twin or relationship event.
+// It blocks while the events are being received.
+func (d *AzureDigitalTwins) Read(handler func(*bindings.ReadResponse) error) error {
+	if d.eventPort == "" {
+		return errors.New("azureDigitalTwins error: missing eventPort for the input binding")
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc(eventsPath, d.eventsHandler(handler))
+
+	d.logger.Infof("listening for Azure Digital Twins events at http://localhost:%s%s", d.eventPort, eventsPath)
+
+	return http.ListenAndServe(fmt.Sprintf(":%s", d.eventPort), mux)
+}
+
+// eventsHandler handles Event Grid deliveries: the webhook validation of both schemas,
+// then batches of Event Grid schema events or single and batched CloudEvents
+func (d *AzureDigitalTwins) eventsHandler(handler func(*bindings.ReadResponse) error) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodOptions:
+			// CloudEvents webhook validation
+			w.Header().Set("WebHook-Allowed-Origin", r.Header.Get("WebHook-Request-Origin"))
+			w.Header().Set("WebHook-Allowed-Rate", "*")
+			w.WriteHeader(http.StatusOK)
+		case http.MethodPost:
+			d.handleEvents(handler, w, r)
+		default:
+			w.WriteHeader(http.StatusMethodNotAllowed)
+		}
+	}
+}
+
+func (d *AzureDigitalTwins) handleEvents(handler func(*bindings.ReadResponse) error, w http.ResponseWriter, r *http.Request) {
+	body, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+
+		return
+	}
+
+	events, err := parseEvents(body)
+	if err != nil {
+		d.logger.Warnf("Invalid Azure Digital Twins event delivery: %s", err)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+
+		return
+	}
+
+	for _, e := range events {
+		eventType := e.eventType()
+		if eventType == subscriptionValidationEventType {
+			var validation struct {
+				ValidationCode string `json:"validationCode"`
+			}
+			err = json.Unmarshal(e.Data, &validation)
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+
+				return
+			}
+
+			w.Header().Set("Content-Type", "application/json")
+			err = json.NewEncoder(w).Encode(map[string]string{"validationResponse": validation.ValidationCode})
+			if err != nil {
+				d.logger.Errorf("Error responding to Event Grid subscription validation: %s", err)
+			}
+
+			return
+		}
+
+		if !strings.HasPrefix(eventType, digitalTwinsEventTypePrefix) {
+			d.logger.Debugf("Skipping event %s of type %s", e.ID, eventType)
+
+			continue
+		}
+
+		err = handler(&bindings.ReadResponse{Data: e.Data, Metadata: e.metadata()})
+		if err != nil {
+			// Event Grid retries the whole delivery
+			d.logger.Errorf("Error handling Azure Digital Twins event %s: %s", e.ID, err)
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+
+			return
+		}
+	}
+
+	w.WriteHeader(http.StatusOK)
+}
+
+// parseEvents parses a delivery, an array of events or a single CloudEvent
+func parseEvents(body []byte) ([]twinEvent, error) {
+	var events []twinEvent
+	if strings.HasPrefix(strings.TrimSpace(string(body)), "[") {
+		err := json.Unmarshal(body, &events)
+
+		return events, err
+	}
+
+	var event twinEvent
+	err := json.Unmarshal(body, &event)
+	if err != nil {
+		return nil, err
+	}
+
+	if event.SpecVersion == "" {
+		return nil, errors.New("expected an array of Event Grid events or a CloudEvent")
+	}
+
+	return []twinEvent{event}, nil
+}
@@ -0,0 +1,120 @@
+// ------------------------------------------------------------
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT License.
+// ------------------------------------------------------------
+
+package digitaltwins
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/dapr/components-contrib/bindings"
+	"github.com/dapr/dapr/pkg/logger"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestReadEvents(t *testing.T) {
+	deliver := func(handler func(*bindings.ReadResponse) error, method, body string) *httptest.ResponseRecorder {
+		d := NewAzureDigitalTwins(logger.NewLogger("test"))
+		req := httptest.NewRequest(method, eventsPath, strings.NewReader(body))
+		req.Header.Set("WebHook-Request-Origin", "eventgrid.azure.net")
+		w := httptest.NewRecorder()
+		d.eventsHandler(handler).ServeHTTP(w, req)
+
+		return w
+	}
+
+	collect := func(responses *[]*bindings.ReadResponse) func(*bindings.ReadResponse) error {
+		return func(res *bindings.ReadResponse) error {
+			*responses = append(*responses, res)
+
+			return nil
+		}
+	}
+
+	t.Run("event grid subscription validation", func(t *testing.T) {
+		w := deliver(nil, http.MethodPost, `[{
+			"id": "1",
+			"eventType": "Microsoft.EventGrid.SubscriptionValidationEvent",
+			"data": {"validationCode": "512d38b6-c7b8-40c8-89fe-f46f9e9622b6"}
+		}]`)
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.JSONEq(t, `{"validationResponse": "512d38b6-c7b8-40c8-89fe-f46f9e9622b6"}`, w.Body.String())
+	})
+
+	t.Run("cloudevents webhook validation", func(t *testing.T) {
+		w := deliver(nil, http.MethodOptions, "")
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, "eventgrid.azure.net", w.Header().Get("WebHook-Allowed-Origin"))
+	})
+
+	t.Run("event grid schema", func(t *testing.T) {
+		var responses []*bindings.ReadResponse
+		w := deliver(collect(&responses), http.MethodPost, `[{
+			"id": "1",
+			"topic": "myinstance.api.wus2.digitaltwins.azure.net",
+			"subject": "myTwin",
+			"eventType": "Microsoft.DigitalTwins.Twin.Update",
+			"eventTime": "2021-01-01T10:00:00Z",
+			"data": {"modelId": "dtmi:example:Sensor;1", "patch": [{"op": "replace", "path": "/temperature", "value": 20}]}
+		}, {
+			"id": "2",
+			"subject": "storage/blob",
+			"eventType": "Microsoft.Storage.BlobCreated",
+			"data": {}
+		}]`)
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Len(t, responses, 1)
+		assert.JSONEq(t, `{"modelId": "dtmi:example:Sensor;1", "patch": [{"op": "replace", "path": "/temperature", "value": 20}]}`, string(responses[0].Data))
+		assert.Equal(t, map[string]string{
+			"eventId":   "1",
+			"eventType": "Microsoft.DigitalTwins.Twin.Update",
+			"time":      "2021-01-01T10:00:00Z",
+			"source":    "myinstance.api.wus2.digitaltwins.azure.net",
+			"twinID":    "myTwin",
+		}, responses[0].Metadata)
+	})
+
+	t.Run("cloudevents schema", func(t *testing.T) {
+		var responses []*bindings.ReadResponse
+		w := deliver(collect(&responses), http.MethodPost, `{
+			"specversion": "1.0",
+			"id": "1",
+			"source": "myinstance.api.wus2.digitaltwins.azure.net",
+			"subject": "myTwin/relationships/rel1",
+			"type": "Microsoft.DigitalTwins.Relationship.Create",
+			"time": "2021-01-01T10:00:00Z",
+			"data": {"$relationshipId": "rel1"}
+		}`)
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Len(t, responses, 1)
+		assert.Equal(t, "myTwin", responses[0].Metadata["twinID"])
+		assert.Equal(t, "rel1", responses[0].Metadata["relationshipId"])
+		assert.Equal(t, "Microsoft.DigitalTwins.Relationship.Create", responses[0].Metadata["eventType"])
+		assert.Equal(t, "2021-01-01T10:00:00Z", responses[0].Metadata["time"])
+	})
+
+	t.Run("handler failure is retried", func(t *testing.T) {
+		w := deliver(func(*bindings.ReadResponse) error {
+			return errors.New("app unavailable")
+		}, http.MethodPost, `[{"id": "1", "subject": "myTwin", "eventType": "Microsoft.DigitalTwins.Twin.Delete", "data": {}}]`)
+		assert.Equal(t, http.StatusInternalServerError, w.Code)
+	})
+
+	t.Run("invalid delivery", func(t *testing.T) {
+		for _, body := range []string{`{`, `{"id": "1"}`} {
+			w := deliver(nil, http.MethodPost, body)
+			assert.Equal(t, http.StatusBadRequest, w.Code, body)
+		}
+	})
+
+	t.Run("missing eventPort", func(t *testing.T) {
+		d := NewAzureDigitalTwins(logger.NewLogger("test"))
+		err := d.Read(nil)
+		assert.Error(t, err)
+	})
+}
@@ -0,0 +1,169 @@
+// ------------------------------------------------------------
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT License.
+// ------------------------------------------------------------
+
+package digitaltwins
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strconv"
+
+	"github.com/dapr/components-contrib/bindings"
+
+	"github.com/dapr/components-contrib/bindings/azure/digitaltwins/digitaltwinsrest"
+)
+
+// modelsResult is the response data of model requests
+type modelsResult struct {
+	Value []digitaltwinsrest.DigitalTwinsModelData `json:"value"`
+}
+
+// rawModel is a DTDL model passed through as is. Unlike decoded JSON objects it is comparable,
+// which the unique items validation of the REST client requires.
+type rawModel struct {
+	json string
+}
+
+// MarshalJSON returns the model JSON
+func (m rawModel) MarshalJSON() ([]byte, error) {
+	return []byte(m.json), nil
+}
+
+// newModelsClient creates a REST client for the DTDL models of the configured Azure Digital Twins instance
+func (d *AzureDigitalTwins) newModelsClient() (digitaltwinsrest.DigitalTwinModelsClient, error) {
+	client, err := d.newBaseClient()
+
+	return digitaltwinsrest.DigitalTwinModelsClient{BaseClient: client}, err
+}
+
+// getModelID returns the model ID of the request metadata
+func getModelID(op string, metadata map[string]string) (string, error) {
+	modelID := metadata[modelIDKey]
+	if modelID == "" {
+		return "", wrapErr(op, "", fmt.Errorf("missing %s metadata", modelIDKey))
+	}
+
+	return modelID, nil
+}
+
+// modelsResponse returns the models as an InvokeResponse
+func modelsResponse(op string, models []digitaltwinsrest.DigitalTwinsModelData) (*bindings.InvokeResponse, error) {
+	if models == nil {
+		models = []digitaltwinsrest.DigitalTwinsModelData{}
+	}
+
+	data, err := json.Marshal(modelsResult{Value: models})
+	if err != nil {
+		return nil, wrapErr(op, "", err)
+	}
+
+	return &bindings.InvokeResponse{Data: data, Metadata: map[string]string{}}, nil
+}
+
+// createModels uploads the JSON array of DTDL models of the request data
+func (d *AzureDigitalTwins) createModels(req *bindings.InvokeRequest) (*bindings.InvokeResponse, error) {
+	op := string(createModelsOperation)
+	var raw []json.RawMessage
+	err := json.Unmarshal(req.Data, &raw)
+	if err != nil {
+		return nil, wrapErr(op, "", fmt.Errorf("invalid request data, expected a JSON array of models: %w", err))
+	}
+	models := make([]interface{}, len(raw))
+	for i, model := range raw {
+		models[i] = rawModel{json: string(model)}
+	}
+
+	client, err := d.newModelsClient()
+	if err != nil {
+		return nil, wrapErr(op, "", err)
+	}
+
+	res, err := client.Add(context.TODO(), models, "", "")
+	if err != nil {
+		return nil, responseErr(op, "", res.Response.Response, err)
+	}
+
+	var created []digitaltwinsrest.DigitalTwinsModelData
+	if res.Value != nil {
+		created = *res.Value
+	}
+
+	return modelsResponse(op, created)
+}
+
+// listModels returns all models, with their definitions when includeModelDefinition is set
+func (d *AzureDigitalTwins) listModels(req *bindings.InvokeRequest) (*bindings.InvokeResponse, error) {
+	op := string(listModelsOperation)
+	var includeModelDefinition *bool
+	if val, ok := req.Metadata[includeModelDefinitionKey]; ok && val != "" {
+		include, err := strconv.ParseBool(val)
+		if err != nil {
+			return nil, wrapErr(op, "", fmt.Errorf("invalid %s %s: %w", includeModelDefinitionKey, val, err))
+		}
+		includeModelDefinition = &include
+	}
+
+	client, err := d.newModelsClient()
+	if err != nil {
+		return nil, wrapErr(op, "", err)
+	}
+
+	ctx := context.TODO()
+	page, err := client.List(ctx, nil, includeModelDefinition, nil, "", "")
+	var models []digitaltwinsrest.DigitalTwinsModelData
+	for err == nil && page.NotDone() {
+		models = append(models, page.Values()...)
+		err = page.NextWithContext(ctx)
+	}
+	if err != nil {
+		return nil, responseErr(op, "", page.Response().Response.Response, err)
+	}
+
+	return modelsResponse(op, models)
+}
+
+// decommissionModel marks the model as decommissioned, so that new twins can no longer reference it
+func (d *AzureDigitalTwins) decommissionModel(req *bindings.InvokeRequest) (*bindings.InvokeResponse, error) {
+	op := string(decommissionModelOperation)
+	modelID, err := getModelID(op, req.Metadata)
+	if err != nil {
+		return nil, err
+	}
+
+	client, err := d.newModelsClient()
+	if err != nil {
+		return nil, wrapErr(op, "", err)
+	}
+
+	patch := []interface{}{jsonPatchOperation{Op: "replace", Path: "/decommissioned", Value: true}}
+	res, err := client.Update(context.TODO(), modelID, patch, "", "")
+	if err != nil {
+		return nil, responseErr(op, "", res.Response, fmt.Errorf("model %s: %w", modelID, err))
+	}
+
+	return &bindings.InvokeResponse{Metadata: map[string]string{}}, nil
+}
+
+// deleteModel deletes the model, which must no longer be referenced by other models
+func (d *AzureDigitalTwins) deleteModel(req *bindings.InvokeRequest) (*bindings.InvokeResponse, error) {
+	op := string(deleteModelOperation)
+	modelID, err := getModelID(op, req.Metadata)
+	if err != nil {
+		return nil, err
+	}
+
+	client, err := d.newModelsClient()
+	if err != nil {
+		return nil, wrapErr(op, "", err)
+	}
+
+	res, err := client.Delete(context.TODO(), modelID, "", "")
+	if err != nil {
+		return nil, responseErr(op, "", res.Response, fmt.Errorf("model %s: %w", modelID, err))
+	}
+
+	return &bindings.InvokeResponse{Metadata: map[string]string{}}, nil
+}
@@ -0,0 +1,79 @@
+// ------------------------------------------------------------
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT License.
+// ------------------------------------------------------------
+
+package digitaltwins
+
+import (
+	"io/ioutil"
+	"net/http"
+	"testing"
+
+	"github.com/dapr/components-contrib/bindings"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestModels(t *testing.T) {
+	model := `{"@id": "dtmi:example:Sensor;1", "@type": "Interface", "@context": "dtmi:dtdl:context;2"}`
+
+	t.Run("create", func(t *testing.T) {
+		sender := &mockSender{statusCode: http.StatusCreated, body: `[{"id": "dtmi:example:Sensor;1", "decommissioned": false}]`}
+		d := newTestBinding(sender)
+		res, err := d.Invoke(&bindings.InvokeRequest{Operation: "createModels", Data: []byte("[" + model + "]"), Metadata: map[string]string{}})
+		assert.NoError(t, err)
+		assert.JSONEq(t, `{"value": [{"id": "dtmi:example:Sensor;1", "decommissioned": false}]}`, string(res.Data))
+
+		req := sender.requests[0]
+		assert.Equal(t, http.MethodPost, req.Method)
+		assert.Equal(t, "/models", req.URL.Path)
+		b, _ := ioutil.ReadAll(req.Body)
+		assert.JSONEq(t, "["+model+"]", string(b))
+	})
+
+	t.Run("create expects an array", func(t *testing.T) {
+		d := newTestBinding(&mockSender{statusCode: http.StatusCreated})
+		_, err := d.Invoke(&bindings.InvokeRequest{Operation: "createModels", Data: []byte(model), Metadata: map[string]string{}})
+		assert.Error(t, err)
+	})
+
+	t.Run("list", func(t *testing.T) {
+		sender := &mockSender{twin: `{"value": [{"id": "dtmi:example:Sensor;1"}, {"id": "dtmi:example:Room;1"}]}`}
+		d := newTestBinding(sender)
+		res, err := d.Invoke(&bindings.InvokeRequest{Operation: "listModels", Metadata: map[string]string{"includeModelDefinition": "true"}})
+		assert.NoError(t, err)
+		assert.JSONEq(t, `{"value": [{"id": "dtmi:example:Sensor;1"}, {"id": "dtmi:example:Room;1"}]}`, string(res.Data))
+		assert.Equal(t, "/models", sender.requests[0].URL.Path)
+		assert.Equal(t, "true", sender.requests[0].URL.Query().Get("includeModelDefinition"))
+	})
+
+	t.Run("decommission", func(t *testing.T) {
+		sender := &mockSender{statusCode: http.StatusNoContent}
+		d := newTestBinding(sender)
+		_, err := d.Invoke(&bindings.InvokeRequest{Operation: "decommissionModel", Metadata: map[string]string{"modelId": "dtmi:example:Sensor;1"}})
+		assert.NoError(t, err)
+
+		req := sender.requests[0]
+		assert.Equal(t, http.MethodPatch, req.Method)
+		assert.Equal(t, "/models/dtmi:example:Sensor;1", req.URL.Path)
+		b, _ := ioutil.ReadAll(req.Body)
+		assert.JSONEq(t, `[{"op": "replace", "path": "/decommissioned", "value": true}]`, string(b))
+	})
+
+	t.Run("delete", func(t *testing.T) {
+		sender := &mockSender{statusCode: http.StatusNoContent}
+		d := newTestBinding(sender)
+		_, err := d.Invoke(&bindings.InvokeRequest{Operation: "deleteModel", Metadata: map[string]string{"modelId": "dtmi:example:Sensor;1"}})
+		assert.NoError(t, err)
+		assert.Equal(t, http.MethodDelete, sender.requests[0].Method)
+		assert.Equal(t, "/models/dtmi:example:Sensor;1", sender.requests[0].URL.Path)
+	})
+
+	t.Run("missing modelId", func(t *testing.T) {
+		d := newTestBinding(&mockSender{statusCode: http.StatusNoContent})
+		for _, op := range []bindings.OperationKind{"decommissionModel", "deleteModel"} {
+			_, err := d.Invoke(&bindings.InvokeRequest{Operation: op, Metadata: map[string]string{}})
+			assert.Error(t, err, op)
+		}
+	})
+}
@@ -0,0 +1,482 @@
+// ------------------------------------------------------------
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT License.
+// ------------------------------------------------------------
+
+package digitaltwins
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/dapr/components-contrib/bindings"
+
+	"github.com/Azure/go-autorest/autorest"
+	"github.com/google/uuid"
+
+	"github.com/dapr/components-contrib/bindings/azure/digitaltwins/digitaltwinsrest"
+)
+
+// twinIDProperty is the twin document property holding the twin ID
+const twinIDProperty = "$dtId"
+
+// queryResult is the response data of query requests
+type queryResult struct {
+	Value             []interface{} `json:"value"`
+	ContinuationToken string        `json:"continuationToken,omitempty"`
+}
+
+// getTwinID returns the twin ID of the request metadata
+func getTwinID(op string, metadata map[string]string) (string, error) {
+	twinID := metadata[twinIDKey]
+	if twinID == "" {
+		return "", wrapErr(op, "", fmt.Errorf("missing %s metadata", twinIDKey))
+	}
+
+	return twinID, nil
+}
+
+// getTwin returns the twin as JSON, along with its ETag under the ifMatch and etag response metadata
+// so that it can be passed on to a conditional update
+func (d *AzureDigitalTwins) getTwin(req *bindings.InvokeRequest) (*bindings.InvokeResponse, error) {
+	twinID, err := getTwinID(getOperation, req.Metadata)
+	if err != nil {
+		return nil, err
+	}
+
+	client, err := d.newClient()
+	if err != nil {
+		return nil, wrapErr(getOperation, twinID, err)
+	}
+
+	res, err := client.GetByID(context.TODO(), twinID, "", "")
+	if err != nil {
+		return nil, responseErr(getOperation, twinID, res.Response.Response, err)
+	}
+
+	data, err := json.Marshal(res.Value)
+	if err != nil {
+		return nil, wrapErr(getOperation, twinID, err)
+	}
+
+	etag := res.Header.Get("ETag")
+
+	return &bindings.InvokeResponse{
+		Data:     data,
+		Metadata: map[string]string{ifMatchKey: etag, etagKey: etag},
+	}, nil
+}
+
+// deleteTwin deletes the twin, conditional on the ETag in ifMatch
+func (d *AzureDigitalTwins) deleteTwin(req *bindings.InvokeRequest) (*bindings.InvokeResponse, error) {
+	twinID, err := getTwinID(deleteOperation, req.Metadata)
+	if err != nil {
+		return nil, err
+	}
+
+	client, err := d.newClient()
+	if err != nil {
+		return nil, wrapErr(deleteOperation, twinID, err)
+	}
+
+	ifMatch := getIfMatch(req.Metadata, ifMatchKey)
+	res, err := client.Delete(context.TODO(), twinID, ifMatch, "", "")
+	if err != nil {
+		return nil, conditionalErr(deleteOperation, twinID, res.Response, err, ifMatch)
+	}
+
+	return &bindings.InvokeResponse{Metadata: map[string]string{}}, nil
+}
+
+// queryTwins runs the query of the request data and returns the results of all pages,
+// or of the first maxPages pages along with the continuation token of the next one.
+// A continuationToken in the request metadata resumes a previous query.
+func (d *AzureDigitalTwins) queryTwins(req *bindings.InvokeRequest) (*bindings.InvokeResponse, error) {
+	op := string(queryOperation)
+	query := string(req.Data)
+	spec := digitaltwinsrest.QuerySpecification{}
+	if token := req.Metadata[continuationTokenKey]; token != "" {
+		spec.ContinuationToken = &token
+	} else if query != "" {
+		spec.Query = &query
+	} else {
+		return nil, wrapErr(op, "", errors.New("missing query in request data"))
+	}
+
+	maxPages := 0
+	if val, ok := req.Metadata[maxPagesKey]; ok && val != "" {
+		var err error
+		maxPages, err = strconv.Atoi(val)
+		if err != nil {
+			return nil, wrapErr(op, "", fmt.Errorf("invalid %s %s: %w", maxPagesKey, val, err))
+		}
+	}
+
+	base, err := d.newBaseClient()
+	if err != nil {
+		return nil, wrapErr(op, "", err)
+	}
+	client := digitaltwinsrest.QueryClient{BaseClient: base}
+
+	result := queryResult{Value: []interface{}{}}
+	for pages := 1; ; pages++ {
+		res, err := client.QueryTwins(context.TODO(), spec, nil, "", "")
+		if err != nil {
+			return nil, responseErr(op, "", res.Response.Response, err)
+		}
+
+		if res.Value != nil {
+			result.Value = append(result.Value, *res.Value...)
+		}
+
+		if res.ContinuationToken == nil || *res.ContinuationToken == "" {
+			break
+		}
+
+		if maxPages > 0 && pages >= maxPages {
+			result.ContinuationToken = *res.ContinuationToken
+
+			break
+		}
+
+		spec = digitaltwinsrest.QuerySpecification{ContinuationToken: res.ContinuationToken}
+	}
+
+	data, err := json.Marshal(result)
+	if err != nil {
+		return nil, wrapErr(op, "", err)
+	}
+
+	response := &bindings.InvokeResponse{Data: data, Metadata: map[string]string{}}
+	if result.ContinuationToken != "" {
+		response.Metadata[continuationTokenKey] = result.ContinuationToken
+	}
+
+	return response, nil
+}
+
+// getRelationshipID returns the twin and relationship IDs of the request metadata
+func getRelationshipID(op bindings.OperationKind, metadata map[string]string) (string, string, error) {
+	twinID, err := getTwinID(string(op), metadata)
+	if err != nil {
+		return "", "", err
+	}
+
+	relationshipID := metadata[relationshipIDKey]
+	if relationshipID == "" {
+		return "", "", wrapErr(string(op), twinID, fmt.Errorf("missing %s metadata", relationshipIDKey))
+	}
+
+	return twinID, relationshipID, nil
+}
+
+// createRelationship creates or replaces the relationship given in the request data,
+// unless ifNoneMatch is "*" and the relationship exists
+func (d *AzureDigitalTwins) createRelationship(req *bindings.InvokeRequest) (*bindings.InvokeResponse, error) {
+	op := string(createRelationshipOperation)
+	twinID, relationshipID, err := getRelationshipID(createRelationshipOperation, req.Metadata)
+	if err != nil {
+		return nil, err
+	}
+
+	var relationship interface{}
+	err = json.Unmarshal(req.Data, &relationship)
+	if err != nil {
+		return nil, wrapErr(op, twinID, fmt.Errorf("invalid request data: %w", err))
+	}
+
+	client, err := d.newClient()
+	if err != nil {
+		return nil, wrapErr(op, twinID, err)
+	}
+
+	res, err := client.AddRelationship(context.TODO(), twinID, relationshipID, relationship, req.Metadata[ifNoneMatchKey], "", "")
+	if err != nil {
+		if res.Response.Response != nil && res.StatusCode == http.StatusPreconditionFailed {
+			return nil, wrapErr(op, twinID, fmt.Errorf("%w: relationship %s exists", ErrPreconditionFailed, relationshipID))
+		}
+
+		return nil, responseErr(op, twinID, res.Response.Response, err)
+	}
+
+	data, err := json.Marshal(res.Value)
+	if err != nil {
+		return nil, wrapErr(op, twinID, err)
+	}
+
+	return &bindings.InvokeResponse{
+		Data:     data,
+		Metadata: map[string]string{ifMatchKey: res.Header.Get("ETag")},
+	}, nil
+}
+
+// updateRelationship applies the JSON Patch of the request data to the relationship, conditional on the ETag in ifMatch
+func (d *AzureDigitalTwins) updateRelationship(req *bindings.InvokeRequest) (*bindings.InvokeResponse, error) {
+	op := string(updateRelationshipOperation)
+	twinID, relationshipID, err := getRelationshipID(updateRelationshipOperation, req.Metadata)
+	if err != nil {
+		return nil, err
+	}
+
+	var patchDoc []interface{}
+	err = json.Unmarshal(req.Data, &patchDoc)
+	if err != nil {
+		return nil, wrapErr(op, twinID, fmt.Errorf("invalid request data: %w", err))
+	}
+
+	client, err := d.newClient()
+	if err != nil {
+		return nil, wrapErr(op, twinID, err)
+	}
+
+	ifMatch := getIfMatch(req.Metadata, ifMatchKey)
+	res, err := client.UpdateRelationship(context.TODO(), twinID, relationshipID, patchDoc, ifMatch, "", "")
+	if err != nil {
+		return nil, conditionalErr(op, twinID, res.Response, err, ifMatch)
+	}
+
+	return &bindings.InvokeResponse{Metadata: map[string]string{}}, nil
+}
+
+// listRelationships returns the outgoing relationships of the twin, optionally filtered by relationshipName
+func (d *AzureDigitalTwins) listRelationships(req *bindings.InvokeRequest) (*bindings.InvokeResponse, error) {
+	op := string(listRelationshipsOperation)
+	twinID, err := getTwinID(op, req.Metadata)
+	if err != nil {
+		return nil, err
+	}
+
+	client, err := d.newClient()
+	if err != nil {
+		return nil, wrapErr(op, twinID, err)
+	}
+
+	ctx := context.TODO()
+	page, err := client.ListRelationships(ctx, twinID, req.Metadata[relationshipNameKey], "", "")
+	relationships := []interface{}{}
+	for err == nil && page.NotDone() {
+		relationships = append(relationships, page.Values()...)
+		err = page.NextWithContext(ctx)
+	}
+	if err != nil {
+		return nil, responseErr(op, twinID, page.Response().Response.Response, err)
+	}
+
+	data, err := json.Marshal(queryResult{Value: relationships})
+	if err != nil {
+		return nil, wrapErr(op, twinID, err)
+	}
+
+	return &bindings.InvokeResponse{Data: data, Metadata: map[string]string{}}, nil
+}
+
+// deleteRelationship deletes the relationship, conditional on the ETag in ifMatch
+func (d *AzureDigitalTwins) deleteRelationship(req *bindings.InvokeRequest) (*bindings.InvokeResponse, error) {
+	op := string(deleteRelationshipOperation)
+	twinID, relationshipID, err := getRelationshipID(deleteRelationshipOperation, req.Metadata)
+	if err != nil {
+		return nil, err
+	}
+
+	client, err := d.newClient()
+	if err != nil {
+		return nil, wrapErr(op, twinID, err)
+	}
+
+	ifMatch := getIfMatch(req.Metadata, ifMatchKey)
+	res, err := client.DeleteRelationship(context.TODO(), twinID, relationshipID, ifMatch, "", "")
+	if err != nil {
+		return nil, conditionalErr(op, twinID, res.Response, err, ifMatch)
+	}
+
+	return &bindings.InvokeResponse{Metadata: map[string]string{}}, nil
+}
+
+// sendTelemetry publishes the request data as telemetry of the twin, or of one of its components.
+// The message ID, used by Azure Digital Twins to deduplicate telemetry, is returned in the response metadata.
+func (d *AzureDigitalTwins) sendTelemetry(req *bindings.InvokeRequest) (*bindings.InvokeResponse, error) {
+	op := string(telemetryOperation)
+	twinID, err := getTwinID(op, req.Metadata)
+	if err != nil {
+		return nil, err
+	}
+
+	var telemetry interface{}
+	err = json.Unmarshal(req.Data, &telemetry)
+	if err != nil {
+		return nil, wrapErr(op, twinID, fmt.Errorf("invalid request data: %w", err))
+	}
+
+	messageID := req.Metadata[messageIDKey]
+	if messageID == "" {
+		messageID = uuid.New().String()
+	}
+
+	client, err := d.newClient()
+	if err != nil {
+		return nil, wrapErr(op, twinID, err)
+	}
+
+	sourceTime := req.Metadata[telemetrySourceTimeKey]
+	var res autorest.Response
+	if componentPath := req.Metadata[componentPathKey]; componentPath != "" {
+		res, err = client.SendComponentTelemetry(context.TODO(), twinID, componentPath, telemetry, messageID, sourceTime, "", "")
+	} else {
+		res, err = client.SendTelemetry(context.TODO(), twinID, telemetry, messageID, sourceTime, "", "")
+	}
+	if err != nil {
+		return nil, responseErr(op, twinID, res.Response, err)
+	}
+
+	return &bindings.InvokeResponse{Metadata: map[string]string{messageIDKey: messageID}}, nil
+}
+
+// upsertTwin creates the twin, or replaces it when it exists, with the twin document of the request data,
+// which must name the model of the twin in $metadata.$model. The twin ID is taken from the twinID metadata or the $dtId of the document.
+// Setting ifNoneMatch to "*" only creates the twin, failing with ErrPreconditionFailed when it exists.
+func (d *AzureDigitalTwins) upsertTwin(req *bindings.InvokeRequest) (*bindings.InvokeResponse, error) {
+	op := string(upsertOperation)
+	var twin map[string]interface{}
+	err := json.Unmarshal(req.Data, &twin)
+	if err != nil || twin == nil {
+		return nil, wrapErr(op, req.Metadata[twinIDKey], fmt.Errorf("invalid request data, expected a twin document: %v", err))
+	}
+
+	twinID := req.Metadata[twinIDKey]
+	if twinID == "" {
+		twinID, _ = twin[twinIDProperty].(string)
+	}
+	if twinID == "" {
+		return nil, wrapErr(op, "", fmt.Errorf("missing %s metadata or %s in the twin document", twinIDKey, twinIDProperty))
+	}
+
+	metadata, _ := twin["$metadata"].(map[string]interface{})
+	if model, _ := metadata["$model"].(string); model == "" {
+		return nil, wrapErr(op, twinID, errors.New("missing $metadata.$model in the twin document"))
+	}
+
+	client, err := d.newClient()
+	if err != nil {
+		return nil, wrapErr(op, twinID, err)
+	}
+
+	res, err := client.Add(context.TODO(), twinID, twin, req.Metadata[ifNoneMatchKey], "", "")
+	if err != nil {
+		if res.Response.Response != nil && res.StatusCode == http.StatusPreconditionFailed {
+			return nil, wrapErr(op, twinID, fmt.Errorf("%w: twin exists", ErrPreconditionFailed))
+		}
+
+		return nil, responseErr(op, twinID, res.Response.Response, err)
+	}
+
+	data, err := json.Marshal(res.Value)
+	if err != nil {
+		return nil, wrapErr(op, twinID, err)
+	}
+
+	etag := res.Header.Get("ETag")
+
+	return &bindings.InvokeResponse{
+		Data:     data,
+		Metadata: map[string]string{ifMatchKey: etag, etagKey: etag},
+	}, nil
+}
+
+// getComponentPath returns the twin and component path of the request metadata
+func getComponentPath(op string, metadata map[string]string) (string, string, error) {
+	twinID, err := getTwinID(op, metadata)
+	if err != nil {
+		return "", "", err
+	}
+
+	componentPath := metadata[componentPathKey]
+	if componentPath == "" {
+		return "", "", wrapErr(op, twinID, fmt.Errorf("missing %s metadata", componentPathKey))
+	}
+
+	return twinID, componentPath, nil
+}
+
+// getComponent returns the component of the twin as JSON, along with the ETag of the twin
+func (d *AzureDigitalTwins) getComponent(req *bindings.InvokeRequest) (*bindings.InvokeResponse, error) {
+	op := string(getComponentOperation)
+	twinID, componentPath, err := getComponentPath(op, req.Metadata)
+	if err != nil {
+		return nil, err
+	}
+
+	client, err := d.newClient()
+	if err != nil {
+		return nil, wrapErr(op, twinID, err)
+	}
+
+	res, err := client.GetComponent(context.TODO(), twinID, componentPath, "", "")
+	if err != nil {
+		return nil, responseErr(op, twinID, res.Response.Response, fmt.Errorf("component %s: %w", componentPath, err))
+	}
+
+	data, err := json.Marshal(res.Value)
+	if err != nil {
+		return nil, wrapErr(op, twinID, err)
+	}
+
+	etag := res.Header.Get("ETag")
+
+	return &bindings.InvokeResponse{
+		Data:     data,
+		Metadata: map[string]string{ifMatchKey: etag, etagKey: etag},
+	}, nil
+}
+
+// updateComponent applies the patch of the request data, with paths relative to the component, to the component of the twin.
+// Like twin patches, it is conditional on the ETag in ifMatch and returns the new ETag.
+func (d *AzureDigitalTwins) updateComponent(req *bindings.InvokeRequest) (*bindings.InvokeResponse, error) {
+	op := string(updateComponentOperation)
+	twinID, componentPath, err := getComponentPath(op, req.Metadata)
+	if err != nil {
+		return nil, err
+	}
+
+	var operationDoc []jsonPatchOperation
+	err = json.Unmarshal(req.Data, &operationDoc)
+	if err != nil {
+		return nil, wrapErr(op, twinID, fmt.Errorf("invalid request data: %w", err))
+	}
+
+	operationDoc, err = toJSONPatch(req.Data, operationDoc, req.Metadata[patchFormatKey])
+	if err != nil {
+		return nil, err
+	}
+
+	err = d.checkPatchValues(operationDoc)
+	if err != nil {
+		return nil, err
+	}
+
+	patchDoc := make([]interface{}, len(operationDoc))
+	for i, v := range operationDoc {
+		patchDoc[i] = v
+	}
+
+	client, err := d.newClient()
+	if err != nil {
+		return nil, wrapErr(op, twinID, err)
+	}
+
+	ifMatch := getIfMatch(req.Metadata, ifMatchKey, etagKey)
+	res, err := client.UpdateComponent(context.TODO(), twinID, componentPath, patchDoc, ifMatch, "", "")
+	if err != nil {
+		return nil, conditionalErr(op, twinID, res.Response, fmt.Errorf("component %s: %w", componentPath, err), ifMatch)
+	}
+
+	metadata := map[string]string{}
+	if etag := res.Header.Get("ETag"); etag != "" {
+		metadata[etagKey] = etag
+	}
+
+	return &bindings.InvokeResponse{Metadata: metadata}, nil
+}
@@ -0,0 +1,333 @@
+// ------------------------------------------------------------
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT License.
+// ------------------------------------------------------------
+
+package digitaltwins
+
+import (
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"testing"
+
+	"github.com/dapr/components-contrib/bindings"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGetTwin(t *testing.T) {
+	t.Run("twin and etag", func(t *testing.T) {
+		sender := &mockSender{twin: `{"$dtId": "myTwin", "temperature": 20}`}
+		d := newTestBinding(sender)
+		res, err := d.Invoke(&bindings.InvokeRequest{Operation: bindings.GetOperation, Metadata: map[string]string{"twinID": "myTwin"}})
+		assert.NoError(t, err)
+		assert.JSONEq(t, `{"$dtId": "myTwin", "temperature": 20}`, string(res.Data))
+		assert.Equal(t, `W/"1"`, res.Metadata["ifMatch"])
+		assert.Equal(t, `W/"1"`, res.Metadata["etag"])
+		assert.Equal(t, "/digitaltwins/myTwin", sender.requests[0].URL.Path)
+	})
+
+	t.Run("missing twinID", func(t *testing.T) {
+		d := newTestBinding(&mockSender{})
+		_, err := d.Invoke(&bindings.InvokeRequest{Operation: bindings.GetOperation, Metadata: map[string]string{}})
+		assert.Error(t, err)
+	})
+}
+
+func TestDeleteTwin(t *testing.T) {
+	t.Run("delete", func(t *testing.T) {
+		sender := &mockSender{statusCode: http.StatusNoContent}
+		d := newTestBinding(sender)
+		_, err := d.Invoke(&bindings.InvokeRequest{Operation: bindings.DeleteOperation, Metadata: map[string]string{"twinID": "myTwin", "ifMatch": `W/"1"`}})
+		assert.NoError(t, err)
+		assert.Equal(t, []string{http.MethodDelete}, sender.methods())
+		assert.Equal(t, "/digitaltwins/myTwin", sender.requests[0].URL.Path)
+		assert.Equal(t, `W/"1"`, sender.requests[0].Header.Get("If-Match"))
+	})
+
+	t.Run("precondition failed", func(t *testing.T) {
+		d := newTestBinding(&mockSender{statusCode: http.StatusPreconditionFailed})
+		_, err := d.Invoke(&bindings.InvokeRequest{Operation: bindings.DeleteOperation, Metadata: map[string]string{"twinID": "myTwin", "ifMatch": `W/"1"`}})
+		assert.True(t, errors.Is(err, ErrPreconditionFailed))
+	})
+
+	t.Run("missing twinID", func(t *testing.T) {
+		d := newTestBinding(&mockSender{statusCode: http.StatusNoContent})
+		_, err := d.Invoke(&bindings.InvokeRequest{Operation: bindings.DeleteOperation, Metadata: map[string]string{}})
+		assert.Error(t, err)
+	})
+}
+
+func TestQueryTwins(t *testing.T) {
+	pages := []string{
+		`{"value": [{"$dtId": "twinA"}], "continuationToken": "next"}`,
+		`{"value": [{"$dtId": "twinB"}]}`,
+	}
+	query := []byte("SELECT * FROM digitaltwins")
+
+	t.Run("all pages", func(t *testing.T) {
+		sender := &mockSender{pages: append([]string{}, pages...)}
+		d := newTestBinding(sender)
+		res, err := d.Invoke(&bindings.InvokeRequest{Operation: "query", Data: query, Metadata: map[string]string{}})
+		assert.NoError(t, err)
+		assert.JSONEq(t, `{"value": [{"$dtId": "twinA"}, {"$dtId": "twinB"}]}`, string(res.Data))
+		assert.Len(t, sender.requests, 2)
+
+		b, _ := ioutil.ReadAll(sender.requests[1].Body)
+		assert.JSONEq(t, `{"continuationToken": "next"}`, string(b))
+	})
+
+	t.Run("max pages", func(t *testing.T) {
+		sender := &mockSender{pages: append([]string{}, pages...)}
+		d := newTestBinding(sender)
+		res, err := d.Invoke(&bindings.InvokeRequest{Operation: "query", Data: query, Metadata: map[string]string{"maxPages": "1"}})
+		assert.NoError(t, err)
+		assert.JSONEq(t, `{"value": [{"$dtId": "twinA"}], "continuationToken": "next"}`, string(res.Data))
+		assert.Equal(t, "next", res.Metadata["continuationToken"])
+
+		res, err = d.Invoke(&bindings.InvokeRequest{Operation: "query", Metadata: map[string]string{"continuationToken": "next"}})
+		assert.NoError(t, err)
+		assert.JSONEq(t, `{"value": [{"$dtId": "twinB"}]}`, string(res.Data))
+	})
+
+	t.Run("missing query", func(t *testing.T) {
+		d := newTestBinding(&mockSender{})
+		_, err := d.Invoke(&bindings.InvokeRequest{Operation: "query", Metadata: map[string]string{}})
+		assert.Error(t, err)
+	})
+}
+
+func TestUnsupportedOperation(t *testing.T) {
+	d := newTestBinding(&mockSender{})
+	_, err := d.Invoke(&bindings.InvokeRequest{Operation: bindings.ListOperation, Metadata: map[string]string{}})
+	assert.Error(t, err)
+}
+
+func TestRelationships(t *testing.T) {
+	metadata := map[string]string{"twinID": "twinA", "relationshipId": "rel1"}
+
+	t.Run("create", func(t *testing.T) {
+		sender := &mockSender{statusCode: http.StatusOK, body: `{"$relationshipId": "rel1", "$sourceId": "twinA", "$targetId": "twinB", "$relationshipName": "contains"}`}
+		d := newTestBinding(sender)
+		res, err := d.Invoke(&bindings.InvokeRequest{
+			Operation: "createRelationship",
+			Data:      []byte(`{"$targetId": "twinB", "$relationshipName": "contains"}`),
+			Metadata:  metadata,
+		})
+		assert.NoError(t, err)
+		assert.Equal(t, http.MethodPut, sender.requests[0].Method)
+		assert.Equal(t, "/digitaltwins/twinA/relationships/rel1", sender.requests[0].URL.Path)
+		assert.JSONEq(t, sender.body, string(res.Data))
+	})
+
+	t.Run("create existing with ifNoneMatch", func(t *testing.T) {
+		sender := &mockSender{statusCode: http.StatusPreconditionFailed}
+		d := newTestBinding(sender)
+		_, err := d.Invoke(&bindings.InvokeRequest{
+			Operation: "createRelationship",
+			Data:      []byte(`{"$targetId": "twinB", "$relationshipName": "contains"}`),
+			Metadata:  map[string]string{"twinID": "twinA", "relationshipId": "rel1", "ifNoneMatch": "*"},
+		})
+		assert.True(t, errors.Is(err, ErrPreconditionFailed))
+		assert.Equal(t, "*", sender.requests[0].Header.Get("If-None-Match"))
+	})
+
+	t.Run("update", func(t *testing.T) {
+		sender := &mockSender{statusCode: http.StatusNoContent}
+		d := newTestBinding(sender)
+		_, err := d.Invoke(&bindings.InvokeRequest{
+			Operation: "updateRelationship",
+			Data:      []byte(`[{"op": "replace", "path": "/since", "value": "2021"}]`),
+			Metadata:  metadata,
+		})
+		assert.NoError(t, err)
+		assert.Equal(t, http.MethodPatch, sender.requests[0].Method)
+		assert.Equal(t, "/digitaltwins/twinA/relationships/rel1", sender.requests[0].URL.Path)
+	})
+
+	t.Run("list", func(t *testing.T) {
+		sender := &mockSender{twin: `{"value": [{"$relationshipId": "rel1"}, {"$relationshipId": "rel2"}]}`}
+		d := newTestBinding(sender)
+		res, err := d.Invoke(&bindings.InvokeRequest{
+			Operation: "listRelationships",
+			Metadata:  map[string]string{"twinID": "twinA", "relationshipName": "contains"},
+		})
+		assert.NoError(t, err)
+		assert.JSONEq(t, `{"value": [{"$relationshipId": "rel1"}, {"$relationshipId": "rel2"}]}`, string(res.Data))
+		assert.Equal(t, "/digitaltwins/twinA/relationships", sender.requests[0].URL.Path)
+		assert.Equal(t, "contains", sender.requests[0].URL.Query().Get("relationshipName"))
+	})
+
+	t.Run("delete", func(t *testing.T) {
+		sender := &mockSender{statusCode: http.StatusNoContent}
+		d := newTestBinding(sender)
+		_, err := d.Invoke(&bindings.InvokeRequest{Operation: "deleteRelationship", Metadata: metadata})
+		assert.NoError(t, err)
+		assert.Equal(t, http.MethodDelete, sender.requests[0].Method)
+		assert.Equal(t, "/digitaltwins/twinA/relationships/rel1", sender.requests[0].URL.Path)
+	})
+
+	t.Run("missing relationshipId", func(t *testing.T) {
+		d := newTestBinding(&mockSender{statusCode: http.StatusNoContent})
+		for _, op := range []bindings.OperationKind{"createRelationship", "updateRelationship", "deleteRelationship"} {
+			_, err := d.Invoke(&bindings.InvokeRequest{Operation: op, Data: []byte(`[]`), Metadata: map[string]string{"twinID": "twinA"}})
+			assert.Error(t, err, op)
+		}
+	})
+}
+
+func TestTelemetry(t *testing.T) {
+	t.Run("twin telemetry", func(t *testing.T) {
+		sender := &mockSender{statusCode: http.StatusNoContent}
+		d := newTestBinding(sender)
+		res, err := d.Invoke(&bindings.InvokeRequest{
+			Operation: "telemetry",
+			Data:      []byte(`{"temperature": 20}`),
+			Metadata:  map[string]string{"twinID": "myTwin", "messageId": "m1", "telemetrySourceTime": "2021-01-01T10:00:00Z"},
+		})
+		assert.NoError(t, err)
+		assert.Equal(t, "m1", res.Metadata["messageId"])
+
+		req := sender.requests[0]
+		assert.Equal(t, http.MethodPost, req.Method)
+		assert.Equal(t, "/digitaltwins/myTwin/telemetry", req.URL.Path)
+		assert.Equal(t, "m1", req.Header.Get("Message-Id"))
+		assert.Equal(t, "2021-01-01T10:00:00Z", req.Header.Get("Telemetry-Source-Time"))
+		b, _ := ioutil.ReadAll(req.Body)
+		assert.JSONEq(t, `{"temperature": 20}`, string(b))
+	})
+
+	t.Run("component telemetry with generated message id", func(t *testing.T) {
+		sender := &mockSender{statusCode: http.StatusNoContent}
+		d := newTestBinding(sender)
+		res, err := d.Invoke(&bindings.InvokeRequest{
+			Operation: "telemetry",
+			Data:      []byte(`{"temperature": 20}`),
+			Metadata:  map[string]string{"twinID": "myTwin", "componentPath": "thermostat"},
+		})
+		assert.NoError(t, err)
+		assert.NotEmpty(t, res.Metadata["messageId"])
+		assert.Equal(t, res.Metadata["messageId"], sender.requests[0].Header.Get("Message-Id"))
+		assert.Equal(t, "/digitaltwins/myTwin/components/thermostat/telemetry", sender.requests[0].URL.Path)
+	})
+
+	t.Run("invalid payload", func(t *testing.T) {
+		d := newTestBinding(&mockSender{statusCode: http.StatusNoContent})
+		_, err := d.Invoke(&bindings.InvokeRequest{Operation: "telemetry", Data: []byte(`{`), Metadata: map[string]string{"twinID": "myTwin"}})
+		assert.Error(t, err)
+	})
+}
+
+func TestComponents(t *testing.T) {
+	metadata := map[string]string{"twinID": "myTwin", "componentPath": "thermostat"}
+
+	t.Run("get", func(t *testing.T) {
+		sender := &mockSender{twin: `{"targetTemperature": 21, "$metadata": {}}`}
+		d := newTestBinding(sender)
+		res, err := d.Invoke(&bindings.InvokeRequest{Operation: "getComponent", Metadata: metadata})
+		assert.NoError(t, err)
+		assert.JSONEq(t, `{"targetTemperature": 21, "$metadata": {}}`, string(res.Data))
+		assert.Equal(t, "/digitaltwins/myTwin/components/thermostat", sender.requests[0].URL.Path)
+		assert.Equal(t, `W/"1"`, res.Metadata["etag"])
+	})
+
+	t.Run("update", func(t *testing.T) {
+		sender := &mockSender{statusCode: http.StatusNoContent, etag: `W/"2"`}
+		d := newTestBinding(sender)
+		res, err := d.Invoke(&bindings.InvokeRequest{
+			Operation: "updateComponent",
+			Data:      []byte(`[{"op": "replace", "path": "/targetTemperature", "value": 22}]`),
+			Metadata:  map[string]string{"twinID": "myTwin", "componentPath": "thermostat", "ifMatch": `W/"1"`},
+		})
+		assert.NoError(t, err)
+		assert.Equal(t, `W/"2"`, res.Metadata["etag"])
+
+		req := sender.requests[0]
+		assert.Equal(t, http.MethodPatch, req.Method)
+		assert.Equal(t, "/digitaltwins/myTwin/components/thermostat", req.URL.Path)
+		assert.Equal(t, `W/"1"`, req.Header.Get("If-Match"))
+		b, _ := ioutil.ReadAll(req.Body)
+		assert.JSONEq(t, `[{"op": "replace", "path": "/targetTemperature", "value": 22}]`, string(b))
+	})
+
+	t.Run("merge patch", func(t *testing.T) {
+		sender := &mockSender{statusCode: http.StatusNoContent}
+		d := newTestBinding(sender)
+		_, err := d.Invoke(&bindings.InvokeRequest{
+			Operation: "updateComponent",
+			Data:      []byte(`[{"targetTemperature": 22}]`),
+			Metadata:  map[string]string{"twinID": "myTwin", "componentPath": "thermostat", "patchFormat": "mergePatch"},
+		})
+		assert.NoError(t, err)
+		b, _ := ioutil.ReadAll(sender.requests[0].Body)
+		assert.JSONEq(t, `[{"op": "add", "path": "/targetTemperature", "value": 22}]`, string(b))
+	})
+
+	t.Run("precondition failed", func(t *testing.T) {
+		d := newTestBinding(&mockSender{statusCode: http.StatusPreconditionFailed})
+		_, err := d.Invoke(&bindings.InvokeRequest{
+			Operation: "updateComponent",
+			Data:      []byte(`[{"op": "replace", "path": "/targetTemperature", "value": 22}]`),
+			Metadata:  map[string]string{"twinID": "myTwin", "componentPath": "thermostat", "ifMatch": `W/"1"`},
+		})
+		assert.True(t, errors.Is(err, ErrPreconditionFailed))
+	})
+
+	t.Run("missing componentPath", func(t *testing.T) {
+		d := newTestBinding(&mockSender{statusCode: http.StatusNoContent})
+		for _, op := range []bindings.OperationKind{"getComponent", "updateComponent"} {
+			_, err := d.Invoke(&bindings.InvokeRequest{Operation: op, Data: []byte(`[]`), Metadata: map[string]string{"twinID": "myTwin"}})
+			assert.Error(t, err, op)
+		}
+	})
+}
+
+func TestUpsert(t *testing.T) {
+	twin := `{"$dtId": "myTwin", "$metadata": {"$model": "dtmi:example:Sensor;1"}, "temperature": 20}`
+
+	t.Run("create or replace", func(t *testing.T) {
+		sender := &mockSender{statusCode: http.StatusOK, body: twin, etag: `W/"1"`}
+		d := newTestBinding(sender)
+		res, err := d.Invoke(&bindings.InvokeRequest{Operation: "upsert", Data: []byte(twin), Metadata: map[string]string{}})
+		assert.NoError(t, err)
+		assert.JSONEq(t, twin, string(res.Data))
+		assert.Equal(t, `W/"1"`, res.Metadata["etag"])
+
+		req := sender.requests[0]
+		assert.Equal(t, http.MethodPut, req.Method)
+		assert.Equal(t, "/digitaltwins/myTwin", req.URL.Path)
+		assert.Empty(t, req.Header.Get("If-None-Match"))
+		b, _ := ioutil.ReadAll(req.Body)
+		assert.JSONEq(t, twin, string(b))
+	})
+
+	t.Run("twinID metadata", func(t *testing.T) {
+		sender := &mockSender{statusCode: http.StatusOK, body: twin}
+		d := newTestBinding(sender)
+		_, err := d.Invoke(&bindings.InvokeRequest{
+			Operation: "upsert",
+			Data:      []byte(`{"$metadata": {"$model": "dtmi:example:Sensor;1"}}`),
+			Metadata:  map[string]string{"twinID": "otherTwin"},
+		})
+		assert.NoError(t, err)
+		assert.Equal(t, "/digitaltwins/otherTwin", sender.requests[0].URL.Path)
+	})
+
+	t.Run("create only", func(t *testing.T) {
+		sender := &mockSender{statusCode: http.StatusPreconditionFailed}
+		d := newTestBinding(sender)
+		_, err := d.Invoke(&bindings.InvokeRequest{Operation: "upsert", Data: []byte(twin), Metadata: map[string]string{"ifNoneMatch": "*"}})
+		assert.True(t, errors.Is(err, ErrPreconditionFailed))
+		assert.Equal(t, "*", sender.requests[0].Header.Get("If-None-Match"))
+	})
+
+	t.Run("invalid twin documents", func(t *testing.T) {
+		sender := &mockSender{statusCode: http.StatusOK}
+		d := newTestBinding(sender)
+		for _, doc := range []string{`[]`, `null`, `{"$metadata": {"$model": "dtmi:example:Sensor;1"}}`, `{"$dtId": "myTwin"}`} {
+			_, err := d.Invoke(&bindings.InvokeRequest{Operation: "upsert", Data: []byte(doc), Metadata: map[string]string{}})
+			assert.Error(t, err, doc)
+		}
+		assert.Empty(t, sender.requests)
+	})
+}
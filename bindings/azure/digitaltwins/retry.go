@@ -0,0 +1,113 @@
+// ------------------------------------------------------------
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT License.
+// ------------------------------------------------------------
+
+package digitaltwins
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/Azure/go-autorest/autorest"
+)
+
+const (
+	// default retry policy, see maxRetries, initialBackoff and maxBackoff
+	defaultMaxRetries     = 3
+	defaultInitialBackoff = 500 * time.Millisecond
+	defaultMaxBackoff     = 30 * time.Second
+)
+
+// retryPolicy retries the requests Azure Digital Twins throttles or is unable to serve
+type retryPolicy struct {
+	maxRetries     int
+	initialBackoff time.Duration
+	maxBackoff     time.Duration
+}
+
+func newRetryPolicy() retryPolicy {
+	return retryPolicy{
+		maxRetries:     defaultMaxRetries,
+		initialBackoff: defaultInitialBackoff,
+		maxBackoff:     defaultMaxBackoff,
+	}
+}
+
+// retryable returns true for the throttled (429) and service unavailable (503) responses
+func retryable(res *http.Response) bool {
+	return res.StatusCode == http.StatusTooManyRequests || res.StatusCode == http.StatusServiceUnavailable
+}
+
+// backoff returns the delay before the retry following the attempt: the Retry-After of the response when set,
+// otherwise the initial backoff doubled on each attempt, both capped at the max backoff
+func (p retryPolicy) backoff(attempt int, res *http.Response) time.Duration {
+	delay, ok := retryAfter(res)
+	if !ok {
+		delay = p.initialBackoff
+		for i := 0; i < attempt && delay < p.maxBackoff; i++ {
+			delay *= 2
+		}
+	}
+
+	if delay > p.maxBackoff {
+		return p.maxBackoff
+	}
+
+	return delay
+}
+
+// retryAfter returns the delay of the Retry-After header, in seconds or an HTTP date
+func retryAfter(res *http.Response) (time.Duration, bool) {
+	val := res.Header.Get("Retry-After")
+	if val == "" {
+		return 0, false
+	}
+
+	if seconds, err := strconv.Atoi(val); err == nil && seconds >= 0 {
+		return time.Duration(seconds) * time.Second, true
+	}
+
+	if date, err := http.ParseTime(val); err == nil {
+		delay := time.Until(date)
+		if delay < 0 {
+			delay = 0
+		}
+
+		return delay, true
+	}
+
+	return 0, false
+}
+
+// sendDecorator returns the decorator of the REST client sender applying the policy,
+// it replaces the default retries of the generated client
+func (p retryPolicy) sendDecorator() autorest.SendDecorator {
+	return func(s autorest.Sender) autorest.Sender {
+		return autorest.SenderFunc(func(r *http.Request) (*http.Response, error) {
+			// the body of the request is buffered so that it can be sent again
+			rr := autorest.NewRetriableRequest(r)
+			for attempt := 0; ; attempt++ {
+				err := rr.Prepare()
+				if err != nil {
+					return nil, err
+				}
+
+				res, err := s.Do(rr.Request())
+				if err != nil || !retryable(res) || attempt >= p.maxRetries {
+					return res, err
+				}
+
+				delay := p.backoff(attempt, res)
+				_ = autorest.Respond(res, autorest.ByDiscardingBody(), autorest.ByClosing())
+
+				select {
+				case <-time.After(delay):
+				case <-r.Context().Done():
+					return nil, r.Context().Err()
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,127 @@
+// ------------------------------------------------------------
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT License.
+// ------------------------------------------------------------
+
+package digitaltwins
+
+import (
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/dapr/components-contrib/bindings"
+	"github.com/dapr/dapr/pkg/logger"
+	"github.com/stretchr/testify/assert"
+)
+
+// sequenceSender returns the responses in order, repeating the last one
+type sequenceSender struct {
+	responses []*http.Response
+	bodies    []string
+}
+
+func (s *sequenceSender) Do(req *http.Request) (*http.Response, error) {
+	b, _ := ioutil.ReadAll(req.Body)
+	s.bodies = append(s.bodies, string(b))
+
+	res := s.responses[0]
+	if len(s.responses) > 1 {
+		s.responses = s.responses[1:]
+	}
+
+	return &http.Response{
+		StatusCode: res.StatusCode,
+		Header:     res.Header,
+		Body:       ioutil.NopCloser(strings.NewReader("")),
+		Request:    req,
+	}, nil
+}
+
+func status(code int, header http.Header) *http.Response {
+	return &http.Response{StatusCode: code, Header: header}
+}
+
+func TestRetryPolicy(t *testing.T) {
+	patch := []byte(`[{"op": "replace", "path": "/temperature", "value": 20}]`)
+	policy := retryPolicy{maxRetries: 3, initialBackoff: time.Millisecond, maxBackoff: 5 * time.Millisecond}
+
+	t.Run("throttled requests are retried", func(t *testing.T) {
+		sender := &sequenceSender{responses: []*http.Response{
+			status(http.StatusTooManyRequests, nil),
+			status(http.StatusServiceUnavailable, nil),
+			status(http.StatusNoContent, nil),
+		}}
+		d := newTestBinding(sender)
+		d.retry = policy
+		_, err := d.Invoke(&bindings.InvokeRequest{Data: patch, Metadata: map[string]string{"twinID": "myTwin"}})
+		assert.NoError(t, err)
+		assert.Len(t, sender.bodies, 3)
+		// the body is sent again on each attempt
+		assert.Equal(t, sender.bodies[0], sender.bodies[2])
+		assert.Contains(t, sender.bodies[2], "/temperature")
+	})
+
+	t.Run("gives up after maxRetries", func(t *testing.T) {
+		sender := &sequenceSender{responses: []*http.Response{status(http.StatusTooManyRequests, nil)}}
+		d := newTestBinding(sender)
+		d.retry = policy
+		_, err := d.Invoke(&bindings.InvokeRequest{Data: patch, Metadata: map[string]string{"twinID": "myTwin"}})
+		assert.Error(t, err)
+		assert.Len(t, sender.bodies, 4)
+	})
+
+	t.Run("other failures are not retried", func(t *testing.T) {
+		sender := &sequenceSender{responses: []*http.Response{status(http.StatusInternalServerError, nil)}}
+		d := newTestBinding(sender)
+		d.retry = policy
+		_, err := d.Invoke(&bindings.InvokeRequest{Data: patch, Metadata: map[string]string{"twinID": "myTwin"}})
+		assert.Error(t, err)
+		assert.Len(t, sender.bodies, 1)
+	})
+
+	t.Run("exponential backoff", func(t *testing.T) {
+		p := retryPolicy{initialBackoff: 100 * time.Millisecond, maxBackoff: time.Second}
+		res := status(http.StatusTooManyRequests, http.Header{})
+		assert.Equal(t, 100*time.Millisecond, p.backoff(0, res))
+		assert.Equal(t, 200*time.Millisecond, p.backoff(1, res))
+		assert.Equal(t, 800*time.Millisecond, p.backoff(3, res))
+		assert.Equal(t, time.Second, p.backoff(4, res))
+		assert.Equal(t, time.Second, p.backoff(100, res))
+	})
+
+	t.Run("retry-after", func(t *testing.T) {
+		p := retryPolicy{initialBackoff: 100 * time.Millisecond, maxBackoff: 10 * time.Second}
+		assert.Equal(t, 2*time.Second, p.backoff(0, status(http.StatusTooManyRequests, http.Header{"Retry-After": []string{"2"}})))
+		assert.Equal(t, 10*time.Second, p.backoff(0, status(http.StatusTooManyRequests, http.Header{"Retry-After": []string{"60"}})))
+
+		date := time.Now().Add(5 * time.Second).UTC().Format(http.TimeFormat)
+		delay := p.backoff(0, status(http.StatusServiceUnavailable, http.Header{"Retry-After": []string{date}}))
+		assert.True(t, delay > 3*time.Second && delay <= 5*time.Second, delay)
+
+		assert.Equal(t, 100*time.Millisecond, p.backoff(0, status(http.StatusTooManyRequests, http.Header{"Retry-After": []string{"soon"}})))
+	})
+
+	t.Run("metadata", func(t *testing.T) {
+		d := NewAzureDigitalTwins(logger.NewLogger("test"))
+		m := bindings.Metadata{Properties: map[string]string{"adtInstanceUrl": "https://adt.example"}}
+		meta, err := d.getAzureDigitalTwinsMetadata(m)
+		assert.NoError(t, err)
+		assert.Equal(t, newRetryPolicy(), meta.retry)
+
+		m.Properties["maxRetries"] = "5"
+		m.Properties["initialBackoff"] = "100ms"
+		m.Properties["maxBackoff"] = "10s"
+		meta, err = d.getAzureDigitalTwinsMetadata(m)
+		assert.NoError(t, err)
+		assert.Equal(t, retryPolicy{maxRetries: 5, initialBackoff: 100 * time.Millisecond, maxBackoff: 10 * time.Second}, meta.retry)
+
+		for k, v := range map[string]string{"maxRetries": "-1", "initialBackoff": "fast", "maxBackoff": "10ms"} {
+			m := bindings.Metadata{Properties: map[string]string{"adtInstanceUrl": "https://adt.example", "initialBackoff": "100ms", k: v}}
+			_, err = d.getAzureDigitalTwinsMetadata(m)
+			assert.Error(t, err, k)
+		}
+	})
+}
@@ -17,6 +17,18 @@ import (
 const (
 	// TTLMetadataKey defines the metadata key for setting a time to live (in seconds)
 	TTLMetadataKey = "ttlInSeconds"
+
+	// RawPayloadMetadataKey defines the metadata key for publishing and delivering pubsub payloads as is, without cloud events envelope
+	RawPayloadMetadataKey = "rawPayload"
+
+	// PriorityMetadataKey defines the metadata key for setting the priority of a message, from 0 (lowest) to 255
+	PriorityMetadataKey = "priority"
+
+	// DeliverAtMetadataKey defines the metadata key for scheduling the delivery of a message at a given time (RFC3339)
+	DeliverAtMetadataKey = "deliverAt"
+
+	// DelaySecondsMetadataKey defines the metadata key for delaying the delivery of a message (in seconds)
+	DelaySecondsMetadataKey = "delaySeconds"
 )
 
 // TryGetTTL tries to get the ttl as a time.Duration value for pubsub, binding and any other building block.
@@ -42,3 +54,69 @@ func TryGetTTL(props map[string]string) (time.Duration, bool, error) {
 
 	return 0, false, nil
 }
+
+// IsRawPayload determines if the pubsub payload is used as is, rather than wrapped in a cloud events envelope.
+func IsRawPayload(props map[string]string) (bool, error) {
+	if val, ok := props[RawPayloadMetadataKey]; ok && val != "" {
+		boolVal, err := strconv.ParseBool(val)
+		if err != nil {
+			return false, errors.Wrapf(err, "%s value must be a valid boolean: actual is '%s'", RawPayloadMetadataKey, val)
+		}
+
+		return boolVal, nil
+	}
+
+	return false, nil
+}
+
+// TryGetPriority tries to get the priority of a message for pubsub and any other building block.
+func TryGetPriority(props map[string]string) (uint8, bool, error) {
+	if val, ok := props[PriorityMetadataKey]; ok && val != "" {
+		intVal, err := strconv.Atoi(val)
+		if err != nil {
+			return 0, false, errors.Wrapf(err, "%s value must be a valid integer: actual is '%s'", PriorityMetadataKey, val)
+		}
+
+		if intVal < 0 || intVal > math.MaxUint8 {
+			return 0, false, fmt.Errorf("%s value must be between 0 and %d: actual is %d", PriorityMetadataKey, math.MaxUint8, intVal)
+		}
+
+		return uint8(intVal), true, nil
+	}
+
+	return 0, false, nil
+}
+
+// TryGetDeliverAt tries to get the time a message is to be delivered at, set either as a time with deliverAt
+// or relative to now with delaySeconds.
+func TryGetDeliverAt(props map[string]string) (time.Time, bool, error) {
+	deliverAt, hasDeliverAt := props[DeliverAtMetadataKey]
+	delaySeconds, hasDelaySeconds := props[DelaySecondsMetadataKey]
+	hasDeliverAt = hasDeliverAt && deliverAt != ""
+	hasDelaySeconds = hasDelaySeconds && delaySeconds != ""
+
+	switch {
+	case hasDeliverAt && hasDelaySeconds:
+		return time.Time{}, false, fmt.Errorf("%s and %s cannot be both set", DeliverAtMetadataKey, DelaySecondsMetadataKey)
+	case hasDeliverAt:
+		t, err := time.Parse(time.RFC3339, deliverAt)
+		if err != nil {
+			return time.Time{}, false, errors.Wrapf(err, "%s value must be a valid RFC3339 time: actual is '%s'", DeliverAtMetadataKey, deliverAt)
+		}
+
+		return t, true, nil
+	case hasDelaySeconds:
+		valInt64, err := strconv.ParseInt(delaySeconds, 10, 64)
+		if err != nil {
+			return time.Time{}, false, errors.Wrapf(err, "%s value must be a valid integer: actual is '%s'", DelaySecondsMetadataKey, delaySeconds)
+		}
+
+		if valInt64 < 0 || valInt64 > math.MaxInt64/int64(time.Second) {
+			return time.Time{}, false, fmt.Errorf("%s value must be a positive number of seconds: actual is %d", DelaySecondsMetadataKey, valInt64)
+		}
+
+		return time.Now().Add(time.Duration(valInt64) * time.Second), true, nil
+	}
+
+	return time.Time{}, false, nil
+}
@@ -0,0 +1,123 @@
+// ------------------------------------------------------------
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT License.
+// ------------------------------------------------------------
+
+package metadata
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTryGetTTL(t *testing.T) {
+	t.Run("ttl is set", func(t *testing.T) {
+		ttl, ok, err := TryGetTTL(map[string]string{TTLMetadataKey: "10"})
+		assert.NoError(t, err)
+		assert.True(t, ok)
+		assert.Equal(t, 10*time.Second, ttl)
+	})
+
+	t.Run("ttl is not set", func(t *testing.T) {
+		_, ok, err := TryGetTTL(map[string]string{})
+		assert.NoError(t, err)
+		assert.False(t, ok)
+	})
+
+	t.Run("invalid ttl", func(t *testing.T) {
+		_, _, err := TryGetTTL(map[string]string{TTLMetadataKey: "a"})
+		assert.Error(t, err)
+
+		_, _, err = TryGetTTL(map[string]string{TTLMetadataKey: "0"})
+		assert.Error(t, err)
+	})
+}
+
+func TestIsRawPayload(t *testing.T) {
+	t.Run("raw payload is set", func(t *testing.T) {
+		raw, err := IsRawPayload(map[string]string{RawPayloadMetadataKey: "true"})
+		assert.NoError(t, err)
+		assert.True(t, raw)
+
+		raw, err = IsRawPayload(map[string]string{RawPayloadMetadataKey: "false"})
+		assert.NoError(t, err)
+		assert.False(t, raw)
+	})
+
+	t.Run("raw payload is not set", func(t *testing.T) {
+		raw, err := IsRawPayload(map[string]string{})
+		assert.NoError(t, err)
+		assert.False(t, raw)
+	})
+
+	t.Run("invalid raw payload", func(t *testing.T) {
+		_, err := IsRawPayload(map[string]string{RawPayloadMetadataKey: "a"})
+		assert.Error(t, err)
+	})
+}
+
+func TestTryGetPriority(t *testing.T) {
+	t.Run("priority is set", func(t *testing.T) {
+		priority, ok, err := TryGetPriority(map[string]string{PriorityMetadataKey: "5"})
+		assert.NoError(t, err)
+		assert.True(t, ok)
+		assert.Equal(t, uint8(5), priority)
+	})
+
+	t.Run("priority is not set", func(t *testing.T) {
+		_, ok, err := TryGetPriority(map[string]string{})
+		assert.NoError(t, err)
+		assert.False(t, ok)
+	})
+
+	t.Run("invalid priority", func(t *testing.T) {
+		_, _, err := TryGetPriority(map[string]string{PriorityMetadataKey: "high"})
+		assert.Error(t, err)
+
+		_, _, err = TryGetPriority(map[string]string{PriorityMetadataKey: "256"})
+		assert.Error(t, err)
+
+		_, _, err = TryGetPriority(map[string]string{PriorityMetadataKey: "-1"})
+		assert.Error(t, err)
+	})
+}
+
+func TestTryGetDeliverAt(t *testing.T) {
+	t.Run("deliverAt is set", func(t *testing.T) {
+		deliverAt, ok, err := TryGetDeliverAt(map[string]string{DeliverAtMetadataKey: "2021-01-02T15:04:05Z"})
+		assert.NoError(t, err)
+		assert.True(t, ok)
+		assert.Equal(t, time.Date(2021, 1, 2, 15, 4, 5, 0, time.UTC), deliverAt)
+	})
+
+	t.Run("delaySeconds is set", func(t *testing.T) {
+		before := time.Now()
+		deliverAt, ok, err := TryGetDeliverAt(map[string]string{DelaySecondsMetadataKey: "60"})
+		assert.NoError(t, err)
+		assert.True(t, ok)
+		assert.False(t, deliverAt.Before(before.Add(time.Minute)))
+		assert.False(t, deliverAt.After(time.Now().Add(time.Minute)))
+	})
+
+	t.Run("delivery time is not set", func(t *testing.T) {
+		_, ok, err := TryGetDeliverAt(map[string]string{})
+		assert.NoError(t, err)
+		assert.False(t, ok)
+	})
+
+	t.Run("invalid delivery time", func(t *testing.T) {
+		_, _, err := TryGetDeliverAt(map[string]string{DeliverAtMetadataKey: "tomorrow"})
+		assert.Error(t, err)
+
+		_, _, err = TryGetDeliverAt(map[string]string{DelaySecondsMetadataKey: "a"})
+		assert.Error(t, err)
+
+		_, _, err = TryGetDeliverAt(map[string]string{DelaySecondsMetadataKey: "-1"})
+		assert.Error(t, err)
+
+		_, _, err = TryGetDeliverAt(map[string]string{DeliverAtMetadataKey: "2021-01-02T15:04:05Z", DelaySecondsMetadataKey: "60"})
+		assert.Error(t, err)
+	})
+}
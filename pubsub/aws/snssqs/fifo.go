@@ -0,0 +1,97 @@
+package snssqs
+
+import (
+	"io/ioutil"
+	"net/url"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/request"
+	sqs "github.com/aws/aws-sdk-go/service/sqs"
+	"github.com/dapr/components-contrib/pubsub"
+)
+
+// the deduplication ID of FIFO messages is at most 128 characters long
+const maxDeduplicationIDLength = 128
+
+// messageGroupID returns the message group of a message published to a FIFO topic:
+// the partitionKey metadata, or else the fifoMessageGroupID metadata, or else the topic.
+func (s *snsSqs) messageGroupID(req *pubsub.PublishRequest) string {
+	if key := req.Metadata[pubsub.PartitionKeyMetadataKey]; key != "" {
+		return key
+	}
+	if s.metadata.fifoMessageGroupID != "" {
+		return s.metadata.fifoMessageGroupID
+	}
+
+	return req.Topic
+}
+
+// deduplicationID returns the deduplication ID of a message published to a FIFO topic, which is the ID of its cloud event.
+// Messages that are not cloud events have none, they are deduplicated by their content.
+func deduplicationID(data []byte) string {
+	e, err := pubsub.ParseCloudEvent(data, "", "")
+	if err != nil || e.ID == "" {
+		return ""
+	}
+	if len(e.ID) > maxDeduplicationIDLength {
+		return nameToHash(e.ID)
+	}
+
+	return e.ID
+}
+
+// withFifoParameters sets the message group and deduplication IDs of a publish request to a FIFO topic.
+// The SNS API of aws-sdk-go v1.25.0 predates FIFO topics, so they are added to the query parameters once the request is built.
+func withFifoParameters(groupID, deduplicationID string) request.Option {
+	return func(r *request.Request) {
+		r.Handlers.Build.PushBack(func(r *request.Request) {
+			if r.Error != nil {
+				return
+			}
+
+			body, err := ioutil.ReadAll(r.Body)
+			if err != nil {
+				r.Error = err
+
+				return
+			}
+			params, err := url.ParseQuery(string(body))
+			if err != nil {
+				r.Error = err
+
+				return
+			}
+
+			params.Set("MessageGroupId", groupID)
+			if deduplicationID != "" {
+				params.Set("MessageDeduplicationId", deduplicationID)
+			}
+			r.SetBufferBody([]byte(params.Encode()))
+		})
+	}
+}
+
+// messageGroups tracks the message groups of the received messages that failed,
+// the next messages of such a group are left in the queue so that they are delivered again in order
+type messageGroups map[string]struct{}
+
+func newMessageGroups() messageGroups {
+	return messageGroups{}
+}
+
+func (g messageGroups) fail(m *sqs.Message) {
+	if id, ok := m.Attributes[sqs.MessageSystemAttributeNameMessageGroupId]; ok {
+		g[aws.StringValue(id)] = struct{}{}
+	}
+}
+
+// failed tells whether a previous message of the group of the message failed, messages of standard queues have no group
+func (g messageGroups) failed(m *sqs.Message) bool {
+	id, ok := m.Attributes[sqs.MessageSystemAttributeNameMessageGroupId]
+	if !ok {
+		return false
+	}
+	_, failed := g[aws.StringValue(id)]
+
+	return failed
+}
@@ -9,6 +9,7 @@ import (
 	"strings"
 
 	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/request"
 	sns "github.com/aws/aws-sdk-go/service/sns"
 	sqs "github.com/aws/aws-sdk-go/service/sqs"
 	aws_auth "github.com/dapr/components-contrib/authentication/aws"
@@ -58,11 +59,20 @@ type snsSqsMetadata struct {
 	messageWaitTimeSeconds int64
 	// maximum number of messages to receive from the queue at a time. Default: 10, Maximum: 10
 	messageMaxNumber int64
+	// use FIFO topics and queues, which deliver the messages of a message group in order and deduplicate them. Default: false
+	fifo bool
+	// message group of the messages published without partitionKey metadata. Default: the topic name
+	fifoMessageGroupID string
+	// use the existing topics, queues and subscriptions instead of creating them. Default: false
+	disableEntityManagement bool
 }
 
 const (
 	awsSqsQueueNameKey = "dapr-queue-name"
 	awsSnsTopicNameKey = "dapr-topic-name"
+
+	// the names of FIFO topics and queues end with .fifo
+	fifoSuffix = ".fifo"
 )
 
 func NewSnsSqs(l logger.Logger) pubsub.PubSub {
@@ -92,6 +102,15 @@ func parseInt64(input string, propertyName string) (int64, error) {
 	return int64(number), nil
 }
 
+func parseBool(input string, propertyName string) (bool, error) {
+	val, err := strconv.ParseBool(input)
+	if err != nil {
+		return false, fmt.Errorf("parsing %s failed with: %v", propertyName, err)
+	}
+
+	return val, nil
+}
+
 // take a name and hash it for compatibility with AWS resource names
 // the output is fixed at 64 characters
 func nameToHash(name string) string {
@@ -192,6 +211,28 @@ func (s *snsSqs) getSnsSqsMetatdata(metadata pubsub.Metadata) (*snsSqsMetadata,
 		md.messageMaxNumber = maxNumber
 	}
 
+	if val, ok := props["fifo"]; ok && val != "" {
+		fifo, err := parseBool(val, "fifo")
+		if err != nil {
+			return nil, err
+		}
+
+		md.fifo = fifo
+	}
+
+	if val, ok := props["fifoMessageGroupID"]; ok {
+		md.fifoMessageGroupID = val
+	}
+
+	if val, ok := props["disableEntityManagement"]; ok && val != "" {
+		disable, err := parseBool(val, "disableEntityManagement")
+		if err != nil {
+			return nil, err
+		}
+
+		md.disableEntityManagement = disable
+	}
+
 	return &md, nil
 }
 
@@ -218,12 +259,30 @@ func (s *snsSqs) Init(metadata pubsub.Metadata) error {
 	return nil
 }
 
+// entityName returns the AWS name of a topic or queue
+func (s *snsSqs) entityName(name string) string {
+	if s.metadata.fifo {
+		return nameToHash(name) + fifoSuffix
+	}
+
+	return nameToHash(name)
+}
+
 func (s *snsSqs) createTopic(topic string) (string, string, error) {
-	hashedName := nameToHash(topic)
-	createTopicResponse, err := s.snsClient.CreateTopic(&sns.CreateTopicInput{
+	hashedName := s.entityName(topic)
+	input := &sns.CreateTopicInput{
 		Name: aws.String(hashedName),
 		Tags: []*sns.Tag{{Key: aws.String(awsSnsTopicNameKey), Value: aws.String(topic)}},
-	})
+	}
+	if s.metadata.fifo {
+		// messages published without deduplication ID, which are not cloud events, are deduplicated by their content
+		input.Attributes = map[string]*string{
+			"FifoTopic":                 aws.String("true"),
+			"ContentBasedDeduplication": aws.String("true"),
+		}
+	}
+
+	createTopicResponse, err := s.snsClient.CreateTopic(input)
 	if err != nil {
 		return "", "", err
 	}
@@ -231,6 +290,32 @@ func (s *snsSqs) createTopic(topic string) (string, string, error) {
 	return *(createTopicResponse.TopicArn), hashedName, nil
 }
 
+// findTopic returns the ARN of an existing topic, which is looked up among the topics of the account
+func (s *snsSqs) findTopic(topic string) (string, string, error) {
+	hashedName := s.entityName(topic)
+	var topicArn string
+	err := s.snsClient.ListTopicsPages(&sns.ListTopicsInput{}, func(page *sns.ListTopicsOutput, lastPage bool) bool {
+		for _, t := range page.Topics {
+			if parseTopicArn(aws.StringValue(t.TopicArn)) == hashedName {
+				topicArn = aws.StringValue(t.TopicArn)
+
+				return false
+			}
+		}
+
+		return true
+	})
+	if err != nil {
+		return "", "", err
+	}
+
+	if topicArn == "" {
+		return "", "", fmt.Errorf("topic %s does not exist and entity management is disabled", topic)
+	}
+
+	return topicArn, hashedName, nil
+}
+
 // get the topic ARN from the topics map. If it doesn't exist in the map, try to fetch it from AWS, if it doesn't exist
 // at all, issue a request to create the topic.
 func (s *snsSqs) getOrCreateTopic(topic string) (string, error) {
@@ -242,9 +327,17 @@ func (s *snsSqs) getOrCreateTopic(topic string) (string, error) {
 		return topicArn, nil
 	}
 
-	s.logger.Debugf("No topic ARN found for %s\n Creating topic instead.", topic)
+	var hashedName string
+	var err error
+	if s.metadata.disableEntityManagement {
+		s.logger.Debugf("No topic ARN found for %s\n Looking up topic instead.", topic)
+
+		topicArn, hashedName, err = s.findTopic(topic)
+	} else {
+		s.logger.Debugf("No topic ARN found for %s\n Creating topic instead.", topic)
 
-	topicArn, hashedName, err := s.createTopic(topic)
+		topicArn, hashedName, err = s.createTopic(topic)
+	}
 	if err != nil {
 		s.logger.Errorf("error creating new topic %s: %v", topic, err)
 
@@ -259,10 +352,16 @@ func (s *snsSqs) getOrCreateTopic(topic string) (string, error) {
 }
 
 func (s *snsSqs) createQueue(queueName string) (*sqsQueueInfo, error) {
-	createQueueResponse, err := s.sqsClient.CreateQueue(&sqs.CreateQueueInput{
-		QueueName: aws.String(nameToHash(queueName)),
+	input := &sqs.CreateQueueInput{
+		QueueName: aws.String(s.entityName(queueName)),
 		Tags:      map[string]*string{awsSqsQueueNameKey: aws.String(queueName)},
-	})
+	}
+	if s.metadata.fifo {
+		// FIFO topics only deliver to FIFO queues
+		input.Attributes = map[string]*string{sqs.QueueAttributeNameFifoQueue: aws.String("true")}
+	}
+
+	createQueueResponse, err := s.sqsClient.CreateQueue(input)
 	if err != nil {
 		return nil, err
 	}
@@ -300,6 +399,29 @@ func (s *snsSqs) createQueue(queueName string) (*sqsQueueInfo, error) {
 	}, nil
 }
 
+// findQueue returns the ARN and the url of an existing queue
+func (s *snsSqs) findQueue(queueName string) (*sqsQueueInfo, error) {
+	queueURLResponse, err := s.sqsClient.GetQueueUrl(&sqs.GetQueueUrlInput{
+		QueueName: aws.String(s.entityName(queueName)),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	queueAttributesResponse, err := s.sqsClient.GetQueueAttributes(&sqs.GetQueueAttributesInput{
+		AttributeNames: []*string{aws.String("QueueArn")},
+		QueueUrl:       queueURLResponse.QueueUrl,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &sqsQueueInfo{
+		arn: aws.StringValue(queueAttributesResponse.Attributes["QueueArn"]),
+		url: aws.StringValue(queueURLResponse.QueueUrl),
+	}, nil
+}
+
 func (s *snsSqs) getOrCreateQueue(queueName string) (*sqsQueueInfo, error) {
 	queueArn, ok := s.queues[queueName]
 
@@ -308,10 +430,18 @@ func (s *snsSqs) getOrCreateQueue(queueName string) (*sqsQueueInfo, error) {
 
 		return queueArn, nil
 	}
-	// creating queues is idempotent, the names serve as unique keys among a given region
-	s.logger.Debugf("No queue arn found for %s\nCreating queue", queueName)
+	var queueInfo *sqsQueueInfo
+	var err error
+	if s.metadata.disableEntityManagement {
+		s.logger.Debugf("No queue arn found for %s\nLooking up queue", queueName)
 
-	queueInfo, err := s.createQueue(queueName)
+		queueInfo, err = s.findQueue(queueName)
+	} else {
+		// creating queues is idempotent, the names serve as unique keys among a given region
+		s.logger.Debugf("No queue arn found for %s\nCreating queue", queueName)
+
+		queueInfo, err = s.createQueue(queueName)
+	}
 	if err != nil {
 		s.logger.Errorf("Error creating queue %s: %v", queueName, err)
 
@@ -330,10 +460,14 @@ func (s *snsSqs) Publish(req *pubsub.PublishRequest) error {
 	}
 
 	message := string(req.Data)
-	_, err = s.snsClient.Publish(&sns.PublishInput{
+	var opts []request.Option
+	if s.metadata.fifo {
+		opts = append(opts, withFifoParameters(s.messageGroupID(req), deduplicationID(req.Data)))
+	}
+	_, err = s.snsClient.PublishWithContext(aws.BackgroundContext(), &sns.PublishInput{
 		Message:  &message,
 		TopicArn: &topicArn,
-	})
+	}, opts...)
 
 	if err != nil {
 		s.logger.Errorf("error publishing topic %s with topic ARN %s: %v", req.Topic, topicArn, err)
@@ -362,28 +496,55 @@ func (s *snsSqs) acknowledgeMessage(queueURL string, receiptHandle *string) erro
 	return err
 }
 
-func (s *snsSqs) handleMessage(message *sqs.Message, queueInfo *sqsQueueInfo, handler func(msg *pubsub.NewMessage) error) error {
+// acknowledgeMessages deletes the messages from the queue in a single call
+func (s *snsSqs) acknowledgeMessages(queueURL string, messages []*sqs.Message) error {
+	entries := make([]*sqs.DeleteMessageBatchRequestEntry, len(messages))
+	for i, m := range messages {
+		entries[i] = &sqs.DeleteMessageBatchRequestEntry{
+			Id:            aws.String(strconv.Itoa(i)),
+			ReceiptHandle: m.ReceiptHandle,
+		}
+	}
+
+	res, err := s.sqsClient.DeleteMessageBatch(&sqs.DeleteMessageBatchInput{
+		QueueUrl: &queueURL,
+		Entries:  entries,
+	})
+	if err != nil {
+		return err
+	}
+
+	if len(res.Failed) > 0 {
+		return fmt.Errorf("failed to delete %d message(s): %s", len(res.Failed), aws.StringValue(res.Failed[0].Message))
+	}
+
+	return nil
+}
+
+// parseMessage returns the pubsub message carried by an SQS message,
+// messages received more times than the retry limit are deleted from the queue instead
+func (s *snsSqs) parseMessage(message *sqs.Message, queueInfo *sqsQueueInfo) (*pubsub.NewMessage, error) {
 	// if this message has been received > x times, delete from queue, it's borked
 	recvCount, ok := message.Attributes[sqs.MessageSystemAttributeNameApproximateReceiveCount]
 
 	if !ok {
-		return fmt.Errorf(
+		return nil, fmt.Errorf(
 			"no ApproximateReceiveCount returned with response, will not attempt further processing: %v", message)
 	}
 
 	recvCountInt, err := strconv.ParseInt(*recvCount, 10, 32)
 	if err != nil {
-		return fmt.Errorf("error parsing ApproximateReceiveCount from message: %v", message)
+		return nil, fmt.Errorf("error parsing ApproximateReceiveCount from message: %v", message)
 	}
 
 	// if we are over the allowable retry limit, delete the message from the queue
 	// TODO dead letter queue
 	if recvCountInt >= s.metadata.messageRetryLimit {
 		if innerErr := s.acknowledgeMessage(queueInfo.url, message.ReceiptHandle); innerErr != nil {
-			return fmt.Errorf("error acknowledging message after receiving the message too many times: %v", innerErr)
+			return nil, fmt.Errorf("error acknowledging message after receiving the message too many times: %v", innerErr)
 		}
 
-		return fmt.Errorf(
+		return nil, fmt.Errorf(
 			"message received greater than %v times, deleting this message without further processing", s.metadata.messageRetryLimit)
 	}
 
@@ -392,16 +553,25 @@ func (s *snsSqs) handleMessage(message *sqs.Message, queueInfo *sqsQueueInfo, ha
 	err = json.Unmarshal([]byte(*(message.Body)), &messageBody)
 
 	if err != nil {
-		return fmt.Errorf("error unmarshalling message: %v", err)
+		return nil, fmt.Errorf("error unmarshalling message: %v", err)
 	}
 
 	topic := parseTopicArn(messageBody.TopicArn)
 	topic = s.topicHash[topic]
-	err = handler(&pubsub.NewMessage{
+
+	return &pubsub.NewMessage{
 		Data:  []byte(messageBody.Message),
 		Topic: topic,
-	})
+	}, nil
+}
 
+func (s *snsSqs) handleMessage(message *sqs.Message, queueInfo *sqsQueueInfo, handler func(msg *pubsub.NewMessage) error) error {
+	msg, err := s.parseMessage(message, queueInfo)
+	if err != nil {
+		return err
+	}
+
+	err = handler(msg)
 	if err != nil {
 		return fmt.Errorf("error handling message: %v", err)
 	}
@@ -410,53 +580,162 @@ func (s *snsSqs) handleMessage(message *sqs.Message, queueInfo *sqsQueueInfo, ha
 	return s.acknowledgeMessage(queueInfo.url, message.ReceiptHandle)
 }
 
-func (s *snsSqs) consumeSubscription(queueInfo *sqsQueueInfo, handler func(msg *pubsub.NewMessage) error) {
-	go func() {
-		for {
-			messageResponse, err := s.sqsClient.ReceiveMessage(&sqs.ReceiveMessageInput{
-				// use this property to decide when a message should be discarded
-				AttributeNames: []*string{
-					aws.String(sqs.MessageSystemAttributeNameApproximateReceiveCount),
-				},
-				MaxNumberOfMessages: aws.Int64(s.metadata.messageMaxNumber),
-				QueueUrl:            &queueInfo.url,
-				VisibilityTimeout:   aws.Int64(s.metadata.messageVisibilityTimeout),
-				WaitTimeSeconds:     aws.Int64(s.metadata.messageWaitTimeSeconds),
-			})
-			if err != nil {
-				s.logger.Errorf("error consuming topic: %v", err)
+// handleBulkMessages delivers the messages as one bulk message per topic,
+// the messages of a bulk message that did not fail are acknowledged together once handled
+func (s *snsSqs) handleBulkMessages(messages []*sqs.Message, queueInfo *sqsQueueInfo, handler func(msg *pubsub.BulkMessage) (pubsub.BulkSubscribeResponse, error)) {
+	var topics []string
+	bulkMessages := make(map[string]*pubsub.BulkMessage)
+	received := make(map[string][]*sqs.Message)
+	for _, m := range messages {
+		msg, err := s.parseMessage(m, queueInfo)
+		if err != nil {
+			s.logger.Error(err)
 
-				continue
-			}
+			continue
+		}
+
+		bulkMessage, ok := bulkMessages[msg.Topic]
+		if !ok {
+			bulkMessage = &pubsub.BulkMessage{Topic: msg.Topic}
+			bulkMessages[msg.Topic] = bulkMessage
+			topics = append(topics, msg.Topic)
+		}
+		bulkMessage.Entries = append(bulkMessage.Entries, pubsub.BulkMessageEntry{
+			EntryID: aws.StringValue(m.MessageId),
+			Event:   msg.Data,
+		})
+		received[msg.Topic] = append(received[msg.Topic], m)
+	}
+
+	for _, topic := range topics {
+		res, err := handler(bulkMessages[topic])
+		if err != nil {
+			s.logger.Errorf("error handling %d message(s) of topic %s: %v", len(received[topic]), topic, err)
+
+			continue
+		}
 
-			// retry receiving messages
-			if len(messageResponse.Messages) < 1 {
-				s.logger.Debug("No messages received, requesting again")
+		failed := res.Errors()
+		groups := newMessageGroups()
+		handled := make([]*sqs.Message, 0, len(received[topic]))
+		for _, m := range received[topic] {
+			if err, ok := failed[aws.StringValue(m.MessageId)]; ok {
+				s.logger.Errorf("error handling message %s of topic %s: %v", aws.StringValue(m.MessageId), topic, err)
+				groups.fail(m)
 
 				continue
 			}
+			if groups.failed(m) {
+				continue
+			}
+			handled = append(handled, m)
+		}
+		if len(handled) == 0 {
+			continue
+		}
+
+		if err := s.acknowledgeMessages(queueInfo.url, handled); err != nil {
+			s.logger.Errorf("error acknowledging %d message(s) of topic %s: %v", len(handled), topic, err)
+		}
+	}
+}
+
+// receiveMessages returns the next messages of the queue, if any
+func (s *snsSqs) receiveMessages(queueInfo *sqsQueueInfo) []*sqs.Message {
+	// use this property to decide when a message should be discarded
+	attributeNames := []*string{
+		aws.String(sqs.MessageSystemAttributeNameApproximateReceiveCount),
+	}
+	if s.metadata.fifo {
+		attributeNames = append(attributeNames, aws.String(sqs.MessageSystemAttributeNameMessageGroupId))
+	}
 
-			s.logger.Debugf("%v message(s) received", len(messageResponse.Messages))
+	messageResponse, err := s.sqsClient.ReceiveMessage(&sqs.ReceiveMessageInput{
+		AttributeNames:      attributeNames,
+		MaxNumberOfMessages: aws.Int64(s.metadata.messageMaxNumber),
+		QueueUrl:            &queueInfo.url,
+		VisibilityTimeout:   aws.Int64(s.metadata.messageVisibilityTimeout),
+		WaitTimeSeconds:     aws.Int64(s.metadata.messageWaitTimeSeconds),
+	})
+	if err != nil {
+		s.logger.Errorf("error consuming topic: %v", err)
+
+		return nil
+	}
+
+	// retry receiving messages
+	if len(messageResponse.Messages) < 1 {
+		s.logger.Debug("No messages received, requesting again")
+
+		return nil
+	}
+
+	s.logger.Debugf("%v message(s) received", len(messageResponse.Messages))
+
+	return messageResponse.Messages
+}
 
-			for _, m := range messageResponse.Messages {
+func (s *snsSqs) consumeSubscription(queueInfo *sqsQueueInfo, handler func(msg *pubsub.NewMessage) error) {
+	go func() {
+		for {
+			groups := newMessageGroups()
+			for _, m := range s.receiveMessages(queueInfo) {
+				if groups.failed(m) {
+					continue
+				}
 				if err := s.handleMessage(m, queueInfo, handler); err != nil {
 					s.logger.Error(err)
+					groups.fail(m)
 				}
 			}
 		}
 	}()
 }
 
+func (s *snsSqs) consumeBulkSubscription(queueInfo *sqsQueueInfo, handler func(msg *pubsub.BulkMessage) (pubsub.BulkSubscribeResponse, error)) {
+	go func() {
+		for {
+			if messages := s.receiveMessages(queueInfo); len(messages) > 0 {
+				s.handleBulkMessages(messages, queueInfo, handler)
+			}
+		}
+	}()
+}
+
 func (s *snsSqs) Subscribe(req pubsub.SubscribeRequest, handler func(msg *pubsub.NewMessage) error) error {
+	queueInfo, err := s.subscribeTopic(req.Topic)
+	if err != nil {
+		return err
+	}
+
+	s.consumeSubscription(queueInfo, handler)
+
+	return nil
+}
+
+// BulkSubscribe delivers the messages received together from the queue as bulk messages, one per topic
+func (s *snsSqs) BulkSubscribe(req pubsub.SubscribeRequest, handler func(msg *pubsub.BulkMessage) (pubsub.BulkSubscribeResponse, error)) error {
+	queueInfo, err := s.subscribeTopic(req.Topic)
+	if err != nil {
+		return err
+	}
+
+	s.consumeBulkSubscription(queueInfo, handler)
+
+	return nil
+}
+
+// subscribeTopic subscribes the queue of the application to the topic and returns the queue
+func (s *snsSqs) subscribeTopic(topic string) (*sqsQueueInfo, error) {
 	// subscribers declare a topic ARN
 	// and declare a SQS queue to use
 	// these should be idempotent
 	// queues should not be created if they exist
-	topicArn, err := s.getOrCreateTopic(req.Topic)
+	topicArn, err := s.getOrCreateTopic(topic)
 	if err != nil {
-		s.logger.Errorf("error getting topic ARN for %s: %v", req.Topic, err)
+		s.logger.Errorf("error getting topic ARN for %s: %v", topic, err)
 
-		return err
+		return nil, err
 	}
 
 	// this is the ID of the application, it is supplied via runtime as "consumerID"
@@ -464,7 +743,12 @@ func (s *snsSqs) Subscribe(req pubsub.SubscribeRequest, handler func(msg *pubsub
 	if err != nil {
 		s.logger.Errorf("error retrieving SQS queue: %v", err)
 
-		return err
+		return nil, err
+	}
+
+	// the queue is expected to be subscribed to the topic already
+	if s.metadata.disableEntityManagement {
+		return queueInfo, nil
 	}
 
 	// subscription creation is idempotent. Subscriptions are unique by topic/queue
@@ -476,17 +760,15 @@ func (s *snsSqs) Subscribe(req pubsub.SubscribeRequest, handler func(msg *pubsub
 		TopicArn:              &topicArn,
 	})
 	if err != nil {
-		s.logger.Errorf("error subscribing to topic %s: %v", req.Topic, err)
+		s.logger.Errorf("error subscribing to topic %s: %v", topic, err)
 
-		return err
+		return nil, err
 	}
 
 	s.subscriptions = append(s.subscriptions, subscribeOutput.SubscriptionArn)
-	s.logger.Debugf("Subscribed to topic %s: %v", req.Topic, subscribeOutput)
-
-	s.consumeSubscription(queueInfo, handler)
+	s.logger.Debugf("Subscribed to topic %s: %v", topic, subscribeOutput)
 
-	return nil
+	return queueInfo, nil
 }
 
 func (s *snsSqs) Close() error {
@@ -2,9 +2,15 @@ package snssqs
 
 import (
 	"fmt"
+	"io/ioutil"
+	"net/url"
 	"strings"
 	"testing"
 
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/awstesting/unit"
+	sns "github.com/aws/aws-sdk-go/service/sns"
+	sqs "github.com/aws/aws-sdk-go/service/sqs"
 	"github.com/dapr/components-contrib/pubsub"
 	"github.com/dapr/dapr/pkg/logger"
 	"github.com/stretchr/testify/require"
@@ -219,6 +225,45 @@ func Test_getSnsSqsMetatdata_invalidMessageMaxNumberTooLow(t *testing.T) {
 	r.Nil(md)
 }
 
+func Test_getSnsSqsMetatdata_fifo(t *testing.T) {
+	r := require.New(t)
+	l := logger.NewLogger("SnsSqs unit test")
+	l.SetOutputLevel(logger.DebugLevel)
+	ps := snsSqs{
+		logger: l,
+	}
+
+	md, err := ps.getSnsSqsMetatdata(pubsub.Metadata{Properties: map[string]string{
+		"consumerID":              "consumer",
+		"fifo":                    "true",
+		"fifoMessageGroupID":      "group",
+		"disableEntityManagement": "true",
+	}})
+
+	r.NoError(err)
+
+	r.True(md.fifo)
+	r.Equal("group", md.fifoMessageGroupID)
+	r.True(md.disableEntityManagement)
+}
+
+func Test_getSnsSqsMetatdata_invalidFifo(t *testing.T) {
+	r := require.New(t)
+	l := logger.NewLogger("SnsSqs unit test")
+	l.SetOutputLevel(logger.DebugLevel)
+	ps := snsSqs{
+		logger: l,
+	}
+
+	md, err := ps.getSnsSqsMetatdata(pubsub.Metadata{Properties: map[string]string{
+		"consumerID": "consumer",
+		"fifo":       "maybe",
+	}})
+
+	r.Error(err)
+	r.Nil(md)
+}
+
 func Test_parseInt64(t *testing.T) {
 	r := require.New(t)
 	number, err := parseInt64("applesauce", "propertyName")
@@ -255,3 +300,116 @@ func Test_nameToHash(t *testing.T) {
 			fmt.Sprintf("Invalid character %s in hashed name", string(c)))
 	}
 }
+
+func Test_parseMessage(t *testing.T) {
+	l := logger.NewLogger("SnsSqs unit test")
+	ps := snsSqs{
+		logger:    l,
+		metadata:  &snsSqsMetadata{messageRetryLimit: 10},
+		topicHash: map[string]string{nameToHash("topic"): "topic"},
+	}
+
+	t.Run("sns notification", func(t *testing.T) {
+		r := require.New(t)
+		body := fmt.Sprintf(`{"Message":"data","TopicArn":"arn:aws:sns:us-east-1:000000000000:%s"}`, nameToHash("topic"))
+		msg, err := ps.parseMessage(&sqs.Message{
+			Attributes: map[string]*string{sqs.MessageSystemAttributeNameApproximateReceiveCount: aws.String("1")},
+			Body:       &body,
+		}, &sqsQueueInfo{})
+		r.NoError(err)
+		r.Equal("topic", msg.Topic)
+		r.Equal([]byte("data"), msg.Data)
+	})
+
+	t.Run("missing receive count", func(t *testing.T) {
+		r := require.New(t)
+		_, err := ps.parseMessage(&sqs.Message{}, &sqsQueueInfo{})
+		r.Error(err)
+	})
+
+	t.Run("invalid body", func(t *testing.T) {
+		r := require.New(t)
+		_, err := ps.parseMessage(&sqs.Message{
+			Attributes: map[string]*string{sqs.MessageSystemAttributeNameApproximateReceiveCount: aws.String("1")},
+			Body:       aws.String("data"),
+		}, &sqsQueueInfo{})
+		r.Error(err)
+	})
+}
+
+func Test_entityName(t *testing.T) {
+	r := require.New(t)
+
+	ps := snsSqs{metadata: &snsSqsMetadata{}}
+	r.Equal(nameToHash("topic"), ps.entityName("topic"))
+
+	ps.metadata.fifo = true
+	r.Equal(nameToHash("topic")+".fifo", ps.entityName("topic"))
+}
+
+func Test_messageGroupID(t *testing.T) {
+	ps := snsSqs{metadata: &snsSqsMetadata{}}
+
+	t.Run("partition key", func(t *testing.T) {
+		r := require.New(t)
+		r.Equal("key", ps.messageGroupID(&pubsub.PublishRequest{
+			Topic:    "topic",
+			Metadata: map[string]string{pubsub.PartitionKeyMetadataKey: "key"},
+		}))
+	})
+
+	t.Run("topic", func(t *testing.T) {
+		r := require.New(t)
+		r.Equal("topic", ps.messageGroupID(&pubsub.PublishRequest{Topic: "topic"}))
+	})
+
+	t.Run("component message group", func(t *testing.T) {
+		r := require.New(t)
+		ps := snsSqs{metadata: &snsSqsMetadata{fifoMessageGroupID: "group"}}
+		r.Equal("group", ps.messageGroupID(&pubsub.PublishRequest{Topic: "topic"}))
+	})
+}
+
+func Test_deduplicationID(t *testing.T) {
+	r := require.New(t)
+
+	r.Equal("1234", deduplicationID([]byte(`{"specversion":"1.0","id":"1234","source":"app","type":"t","data":"a"}`)))
+	r.Equal("", deduplicationID([]byte("data")))
+
+	longID := strings.Repeat("a", 200)
+	r.Equal(nameToHash(longID), deduplicationID([]byte(`{"specversion":"1.0","id":"`+longID+`","source":"app","type":"t"}`)))
+}
+
+func Test_withFifoParameters(t *testing.T) {
+	r := require.New(t)
+	req, _ := sns.New(unit.Session).PublishRequest(&sns.PublishInput{
+		Message:  aws.String("data"),
+		TopicArn: aws.String("arn:aws:sns:us-east-1:000000000000:topic.fifo"),
+	})
+	req.ApplyOptions(withFifoParameters("group", "1234"))
+	r.NoError(req.Build())
+
+	body, err := ioutil.ReadAll(req.Body)
+	r.NoError(err)
+	params, err := url.ParseQuery(string(body))
+	r.NoError(err)
+	r.Equal("data", params.Get("Message"))
+	r.Equal("group", params.Get("MessageGroupId"))
+	r.Equal("1234", params.Get("MessageDeduplicationId"))
+}
+
+func Test_messageGroups(t *testing.T) {
+	r := require.New(t)
+	groups := newMessageGroups()
+	first := &sqs.Message{Attributes: map[string]*string{sqs.MessageSystemAttributeNameMessageGroupId: aws.String("a")}}
+	second := &sqs.Message{Attributes: map[string]*string{sqs.MessageSystemAttributeNameMessageGroupId: aws.String("a")}}
+	other := &sqs.Message{Attributes: map[string]*string{sqs.MessageSystemAttributeNameMessageGroupId: aws.String("b")}}
+	standard := &sqs.Message{}
+
+	r.False(groups.failed(second))
+	groups.fail(first)
+	groups.fail(standard)
+	r.True(groups.failed(second))
+	r.False(groups.failed(other))
+	r.False(groups.failed(standard))
+}
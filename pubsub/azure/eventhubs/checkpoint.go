@@ -0,0 +1,110 @@
+// ------------------------------------------------------------
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT License.
+// ------------------------------------------------------------
+
+package eventhubs
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/Azure/azure-amqp-common-go/persist"
+	"github.com/Azure/azure-event-hubs-go/eph"
+)
+
+// checkpointer persists the checkpoint of a partition once every checkpointCount events or checkpointInterval,
+// instead of after every event. The events received since the last persisted checkpoint are replayed on restart.
+// The partitions without checkpoint are read from the startOffset.
+type checkpointer struct {
+	eph.Checkpointer
+	count       int
+	interval    time.Duration
+	startOffset string
+
+	lock       sync.Mutex
+	partitions map[string]*partitionCheckpoint
+	now        func() time.Time
+}
+
+type partitionCheckpoint struct {
+	// pending is the number of events handled since the last persisted checkpoint
+	pending   int
+	persisted time.Time
+}
+
+func newCheckpointer(c eph.Checkpointer, m azureEventHubsMetadata) *checkpointer {
+	return &checkpointer{
+		Checkpointer: c,
+		count:        m.checkpointCount,
+		interval:     m.checkpointInterval,
+		startOffset:  m.startOffset,
+		partitions:   make(map[string]*partitionCheckpoint),
+		now:          time.Now,
+	}
+}
+
+// GetCheckpoint returns the checkpoint of a partition, which starts at the start offset when none was persisted
+func (c *checkpointer) GetCheckpoint(ctx context.Context, partitionID string) (persist.Checkpoint, bool) {
+	checkpoint, ok := c.Checkpointer.GetCheckpoint(ctx, partitionID)
+	if ok {
+		checkpoint = c.withStartOffset(checkpoint)
+	}
+
+	return checkpoint, ok
+}
+
+// EnsureCheckpoint returns the checkpoint of a partition, which starts at the start offset when none was persisted
+func (c *checkpointer) EnsureCheckpoint(ctx context.Context, partitionID string) (persist.Checkpoint, error) {
+	checkpoint, err := c.Checkpointer.EnsureCheckpoint(ctx, partitionID)
+	if err != nil {
+		return checkpoint, err
+	}
+
+	return c.withStartOffset(checkpoint), nil
+}
+
+// UpdateCheckpoint persists the checkpoint of a partition once it is due
+func (c *checkpointer) UpdateCheckpoint(ctx context.Context, partitionID string, checkpoint persist.Checkpoint) error {
+	if !c.due(partitionID) {
+		return nil
+	}
+
+	return c.Checkpointer.UpdateCheckpoint(ctx, partitionID, checkpoint)
+}
+
+func (c *checkpointer) withStartOffset(checkpoint persist.Checkpoint) persist.Checkpoint {
+	if c.startOffset != "" && checkpoint.Offset == persist.StartOfStream {
+		checkpoint.Offset = c.startOffset
+	}
+
+	return checkpoint
+}
+
+// due counts an event handled on a partition and tells whether its checkpoint is to be persisted
+func (c *checkpointer) due(partitionID string) bool {
+	if c.count <= 1 && c.interval <= 0 {
+		return true
+	}
+
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	p, ok := c.partitions[partitionID]
+	if !ok {
+		p = &partitionCheckpoint{}
+		c.partitions[partitionID] = p
+	}
+	p.pending++
+
+	now := c.now()
+	if (c.count > 0 && p.pending >= c.count) || (c.interval > 0 && now.Sub(p.persisted) >= c.interval) {
+		p.pending = 0
+		p.persisted = now
+
+		return true
+	}
+
+	return false
+}
@@ -9,12 +9,15 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"strconv"
+	"time"
 
 	eventhub "github.com/Azure/azure-event-hubs-go"
 	"github.com/Azure/azure-event-hubs-go/eph"
 	"github.com/Azure/azure-event-hubs-go/storage"
 	"github.com/Azure/azure-storage-blob-go/azblob"
 	"github.com/Azure/go-autorest/autorest/azure"
+	contrib_metadata "github.com/dapr/components-contrib/metadata"
 	"github.com/dapr/components-contrib/pubsub"
 	"github.com/dapr/dapr/pkg/logger"
 )
@@ -28,6 +31,19 @@ const (
 	storageAccountName   = "storageAccountName"
 	storageAccountKey    = "storageAccountKey"
 	storageContainerName = "storageContainerName"
+	// tune the checkpoints of the subscriber
+	checkpointCount    = "checkpointCount"
+	checkpointInterval = "checkpointInterval"
+	initialPosition    = "initialPosition"
+	prefetchCount      = "prefetchCount"
+
+	// initial positions, or else an RFC 3339 timestamp
+
+	initialPositionEarliest = "earliest"
+	initialPositionLatest   = "latest"
+
+	// latestOffset is the offset of the end of a partition
+	latestOffset = "@latest"
 
 	// errors
 
@@ -52,6 +68,15 @@ type azureEventHubsMetadata struct {
 	storageAccountName   string
 	storageAccountKey    string
 	storageContainerName string
+	partitionKeyPath     pubsub.KeyPath
+	// checkpointCount is the number of events a checkpoint is persisted after, 0 when only checkpointInterval applies
+	checkpointCount int
+	// checkpointInterval is the time a checkpoint is persisted after, 0 when only checkpointCount applies
+	checkpointInterval time.Duration
+	// startOffset is the offset the partitions without checkpoint are read from, they are read from the start when empty
+	startOffset string
+	// startTime is the enqueued time of the first events delivered to the partitions without checkpoint, if set
+	startTime time.Time
 }
 
 // NewAzureEventHubs returns a new Azure Event hubs instance
@@ -92,6 +117,52 @@ func parseEventHubsMetadata(meta pubsub.Metadata) (azureEventHubsMetadata, error
 		return m, errors.New(missingConsumerIDErrorMsg)
 	}
 
+	path, err := pubsub.ParseKeyPath(meta.Properties[pubsub.PartitionKeyPathMetadataKey])
+	if err != nil {
+		return m, fmt.Errorf("error: %s", err)
+	}
+	m.partitionKeyPath = path
+
+	m.checkpointCount = 1
+	if val, ok := meta.Properties[checkpointCount]; ok && val != "" {
+		count, err := strconv.Atoi(val)
+		if err != nil || count < 0 {
+			return m, fmt.Errorf("error: invalid %s %s, it must be a positive number", checkpointCount, val)
+		}
+		m.checkpointCount = count
+		if _, ok := meta.Properties[checkpointInterval]; !ok && count == 0 {
+			return m, fmt.Errorf("error: %s 0 requires %s", checkpointCount, checkpointInterval)
+		}
+	} else if _, ok := meta.Properties[checkpointInterval]; ok {
+		// the checkpoints are only persisted after the interval
+		m.checkpointCount = 0
+	}
+
+	if val, ok := meta.Properties[checkpointInterval]; ok && val != "" {
+		interval, err := time.ParseDuration(val)
+		if err != nil || interval <= 0 {
+			return m, fmt.Errorf("error: invalid %s %s, it must be a positive duration", checkpointInterval, val)
+		}
+		m.checkpointInterval = interval
+	}
+
+	switch val := meta.Properties[initialPosition]; val {
+	case "", initialPositionEarliest:
+	case initialPositionLatest:
+		m.startOffset = latestOffset
+	default:
+		start, err := time.Parse(time.RFC3339, val)
+		if err != nil {
+			return m, fmt.Errorf("error: invalid %s %s, it must be %s, %s or an RFC 3339 timestamp", initialPosition, val, initialPositionEarliest, initialPositionLatest)
+		}
+		m.startTime = start
+	}
+
+	// the receivers of the event processor host of azure-event-hubs-go v1.3.1 cannot be given a prefetch count
+	if val, ok := meta.Properties[prefetchCount]; ok && val != "" {
+		return m, fmt.Errorf("error: %s is not supported by the event processor host", prefetchCount)
+	}
+
 	return m, nil
 }
 
@@ -114,7 +185,26 @@ func (aeh *AzureEventHubs) Init(metadata pubsub.Metadata) error {
 
 // Publish sends data to Azure Event Hubs
 func (aeh *AzureEventHubs) Publish(req *pubsub.PublishRequest) error {
-	err := aeh.hub.Send(context.Background(), &eventhub.Event{Data: req.Data})
+	event := &eventhub.Event{Data: req.Data}
+
+	rawPayload, err := contrib_metadata.IsRawPayload(req.Metadata)
+	if err != nil {
+		return fmt.Errorf("error from publish: %s", err)
+	}
+
+	// raw payloads are only keyed by the metadata
+	partitionKey := req.Metadata[pubsub.PartitionKeyMetadataKey]
+	if !rawPayload {
+		partitionKey, err = pubsub.PartitionKey(req.Data, req.Metadata, aeh.metadata.partitionKeyPath)
+		if err != nil {
+			return fmt.Errorf("error from publish: %s", err)
+		}
+	}
+	if partitionKey != "" {
+		event.PartitionKey = &partitionKey
+	}
+
+	err = aeh.hub.Send(context.Background(), event)
 	if err != nil {
 		return fmt.Errorf("error from publish: %s", err)
 	}
@@ -122,6 +212,23 @@ func (aeh *AzureEventHubs) Publish(req *pubsub.PublishRequest) error {
 	return nil
 }
 
+// BulkPublish sends the entries to Azure Event Hubs in a single batch
+func (aeh *AzureEventHubs) BulkPublish(req *pubsub.BulkPublishRequest) (pubsub.BulkPublishResponse, error) {
+	events := make([]*eventhub.Event, len(req.Entries))
+	for i, entry := range req.Entries {
+		events[i] = &eventhub.Event{Data: entry.Event}
+	}
+
+	err := aeh.hub.SendBatch(context.Background(), eventhub.NewEventBatch(events))
+	if err != nil {
+		err = fmt.Errorf("error from bulk publish: %s", err)
+
+		return pubsub.NewBulkPublishErrorResponse(req, err), err
+	}
+
+	return pubsub.BulkPublishResponse{}, nil
+}
+
 // Subscribe receives data from Azure Event Hubs
 func (aeh *AzureEventHubs) Subscribe(req pubsub.SubscribeRequest, handler func(msg *pubsub.NewMessage) error) error {
 	cred, err := azblob.NewSharedKeyCredential(aeh.metadata.storageAccountName, aeh.metadata.storageAccountKey)
@@ -134,13 +241,19 @@ func (aeh *AzureEventHubs) Subscribe(req pubsub.SubscribeRequest, handler func(m
 		return err
 	}
 
-	processor, err := eph.NewFromConnectionString(context.Background(), aeh.metadata.connectionString, leaserCheckpointer, leaserCheckpointer, eph.WithNoBanner(), eph.WithConsumerGroup(aeh.metadata.consumerGroup))
+	processor, err := eph.NewFromConnectionString(context.Background(), aeh.metadata.connectionString, leaserCheckpointer, newCheckpointer(leaserCheckpointer, aeh.metadata), eph.WithNoBanner(), eph.WithConsumerGroup(aeh.metadata.consumerGroup))
 	if err != nil {
 		return err
 	}
 
 	_, err = processor.RegisterHandler(context.Background(),
 		func(c context.Context, e *eventhub.Event) error {
+			// partitions without checkpoint are read from their start up to the initial timestamp,
+			// the events are skipped but checkpointed so that they are not read again
+			if aeh.enqueuedBeforeStart(e) {
+				return nil
+			}
+
 			return handler(&pubsub.NewMessage{Data: e.Data, Topic: req.Topic})
 		})
 	if err != nil {
@@ -155,6 +268,15 @@ func (aeh *AzureEventHubs) Subscribe(req pubsub.SubscribeRequest, handler func(m
 	return nil
 }
 
+// enqueuedBeforeStart tells whether an event was enqueued before the initial timestamp
+func (aeh *AzureEventHubs) enqueuedBeforeStart(e *eventhub.Event) bool {
+	if aeh.metadata.startTime.IsZero() || e.SystemProperties == nil || e.SystemProperties.EnqueuedTime == nil {
+		return false
+	}
+
+	return e.SystemProperties.EnqueuedTime.Before(aeh.metadata.startTime)
+}
+
 func (aeh *AzureEventHubs) Close() error {
 	return aeh.hub.Close(context.TODO())
 }
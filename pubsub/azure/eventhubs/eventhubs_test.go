@@ -6,8 +6,13 @@
 package eventhubs
 
 import (
+	"context"
 	"testing"
+	"time"
 
+	"github.com/Azure/azure-amqp-common-go/persist"
+	eventhub "github.com/Azure/azure-event-hubs-go"
+	"github.com/Azure/azure-event-hubs-go/eph"
 	"github.com/dapr/components-contrib/pubsub"
 	"github.com/stretchr/testify/assert"
 )
@@ -25,6 +30,74 @@ func TestParseEventHubsMetadata(t *testing.T) {
 		assert.Equal(t, m.storageAccountKey, "key")
 		assert.Equal(t, m.storageContainerName, "container")
 		assert.Equal(t, m.consumerGroup, "mygroup")
+		assert.Nil(t, m.partitionKeyPath)
+	})
+
+	t.Run("test partitionKeyPath", func(t *testing.T) {
+		props := map[string]string{"connectionString": "fake", "consumerID": "mygroup", "storageAccountName": "account", "storageAccountKey": "key", "storageContainerName": "container", "partitionKeyPath": "$.customer.id"}
+
+		metadata := pubsub.Metadata{Properties: props}
+		m, err := parseEventHubsMetadata(metadata)
+
+		assert.NoError(t, err)
+		expected, _ := pubsub.ParseKeyPath("$.customer.id")
+		assert.Equal(t, expected, m.partitionKeyPath)
+
+		props["partitionKeyPath"] = "$..id"
+		_, err = parseEventHubsMetadata(metadata)
+		assert.Error(t, err)
+	})
+
+	t.Run("test checkpoints and initial position", func(t *testing.T) {
+		props := map[string]string{"connectionString": "fake", "consumerID": "mygroup", "storageAccountName": "account", "storageAccountKey": "key", "storageContainerName": "container"}
+
+		metadata := pubsub.Metadata{Properties: props}
+		m, err := parseEventHubsMetadata(metadata)
+		assert.NoError(t, err)
+		assert.Equal(t, 1, m.checkpointCount)
+		assert.Equal(t, time.Duration(0), m.checkpointInterval)
+		assert.Empty(t, m.startOffset)
+		assert.True(t, m.startTime.IsZero())
+
+		props["checkpointInterval"] = "30s"
+		m, err = parseEventHubsMetadata(metadata)
+		assert.NoError(t, err)
+		assert.Equal(t, 0, m.checkpointCount)
+		assert.Equal(t, 30*time.Second, m.checkpointInterval)
+
+		props["checkpointCount"] = "100"
+		props["initialPosition"] = "latest"
+		m, err = parseEventHubsMetadata(metadata)
+		assert.NoError(t, err)
+		assert.Equal(t, 100, m.checkpointCount)
+		assert.Equal(t, latestOffset, m.startOffset)
+
+		props["initialPosition"] = "2021-02-01T10:00:00Z"
+		m, err = parseEventHubsMetadata(metadata)
+		assert.NoError(t, err)
+		assert.Empty(t, m.startOffset)
+		assert.Equal(t, time.Date(2021, 2, 1, 10, 0, 0, 0, time.UTC), m.startTime.UTC())
+	})
+
+	t.Run("test invalid checkpoints and initial position", func(t *testing.T) {
+		invalid := []map[string]string{
+			{"checkpointCount": "-1"},
+			{"checkpointCount": "0"},
+			{"checkpointInterval": "0s"},
+			{"checkpointInterval": "soon"},
+			{"initialPosition": "first"},
+			{"prefetchCount": "300"},
+		}
+		for _, props := range invalid {
+			props["connectionString"] = "fake"
+			props["consumerID"] = "mygroup"
+			props["storageAccountName"] = "account"
+			props["storageAccountKey"] = "key"
+			props["storageContainerName"] = "container"
+
+			_, err := parseEventHubsMetadata(pubsub.Metadata{Properties: props})
+			assert.Error(t, err)
+		}
 	})
 
 	type invalidConfigTestCase struct {
@@ -69,3 +142,88 @@ func TestParseEventHubsMetadata(t *testing.T) {
 		})
 	}
 }
+
+type fakeCheckpointer struct {
+	eph.Checkpointer
+	checkpoint persist.Checkpoint
+	updates    int
+}
+
+func (f *fakeCheckpointer) GetCheckpoint(ctx context.Context, partitionID string) (persist.Checkpoint, bool) {
+	return f.checkpoint, true
+}
+
+func (f *fakeCheckpointer) UpdateCheckpoint(ctx context.Context, partitionID string, checkpoint persist.Checkpoint) error {
+	f.checkpoint = checkpoint
+	f.updates++
+
+	return nil
+}
+
+func TestCheckpointer(t *testing.T) {
+	t.Run("every event", func(t *testing.T) {
+		fake := &fakeCheckpointer{}
+		c := newCheckpointer(fake, azureEventHubsMetadata{checkpointCount: 1})
+
+		for i := 0; i < 3; i++ {
+			assert.NoError(t, c.UpdateCheckpoint(context.Background(), "0", persist.Checkpoint{}))
+		}
+		assert.Equal(t, 3, fake.updates)
+	})
+
+	t.Run("every count events", func(t *testing.T) {
+		fake := &fakeCheckpointer{}
+		c := newCheckpointer(fake, azureEventHubsMetadata{checkpointCount: 3})
+
+		for i := 0; i < 7; i++ {
+			assert.NoError(t, c.UpdateCheckpoint(context.Background(), "0", persist.Checkpoint{}))
+		}
+		assert.Equal(t, 2, fake.updates)
+
+		// partitions are counted apart
+		assert.NoError(t, c.UpdateCheckpoint(context.Background(), "1", persist.Checkpoint{}))
+		assert.Equal(t, 2, fake.updates)
+	})
+
+	t.Run("every interval", func(t *testing.T) {
+		fake := &fakeCheckpointer{}
+		c := newCheckpointer(fake, azureEventHubsMetadata{checkpointInterval: time.Minute})
+		now := time.Now()
+		c.now = func() time.Time { return now }
+
+		assert.NoError(t, c.UpdateCheckpoint(context.Background(), "0", persist.Checkpoint{}))
+		assert.NoError(t, c.UpdateCheckpoint(context.Background(), "0", persist.Checkpoint{}))
+		assert.Equal(t, 1, fake.updates)
+
+		now = now.Add(time.Minute)
+		assert.NoError(t, c.UpdateCheckpoint(context.Background(), "0", persist.Checkpoint{}))
+		assert.Equal(t, 2, fake.updates)
+	})
+
+	t.Run("start offset", func(t *testing.T) {
+		fake := &fakeCheckpointer{checkpoint: persist.Checkpoint{Offset: persist.StartOfStream}}
+		c := newCheckpointer(fake, azureEventHubsMetadata{checkpointCount: 1, startOffset: latestOffset})
+
+		checkpoint, ok := c.GetCheckpoint(context.Background(), "0")
+		assert.True(t, ok)
+		assert.Equal(t, latestOffset, checkpoint.Offset)
+
+		fake.checkpoint = persist.Checkpoint{Offset: "1024"}
+		checkpoint, _ = c.GetCheckpoint(context.Background(), "0")
+		assert.Equal(t, "1024", checkpoint.Offset)
+	})
+}
+
+func TestEnqueuedBeforeStart(t *testing.T) {
+	start := time.Date(2021, 2, 1, 10, 0, 0, 0, time.UTC)
+	before := start.Add(-time.Second)
+	after := start.Add(time.Second)
+
+	aeh := &AzureEventHubs{metadata: azureEventHubsMetadata{startTime: start}}
+	assert.True(t, aeh.enqueuedBeforeStart(&eventhub.Event{SystemProperties: &eventhub.SystemProperties{EnqueuedTime: &before}}))
+	assert.False(t, aeh.enqueuedBeforeStart(&eventhub.Event{SystemProperties: &eventhub.SystemProperties{EnqueuedTime: &after}}))
+	assert.False(t, aeh.enqueuedBeforeStart(&eventhub.Event{}))
+
+	aeh.metadata.startTime = time.Time{}
+	assert.False(t, aeh.enqueuedBeforeStart(&eventhub.Event{SystemProperties: &eventhub.SystemProperties{EnqueuedTime: &before}}))
+}
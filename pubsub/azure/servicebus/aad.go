@@ -0,0 +1,87 @@
+// ------------------------------------------------------------
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT License.
+// ------------------------------------------------------------
+
+package servicebus
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/Azure/azure-amqp-common-go/v3/aad"
+	"github.com/Azure/azure-amqp-common-go/v3/auth"
+	azservicebus "github.com/Azure/azure-service-bus-go"
+	"github.com/Azure/go-autorest/autorest/adal"
+	"github.com/Azure/go-autorest/autorest/azure"
+)
+
+// serviceBusResource is the AAD resource of the Service Bus data plane
+const serviceBusResource = "https://servicebus.azure.net/"
+
+// parseNamespaceName returns the name of the namespace, which may be given with its fully qualified domain name
+func parseNamespaceName(namespace string) string {
+	return strings.SplitN(namespace, ".", 2)[0]
+}
+
+// newAADTokenProvider returns the provider of the AAD tokens authenticating with the namespace:
+// those of the service principal when a client secret is given, else those of the managed identity,
+// user assigned when a client ID is given.
+func newAADTokenProvider(m metadata) (auth.TokenProvider, error) {
+	env, err := azure.EnvironmentFromName(m.AzureEnvironment)
+	if err != nil {
+		return nil, fmt.Errorf("%s invalid azureEnvironment %s, %s", errorMessagePrefix, m.AzureEnvironment, err)
+	}
+
+	var token *adal.ServicePrincipalToken
+	if m.AzureClientSecret != "" {
+		oauthConfig, err := adal.NewOAuthConfig(env.ActiveDirectoryEndpoint, m.AzureTenantID)
+		if err != nil {
+			return nil, fmt.Errorf("%s invalid azureTenantId %s, %s", errorMessagePrefix, m.AzureTenantID, err)
+		}
+
+		token, err = adal.NewServicePrincipalToken(*oauthConfig, m.AzureClientID, m.AzureClientSecret, serviceBusResource)
+		if err != nil {
+			return nil, fmt.Errorf("%s failed to get oauth token for the service principal: %s", errorMessagePrefix, err)
+		}
+	} else {
+		msiEndpoint, err := adal.GetMSIEndpoint()
+		if err != nil {
+			return nil, fmt.Errorf("%s failed to get the managed identity endpoint: %s", errorMessagePrefix, err)
+		}
+
+		if m.AzureClientID == "" {
+			token, err = adal.NewServicePrincipalTokenFromMSI(msiEndpoint, serviceBusResource)
+		} else {
+			token, err = adal.NewServicePrincipalTokenFromMSIWithUserAssignedID(msiEndpoint, serviceBusResource, m.AzureClientID)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%s failed to get oauth token from the managed identity: %s", errorMessagePrefix, err)
+		}
+	}
+
+	provider, err := aad.NewJWTProvider(aad.JWTProviderWithAADToken(token), aad.JWTProviderWithAzureEnvironment(&env))
+	if err != nil {
+		return nil, fmt.Errorf("%s %s", errorMessagePrefix, err)
+	}
+
+	return provider, nil
+}
+
+// namespaceWithTokenProvider configures the namespace of the given name in the Azure environment of the component
+// to authenticate with the tokens of the given provider
+func namespaceWithTokenProvider(m metadata, provider auth.TokenProvider) azservicebus.NamespaceOption {
+	return func(ns *azservicebus.Namespace) error {
+		env, err := azure.EnvironmentFromName(m.AzureEnvironment)
+		if err != nil {
+			return fmt.Errorf("%s invalid azureEnvironment %s, %s", errorMessagePrefix, m.AzureEnvironment, err)
+		}
+
+		ns.Name = m.NamespaceName
+		ns.Suffix = env.ServiceBusEndpointSuffix
+		ns.Environment = env
+		ns.TokenProvider = provider
+
+		return nil
+	}
+}
@@ -5,16 +5,25 @@
 
 package servicebus
 
+import "github.com/dapr/components-contrib/pubsub"
+
 // Reference for settings:
 // https://github.com/Azure/azure-service-bus-go/blob/54b2faa53e5216616e59725281be692acc120c34/subscription_manager.go#L101
 type metadata struct {
 	ConnectionString               string `json:"connectionString"`
+	NamespaceName                  string `json:"namespaceName"`
+	AzureTenantID                  string `json:"azureTenantId"`
+	AzureClientID                  string `json:"azureClientId"`
+	AzureClientSecret              string `json:"azureClientSecret"`
+	AzureEnvironment               string `json:"azureEnvironment"`
 	ConsumerID                     string `json:"consumerID"`
 	TimeoutInSec                   int    `json:"timeoutInSec"`
 	HandlerTimeoutInSec            int    `json:"handlerTimeoutInSec"`
 	LockRenewalInSec               int    `json:"lockRenewalInSec"`
 	MaxActiveMessages              int    `json:"maxActiveMessages"`
 	MaxActiveMessagesRecoveryInSec int    `json:"maxActiveMessagesRecoveryInSec"`
+	MaxConcurrentSessions          int    `json:"maxConcurrentSessions"`
+	SessionIdleTimeoutInSec        int    `json:"sessionIdleTimeoutInSec"`
 	DisableEntityManagement        bool   `json:"disableEntityManagement"`
 	MaxDeliveryCount               *int   `json:"maxDeliveryCount"`
 	LockDurationInSec              *int   `json:"lockDurationInSec"`
@@ -22,4 +31,8 @@ type metadata struct {
 	AutoDeleteOnIdleInSec          *int   `json:"autoDeleteOnIdleInSec"`
 	MaxConcurrentHandlers          *int   `json:"maxConcurrentHandlers"`
 	PrefetchCount                  *int   `json:"prefetchCount"`
+	ContentMode                    string `json:"contentMode"`
+	Compression                    string `json:"compression"`
+	DeadLetter                     pubsub.DeadLetterConfig
+	MaxExtensions                  int `json:"maxCloudEventExtensions"`
 }
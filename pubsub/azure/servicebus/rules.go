@@ -0,0 +1,161 @@
+// ------------------------------------------------------------
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT License.
+// ------------------------------------------------------------
+
+package servicebus
+
+import (
+	"context"
+	"fmt"
+	"hash/fnv"
+	"sort"
+	"strings"
+	"time"
+
+	azservicebus "github.com/Azure/azure-service-bus-go"
+)
+
+const (
+	// sqlFilter is the subscription metadata key of the SQL filter expression the messages are delivered upon
+	sqlFilter = "sqlFilter"
+	// correlationFilterPrefix prefixes the subscription metadata keys of the correlation filter the messages
+	// are delivered upon, e.g. correlationFilter.label or correlationFilter.properties.region
+	correlationFilterPrefix = "correlationFilter."
+	// correlationFilterPropertiesPrefix prefixes the application properties of the correlation filter
+	correlationFilterPropertiesPrefix = correlationFilterPrefix + "properties."
+
+	// defaultRuleName is the rule every subscription is created with, delivering all messages
+	defaultRuleName = "$Default"
+	// filterRulePrefix prefixes the name of the rules managed by the component
+	filterRulePrefix = "dapr-"
+)
+
+// subscriptionRule is the rule a subscription delivers the messages upon
+type subscriptionRule struct {
+	name   string
+	filter azservicebus.FilterDescriber
+}
+
+// parseSubscriptionRule parses the SQL or correlation filter of the subscription metadata.
+// It returns nil when there is none, so that all the messages are delivered.
+// The rule is named after a hash of the filter, so that the rule of a changed filter gets a new name.
+func parseSubscriptionRule(metadata map[string]string) (*subscriptionRule, error) {
+	var correlation azservicebus.CorrelationFilter
+	var keys []string
+	for key, val := range metadata {
+		if !strings.HasPrefix(key, correlationFilterPrefix) || val == "" {
+			continue
+		}
+		keys = append(keys, key+"="+val)
+		value := val
+
+		if strings.HasPrefix(key, correlationFilterPropertiesPrefix) {
+			if correlation.Properties == nil {
+				correlation.Properties = make(map[string]interface{})
+			}
+			correlation.Properties[strings.TrimPrefix(key, correlationFilterPropertiesPrefix)] = value
+
+			continue
+		}
+
+		switch strings.TrimPrefix(key, correlationFilterPrefix) {
+		case "correlationId":
+			correlation.CorrelationID = &value
+		case "messageId":
+			correlation.MessageID = &value
+		case "to":
+			correlation.To = &value
+		case "replyTo":
+			correlation.ReplyTo = &value
+		case "label":
+			correlation.Label = &value
+		case "sessionId":
+			correlation.SessionID = &value
+		case "replyToSessionId":
+			correlation.ReplyToSessionID = &value
+		case "contentType":
+			correlation.ContentType = &value
+		default:
+			return nil, fmt.Errorf("%s invalid correlation filter %s", errorMessagePrefix, key)
+		}
+	}
+
+	expression := metadata[sqlFilter]
+	switch {
+	case expression != "" && len(keys) > 0:
+		return nil, fmt.Errorf("%s %s and correlation filters are mutually exclusive", errorMessagePrefix, sqlFilter)
+	case expression != "":
+		return &subscriptionRule{
+			name:   filterRuleName("sql:" + expression),
+			filter: azservicebus.SQLFilter{Expression: expression},
+		}, nil
+	case len(keys) > 0:
+		sort.Strings(keys)
+
+		return &subscriptionRule{
+			name:   filterRuleName("correlation:" + strings.Join(keys, "\n")),
+			filter: correlation,
+		}, nil
+	default:
+		return nil, nil
+	}
+}
+
+func filterRuleName(filter string) string {
+	h := fnv.New32a()
+	h.Write([]byte(filter))
+
+	return fmt.Sprintf("%s%08x", filterRulePrefix, h.Sum32())
+}
+
+// ensureSubscriptionRules makes the rule the only rule of the subscription. The rule is added before the others
+// are deleted, so that no message is dropped meanwhile. Without rule, the default rule delivering all the messages
+// replaces the rules added by the component, and the rules managed by other means are left as is.
+func (a *azureServiceBus) ensureSubscriptionRules(mgr *azservicebus.SubscriptionManager, subscription string, rule *subscriptionRule) error {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*time.Duration(a.metadata.TimeoutInSec))
+	defer cancel()
+
+	rules, err := mgr.ListRules(ctx, subscription)
+	if err != nil {
+		return fmt.Errorf("%s could not list the rules of subscription %s, %s", errorMessagePrefix, subscription, err)
+	}
+
+	name := defaultRuleName
+	var filter azservicebus.FilterDescriber = azservicebus.TrueFilter{}
+	if rule != nil {
+		name = rule.name
+		filter = rule.filter
+	}
+
+	var stale []string
+	found := false
+	for _, r := range rules {
+		switch {
+		case r.Name == name:
+			found = true
+		case rule != nil || strings.HasPrefix(r.Name, filterRulePrefix):
+			stale = append(stale, r.Name)
+		}
+	}
+	if len(stale) == 0 && (found || rule == nil) {
+		// the subscription does not filter the messages unless the component did
+		return nil
+	}
+
+	if !found {
+		a.logger.Debugf("Adding rule %s to subscription %s", name, subscription)
+		if _, err = mgr.PutRule(ctx, subscription, name, filter); err != nil {
+			return fmt.Errorf("%s could not put rule %s of subscription %s, %s", errorMessagePrefix, name, subscription, err)
+		}
+	}
+
+	for _, r := range stale {
+		a.logger.Debugf("Deleting rule %s of subscription %s", r, subscription)
+		if err = mgr.DeleteRule(ctx, subscription, r); err != nil && !azservicebus.IsErrNotFound(err) {
+			return fmt.Errorf("%s could not delete rule %s of subscription %s, %s", errorMessagePrefix, r, subscription, err)
+		}
+	}
+
+	return nil
+}
@@ -11,6 +11,7 @@ import (
 	"strconv"
 	"time"
 
+	"github.com/Azure/azure-amqp-common-go/v3/auth"
 	azservicebus "github.com/Azure/azure-service-bus-go"
 	contrib_metadata "github.com/dapr/components-contrib/metadata"
 	"github.com/dapr/components-contrib/pubsub"
@@ -20,6 +21,11 @@ import (
 const (
 	// Keys
 	connectionString               = "connectionString"
+	namespaceName                  = "namespaceName"
+	azureTenantID                  = "azureTenantId"
+	azureClientID                  = "azureClientId"
+	azureClientSecret              = "azureClientSecret"
+	azureEnvironment               = "azureEnvironment"
 	consumerID                     = "consumerID"
 	maxDeliveryCount               = "maxDeliveryCount"
 	timeoutInSec                   = "timeoutInSec"
@@ -33,8 +39,19 @@ const (
 	prefetchCount                  = "prefetchCount"
 	maxActiveMessages              = "maxActiveMessages"
 	maxActiveMessagesRecoveryInSec = "maxActiveMessagesRecoveryInSec"
+	maxConcurrentSessions          = "maxConcurrentSessions"
+	sessionIdleTimeoutInSec        = "sessionIdleTimeoutInSec"
 	errorMessagePrefix             = "azure service bus error:"
 
+	// requireSessions is the subscription metadata key creating session enabled subscriptions,
+	// whose messages are delivered in order per session
+	requireSessions = "requireSessions"
+	// sessionIDMetadataKey is the publish metadata key of the session of the message
+	sessionIDMetadataKey = "sessionId"
+
+	// ceHeaderPrefix prefixes the cloud event attributes in the application properties in binary content mode
+	ceHeaderPrefix = "cloudEvents_"
+
 	// Defaults
 	defaultTimeoutInSec        = 60
 	defaultHandlerTimeoutInSec = 60
@@ -44,6 +61,9 @@ const (
 	defaultMaxActiveMessages              = 10000
 	defaultMaxActiveMessagesRecoveryInSec = 2
 	defaultDisableEntityManagement        = false
+	defaultMaxConcurrentSessions          = 8
+	defaultSessionIdleTimeoutInSec        = 60
+	defaultAzureEnvironment               = "AzurePublicCloud"
 
 	maxReconnAttempts       = 10
 	connectionRecoveryInSec = 2
@@ -65,7 +85,7 @@ func NewAzureServiceBus(logger logger.Logger) pubsub.PubSub {
 	return &azureServiceBus{
 		logger:        logger,
 		subscriptions: []*subscription{},
-		features:      []pubsub.Feature{pubsub.FeatureMessageTTL},
+		features:      []pubsub.Feature{pubsub.FeatureMessageTTL, pubsub.FeatureScheduledDelivery},
 	}
 }
 
@@ -73,10 +93,30 @@ func parseAzureServiceBusMetadata(meta pubsub.Metadata) (metadata, error) {
 	m := metadata{}
 
 	/* Required configuration settings - no defaults */
+	// the namespace is reached with a connection string, or with its name when authenticating with Azure AD
 	if val, ok := meta.Properties[connectionString]; ok && val != "" {
 		m.ConnectionString = val
-	} else {
-		return m, fmt.Errorf("%s missing connection string", errorMessagePrefix)
+	}
+	if val, ok := meta.Properties[namespaceName]; ok && val != "" {
+		m.NamespaceName = parseNamespaceName(val)
+	}
+	if m.ConnectionString == "" && m.NamespaceName == "" {
+		return m, fmt.Errorf("%s missing connection string or namespace name", errorMessagePrefix)
+	}
+	if m.ConnectionString != "" && m.NamespaceName != "" {
+		return m, fmt.Errorf("%s %s and %s are mutually exclusive", errorMessagePrefix, connectionString, namespaceName)
+	}
+
+	m.AzureTenantID = meta.Properties[azureTenantID]
+	m.AzureClientID = meta.Properties[azureClientID]
+	m.AzureClientSecret = meta.Properties[azureClientSecret]
+	if m.AzureClientSecret != "" && (m.AzureTenantID == "" || m.AzureClientID == "") {
+		return m, fmt.Errorf("%s %s and %s are required to authenticate with a client secret", errorMessagePrefix, azureTenantID, azureClientID)
+	}
+
+	m.AzureEnvironment = defaultAzureEnvironment
+	if val, ok := meta.Properties[azureEnvironment]; ok && val != "" {
+		m.AzureEnvironment = val
 	}
 
 	if val, ok := meta.Properties[consumerID]; ok && val != "" {
@@ -140,6 +180,24 @@ func parseAzureServiceBusMetadata(meta pubsub.Metadata) (metadata, error) {
 		}
 	}
 
+	m.MaxConcurrentSessions = defaultMaxConcurrentSessions
+	if val, ok := meta.Properties[maxConcurrentSessions]; ok && val != "" {
+		var err error
+		m.MaxConcurrentSessions, err = strconv.Atoi(val)
+		if err != nil || m.MaxConcurrentSessions < 1 {
+			return m, fmt.Errorf("%s invalid maxConcurrentSessions %s, expected a positive integer", errorMessagePrefix, val)
+		}
+	}
+
+	m.SessionIdleTimeoutInSec = defaultSessionIdleTimeoutInSec
+	if val, ok := meta.Properties[sessionIdleTimeoutInSec]; ok && val != "" {
+		var err error
+		m.SessionIdleTimeoutInSec, err = strconv.Atoi(val)
+		if err != nil || m.SessionIdleTimeoutInSec < 1 {
+			return m, fmt.Errorf("%s invalid sessionIdleTimeoutInSec %s, expected a positive integer", errorMessagePrefix, val)
+		}
+	}
+
 	/* Nullable configuration settings - defaults will be set by the server */
 	if val, ok := meta.Properties[maxDeliveryCount]; ok && val != "" {
 		valAsInt, err := strconv.Atoi(val)
@@ -191,6 +249,30 @@ func parseAzureServiceBusMetadata(meta pubsub.Metadata) (metadata, error) {
 		m.PrefetchCount = &valAsInt
 	}
 
+	contentMode, err := pubsub.ParseContentMode(meta.Properties)
+	if err != nil {
+		return m, fmt.Errorf("%s %s", errorMessagePrefix, err)
+	}
+	m.ContentMode = contentMode
+
+	m.Compression, err = pubsub.ParseCompression(meta.Properties, pubsub.CompressionNone)
+	if err != nil {
+		return m, fmt.Errorf("%s %s", errorMessagePrefix, err)
+	}
+
+	m.DeadLetter, err = pubsub.ParseDeadLetterConfig(meta.Properties)
+	if err != nil {
+		return m, fmt.Errorf("%s %s", errorMessagePrefix, err)
+	}
+	if m.DeadLetter.Enabled() && m.MaxDeliveryCount != nil && *m.MaxDeliveryCount < m.DeadLetter.MaxDeliveryAttempts {
+		return m, fmt.Errorf("%s %s %d exceeds %s %d, messages would be dead lettered by Service Bus first", errorMessagePrefix, pubsub.MaxDeliveryAttemptsMetadataKey, m.DeadLetter.MaxDeliveryAttempts, maxDeliveryCount, *m.MaxDeliveryCount)
+	}
+
+	m.MaxExtensions, err = pubsub.ParseMaxExtensions(meta.Properties)
+	if err != nil {
+		return m, fmt.Errorf("%s %s", errorMessagePrefix, err)
+	}
+
 	return m, nil
 }
 
@@ -201,7 +283,17 @@ func (a *azureServiceBus) Init(metadata pubsub.Metadata) error {
 	}
 
 	a.metadata = m
-	a.namespace, err = azservicebus.NewNamespace(azservicebus.NamespaceWithConnectionString(a.metadata.ConnectionString))
+	if m.NamespaceName != "" {
+		// authenticate with Azure AD, as the service principal or the managed identity
+		var tokenProvider auth.TokenProvider
+		tokenProvider, err = newAADTokenProvider(m)
+		if err != nil {
+			return err
+		}
+		a.namespace, err = azservicebus.NewNamespace(namespaceWithTokenProvider(m, tokenProvider))
+	} else {
+		a.namespace, err = azservicebus.NewNamespace(azservicebus.NamespaceWithConnectionString(a.metadata.ConnectionString))
+	}
 	if err != nil {
 		return err
 	}
@@ -212,6 +304,16 @@ func (a *azureServiceBus) Init(metadata pubsub.Metadata) error {
 }
 
 func (a *azureServiceBus) Publish(req *pubsub.PublishRequest) error {
+	rawPayload, err := contrib_metadata.IsRawPayload(req.Metadata)
+	if err != nil {
+		return fmt.Errorf("%s %s", errorMessagePrefix, err)
+	}
+
+	deliverAt, hasDeliverAt, err := contrib_metadata.TryGetDeliverAt(req.Metadata)
+	if err != nil {
+		return fmt.Errorf("%s %s", errorMessagePrefix, err)
+	}
+
 	if !a.metadata.DisableEntityManagement {
 		err := a.ensureTopic(req.Topic)
 		if err != nil {
@@ -228,11 +330,42 @@ func (a *azureServiceBus) Publish(req *pubsub.PublishRequest) error {
 	ctx, cancel := context.WithTimeout(context.Background(), time.Second*time.Duration(a.metadata.TimeoutInSec))
 	defer cancel()
 
-	msg := azservicebus.NewMessage(req.Data)
+	data := req.Data
+	if !rawPayload {
+		compression, err := pubsub.ParseCompression(req.Metadata, a.metadata.Compression)
+		if err != nil {
+			return fmt.Errorf("%s %s", errorMessagePrefix, err)
+		}
+		data, err = pubsub.CompressCloudEvent(data, compression)
+		if err != nil {
+			return fmt.Errorf("%s %s", errorMessagePrefix, err)
+		}
+	}
+
+	msg := azservicebus.NewMessage(data)
+	if !rawPayload && a.metadata.ContentMode == pubsub.ContentModeBinary {
+		binary, err := pubsub.ToBinaryMessage(data, ceHeaderPrefix)
+		if err != nil {
+			return fmt.Errorf("%s binary content mode requires a cloud event: %s", errorMessagePrefix, err)
+		}
+
+		msg = azservicebus.NewMessage(binary.Data)
+		msg.ContentType = binary.ContentType
+		msg.UserProperties = make(map[string]interface{}, len(binary.Headers))
+		for key, value := range binary.Headers {
+			msg.UserProperties[key] = value
+		}
+	}
 	ttl, hasTTL, _ := contrib_metadata.TryGetTTL(req.Metadata)
 	if hasTTL {
 		msg.TTL = &ttl
 	}
+	if hasDeliverAt {
+		msg.ScheduleAt(deliverAt)
+	}
+	if sessionID := req.Metadata[sessionIDMetadataKey]; sessionID != "" {
+		msg.SessionID = &sessionID
+	}
 
 	err = sender.Send(ctx, msg)
 	if err != nil {
@@ -243,12 +376,32 @@ func (a *azureServiceBus) Publish(req *pubsub.PublishRequest) error {
 }
 
 func (a *azureServiceBus) Subscribe(req pubsub.SubscribeRequest, appHandler func(msg *pubsub.NewMessage) error) error {
+	rawPayload, err := contrib_metadata.IsRawPayload(req.Metadata)
+	if err != nil {
+		return fmt.Errorf("%s %s", errorMessagePrefix, err)
+	}
+
+	sessions := false
+	if val, ok := req.Metadata[requireSessions]; ok && val != "" {
+		sessions, err = strconv.ParseBool(val)
+		if err != nil {
+			return fmt.Errorf("%s invalid %s %s, %s", errorMessagePrefix, requireSessions, val, err)
+		}
+	}
+
+	rule, err := parseSubscriptionRule(req.Metadata)
+	if err != nil {
+		return err
+	}
+
 	subID := a.metadata.ConsumerID
 	if !a.metadata.DisableEntityManagement {
-		err := a.ensureSubscription(subID, req.Topic)
+		err := a.ensureSubscription(subID, req.Topic, sessions, rule)
 		if err != nil {
 			return err
 		}
+	} else if rule != nil {
+		return fmt.Errorf("%s subscription filters require entity management", errorMessagePrefix)
 	}
 
 	go func() {
@@ -302,7 +455,7 @@ func (a *azureServiceBus) Subscribe(req pubsub.SubscribeRequest, appHandler func
 
 				return
 			}
-			sub := newSubscription(req.Topic, subEntity, a.metadata.MaxConcurrentHandlers, a.logger)
+			sub := newSubscription(req.Topic, subEntity, a.metadata.MaxConcurrentHandlers, rawPayload, a.metadata.DeadLetter, a.metadata.MaxExtensions, a, a.logger)
 			a.subscriptions = append(a.subscriptions, sub)
 			// ReceiveAndBlock will only return with an error
 			// that it cannot handle internally. The subscription
@@ -311,13 +464,24 @@ func (a *azureServiceBus) Subscribe(req pubsub.SubscribeRequest, appHandler func
 			// to re-establish the subscription connection until
 			// we exhaust the number of reconnect attempts.
 			ctx, cancel := context.WithCancel(context.Background())
-			innerErr := sub.ReceiveAndBlock(ctx,
-				appHandler,
-				a.metadata.LockRenewalInSec,
-				a.metadata.HandlerTimeoutInSec,
-				a.metadata.TimeoutInSec,
-				a.metadata.MaxActiveMessages,
-				a.metadata.MaxActiveMessagesRecoveryInSec)
+			var innerErr error
+			if sessions {
+				innerErr = sub.ReceiveSessionsAndBlock(ctx,
+					appHandler,
+					a.metadata.MaxConcurrentSessions,
+					a.metadata.SessionIdleTimeoutInSec,
+					a.metadata.LockRenewalInSec,
+					a.metadata.HandlerTimeoutInSec,
+					a.metadata.TimeoutInSec)
+			} else {
+				innerErr = sub.ReceiveAndBlock(ctx,
+					appHandler,
+					a.metadata.LockRenewalInSec,
+					a.metadata.HandlerTimeoutInSec,
+					a.metadata.TimeoutInSec,
+					a.metadata.MaxActiveMessages,
+					a.metadata.MaxActiveMessagesRecoveryInSec)
+			}
 			if innerErr != nil {
 				a.logger.Error(innerErr)
 			}
@@ -355,7 +519,7 @@ func (a *azureServiceBus) ensureTopic(topic string) error {
 	return nil
 }
 
-func (a *azureServiceBus) ensureSubscription(name string, topic string) error {
+func (a *azureServiceBus) ensureSubscription(name string, topic string, sessions bool, rule *subscriptionRule) error {
 	err := a.ensureTopic(topic)
 	if err != nil {
 		return err
@@ -372,13 +536,16 @@ func (a *azureServiceBus) ensureSubscription(name string, topic string) error {
 	}
 
 	if entity == nil {
-		err = a.createSubscriptionEntity(subManager, topic, name)
+		err = a.createSubscriptionEntity(subManager, topic, name, sessions)
 		if err != nil {
 			return err
 		}
+	} else if sessions && (entity.RequiresSession == nil || !*entity.RequiresSession) {
+		// sessions can only be enabled when subscriptions are created
+		return fmt.Errorf("%s subscription %s to topic %s does not require sessions, it must be deleted to enable them", errorMessagePrefix, name, topic)
 	}
 
-	return nil
+	return a.ensureSubscriptionRules(subManager, name, rule)
 }
 
 func (a *azureServiceBus) getTopicEntity(topic string) (*azservicebus.TopicEntity, error) {
@@ -418,7 +585,7 @@ func (a *azureServiceBus) getSubscriptionEntity(mgr *azservicebus.SubscriptionMa
 	return entity, nil
 }
 
-func (a *azureServiceBus) createSubscriptionEntity(mgr *azservicebus.SubscriptionManager, topic, subscription string) error {
+func (a *azureServiceBus) createSubscriptionEntity(mgr *azservicebus.SubscriptionManager, topic, subscription string, sessions bool) error {
 	ctx, cancel := context.WithTimeout(context.Background(), time.Second*time.Duration(a.metadata.TimeoutInSec))
 	defer cancel()
 
@@ -426,6 +593,9 @@ func (a *azureServiceBus) createSubscriptionEntity(mgr *azservicebus.Subscriptio
 	if err != nil {
 		return err
 	}
+	if sessions {
+		opts = append(opts, subscriptionManagementOptionsWithRequiresSession())
+	}
 
 	_, err = mgr.Put(ctx, subscription, opts...)
 	if err != nil {
@@ -492,6 +662,15 @@ func subscriptionManagementOptionsWithDefaultMessageTimeToLive(durationInSec *in
 	}
 }
 
+func subscriptionManagementOptionsWithRequiresSession() azservicebus.SubscriptionManagementOption {
+	return func(d *azservicebus.SubscriptionDescription) error {
+		requiresSession := true
+		d.RequiresSession = &requiresSession
+
+		return nil
+	}
+}
+
 func subscriptionManagementOptionsWithLockDuration(durationInSec *int) azservicebus.SubscriptionManagementOption {
 	return func(d *azservicebus.SubscriptionDescription) error {
 		duration := fmt.Sprintf("PT%dS", *durationInSec)
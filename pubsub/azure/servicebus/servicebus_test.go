@@ -6,8 +6,11 @@
 package servicebus
 
 import (
+	"strings"
 	"testing"
 
+	azservicebus "github.com/Azure/azure-service-bus-go"
+	"github.com/Azure/go-autorest/autorest/azure"
 	"github.com/dapr/components-contrib/pubsub"
 	"github.com/stretchr/testify/assert"
 )
@@ -92,6 +95,86 @@ func TestParseServiceBusMetadata(t *testing.T) {
 		assert.Empty(t, m.ConnectionString)
 	})
 
+	t.Run("valid namespaceName with managed identity", func(t *testing.T) {
+		fakeProperties := getFakeProperties()
+
+		fakeMetaData := pubsub.Metadata{
+			Properties: fakeProperties,
+		}
+		fakeMetaData.Properties[connectionString] = ""
+		fakeMetaData.Properties[namespaceName] = "fakeNamespace.servicebus.windows.net"
+		fakeMetaData.Properties[azureClientID] = "fakeClientId"
+
+		// act
+		m, err := parseAzureServiceBusMetadata(fakeMetaData)
+
+		// assert
+		assert.Nil(t, err)
+		assert.Empty(t, m.ConnectionString)
+		assert.Equal(t, "fakeNamespace", m.NamespaceName)
+		assert.Equal(t, "fakeClientId", m.AzureClientID)
+		assert.Equal(t, defaultAzureEnvironment, m.AzureEnvironment)
+	})
+
+	t.Run("valid namespaceName with service principal", func(t *testing.T) {
+		fakeProperties := getFakeProperties()
+
+		fakeMetaData := pubsub.Metadata{
+			Properties: fakeProperties,
+		}
+		fakeMetaData.Properties[connectionString] = ""
+		fakeMetaData.Properties[namespaceName] = "fakeNamespace"
+		fakeMetaData.Properties[azureTenantID] = "fakeTenantId"
+		fakeMetaData.Properties[azureClientID] = "fakeClientId"
+		fakeMetaData.Properties[azureClientSecret] = "fakeClientSecret"
+		fakeMetaData.Properties[azureEnvironment] = "AzureChinaCloud"
+
+		// act
+		m, err := parseAzureServiceBusMetadata(fakeMetaData)
+
+		// assert
+		assert.Nil(t, err)
+		assert.Equal(t, "fakeNamespace", m.NamespaceName)
+		assert.Equal(t, "fakeTenantId", m.AzureTenantID)
+		assert.Equal(t, "fakeClientSecret", m.AzureClientSecret)
+		assert.Equal(t, "AzureChinaCloud", m.AzureEnvironment)
+	})
+
+	t.Run("client secret without tenant", func(t *testing.T) {
+		fakeProperties := getFakeProperties()
+
+		fakeMetaData := pubsub.Metadata{
+			Properties: fakeProperties,
+		}
+		fakeMetaData.Properties[connectionString] = ""
+		fakeMetaData.Properties[namespaceName] = "fakeNamespace"
+		fakeMetaData.Properties[azureClientID] = "fakeClientId"
+		fakeMetaData.Properties[azureClientSecret] = "fakeClientSecret"
+
+		// act
+		_, err := parseAzureServiceBusMetadata(fakeMetaData)
+
+		// assert
+		assert.Error(t, err)
+		assertValidErrorMessage(t, err)
+	})
+
+	t.Run("both connectionString and namespaceName", func(t *testing.T) {
+		fakeProperties := getFakeProperties()
+
+		fakeMetaData := pubsub.Metadata{
+			Properties: fakeProperties,
+		}
+		fakeMetaData.Properties[namespaceName] = "fakeNamespace"
+
+		// act
+		_, err := parseAzureServiceBusMetadata(fakeMetaData)
+
+		// assert
+		assert.Error(t, err)
+		assertValidErrorMessage(t, err)
+	})
+
 	t.Run("missing required consumerID", func(t *testing.T) {
 		fakeProperties := getFakeProperties()
 
@@ -492,6 +575,282 @@ func TestParseServiceBusMetadata(t *testing.T) {
 		assert.Error(t, err)
 		assertValidErrorMessage(t, err)
 	})
+
+	t.Run("missing optional contentMode", func(t *testing.T) {
+		fakeProperties := getFakeProperties()
+
+		fakeMetaData := pubsub.Metadata{
+			Properties: fakeProperties,
+		}
+
+		// act
+		m, err := parseAzureServiceBusMetadata(fakeMetaData)
+
+		// assert
+		assert.Equal(t, pubsub.ContentModeStructured, m.ContentMode)
+		assert.Nil(t, err)
+	})
+
+	t.Run("invalid optional contentMode", func(t *testing.T) {
+		fakeProperties := getFakeProperties()
+
+		fakeMetaData := pubsub.Metadata{
+			Properties: fakeProperties,
+		}
+		fakeMetaData.Properties[pubsub.ContentModeMetadataKey] = "batched"
+
+		// act
+		_, err := parseAzureServiceBusMetadata(fakeMetaData)
+
+		// assert
+		assert.Error(t, err)
+		assertValidErrorMessage(t, err)
+	})
+
+	t.Run("valid optional compression", func(t *testing.T) {
+		fakeProperties := getFakeProperties()
+
+		fakeMetaData := pubsub.Metadata{
+			Properties: fakeProperties,
+		}
+		fakeMetaData.Properties[pubsub.CompressionMetadataKey] = "zstd"
+
+		// act
+		m, err := parseAzureServiceBusMetadata(fakeMetaData)
+
+		// assert
+		assert.Equal(t, pubsub.CompressionZstd, m.Compression)
+		assert.Nil(t, err)
+	})
+
+	t.Run("invalid optional compression", func(t *testing.T) {
+		fakeProperties := getFakeProperties()
+
+		fakeMetaData := pubsub.Metadata{
+			Properties: fakeProperties,
+		}
+		fakeMetaData.Properties[pubsub.CompressionMetadataKey] = "lz4"
+
+		// act
+		_, err := parseAzureServiceBusMetadata(fakeMetaData)
+
+		// assert
+		assert.Error(t, err)
+		assertValidErrorMessage(t, err)
+	})
+
+	t.Run("default session settings", func(t *testing.T) {
+		fakeProperties := getFakeProperties()
+
+		fakeMetaData := pubsub.Metadata{
+			Properties: fakeProperties,
+		}
+
+		// act
+		m, err := parseAzureServiceBusMetadata(fakeMetaData)
+
+		// assert
+		assert.Nil(t, err)
+		assert.Equal(t, defaultMaxConcurrentSessions, m.MaxConcurrentSessions)
+		assert.Equal(t, defaultSessionIdleTimeoutInSec, m.SessionIdleTimeoutInSec)
+	})
+
+	t.Run("valid optional session settings", func(t *testing.T) {
+		fakeProperties := getFakeProperties()
+
+		fakeMetaData := pubsub.Metadata{
+			Properties: fakeProperties,
+		}
+		fakeMetaData.Properties[maxConcurrentSessions] = "2"
+		fakeMetaData.Properties[sessionIdleTimeoutInSec] = "10"
+
+		// act
+		m, err := parseAzureServiceBusMetadata(fakeMetaData)
+
+		// assert
+		assert.Nil(t, err)
+		assert.Equal(t, 2, m.MaxConcurrentSessions)
+		assert.Equal(t, 10, m.SessionIdleTimeoutInSec)
+	})
+
+	for _, key := range []string{maxConcurrentSessions, sessionIdleTimeoutInSec} {
+		key := key
+		t.Run("invalid optional "+key, func(t *testing.T) {
+			fakeProperties := getFakeProperties()
+
+			fakeMetaData := pubsub.Metadata{
+				Properties: fakeProperties,
+			}
+			fakeMetaData.Properties[key] = "0"
+
+			// act
+			_, err := parseAzureServiceBusMetadata(fakeMetaData)
+
+			// assert
+			assert.Error(t, err)
+			assertValidErrorMessage(t, err)
+		})
+	}
+
+	t.Run("valid optional deadLetterTopic", func(t *testing.T) {
+		fakeProperties := getFakeProperties()
+
+		fakeMetaData := pubsub.Metadata{
+			Properties: fakeProperties,
+		}
+		fakeMetaData.Properties[pubsub.DeadLetterTopicMetadataKey] = "poison"
+		fakeMetaData.Properties[pubsub.MaxDeliveryAttemptsMetadataKey] = "5"
+
+		// act
+		m, err := parseAzureServiceBusMetadata(fakeMetaData)
+
+		// assert
+		assert.Equal(t, pubsub.DeadLetterConfig{Topic: "poison", MaxDeliveryAttempts: 5}, m.DeadLetter)
+		assert.Nil(t, err)
+	})
+
+	t.Run("maxDeliveryAttempts exceeding maxDeliveryCount", func(t *testing.T) {
+		fakeProperties := getFakeProperties()
+
+		fakeMetaData := pubsub.Metadata{
+			Properties: fakeProperties,
+		}
+		fakeMetaData.Properties[maxDeliveryCount] = "3"
+		fakeMetaData.Properties[pubsub.DeadLetterTopicMetadataKey] = "poison"
+		fakeMetaData.Properties[pubsub.MaxDeliveryAttemptsMetadataKey] = "5"
+
+		// act
+		_, err := parseAzureServiceBusMetadata(fakeMetaData)
+
+		// assert
+		assert.Error(t, err)
+		assertValidErrorMessage(t, err)
+	})
+}
+
+func TestNewMessage(t *testing.T) {
+	t.Run("binary cloud event", func(t *testing.T) {
+		message := azservicebus.NewMessage([]byte("text"))
+		message.ContentType = "text/plain"
+		message.UserProperties = map[string]interface{}{
+			"cloudEvents_specversion": "1.0",
+			"cloudEvents_id":          "a",
+		}
+
+		msg, err := newMessage("topic", message, false, 0)
+		assert.NoError(t, err)
+		assert.Equal(t, "topic", msg.Topic)
+
+		e, err := pubsub.FromCloudEvent(msg.Data, "")
+		assert.NoError(t, err)
+		assert.Equal(t, "a", e[pubsub.IDField])
+		assert.Equal(t, "text", e[pubsub.DataField])
+	})
+
+	t.Run("structured cloud event", func(t *testing.T) {
+		msg, err := newMessage("topic", azservicebus.NewMessage([]byte(`{"specversion":"1.0"}`)), false, 0)
+		assert.NoError(t, err)
+		assert.Equal(t, `{"specversion":"1.0"}`, string(msg.Data))
+	})
+
+	t.Run("raw payload", func(t *testing.T) {
+		message := azservicebus.NewMessage([]byte("text"))
+		message.UserProperties = map[string]interface{}{"cloudEvents_specversion": "1.0"}
+
+		msg, err := newMessage("topic", message, true, 0)
+		assert.NoError(t, err)
+		assert.Equal(t, "text", string(msg.Data))
+	})
+}
+
+func TestParseSubscriptionRule(t *testing.T) {
+	t.Run("no filter", func(t *testing.T) {
+		rule, err := parseSubscriptionRule(map[string]string{requireSessions: "true"})
+		assert.NoError(t, err)
+		assert.Nil(t, rule)
+	})
+
+	t.Run("sql filter", func(t *testing.T) {
+		rule, err := parseSubscriptionRule(map[string]string{sqlFilter: "region = 'eu'"})
+		assert.NoError(t, err)
+		assert.Equal(t, azservicebus.SQLFilter{Expression: "region = 'eu'"}, rule.filter)
+		assert.True(t, strings.HasPrefix(rule.name, filterRulePrefix))
+
+		other, err := parseSubscriptionRule(map[string]string{sqlFilter: "region = 'us'"})
+		assert.NoError(t, err)
+		assert.NotEqual(t, rule.name, other.name)
+	})
+
+	t.Run("correlation filter", func(t *testing.T) {
+		rule, err := parseSubscriptionRule(map[string]string{
+			"correlationFilter.label":             "order",
+			"correlationFilter.contentType":       "application/json",
+			"correlationFilter.properties.region": "eu",
+		})
+		assert.NoError(t, err)
+
+		filter, ok := rule.filter.(azservicebus.CorrelationFilter)
+		assert.True(t, ok)
+		assert.Equal(t, "order", *filter.Label)
+		assert.Equal(t, "application/json", *filter.ContentType)
+		assert.Nil(t, filter.CorrelationID)
+		assert.Equal(t, map[string]interface{}{"region": "eu"}, filter.Properties)
+
+		same, err := parseSubscriptionRule(map[string]string{
+			"correlationFilter.properties.region": "eu",
+			"correlationFilter.contentType":       "application/json",
+			"correlationFilter.label":             "order",
+		})
+		assert.NoError(t, err)
+		assert.Equal(t, rule.name, same.name)
+	})
+
+	t.Run("invalid correlation filter", func(t *testing.T) {
+		_, err := parseSubscriptionRule(map[string]string{"correlationFilter.unknown": "a"})
+		assert.Error(t, err)
+		assertValidErrorMessage(t, err)
+	})
+
+	t.Run("sql and correlation filters", func(t *testing.T) {
+		_, err := parseSubscriptionRule(map[string]string{
+			sqlFilter:                 "region = 'eu'",
+			"correlationFilter.label": "order",
+		})
+		assert.Error(t, err)
+		assertValidErrorMessage(t, err)
+	})
+}
+
+func TestNamespaceWithTokenProvider(t *testing.T) {
+	t.Run("public cloud", func(t *testing.T) {
+		m := metadata{NamespaceName: "fakeNamespace", AzureEnvironment: defaultAzureEnvironment}
+
+		ns, err := azservicebus.NewNamespace(namespaceWithTokenProvider(m, nil))
+
+		assert.Nil(t, err)
+		assert.Equal(t, "fakeNamespace", ns.Name)
+		assert.Equal(t, "servicebus.windows.net", ns.Suffix)
+		assert.Equal(t, azure.PublicCloud.Name, ns.Environment.Name)
+	})
+
+	t.Run("national cloud", func(t *testing.T) {
+		m := metadata{NamespaceName: "fakeNamespace", AzureEnvironment: "AzureChinaCloud"}
+
+		ns, err := azservicebus.NewNamespace(namespaceWithTokenProvider(m, nil))
+
+		assert.Nil(t, err)
+		assert.Equal(t, "servicebus.chinacloudapi.cn", ns.Suffix)
+		assert.Equal(t, azure.ChinaCloud.Name, ns.Environment.Name)
+	})
+
+	t.Run("invalid environment", func(t *testing.T) {
+		m := metadata{NamespaceName: "fakeNamespace", AzureEnvironment: "invalid"}
+
+		_, err := azservicebus.NewNamespace(namespaceWithTokenProvider(m, nil))
+
+		assert.Error(t, err)
+		assertValidErrorMessage(t, err)
+	})
 }
 
 func assertValidErrorMessage(t *testing.T, err error) {
@@ -0,0 +1,141 @@
+// ------------------------------------------------------------
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT License.
+// ------------------------------------------------------------
+
+package servicebus
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	azservicebus "github.com/Azure/azure-service-bus-go"
+	"github.com/dapr/components-contrib/pubsub"
+)
+
+// ReceiveSessionsAndBlock is a blocking call to receive the messages of a session enabled subscription.
+// Up to maxConcurrentSessions sessions are accepted at once. The messages of a session are handled one after
+// the other, in order, and the session is released once it has no message for the idle timeout,
+// so that the next available session is accepted.
+func (s *subscription) ReceiveSessionsAndBlock(ctx context.Context, appHandler func(msg *pubsub.NewMessage) error, maxConcurrentSessions int, sessionIdleTimeoutInSec int, lockRenewalInSec int, handlerTimeoutInSec int, timeoutInSec int) error {
+	// Close subscription
+	defer func() {
+		closeCtx, closeCancel := context.WithTimeout(context.Background(), time.Second*time.Duration(timeoutInSec))
+		defer closeCancel()
+		s.close(closeCtx)
+	}()
+
+	handlerFunc := s.getHandlerFunc(appHandler, handlerTimeoutInSec, timeoutInSec)
+
+	var wg sync.WaitGroup
+	for i := 0; i < maxConcurrentSessions; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			for ctx.Err() == nil {
+				h := &sessionHandler{
+					handlerFunc:  handlerFunc,
+					topic:        s.topic,
+					idleTimeout:  time.Second * time.Duration(sessionIdleTimeoutInSec),
+					lockRenewal:  time.Second * time.Duration(lockRenewalInSec),
+					subscription: s,
+				}
+				s.receiveSession(ctx, h, timeoutInSec)
+			}
+		}()
+	}
+	wg.Wait()
+
+	return ctx.Err()
+}
+
+// receiveSession accepts the next available session and handles its messages until it is released
+func (s *subscription) receiveSession(ctx context.Context, h *sessionHandler, timeoutInSec int) {
+	s.logger.Debugf("Waiting to accept a session on topic %s", s.topic)
+	session := s.entity.NewSession(nil)
+	err := session.ReceiveOne(ctx, h)
+
+	closeCtx, closeCancel := context.WithTimeout(context.Background(), time.Second*time.Duration(timeoutInSec))
+	defer closeCancel()
+	if closeErr := session.Close(closeCtx); closeErr != nil {
+		s.logger.Debugf("%s closing session receiver for topic %s: %s", errorMessagePrefix, s.topic, closeErr)
+	}
+
+	if err != nil && ctx.Err() == nil {
+		// accepting a session times out when none is available, and the receiver link is re-established on failures
+		s.logger.Debugf("%s error receiving session on topic %s, %s", errorMessagePrefix, s.topic, err)
+		select {
+		case <-ctx.Done():
+		case <-time.After(time.Second * connectionRecoveryInSec):
+		}
+	}
+}
+
+// sessionHandler handles the messages of a session in order, and renews the session lock meanwhile
+type sessionHandler struct {
+	handlerFunc  azservicebus.HandlerFunc
+	topic        string
+	idleTimeout  time.Duration
+	lockRenewal  time.Duration
+	subscription *subscription
+
+	session *azservicebus.MessageSession
+	idle    *time.Timer
+	done    chan struct{}
+}
+
+// Start is called when a session is accepted
+func (h *sessionHandler) Start(session *azservicebus.MessageSession) error {
+	h.session = session
+	h.done = make(chan struct{})
+	h.idle = time.AfterFunc(h.idleTimeout, func() {
+		h.subscription.logger.Debugf("Releasing idle session %s on topic %s", h.sessionID(), h.topic)
+		session.Close()
+	})
+	h.subscription.logger.Debugf("Accepted session %s on topic %s", h.sessionID(), h.topic)
+
+	if h.lockRenewal > 0 {
+		go h.renewLock()
+	}
+
+	return nil
+}
+
+// Handle handles a message of the session, the next message is not received before it returns
+func (h *sessionHandler) Handle(ctx context.Context, message *azservicebus.Message) error {
+	h.idle.Stop()
+	defer h.idle.Reset(h.idleTimeout)
+
+	return h.handlerFunc(ctx, message)
+}
+
+// End is called when the session is released
+func (h *sessionHandler) End() {
+	h.idle.Stop()
+	close(h.done)
+	h.subscription.logger.Debugf("Released session %s on topic %s", h.sessionID(), h.topic)
+}
+
+func (h *sessionHandler) renewLock() {
+	for {
+		select {
+		case <-h.done:
+			return
+		case <-time.After(h.lockRenewal):
+			// Lock renewal is best effort, the session is accepted again once released if it fails.
+			if err := h.session.RenewLock(context.Background()); err != nil {
+				h.subscription.logger.Warnf("Couldn't renew the lock of session %s on topic %s, %s", h.sessionID(), h.topic, err)
+			}
+		}
+	}
+}
+
+func (h *sessionHandler) sessionID() string {
+	if id := h.session.SessionID(); id != nil {
+		return *id
+	}
+
+	return ""
+}
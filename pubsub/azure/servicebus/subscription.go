@@ -18,14 +18,22 @@ type subscription struct {
 	entity                  *azservicebus.Subscription
 	limitConcurrentHandlers bool
 	handlerChan             chan handler
+	rawPayload              bool
+	deadLetter              pubsub.DeadLetterConfig
+	maxExtensions           int
+	publisher               pubsub.PubSub
 	logger                  logger.Logger
 }
 
-func newSubscription(topic string, sub *azservicebus.Subscription, maxConcurrentHandlers *int, logger logger.Logger) *subscription {
+func newSubscription(topic string, sub *azservicebus.Subscription, maxConcurrentHandlers *int, rawPayload bool, deadLetter pubsub.DeadLetterConfig, maxExtensions int, publisher pubsub.PubSub, logger logger.Logger) *subscription {
 	s := &subscription{
 		topic:          topic,
 		activeMessages: make(map[string]*azservicebus.Message),
 		entity:         sub,
+		rawPayload:     rawPayload,
+		deadLetter:     deadLetter,
+		maxExtensions:  maxExtensions,
+		publisher:      publisher,
 		logger:         logger,
 	}
 
@@ -108,9 +116,14 @@ func (s *subscription) close(ctx context.Context) {
 
 func (s *subscription) getHandlerFunc(appHandler func(msg *pubsub.NewMessage) error, handlerTimeoutInSec int, timeoutInSec int) azservicebus.HandlerFunc {
 	return func(ctx context.Context, message *azservicebus.Message) error {
-		msg := &pubsub.NewMessage{
-			Data:  message.Data,
-			Topic: s.topic,
+		msg, err := newMessage(s.topic, message, s.rawPayload, s.maxExtensions)
+		if err != nil {
+			s.logger.Warnf("%s %s", errorMessagePrefix, err)
+			if s.shouldDeadLetter(message) {
+				return s.deadLetterMessage(ctx, message, &pubsub.NewMessage{Data: message.Data, Topic: s.topic})
+			}
+
+			return s.abandonMessage(ctx, message)
 		}
 
 		// TODO(#1721): Context should be propogated to the app handler call for timeout and cancellation.
@@ -128,6 +141,9 @@ func (s *subscription) getHandlerFunc(appHandler func(msg *pubsub.NewMessage) er
 
 		if appErr != nil {
 			s.logger.Warnf("Error in app's handler: %+v", appErr)
+			if s.shouldDeadLetter(message) {
+				return s.deadLetterMessage(finalizeCtx, message, msg)
+			}
 			if abandonErr := s.abandonMessage(finalizeCtx, message); abandonErr != nil {
 				return fmt.Errorf("failed to abandon: %+v", abandonErr)
 			}
@@ -217,6 +233,28 @@ func (s *subscription) abandonMessage(ctx context.Context, m *azservicebus.Messa
 	return m.Abandon(ctx)
 }
 
+// shouldDeadLetter tells whether a message that failed is to be published to the dead letter topic
+// rather than abandoned to be delivered again
+func (s *subscription) shouldDeadLetter(m *azservicebus.Message) bool {
+	return s.deadLetter.Enabled() && s.deadLetter.Exhausted(int(m.DeliveryCount))
+}
+
+func (s *subscription) deadLetterMessage(ctx context.Context, m *azservicebus.Message, msg *pubsub.NewMessage) error {
+	s.logger.Debugf("Publishing message %s on topic %s to dead letter topic %s", m.ID, s.topic, s.deadLetter.Topic)
+	if err := s.deadLetter.DeadLetter(s.publisher, msg); err != nil {
+		if abandonErr := s.abandonMessage(ctx, m); abandonErr != nil {
+			return fmt.Errorf("failed to abandon: %+v", abandonErr)
+		}
+
+		return err
+	}
+	if completeErr := s.completeMessage(ctx, m); completeErr != nil {
+		return fmt.Errorf("failed to complete: %+v", completeErr)
+	}
+
+	return nil
+}
+
 func (s *subscription) completeMessage(ctx context.Context, m *azservicebus.Message) error {
 	s.logger.Debugf("Completing message %s on topic %s", m.ID, s.topic)
 
@@ -236,3 +274,37 @@ func (s *subscription) removeActiveMessage(messageID string) {
 	delete(s.activeMessages, messageID)
 	s.mu.Unlock()
 }
+
+// newMessage returns the message for a Service Bus message, converting cloud events in binary content mode to structured ones
+// and decompressing their data, unless the raw payload is requested.
+// Binary mode cloud events with more than maxExtensions extension attributes are rejected, 0 disables the limit.
+func newMessage(topic string, message *azservicebus.Message, rawPayload bool, maxExtensions int) (*pubsub.NewMessage, error) {
+	if rawPayload {
+		return &pubsub.NewMessage{
+			Data:  message.Data,
+			Topic: topic,
+		}, nil
+	}
+
+	headers := make(map[string]string, len(message.UserProperties))
+	for key, value := range message.UserProperties {
+		if s, ok := value.(string); ok {
+			headers[key] = s
+		}
+	}
+
+	data, _, err := pubsub.FromBinaryMessage(headers, ceHeaderPrefix, message.ContentType, message.Data, maxExtensions)
+	if err != nil {
+		return nil, fmt.Errorf("invalid binary cloud event %s: %s", message.ID, err)
+	}
+
+	data, err = pubsub.DecompressCloudEvent(data)
+	if err != nil {
+		return nil, fmt.Errorf("invalid cloud event %s: %s", message.ID, err)
+	}
+
+	return &pubsub.NewMessage{
+		Data:  data,
+		Topic: topic,
+	}, nil
+}
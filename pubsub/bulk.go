@@ -0,0 +1,159 @@
+// ------------------------------------------------------------
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT License.
+// ------------------------------------------------------------
+
+package pubsub
+
+import (
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	// BulkSubscribeMaxCountMetadataKey is the subscription metadata key setting the maximum number of messages of a bulk message
+	BulkSubscribeMaxCountMetadataKey = "maxBulkSubCount"
+	// BulkSubscribeMaxAwaitDurationMetadataKey is the subscription metadata key setting how long, in milliseconds,
+	// messages are awaited before a bulk message that is not full is delivered
+	BulkSubscribeMaxAwaitDurationMetadataKey = "maxBulkSubAwaitDurationMs"
+
+	// DefaultBulkSubscribeMaxCount is the default maximum number of messages of a bulk message
+	DefaultBulkSubscribeMaxCount = 100
+	// DefaultBulkSubscribeMaxAwaitDuration is the default time messages are awaited before a bulk message is delivered
+	DefaultBulkSubscribeMaxAwaitDuration = time.Second
+)
+
+// BulkPublisher is the interface for message buses able to publish many messages in one round trip
+type BulkPublisher interface {
+	BulkPublish(req *BulkPublishRequest) (BulkPublishResponse, error)
+}
+
+// BulkSubscriber is the interface for message buses able to deliver many messages at once.
+// The handler acknowledges the messages of a bulk message but the failed entries of its response,
+// or none of them when it returns an error.
+type BulkSubscriber interface {
+	BulkSubscribe(req SubscribeRequest, handler func(msg *BulkMessage) (BulkSubscribeResponse, error)) error
+}
+
+// BulkSubscribeConfig is the configuration of a bulk subscription
+type BulkSubscribeConfig struct {
+	MaxCount         int
+	MaxAwaitDuration time.Duration
+}
+
+// ParseBulkSubscribeConfig returns the bulk subscription configuration set in the subscription metadata
+func ParseBulkSubscribeConfig(metadata map[string]string) (BulkSubscribeConfig, error) {
+	c := BulkSubscribeConfig{
+		MaxCount:         DefaultBulkSubscribeMaxCount,
+		MaxAwaitDuration: DefaultBulkSubscribeMaxAwaitDuration,
+	}
+
+	if val, ok := metadata[BulkSubscribeMaxCountMetadataKey]; ok && val != "" {
+		count, err := strconv.Atoi(val)
+		if err != nil || count <= 0 {
+			return c, fmt.Errorf("%s value must be a positive integer: actual is '%s'", BulkSubscribeMaxCountMetadataKey, val)
+		}
+		c.MaxCount = count
+	}
+
+	if val, ok := metadata[BulkSubscribeMaxAwaitDurationMetadataKey]; ok && val != "" {
+		ms, err := strconv.Atoi(val)
+		if err != nil || ms <= 0 {
+			return c, fmt.Errorf("%s value must be a positive integer: actual is '%s'", BulkSubscribeMaxAwaitDurationMetadataKey, val)
+		}
+		c.MaxAwaitDuration = time.Duration(ms) * time.Millisecond
+	}
+
+	return c, nil
+}
+
+// DefaultBulkSubscriber is a default implementation of BulkSubscriber for message buses without native batching
+type DefaultBulkSubscriber struct {
+	p PubSub
+}
+
+// NewDefaultBulkSubscriber builds a bulk subscriber delivering the messages one by one
+func NewDefaultBulkSubscriber(pubsub PubSub) DefaultBulkSubscriber {
+	return DefaultBulkSubscriber{p: pubsub}
+}
+
+// BulkSubscribe delivers each message as a bulk message of a single entry
+func (b *DefaultBulkSubscriber) BulkSubscribe(req SubscribeRequest, handler func(msg *BulkMessage) (BulkSubscribeResponse, error)) error {
+	return b.p.Subscribe(req, func(msg *NewMessage) error {
+		res, err := handler(&BulkMessage{
+			Entries: []BulkMessageEntry{{
+				EntryID:  uuid.New().String(),
+				Event:    msg.Data,
+				Metadata: msg.Metadata,
+			}},
+			Topic:    msg.Topic,
+			Metadata: msg.Metadata,
+		})
+		if err != nil {
+			return err
+		}
+		if len(res.FailedEntries) > 0 {
+			return res.FailedEntries[0].Error
+		}
+
+		return nil
+	})
+}
+
+// DefaultBulkPublisher is a default implementation of BulkPublisher for message buses without native batching
+type DefaultBulkPublisher struct {
+	p PubSub
+}
+
+// NewDefaultBulkPublisher builds a bulk publisher publishing the entries one by one
+func NewDefaultBulkPublisher(pubsub PubSub) DefaultBulkPublisher {
+	return DefaultBulkPublisher{p: pubsub}
+}
+
+// BulkPublish publishes the entries one by one, the entries that fail are listed in the response
+func (b *DefaultBulkPublisher) BulkPublish(req *BulkPublishRequest) (BulkPublishResponse, error) {
+	res := BulkPublishResponse{}
+	for _, entry := range req.Entries {
+		err := b.p.Publish(&PublishRequest{
+			Data:       entry.Event,
+			PubsubName: req.PubsubName,
+			Topic:      req.Topic,
+			Metadata:   entry.mergedMetadata(req.Metadata),
+		})
+		if err != nil {
+			res.FailedEntries = append(res.FailedEntries, BulkPublishFailedEntry{EntryID: entry.EntryID, Error: err})
+		}
+	}
+
+	return res, nil
+}
+
+// NewBulkPublishErrorResponse returns the response of a bulk publish request that failed as a whole
+func NewBulkPublishErrorResponse(req *BulkPublishRequest, err error) BulkPublishResponse {
+	res := BulkPublishResponse{FailedEntries: make([]BulkPublishFailedEntry, len(req.Entries))}
+	for i, entry := range req.Entries {
+		res.FailedEntries[i] = BulkPublishFailedEntry{EntryID: entry.EntryID, Error: err}
+	}
+
+	return res
+}
+
+// mergedMetadata returns the request metadata overridden by the metadata of the entry
+func (e BulkMessageEntry) mergedMetadata(metadata map[string]string) map[string]string {
+	if len(e.Metadata) == 0 {
+		return metadata
+	}
+
+	merged := make(map[string]string, len(metadata)+len(e.Metadata))
+	for k, v := range metadata {
+		merged[k] = v
+	}
+	for k, v := range e.Metadata {
+		merged[k] = v
+	}
+
+	return merged
+}
@@ -0,0 +1,134 @@
+// ------------------------------------------------------------
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT License.
+// ------------------------------------------------------------
+
+package pubsub
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type fakePubSub struct {
+	published []*PublishRequest
+	handler   func(msg *NewMessage) error
+}
+
+func (f *fakePubSub) Init(metadata Metadata) error {
+	return nil
+}
+
+func (f *fakePubSub) Features() []Feature {
+	return nil
+}
+
+func (f *fakePubSub) Publish(req *PublishRequest) error {
+	if string(req.Data) == "fail" {
+		return errors.New("publish failed")
+	}
+	f.published = append(f.published, req)
+
+	return nil
+}
+
+func (f *fakePubSub) Subscribe(req SubscribeRequest, handler func(msg *NewMessage) error) error {
+	f.handler = handler
+
+	return nil
+}
+
+func (f *fakePubSub) Close() error {
+	return nil
+}
+
+func TestDefaultBulkPublisher(t *testing.T) {
+	p := &fakePubSub{}
+	b := NewDefaultBulkPublisher(p)
+
+	res, err := b.BulkPublish(&BulkPublishRequest{
+		Entries: []BulkMessageEntry{
+			{EntryID: "1", Event: []byte("a"), Metadata: map[string]string{"ttlInSeconds": "10"}},
+			{EntryID: "2", Event: []byte("fail")},
+			{EntryID: "3", Event: []byte("c")},
+		},
+		PubsubName: "mypubsub",
+		Topic:      "topic",
+		Metadata:   map[string]string{"ttlInSeconds": "5", "other": "a"},
+	})
+	assert.NoError(t, err)
+	assert.Len(t, res.FailedEntries, 1)
+	assert.Equal(t, "2", res.FailedEntries[0].EntryID)
+	assert.Error(t, res.FailedEntries[0].Error)
+
+	assert.Len(t, p.published, 2)
+	assert.Equal(t, "topic", p.published[0].Topic)
+	assert.Equal(t, "mypubsub", p.published[0].PubsubName)
+	assert.Equal(t, map[string]string{"ttlInSeconds": "10", "other": "a"}, p.published[0].Metadata)
+	assert.Equal(t, map[string]string{"ttlInSeconds": "5", "other": "a"}, p.published[1].Metadata)
+}
+
+func TestNewBulkPublishErrorResponse(t *testing.T) {
+	err := errors.New("failed")
+	res := NewBulkPublishErrorResponse(&BulkPublishRequest{
+		Entries: []BulkMessageEntry{{EntryID: "1"}, {EntryID: "2"}},
+	}, err)
+	assert.Equal(t, []BulkPublishFailedEntry{{EntryID: "1", Error: err}, {EntryID: "2", Error: err}}, res.FailedEntries)
+}
+
+func TestParseBulkSubscribeConfig(t *testing.T) {
+	t.Run("defaults", func(t *testing.T) {
+		c, err := ParseBulkSubscribeConfig(map[string]string{})
+		assert.NoError(t, err)
+		assert.Equal(t, BulkSubscribeConfig{MaxCount: DefaultBulkSubscribeMaxCount, MaxAwaitDuration: DefaultBulkSubscribeMaxAwaitDuration}, c)
+	})
+
+	t.Run("configured", func(t *testing.T) {
+		c, err := ParseBulkSubscribeConfig(map[string]string{BulkSubscribeMaxCountMetadataKey: "10", BulkSubscribeMaxAwaitDurationMetadataKey: "50"})
+		assert.NoError(t, err)
+		assert.Equal(t, BulkSubscribeConfig{MaxCount: 10, MaxAwaitDuration: 50 * time.Millisecond}, c)
+	})
+
+	t.Run("invalid", func(t *testing.T) {
+		_, err := ParseBulkSubscribeConfig(map[string]string{BulkSubscribeMaxCountMetadataKey: "0"})
+		assert.Error(t, err)
+
+		_, err = ParseBulkSubscribeConfig(map[string]string{BulkSubscribeMaxAwaitDurationMetadataKey: "a"})
+		assert.Error(t, err)
+	})
+}
+
+func TestDefaultBulkSubscriber(t *testing.T) {
+	p := &fakePubSub{}
+	b := NewDefaultBulkSubscriber(p)
+
+	var received *BulkMessage
+	err := b.BulkSubscribe(SubscribeRequest{Topic: "topic"}, func(msg *BulkMessage) (BulkSubscribeResponse, error) {
+		received = msg
+		if string(msg.Entries[0].Event) == "fail" {
+			return BulkSubscribeResponse{FailedEntries: []BulkSubscribeFailedEntry{{EntryID: msg.Entries[0].EntryID, Error: errors.New("failed")}}}, nil
+		}
+
+		return BulkSubscribeResponse{}, nil
+	})
+	assert.NoError(t, err)
+
+	err = p.handler(&NewMessage{Topic: "topic", Data: []byte("a")})
+	assert.NoError(t, err)
+	assert.Equal(t, "topic", received.Topic)
+	assert.Len(t, received.Entries, 1)
+	assert.Equal(t, []byte("a"), received.Entries[0].Event)
+	assert.NotEmpty(t, received.Entries[0].EntryID)
+
+	err = p.handler(&NewMessage{Topic: "topic", Data: []byte("fail")})
+	assert.Error(t, err)
+}
+
+func TestBulkSubscribeResponseErrors(t *testing.T) {
+	err := errors.New("failed")
+	res := BulkSubscribeResponse{FailedEntries: []BulkSubscribeFailedEntry{{EntryID: "1", Error: err}}}
+	assert.Equal(t, map[string]error{"1": err}, res.Errors())
+}
@@ -0,0 +1,333 @@
+// ------------------------------------------------------------
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT License.
+// ------------------------------------------------------------
+
+package pubsub
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+
+	jsoniter "github.com/json-iterator/go"
+)
+
+// Attribute names of the cloudevents envelope
+const (
+	IDField              = "id"
+	SpecVersionField     = "specversion"
+	SourceField          = "source"
+	TypeField            = "type"
+	SubjectField         = "subject"
+	DataContentTypeField = "datacontenttype"
+	DataSchemaField      = "dataschema"
+	TimeField            = "time"
+	TopicField           = "topic"
+	PubsubNameField      = "pubsubname"
+	ExpirationField      = expirationField
+)
+
+// ErrTooManyExtensions is returned when a cloud event carries more extension attributes than a component accepts,
+// see MaxExtensionsMetadataKey.
+var ErrTooManyExtensions = errors.New("too many cloud event extension attributes")
+
+// CloudEvent is a typed representation of a cloudevents JSON envelope.
+type CloudEvent struct {
+	ID              string
+	SpecVersion     string
+	Source          string
+	Type            string
+	Subject         string
+	DataContentType string
+	DataSchema      string
+	Time            string
+	Topic           string
+	PubsubName      string
+	TraceID         string
+	TraceState      string
+	Expiration      string
+
+	// Data is the data attribute, either a string or a native JSON value.
+	// Unmarshal keeps JSON object and array data undecoded as a jsoniter.RawMessage, see DecodeData.
+	Data interface{}
+	// DataBase64 is the base64 encoded data_base64 attribute of binary payloads.
+	DataBase64 string
+
+	// Extensions holds all other attributes, including standard attributes
+	// that do not have the string type required by the spec.
+	Extensions map[string]interface{}
+
+	// emptyAttributes are the standard attributes that the event carries with an empty value
+	emptyAttributes map[string]struct{}
+}
+
+// NewCloudEvent returns a cloudevents envelope, applying the same defaults as NewCloudEventsEnvelope.
+func NewCloudEvent(id, source, eventType, subject string, topic string, pubsubName string, dataContentType string, data []byte, traceID string) *CloudEvent {
+	return NewCloudEventWithTraceState(id, source, eventType, subject, topic, pubsubName, dataContentType, data, traceID, "")
+}
+
+// NewCloudEventWithTraceState is NewCloudEvent with the W3C tracestate accompanying traceID.
+func NewCloudEventWithTraceState(id, source, eventType, subject string, topic string, pubsubName string, dataContentType string, data []byte, traceID string, traceState string) *CloudEvent {
+	binary := isBinaryData(dataContentType, data)
+	id, source, eventType, dataContentType = envelopeDefaults(id, source, eventType, dataContentType, data, binary)
+
+	e := &CloudEvent{
+		ID:              id,
+		SpecVersion:     CloudEventsSpecVersion,
+		Source:          source,
+		Type:            eventType,
+		Subject:         subject,
+		DataContentType: dataContentType,
+		Topic:           topic,
+		PubsubName:      pubsubName,
+		TraceID:         traceID,
+		TraceState:      traceState,
+	}
+	// The envelope always carries its baseline attributes, even when they are empty
+	for _, attr := range [...]struct{ name, value string }{
+		{DataContentTypeField, dataContentType},
+		{SubjectField, subject},
+		{TopicField, topic},
+		{PubsubNameField, pubsubName},
+	} {
+		if attr.value == "" {
+			e.setEmpty(attr.name)
+		}
+	}
+
+	if binary {
+		e.DataBase64 = base64.StdEncoding.EncodeToString(data)
+	} else {
+		e.Data = string(data)
+	}
+
+	return e
+}
+
+// Marshal returns the JSON encoding of the cloud event.
+// Undecoded data is written as is.
+func (e *CloudEvent) Marshal() ([]byte, error) {
+	return jsoniter.Marshal(e.toMap())
+}
+
+// Unmarshal parses the cloudevents JSON into the cloud event.
+// The attributes are read directly into the fields, without an intermediate map,
+// and JSON object or array data is only decoded on demand, see DecodeData.
+func (e *CloudEvent) Unmarshal(data []byte) error {
+	return e.UnmarshalWithMaxExtensions(data, 0)
+}
+
+// UnmarshalWithMaxExtensions is Unmarshal returning an error wrapping ErrTooManyExtensions
+// when the cloud event carries more than maxExtensions extension attributes, 0 disables the limit.
+func (e *CloudEvent) UnmarshalWithMaxExtensions(data []byte, maxExtensions int) error {
+	*e = CloudEvent{Extensions: make(map[string]interface{})}
+
+	iter := jsoniter.ConfigDefault.BorrowIterator(data)
+	defer jsoniter.ConfigDefault.ReturnIterator(iter)
+
+	if iter.WhatIsNext() != jsoniter.ObjectValue {
+		return errors.New("cloud event must be a JSON object")
+	}
+
+	iter.ReadMapCB(func(iter *jsoniter.Iterator, field string) bool {
+		if field == DataField {
+			next := iter.WhatIsNext()
+			if next == jsoniter.ObjectValue || next == jsoniter.ArrayValue {
+				e.Data = jsoniter.RawMessage(iter.SkipAndReturnBytes())
+
+				return true
+			}
+		}
+		e.setAttribute(field, iter.Read())
+
+		return iter.Error == nil
+	})
+	if iter.Error != nil {
+		return iter.Error
+	}
+
+	if iter.WhatIsNext() != jsoniter.InvalidValue {
+		return errors.New("unexpected data after the cloud event")
+	}
+
+	return checkExtensions(e.Extensions, maxExtensions)
+}
+
+// DecodeData returns the data attribute, decoding data kept as JSON by Unmarshal to its native representation.
+// The decoded value replaces Data.
+func (e *CloudEvent) DecodeData() (interface{}, error) {
+	raw, ok := e.Data.(jsoniter.RawMessage)
+	if !ok {
+		return e.Data, nil
+	}
+
+	var data interface{}
+	err := jsoniter.Unmarshal(raw, &data)
+	if err != nil {
+		return nil, err
+	}
+	e.Data = data
+
+	return data, nil
+}
+
+// ToMap returns the map representation of the cloud event, with decoded data.
+// Attributes with an empty value are omitted, except for traceid which is always present,
+// the attributes that an inbound event carries empty, and the baseline attributes of the envelopes created
+// by NewCloudEvent.
+func (e *CloudEvent) ToMap() map[string]interface{} {
+	// data kept by Unmarshal is valid JSON, so decoding does not fail
+	_, _ = e.DecodeData()
+
+	return e.toMap()
+}
+
+func (e *CloudEvent) toMap() map[string]interface{} {
+	m := make(map[string]interface{}, len(e.Extensions)+15)
+	for k, v := range e.Extensions {
+		m[k] = v
+	}
+
+	e.setMapAttribute(m, IDField, e.ID)
+	e.setMapAttribute(m, SpecVersionField, e.SpecVersion)
+	e.setMapAttribute(m, SourceField, e.Source)
+	e.setMapAttribute(m, TypeField, e.Type)
+	e.setMapAttribute(m, SubjectField, e.Subject)
+	e.setMapAttribute(m, DataContentTypeField, e.DataContentType)
+	e.setMapAttribute(m, DataSchemaField, e.DataSchema)
+	e.setMapAttribute(m, TimeField, e.Time)
+	e.setMapAttribute(m, TopicField, e.Topic)
+	e.setMapAttribute(m, PubsubNameField, e.PubsubName)
+	e.setMapAttribute(m, ExpirationField, e.Expiration)
+	e.setMapAttribute(m, DataBase64Field, e.DataBase64)
+	e.setMapAttribute(m, TraceStateField, e.TraceState)
+	m[TraceIDField] = e.TraceID
+
+	if e.Data != nil {
+		m[DataField] = e.Data
+	}
+
+	return m
+}
+
+// GetData returns the payload of the cloud event as bytes, see GetData.
+func (e *CloudEvent) GetData() ([]byte, error) {
+	if e.DataBase64 != "" {
+		return base64.StdEncoding.DecodeString(e.DataBase64)
+	}
+
+	return dataBytes(e.Data)
+}
+
+// HasExpired determines if the cloud event has expired, see HasExpired.
+func (e *CloudEvent) HasExpired() bool {
+	expired, err := e.CheckExpiration()
+
+	return err == nil && expired
+}
+
+// CheckExpiration determines if the cloud event has expired, see CheckExpiration.
+func (e *CloudEvent) CheckExpiration() (bool, error) {
+	return checkExpiration(e.attribute(ExpirationField, e.Expiration), e.Extensions[TTLField], e.attribute(TimeField, e.Time))
+}
+
+// attribute returns the typed value of a standard attribute,
+// or the value kept in the extensions when it was not a string
+func (e *CloudEvent) attribute(name, value string) interface{} {
+	if value != "" {
+		return value
+	}
+
+	return e.Extensions[name]
+}
+
+func (e *CloudEvent) fromMap(m map[string]interface{}) {
+	e.Extensions = make(map[string]interface{})
+	for k, v := range m {
+		e.setAttribute(k, v)
+	}
+}
+
+// setAttribute sets the field of a standard attribute, or the extension
+func (e *CloudEvent) setAttribute(name string, value interface{}) {
+	switch name {
+	case IDField:
+		e.ID = stringAttribute(e, name, value)
+	case SpecVersionField:
+		e.SpecVersion = stringAttribute(e, name, value)
+	case SourceField:
+		e.Source = stringAttribute(e, name, value)
+	case TypeField:
+		e.Type = stringAttribute(e, name, value)
+	case SubjectField:
+		e.Subject = stringAttribute(e, name, value)
+	case DataContentTypeField:
+		e.DataContentType = stringAttribute(e, name, value)
+	case DataSchemaField:
+		e.DataSchema = stringAttribute(e, name, value)
+	case TimeField:
+		e.Time = stringAttribute(e, name, value)
+	case TopicField:
+		e.Topic = stringAttribute(e, name, value)
+	case PubsubNameField:
+		e.PubsubName = stringAttribute(e, name, value)
+	case TraceIDField:
+		e.TraceID = stringAttribute(e, name, value)
+	case TraceStateField:
+		e.TraceState = stringAttribute(e, name, value)
+	case ExpirationField:
+		e.Expiration = stringAttribute(e, name, value)
+	case DataBase64Field:
+		e.DataBase64 = stringAttribute(e, name, value)
+	case DataField:
+		e.Data = value
+	default:
+		e.Extensions[name] = value
+	}
+}
+
+// checkExtensions returns an error if the number of extension attributes exceeds maxExtensions, 0 disables the limit.
+func checkExtensions(extensions map[string]interface{}, maxExtensions int) error {
+	if maxExtensions > 0 && len(extensions) > maxExtensions {
+		return fmt.Errorf("%w: %d exceeds the maximum of %d", ErrTooManyExtensions, len(extensions), maxExtensions)
+	}
+
+	return nil
+}
+
+// stringAttribute returns the attribute value if it is a string,
+// otherwise the value is kept unchanged in the extensions.
+// An empty string is recorded so that the attribute is kept in the map representation.
+func stringAttribute(e *CloudEvent, name string, value interface{}) string {
+	s, ok := value.(string)
+	if !ok {
+		e.Extensions[name] = value
+	} else if s == "" {
+		e.setEmpty(name)
+	}
+
+	return s
+}
+
+// setEmpty records that the event carries a standard attribute with an empty value
+func (e *CloudEvent) setEmpty(name string) {
+	if e.emptyAttributes == nil {
+		e.emptyAttributes = make(map[string]struct{})
+	}
+	e.emptyAttributes[name] = struct{}{}
+}
+
+// setMapAttribute sets a standard attribute in the map representation.
+// An empty attribute is only kept when the event carries it, unless the map holds it as an extension.
+func (e *CloudEvent) setMapAttribute(m map[string]interface{}, name, value string) {
+	if value == "" {
+		if _, ok := e.emptyAttributes[name]; !ok {
+			return
+		}
+		if _, ok := m[name]; ok {
+			return
+		}
+	}
+	m[name] = value
+}
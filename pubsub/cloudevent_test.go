@@ -0,0 +1,220 @@
+// ------------------------------------------------------------
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT License.
+// ------------------------------------------------------------
+
+package pubsub
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	jsoniter "github.com/json-iterator/go"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewCloudEvent(t *testing.T) {
+	t.Run("mirrors map envelope", func(t *testing.T) {
+		e := NewCloudEventWithTraceState("a", "source", "type", "subject", "topic", "mypubsub", "application/xml", []byte("<root/>"), "1", "vendor=a")
+		assert.Equal(t, "a", e.ID)
+		assert.Equal(t, CloudEventsSpecVersion, e.SpecVersion)
+		assert.Equal(t, "source", e.Source)
+		assert.Equal(t, "type", e.Type)
+		assert.Equal(t, "subject", e.Subject)
+		assert.Equal(t, "application/xml", e.DataContentType)
+		assert.Equal(t, "topic", e.Topic)
+		assert.Equal(t, "mypubsub", e.PubsubName)
+		assert.Equal(t, "1", e.TraceID)
+		assert.Equal(t, "vendor=a", e.TraceState)
+		assert.Equal(t, "<root/>", e.Data)
+
+		envelope := NewCloudEventsEnvelopeWithTraceState("a", "source", "type", "subject", "topic", "mypubsub", "application/xml", []byte("<root/>"), "1", "vendor=a")
+		assert.Equal(t, envelope, e.ToMap())
+	})
+
+	t.Run("defaults", func(t *testing.T) {
+		e := NewCloudEvent("", "", "", "", "topic", "mypubsub", "", []byte("data"), "")
+		assert.NotEmpty(t, e.ID)
+		assert.Equal(t, DefaultCloudEventSource, e.Source)
+		assert.Equal(t, DefaultCloudEventType, e.Type)
+		assert.Equal(t, DefaultCloudEventDataContentType, e.DataContentType)
+	})
+}
+
+func TestCloudEventMarshal(t *testing.T) {
+	t.Run("round trip", func(t *testing.T) {
+		e := NewCloudEvent("a", "source", "type", "subject", "topic", "mypubsub", "", []byte(`{"a":"b"}`), "1")
+		e.Expiration = "2021-01-01T00:00:00Z"
+		e.Extensions = map[string]interface{}{"comexampleextension": "value"}
+
+		b, err := e.Marshal()
+		assert.NoError(t, err)
+
+		n := &CloudEvent{}
+		err = n.Unmarshal(b)
+		assert.NoError(t, err)
+		assert.Equal(t, e, n)
+	})
+
+	t.Run("unmarshal extensions", func(t *testing.T) {
+		e := &CloudEvent{}
+		err := e.Unmarshal([]byte(`{"specversion":"1.0","id":"a","comexampleothervalue":5,"data":{"a":"b"}}`))
+		assert.NoError(t, err)
+		assert.Equal(t, "1.0", e.SpecVersion)
+		assert.Equal(t, "a", e.ID)
+		assert.Equal(t, 5.0, e.Extensions["comexampleothervalue"])
+		data, err := e.DecodeData()
+		assert.NoError(t, err)
+		assert.Equal(t, map[string]interface{}{"a": "b"}, data)
+	})
+
+	t.Run("object data is decoded on demand", func(t *testing.T) {
+		e := &CloudEvent{}
+		err := e.Unmarshal([]byte(`{"specversion":"1.0","data":{"a":[1,2]}}`))
+		assert.NoError(t, err)
+		assert.Equal(t, jsoniter.RawMessage(`{"a":[1,2]}`), e.Data)
+
+		b, err := e.Marshal()
+		assert.NoError(t, err)
+		assert.JSONEq(t, `{"specversion":"1.0","traceid":"","data":{"a":[1,2]}}`, string(b))
+
+		assert.Equal(t, map[string]interface{}{"a": []interface{}{1.0, 2.0}}, e.ToMap()[DataField])
+		assert.Equal(t, map[string]interface{}{"a": []interface{}{1.0, 2.0}}, e.Data)
+	})
+
+	t.Run("scalar data is decoded", func(t *testing.T) {
+		e := &CloudEvent{}
+		err := e.Unmarshal([]byte(`{"specversion":"1.0","data":"text"}`))
+		assert.NoError(t, err)
+		assert.Equal(t, "text", e.Data)
+
+		data, err := e.GetData()
+		assert.NoError(t, err)
+		assert.Equal(t, []byte("text"), data)
+	})
+
+	t.Run("raw data is returned as is", func(t *testing.T) {
+		e := &CloudEvent{}
+		err := e.Unmarshal([]byte(`{"specversion":"1.0","data":[1, 2]}`))
+		assert.NoError(t, err)
+
+		data, err := e.GetData()
+		assert.NoError(t, err)
+		assert.Equal(t, []byte(`[1, 2]`), data)
+	})
+
+	t.Run("attributes of the wrong type are kept", func(t *testing.T) {
+		e := &CloudEvent{}
+		err := e.Unmarshal([]byte(`{"specversion":"1.0","expiration":1609459200}`))
+		assert.NoError(t, err)
+		assert.Empty(t, e.Expiration)
+		assert.Equal(t, 1609459200.0, e.ToMap()[ExpirationField])
+	})
+
+	t.Run("invalid JSON", func(t *testing.T) {
+		e := &CloudEvent{}
+		err := e.Unmarshal([]byte("a"))
+		assert.Error(t, err)
+	})
+
+	t.Run("not an object", func(t *testing.T) {
+		e := &CloudEvent{}
+		err := e.Unmarshal([]byte(`["a"]`))
+		assert.Error(t, err)
+	})
+
+	t.Run("trailing data", func(t *testing.T) {
+		e := &CloudEvent{}
+		err := e.Unmarshal([]byte(`{"specversion":"1.0"}{}`))
+		assert.Error(t, err)
+	})
+}
+
+func TestCloudEventHasExpired(t *testing.T) {
+	e := NewCloudEvent("a", "", "", "", "topic", "mypubsub", "", nil, "")
+	assert.False(t, e.HasExpired())
+
+	e.Expiration = time.Now().UTC().Add(time.Hour * -24).Format(time.RFC3339)
+	assert.True(t, e.HasExpired())
+
+	e.Expiration = time.Now().UTC().Add(time.Hour * 24).Format(time.RFC3339)
+	assert.False(t, e.HasExpired())
+
+	t.Run("numeric expiration", func(t *testing.T) {
+		e := &CloudEvent{}
+		err := e.Unmarshal([]byte(`{"specversion":"1.0","expiration":1609459200}`))
+		assert.NoError(t, err)
+		assert.True(t, e.HasExpired())
+	})
+
+	t.Run("ttl", func(t *testing.T) {
+		e := &CloudEvent{}
+		err := e.Unmarshal([]byte(`{"specversion":"1.0","time":"2021-01-01T00:00:00Z","ttl":60}`))
+		assert.NoError(t, err)
+		expired, err := e.CheckExpiration()
+		assert.NoError(t, err)
+		assert.True(t, expired)
+	})
+
+	t.Run("invalid expiration", func(t *testing.T) {
+		e := &CloudEvent{Expiration: "tomorrow"}
+		_, err := e.CheckExpiration()
+		assert.Error(t, err)
+		assert.False(t, e.HasExpired())
+	})
+}
+
+func TestCloudEventGetData(t *testing.T) {
+	e := NewCloudEvent("a", "", "", "", "topic", "mypubsub", "", []byte{0xff, 0x00}, "")
+	data, err := e.GetData()
+	assert.NoError(t, err)
+	assert.Equal(t, []byte{0xff, 0x00}, data)
+
+	e = NewCloudEvent("a", "", "", "", "topic", "mypubsub", "", []byte("text"), "")
+	data, err = e.GetData()
+	assert.NoError(t, err)
+	assert.Equal(t, []byte("text"), data)
+}
+
+func TestMaxExtensionAttributes(t *testing.T) {
+	event := func(extensions int) []byte {
+		attributes := []string{`"specversion":"1.0"`, `"id":"a"`}
+		for i := 0; i < extensions; i++ {
+			attributes = append(attributes, fmt.Sprintf(`"ext%d":"v"`, i))
+		}
+
+		return []byte("{" + strings.Join(attributes, ",") + "}")
+	}
+
+	t.Run("at the cap", func(t *testing.T) {
+		e := &CloudEvent{}
+		err := e.UnmarshalWithMaxExtensions(event(3), 3)
+		assert.NoError(t, err)
+		assert.Len(t, e.Extensions, 3)
+	})
+
+	t.Run("over the cap", func(t *testing.T) {
+		e := &CloudEvent{}
+		err := e.UnmarshalWithMaxExtensions(event(4), 3)
+		assert.True(t, errors.Is(err, ErrTooManyExtensions))
+	})
+
+	t.Run("non string standard attributes count as extensions", func(t *testing.T) {
+		e := &CloudEvent{}
+		err := e.UnmarshalWithMaxExtensions([]byte(`{"specversion":"1.0","id":1,"ext0":"v","ext1":"v","ext2":"v"}`), 3)
+		assert.True(t, errors.Is(err, ErrTooManyExtensions))
+	})
+
+	t.Run("no limit by default", func(t *testing.T) {
+		e := &CloudEvent{}
+		err := e.Unmarshal(event(200))
+		assert.NoError(t, err)
+		assert.Len(t, e.Extensions, 200)
+
+		_, err = FromCloudEvent(event(200), "")
+		assert.NoError(t, err)
+	})
+}
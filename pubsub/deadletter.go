@@ -0,0 +1,88 @@
+// ------------------------------------------------------------
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT License.
+// ------------------------------------------------------------
+
+package pubsub
+
+import (
+	"fmt"
+	"strconv"
+
+	contrib_metadata "github.com/dapr/components-contrib/metadata"
+)
+
+const (
+	// DeadLetterTopicMetadataKey is the component metadata key naming the topic messages are published to
+	// when they cannot be delivered
+	DeadLetterTopicMetadataKey = "deadLetterTopic"
+	// MaxDeliveryAttemptsMetadataKey is the component metadata key setting how many times a message is delivered
+	// to the application before it is given up on
+	MaxDeliveryAttemptsMetadataKey = "maxDeliveryAttempts"
+
+	// DefaultMaxDeliveryAttempts is the number of delivery attempts when a dead letter topic is set without maxDeliveryAttempts
+	DefaultMaxDeliveryAttempts = 3
+)
+
+// DeadLetterConfig is the dead letter configuration of a component.
+type DeadLetterConfig struct {
+	// Topic is the dead letter topic, dead lettering is disabled if empty.
+	Topic string
+	// MaxDeliveryAttempts is the number of times a message is delivered before being dead lettered,
+	// 0 keeps the component's own redelivery behavior.
+	MaxDeliveryAttempts int
+}
+
+// ParseDeadLetterConfig returns the dead letter configuration set in the component metadata.
+func ParseDeadLetterConfig(metadata map[string]string) (DeadLetterConfig, error) {
+	c := DeadLetterConfig{Topic: metadata[DeadLetterTopicMetadataKey]}
+
+	if val, ok := metadata[MaxDeliveryAttemptsMetadataKey]; ok && val != "" {
+		attempts, err := strconv.Atoi(val)
+		if err != nil || attempts <= 0 {
+			return c, fmt.Errorf("%s value must be a positive integer: actual is '%s'", MaxDeliveryAttemptsMetadataKey, val)
+		}
+		c.MaxDeliveryAttempts = attempts
+	} else if c.Topic != "" {
+		c.MaxDeliveryAttempts = DefaultMaxDeliveryAttempts
+	}
+
+	return c, nil
+}
+
+// Enabled tells whether the messages that cannot be delivered are published to a dead letter topic.
+func (c DeadLetterConfig) Enabled() bool {
+	return c.Topic != ""
+}
+
+// Exhausted tells whether a message which failed deliveryCount times is not to be delivered again.
+func (c DeadLetterConfig) Exhausted(deliveryCount int) bool {
+	return c.MaxDeliveryAttempts > 0 && deliveryCount >= c.MaxDeliveryAttempts
+}
+
+// Deliver delivers a message to the handler up to MaxDeliveryAttempts times, until it succeeds.
+// It is meant for brokers that do not redeliver failed messages themselves, and returns the last error.
+func (c DeadLetterConfig) Deliver(msg *NewMessage, handler func(msg *NewMessage) error) error {
+	var err error
+	for attempt := 1; ; attempt++ {
+		err = handler(msg)
+		if err == nil || attempt >= c.MaxDeliveryAttempts {
+			return err
+		}
+	}
+}
+
+// DeadLetter publishes a message which could not be delivered to the dead letter topic.
+// The message data is published as is, so that it can be told apart from the original message only by its topic.
+func (c DeadLetterConfig) DeadLetter(publisher PubSub, msg *NewMessage) error {
+	err := publisher.Publish(&PublishRequest{
+		Data:     msg.Data,
+		Topic:    c.Topic,
+		Metadata: map[string]string{contrib_metadata.RawPayloadMetadataKey: "true"},
+	})
+	if err != nil {
+		return fmt.Errorf("cannot publish message of topic %s to dead letter topic %s: %s", msg.Topic, c.Topic, err)
+	}
+
+	return nil
+}
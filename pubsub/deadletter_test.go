@@ -0,0 +1,105 @@
+// ------------------------------------------------------------
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT License.
+// ------------------------------------------------------------
+
+package pubsub
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseDeadLetterConfig(t *testing.T) {
+	t.Run("not configured", func(t *testing.T) {
+		c, err := ParseDeadLetterConfig(map[string]string{})
+		assert.NoError(t, err)
+		assert.False(t, c.Enabled())
+		assert.Equal(t, 0, c.MaxDeliveryAttempts)
+	})
+
+	t.Run("default max delivery attempts", func(t *testing.T) {
+		c, err := ParseDeadLetterConfig(map[string]string{DeadLetterTopicMetadataKey: "poison"})
+		assert.NoError(t, err)
+		assert.True(t, c.Enabled())
+		assert.Equal(t, "poison", c.Topic)
+		assert.Equal(t, DefaultMaxDeliveryAttempts, c.MaxDeliveryAttempts)
+	})
+
+	t.Run("max delivery attempts", func(t *testing.T) {
+		c, err := ParseDeadLetterConfig(map[string]string{DeadLetterTopicMetadataKey: "poison", MaxDeliveryAttemptsMetadataKey: "5"})
+		assert.NoError(t, err)
+		assert.Equal(t, 5, c.MaxDeliveryAttempts)
+	})
+
+	t.Run("invalid max delivery attempts", func(t *testing.T) {
+		_, err := ParseDeadLetterConfig(map[string]string{MaxDeliveryAttemptsMetadataKey: "0"})
+		assert.Error(t, err)
+
+		_, err = ParseDeadLetterConfig(map[string]string{MaxDeliveryAttemptsMetadataKey: "a"})
+		assert.Error(t, err)
+	})
+}
+
+func TestDeadLetterConfigExhausted(t *testing.T) {
+	c := DeadLetterConfig{Topic: "poison", MaxDeliveryAttempts: 3}
+	assert.False(t, c.Exhausted(2))
+	assert.True(t, c.Exhausted(3))
+
+	assert.False(t, DeadLetterConfig{}.Exhausted(100))
+}
+
+func TestDeadLetterConfigDeliver(t *testing.T) {
+	t.Run("retries until success", func(t *testing.T) {
+		attempts := 0
+		err := DeadLetterConfig{MaxDeliveryAttempts: 3}.Deliver(&NewMessage{}, func(msg *NewMessage) error {
+			attempts++
+			if attempts < 2 {
+				return errors.New("failed")
+			}
+
+			return nil
+		})
+		assert.NoError(t, err)
+		assert.Equal(t, 2, attempts)
+	})
+
+	t.Run("gives up after max delivery attempts", func(t *testing.T) {
+		attempts := 0
+		err := DeadLetterConfig{MaxDeliveryAttempts: 3}.Deliver(&NewMessage{}, func(msg *NewMessage) error {
+			attempts++
+
+			return errors.New("failed")
+		})
+		assert.Error(t, err)
+		assert.Equal(t, 3, attempts)
+	})
+
+	t.Run("delivers once when not configured", func(t *testing.T) {
+		attempts := 0
+		err := DeadLetterConfig{}.Deliver(&NewMessage{}, func(msg *NewMessage) error {
+			attempts++
+
+			return errors.New("failed")
+		})
+		assert.Error(t, err)
+		assert.Equal(t, 1, attempts)
+	})
+}
+
+func TestDeadLetterConfigDeadLetter(t *testing.T) {
+	p := &fakePubSub{}
+	c := DeadLetterConfig{Topic: "poison", MaxDeliveryAttempts: 3}
+
+	err := c.DeadLetter(p, &NewMessage{Topic: "orders", Data: []byte("a")})
+	assert.NoError(t, err)
+	assert.Len(t, p.published, 1)
+	assert.Equal(t, "poison", p.published[0].Topic)
+	assert.Equal(t, []byte("a"), p.published[0].Data)
+	assert.Equal(t, map[string]string{"rawPayload": "true"}, p.published[0].Metadata)
+
+	err = c.DeadLetter(p, &NewMessage{Topic: "orders", Data: []byte("fail")})
+	assert.Error(t, err)
+}
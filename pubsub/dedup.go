@@ -0,0 +1,207 @@
+// ------------------------------------------------------------
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT License.
+// ------------------------------------------------------------
+
+package pubsub
+
+import (
+	"container/list"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	contrib_metadata "github.com/dapr/components-contrib/metadata"
+	"github.com/dapr/components-contrib/state"
+)
+
+// DefaultDeduplicationTTL is how long the IDs of the delivered messages are remembered by default
+const DefaultDeduplicationTTL = 10 * time.Minute
+
+// DeduplicationStore records the IDs of the messages already delivered to a subscriber
+type DeduplicationStore interface {
+	// Add records the ID for the given time to live, it returns false if the ID is already recorded.
+	Add(id string, ttl time.Duration) (bool, error)
+	// Remove forgets the ID, so that a message which failed to be delivered can be delivered again.
+	Remove(id string) error
+}
+
+// Deduplicator delivers messages with the same cloud event ID once, offering effectively-once delivery
+// on top of brokers delivering messages at least once.
+type Deduplicator struct {
+	store DeduplicationStore
+	ttl   time.Duration
+}
+
+// NewDeduplicator returns a deduplicator remembering the IDs of the delivered messages in the store for ttl.
+func NewDeduplicator(store DeduplicationStore, ttl time.Duration) *Deduplicator {
+	if ttl <= 0 {
+		ttl = DefaultDeduplicationTTL
+	}
+
+	return &Deduplicator{
+		store: store,
+		ttl:   ttl,
+	}
+}
+
+// Handler wraps a subscription handler so that messages whose cloud event was already delivered on the same topic
+// are acknowledged without being delivered again. Messages which are not cloud events are always delivered.
+func (d *Deduplicator) Handler(handler func(msg *NewMessage) error) func(msg *NewMessage) error {
+	return func(msg *NewMessage) error {
+		key, ok := deduplicationKey(msg)
+		if !ok {
+			return handler(msg)
+		}
+
+		added, err := d.store.Add(key, d.ttl)
+		if err != nil {
+			return fmt.Errorf("cannot record message %s for deduplication: %s", key, err)
+		}
+		if !added {
+			return nil
+		}
+
+		err = handler(msg)
+		if err != nil {
+			// the message can be delivered again
+			if removeErr := d.store.Remove(key); removeErr != nil {
+				return fmt.Errorf("%s, and cannot forget message %s for deduplication: %s", err, key, removeErr)
+			}
+		}
+
+		return err
+	}
+}
+
+// deduplicationKey identifies a cloud event by its topic, source and ID, as the ID is only unique per source
+func deduplicationKey(msg *NewMessage) (string, bool) {
+	e, err := ParseCloudEvent(msg.Data, "", "")
+	if err != nil || e.ID == "" {
+		return "", false
+	}
+
+	return msg.Topic + "||" + e.Source + "||" + e.ID, true
+}
+
+// MemoryDeduplicationStore is a deduplication store keeping the most recently delivered IDs in memory
+type MemoryDeduplicationStore struct {
+	capacity int
+	entries  map[string]*list.Element
+	lru      *list.List
+	lock     sync.Mutex
+	now      func() time.Time
+}
+
+type memoryDeduplicationEntry struct {
+	id      string
+	expires time.Time
+}
+
+// NewMemoryDeduplicationStore returns an in memory store of at most capacity IDs, the least recently added
+// IDs are evicted first.
+func NewMemoryDeduplicationStore(capacity int) *MemoryDeduplicationStore {
+	return &MemoryDeduplicationStore{
+		capacity: capacity,
+		entries:  make(map[string]*list.Element),
+		lru:      list.New(),
+		now:      time.Now,
+	}
+}
+
+// Add records the ID for the given time to live, it returns false if the ID is already recorded.
+func (s *MemoryDeduplicationStore) Add(id string, ttl time.Duration) (bool, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	now := s.now()
+	if e, ok := s.entries[id]; ok {
+		entry := e.Value.(*memoryDeduplicationEntry)
+		if now.Before(entry.expires) {
+			return false, nil
+		}
+
+		entry.expires = now.Add(ttl)
+		s.lru.MoveToFront(e)
+
+		return true, nil
+	}
+
+	s.entries[id] = s.lru.PushFront(&memoryDeduplicationEntry{id: id, expires: now.Add(ttl)})
+	for s.capacity > 0 && s.lru.Len() > s.capacity {
+		oldest := s.lru.Back()
+		s.lru.Remove(oldest)
+		delete(s.entries, oldest.Value.(*memoryDeduplicationEntry).id)
+	}
+
+	return true, nil
+}
+
+// Remove forgets the ID.
+func (s *MemoryDeduplicationStore) Remove(id string) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if e, ok := s.entries[id]; ok {
+		s.lru.Remove(e)
+		delete(s.entries, id)
+	}
+
+	return nil
+}
+
+// StateDeduplicationStore is a deduplication store keeping the delivered IDs in a state store,
+// so that they are shared by the instances of a subscriber.
+// The IDs are expired with the ttlInSeconds metadata by the state stores with FeatureTTL, and by the store itself otherwise.
+// Checking and recording an ID are not atomic, so a message delivered concurrently to two instances may be delivered twice.
+type StateDeduplicationStore struct {
+	store     state.Store
+	keyPrefix string
+	now       func() time.Time
+}
+
+// NewStateDeduplicationStore returns a deduplication store saving the IDs in the state store, under the key prefix.
+func NewStateDeduplicationStore(store state.Store, keyPrefix string) *StateDeduplicationStore {
+	return &StateDeduplicationStore{
+		store:     store,
+		keyPrefix: keyPrefix,
+		now:       time.Now,
+	}
+}
+
+// Add records the ID for the given time to live, it returns false if the ID is already recorded.
+func (s *StateDeduplicationStore) Add(id string, ttl time.Duration) (bool, error) {
+	now := s.now()
+	res, err := s.store.Get(&state.GetRequest{Key: s.keyPrefix + id})
+	if err != nil {
+		return false, err
+	}
+
+	if res != nil && len(res.Data) > 0 {
+		// the value is the expiry time, quoted by state stores saving values as JSON
+		expires, err := strconv.ParseInt(strings.Trim(string(res.Data), `"`), 10, 64)
+		if err == nil && now.Unix() < expires {
+			return false, nil
+		}
+	}
+
+	ttlInSeconds := int64(math.Ceil(ttl.Seconds()))
+	var metadata map[string]string
+	if state.FeatureTTL.IsPresent(state.Features(s.store)) {
+		metadata = map[string]string{contrib_metadata.TTLMetadataKey: strconv.FormatInt(ttlInSeconds, 10)}
+	}
+
+	return true, s.store.Set(&state.SetRequest{
+		Key:      s.keyPrefix + id,
+		Value:    strconv.FormatInt(now.Unix()+ttlInSeconds, 10),
+		Metadata: metadata,
+	})
+}
+
+// Remove forgets the ID.
+func (s *StateDeduplicationStore) Remove(id string) error {
+	return s.store.Delete(&state.DeleteRequest{Key: s.keyPrefix + id})
+}
@@ -0,0 +1,181 @@
+// ------------------------------------------------------------
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT License.
+// ------------------------------------------------------------
+
+package pubsub
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/dapr/components-contrib/state"
+	"github.com/stretchr/testify/assert"
+)
+
+type fakeStateStore struct {
+	state.DefaultBulkStore
+	items    map[string][]byte
+	metadata map[string]map[string]string
+	features []state.Feature
+}
+
+func newFakeStateStore() *fakeStateStore {
+	s := &fakeStateStore{
+		items:    make(map[string][]byte),
+		metadata: make(map[string]map[string]string),
+	}
+	s.DefaultBulkStore = state.NewDefaultBulkStore(s)
+
+	return s
+}
+
+func (s *fakeStateStore) Features() []state.Feature {
+	return s.features
+}
+
+func (s *fakeStateStore) Init(metadata state.Metadata) error {
+	return nil
+}
+
+func (s *fakeStateStore) Get(req *state.GetRequest) (*state.GetResponse, error) {
+	return &state.GetResponse{Data: s.items[req.Key]}, nil
+}
+
+func (s *fakeStateStore) Set(req *state.SetRequest) error {
+	s.items[req.Key] = []byte(`"` + req.Value.(string) + `"`)
+	s.metadata[req.Key] = req.Metadata
+
+	return nil
+}
+
+func (s *fakeStateStore) Delete(req *state.DeleteRequest) error {
+	delete(s.items, req.Key)
+
+	return nil
+}
+
+func TestDeduplicator(t *testing.T) {
+	event := []byte(`{"specversion":"1.0","id":"a","source":"s","type":"t"}`)
+
+	t.Run("duplicates are not delivered", func(t *testing.T) {
+		delivered := 0
+		handler := NewDeduplicator(NewMemoryDeduplicationStore(10), time.Minute).Handler(func(msg *NewMessage) error {
+			delivered++
+
+			return nil
+		})
+
+		assert.NoError(t, handler(&NewMessage{Topic: "topic", Data: event}))
+		assert.NoError(t, handler(&NewMessage{Topic: "topic", Data: event}))
+		assert.Equal(t, 1, delivered)
+
+		// the same event on another topic is not a duplicate
+		assert.NoError(t, handler(&NewMessage{Topic: "other", Data: event}))
+		assert.Equal(t, 2, delivered)
+	})
+
+	t.Run("failed messages are delivered again", func(t *testing.T) {
+		delivered := 0
+		handler := NewDeduplicator(NewMemoryDeduplicationStore(10), time.Minute).Handler(func(msg *NewMessage) error {
+			delivered++
+			if delivered == 1 {
+				return errors.New("failed")
+			}
+
+			return nil
+		})
+
+		assert.Error(t, handler(&NewMessage{Topic: "topic", Data: event}))
+		assert.NoError(t, handler(&NewMessage{Topic: "topic", Data: event}))
+		assert.NoError(t, handler(&NewMessage{Topic: "topic", Data: event}))
+		assert.Equal(t, 2, delivered)
+	})
+
+	t.Run("messages which are not cloud events are always delivered", func(t *testing.T) {
+		delivered := 0
+		handler := NewDeduplicator(NewMemoryDeduplicationStore(10), time.Minute).Handler(func(msg *NewMessage) error {
+			delivered++
+
+			return nil
+		})
+
+		assert.NoError(t, handler(&NewMessage{Topic: "topic", Data: []byte("raw")}))
+		assert.NoError(t, handler(&NewMessage{Topic: "topic", Data: []byte("raw")}))
+		assert.Equal(t, 2, delivered)
+	})
+}
+
+func TestMemoryDeduplicationStore(t *testing.T) {
+	t.Run("ids expire", func(t *testing.T) {
+		now := time.Now()
+		s := NewMemoryDeduplicationStore(10)
+		s.now = func() time.Time { return now }
+
+		added, err := s.Add("a", time.Minute)
+		assert.NoError(t, err)
+		assert.True(t, added)
+
+		added, _ = s.Add("a", time.Minute)
+		assert.False(t, added)
+
+		now = now.Add(time.Minute)
+		added, _ = s.Add("a", time.Minute)
+		assert.True(t, added)
+	})
+
+	t.Run("least recently added ids are evicted", func(t *testing.T) {
+		s := NewMemoryDeduplicationStore(2)
+		s.Add("a", time.Minute)
+		s.Add("b", time.Minute)
+		s.Add("c", time.Minute)
+
+		added, _ := s.Add("a", time.Minute)
+		assert.True(t, added)
+		added, _ = s.Add("c", time.Minute)
+		assert.False(t, added)
+	})
+
+	t.Run("removed ids can be added again", func(t *testing.T) {
+		s := NewMemoryDeduplicationStore(2)
+		s.Add("a", time.Minute)
+		assert.NoError(t, s.Remove("a"))
+
+		added, _ := s.Add("a", time.Minute)
+		assert.True(t, added)
+	})
+}
+
+func TestStateDeduplicationStore(t *testing.T) {
+	now := time.Unix(1000, 0)
+	store := newFakeStateStore()
+	store.features = []state.Feature{state.FeatureTTL}
+	s := NewStateDeduplicationStore(store, "dedup||")
+	s.now = func() time.Time { return now }
+
+	added, err := s.Add("a", 90*time.Second)
+	assert.NoError(t, err)
+	assert.True(t, added)
+	assert.Equal(t, `"1090"`, string(store.items["dedup||a"]))
+	assert.Equal(t, map[string]string{"ttlInSeconds": "90"}, store.metadata["dedup||a"])
+
+	added, err = s.Add("a", 90*time.Second)
+	assert.NoError(t, err)
+	assert.False(t, added)
+
+	// expired ids are added again, even if the state store has not removed them yet
+	now = now.Add(90 * time.Second)
+	added, _ = s.Add("a", 90*time.Second)
+	assert.True(t, added)
+
+	assert.NoError(t, s.Remove("a"))
+	assert.Empty(t, store.items)
+
+	// the state stores not supporting TTL receive no ttlInSeconds metadata
+	store.features = nil
+	added, err = s.Add("b", 90*time.Second)
+	assert.NoError(t, err)
+	assert.True(t, added)
+	assert.Empty(t, store.metadata["dedup||b"])
+}
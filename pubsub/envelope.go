@@ -6,8 +6,16 @@
 package pubsub
 
 import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"math"
+	"mime"
+	"strconv"
+	"strings"
 	"time"
+	"unicode/utf8"
 
 	contrib_metadata "github.com/dapr/components-contrib/metadata"
 	"github.com/google/uuid"
@@ -25,13 +33,82 @@ const (
 	DefaultCloudEventSource = "Dapr"
 	// DefaultCloudEventDataContentType is the default content-type for the data attribute
 	DefaultCloudEventDataContentType = "text/plain"
-	TraceIDField                     = "traceid"
-	expirationField                  = "expiration"
+	// TraceIDField is the attribute holding the W3C traceparent of the event
+	TraceIDField = "traceid"
+	// TraceStateField is the attribute holding the W3C tracestate accompanying the traceparent
+	TraceStateField = "tracestate"
+	// DataField is the attribute holding the event payload
+	DataField = "data"
+	// DataBase64Field is the attribute holding the base64 encoded binary event payload
+	DataBase64Field = "data_base64"
+	// BinaryCloudEventDataContentType is the default content-type for binary data
+	BinaryCloudEventDataContentType = "application/octet-stream"
+	// SchemaVersionField is the extension attribute carrying the version of the data schema
+	SchemaVersionField = "schemaversion"
+	// SchemaVersionMetadataKey is the publish metadata key used to tag the cloud event with a schema version
+	SchemaVersionMetadataKey = "schemaVersion"
+	// TTLField is the attribute holding the time to live of the event in seconds, counted from the event time
+	TTLField        = "ttl"
+	expirationField = "expiration"
 )
 
-// NewCloudEventsEnvelope returns a map representation of a cloudevents JSON
+// Publish metadata keys overriding cloud event attributes
+const (
+	TypeMetadataKey    = "cloudevent.type"
+	SourceMetadataKey  = "cloudevent.source"
+	SubjectMetadataKey = "cloudevent.subject"
+)
+
+// metadataOverrides maps the publish metadata keys to the cloud event attributes they override
+var metadataOverrides = map[string]string{
+	TypeMetadataKey:    TypeField,
+	SourceMetadataKey:  SourceField,
+	SubjectMetadataKey: SubjectField,
+}
+
+// ErrInvalidExpiration is returned when the expiration of a cloud event is present but cannot be parsed.
+var ErrInvalidExpiration = errors.New("invalid cloud event expiration")
+
+// NewCloudEventsEnvelope returns a map representation of a cloudevents JSON.
+// Binary payloads, that is payloads that are not valid UTF-8 or are flagged as binary by
+// their content type, are base64 encoded into the data_base64 attribute instead of data.
+// The map is built directly, with the attributes of NewCloudEvent.
 func NewCloudEventsEnvelope(id, source, eventType, subject string, topic string, pubsubName string, dataContentType string, data []byte, traceID string) map[string]interface{} {
-	// defaults
+	return NewCloudEventsEnvelopeWithTraceState(id, source, eventType, subject, topic, pubsubName, dataContentType, data, traceID, "")
+}
+
+// NewCloudEventsEnvelopeWithTraceState is NewCloudEventsEnvelope with the W3C tracestate accompanying traceID.
+// The tracestate attribute is omitted when traceState is empty.
+func NewCloudEventsEnvelopeWithTraceState(id, source, eventType, subject string, topic string, pubsubName string, dataContentType string, data []byte, traceID string, traceState string) map[string]interface{} {
+	binary := isBinaryData(dataContentType, data)
+	id, source, eventType, dataContentType = envelopeDefaults(id, source, eventType, dataContentType, data, binary)
+
+	m := map[string]interface{}{
+		IDField:              id,
+		SpecVersionField:     CloudEventsSpecVersion,
+		DataContentTypeField: dataContentType,
+		SourceField:          source,
+		TypeField:            eventType,
+		SubjectField:         subject,
+		TopicField:           topic,
+		PubsubNameField:      pubsubName,
+		TraceIDField:         traceID,
+	}
+	if traceState != "" {
+		m[TraceStateField] = traceState
+	}
+
+	if binary {
+		m[DataBase64Field] = base64.StdEncoding.EncodeToString(data)
+	} else {
+		m[DataField] = string(data)
+	}
+
+	return m
+}
+
+// envelopeDefaults fills in the default values for the envelope attributes that were not set
+func envelopeDefaults(id, source, eventType, dataContentType string, data []byte, binary bool) (string, string, string, string) {
 	if id == "" {
 		id = uuid.New().String()
 	}
@@ -41,63 +118,360 @@ func NewCloudEventsEnvelope(id, source, eventType, subject string, topic string,
 	if eventType == "" {
 		eventType = DefaultCloudEventType
 	}
+	// A content type given by the caller is kept as is, it is only detected when missing
 	if dataContentType == "" {
-		dataContentType = DefaultCloudEventDataContentType
+		if binary {
+			dataContentType = BinaryCloudEventDataContentType
+		} else {
+			dataContentType = DetectDataContentType(data)
+		}
 	}
 
-	var j interface{}
-	err := jsoniter.Unmarshal(data, &j)
-	if err == nil {
-		dataContentType = "application/json"
+	return id, source, eventType, dataContentType
+}
+
+// DataContentTypeDetector returns the content type of a payload published without one.
+type DataContentTypeDetector func(data []byte) string
+
+// DetectDataContentType is the detector used for payloads published without a content type.
+// By default, JSON payloads are detected as application/json and anything else as text/plain.
+var DetectDataContentType DataContentTypeDetector = detectJSONContentType
+
+func detectJSONContentType(data []byte) string {
+	if jsoniter.Valid(data) {
+		return "application/json"
 	}
 
-	return map[string]interface{}{
-		"id":              id,
-		"specversion":     CloudEventsSpecVersion,
-		"datacontenttype": dataContentType,
-		"source":          source,
-		"type":            eventType,
-		"subject":         subject,
-		"topic":           topic,
-		"pubsubname":      pubsubName,
-		"data":            string(data),
-		"traceid":         traceID,
+	return DefaultCloudEventDataContentType
+}
+
+// isBinaryData determines if the payload must be carried as data_base64,
+// that is when it is not valid UTF-8 or its content type is a binary one.
+func isBinaryData(dataContentType string, data []byte) bool {
+	return !utf8.Valid(data) || isBinaryContentType(dataContentType)
+}
+
+func isBinaryContentType(contentType string) bool {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return false
 	}
+
+	switch mediaType {
+	case BinaryCloudEventDataContentType, "application/protobuf", "application/x-protobuf", "application/gzip", "application/zip":
+		return true
+	}
+
+	return strings.HasPrefix(mediaType, "image/") || strings.HasPrefix(mediaType, "audio/") || strings.HasPrefix(mediaType, "video/")
 }
 
-// FromCloudEvent returns a map representation of an existing cloudevents JSON
+// FromCloudEvent returns a map representation of an existing cloudevents JSON.
+// The data attribute is kept as it appears in the event: a JSON object or array
+// is decoded to its native representation, while text payloads remain strings.
+// Use GetData to read the payload as bytes regardless of its representation,
+// it decodes data_base64, which is checked to be valid base64 and not to come along with data.
+// Extension attributes are kept unchanged and an existing trace context is preserved,
+// the given traceID is only set on events that do not carry a traceid.
 func FromCloudEvent(cloudEvent []byte, traceID string) (map[string]interface{}, error) {
+	return FromCloudEventWithTraceState(cloudEvent, traceID, "")
+}
+
+// FromCloudEventWithTraceState is FromCloudEvent with the W3C tracestate accompanying traceID,
+// which is only set along with traceID on events that do not carry a traceid.
+// The event is decoded directly into the map, without going through CloudEvent.
+func FromCloudEventWithTraceState(cloudEvent []byte, traceID string, traceState string) (map[string]interface{}, error) {
 	var m map[string]interface{}
 	err := jsoniter.Unmarshal(cloudEvent, &m)
 	if err != nil {
-		return m, err
+		return nil, err
+	}
+	if m == nil {
+		return nil, errors.New("cloud event must be a JSON object")
+	}
+
+	err = checkMapData(m)
+	if err != nil {
+		return nil, err
 	}
 
-	setTraceContext(m, traceID)
+	setMapTraceContext(m, traceID, traceState)
 
 	return m, nil
 }
 
-func setTraceContext(cloudEvent map[string]interface{}, traceID string) {
+// ParseCloudEvent is FromCloudEvent returning the typed cloud event,
+// whose JSON object or array data is only decoded on demand, see CloudEvent.DecodeData.
+func ParseCloudEvent(cloudEvent []byte, traceID string, traceState string) (*CloudEvent, error) {
+	e := &CloudEvent{}
+	err := e.Unmarshal(cloudEvent)
+	if err != nil {
+		return nil, err
+	}
+
+	err = checkData(e)
+	if err != nil {
+		return nil, err
+	}
+
+	setTraceContext(e, traceID, traceState)
+
+	return e, nil
+}
+
+// checkData validates the payload of an inbound cloud event, which the spec allows in data or data_base64 but not both
+func checkData(e *CloudEvent) error {
+	if e.DataBase64 == "" {
+		return nil
+	}
+
+	if e.Data != nil {
+		return fmt.Errorf("cloud event cannot have both %s and %s", DataField, DataBase64Field)
+	}
+
+	_, err := base64.StdEncoding.DecodeString(e.DataBase64)
+	if err != nil {
+		return fmt.Errorf("invalid %s: %s", DataBase64Field, err)
+	}
+
+	return nil
+}
+
+// checkMapData is checkData for the map representation of a cloud event
+func checkMapData(cloudEvent map[string]interface{}) error {
+	encoded, _ := cloudEvent[DataBase64Field].(string)
+	if encoded == "" {
+		return nil
+	}
+
+	if cloudEvent[DataField] != nil {
+		return fmt.Errorf("cloud event cannot have both %s and %s", DataField, DataBase64Field)
+	}
+
+	_, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return fmt.Errorf("invalid %s: %s", DataBase64Field, err)
+	}
+
+	return nil
+}
+
+// setMapTraceContext is setTraceContext for the map representation of a cloud event
+func setMapTraceContext(cloudEvent map[string]interface{}, traceID string, traceState string) {
+	if id, _ := cloudEvent[TraceIDField].(string); id != "" {
+		return
+	}
+
 	cloudEvent[TraceIDField] = traceID
+	if traceState != "" {
+		cloudEvent[TraceStateField] = traceState
+	} else if state, _ := cloudEvent[TraceStateField].(string); state != "" {
+		delete(cloudEvent, TraceStateField)
+	}
+}
+
+// setTraceContext injects the trace context into the cloud event, unless the event already carries one.
+// The tracestate belongs to the traceparent it was sent with, so both are kept or replaced together.
+func setTraceContext(cloudEvent *CloudEvent, traceID string, traceState string) {
+	if cloudEvent.TraceID != "" {
+		return
+	}
+
+	cloudEvent.TraceID = traceID
+	cloudEvent.TraceState = traceState
+}
+
+// GetData returns the payload of the cloud event as bytes.
+// String data, as produced by NewCloudEventsEnvelope, is returned as is,
+// native JSON values, as found in inbound structured events, are serialized back to JSON
+// and data_base64 is decoded to the original binary payload.
+func GetData(cloudEvent map[string]interface{}) ([]byte, error) {
+	if v, ok := cloudEvent[DataBase64Field]; ok {
+		encoded, ok := v.(string)
+		if !ok {
+			return nil, fmt.Errorf("%s must be a string", DataBase64Field)
+		}
+
+		return base64.StdEncoding.DecodeString(encoded)
+	}
+
+	return dataBytes(cloudEvent[DataField])
+}
+
+func dataBytes(data interface{}) ([]byte, error) {
+	switch d := data.(type) {
+	case nil:
+		return nil, nil
+	case string:
+		return []byte(d), nil
+	case []byte:
+		return d, nil
+	case jsoniter.RawMessage:
+		return d, nil
+	default:
+		return jsoniter.Marshal(d)
+	}
 }
 
 // HasExpired determines if the current cloud event has expired.
+// Events with an invalid expiration are reported as not expired, use CheckExpiration to detect them.
 func HasExpired(cloudEvent map[string]interface{}) bool {
-	e, ok := cloudEvent[expirationField]
-	if ok && e != "" {
-		expiration, err := time.Parse(time.RFC3339, fmt.Sprintf("%s", e))
+	expired, err := CheckExpiration(cloudEvent)
+
+	return err == nil && expired
+}
+
+// CheckExpiration determines if the current cloud event has expired.
+// The expiration attribute is either an RFC3339 timestamp or the number of seconds since the Unix epoch.
+// Without expiration, the expiry is computed from the ttl attribute, in seconds, added to the event time.
+// An error wrapping ErrInvalidExpiration is returned when these attributes are present but cannot be parsed.
+func CheckExpiration(cloudEvent map[string]interface{}) (bool, error) {
+	return checkExpiration(cloudEvent[expirationField], cloudEvent[TTLField], cloudEvent[TimeField])
+}
+
+func checkExpiration(expiration, ttl, eventTime interface{}) (bool, error) {
+	now := time.Now().UTC()
+
+	if !isEmptyAttribute(expiration) {
+		t, err := parseExpiration(expiration)
 		if err != nil {
+			return false, fmt.Errorf("%w: %s %v", ErrInvalidExpiration, expirationField, expiration)
+		}
+
+		return t.UTC().Before(now), nil
+	}
+
+	if !isEmptyAttribute(ttl) {
+		seconds, err := parseSeconds(ttl)
+		if err != nil {
+			return false, fmt.Errorf("%w: %s %v", ErrInvalidExpiration, TTLField, ttl)
+		}
+
+		s, _ := eventTime.(string)
+		t, err := time.Parse(time.RFC3339, s)
+		if err != nil {
+			return false, fmt.Errorf("%w: %s requires a valid %s, got %v", ErrInvalidExpiration, TTLField, TimeField, eventTime)
+		}
+
+		return t.Add(time.Duration(seconds * float64(time.Second))).UTC().Before(now), nil
+	}
+
+	return false, nil
+}
+
+// parseExpiration parses an RFC3339 timestamp or a number of seconds since the Unix epoch
+func parseExpiration(expiration interface{}) (time.Time, error) {
+	if s, ok := expiration.(string); ok {
+		t, err := time.Parse(time.RFC3339, s)
+		if err == nil {
+			return t, nil
+		}
+	}
+
+	seconds, err := parseSeconds(expiration)
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	whole := math.Floor(seconds)
+
+	return time.Unix(int64(whole), int64((seconds-whole)*float64(time.Second))), nil
+}
+
+func parseSeconds(value interface{}) (float64, error) {
+	switch v := value.(type) {
+	case float64:
+		return v, nil
+	case float32:
+		return float64(v), nil
+	case int:
+		return float64(v), nil
+	case int64:
+		return float64(v), nil
+	case json.Number:
+		return v.Float64()
+	case string:
+		return strconv.ParseFloat(v, 64)
+	default:
+		return 0, fmt.Errorf("unsupported type %T", value)
+	}
+}
+
+func isEmptyAttribute(value interface{}) bool {
+	return value == nil || value == ""
+}
+
+// GetTraceID returns the trace ID of the cloud event's traceid attribute, which holds either
+// a bare 32 hex digit trace ID or a full W3C traceparent, in which case its trace-id part is returned.
+// It returns false when the attribute is missing or is not a valid trace ID or traceparent.
+func GetTraceID(cloudEvent map[string]interface{}) (string, bool) {
+	value, _ := cloudEvent[TraceIDField].(string)
+	if value == "" {
+		return "", false
+	}
+
+	traceID := value
+	if parts := strings.Split(value, "-"); len(parts) > 1 {
+		// traceparent: version-traceid-parentid-flags
+		if len(parts) < 4 || !isHex(parts[0], 2) || !isHex(parts[2], 16) || !isHex(parts[3], 2) {
+			return "", false
+		}
+		traceID = parts[1]
+	}
+
+	if !isHex(traceID, 32) || strings.Trim(traceID, "0") == "" {
+		return "", false
+	}
+
+	return strings.ToLower(traceID), true
+}
+
+// isHex determines if s is made of exactly length hex digits
+func isHex(s string, length int) bool {
+	if len(s) != length {
+		return false
+	}
+
+	for _, c := range s {
+		if !strings.ContainsRune("0123456789abcdefABCDEF", c) {
 			return false
 		}
+	}
+
+	return true
+}
 
-		return expiration.UTC().Before(time.Now().UTC())
+// SetSchemaVersion tags the cloud event with the version of the schema its data conforms to.
+// This is a plain version tag, unlike the dataschema attribute which references a schema URI.
+// An empty version removes any existing tag.
+func SetSchemaVersion(cloudEvent map[string]interface{}, schemaVersion string) {
+	if schemaVersion == "" {
+		delete(cloudEvent, SchemaVersionField)
+
+		return
 	}
 
-	return false
+	cloudEvent[SchemaVersionField] = schemaVersion
+}
+
+// GetSchemaVersion returns the data schema version of the cloud event, if tagged.
+func GetSchemaVersion(cloudEvent map[string]interface{}) (string, bool) {
+	v, ok := cloudEvent[SchemaVersionField]
+	if !ok {
+		return "", false
+	}
+
+	schemaVersion, ok := v.(string)
+	if !ok || schemaVersion == "" {
+		return "", false
+	}
+
+	return schemaVersion, true
 }
 
 // ApplyMetadata will process metadata to modify the cloud event based on the component's feature set.
+// The type, source and subject of the cloud event are overridden by the non-empty
+// cloudevent.type, cloudevent.source and cloudevent.subject metadata values.
+// Other cloudevent. metadata keys set extension attributes, they are validated and applied by ApplyExtensions.
 func ApplyMetadata(cloudEvent map[string]interface{}, componentFeatures []Feature, metadata map[string]string) {
 	ttl, hasTTL, _ := contrib_metadata.TryGetTTL(metadata)
 	if hasTTL && !FeatureMessageTTL.IsPresent(componentFeatures) {
@@ -111,4 +485,14 @@ func ApplyMetadata(cloudEvent map[string]interface{}, componentFeatures []Featur
 		expiration := now.Add(ttl)
 		cloudEvent[expirationField] = expiration.Format(time.RFC3339)
 	}
+
+	if schemaVersion, ok := metadata[SchemaVersionMetadataKey]; ok && schemaVersion != "" {
+		SetSchemaVersion(cloudEvent, schemaVersion)
+	}
+
+	for key, field := range metadataOverrides {
+		if val, ok := metadata[key]; ok && val != "" {
+			cloudEvent[field] = val
+		}
+	}
 }
@@ -0,0 +1,82 @@
+// ------------------------------------------------------------
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT License.
+// ------------------------------------------------------------
+
+package pubsub
+
+import (
+	"bytes"
+	"fmt"
+
+	jsoniter "github.com/json-iterator/go"
+)
+
+// BatchContentType is the content type of the CloudEvents JSON batch format
+const BatchContentType = "application/cloudevents-batch+json"
+
+// NewCloudEventsBatch returns the CloudEvents JSON batch, that is the JSON array, of structured cloud events.
+func NewCloudEventsBatch(cloudEvents [][]byte) ([]byte, error) {
+	size := 2
+	for _, e := range cloudEvents {
+		size += len(e) + 1
+	}
+
+	batch := make([]byte, 0, size)
+	batch = append(batch, '[')
+	for i, e := range cloudEvents {
+		if !isJSONObject(e) {
+			return nil, fmt.Errorf("batch entry %d is not a structured cloud event", i)
+		}
+
+		if i > 0 {
+			batch = append(batch, ',')
+		}
+		batch = append(batch, e...)
+	}
+
+	return append(batch, ']'), nil
+}
+
+// SplitCloudEventsBatch returns the structured cloud events of a CloudEvents JSON batch.
+func SplitCloudEventsBatch(batch []byte) ([][]byte, error) {
+	var entries []jsoniter.RawMessage
+	err := jsoniter.Unmarshal(batch, &entries)
+	if err != nil {
+		return nil, fmt.Errorf("invalid cloud events batch: %s", err)
+	}
+
+	cloudEvents := make([][]byte, len(entries))
+	for i, e := range entries {
+		if !isJSONObject(e) {
+			return nil, fmt.Errorf("batch entry %d is not a structured cloud event", i)
+		}
+		cloudEvents[i] = e
+	}
+
+	return cloudEvents, nil
+}
+
+// FromCloudEventsBatch is FromCloudEvent for each cloud event of a CloudEvents JSON batch.
+func FromCloudEventsBatch(batch []byte, traceID string, traceState string) ([]map[string]interface{}, error) {
+	cloudEvents, err := SplitCloudEventsBatch(batch)
+	if err != nil {
+		return nil, err
+	}
+
+	res := make([]map[string]interface{}, len(cloudEvents))
+	for i, e := range cloudEvents {
+		res[i], err = FromCloudEventWithTraceState(e, traceID, traceState)
+		if err != nil {
+			return nil, fmt.Errorf("batch entry %d: %s", i, err)
+		}
+	}
+
+	return res, nil
+}
+
+func isJSONObject(data []byte) bool {
+	trimmed := bytes.TrimSpace(data)
+
+	return len(trimmed) > 0 && trimmed[0] == '{' && jsoniter.Valid(trimmed)
+}
@@ -0,0 +1,59 @@
+// ------------------------------------------------------------
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT License.
+// ------------------------------------------------------------
+
+package pubsub
+
+import (
+	"testing"
+
+	jsoniter "github.com/json-iterator/go"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCloudEventsBatch(t *testing.T) {
+	first, _ := jsoniter.Marshal(NewCloudEventsEnvelope("a", "", "", "", "topic", "mypubsub", "", []byte("first"), ""))
+	second, _ := jsoniter.Marshal(NewCloudEventsEnvelope("b", "", "", "", "topic", "mypubsub", "", []byte(`{"second":2}`), ""))
+
+	t.Run("round trip", func(t *testing.T) {
+		batch, err := NewCloudEventsBatch([][]byte{first, second})
+		assert.NoError(t, err)
+		assert.True(t, jsoniter.Valid(batch))
+
+		cloudEvents, err := SplitCloudEventsBatch(batch)
+		assert.NoError(t, err)
+		assert.Equal(t, [][]byte{first, second}, cloudEvents)
+
+		events, err := FromCloudEventsBatch(batch, "1", "")
+		assert.NoError(t, err)
+		assert.Len(t, events, 2)
+		assert.Equal(t, "a", events[0][IDField])
+		assert.Equal(t, "first", events[0][DataField])
+		assert.Equal(t, "b", events[1][IDField])
+		assert.Equal(t, "1", events[1][TraceIDField])
+	})
+
+	t.Run("empty batch", func(t *testing.T) {
+		batch, err := NewCloudEventsBatch(nil)
+		assert.NoError(t, err)
+		assert.Equal(t, "[]", string(batch))
+
+		events, err := FromCloudEventsBatch(batch, "", "")
+		assert.NoError(t, err)
+		assert.Empty(t, events)
+	})
+
+	t.Run("entries must be cloud events", func(t *testing.T) {
+		_, err := NewCloudEventsBatch([][]byte{first, []byte("text")})
+		assert.Error(t, err)
+
+		_, err = SplitCloudEventsBatch([]byte(`[{"specversion":"1.0"},"text"]`))
+		assert.Error(t, err)
+	})
+
+	t.Run("batch must be an array", func(t *testing.T) {
+		_, err := SplitCloudEventsBatch(first)
+		assert.Error(t, err)
+	})
+}
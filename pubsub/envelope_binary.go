@@ -0,0 +1,153 @@
+// ------------------------------------------------------------
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT License.
+// ------------------------------------------------------------
+
+package pubsub
+
+import (
+	"encoding/base64"
+	"fmt"
+	"mime"
+	"strings"
+
+	jsoniter "github.com/json-iterator/go"
+)
+
+// ContentModeMetadataKey is the component metadata key selecting how cloud events are carried by brokers supporting message headers
+const ContentModeMetadataKey = "contentMode"
+
+const (
+	// ContentModeStructured carries the cloud event as a JSON envelope in the message body, this is the default
+	ContentModeStructured = "structured"
+	// ContentModeBinary carries the cloud event attributes in the message headers and the raw payload as the message body
+	ContentModeBinary = "binary"
+)
+
+// ParseContentMode returns the content mode configured in the component metadata, structured by default.
+func ParseContentMode(metadata map[string]string) (string, error) {
+	switch mode := strings.ToLower(metadata[ContentModeMetadataKey]); mode {
+	case "":
+		return ContentModeStructured, nil
+	case ContentModeStructured, ContentModeBinary:
+		return mode, nil
+	default:
+		return "", fmt.Errorf("invalid %s %s, expected %s or %s", ContentModeMetadataKey, metadata[ContentModeMetadataKey], ContentModeStructured, ContentModeBinary)
+	}
+}
+
+// BinaryMessage is a cloud event in binary content mode.
+type BinaryMessage struct {
+	// Headers holds the cloud event attributes, named after the attribute with the transport's prefix.
+	// Extension values that are not strings are JSON encoded.
+	Headers map[string]string
+	// ContentType is the datacontenttype attribute, which transports carry in their own content type header.
+	ContentType string
+	// Data is the raw payload.
+	Data []byte
+}
+
+// ToBinaryMessage converts a structured cloud event, as published by Dapr, to binary content mode.
+// The headers are named after the attributes with the given prefix, e.g. ce_ for Kafka,
+// and data_base64 is decoded so that the message body always holds the original payload.
+func ToBinaryMessage(cloudEvent []byte, headerPrefix string) (*BinaryMessage, error) {
+	e, err := ParseCloudEvent(cloudEvent, "", "")
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := e.GetData()
+	if err != nil {
+		return nil, err
+	}
+
+	attributes := e.toMap()
+	headers := make(map[string]string, len(attributes))
+	for name, value := range attributes {
+		switch name {
+		case DataField, DataBase64Field, DataContentTypeField:
+			continue
+		}
+
+		if s, ok := value.(string); ok {
+			if s != "" {
+				headers[headerPrefix+name] = s
+			}
+
+			continue
+		}
+
+		b, err := jsoniter.Marshal(value)
+		if err != nil {
+			return nil, fmt.Errorf("cannot encode attribute %s: %s", name, err)
+		}
+		headers[headerPrefix+name] = string(b)
+	}
+
+	return &BinaryMessage{
+		Headers:     headers,
+		ContentType: e.DataContentType,
+		Data:        data,
+	}, nil
+}
+
+// FromBinaryMessage converts a message received in binary content mode to a structured cloud event,
+// so that subscribers get the same envelope regardless of the content mode of the publisher.
+// It returns false, along with the unchanged data, when the message is not a binary mode cloud event,
+// that is when it has no specversion header or its content type is the structured cloud events one.
+// Messages with more than maxExtensions extension headers are rejected, 0 disables the limit.
+func FromBinaryMessage(headers map[string]string, headerPrefix string, contentType string, data []byte, maxExtensions int) ([]byte, bool, error) {
+	if headers[headerPrefix+SpecVersionField] == "" || isStructuredContentType(contentType) {
+		return data, false, nil
+	}
+
+	e := &CloudEvent{Extensions: make(map[string]interface{})}
+	for name, value := range headers {
+		if !strings.HasPrefix(name, headerPrefix) {
+			continue
+		}
+		e.setAttribute(strings.ToLower(strings.TrimPrefix(name, headerPrefix)), value)
+	}
+
+	// the payload is the message body, not a header
+	e.Data = nil
+	e.DataBase64 = ""
+
+	err := checkExtensions(e.Extensions, maxExtensions)
+	if err != nil {
+		return nil, false, err
+	}
+
+	e.DataContentType = contentType
+	switch {
+	case len(data) == 0:
+	case isBinaryData(contentType, data):
+		e.DataBase64 = base64.StdEncoding.EncodeToString(data)
+	case isJSONContentType(contentType) && jsoniter.Valid(data):
+		e.Data = jsoniter.RawMessage(data)
+	default:
+		e.Data = string(data)
+	}
+
+	b, err := e.Marshal()
+	if err != nil {
+		return nil, false, err
+	}
+
+	return b, true, nil
+}
+
+func isStructuredContentType(contentType string) bool {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+
+	return err == nil && strings.HasPrefix(mediaType, "application/cloudevents")
+}
+
+func isJSONContentType(contentType string) bool {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return false
+	}
+
+	return mediaType == "application/json" || strings.HasSuffix(mediaType, "+json")
+}
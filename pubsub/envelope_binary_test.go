@@ -0,0 +1,136 @@
+// ------------------------------------------------------------
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT License.
+// ------------------------------------------------------------
+
+package pubsub
+
+import (
+	"testing"
+
+	jsoniter "github.com/json-iterator/go"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseContentMode(t *testing.T) {
+	t.Run("structured by default", func(t *testing.T) {
+		mode, err := ParseContentMode(map[string]string{})
+		assert.NoError(t, err)
+		assert.Equal(t, ContentModeStructured, mode)
+	})
+
+	t.Run("binary", func(t *testing.T) {
+		mode, err := ParseContentMode(map[string]string{ContentModeMetadataKey: "Binary"})
+		assert.NoError(t, err)
+		assert.Equal(t, ContentModeBinary, mode)
+	})
+
+	t.Run("invalid", func(t *testing.T) {
+		_, err := ParseContentMode(map[string]string{ContentModeMetadataKey: "batched"})
+		assert.Error(t, err)
+	})
+}
+
+func TestToBinaryMessage(t *testing.T) {
+	t.Run("attributes in headers", func(t *testing.T) {
+		envelope := NewCloudEventsEnvelope("a", "source", "type", "", "topic", "mypubsub", "application/json", []byte(`{"b":1}`), "1")
+		envelope[TTLField] = 60
+		b, _ := jsoniter.Marshal(envelope)
+
+		m, err := ToBinaryMessage(b, "ce_")
+		assert.NoError(t, err)
+		assert.Equal(t, map[string]string{
+			"ce_id":          "a",
+			"ce_specversion": "1.0",
+			"ce_source":      "source",
+			"ce_type":        "type",
+			"ce_topic":       "topic",
+			"ce_pubsubname":  "mypubsub",
+			"ce_traceid":     "1",
+			"ce_ttl":         "60",
+		}, m.Headers)
+		assert.Equal(t, "application/json", m.ContentType)
+		assert.Equal(t, `{"b":1}`, string(m.Data))
+	})
+
+	t.Run("binary data is decoded", func(t *testing.T) {
+		b, _ := jsoniter.Marshal(NewCloudEventsEnvelope("a", "", "", "", "topic", "mypubsub", "", []byte{0xff, 0x00}, ""))
+
+		m, err := ToBinaryMessage(b, "ce_")
+		assert.NoError(t, err)
+		assert.Equal(t, []byte{0xff, 0x00}, m.Data)
+		assert.Equal(t, BinaryCloudEventDataContentType, m.ContentType)
+		assert.NotContains(t, m.Headers, "ce_data_base64")
+	})
+
+	t.Run("not a cloud event", func(t *testing.T) {
+		_, err := ToBinaryMessage([]byte("a"), "ce_")
+		assert.Error(t, err)
+	})
+}
+
+func TestFromBinaryMessage(t *testing.T) {
+	t.Run("round trip", func(t *testing.T) {
+		envelope := NewCloudEventsEnvelopeWithTraceState("a", "source", "type", "", "topic", "mypubsub", "application/json", []byte(`{"b":1}`), "1", "vendor=a")
+		envelope["comexampleextension"] = "value"
+		b, _ := jsoniter.Marshal(envelope)
+
+		m, err := ToBinaryMessage(b, "cloudEvents_")
+		assert.NoError(t, err)
+
+		structured, ok, err := FromBinaryMessage(m.Headers, "cloudEvents_", m.ContentType, m.Data, 0)
+		assert.NoError(t, err)
+		assert.True(t, ok)
+
+		n, err := FromCloudEvent(structured, "")
+		assert.NoError(t, err)
+		assert.Equal(t, "a", n[IDField])
+		assert.Equal(t, "source", n[SourceField])
+		assert.Equal(t, "type", n[TypeField])
+		assert.Equal(t, "application/json", n[DataContentTypeField])
+		assert.Equal(t, "1", n[TraceIDField])
+		assert.Equal(t, "vendor=a", n[TraceStateField])
+		assert.Equal(t, "value", n["comexampleextension"])
+		assert.Equal(t, map[string]interface{}{"b": 1.0}, n[DataField])
+	})
+
+	t.Run("text data", func(t *testing.T) {
+		structured, ok, err := FromBinaryMessage(map[string]string{"ce_specversion": "1.0", "ce_id": "a"}, "ce_", "text/plain", []byte("text"), 0)
+		assert.NoError(t, err)
+		assert.True(t, ok)
+		assert.JSONEq(t, `{"specversion":"1.0","id":"a","datacontenttype":"text/plain","traceid":"","data":"text"}`, string(structured))
+	})
+
+	t.Run("binary data", func(t *testing.T) {
+		structured, ok, err := FromBinaryMessage(map[string]string{"ce_specversion": "1.0"}, "ce_", "", []byte{0xff, 0x00}, 0)
+		assert.NoError(t, err)
+		assert.True(t, ok)
+
+		e, err := ParseCloudEvent(structured, "", "")
+		assert.NoError(t, err)
+		data, err := e.GetData()
+		assert.NoError(t, err)
+		assert.Equal(t, []byte{0xff, 0x00}, data)
+	})
+
+	t.Run("headers without the prefix are ignored", func(t *testing.T) {
+		structured, ok, err := FromBinaryMessage(map[string]string{"ce_specversion": "1.0", "other": "a"}, "ce_", "text/plain", []byte("text"), 0)
+		assert.NoError(t, err)
+		assert.True(t, ok)
+		assert.NotContains(t, string(structured), "other")
+	})
+
+	t.Run("not a cloud event", func(t *testing.T) {
+		data, ok, err := FromBinaryMessage(map[string]string{"other": "a"}, "ce_", "text/plain", []byte("text"), 0)
+		assert.NoError(t, err)
+		assert.False(t, ok)
+		assert.Equal(t, []byte("text"), data)
+	})
+
+	t.Run("structured cloud event", func(t *testing.T) {
+		data, ok, err := FromBinaryMessage(map[string]string{"ce_specversion": "1.0"}, "ce_", ContentType, []byte(`{"specversion":"1.0"}`), 0)
+		assert.NoError(t, err)
+		assert.False(t, ok)
+		assert.Equal(t, `{"specversion":"1.0"}`, string(data))
+	})
+}
@@ -0,0 +1,198 @@
+// ------------------------------------------------------------
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT License.
+// ------------------------------------------------------------
+
+package pubsub
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"strings"
+	"sync"
+
+	"github.com/golang/snappy"
+	jsoniter "github.com/json-iterator/go"
+	"github.com/klauspost/compress/zstd"
+)
+
+const (
+	// CompressionMetadataKey is the component and publish metadata key selecting the compression of the cloud event data
+	CompressionMetadataKey = "compression"
+	// ContentEncodingField is the extension attribute naming the compression of the data
+	ContentEncodingField = "contentencoding"
+)
+
+// Compressions of the cloud event data
+const (
+	CompressionNone   = ""
+	CompressionGzip   = "gzip"
+	CompressionSnappy = "snappy"
+	CompressionZstd   = "zstd"
+)
+
+var (
+	zstdOnce    sync.Once
+	zstdEncoder *zstd.Encoder
+	zstdDecoder *zstd.Decoder
+	zstdErr     error
+)
+
+// ParseCompression returns the compression set in the metadata, defaultCompression if it is not set.
+func ParseCompression(metadata map[string]string, defaultCompression string) (string, error) {
+	val, ok := metadata[CompressionMetadataKey]
+	if !ok || val == "" {
+		return defaultCompression, nil
+	}
+
+	switch compression := strings.ToLower(val); compression {
+	case "none":
+		return CompressionNone, nil
+	case CompressionGzip, CompressionSnappy, CompressionZstd:
+		return compression, nil
+	default:
+		return "", fmt.Errorf("invalid %s %s, expected none, %s, %s or %s", CompressionMetadataKey, val, CompressionGzip, CompressionSnappy, CompressionZstd)
+	}
+}
+
+// CompressCloudEvent compresses the data of the cloud event, which is then carried in data_base64,
+// and names the compression in the contentencoding attribute.
+// Events without data, or whose data is already compressed, are returned as is.
+func CompressCloudEvent(cloudEvent []byte, compression string) ([]byte, error) {
+	if compression == CompressionNone {
+		return cloudEvent, nil
+	}
+
+	e, err := ParseCloudEvent(cloudEvent, "", "")
+	if err != nil {
+		return nil, err
+	}
+	if _, ok := e.Extensions[ContentEncodingField]; ok || (e.Data == nil && e.DataBase64 == "") {
+		return cloudEvent, nil
+	}
+
+	// data is compressed as JSON so that its type is restored, data_base64 as the bytes it encodes
+	var data []byte
+	if e.DataBase64 != "" {
+		data, err = e.GetData()
+	} else {
+		data, err = jsoniter.Marshal(e.Data)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	compressed, err := compress(compression, data)
+	if err != nil {
+		return nil, fmt.Errorf("cannot compress cloud event data with %s: %s", compression, err)
+	}
+
+	e.Data = nil
+	e.DataBase64 = base64.StdEncoding.EncodeToString(compressed)
+	e.Extensions[ContentEncodingField] = compression
+
+	return e.Marshal()
+}
+
+// DecompressCloudEvent restores the data of a cloud event compressed by CompressCloudEvent.
+// Events without the contentencoding attribute are returned as is.
+func DecompressCloudEvent(cloudEvent []byte) ([]byte, error) {
+	e, err := ParseCloudEvent(cloudEvent, "", "")
+	if err != nil {
+		// not a cloud event, so not compressed
+		return cloudEvent, nil
+	}
+
+	encoding, ok := e.Extensions[ContentEncodingField]
+	if !ok {
+		return cloudEvent, nil
+	}
+	compression, _ := encoding.(string)
+
+	compressed, err := e.GetData()
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := decompress(compression, compressed)
+	if err != nil {
+		return nil, fmt.Errorf("cannot decompress cloud event data with %v: %s", encoding, err)
+	}
+
+	delete(e.Extensions, ContentEncodingField)
+	if isBinaryContentType(e.DataContentType) || !json.Valid(data) {
+		e.DataBase64 = base64.StdEncoding.EncodeToString(data)
+	} else {
+		// the JSON data is written as is
+		e.DataBase64 = ""
+		e.Data = jsoniter.RawMessage(data)
+	}
+
+	return e.Marshal()
+}
+
+func compress(compression string, data []byte) ([]byte, error) {
+	switch compression {
+	case CompressionGzip:
+		var buf bytes.Buffer
+		w := gzip.NewWriter(&buf)
+		if _, err := w.Write(data); err != nil {
+			return nil, err
+		}
+		if err := w.Close(); err != nil {
+			return nil, err
+		}
+
+		return buf.Bytes(), nil
+	case CompressionSnappy:
+		return snappy.Encode(nil, data), nil
+	case CompressionZstd:
+		if err := initZstd(); err != nil {
+			return nil, err
+		}
+
+		return zstdEncoder.EncodeAll(data, nil), nil
+	default:
+		return nil, fmt.Errorf("unknown compression %s", compression)
+	}
+}
+
+func decompress(compression string, data []byte) ([]byte, error) {
+	switch compression {
+	case CompressionGzip:
+		r, err := gzip.NewReader(bytes.NewReader(data))
+		if err != nil {
+			return nil, err
+		}
+		defer r.Close()
+
+		return ioutil.ReadAll(r)
+	case CompressionSnappy:
+		return snappy.Decode(nil, data)
+	case CompressionZstd:
+		if err := initZstd(); err != nil {
+			return nil, err
+		}
+
+		return zstdDecoder.DecodeAll(data, nil)
+	default:
+		return nil, fmt.Errorf("unknown compression %s", compression)
+	}
+}
+
+// initZstd creates the zstd encoder and decoder once, they are safe for concurrent use of EncodeAll and DecodeAll
+func initZstd() error {
+	zstdOnce.Do(func() {
+		zstdEncoder, zstdErr = zstd.NewWriter(nil)
+		if zstdErr != nil {
+			return
+		}
+		zstdDecoder, zstdErr = zstd.NewReader(nil)
+	})
+
+	return zstdErr
+}
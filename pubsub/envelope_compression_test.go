@@ -0,0 +1,102 @@
+// ------------------------------------------------------------
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT License.
+// ------------------------------------------------------------
+
+package pubsub
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseCompression(t *testing.T) {
+	compression, err := ParseCompression(map[string]string{}, CompressionGzip)
+	assert.NoError(t, err)
+	assert.Equal(t, CompressionGzip, compression)
+
+	compression, err = ParseCompression(map[string]string{CompressionMetadataKey: "None"}, CompressionGzip)
+	assert.NoError(t, err)
+	assert.Equal(t, CompressionNone, compression)
+
+	compression, err = ParseCompression(map[string]string{CompressionMetadataKey: "ZSTD"}, CompressionNone)
+	assert.NoError(t, err)
+	assert.Equal(t, CompressionZstd, compression)
+
+	_, err = ParseCompression(map[string]string{CompressionMetadataKey: "lz4"}, CompressionNone)
+	assert.Error(t, err)
+}
+
+func TestCompressCloudEvent(t *testing.T) {
+	events := map[string]string{
+		"text":   `{"specversion":"1.0","id":"a","traceid":"","datacontenttype":"text/plain","data":"` + strings.Repeat("text", 100) + `"}`,
+		"json":   `{"specversion":"1.0","id":"a","traceid":"","datacontenttype":"application/json","data":{"b":[1,2],"a":"x"}}`,
+		"number": `{"specversion":"1.0","id":"a","traceid":"","datacontenttype":"application/json","data":1}`,
+		"binary": `{"specversion":"1.0","id":"a","traceid":"","datacontenttype":"application/octet-stream","data_base64":"AAEC"}`,
+	}
+
+	for _, compression := range []string{CompressionGzip, CompressionSnappy, CompressionZstd} {
+		for name, event := range events {
+			t.Run(compression+" "+name, func(t *testing.T) {
+				compressed, err := CompressCloudEvent([]byte(event), compression)
+				assert.NoError(t, err)
+
+				e, err := ParseCloudEvent(compressed, "", "")
+				assert.NoError(t, err)
+				assert.Equal(t, compression, e.Extensions[ContentEncodingField])
+				assert.Nil(t, e.Data)
+				assert.NotEmpty(t, e.DataBase64)
+
+				// compressed events are not compressed again
+				again, err := CompressCloudEvent(compressed, compression)
+				assert.NoError(t, err)
+				assert.Equal(t, compressed, again)
+
+				decompressed, err := DecompressCloudEvent(compressed)
+				assert.NoError(t, err)
+				assert.JSONEq(t, event, string(decompressed))
+			})
+		}
+	}
+
+	t.Run("binary content mode", func(t *testing.T) {
+		compressed, err := CompressCloudEvent([]byte(events["text"]), CompressionSnappy)
+		assert.NoError(t, err)
+
+		binary, err := ToBinaryMessage(compressed, "ce_")
+		assert.NoError(t, err)
+		assert.Equal(t, CompressionSnappy, binary.Headers["ce_contentencoding"])
+
+		structured, _, err := FromBinaryMessage(binary.Headers, "ce_", binary.ContentType, binary.Data, 0)
+		assert.NoError(t, err)
+
+		decompressed, err := DecompressCloudEvent(structured)
+		assert.NoError(t, err)
+		assert.JSONEq(t, events["text"], string(decompressed))
+	})
+
+	t.Run("no compression", func(t *testing.T) {
+		compressed, err := CompressCloudEvent([]byte(events["text"]), CompressionNone)
+		assert.NoError(t, err)
+		assert.Equal(t, events["text"], string(compressed))
+	})
+
+	t.Run("not a cloud event", func(t *testing.T) {
+		_, err := CompressCloudEvent([]byte("text"), CompressionGzip)
+		assert.Error(t, err)
+
+		data, err := DecompressCloudEvent([]byte("text"))
+		assert.NoError(t, err)
+		assert.Equal(t, "text", string(data))
+	})
+
+	t.Run("invalid compressed data", func(t *testing.T) {
+		_, err := DecompressCloudEvent([]byte(`{"specversion":"1.0","id":"a","contentencoding":"gzip","data_base64":"AAEC"}`))
+		assert.Error(t, err)
+
+		_, err = DecompressCloudEvent([]byte(`{"specversion":"1.0","id":"a","contentencoding":"lz4","data_base64":"AAEC"}`))
+		assert.Error(t, err)
+	})
+}
@@ -0,0 +1,109 @@
+// ------------------------------------------------------------
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT License.
+// ------------------------------------------------------------
+
+package pubsub
+
+import (
+	"encoding/base64"
+	"sync"
+
+	jsoniter "github.com/json-iterator/go"
+)
+
+const defaultEnvelopeBufferSize = 1024
+
+// EnvelopeBuffer holds a serialized cloud events envelope.
+// Buffers are obtained with AcquireEnvelopeBuffer and must be handed back with
+// ReleaseEnvelopeBuffer once the serialized bytes are no longer referenced.
+type EnvelopeBuffer struct {
+	B []byte
+}
+
+var envelopeBufferPool = sync.Pool{
+	New: func() interface{} {
+		return &EnvelopeBuffer{B: make([]byte, 0, defaultEnvelopeBufferSize)}
+	},
+}
+
+// AcquireEnvelopeBuffer returns an empty buffer from the pool.
+func AcquireEnvelopeBuffer() *EnvelopeBuffer {
+	return envelopeBufferPool.Get().(*EnvelopeBuffer)
+}
+
+// ReleaseEnvelopeBuffer returns the buffer to the pool.
+func ReleaseEnvelopeBuffer(b *EnvelopeBuffer) {
+	b.B = b.B[:0]
+	envelopeBufferPool.Put(b)
+}
+
+// AppendCloudEventsEnvelope appends the JSON serialization of a cloudevents envelope to dst and returns the extended buffer.
+// It produces the same envelope as marshaling the result of NewCloudEventsEnvelope,
+// without allocating the intermediate map, so that high rate publishers can reuse buffers:
+//
+//	buf := pubsub.AcquireEnvelopeBuffer()
+//	buf.B = pubsub.AppendCloudEventsEnvelope(buf.B, id, source, eventType, subject, topic, pubsubName, dataContentType, data, traceID)
+//	// publish buf.B
+//	pubsub.ReleaseEnvelopeBuffer(buf)
+func AppendCloudEventsEnvelope(dst []byte, id, source, eventType, subject string, topic string, pubsubName string, dataContentType string, data []byte, traceID string) []byte {
+	return AppendCloudEventsEnvelopeWithTraceState(dst, id, source, eventType, subject, topic, pubsubName, dataContentType, data, traceID, "")
+}
+
+// AppendCloudEventsEnvelopeWithTraceState is AppendCloudEventsEnvelope with the W3C tracestate accompanying traceID,
+// producing the same envelope as marshaling the result of NewCloudEventsEnvelopeWithTraceState.
+func AppendCloudEventsEnvelopeWithTraceState(dst []byte, id, source, eventType, subject string, topic string, pubsubName string, dataContentType string, data []byte, traceID string, traceState string) []byte {
+	binary := isBinaryData(dataContentType, data)
+	id, source, eventType, dataContentType = envelopeDefaults(id, source, eventType, dataContentType, data, binary)
+
+	stream := jsoniter.ConfigDefault.BorrowStream(nil)
+	stream.SetBuffer(dst)
+
+	stream.WriteObjectStart()
+	stream.WriteObjectField(IDField)
+	stream.WriteString(id)
+	writeRequiredEnvelopeField(stream, SpecVersionField, CloudEventsSpecVersion)
+	writeRequiredEnvelopeField(stream, DataContentTypeField, dataContentType)
+	writeRequiredEnvelopeField(stream, SourceField, source)
+	writeRequiredEnvelopeField(stream, TypeField, eventType)
+	writeRequiredEnvelopeField(stream, SubjectField, subject)
+	writeRequiredEnvelopeField(stream, TopicField, topic)
+	writeRequiredEnvelopeField(stream, PubsubNameField, pubsubName)
+	if binary {
+		writeEnvelopeField(stream, DataBase64Field, base64.StdEncoding.EncodeToString(data))
+	} else {
+		stream.WriteMore()
+		stream.WriteObjectField(DataField)
+		stream.WriteString(string(data))
+	}
+	stream.WriteMore()
+	stream.WriteObjectField(TraceIDField)
+	stream.WriteString(traceID)
+	writeEnvelopeField(stream, TraceStateField, traceState)
+	stream.WriteObjectEnd()
+
+	buf := stream.Buffer()
+	// Detach the caller's buffer before the stream goes back to the pool
+	stream.SetBuffer(nil)
+	jsoniter.ConfigDefault.ReturnStream(stream)
+
+	return buf
+}
+
+// writeEnvelopeField writes an attribute that follows other attributes, omitting empty values like CloudEvent.ToMap
+func writeEnvelopeField(stream *jsoniter.Stream, field, value string) {
+	if value == "" {
+		return
+	}
+
+	stream.WriteMore()
+	stream.WriteObjectField(field)
+	stream.WriteString(value)
+}
+
+// writeRequiredEnvelopeField writes an attribute that follows other attributes, even when its value is empty
+func writeRequiredEnvelopeField(stream *jsoniter.Stream, field, value string) {
+	stream.WriteMore()
+	stream.WriteObjectField(field)
+	stream.WriteString(value)
+}
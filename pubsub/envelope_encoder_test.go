@@ -0,0 +1,105 @@
+// ------------------------------------------------------------
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT License.
+// ------------------------------------------------------------
+
+package pubsub
+
+import (
+	"testing"
+
+	jsoniter "github.com/json-iterator/go"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAppendCloudEventsEnvelope(t *testing.T) {
+	t.Run("matches map envelope", func(t *testing.T) {
+		for _, data := range [][]byte{nil, []byte("text"), []byte(`{"a":"b"}`), []byte("<root a=\"é\"/>\n"), {0xff, 0xfe, 0x00}} {
+			expected := NewCloudEventsEnvelopeWithTraceState("a", "source", "type", "subject", "topic", "mypubsub", "application/xml", data, "1", "vendor=a")
+
+			buf := AcquireEnvelopeBuffer()
+			buf.B = AppendCloudEventsEnvelopeWithTraceState(buf.B, "a", "source", "type", "subject", "topic", "mypubsub", "application/xml", data, "1", "vendor=a")
+
+			var actual map[string]interface{}
+			err := jsoniter.Unmarshal(buf.B, &actual)
+			ReleaseEnvelopeBuffer(buf)
+			assert.NoError(t, err)
+			assert.Equal(t, expected, actual)
+		}
+	})
+
+	t.Run("matches map envelope without optional attributes", func(t *testing.T) {
+		expected := NewCloudEventsEnvelope("a", "", "", "", "", "", "", []byte("data"), "")
+		b := AppendCloudEventsEnvelope(nil, "a", "", "", "", "", "", "", []byte("data"), "")
+
+		var actual map[string]interface{}
+		err := jsoniter.Unmarshal(b, &actual)
+		assert.NoError(t, err)
+		assert.Equal(t, expected, actual)
+	})
+
+	t.Run("defaults", func(t *testing.T) {
+		b := AppendCloudEventsEnvelope(nil, "", "", "", "", "topic", "mypubsub", "", []byte("data"), "")
+
+		var actual map[string]interface{}
+		err := jsoniter.Unmarshal(b, &actual)
+		assert.NoError(t, err)
+		assert.NotEmpty(t, actual["id"])
+		assert.Equal(t, DefaultCloudEventSource, actual["source"])
+		assert.Equal(t, DefaultCloudEventType, actual["type"])
+		assert.Equal(t, DefaultCloudEventDataContentType, actual["datacontenttype"])
+	})
+
+	t.Run("appends to existing content", func(t *testing.T) {
+		b := AppendCloudEventsEnvelope([]byte("prefix"), "a", "", "", "", "topic", "mypubsub", "", nil, "")
+		assert.Equal(t, "prefix{", string(b[:7]))
+	})
+
+	t.Run("released buffer is not reused by the encoder", func(t *testing.T) {
+		first := AppendCloudEventsEnvelope(make([]byte, 0, 1024), "first", "", "", "", "topic", "mypubsub", "", nil, "")
+		expected := string(first)
+		AppendCloudEventsEnvelope(nil, "second", "", "", "", "topic", "mypubsub", "", nil, "")
+		assert.Equal(t, expected, string(first))
+	})
+}
+
+var benchmarkPayload = []byte(`{"orderId":"1234","items":[{"sku":"a","quantity":1},{"sku":"b","quantity":2}],"total":12.5}`)
+
+func BenchmarkNewCloudEventsEnvelope(b *testing.B) {
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		envelope := NewCloudEventsEnvelope("a", "source", "type", "subject", "topic", "mypubsub", "", benchmarkPayload, "1")
+		if _, err := jsoniter.Marshal(envelope); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkAppendCloudEventsEnvelope(b *testing.B) {
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		buf := AcquireEnvelopeBuffer()
+		buf.B = AppendCloudEventsEnvelope(buf.B, "a", "source", "type", "subject", "topic", "mypubsub", "", benchmarkPayload, "1")
+		ReleaseEnvelopeBuffer(buf)
+	}
+}
+
+var benchmarkCloudEvent = []byte(`{"specversion":"1.0","id":"a","source":"source","type":"type","topic":"topic","pubsubname":"mypubsub","traceid":"1","datacontenttype":"application/json","data":` + string(benchmarkPayload) + `}`)
+
+func BenchmarkParseCloudEvent(b *testing.B) {
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		if _, err := ParseCloudEvent(benchmarkCloudEvent, "1", ""); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkFromCloudEvent(b *testing.B) {
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		if _, err := FromCloudEvent(benchmarkCloudEvent, "1"); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
@@ -0,0 +1,146 @@
+// ------------------------------------------------------------
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT License.
+// ------------------------------------------------------------
+
+package pubsub
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// ExtensionMetadataPrefix prefixes the publish metadata keys setting extension attributes on the cloud event,
+// e.g. cloudevent.correlationid sets the correlationid extension attribute.
+// The cloudevent.type, cloudevent.source and cloudevent.subject keys override the attributes instead, see ApplyMetadata.
+const ExtensionMetadataPrefix = "cloudevent."
+
+// MaxExtensionsMetadataKey is the component metadata key capping the number of extension attributes
+// accepted on the cloud events the component receives or publishes, there is no limit when it is not set.
+const MaxExtensionsMetadataKey = "maxCloudEventExtensions"
+
+// maxExtensionNameLength is the length extension attribute names should not exceed, as recommended by the spec
+const maxExtensionNameLength = 20
+
+// ErrInvalidExtension is returned when an extension attribute has an invalid or reserved name.
+var ErrInvalidExtension = errors.New("invalid cloud event extension attribute")
+
+// reservedAttributes holds the attributes of the spec and of Dapr, which cannot be set as extensions
+var reservedAttributes = map[string]bool{
+	IDField:              true,
+	SpecVersionField:     true,
+	SourceField:          true,
+	TypeField:            true,
+	SubjectField:         true,
+	DataContentTypeField: true,
+	DataSchemaField:      true,
+	TimeField:            true,
+	TopicField:           true,
+	PubsubNameField:      true,
+	TraceIDField:         true,
+	TraceStateField:      true,
+	ExpirationField:      true,
+	TTLField:             true,
+	DataField:            true,
+	DataBase64Field:      true,
+	SchemaVersionField:   true,
+}
+
+// ValidateExtensionName returns an error wrapping ErrInvalidExtension if the name is not a valid extension attribute name,
+// that is when it is not made of 1 to 20 lower-case letters or digits, or is reserved for a standard or Dapr attribute.
+func ValidateExtensionName(name string) error {
+	if name == "" || len(name) > maxExtensionNameLength {
+		return fmt.Errorf("%w: %s must have 1 to %d characters", ErrInvalidExtension, name, maxExtensionNameLength)
+	}
+
+	for _, c := range name {
+		if (c < 'a' || c > 'z') && (c < '0' || c > '9') {
+			return fmt.Errorf("%w: %s must only contain lower-case letters and digits", ErrInvalidExtension, name)
+		}
+	}
+
+	if reservedAttributes[name] {
+		return fmt.Errorf("%w: %s is a reserved attribute", ErrInvalidExtension, name)
+	}
+
+	return nil
+}
+
+// ParseMaxExtensions returns the number of extension attributes accepted by a component, see MaxExtensionsMetadataKey.
+// It returns 0, which disables the limit, when the component metadata does not set it.
+func ParseMaxExtensions(metadata map[string]string) (int, error) {
+	val, ok := metadata[MaxExtensionsMetadataKey]
+	if !ok || val == "" {
+		return 0, nil
+	}
+
+	maxExtensions, err := strconv.Atoi(val)
+	if err != nil || maxExtensions <= 0 {
+		return 0, fmt.Errorf("%s value must be a positive integer: actual is '%s'", MaxExtensionsMetadataKey, val)
+	}
+
+	return maxExtensions, nil
+}
+
+// GetMetadataExtensions returns the extension attributes set by the publish metadata, see ExtensionMetadataPrefix.
+func GetMetadataExtensions(metadata map[string]string) (map[string]string, error) {
+	extensions := map[string]string{}
+	for key, value := range metadata {
+		if !strings.HasPrefix(key, ExtensionMetadataPrefix) {
+			continue
+		}
+
+		if _, ok := metadataOverrides[key]; ok {
+			continue
+		}
+
+		name := strings.TrimPrefix(key, ExtensionMetadataPrefix)
+		err := ValidateExtensionName(name)
+		if err != nil {
+			return nil, err
+		}
+		extensions[name] = value
+	}
+
+	return extensions, nil
+}
+
+// ApplyExtensions merges the extension attributes set by the publish metadata into the cloud event.
+// Extensions already present on the cloud event are overridden, and the cloud event is left unchanged on error,
+// including when it would carry more than maxExtensions extension attributes, 0 disables the limit.
+func ApplyExtensions(cloudEvent map[string]interface{}, metadata map[string]string, maxExtensions int) error {
+	extensions, err := GetMetadataExtensions(metadata)
+	if err != nil {
+		return err
+	}
+
+	merged := GetExtensions(cloudEvent)
+	for name, value := range extensions {
+		merged[name] = value
+	}
+
+	err = checkExtensions(merged, maxExtensions)
+	if err != nil {
+		return err
+	}
+
+	for name, value := range extensions {
+		cloudEvent[name] = value
+	}
+
+	return nil
+}
+
+// GetExtensions returns the extension attributes of the cloud event, that is all attributes but the standard and Dapr ones.
+func GetExtensions(cloudEvent map[string]interface{}) map[string]interface{} {
+	extensions := map[string]interface{}{}
+	for name, value := range cloudEvent {
+		if !reservedAttributes[name] {
+			extensions[name] = value
+		}
+	}
+
+	return extensions
+}
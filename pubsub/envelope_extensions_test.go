@@ -0,0 +1,105 @@
+// ------------------------------------------------------------
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT License.
+// ------------------------------------------------------------
+
+package pubsub
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValidateExtensionName(t *testing.T) {
+	valid := []string{"correlationid", "partitionkey", "tenant1", "a"}
+	for _, name := range valid {
+		t.Run(fmt.Sprintf("%s is valid", name), func(t *testing.T) {
+			assert.NoError(t, ValidateExtensionName(name))
+		})
+	}
+
+	invalid := []string{"", "correlationId", "tenant-id", "tenant_id", "averyveryverylongextension", TypeField, TraceIDField, DataBase64Field, TTLField}
+	for _, name := range invalid {
+		t.Run(fmt.Sprintf("%s is invalid", name), func(t *testing.T) {
+			err := ValidateExtensionName(name)
+			assert.True(t, errors.Is(err, ErrInvalidExtension))
+		})
+	}
+}
+
+func TestParseMaxExtensions(t *testing.T) {
+	t.Run("no limit when not set", func(t *testing.T) {
+		maxExtensions, err := ParseMaxExtensions(map[string]string{})
+		assert.NoError(t, err)
+		assert.Equal(t, 0, maxExtensions)
+	})
+
+	t.Run("limit", func(t *testing.T) {
+		maxExtensions, err := ParseMaxExtensions(map[string]string{MaxExtensionsMetadataKey: "10"})
+		assert.NoError(t, err)
+		assert.Equal(t, 10, maxExtensions)
+	})
+
+	t.Run("invalid limit", func(t *testing.T) {
+		for _, val := range []string{"0", "-1", "a"} {
+			_, err := ParseMaxExtensions(map[string]string{MaxExtensionsMetadataKey: val})
+			assert.Error(t, err, val)
+		}
+	})
+}
+
+func TestApplyExtensions(t *testing.T) {
+	t.Run("extensions are merged", func(t *testing.T) {
+		envelope := NewCloudEventsEnvelope("a", "", "", "", "topic", "mypubsub", "", []byte("data"), "")
+		err := ApplyExtensions(envelope, map[string]string{
+			"cloudevent.correlationid": "c1",
+			"cloudevent.partitionkey":  "p1",
+			"cloudevent.type":          "overridden.by.apply.metadata",
+			"ttlInSeconds":             "10",
+		}, 0)
+		assert.NoError(t, err)
+		assert.Equal(t, "c1", envelope["correlationid"])
+		assert.Equal(t, "p1", envelope[PartitionKeyField])
+		assert.Equal(t, DefaultCloudEventType, envelope[TypeField])
+
+		assert.Equal(t, map[string]interface{}{"correlationid": "c1", "partitionkey": "p1"}, GetExtensions(envelope))
+	})
+
+	t.Run("reserved attributes are rejected", func(t *testing.T) {
+		envelope := NewCloudEventsEnvelope("a", "", "", "", "topic", "mypubsub", "", []byte("data"), "")
+		err := ApplyExtensions(envelope, map[string]string{
+			"cloudevent.correlationid": "c1",
+			"cloudevent.topic":         "other",
+		}, 0)
+		assert.True(t, errors.Is(err, ErrInvalidExtension))
+		assert.Equal(t, "topic", envelope[TopicField])
+		assert.NotContains(t, envelope, "correlationid")
+	})
+
+	t.Run("extension count is limited", func(t *testing.T) {
+		envelope := NewCloudEventsEnvelope("a", "", "", "", "topic", "mypubsub", "", []byte("data"), "")
+		envelope["existing"] = "a"
+		err := ApplyExtensions(envelope, map[string]string{"cloudevent.correlationid": "c1"}, 1)
+		assert.True(t, errors.Is(err, ErrTooManyExtensions))
+		assert.NotContains(t, envelope, "correlationid")
+	})
+
+	t.Run("extensions reach subscribers", func(t *testing.T) {
+		envelope := NewCloudEventsEnvelope("a", "", "", "", "topic", "mypubsub", "", []byte("data"), "1")
+		err := ApplyExtensions(envelope, map[string]string{"cloudevent.tenantid": "t1"}, 0)
+		assert.NoError(t, err)
+		b, _ := json.Marshal(envelope)
+
+		n, err := FromCloudEvent(b, "")
+		assert.NoError(t, err)
+		assert.Equal(t, map[string]interface{}{"tenantid": "t1"}, GetExtensions(n))
+
+		e, err := ParseCloudEvent(b, "", "")
+		assert.NoError(t, err)
+		assert.Equal(t, "t1", e.Extensions["tenantid"])
+	})
+}
@@ -0,0 +1,70 @@
+// ------------------------------------------------------------
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT License.
+// ------------------------------------------------------------
+
+package pubsub
+
+import (
+	"fmt"
+
+	jsoniter "github.com/json-iterator/go"
+)
+
+// KeySource selects the cloud event attribute used as message key by key-value brokers
+type KeySource string
+
+const (
+	// KeySourcePartitionKey keys messages by the partitionkey extension attribute
+	KeySourcePartitionKey KeySource = "partitionKey"
+	// KeySourceOrderingKey keys messages by the orderingkey extension attribute
+	KeySourceOrderingKey KeySource = "orderingKey"
+	// KeySourceSubject keys messages by the subject attribute
+	KeySourceSubject KeySource = "subject"
+
+	// PartitionKeyField is the extension attribute holding the partition key of the event
+	PartitionKeyField = "partitionkey"
+	// OrderingKeyField is the extension attribute holding the ordering key of the event
+	OrderingKeyField = "orderingkey"
+)
+
+// keySourceFields maps the key sources to the attribute they read
+var keySourceFields = map[KeySource]string{
+	KeySourcePartitionKey: PartitionKeyField,
+	KeySourceOrderingKey:  OrderingKeyField,
+	KeySourceSubject:      SubjectField,
+}
+
+// KeyValue returns the message key and value to produce the cloud event to a key-value broker.
+// The key is read from the attribute selected by source, falling back to the event id
+// when the attribute is missing or empty. The value is the JSON serialization of the envelope.
+func KeyValue(cloudEvent map[string]interface{}, source KeySource) (key []byte, value []byte, err error) {
+	field, ok := keySourceFields[source]
+	if !ok {
+		return nil, nil, fmt.Errorf("unknown key source %s", source)
+	}
+
+	k := attributeString(cloudEvent[field])
+	if k == "" {
+		k = attributeString(cloudEvent[IDField])
+	}
+
+	value, err = jsoniter.Marshal(cloudEvent)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return []byte(k), value, nil
+}
+
+// attributeString returns the string form of an attribute value, or "" when absent
+func attributeString(value interface{}) string {
+	switch v := value.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	default:
+		return fmt.Sprint(v)
+	}
+}
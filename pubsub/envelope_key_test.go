@@ -0,0 +1,63 @@
+// ------------------------------------------------------------
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT License.
+// ------------------------------------------------------------
+
+package pubsub
+
+import (
+	"testing"
+
+	jsoniter "github.com/json-iterator/go"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestKeyValue(t *testing.T) {
+	envelope := func() map[string]interface{} {
+		e := NewCloudEventsEnvelope("a", "", "", "mysubject", "topic", "mypubsub", "", []byte("data"), "")
+		e[PartitionKeyField] = "partition"
+		e[OrderingKeyField] = "ordering"
+
+		return e
+	}
+
+	t.Run("key sources", func(t *testing.T) {
+		for source, expected := range map[KeySource]string{
+			KeySourcePartitionKey: "partition",
+			KeySourceOrderingKey:  "ordering",
+			KeySourceSubject:      "mysubject",
+		} {
+			e := envelope()
+			key, value, err := KeyValue(e, source)
+			assert.NoError(t, err)
+			assert.Equal(t, []byte(expected), key, source)
+
+			var actual map[string]interface{}
+			err = jsoniter.Unmarshal(value, &actual)
+			assert.NoError(t, err)
+			assert.Equal(t, e, actual)
+		}
+	})
+
+	t.Run("missing key falls back to event id", func(t *testing.T) {
+		e := NewCloudEventsEnvelope("a", "", "", "", "topic", "mypubsub", "", []byte("data"), "")
+		for _, source := range []KeySource{KeySourcePartitionKey, KeySourceOrderingKey, KeySourceSubject} {
+			key, _, err := KeyValue(e, source)
+			assert.NoError(t, err)
+			assert.Equal(t, []byte("a"), key, source)
+		}
+	})
+
+	t.Run("non string key", func(t *testing.T) {
+		e := envelope()
+		e[PartitionKeyField] = 5.0
+		key, _, err := KeyValue(e, KeySourcePartitionKey)
+		assert.NoError(t, err)
+		assert.Equal(t, []byte("5"), key)
+	})
+
+	t.Run("unknown key source", func(t *testing.T) {
+		_, _, err := KeyValue(envelope(), "other")
+		assert.Error(t, err)
+	})
+}
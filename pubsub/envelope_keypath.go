@@ -0,0 +1,174 @@
+// ------------------------------------------------------------
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT License.
+// ------------------------------------------------------------
+
+package pubsub
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+
+	jsoniter "github.com/json-iterator/go"
+)
+
+const (
+	// PartitionKeyMetadataKey is the publish metadata key setting the partition key of the message
+	PartitionKeyMetadataKey = "partitionKey"
+	// PartitionKeyPathMetadataKey is the component and publish metadata key of the path into the cloud event data
+	// the partition key is read from, when the partitionKey metadata is not set
+	PartitionKeyPathMetadataKey = "partitionKeyPath"
+)
+
+// KeyPath is a path into JSON data, a subset of JSONPath selecting a single value
+// with member and array index accessors, e.g. $.customer.id, $.items[0].sku or $['order id'].
+type KeyPath []keyPathSegment
+
+type keyPathSegment struct {
+	name  string
+	index int
+	// isIndex tells whether the segment is an array index rather than a member name
+	isIndex bool
+}
+
+// ParseKeyPath parses a key path, the leading $ being optional. An empty path returns a nil KeyPath.
+func ParseKeyPath(path string) (KeyPath, error) {
+	rest := strings.TrimSpace(path)
+	if strings.HasPrefix(rest, "$") {
+		rest = rest[1:]
+	} else if rest != "" && rest[0] != '[' {
+		// the first member name of paths without $ is not prefixed by a dot
+		rest = "." + rest
+	}
+	if rest == "" {
+		return nil, nil
+	}
+
+	var p KeyPath
+	for rest != "" {
+		var segment keyPathSegment
+		var err error
+		switch rest[0] {
+		case '.':
+			end := strings.IndexAny(rest[1:], ".[") + 1
+			if end == 0 {
+				end = len(rest)
+			}
+			segment.name = rest[1:end]
+			if segment.name == "" {
+				return nil, fmt.Errorf("invalid key path %s: empty member name", path)
+			}
+			rest = rest[end:]
+		case '[':
+			end := strings.IndexByte(rest, ']')
+			if end < 0 {
+				return nil, fmt.Errorf("invalid key path %s: missing ]", path)
+			}
+			segment, err = parseKeyPathBracket(rest[1:end])
+			if err != nil {
+				return nil, fmt.Errorf("invalid key path %s: %s", path, err)
+			}
+			rest = rest[end+1:]
+		default:
+			return nil, fmt.Errorf("invalid key path %s: unexpected %q", path, rest[0])
+		}
+		p = append(p, segment)
+	}
+
+	return p, nil
+}
+
+// parseKeyPathBracket parses the content of a bracket accessor, a quoted member name or an array index
+func parseKeyPathBracket(s string) (keyPathSegment, error) {
+	if len(s) >= 2 && (s[0] == '\'' || s[0] == '"') && s[len(s)-1] == s[0] {
+		return keyPathSegment{name: s[1 : len(s)-1]}, nil
+	}
+
+	index, err := strconv.Atoi(s)
+	if err != nil || index < 0 {
+		return keyPathSegment{}, fmt.Errorf("invalid array index %s", s)
+	}
+
+	return keyPathSegment{index: index, isIndex: true}, nil
+}
+
+// Extract returns the string form of the value at the path in decoded JSON data, false if there is none.
+// Strings are returned as is, and other values as JSON.
+func (p KeyPath) Extract(data interface{}) (string, bool) {
+	value := data
+	for _, segment := range p {
+		if segment.isIndex {
+			a, ok := value.([]interface{})
+			if !ok || segment.index >= len(a) {
+				return "", false
+			}
+			value = a[segment.index]
+		} else {
+			m, ok := value.(map[string]interface{})
+			if !ok {
+				return "", false
+			}
+			if value, ok = m[segment.name]; !ok {
+				return "", false
+			}
+		}
+	}
+
+	switch v := value.(type) {
+	case nil:
+		return "", false
+	case string:
+		return v, v != ""
+	default:
+		// encoding/json sorts the members of objects, so that keys are stable
+		b, err := json.Marshal(v)
+		if err != nil {
+			return "", false
+		}
+
+		return string(b), true
+	}
+}
+
+// PartitionKey returns the partition key to publish a cloud event with: the partitionKey publish metadata,
+// or else the value at the key path in the cloud event data. The key path of the partitionKeyPath publish metadata
+// overrides that of the component. An empty key is returned when there is none, so that the broker picks the partition.
+func PartitionKey(cloudEvent []byte, metadata map[string]string, componentPath KeyPath) (string, error) {
+	if key := metadata[PartitionKeyMetadataKey]; key != "" {
+		return key, nil
+	}
+
+	path := componentPath
+	if val := metadata[PartitionKeyPathMetadataKey]; val != "" {
+		var err error
+		path, err = ParseKeyPath(val)
+		if err != nil {
+			return "", err
+		}
+	}
+	if len(path) == 0 {
+		return "", nil
+	}
+
+	e, err := ParseCloudEvent(cloudEvent, "", "")
+	if err != nil {
+		return "", fmt.Errorf("%s requires a cloud event: %s", PartitionKeyPathMetadataKey, err)
+	}
+
+	data, err := e.DecodeData()
+	if err != nil {
+		return "", err
+	}
+	if s, ok := data.(string); ok && isJSONContentType(e.DataContentType) {
+		// JSON data may be carried as a string
+		if err = jsoniter.UnmarshalFromString(s, &data); err != nil {
+			return "", nil
+		}
+	}
+
+	key, _ := path.Extract(data)
+
+	return key, nil
+}
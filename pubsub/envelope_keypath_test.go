@@ -0,0 +1,109 @@
+// ------------------------------------------------------------
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT License.
+// ------------------------------------------------------------
+
+package pubsub
+
+import (
+	"testing"
+
+	jsoniter "github.com/json-iterator/go"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestKeyPath(t *testing.T) {
+	var data interface{}
+	err := jsoniter.UnmarshalFromString(`{"customer":{"id":"c1","tier":2},"items":[{"sku":"s1"},{"sku":"s2"}],"order id":"o1","empty":""}`, &data)
+	assert.NoError(t, err)
+
+	t.Run("extract", func(t *testing.T) {
+		for path, expected := range map[string]string{
+			"$.customer.id":   "c1",
+			"customer.id":     "c1",
+			"$.customer.tier": "2",
+			"$.items[1].sku":  "s2",
+			"$['order id']":   "o1",
+			`$["order id"]`:   "o1",
+			"$.customer":      `{"id":"c1","tier":2}`,
+		} {
+			p, err := ParseKeyPath(path)
+			assert.NoError(t, err, path)
+
+			key, ok := p.Extract(data)
+			assert.True(t, ok, path)
+			assert.Equal(t, expected, key, path)
+		}
+	})
+
+	t.Run("missing values", func(t *testing.T) {
+		for _, path := range []string{"$.customer.name", "$.items[2].sku", "$.customer[0]", "$.items.sku", "$.empty"} {
+			p, err := ParseKeyPath(path)
+			assert.NoError(t, err, path)
+
+			_, ok := p.Extract(data)
+			assert.False(t, ok, path)
+		}
+	})
+
+	t.Run("empty path", func(t *testing.T) {
+		p, err := ParseKeyPath("$")
+		assert.NoError(t, err)
+		assert.Nil(t, p)
+	})
+
+	t.Run("invalid paths", func(t *testing.T) {
+		for _, path := range []string{"$..id", "$.items[", "$.items[-1]", "$.items[a]", "$x"} {
+			_, err := ParseKeyPath(path)
+			assert.Error(t, err, path)
+		}
+	})
+}
+
+func TestPartitionKey(t *testing.T) {
+	event := []byte(`{"specversion":"1.0","id":"a","datacontenttype":"application/json","data":{"customer":{"id":"c1"}}}`)
+	path, _ := ParseKeyPath("$.customer.id")
+
+	t.Run("component path", func(t *testing.T) {
+		key, err := PartitionKey(event, map[string]string{}, path)
+		assert.NoError(t, err)
+		assert.Equal(t, "c1", key)
+	})
+
+	t.Run("partitionKey metadata", func(t *testing.T) {
+		key, err := PartitionKey(event, map[string]string{PartitionKeyMetadataKey: "p"}, path)
+		assert.NoError(t, err)
+		assert.Equal(t, "p", key)
+	})
+
+	t.Run("partitionKeyPath metadata", func(t *testing.T) {
+		key, err := PartitionKey(event, map[string]string{PartitionKeyPathMetadataKey: "$.customer"}, path)
+		assert.NoError(t, err)
+		assert.Equal(t, `{"id":"c1"}`, key)
+
+		_, err = PartitionKey(event, map[string]string{PartitionKeyPathMetadataKey: "$."}, path)
+		assert.Error(t, err)
+	})
+
+	t.Run("JSON data carried as a string", func(t *testing.T) {
+		e, _ := NewCloudEvent("a", "", "", "", "topic", "pubsub", "application/json", []byte(`{"customer":{"id":"c2"}}`), "").Marshal()
+		key, err := PartitionKey(e, map[string]string{}, path)
+		assert.NoError(t, err)
+		assert.Equal(t, "c2", key)
+	})
+
+	t.Run("no key", func(t *testing.T) {
+		key, err := PartitionKey(event, map[string]string{}, nil)
+		assert.NoError(t, err)
+		assert.Empty(t, key)
+
+		key, err = PartitionKey([]byte(`{"specversion":"1.0","id":"a","data":"text"}`), map[string]string{}, path)
+		assert.NoError(t, err)
+		assert.Empty(t, key)
+	})
+
+	t.Run("not a cloud event", func(t *testing.T) {
+		_, err := PartitionKey([]byte("text"), map[string]string{}, path)
+		assert.Error(t, err)
+	})
+}
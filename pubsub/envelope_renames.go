@@ -0,0 +1,107 @@
+// ------------------------------------------------------------
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT License.
+// ------------------------------------------------------------
+
+package pubsub
+
+import (
+	"fmt"
+	"strings"
+
+	jsoniter "github.com/json-iterator/go"
+)
+
+// AttributeRenames maps canonical cloud event attribute names to the names consumers expect,
+// e.g. type to eventType, so that envelopes can be produced in a consumer's dialect.
+// Attributes that are not mapped keep their standard name.
+type AttributeRenames map[string]string
+
+// ParseAttributeRenames parses renames in the "canonical=output,canonical=output" format,
+// e.g. "type=eventType,datacontenttype=contentType".
+func ParseAttributeRenames(value string) (AttributeRenames, error) {
+	renames := AttributeRenames{}
+	outputs := map[string]bool{}
+	if value == "" {
+		return renames, nil
+	}
+
+	for _, mapping := range strings.Split(value, ",") {
+		parts := strings.SplitN(mapping, "=", 2)
+		if len(parts) != 2 {
+			return nil, fmt.Errorf("invalid attribute rename %s, expected canonical=output", mapping)
+		}
+
+		canonical, output := strings.TrimSpace(parts[0]), strings.TrimSpace(parts[1])
+		if canonical == "" || output == "" {
+			return nil, fmt.Errorf("invalid attribute rename %s, expected canonical=output", mapping)
+		}
+
+		if _, ok := renames[canonical]; ok {
+			return nil, fmt.Errorf("attribute %s is renamed more than once", canonical)
+		}
+
+		if outputs[output] {
+			return nil, fmt.Errorf("several attributes are renamed to %s", output)
+		}
+
+		renames[canonical] = output
+		outputs[output] = true
+	}
+
+	return renames, nil
+}
+
+// Apply returns a copy of the cloud event with the attributes renamed to their output names.
+func (r AttributeRenames) Apply(cloudEvent map[string]interface{}) map[string]interface{} {
+	return rename(cloudEvent, r)
+}
+
+// Reverse returns a copy of the cloud event with the output names renamed back to the canonical names.
+func (r AttributeRenames) Reverse(cloudEvent map[string]interface{}) map[string]interface{} {
+	reversed := make(map[string]string, len(r))
+	for canonical, output := range r {
+		reversed[output] = canonical
+	}
+
+	return rename(cloudEvent, reversed)
+}
+
+func rename(cloudEvent map[string]interface{}, names map[string]string) map[string]interface{} {
+	renamed := make(map[string]interface{}, len(cloudEvent))
+	for k, v := range cloudEvent {
+		if name, ok := names[k]; ok {
+			k = name
+		}
+		renamed[k] = v
+	}
+
+	return renamed
+}
+
+// MarshalRenamed returns the JSON encoding of the cloud event with the attributes renamed.
+func (e *CloudEvent) MarshalRenamed(renames AttributeRenames) ([]byte, error) {
+	return jsoniter.Marshal(renames.Apply(e.ToMap()))
+}
+
+// FromRenamedCloudEvent is FromCloudEvent for events produced with renamed attributes,
+// the returned map uses the canonical attribute names.
+func FromRenamedCloudEvent(cloudEvent []byte, renames AttributeRenames, traceID string, traceState string) (map[string]interface{}, error) {
+	var m map[string]interface{}
+	err := jsoniter.Unmarshal(cloudEvent, &m)
+	if err != nil {
+		return nil, err
+	}
+
+	e := &CloudEvent{}
+	e.fromMap(renames.Reverse(m))
+
+	err = checkData(e)
+	if err != nil {
+		return nil, err
+	}
+
+	setTraceContext(e, traceID, traceState)
+
+	return e.ToMap(), nil
+}
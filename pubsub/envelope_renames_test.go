@@ -0,0 +1,60 @@
+// ------------------------------------------------------------
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT License.
+// ------------------------------------------------------------
+
+package pubsub
+
+import (
+	"testing"
+
+	jsoniter "github.com/json-iterator/go"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAttributeRenames(t *testing.T) {
+	renames, err := ParseAttributeRenames("type=eventType, datacontenttype=contentType")
+	assert.NoError(t, err)
+	assert.Equal(t, AttributeRenames{TypeField: "eventType", DataContentTypeField: "contentType"}, renames)
+
+	t.Run("renamed output", func(t *testing.T) {
+		e := NewCloudEvent("a", "source", "mytype", "", "topic", "mypubsub", "application/xml", []byte("<root/>"), "1")
+		b, err := e.MarshalRenamed(renames)
+		assert.NoError(t, err)
+
+		var actual map[string]interface{}
+		err = jsoniter.Unmarshal(b, &actual)
+		assert.NoError(t, err)
+		assert.Equal(t, "mytype", actual["eventType"])
+		assert.Equal(t, "application/xml", actual["contentType"])
+		assert.NotContains(t, actual, TypeField)
+		assert.NotContains(t, actual, DataContentTypeField)
+		assert.Equal(t, "source", actual[SourceField])
+	})
+
+	t.Run("FromRenamedCloudEvent reverses the renames", func(t *testing.T) {
+		envelope := NewCloudEventsEnvelope("a", "source", "mytype", "", "topic", "mypubsub", "application/xml", []byte("<root/>"), "1")
+		b, err := jsoniter.Marshal(renames.Apply(envelope))
+		assert.NoError(t, err)
+
+		actual, err := FromRenamedCloudEvent(b, renames, "", "")
+		assert.NoError(t, err)
+		assert.Equal(t, envelope, actual)
+	})
+
+	t.Run("default names", func(t *testing.T) {
+		envelope := NewCloudEventsEnvelope("a", "source", "mytype", "", "topic", "mypubsub", "", []byte("data"), "1")
+		assert.Equal(t, envelope, AttributeRenames{}.Apply(envelope))
+
+		renames, err := ParseAttributeRenames("")
+		assert.NoError(t, err)
+		assert.Empty(t, renames)
+	})
+
+	t.Run("invalid renames", func(t *testing.T) {
+		for _, value := range []string{"type", "type=", "=eventType", "type=a,type=b", "type=a,source=a"} {
+			_, err := ParseAttributeRenames(value)
+			assert.Error(t, err, value)
+		}
+	})
+}
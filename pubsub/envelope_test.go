@@ -6,7 +6,9 @@
 package pubsub
 
 import (
+	"encoding/base64"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"math"
 	"strings"
@@ -41,6 +43,13 @@ func TestEnvelopeXML(t *testing.T) {
 		assert.Equal(t, "mypubsub", envelope[pubsubNameField])
 	})
 
+	t.Run("xml content that is also valid JSON", func(t *testing.T) {
+		str := `"<root/>"`
+		envelope := NewCloudEventsEnvelope("a", "", "", "", "routed.topic", "mypubsub", "application/xml", []byte(str), "")
+		assert.Equal(t, "application/xml", envelope[dataContentTypeField])
+		assert.Equal(t, str, envelope[dataField])
+	})
+
 	t.Run("xml without content-type", func(t *testing.T) {
 		str := `<root/>`
 		envelope := NewCloudEventsEnvelope("a", "", "", "", "routed.topic", "mypubsub", "", []byte(str), "")
@@ -186,13 +195,45 @@ func TestCreateCloudEventsEnvelopeExpiration(t *testing.T) {
 
 func TestSetTraceID(t *testing.T) {
 	t.Run("trace id is present", func(t *testing.T) {
-		m := map[string]interface{}{
-			"specversion": "1.0",
-			"customfield": "a",
+		e := &CloudEvent{
+			SpecVersion: "1.0",
+			Extensions: map[string]interface{}{
+				"customfield": "a",
+			},
+		}
+
+		setTraceContext(e, "1", "")
+		assert.Equal(t, "1", e.ToMap()[TraceIDField])
+	})
+
+	t.Run("trace id is not overwritten", func(t *testing.T) {
+		e := &CloudEvent{
+			SpecVersion: "1.0",
+			TraceID:     "original",
 		}
 
-		setTraceContext(m, "1")
-		assert.Equal(t, "1", m[TraceIDField])
+		setTraceContext(e, "1", "")
+		assert.Equal(t, "original", e.TraceID)
+	})
+
+	t.Run("trace state is set with the trace id", func(t *testing.T) {
+		e := &CloudEvent{SpecVersion: "1.0"}
+
+		setTraceContext(e, "1", "vendor=a")
+		assert.Equal(t, "1", e.TraceID)
+		assert.Equal(t, "vendor=a", e.ToMap()[TraceStateField])
+	})
+
+	t.Run("trace state is kept with the existing trace id", func(t *testing.T) {
+		e := &CloudEvent{
+			SpecVersion: "1.0",
+			TraceID:     "original",
+			TraceState:  "vendor=original",
+		}
+
+		setTraceContext(e, "1", "vendor=a")
+		assert.Equal(t, "original", e.TraceID)
+		assert.Equal(t, "vendor=original", e.TraceState)
 	})
 }
 
@@ -215,4 +256,407 @@ func TestNewFromExisting(t *testing.T) {
 		_, err := FromCloudEvent([]byte("a"), "1")
 		assert.Error(t, err)
 	})
+
+	t.Run("existing trace id is preserved", func(t *testing.T) {
+		n, err := FromCloudEvent([]byte(`{"specversion":"1.0","traceid":"original"}`), "1")
+		assert.NoError(t, err)
+		assert.Equal(t, "original", n[TraceIDField])
+
+		n, err = FromCloudEvent([]byte(`{"specversion":"1.0","traceid":"original"}`), "")
+		assert.NoError(t, err)
+		assert.Equal(t, "original", n[TraceIDField])
+	})
+
+	t.Run("existing trace state is preserved", func(t *testing.T) {
+		n, err := FromCloudEventWithTraceState([]byte(`{"specversion":"1.0","traceid":"original","tracestate":"vendor=original"}`), "1", "vendor=a")
+		assert.NoError(t, err)
+		assert.Equal(t, "original", n[TraceIDField])
+		assert.Equal(t, "vendor=original", n[TraceStateField])
+	})
+
+	t.Run("trace state is set on events without trace context", func(t *testing.T) {
+		n, err := FromCloudEventWithTraceState([]byte(`{"specversion":"1.0"}`), "1", "vendor=a")
+		assert.NoError(t, err)
+		assert.Equal(t, "1", n[TraceIDField])
+		assert.Equal(t, "vendor=a", n[TraceStateField])
+
+		n, err = FromCloudEvent([]byte(`{"specversion":"1.0"}`), "1")
+		assert.NoError(t, err)
+		assert.NotContains(t, n, TraceStateField)
+	})
+
+	t.Run("trace context round trips across a hop", func(t *testing.T) {
+		envelope := NewCloudEventsEnvelopeWithTraceState("a", "", "", "", "topic", "mypubsub", "", []byte("data"), "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01", "congo=t61rcWkgMzE,rojo=00f067aa0ba902b7")
+		b, err := json.Marshal(envelope)
+		assert.NoError(t, err)
+
+		n, err := FromCloudEventWithTraceState(b, "00-0af7651916cd43dd8448eb211c80319c-b7ad6b7169203331-01", "vendor=subscriber")
+		assert.NoError(t, err)
+		assert.Equal(t, "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01", n[TraceIDField])
+		assert.Equal(t, "congo=t61rcWkgMzE,rojo=00f067aa0ba902b7", n[TraceStateField])
+
+		b = AppendCloudEventsEnvelopeWithTraceState(nil, "a", "", "", "", "topic", "mypubsub", "", []byte("data"), "1", "vendor=a")
+		n, err = FromCloudEventWithTraceState(b, "2", "vendor=b")
+		assert.NoError(t, err)
+		assert.Equal(t, "1", n[TraceIDField])
+		assert.Equal(t, "vendor=a", n[TraceStateField])
+	})
+
+	t.Run("empty trace id is replaced", func(t *testing.T) {
+		n, err := FromCloudEvent([]byte(`{"specversion":"1.0","traceid":""}`), "1")
+		assert.NoError(t, err)
+		assert.Equal(t, "1", n[TraceIDField])
+	})
+
+	t.Run("extension attributes are preserved", func(t *testing.T) {
+		n, err := FromCloudEvent([]byte(`{"specversion":"1.0","correlationid":"c1","tenant":{"id":5},"comexampleothervalue":5}`), "1")
+		assert.NoError(t, err)
+		assert.Equal(t, "c1", n["correlationid"])
+		assert.Equal(t, map[string]interface{}{"id": 5.0}, n["tenant"])
+		assert.Equal(t, 5.0, n["comexampleothervalue"])
+	})
+}
+
+func TestParseCloudEvent(t *testing.T) {
+	t.Run("valid cloudevent", func(t *testing.T) {
+		e, err := ParseCloudEvent([]byte(`{"specversion":"1.0","id":"a","customfield":"a","data":{"b":1}}`), "1", "vendor=a")
+		assert.NoError(t, err)
+		assert.Equal(t, "1.0", e.SpecVersion)
+		assert.Equal(t, "a", e.ID)
+		assert.Equal(t, "a", e.Extensions["customfield"])
+		assert.Equal(t, "1", e.TraceID)
+		assert.Equal(t, "vendor=a", e.TraceState)
+
+		data, err := e.DecodeData()
+		assert.NoError(t, err)
+		assert.Equal(t, map[string]interface{}{"b": 1.0}, data)
+	})
+
+	t.Run("invalid cloudevent", func(t *testing.T) {
+		_, err := ParseCloudEvent([]byte("a"), "1", "")
+		assert.Error(t, err)
+	})
+
+	t.Run("existing trace id is preserved", func(t *testing.T) {
+		e, err := ParseCloudEvent([]byte(`{"specversion":"1.0","traceid":"original"}`), "1", "")
+		assert.NoError(t, err)
+		assert.Equal(t, "original", e.TraceID)
+	})
+
+	t.Run("same map as FromCloudEvent", func(t *testing.T) {
+		for _, event := range []string{
+			`{"specversion":"1.0","id":"a","customfield":"a","data":{"b":1}}`,
+			`{"specversion":"1.0","id":"","subject":"","time":1,"data":"a"}`,
+			`{"specversion":"1.0","traceid":"original","tracestate":"vendor=a"}`,
+			`{"specversion":"1.0","traceid":"","tracestate":"vendor=a","data_base64":"YQ=="}`,
+		} {
+			expected, err := FromCloudEventWithTraceState([]byte(event), "1", "")
+			assert.NoError(t, err)
+			e, err := ParseCloudEvent([]byte(event), "1", "")
+			assert.NoError(t, err)
+			assert.Equal(t, expected, e.ToMap(), event)
+		}
+	})
+
+	t.Run("not an object", func(t *testing.T) {
+		_, err := FromCloudEvent([]byte("null"), "1")
+		assert.Error(t, err)
+		_, err = ParseCloudEvent([]byte("null"), "1", "")
+		assert.Error(t, err)
+	})
+}
+
+func TestSchemaVersion(t *testing.T) {
+	t.Run("round trip", func(t *testing.T) {
+		envelope := NewCloudEventsEnvelope("a", "", "", "", "routed.topic", "mypubsub", "", []byte("data"), "")
+		SetSchemaVersion(envelope, "2.1")
+		b, err := json.Marshal(envelope)
+		assert.NoError(t, err)
+
+		n, err := FromCloudEvent(b, "")
+		assert.NoError(t, err)
+		version, ok := GetSchemaVersion(n)
+		assert.True(t, ok)
+		assert.Equal(t, "2.1", version)
+	})
+
+	t.Run("from metadata", func(t *testing.T) {
+		envelope := NewCloudEventsEnvelope("a", "", "", "", "routed.topic", "mypubsub", "", []byte("data"), "")
+		ApplyMetadata(envelope, nil, map[string]string{
+			SchemaVersionMetadataKey: "3",
+		})
+		version, ok := GetSchemaVersion(envelope)
+		assert.True(t, ok)
+		assert.Equal(t, "3", version)
+	})
+
+	t.Run("not tagged", func(t *testing.T) {
+		envelope := NewCloudEventsEnvelope("a", "", "", "", "routed.topic", "mypubsub", "", []byte("data"), "")
+		_, ok := GetSchemaVersion(envelope)
+		assert.False(t, ok)
+	})
+
+	t.Run("empty version removes tag", func(t *testing.T) {
+		envelope := NewCloudEventsEnvelope("a", "", "", "", "routed.topic", "mypubsub", "", []byte("data"), "")
+		SetSchemaVersion(envelope, "1")
+		SetSchemaVersion(envelope, "")
+		assert.NotContains(t, envelope, SchemaVersionField)
+	})
+}
+
+func TestGetData(t *testing.T) {
+	t.Run("data as object", func(t *testing.T) {
+		n, err := FromCloudEvent([]byte(`{"specversion":"1.0","datacontenttype":"application/json","data":{"a":"b","c":[1,2]}}`), "")
+		assert.NoError(t, err)
+		assert.IsType(t, map[string]interface{}{}, n[DataField])
+
+		data, err := GetData(n)
+		assert.NoError(t, err)
+		assert.JSONEq(t, `{"a":"b","c":[1,2]}`, string(data))
+	})
+
+	t.Run("data as string", func(t *testing.T) {
+		n, err := FromCloudEvent([]byte(`{"specversion":"1.0","datacontenttype":"text/plain","data":"hello"}`), "")
+		assert.NoError(t, err)
+		assert.Equal(t, "hello", n[DataField])
+
+		data, err := GetData(n)
+		assert.NoError(t, err)
+		assert.Equal(t, []byte("hello"), data)
+	})
+
+	t.Run("data from envelope", func(t *testing.T) {
+		envelope := NewCloudEventsEnvelope("a", "", "", "", "routed.topic", "mypubsub", "", []byte(`{"a":"b"}`), "")
+		data, err := GetData(envelope)
+		assert.NoError(t, err)
+		assert.Equal(t, []byte(`{"a":"b"}`), data)
+	})
+
+	t.Run("no data", func(t *testing.T) {
+		data, err := GetData(map[string]interface{}{"specversion": "1.0"})
+		assert.NoError(t, err)
+		assert.Nil(t, data)
+	})
+}
+
+func TestBinaryData(t *testing.T) {
+	binaryData := []byte{0x0a, 0xff, 0xfe, 0x00, 0x80}
+
+	t.Run("non UTF-8 data", func(t *testing.T) {
+		envelope := NewCloudEventsEnvelope("a", "", "", "", "routed.topic", "mypubsub", "", binaryData, "")
+		assert.Equal(t, BinaryCloudEventDataContentType, envelope[dataContentTypeField])
+		assert.Equal(t, base64.StdEncoding.EncodeToString(binaryData), envelope[DataBase64Field])
+		assert.NotContains(t, envelope, DataField)
+	})
+
+	t.Run("non UTF-8 data keeps content type", func(t *testing.T) {
+		envelope := NewCloudEventsEnvelope("a", "", "", "", "routed.topic", "mypubsub", "application/x-protobuf", binaryData, "")
+		assert.Equal(t, "application/x-protobuf", envelope[dataContentTypeField])
+		assert.Contains(t, envelope, DataBase64Field)
+	})
+
+	t.Run("text flagged as binary by content type", func(t *testing.T) {
+		envelope := NewCloudEventsEnvelope("a", "", "", "", "routed.topic", "mypubsub", "image/png", []byte("abc"), "")
+		assert.Equal(t, "image/png", envelope[dataContentTypeField])
+		assert.Equal(t, base64.StdEncoding.EncodeToString([]byte("abc")), envelope[DataBase64Field])
+	})
+
+	t.Run("text and JSON keep using data", func(t *testing.T) {
+		envelope := NewCloudEventsEnvelope("a", "", "", "", "routed.topic", "mypubsub", "", []byte("héllo"), "")
+		assert.Equal(t, "héllo", envelope[DataField])
+		assert.NotContains(t, envelope, DataBase64Field)
+
+		envelope = NewCloudEventsEnvelope("a", "", "", "", "routed.topic", "mypubsub", "", []byte(`{"a":1}`), "")
+		assert.Equal(t, `{"a":1}`, envelope[DataField])
+		assert.NotContains(t, envelope, DataBase64Field)
+	})
+
+	t.Run("binary content type with data that is valid JSON", func(t *testing.T) {
+		envelope := NewCloudEventsEnvelope("a", "", "", "", "routed.topic", "mypubsub", "application/octet-stream", []byte("12"), "")
+		assert.Equal(t, "application/octet-stream", envelope[dataContentTypeField])
+		assert.Equal(t, base64.StdEncoding.EncodeToString([]byte("12")), envelope[DataBase64Field])
+	})
+
+	t.Run("round trip", func(t *testing.T) {
+		envelope := NewCloudEventsEnvelope("a", "", "", "", "routed.topic", "mypubsub", "", binaryData, "")
+		b, err := json.Marshal(envelope)
+		assert.NoError(t, err)
+
+		n, err := FromCloudEvent(b, "")
+		assert.NoError(t, err)
+		data, err := GetData(n)
+		assert.NoError(t, err)
+		assert.Equal(t, binaryData, data)
+	})
+
+	t.Run("invalid data_base64", func(t *testing.T) {
+		_, err := GetData(map[string]interface{}{DataBase64Field: "not base64!"})
+		assert.Error(t, err)
+	})
+
+	t.Run("inbound invalid data_base64", func(t *testing.T) {
+		_, err := FromCloudEvent([]byte(`{"specversion":"1.0","data_base64":"not base64!"}`), "")
+		assert.Error(t, err)
+	})
+
+	t.Run("inbound data and data_base64", func(t *testing.T) {
+		_, err := FromCloudEvent([]byte(`{"specversion":"1.0","data":"a","data_base64":"YQ=="}`), "")
+		assert.Error(t, err)
+
+		_, err = FromRenamedCloudEvent([]byte(`{"specversion":"1.0","data":"a","data_base64":"YQ=="}`), nil, "", "")
+		assert.Error(t, err)
+	})
+
+	t.Run("inbound data_base64 is decoded", func(t *testing.T) {
+		e, err := ParseCloudEvent([]byte(`{"specversion":"1.0","datacontenttype":"application/octet-stream","data_base64":"Cv/+AIA="}`), "", "")
+		assert.NoError(t, err)
+		data, err := e.GetData()
+		assert.NoError(t, err)
+		assert.Equal(t, binaryData, data)
+	})
+}
+
+func TestCheckExpiration(t *testing.T) {
+	past := time.Now().UTC().Add(time.Hour * -24)
+	future := time.Now().UTC().Add(time.Hour * 24)
+
+	tests := []struct {
+		name       string
+		cloudEvent map[string]interface{}
+		expired    bool
+		invalid    bool
+	}{
+		{"no expiration", map[string]interface{}{}, false, false},
+		{"empty expiration", map[string]interface{}{expirationField: ""}, false, false},
+		{"RFC3339 expired", map[string]interface{}{expirationField: past.Format(time.RFC3339)}, true, false},
+		{"RFC3339 not expired", map[string]interface{}{expirationField: future.Format(time.RFC3339)}, false, false},
+		{"Unix float expired", map[string]interface{}{expirationField: float64(past.Unix())}, true, false},
+		{"Unix float not expired", map[string]interface{}{expirationField: float64(future.Unix())}, false, false},
+		{"Unix int expired", map[string]interface{}{expirationField: past.Unix()}, true, false},
+		{"Unix string not expired", map[string]interface{}{expirationField: fmt.Sprintf("%d", future.Unix())}, false, false},
+		{"invalid expiration", map[string]interface{}{expirationField: past.Format(time.RFC1123)}, false, true},
+		{"invalid expiration type", map[string]interface{}{expirationField: true}, false, true},
+		{"ttl expired", map[string]interface{}{TTLField: 60.0, TimeField: past.Format(time.RFC3339)}, true, false},
+		{"ttl not expired", map[string]interface{}{TTLField: "60", TimeField: time.Now().UTC().Format(time.RFC3339)}, false, false},
+		{"ttl without time", map[string]interface{}{TTLField: 60.0}, false, true},
+		{"invalid ttl", map[string]interface{}{TTLField: "a minute", TimeField: past.Format(time.RFC3339)}, false, true},
+		{"expiration takes precedence over ttl", map[string]interface{}{expirationField: future.Format(time.RFC3339), TTLField: 1.0, TimeField: past.Format(time.RFC3339)}, false, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			expired, err := CheckExpiration(tt.cloudEvent)
+			if tt.invalid {
+				assert.True(t, errors.Is(err, ErrInvalidExpiration))
+			} else {
+				assert.NoError(t, err)
+			}
+			assert.Equal(t, tt.expired, expired)
+			assert.Equal(t, tt.expired, HasExpired(tt.cloudEvent))
+		})
+	}
+
+	t.Run("numeric expiration from JSON", func(t *testing.T) {
+		n, err := FromCloudEvent([]byte(fmt.Sprintf(`{"specversion":"1.0","expiration":%d}`, past.Unix())), "")
+		assert.NoError(t, err)
+		assert.True(t, HasExpired(n))
+	})
+}
+
+func TestApplyMetadataOverrides(t *testing.T) {
+	t.Run("override present", func(t *testing.T) {
+		envelope := NewCloudEventsEnvelope("a", "", "", "subject", "routed.topic", "mypubsub", "", []byte("data"), "")
+		ApplyMetadata(envelope, nil, map[string]string{
+			TypeMetadataKey:    "com.myorg.order.created",
+			SourceMetadataKey:  "orders",
+			SubjectMetadataKey: "order/1",
+		})
+		assert.Equal(t, "com.myorg.order.created", envelope[TypeField])
+		assert.Equal(t, "orders", envelope[SourceField])
+		assert.Equal(t, "order/1", envelope[SubjectField])
+	})
+
+	t.Run("leave default", func(t *testing.T) {
+		envelope := NewCloudEventsEnvelope("a", "", "", "subject", "routed.topic", "mypubsub", "", []byte("data"), "")
+		ApplyMetadata(envelope, nil, map[string]string{})
+		assert.Equal(t, DefaultCloudEventType, envelope[TypeField])
+		assert.Equal(t, DefaultCloudEventSource, envelope[SourceField])
+		assert.Equal(t, "subject", envelope[SubjectField])
+	})
+
+	t.Run("empty values are ignored", func(t *testing.T) {
+		envelope := NewCloudEventsEnvelope("a", "", "e1", "subject", "routed.topic", "mypubsub", "", []byte("data"), "")
+		ApplyMetadata(envelope, nil, map[string]string{
+			TypeMetadataKey:    "",
+			SubjectMetadataKey: "",
+		})
+		assert.Equal(t, "e1", envelope[TypeField])
+		assert.Equal(t, "subject", envelope[SubjectField])
+	})
+}
+
+func TestDetectDataContentType(t *testing.T) {
+	t.Run("caller content type is kept", func(t *testing.T) {
+		envelope := NewCloudEventsEnvelope("a", "", "", "", "routed.topic", "mypubsub", "text/csv", []byte("1"), "")
+		assert.Equal(t, "text/csv", envelope[dataContentTypeField])
+	})
+
+	t.Run("custom detector", func(t *testing.T) {
+		defer func(detector DataContentTypeDetector) {
+			DetectDataContentType = detector
+		}(DetectDataContentType)
+
+		DetectDataContentType = func(data []byte) string {
+			return "application/xml"
+		}
+
+		envelope := NewCloudEventsEnvelope("a", "", "", "", "routed.topic", "mypubsub", "", []byte("<root/>"), "")
+		assert.Equal(t, "application/xml", envelope[dataContentTypeField])
+
+		envelope = NewCloudEventsEnvelope("a", "", "", "", "routed.topic", "mypubsub", "text/plain", []byte("<root/>"), "")
+		assert.Equal(t, "text/plain", envelope[dataContentTypeField])
+	})
+}
+
+func TestGetTraceID(t *testing.T) {
+	traceID := "4bf92f3577b34da6a3ce929d0e0e4736"
+
+	t.Run("bare trace id", func(t *testing.T) {
+		id, ok := GetTraceID(map[string]interface{}{TraceIDField: traceID})
+		assert.True(t, ok)
+		assert.Equal(t, traceID, id)
+
+		id, ok = GetTraceID(map[string]interface{}{TraceIDField: strings.ToUpper(traceID)})
+		assert.True(t, ok)
+		assert.Equal(t, traceID, id)
+	})
+
+	t.Run("traceparent", func(t *testing.T) {
+		envelope := NewCloudEventsEnvelope("a", "", "", "", "routed.topic", "mypubsub", "", []byte("data"), "00-"+traceID+"-00f067aa0ba902b7-01")
+		id, ok := GetTraceID(envelope)
+		assert.True(t, ok)
+		assert.Equal(t, traceID, id)
+	})
+
+	t.Run("missing or invalid", func(t *testing.T) {
+		for _, value := range []interface{}{
+			nil,
+			"",
+			5,
+			"4bf92f3577b34da6",
+			traceID + "00",
+			"zbf92f3577b34da6a3ce929d0e0e4736",
+			"00000000000000000000000000000000",
+			"00-" + traceID,
+			"00-" + traceID + "-00f067aa-01",
+			"00-4bf92f35-00f067aa0ba902b7-01",
+		} {
+			id, ok := GetTraceID(map[string]interface{}{TraceIDField: value})
+			assert.False(t, ok, value)
+			assert.Empty(t, id)
+		}
+
+		_, ok := GetTraceID(map[string]interface{}{})
+		assert.False(t, ok)
+	})
 }
@@ -8,6 +8,10 @@ package pubsub
 const (
 	// FeatureMessageTTL is the feature to handle message TTL.
 	FeatureMessageTTL Feature = "MESSAGE_TTL"
+	// FeatureMessagePriority is the feature to deliver messages according to their priority metadata.
+	FeatureMessagePriority Feature = "MESSAGE_PRIORITY"
+	// FeatureScheduledDelivery is the feature to deliver messages at the time set by their deliverAt or delaySeconds metadata.
+	FeatureScheduledDelivery Feature = "SCHEDULED_DELIVERY"
 )
 
 // Feature names a feature that can be implemented by PubSub components.
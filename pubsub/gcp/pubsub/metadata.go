@@ -14,4 +14,7 @@ type metadata struct {
 	TokenURI                string `json:"token_uri"`
 	AuthProviderCertURL     string `json:"auth_provider_x509_cert_url"`
 	ClientCertURL           string `json:"client_x509_cert_url"`
+	// EnableMessageOrdering creates the subscriptions with message ordering, the messages of an ordering key
+	// are delivered in the order they were published
+	EnableMessageOrdering bool `json:"-"`
 }
@@ -4,6 +4,7 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"strconv"
 
 	gcppubsub "cloud.google.com/go/pubsub"
 	"github.com/dapr/components-contrib/pubsub"
@@ -12,8 +13,10 @@ import (
 )
 
 const (
-	errorMessagePrefix = "gcp pubsub error:"
-	consumerID         = "consumerID"
+	errorMessagePrefix        = "gcp pubsub error:"
+	consumerID                = "consumerID"
+	enableMessageOrdering     = "enableMessageOrdering"
+	enableExactlyOnceDelivery = "enableExactlyOnceDelivery"
 )
 
 // GCPPubSub type
@@ -40,6 +43,10 @@ func (g *GCPPubSub) Init(meta pubsub.Metadata) error {
 	if err != nil {
 		return err
 	}
+	if err = parseDeliveryMetadata(meta, &pubsubMeta); err != nil {
+		return err
+	}
+
 	clientOptions := option.WithCredentialsJSON(b)
 	ctx := context.Background()
 	pubsubClient, err := gcppubsub.NewClient(ctx, pubsubMeta.ProjectID, clientOptions)
@@ -65,6 +72,30 @@ func (g *GCPPubSub) parseMetadata(metadata pubsub.Metadata) ([]byte, error) {
 	return b, err
 }
 
+// parseDeliveryMetadata parses the message ordering and delivery options, which are not JSON typed
+func parseDeliveryMetadata(meta pubsub.Metadata, pubsubMeta *metadata) error {
+	var err error
+	if val, ok := meta.Properties[enableMessageOrdering]; ok && val != "" {
+		pubsubMeta.EnableMessageOrdering, err = strconv.ParseBool(val)
+		if err != nil {
+			return fmt.Errorf("%s invalid %s %s, %s", errorMessagePrefix, enableMessageOrdering, val, err)
+		}
+	}
+
+	// exactly once delivery requires a more recent client library than cloud.google.com/go/pubsub v1.4.0
+	if val, ok := meta.Properties[enableExactlyOnceDelivery]; ok && val != "" {
+		exactlyOnce, err := strconv.ParseBool(val)
+		if err != nil {
+			return fmt.Errorf("%s invalid %s %s, %s", errorMessagePrefix, enableExactlyOnceDelivery, val, err)
+		}
+		if exactlyOnce {
+			return fmt.Errorf("%s %s is not supported by the client library", errorMessagePrefix, enableExactlyOnceDelivery)
+		}
+	}
+
+	return nil
+}
+
 // Publish the topic to GCP Pubsub
 func (g *GCPPubSub) Publish(req *pubsub.PublishRequest) error {
 	if !g.metadata.DisableEntityManagement {
@@ -77,9 +108,19 @@ func (g *GCPPubSub) Publish(req *pubsub.PublishRequest) error {
 	ctx := context.Background()
 	topic := g.getTopic(req.Topic)
 
+	// the messages of an ordering key are published and delivered in order
+	orderingKey := req.Metadata[pubsub.PartitionKeyMetadataKey]
+	topic.EnableMessageOrdering = orderingKey != ""
+
 	_, err := topic.Publish(ctx, &gcppubsub.Message{
-		Data: req.Data,
+		Data:        req.Data,
+		OrderingKey: orderingKey,
 	}).Get((ctx))
+	if err != nil && orderingKey != "" {
+		// the publishing of an ordering key is paused once it fails, so that the next messages are not published
+		// out of order, it is resumed as the message is published again
+		topic.ResumePublish(orderingKey)
+	}
 
 	return err
 }
@@ -145,9 +186,23 @@ func (g *GCPPubSub) ensureSubscription(subscription string, topic string) error
 
 	entity := g.getSubscription(subscription)
 	exists, subErr := entity.Exists(context.Background())
+	if subErr != nil {
+		return subErr
+	}
 	if !exists {
 		_, subErr = g.client.CreateSubscription(context.Background(), g.metadata.ConsumerID,
-			gcppubsub.SubscriptionConfig{Topic: g.getTopic(topic)})
+			gcppubsub.SubscriptionConfig{
+				Topic:                 g.getTopic(topic),
+				EnableMessageOrdering: g.metadata.EnableMessageOrdering,
+			})
+
+		return subErr
+	}
+
+	// message ordering can only be set when subscriptions are created
+	config, subErr := entity.Config(context.Background())
+	if subErr == nil && config.EnableMessageOrdering != g.metadata.EnableMessageOrdering {
+		g.logger.Warnf("%s subscription %s has %s %t, it must be deleted to change it", errorMessagePrefix, subscription, enableMessageOrdering, config.EnableMessageOrdering)
 	}
 
 	return subErr
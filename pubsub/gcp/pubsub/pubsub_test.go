@@ -34,3 +34,42 @@ func TestInit(t *testing.T) {
 	assert.Equal(t, "https://token", pubsubMeta.TokenURI)
 	assert.Equal(t, "serviceaccount", pubsubMeta.Type)
 }
+
+func TestParseDeliveryMetadata(t *testing.T) {
+	t.Run("message ordering is disabled by default", func(t *testing.T) {
+		var m metadata
+		err := parseDeliveryMetadata(pubsub.Metadata{Properties: map[string]string{}}, &m)
+
+		assert.Nil(t, err)
+		assert.False(t, m.EnableMessageOrdering)
+	})
+
+	t.Run("message ordering is enabled", func(t *testing.T) {
+		var m metadata
+		err := parseDeliveryMetadata(pubsub.Metadata{Properties: map[string]string{enableMessageOrdering: "true"}}, &m)
+
+		assert.Nil(t, err)
+		assert.True(t, m.EnableMessageOrdering)
+	})
+
+	t.Run("message ordering is invalid", func(t *testing.T) {
+		var m metadata
+		err := parseDeliveryMetadata(pubsub.Metadata{Properties: map[string]string{enableMessageOrdering: "maybe"}}, &m)
+
+		assert.Error(t, err)
+	})
+
+	t.Run("exactly once delivery is not supported", func(t *testing.T) {
+		var m metadata
+		err := parseDeliveryMetadata(pubsub.Metadata{Properties: map[string]string{enableExactlyOnceDelivery: "true"}}, &m)
+
+		assert.Error(t, err)
+	})
+
+	t.Run("exactly once delivery is disabled", func(t *testing.T) {
+		var m metadata
+		err := parseDeliveryMetadata(pubsub.Metadata{Properties: map[string]string{enableExactlyOnceDelivery: "false"}}, &m)
+
+		assert.Nil(t, err)
+	})
+}
@@ -0,0 +1,165 @@
+// ------------------------------------------------------------
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT License.
+// ------------------------------------------------------------
+
+package inmemory
+
+import (
+	"sync"
+	"time"
+)
+
+// defaultBus is shared by the components of the process, so that they exchange messages like the clients of a broker
+var defaultBus = newBus()
+
+// bus routes the messages of a topic to each of its consumer groups, where a message is delivered to one subscriber
+// of the group, in turn. There is no persistence: the messages of a topic without subscribers are dropped.
+type bus struct {
+	lock sync.Mutex
+	// topics holds the consumer groups of each topic by their name
+	topics map[string]map[string]*group
+}
+
+type group struct {
+	subscribers []*subscriber
+	next        int
+}
+
+type message struct {
+	topic string
+	data  []byte
+	// due is the time the message is delivered at
+	due time.Time
+}
+
+func newBus() *bus {
+	return &bus{topics: make(map[string]map[string]*group)}
+}
+
+// subscribe adds a subscriber to a consumer group of a topic
+func (b *bus) subscribe(topic, consumerGroup string, s *subscriber) {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	groups, ok := b.topics[topic]
+	if !ok {
+		groups = make(map[string]*group)
+		b.topics[topic] = groups
+	}
+	g, ok := groups[consumerGroup]
+	if !ok {
+		g = &group{}
+		groups[consumerGroup] = g
+	}
+	g.subscribers = append(g.subscribers, s)
+}
+
+// unsubscribe removes a subscriber, the groups and topics left without subscribers are removed
+func (b *bus) unsubscribe(s *subscriber) {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	for topic, groups := range b.topics {
+		for name, g := range groups {
+			for i, sub := range g.subscribers {
+				if sub == s {
+					g.subscribers = append(g.subscribers[:i], g.subscribers[i+1:]...)
+
+					break
+				}
+			}
+			if len(g.subscribers) == 0 {
+				delete(groups, name)
+			}
+		}
+		if len(groups) == 0 {
+			delete(b.topics, topic)
+		}
+	}
+}
+
+// publish hands a message to one subscriber of each consumer group of its topic
+func (b *bus) publish(msg *message) {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	for _, g := range b.topics[msg.topic] {
+		g.subscribers[g.next%len(g.subscribers)].enqueue(msg)
+		g.next++
+	}
+}
+
+// subscriber delivers its messages one at a time, in the order they were published
+type subscriber struct {
+	lock  sync.Mutex
+	queue []*message
+	// ready is signaled when a message is enqueued
+	ready chan struct{}
+	done  chan struct{}
+}
+
+func newSubscriber() *subscriber {
+	return &subscriber{
+		ready: make(chan struct{}, 1),
+		done:  make(chan struct{}),
+	}
+}
+
+// enqueue adds a message to the queue of the subscriber, it never blocks the publisher
+func (s *subscriber) enqueue(msg *message) {
+	s.lock.Lock()
+	s.queue = append(s.queue, msg)
+	s.lock.Unlock()
+
+	select {
+	case s.ready <- struct{}{}:
+	default:
+	}
+}
+
+func (s *subscriber) dequeue() (*message, bool) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if len(s.queue) == 0 {
+		return nil, false
+	}
+	msg := s.queue[0]
+	s.queue[0] = nil
+	s.queue = s.queue[1:]
+
+	return msg, true
+}
+
+// run delivers the messages once they are due, until the subscriber is closed
+func (s *subscriber) run(deliver func(msg *message)) {
+	for {
+		msg, ok := s.dequeue()
+		if !ok {
+			select {
+			case <-s.ready:
+				continue
+			case <-s.done:
+				return
+			}
+		}
+
+		if wait := time.Until(msg.due); wait > 0 {
+			timer := time.NewTimer(wait)
+			select {
+			case <-timer.C:
+			case <-s.done:
+				timer.Stop()
+
+				return
+			}
+		}
+
+		deliver(msg)
+	}
+}
+
+func (s *subscriber) close() {
+	close(s.done)
+}
@@ -0,0 +1,207 @@
+// ------------------------------------------------------------
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT License.
+// ------------------------------------------------------------
+
+/*
+Package inmemory implements an in-memory pubsub component, for tests and local development without a broker
+*/
+package inmemory
+
+import (
+	"errors"
+	"fmt"
+	"math/rand"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/dapr/components-contrib/pubsub"
+	"github.com/dapr/dapr/pkg/logger"
+	"github.com/google/uuid"
+)
+
+const (
+	consumerID          = "consumerID"
+	deliveryDelay       = "deliveryDelay"
+	publishFailureRate  = "publishFailureRate"
+	deliveryFailureRate = "deliveryFailureRate"
+
+	errorMessagePrefix = "inmemory error:"
+)
+
+var (
+	errPublishFailure  = errors.New("inmemory error: injected publish failure")
+	errDeliveryFailure = errors.New("inmemory error: injected delivery failure")
+)
+
+type inMemoryPubSub struct {
+	bus      *bus
+	metadata metadata
+
+	lock        sync.Mutex
+	subscribers []*subscriber
+	// random draws the injected failures, it is guarded by lock
+	random *rand.Rand
+
+	logger logger.Logger
+}
+
+// NewInMemoryPubSub returns a new in-memory pubsub, the components of the process share their topics
+func NewInMemoryPubSub(logger logger.Logger) pubsub.PubSub {
+	return &inMemoryPubSub{
+		bus:    defaultBus,
+		random: rand.New(rand.NewSource(time.Now().UnixNano())), //nolint:gosec
+		logger: logger,
+	}
+}
+
+func parseInMemoryMetadata(meta pubsub.Metadata) (metadata, error) {
+	m := metadata{}
+
+	// the components without consumerID have a group of their own, so that they receive every message
+	if val, ok := meta.Properties[consumerID]; ok && val != "" {
+		m.consumerGroup = val
+	} else {
+		m.consumerGroup = uuid.New().String()
+	}
+
+	if val, ok := meta.Properties[deliveryDelay]; ok && val != "" {
+		delay, err := time.ParseDuration(val)
+		if err != nil || delay < 0 {
+			return m, fmt.Errorf("%s invalid %s %s, it must be a positive duration", errorMessagePrefix, deliveryDelay, val)
+		}
+		m.deliveryDelay = delay
+	}
+
+	var err error
+	if m.publishFailureRate, err = parseRate(meta.Properties, publishFailureRate); err != nil {
+		return m, err
+	}
+	if m.deliveryFailureRate, err = parseRate(meta.Properties, deliveryFailureRate); err != nil {
+		return m, err
+	}
+
+	m.deadLetter, err = pubsub.ParseDeadLetterConfig(meta.Properties)
+	if err != nil {
+		return m, fmt.Errorf("%s %s", errorMessagePrefix, err)
+	}
+
+	return m, nil
+}
+
+// parseRate parses a ratio between 0 and 1
+func parseRate(properties map[string]string, key string) (float64, error) {
+	val, ok := properties[key]
+	if !ok || val == "" {
+		return 0, nil
+	}
+
+	rate, err := strconv.ParseFloat(val, 64)
+	if err != nil || rate < 0 || rate > 1 {
+		return 0, fmt.Errorf("%s invalid %s %s, it must be between 0 and 1", errorMessagePrefix, key, val)
+	}
+
+	return rate, nil
+}
+
+func (p *inMemoryPubSub) Init(metadata pubsub.Metadata) error {
+	m, err := parseInMemoryMetadata(metadata)
+	if err != nil {
+		return err
+	}
+
+	p.metadata = m
+
+	return nil
+}
+
+func (p *inMemoryPubSub) Publish(req *pubsub.PublishRequest) error {
+	if p.fail(p.metadata.publishFailureRate) {
+		return errPublishFailure
+	}
+
+	// the data is copied, so that the publisher can reuse its buffer
+	data := make([]byte, len(req.Data))
+	copy(data, req.Data)
+
+	p.bus.publish(&message{
+		topic: req.Topic,
+		data:  data,
+		due:   time.Now().Add(p.metadata.deliveryDelay),
+	})
+
+	return nil
+}
+
+func (p *inMemoryPubSub) Subscribe(req pubsub.SubscribeRequest, handler func(msg *pubsub.NewMessage) error) error {
+	s := newSubscriber()
+
+	p.lock.Lock()
+	p.subscribers = append(p.subscribers, s)
+	p.lock.Unlock()
+
+	p.bus.subscribe(req.Topic, p.metadata.consumerGroup, s)
+	go s.run(func(msg *message) {
+		p.deliver(msg, handler)
+	})
+	p.logger.Debugf("inmemory: subscribed to topic %s with consumer group %s", req.Topic, p.metadata.consumerGroup)
+
+	return nil
+}
+
+// deliver delivers a message up to maxDeliveryAttempts times, the messages which cannot be delivered
+// are published to the dead letter topic if any, or else dropped
+func (p *inMemoryPubSub) deliver(msg *message, handler func(msg *pubsub.NewMessage) error) {
+	m := &pubsub.NewMessage{Topic: msg.topic, Data: msg.data}
+	err := p.metadata.deadLetter.Deliver(m, func(m *pubsub.NewMessage) error {
+		if p.fail(p.metadata.deliveryFailureRate) {
+			return errDeliveryFailure
+		}
+
+		return handler(m)
+	})
+	if err == nil {
+		return
+	}
+
+	if p.metadata.deadLetter.Enabled() {
+		if dlErr := p.metadata.deadLetter.DeadLetter(p, m); dlErr != nil {
+			p.logger.Errorf("%s %s", errorMessagePrefix, dlErr)
+		}
+
+		return
+	}
+
+	p.logger.Errorf("%s dropping message of topic %s, which could not be delivered: %s", errorMessagePrefix, msg.topic, err)
+}
+
+// fail tells whether an operation is to be failed on purpose, given the failure rate
+func (p *inMemoryPubSub) fail(rate float64) bool {
+	if rate <= 0 {
+		return false
+	}
+
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	return p.random.Float64() < rate
+}
+
+func (p *inMemoryPubSub) Close() error {
+	p.lock.Lock()
+	subscribers := p.subscribers
+	p.subscribers = nil
+	p.lock.Unlock()
+
+	for _, s := range subscribers {
+		p.bus.unsubscribe(s)
+		s.close()
+	}
+
+	return nil
+}
+
+func (p *inMemoryPubSub) Features() []pubsub.Feature {
+	return nil
+}
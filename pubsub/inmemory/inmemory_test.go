@@ -0,0 +1,223 @@
+// ------------------------------------------------------------
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT License.
+// ------------------------------------------------------------
+
+package inmemory
+
+import (
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/dapr/components-contrib/pubsub"
+	"github.com/dapr/dapr/pkg/logger"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestPubSub(t *testing.T, b *bus, properties map[string]string) pubsub.PubSub {
+	p := NewInMemoryPubSub(logger.NewLogger("test")).(*inMemoryPubSub)
+	p.bus = b
+	require.NoError(t, p.Init(pubsub.Metadata{Properties: properties}))
+
+	return p
+}
+
+// receiver collects the messages delivered to a handler
+type receiver struct {
+	lock     sync.Mutex
+	messages []string
+	received chan struct{}
+}
+
+func newReceiver() *receiver {
+	return &receiver{received: make(chan struct{}, 100)}
+}
+
+func (r *receiver) handle(msg *pubsub.NewMessage) error {
+	r.lock.Lock()
+	r.messages = append(r.messages, string(msg.Data))
+	r.lock.Unlock()
+	r.received <- struct{}{}
+
+	return nil
+}
+
+func (r *receiver) wait(t *testing.T, count int) []string {
+	for i := 0; i < count; i++ {
+		select {
+		case <-r.received:
+		case <-time.After(5 * time.Second):
+			t.Fatalf("received %d messages, expected %d", i, count)
+		}
+	}
+
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	return append([]string(nil), r.messages...)
+}
+
+func TestParseInMemoryMetadata(t *testing.T) {
+	t.Run("defaults", func(t *testing.T) {
+		m, err := parseInMemoryMetadata(pubsub.Metadata{Properties: map[string]string{}})
+
+		assert.NoError(t, err)
+		assert.NotEmpty(t, m.consumerGroup)
+		assert.Equal(t, time.Duration(0), m.deliveryDelay)
+		assert.Equal(t, float64(0), m.publishFailureRate)
+		assert.Equal(t, float64(0), m.deliveryFailureRate)
+		assert.False(t, m.deadLetter.Enabled())
+	})
+
+	t.Run("metadata is correct", func(t *testing.T) {
+		m, err := parseInMemoryMetadata(pubsub.Metadata{Properties: map[string]string{
+			consumerID:                        "app",
+			deliveryDelay:                     "100ms",
+			publishFailureRate:                "0.1",
+			deliveryFailureRate:               "0.5",
+			pubsub.DeadLetterTopicMetadataKey: "poison",
+		}})
+
+		assert.NoError(t, err)
+		assert.Equal(t, "app", m.consumerGroup)
+		assert.Equal(t, 100*time.Millisecond, m.deliveryDelay)
+		assert.Equal(t, 0.1, m.publishFailureRate)
+		assert.Equal(t, 0.5, m.deliveryFailureRate)
+		assert.Equal(t, "poison", m.deadLetter.Topic)
+	})
+
+	invalid := map[string]string{
+		deliveryDelay:                         "soon",
+		publishFailureRate:                    "2",
+		deliveryFailureRate:                   "-0.5",
+		pubsub.MaxDeliveryAttemptsMetadataKey: "0",
+	}
+	for key, val := range invalid {
+		key, val := key, val
+		t.Run("invalid "+key, func(t *testing.T) {
+			_, err := parseInMemoryMetadata(pubsub.Metadata{Properties: map[string]string{key: val}})
+
+			assert.Error(t, err)
+		})
+	}
+}
+
+func TestPublishSubscribe(t *testing.T) {
+	t.Run("consumer groups", func(t *testing.T) {
+		b := newBus()
+		publisher := newTestPubSub(t, b, map[string]string{consumerID: "publisher"})
+		first := newTestPubSub(t, b, map[string]string{consumerID: "a"})
+		second := newTestPubSub(t, b, map[string]string{consumerID: "a"})
+		other := newTestPubSub(t, b, map[string]string{consumerID: "b"})
+		defer first.Close()
+		defer second.Close()
+		defer other.Close()
+
+		group := newReceiver()
+		otherGroup := newReceiver()
+		require.NoError(t, first.Subscribe(pubsub.SubscribeRequest{Topic: "orders"}, group.handle))
+		require.NoError(t, second.Subscribe(pubsub.SubscribeRequest{Topic: "orders"}, group.handle))
+		require.NoError(t, other.Subscribe(pubsub.SubscribeRequest{Topic: "orders"}, otherGroup.handle))
+
+		for _, data := range []string{"1", "2", "3", "4"} {
+			require.NoError(t, publisher.Publish(&pubsub.PublishRequest{Topic: "orders", Data: []byte(data)}))
+		}
+
+		// each group receives every message once, shared among its subscribers
+		assert.ElementsMatch(t, []string{"1", "2", "3", "4"}, group.wait(t, 4))
+		assert.Equal(t, []string{"1", "2", "3", "4"}, otherGroup.wait(t, 4))
+	})
+
+	t.Run("topics without subscriber", func(t *testing.T) {
+		b := newBus()
+		p := newTestPubSub(t, b, map[string]string{})
+
+		assert.NoError(t, p.Publish(&pubsub.PublishRequest{Topic: "orders", Data: []byte("1")}))
+	})
+
+	t.Run("delivery delay", func(t *testing.T) {
+		b := newBus()
+		p := newTestPubSub(t, b, map[string]string{deliveryDelay: "200ms"})
+		defer p.Close()
+
+		r := newReceiver()
+		require.NoError(t, p.Subscribe(pubsub.SubscribeRequest{Topic: "orders"}, r.handle))
+
+		start := time.Now()
+		require.NoError(t, p.Publish(&pubsub.PublishRequest{Topic: "orders", Data: []byte("1")}))
+		r.wait(t, 1)
+		assert.True(t, time.Since(start) >= 200*time.Millisecond)
+	})
+
+	t.Run("closed subscribers receive no message", func(t *testing.T) {
+		b := newBus()
+		p := newTestPubSub(t, b, map[string]string{})
+
+		require.NoError(t, p.Subscribe(pubsub.SubscribeRequest{Topic: "orders"}, func(msg *pubsub.NewMessage) error {
+			t.Error("unexpected message")
+
+			return nil
+		}))
+		require.NoError(t, p.Close())
+
+		assert.Empty(t, b.topics)
+		assert.NoError(t, p.Publish(&pubsub.PublishRequest{Topic: "orders", Data: []byte("1")}))
+	})
+}
+
+func TestFailureInjection(t *testing.T) {
+	t.Run("publish failures", func(t *testing.T) {
+		p := newTestPubSub(t, newBus(), map[string]string{publishFailureRate: "1"})
+
+		assert.Equal(t, errPublishFailure, p.Publish(&pubsub.PublishRequest{Topic: "orders", Data: []byte("1")}))
+	})
+
+	t.Run("delivery failures are dead lettered", func(t *testing.T) {
+		b := newBus()
+		p := newTestPubSub(t, b, map[string]string{
+			deliveryFailureRate:                   "1",
+			pubsub.DeadLetterTopicMetadataKey:     "poison",
+			pubsub.MaxDeliveryAttemptsMetadataKey: "2",
+		})
+		defer p.Close()
+
+		deliveries := 0
+		require.NoError(t, p.Subscribe(pubsub.SubscribeRequest{Topic: "orders"}, func(msg *pubsub.NewMessage) error {
+			deliveries++
+
+			return nil
+		}))
+		// the dead letter subscriber does not fail its deliveries
+		deadLetter := newTestPubSub(t, b, map[string]string{})
+		defer deadLetter.Close()
+		poison := newReceiver()
+		require.NoError(t, deadLetter.Subscribe(pubsub.SubscribeRequest{Topic: "poison"}, poison.handle))
+
+		require.NoError(t, p.Publish(&pubsub.PublishRequest{Topic: "orders", Data: []byte("1")}))
+		assert.Equal(t, []string{"1"}, poison.wait(t, 1))
+		assert.Equal(t, 0, deliveries)
+	})
+
+	t.Run("handler failures are redelivered", func(t *testing.T) {
+		b := newBus()
+		p := newTestPubSub(t, b, map[string]string{pubsub.MaxDeliveryAttemptsMetadataKey: "3"})
+		defer p.Close()
+
+		r := newReceiver()
+		attempts := 0
+		require.NoError(t, p.Subscribe(pubsub.SubscribeRequest{Topic: "orders"}, func(msg *pubsub.NewMessage) error {
+			attempts++
+			if attempts < 3 {
+				return errors.New("not yet")
+			}
+
+			return r.handle(msg)
+		}))
+
+		require.NoError(t, p.Publish(&pubsub.PublishRequest{Topic: "orders", Data: []byte("1")}))
+		assert.Equal(t, []string{"1"}, r.wait(t, 1))
+	})
+}
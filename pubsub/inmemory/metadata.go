@@ -0,0 +1,24 @@
+// ------------------------------------------------------------
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT License.
+// ------------------------------------------------------------
+
+package inmemory
+
+import (
+	"time"
+
+	"github.com/dapr/components-contrib/pubsub"
+)
+
+type metadata struct {
+	// consumerGroup shares the messages of a topic among the subscribers of the group
+	consumerGroup string
+	// deliveryDelay is the time between the publishing of a message and its delivery
+	deliveryDelay time.Duration
+	// publishFailureRate is the ratio of publish calls failed on purpose
+	publishFailureRate float64
+	// deliveryFailureRate is the ratio of deliveries failed on purpose, as if the handler had failed
+	deliveryFailureRate float64
+	deadLetter          pubsub.DeadLetterConfig
+}
@@ -0,0 +1,540 @@
+// ------------------------------------------------------------
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT License.
+// ------------------------------------------------------------
+
+/*
+Package jetstream implements NATS JetStream pubsub component
+*/
+package jetstream
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/dapr/components-contrib/pubsub"
+	"github.com/dapr/dapr/pkg/logger"
+	nats "github.com/nats-io/nats.go"
+)
+
+// compulsory options
+const (
+	natsURL = "natsURL"
+)
+
+// stream options (optional)
+const (
+	streamName    = "streamName"
+	autoProvision = "autoProvision"
+	storageType   = "storageType"
+	replicas      = "replicas"
+	maxAge        = "maxAge"
+)
+
+// consumer options (optional)
+const (
+	durableName   = "durableName"
+	consumerMode  = "consumerMode"
+	deliverPolicy = "deliverPolicy"
+	ackWait       = "ackWait"
+	maxDeliver    = "maxDeliver"
+	maxAckPending = "maxAckPending"
+	pullBatchSize = "pullBatchSize"
+	pullTimeout   = "pullTimeout"
+)
+
+// valid values for the options
+const (
+	storageTypeFile   = "file"
+	storageTypeMemory = "memory"
+
+	// consumerModePull fetches batches of messages, so that the instances of the consumer share the messages
+	consumerModePull = "pull"
+	// consumerModePush has the messages pushed by the server to the queue group of the instances of the consumer
+	consumerModePush = "push"
+
+	deliverPolicyAll  = "all"
+	deliverPolicyNew  = "new"
+	deliverPolicyLast = "last"
+)
+
+// the stream and consumer policies of the options
+var (
+	storageTypes = map[string]nats.StorageType{
+		storageTypeFile:   nats.FileStorage,
+		storageTypeMemory: nats.MemoryStorage,
+	}
+	deliverPolicies = map[string]nats.DeliverPolicy{
+		deliverPolicyAll:  nats.DeliverAllPolicy,
+		deliverPolicyNew:  nats.DeliverNewPolicy,
+		deliverPolicyLast: nats.DeliverLastPolicy,
+	}
+)
+
+const (
+	consumerID = "consumerID" // passed in by Dapr runtime
+
+	defaultAckWait       = time.Second * 30
+	defaultPullBatchSize = 10
+	defaultPullTimeout   = time.Second * 5
+)
+
+type jetStream struct {
+	metadata metadata
+	natsConn *nats.Conn
+	jsc      nats.JetStreamContext
+
+	// streams caches the stream of each topic
+	streams     map[string]string
+	streamsLock sync.Mutex
+
+	logger logger.Logger
+}
+
+// NewJetStream returns a new NATS JetStream pub-sub implementation
+func NewJetStream(logger logger.Logger) pubsub.PubSub {
+	return &jetStream{
+		streams: map[string]string{},
+		logger:  logger,
+	}
+}
+
+func parseJetStreamMetadata(meta pubsub.Metadata) (metadata, error) {
+	m := metadata{}
+	if val, ok := meta.Properties[natsURL]; ok && val != "" {
+		m.natsURL = val
+	} else {
+		return m, errors.New("jetstream error: missing nats URL")
+	}
+
+	if val, ok := meta.Properties[durableName]; ok && val != "" {
+		m.durableName = val
+	} else if val, ok := meta.Properties[consumerID]; ok && val != "" {
+		m.durableName = val
+	} else {
+		return m, errors.New("jetstream error: missing durable name")
+	}
+
+	m.streamName = meta.Properties[streamName]
+
+	m.autoProvision = true
+	if val, ok := meta.Properties[autoProvision]; ok && val != "" {
+		b, err := strconv.ParseBool(val)
+		if err != nil {
+			return m, fmt.Errorf("jetstream error: can't parse autoProvision field: %s", err)
+		}
+		m.autoProvision = b
+	}
+
+	m.storageType = storageTypeFile
+	if val, ok := meta.Properties[storageType]; ok && val != "" {
+		if val != storageTypeFile && val != storageTypeMemory {
+			return m, errors.New("jetstream error: valid value for storageType is file or memory")
+		}
+		m.storageType = val
+	}
+
+	m.replicas = 1
+	if val, ok := meta.Properties[replicas]; ok && val != "" {
+		r, err := strconv.Atoi(val)
+		if err != nil || r < 1 {
+			return m, errors.New("jetstream error: replicas should be equal to or more than 1")
+		}
+		m.replicas = r
+	}
+
+	if val, ok := meta.Properties[maxAge]; ok && val != "" {
+		d, err := time.ParseDuration(val)
+		if err != nil {
+			return m, fmt.Errorf("jetstream error: can't parse maxAge field: %s", err)
+		}
+		m.maxAge = d
+	}
+
+	m.consumerMode = consumerModePull
+	if val, ok := meta.Properties[consumerMode]; ok && val != "" {
+		if val != consumerModePull && val != consumerModePush {
+			return m, errors.New("jetstream error: valid value for consumerMode is pull or push")
+		}
+		m.consumerMode = val
+	}
+
+	m.deliverPolicy = deliverPolicyAll
+	if val, ok := meta.Properties[deliverPolicy]; ok && val != "" {
+		if val != deliverPolicyAll && val != deliverPolicyNew && val != deliverPolicyLast {
+			return m, errors.New("jetstream error: valid value for deliverPolicy is all, new or last")
+		}
+		m.deliverPolicy = val
+	}
+
+	m.ackWait = defaultAckWait
+	if val, ok := meta.Properties[ackWait]; ok && val != "" {
+		d, err := time.ParseDuration(val)
+		if err != nil {
+			return m, fmt.Errorf("jetstream error: can't parse ackWait field: %s", err)
+		}
+		m.ackWait = d
+	}
+
+	if val, ok := meta.Properties[maxDeliver]; ok && val != "" {
+		max, err := strconv.Atoi(val)
+		if err != nil || max < 1 {
+			return m, errors.New("jetstream error: maxDeliver should be equal to or more than 1")
+		}
+		m.maxDeliver = max
+	}
+
+	if val, ok := meta.Properties[maxAckPending]; ok && val != "" {
+		max, err := strconv.Atoi(val)
+		if err != nil || max < 1 {
+			return m, errors.New("jetstream error: maxAckPending should be equal to or more than 1")
+		}
+		m.maxAckPending = max
+	}
+
+	m.pullBatchSize = defaultPullBatchSize
+	if val, ok := meta.Properties[pullBatchSize]; ok && val != "" {
+		size, err := strconv.Atoi(val)
+		if err != nil || size < 1 {
+			return m, errors.New("jetstream error: pullBatchSize should be equal to or more than 1")
+		}
+		m.pullBatchSize = size
+	}
+
+	m.pullTimeout = defaultPullTimeout
+	if val, ok := meta.Properties[pullTimeout]; ok && val != "" {
+		d, err := time.ParseDuration(val)
+		if err != nil || d <= 0 {
+			return m, errors.New("jetstream error: pullTimeout should be a positive duration")
+		}
+		m.pullTimeout = d
+	}
+
+	deadLetter, err := pubsub.ParseDeadLetterConfig(meta.Properties)
+	if err != nil {
+		return m, fmt.Errorf("jetstream error: %s", err)
+	}
+	if deadLetter.Enabled() && m.maxDeliver > 0 && m.maxDeliver < deadLetter.MaxDeliveryAttempts {
+		return m, fmt.Errorf("jetstream error: %s %d exceeds %s %d, messages would not be delivered anymore first", pubsub.MaxDeliveryAttemptsMetadataKey, deadLetter.MaxDeliveryAttempts, maxDeliver, m.maxDeliver)
+	}
+	m.deadLetter = deadLetter
+
+	return m, nil
+}
+
+func (j *jetStream) Init(metadata pubsub.Metadata) error {
+	m, err := parseJetStreamMetadata(metadata)
+	if err != nil {
+		return err
+	}
+	j.metadata = m
+
+	natsConn, err := nats.Connect(m.natsURL, nats.MaxReconnects(-1))
+	if err != nil {
+		return fmt.Errorf("jetstream: error connecting to nats at %s: %s", m.natsURL, err)
+	}
+	j.logger.Debugf("connected to nats at %s", m.natsURL)
+
+	jsc, err := natsConn.JetStream()
+	if err != nil {
+		natsConn.Close()
+
+		return fmt.Errorf("jetstream: error getting the JetStream context: %s", err)
+	}
+
+	j.natsConn = natsConn
+	j.jsc = jsc
+
+	return nil
+}
+
+func (j *jetStream) Publish(req *pubsub.PublishRequest) error {
+	if j.metadata.autoProvision {
+		if _, err := j.ensureStream(req.Topic); err != nil {
+			return fmt.Errorf("jetstream: error provisioning stream of topic %s: %s", req.Topic, err)
+		}
+	}
+
+	// the message is stored once the stream acknowledges it
+	_, err := j.jsc.Publish(req.Topic, req.Data)
+	if errors.Is(err, nats.ErrNoStreamResponse) {
+		return fmt.Errorf("jetstream: error from publish: no stream stores topic %s", req.Topic)
+	}
+	if err != nil {
+		return fmt.Errorf("jetstream: error from publish: %s", err)
+	}
+
+	return nil
+}
+
+func (j *jetStream) Subscribe(req pubsub.SubscribeRequest, handler func(msg *pubsub.NewMessage) error) error {
+	stream, err := j.ensureStream(req.Topic)
+	if err != nil {
+		return fmt.Errorf("jetstream: error provisioning stream of topic %s: %s", req.Topic, err)
+	}
+
+	// consumers are named after their topic, as a stream may store several topics
+	durable := sanitizeName(j.metadata.durableName + "-" + req.Topic)
+	config, err := j.ensureConsumer(stream, durable, req.Topic)
+	if err != nil {
+		return fmt.Errorf("jetstream: error provisioning consumer %s of stream %s: %s", durable, stream, err)
+	}
+
+	// the mode of existing consumers cannot be changed
+	if config.DeliverSubject != "" {
+		if j.metadata.consumerMode != consumerModePush {
+			j.logger.Warnf("jetstream: consumer %s of stream %s is a push consumer", durable, stream)
+		}
+		cb := func(natsMsg *nats.Msg) {
+			j.handleMessage(req.Topic, natsMsg, handler)
+		}
+		// the instances share the messages of the consumers with a deliver group only
+		if config.DeliverGroup != "" {
+			_, err = j.jsc.QueueSubscribe(req.Topic, config.DeliverGroup, cb, nats.Bind(stream, durable), nats.ManualAck())
+		} else {
+			_, err = j.jsc.Subscribe(req.Topic, cb, nats.Bind(stream, durable), nats.ManualAck())
+		}
+		if err != nil {
+			return fmt.Errorf("jetstream: subscribe error %s", err)
+		}
+		j.logger.Debugf("jetstream: subscribed to subject %s with push consumer %s of stream %s", req.Topic, durable, stream)
+
+		return nil
+	}
+
+	if j.metadata.consumerMode != consumerModePull {
+		j.logger.Warnf("jetstream: consumer %s of stream %s is a pull consumer", durable, stream)
+	}
+	sub, err := j.jsc.PullSubscribe(req.Topic, durable, nats.Bind(stream, durable))
+	if err != nil {
+		return fmt.Errorf("jetstream: subscribe error %s", err)
+	}
+	go j.pull(durable, req.Topic, sub, handler)
+	j.logger.Debugf("jetstream: subscribed to subject %s with pull consumer %s of stream %s", req.Topic, durable, stream)
+
+	return nil
+}
+
+// ensureStream returns the stream of the topic: that of the component, or else the stream storing the subject.
+// With auto provisioning, the stream is created when it does not exist, and the topic is added to its subjects.
+func (j *jetStream) ensureStream(topic string) (string, error) {
+	j.streamsLock.Lock()
+	defer j.streamsLock.Unlock()
+
+	if name, ok := j.streams[topic]; ok {
+		return name, nil
+	}
+
+	name := j.metadata.streamName
+	if name == "" {
+		if stored := j.streamOfSubject(topic); stored != "" {
+			j.streams[topic] = stored
+
+			return stored, nil
+		}
+		name = sanitizeName(topic)
+	}
+
+	info, err := j.jsc.StreamInfo(name)
+	switch {
+	case errors.Is(err, nats.ErrStreamNotFound):
+		if !j.metadata.autoProvision {
+			return "", fmt.Errorf("stream %s does not exist", name)
+		}
+		err = j.createStream(name, topic)
+	case err != nil:
+		return "", err
+	default:
+		err = j.addStreamSubject(info.Config, topic)
+	}
+	if err != nil {
+		return "", err
+	}
+
+	j.streams[topic] = name
+
+	return name, nil
+}
+
+// streamOfSubject returns the name of the stream storing the subject, or an empty string if there is none
+func (j *jetStream) streamOfSubject(subject string) string {
+	var name string
+	// the channel is drained, so that the listing goroutine returns
+	for info := range j.jsc.StreamsInfo() {
+		if name != "" {
+			continue
+		}
+		for _, filter := range info.Config.Subjects {
+			if subjectMatches(filter, subject) {
+				name = info.Config.Name
+
+				break
+			}
+		}
+	}
+
+	return name
+}
+
+func (j *jetStream) createStream(name, topic string) error {
+	j.logger.Debugf("jetstream: creating stream %s of subject %s", name, topic)
+	_, err := j.jsc.AddStream(&nats.StreamConfig{
+		Name:       name,
+		Subjects:   []string{topic},
+		Retention:  nats.LimitsPolicy,
+		Storage:    storageTypes[j.metadata.storageType],
+		Replicas:   j.metadata.replicas,
+		MaxAge:     j.metadata.maxAge,
+		MaxMsgs:    -1,
+		MaxBytes:   -1,
+		MaxMsgSize: -1,
+		Discard:    nats.DiscardOld,
+	})
+
+	return err
+}
+
+// addStreamSubject adds the topic to the subjects of the stream unless one of them matches it already
+func (j *jetStream) addStreamSubject(config nats.StreamConfig, topic string) error {
+	for _, subject := range config.Subjects {
+		if subjectMatches(subject, topic) {
+			return nil
+		}
+	}
+	if !j.metadata.autoProvision {
+		return fmt.Errorf("stream %s does not store subject %s", config.Name, topic)
+	}
+
+	j.logger.Debugf("jetstream: adding subject %s to stream %s", topic, config.Name)
+	config.Subjects = append(config.Subjects, topic)
+	_, err := j.jsc.UpdateStream(&config)
+
+	return err
+}
+
+// ensureConsumer returns the configuration of the durable consumer of the topic, which is created when it does not exist
+func (j *jetStream) ensureConsumer(stream, durable, topic string) (*nats.ConsumerConfig, error) {
+	info, err := j.jsc.ConsumerInfo(stream, durable)
+	if err == nil {
+		return &info.Config, nil
+	}
+	if !errors.Is(err, nats.ErrConsumerNotFound) {
+		return nil, err
+	}
+
+	config := &nats.ConsumerConfig{
+		Durable:       durable,
+		DeliverPolicy: deliverPolicies[j.metadata.deliverPolicy],
+		AckPolicy:     nats.AckExplicitPolicy,
+		AckWait:       j.metadata.ackWait,
+		MaxDeliver:    j.metadata.maxDeliver,
+		FilterSubject: topic,
+		ReplayPolicy:  nats.ReplayInstantPolicy,
+		MaxAckPending: j.metadata.maxAckPending,
+	}
+	if j.metadata.consumerMode == consumerModePush {
+		config.DeliverSubject = nats.NewInbox()
+		config.DeliverGroup = durable
+	}
+
+	j.logger.Debugf("jetstream: creating %s consumer %s of stream %s", j.metadata.consumerMode, durable, stream)
+	info, err = j.jsc.AddConsumer(stream, config)
+	if err != nil {
+		return nil, err
+	}
+
+	return &info.Config, nil
+}
+
+// pull fetches batches of messages of the pull consumer and handles them, until the connection is closed
+func (j *jetStream) pull(durable, topic string, sub *nats.Subscription, handler func(msg *pubsub.NewMessage) error) {
+	for {
+		// the batch is over once it is received, or once the server stopped delivering it
+		msgs, err := sub.Fetch(j.metadata.pullBatchSize, nats.MaxWait(j.metadata.pullTimeout))
+		if err != nil && !errors.Is(err, nats.ErrTimeout) {
+			if j.natsConn.IsClosed() || !sub.IsValid() {
+				return
+			}
+			j.logger.Errorf("jetstream: error fetching messages of consumer %s: %s", durable, err)
+			time.Sleep(j.metadata.pullTimeout)
+
+			continue
+		}
+
+		for _, natsMsg := range msgs {
+			j.handleMessage(topic, natsMsg, handler)
+		}
+	}
+}
+
+func (j *jetStream) handleMessage(topic string, natsMsg *nats.Msg, handler func(msg *pubsub.NewMessage) error) {
+	msg := pubsub.NewMessage{Topic: topic, Data: natsMsg.Data}
+	err := handler(&msg)
+	if err != nil && j.metadata.deadLetter.Enabled() && j.metadata.deadLetter.Exhausted(deliveryCount(natsMsg)) {
+		err = j.metadata.deadLetter.DeadLetter(j, &msg)
+		if err != nil {
+			j.logger.Errorf("jetstream: %s", err)
+		}
+	}
+	if err != nil {
+		// the message is delivered again once the ack wait is over
+		return
+	}
+
+	// we only send a successful ACK if there is no error from Dapr runtime
+	if err = natsMsg.Ack(); err != nil {
+		j.logger.Warnf("jetstream: error acknowledging message of topic %s: %s", topic, err)
+	}
+}
+
+func (j *jetStream) Close() error {
+	j.natsConn.Close()
+
+	return nil
+}
+
+func (j *jetStream) Features() []pubsub.Feature {
+	return nil
+}
+
+// deliveryCount returns how many times a message was delivered, 0 if it is not a JetStream message
+func deliveryCount(natsMsg *nats.Msg) int {
+	meta, err := natsMsg.Metadata()
+	if err != nil {
+		return 0
+	}
+
+	return int(meta.NumDelivered)
+}
+
+// subjectMatches tells whether a subject matches a subject filter, which may hold * and > wildcards
+func subjectMatches(filter, subject string) bool {
+	filterTokens := strings.Split(filter, ".")
+	subjectTokens := strings.Split(subject, ".")
+	for i, token := range filterTokens {
+		if token == ">" {
+			return len(subjectTokens) > i
+		}
+		if i >= len(subjectTokens) || (token != "*" && token != subjectTokens[i]) {
+			return false
+		}
+	}
+
+	return len(filterTokens) == len(subjectTokens)
+}
+
+// sanitizeName returns a stream or consumer name, which cannot hold the subject separator and wildcards, nor whitespaces
+func sanitizeName(name string) string {
+	return strings.Map(func(r rune) rune {
+		switch r {
+		case '.', '*', '>', '/', '\\', ' ', '\t':
+			return '_'
+		default:
+			return r
+		}
+	}, name)
+}
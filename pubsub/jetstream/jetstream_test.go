@@ -0,0 +1,165 @@
+// ------------------------------------------------------------
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT License.
+// ------------------------------------------------------------
+
+package jetstream
+
+import (
+	"testing"
+	"time"
+
+	"github.com/dapr/components-contrib/pubsub"
+	nats "github.com/nats-io/nats.go"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseJetStreamMetadata(t *testing.T) {
+	t.Run("defaults", func(t *testing.T) {
+		fakeMetaData := pubsub.Metadata{
+			Properties: map[string]string{
+				natsURL:    "nats://foo",
+				consumerID: "fooq",
+			},
+		}
+
+		// act
+		m, err := parseJetStreamMetadata(fakeMetaData)
+
+		// assert
+		assert.NoError(t, err)
+		assert.Equal(t, "nats://foo", m.natsURL)
+		assert.Equal(t, "fooq", m.durableName)
+		assert.Empty(t, m.streamName)
+		assert.True(t, m.autoProvision)
+		assert.Equal(t, storageTypeFile, m.storageType)
+		assert.Equal(t, 1, m.replicas)
+		assert.Equal(t, consumerModePull, m.consumerMode)
+		assert.Equal(t, deliverPolicyAll, m.deliverPolicy)
+		assert.Equal(t, defaultAckWait, m.ackWait)
+		assert.Equal(t, defaultPullBatchSize, m.pullBatchSize)
+		assert.Equal(t, defaultPullTimeout, m.pullTimeout)
+	})
+
+	t.Run("metadata is correct", func(t *testing.T) {
+		fakeMetaData := pubsub.Metadata{
+			Properties: map[string]string{
+				natsURL:       "nats://foo",
+				consumerID:    "fooq",
+				durableName:   "foodurable",
+				streamName:    "FOO",
+				autoProvision: "false",
+				storageType:   storageTypeMemory,
+				replicas:      "3",
+				maxAge:        "24h",
+				consumerMode:  consumerModePush,
+				deliverPolicy: deliverPolicyNew,
+				ackWait:       "1m",
+				maxDeliver:    "5",
+				maxAckPending: "100",
+				pullBatchSize: "50",
+				pullTimeout:   "10s",
+			},
+		}
+
+		// act
+		m, err := parseJetStreamMetadata(fakeMetaData)
+
+		// assert
+		assert.NoError(t, err)
+		assert.Equal(t, "foodurable", m.durableName)
+		assert.Equal(t, "FOO", m.streamName)
+		assert.False(t, m.autoProvision)
+		assert.Equal(t, storageTypeMemory, m.storageType)
+		assert.Equal(t, 3, m.replicas)
+		assert.Equal(t, 24*time.Hour, m.maxAge)
+		assert.Equal(t, consumerModePush, m.consumerMode)
+		assert.Equal(t, deliverPolicyNew, m.deliverPolicy)
+		assert.Equal(t, time.Minute, m.ackWait)
+		assert.Equal(t, 5, m.maxDeliver)
+		assert.Equal(t, 100, m.maxAckPending)
+		assert.Equal(t, 50, m.pullBatchSize)
+		assert.Equal(t, 10*time.Second, m.pullTimeout)
+	})
+
+	t.Run("nats url is not given", func(t *testing.T) {
+		fakeMetaData := pubsub.Metadata{
+			Properties: map[string]string{consumerID: "fooq"},
+		}
+
+		// act
+		_, err := parseJetStreamMetadata(fakeMetaData)
+
+		// assert
+		assert.Error(t, err)
+	})
+
+	invalid := map[string]string{
+		autoProvision: "maybe",
+		storageType:   "disk",
+		replicas:      "0",
+		maxAge:        "forever",
+		consumerMode:  "poll",
+		deliverPolicy: "first",
+		ackWait:       "soon",
+		maxDeliver:    "0",
+		maxAckPending: "-1",
+		pullBatchSize: "0",
+		pullTimeout:   "0s",
+	}
+	for key, val := range invalid {
+		key, val := key, val
+		t.Run("invalid "+key, func(t *testing.T) {
+			fakeMetaData := pubsub.Metadata{
+				Properties: map[string]string{
+					natsURL:    "nats://foo",
+					consumerID: "fooq",
+					key:        val,
+				},
+			}
+
+			// act
+			_, err := parseJetStreamMetadata(fakeMetaData)
+
+			// assert
+			assert.Error(t, err)
+		})
+	}
+
+	t.Run("maxDeliveryAttempts exceeding maxDeliver", func(t *testing.T) {
+		fakeMetaData := pubsub.Metadata{
+			Properties: map[string]string{
+				natsURL:                               "nats://foo",
+				consumerID:                            "fooq",
+				maxDeliver:                            "3",
+				pubsub.DeadLetterTopicMetadataKey:     "poison",
+				pubsub.MaxDeliveryAttemptsMetadataKey: "5",
+			},
+		}
+
+		// act
+		_, err := parseJetStreamMetadata(fakeMetaData)
+
+		// assert
+		assert.Error(t, err)
+	})
+}
+
+func TestDeliveryCount(t *testing.T) {
+	assert.Equal(t, 0, deliveryCount(&nats.Msg{Subject: "orders", Reply: "_INBOX.foo"}))
+}
+
+func TestSubjectMatches(t *testing.T) {
+	assert.True(t, subjectMatches("orders", "orders"))
+	assert.True(t, subjectMatches("orders.*", "orders.new"))
+	assert.True(t, subjectMatches("orders.>", "orders.new.eu"))
+	assert.False(t, subjectMatches("orders.>", "orders"))
+	assert.False(t, subjectMatches("orders.*", "orders.new.eu"))
+	assert.False(t, subjectMatches("orders", "orders.new"))
+	assert.False(t, subjectMatches("orders.new", "orders"))
+}
+
+func TestSanitizeName(t *testing.T) {
+	assert.Equal(t, "orders_new_eu", sanitizeName("orders.new.eu"))
+	assert.Equal(t, "dapr-orders__", sanitizeName("dapr-orders.*"))
+}
@@ -0,0 +1,32 @@
+// ------------------------------------------------------------
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT License.
+// ------------------------------------------------------------
+
+package jetstream
+
+import (
+	"time"
+
+	"github.com/dapr/components-contrib/pubsub"
+)
+
+type metadata struct {
+	natsURL     string
+	durableName string
+	// streamName is the stream of all the topics, the stream of each topic is looked up by its subject when empty
+	streamName    string
+	autoProvision bool
+	storageType   string
+	replicas      int
+	maxAge        time.Duration
+
+	consumerMode  string
+	deliverPolicy string
+	ackWait       time.Duration
+	maxDeliver    int
+	maxAckPending int
+	pullBatchSize int
+	pullTimeout   time.Duration
+	deadLetter    pubsub.DeadLetterConfig
+}
@@ -8,16 +8,59 @@ package kafka
 import (
 	"context"
 	"crypto/tls"
+	"encoding/json"
 	"errors"
+	"fmt"
 	"strconv"
 	"strings"
 	"sync"
+	"time"
 
+	"github.com/Azure/go-autorest/autorest/azure/auth"
 	"github.com/Shopify/sarama"
+	contrib_metadata "github.com/dapr/components-contrib/metadata"
 	"github.com/dapr/components-contrib/pubsub"
+	"github.com/dapr/components-contrib/pubsub/schemaregistry"
 	"github.com/dapr/dapr/pkg/logger"
 )
 
+const (
+	// ceHeaderPrefix prefixes the cloud event attributes in the record headers in binary content mode
+	ceHeaderPrefix = "ce_"
+	// contentTypeHeader carries the datacontenttype attribute in binary content mode
+	contentTypeHeader = "content-type"
+	// headerMetadataPrefix prefixes the record headers in the publish and subscriber metadata,
+	// e.g. the metadata header.source is published and received as the source header
+	headerMetadataPrefix = "header."
+
+	// saslMechanismPlain authenticates with the SASL username and password
+	saslMechanismPlain = "PLAIN"
+	// saslMechanismOAuthBearer authenticates with tokens of an OIDC provider
+	saslMechanismOAuthBearer = "OAUTHBEARER"
+
+	// rebalanceStrategyRange assigns each consumer ranges of consecutive partitions of the topics
+	rebalanceStrategyRange = "range"
+	// rebalanceStrategyRoundRobin assigns the partitions of the topics to the consumers in turn
+	rebalanceStrategyRoundRobin = "roundrobin"
+	// rebalanceStrategySticky balances the partitions while keeping as many of the previous assignments as possible
+	rebalanceStrategySticky = "sticky"
+
+	// producerCompressionNone sends the record batches uncompressed
+	producerCompressionNone = "none"
+
+	// defaultSchemaCacheTTL is how long the latest schemas of the schema registry are cached by default
+	defaultSchemaCacheTTL = 5 * time.Minute
+)
+
+// producerCompressionCodecs are the compression codecs of the record batches, by their producerCompression metadata value
+var producerCompressionCodecs = map[string]sarama.CompressionCodec{
+	producerCompressionNone: sarama.CompressionNone,
+	"gzip":                  sarama.CompressionGZIP,
+	"snappy":                sarama.CompressionSnappy,
+	"lz4":                   sarama.CompressionLZ4,
+	"zstd":                  sarama.CompressionZSTD,
+}
+
 // Kafka allows reading/writing to a Kafka consumer group
 type Kafka struct {
 	producer      sarama.SyncProducer
@@ -27,11 +70,32 @@ type Kafka struct {
 	authRequired  bool
 	saslUsername  string
 	saslPassword  string
+	saslMechanism string
+	tokenProvider sarama.AccessTokenProvider
 	cg            sarama.ConsumerGroup
 	topics        map[string]bool
+	rawPayload    map[string]bool
 	cancel        context.CancelFunc
 	consumer      consumer
 	config        *sarama.Config
+	initialOffset int64
+	contentMode   string
+	deadLetter    pubsub.DeadLetterConfig
+	// maxExtensions caps the extension attributes of the binary mode cloud events received, 0 if there is no limit
+	maxExtensions int
+	callback      func(msg *pubsub.NewMessage) error
+	bulkCallback  func(msg *pubsub.BulkMessage) (pubsub.BulkSubscribeResponse, error)
+	// bulk holds the configuration of the topics subscribed in bulk
+	bulk map[string]pubsub.BulkSubscribeConfig
+	// serializer serializes record values with the schema registry, nil if there is none
+	serializer      *schemaregistry.Serializer
+	valueSchemaType map[string]schemaregistry.SchemaType
+	// compression is the default compression of the published cloud event data
+	compression string
+	// partitionKeyPath is the default path of the record keys in the cloud event data
+	partitionKeyPath pubsub.KeyPath
+	// txnLock serializes the transactions of a transactional producer, which runs one at a time
+	txnLock sync.Mutex
 }
 
 type kafkaMetadata struct {
@@ -40,21 +104,84 @@ type kafkaMetadata struct {
 	AuthRequired bool     `json:"authRequired"`
 	SaslUsername string   `json:"saslUsername"`
 	SaslPassword string   `json:"saslPassword"`
+	// SaslMechanism is PLAIN or OAUTHBEARER, whose tokens are retrieved with the OIDC client credentials flow
+	SaslMechanism     string            `json:"saslMechanism"`
+	OidcTokenEndpoint string            `json:"oidcTokenEndpoint"`
+	OidcClientID      string            `json:"oidcClientID"`
+	OidcClientSecret  string            `json:"oidcClientSecret"`
+	OidcScopes        []string          `json:"oidcScopes"`
+	OidcExtensions    map[string]string `json:"oidcExtensions"`
+	ContentMode       string            `json:"contentMode"`
+	DeadLetter        pubsub.DeadLetterConfig
+	MaxExtensions     int `json:"maxCloudEventExtensions"`
+
+	SchemaRegistryURL       string        `json:"schemaRegistryURL"`
+	SchemaRegistryAPIKey    string        `json:"schemaRegistryAPIKey"`
+	SchemaRegistryAPISecret string        `json:"schemaRegistryAPISecret"`
+	SchemaRegistryNamespace string        `json:"schemaRegistryNamespace"`
+	SchemaRegistryGroup     string        `json:"schemaRegistryGroup"`
+	SchemaCacheTTL          time.Duration `json:"schemaCacheTTL"`
+
+	// SessionTimeout is how long the group coordinator waits for heartbeats before removing a consumer from the group
+	SessionTimeout    time.Duration `json:"sessionTimeout"`
+	HeartbeatInterval time.Duration `json:"heartbeatInterval"`
+	// MaxPollInterval is how long the consumers have to finish processing their messages and rejoin the group on rebalance
+	MaxPollInterval   time.Duration `json:"maxPollInterval"`
+	RebalanceStrategy string        `json:"rebalanceStrategy"`
+	// GroupInstanceID is the static member ID of KIP-345, which keeps the partitions of a consumer restarting
+	// within the session timeout
+	GroupInstanceID string `json:"groupInstanceID"`
+
+	// InitialOffset is where the consumer group starts consuming the topics it has not consumed yet:
+	// sarama.OffsetOldest, sarama.OffsetNewest or a time in milliseconds
+	InitialOffset int64 `json:"initialOffset"`
+
+	// EnableIdempotence makes the producer idempotent, so that retries do not duplicate records
+	EnableIdempotence bool `json:"enableIdempotence"`
+	// TransactionalID makes the producer transactional, so that the records of a publish are committed atomically,
+	// which implies EnableIdempotence
+	TransactionalID string `json:"transactionalID"`
+
+	Compression      string         `json:"compression"`
+	PartitionKeyPath pubsub.KeyPath `json:"partitionKeyPath"`
+
+	// ProducerCompression compresses the record batches sent by the producer, whereas Compression compresses the cloud event data
+	ProducerCompression sarama.CompressionCodec `json:"producerCompression"`
+	// BatchSize is the number of bytes of records the producer sends at once, records are sent once Linger elapsed otherwise
+	BatchSize int `json:"batchSize"`
+	// Linger is how long the producer waits for records to batch before sending them
+	Linger time.Duration `json:"lingerMs"`
+	// MaxInFlightRequests is the number of requests the producer sends to a broker without waiting for their response
+	MaxInFlightRequests int `json:"maxInFlightRequests"`
 }
 
 type consumer struct {
 	ready    chan bool
 	callback func(msg *pubsub.NewMessage) error
 	once     sync.Once
+	// rawPayload holds the topics whose records are delivered as is
+	rawPayload map[string]bool
+	deadLetter pubsub.DeadLetterConfig
+	// maxExtensions caps the extension attributes of the binary mode cloud events, 0 if there is no limit
+	maxExtensions int
+	// publisher publishes the records that cannot be delivered to the dead letter topic
+	publisher pubsub.PubSub
+	// bulkCallback is called with the records of the topics in bulk
+	bulkCallback func(msg *pubsub.BulkMessage) (pubsub.BulkSubscribeResponse, error)
+	bulk         map[string]pubsub.BulkSubscribeConfig
+	// valueSchemaType holds the schema type of the record values of the topics, deserialized with the serializer
+	valueSchemaType map[string]schemaregistry.SchemaType
+	serializer      *schemaregistry.Serializer
 }
 
 func (consumer *consumer) ConsumeClaim(session sarama.ConsumerGroupSession, claim sarama.ConsumerGroupClaim) error {
+	if config, ok := consumer.bulk[claim.Topic()]; ok && consumer.bulkCallback != nil {
+		return consumer.consumeBulkClaim(session, claim, config)
+	}
+
 	for message := range claim.Messages() {
 		if consumer.callback != nil {
-			err := consumer.callback(&pubsub.NewMessage{
-				Topic: claim.Topic(),
-				Data:  message.Value,
-			})
+			err := consumer.deliver(claim.Topic(), message)
 			if err == nil {
 				session.MarkMessage(message, "")
 			}
@@ -64,6 +191,175 @@ func (consumer *consumer) ConsumeClaim(session sarama.ConsumerGroupSession, clai
 	return nil
 }
 
+// deliver delivers a record to the callback, retrying up to the max delivery attempts.
+// Records that cannot be delivered are published to the dead letter topic if there is one,
+// as delivered or as is when they cannot be read.
+func (consumer *consumer) deliver(topic string, message *sarama.ConsumerMessage) error {
+	msg, err := consumer.readMessage(topic, message)
+	if err != nil {
+		msg = &pubsub.NewMessage{
+			Topic: topic,
+			Data:  message.Value,
+		}
+	} else {
+		err = consumer.deadLetter.Deliver(msg, consumer.callback)
+	}
+	if err == nil || !consumer.deadLetter.Enabled() {
+		return err
+	}
+
+	return consumer.deadLetter.DeadLetter(consumer.publisher, msg)
+}
+
+// consumeBulkClaim delivers the records of a claim as bulk messages of at most MaxCount records,
+// records are awaited at most MaxAwaitDuration before a bulk message that is not full is delivered.
+func (consumer *consumer) consumeBulkClaim(session sarama.ConsumerGroupSession, claim sarama.ConsumerGroupClaim, config pubsub.BulkSubscribeConfig) error {
+	ticker := time.NewTicker(config.MaxAwaitDuration)
+	defer ticker.Stop()
+
+	messages := make([]*sarama.ConsumerMessage, 0, config.MaxCount)
+	for {
+		select {
+		case message, ok := <-claim.Messages():
+			if !ok {
+				consumer.deliverBulk(session, claim.Topic(), messages)
+
+				return nil
+			}
+
+			messages = append(messages, message)
+			if len(messages) >= config.MaxCount {
+				consumer.deliverBulk(session, claim.Topic(), messages)
+				messages = messages[:0]
+			}
+		case <-ticker.C:
+			consumer.deliverBulk(session, claim.Topic(), messages)
+			messages = messages[:0]
+		}
+	}
+}
+
+// deliverBulk delivers the records as a bulk message, identifying the entries by their offset,
+// and marks the records of the entries that did not fail
+func (consumer *consumer) deliverBulk(session sarama.ConsumerGroupSession, topic string, messages []*sarama.ConsumerMessage) {
+	if len(messages) == 0 {
+		return
+	}
+
+	bulkMessage := &pubsub.BulkMessage{
+		Topic:   topic,
+		Entries: make([]pubsub.BulkMessageEntry, 0, len(messages)),
+	}
+	delivered := make(map[string]*sarama.ConsumerMessage, len(messages))
+	for _, message := range messages {
+		msg, err := consumer.readMessage(topic, message)
+		if err != nil {
+			continue
+		}
+
+		entryID := strconv.FormatInt(message.Offset, 10)
+		bulkMessage.Entries = append(bulkMessage.Entries, pubsub.BulkMessageEntry{
+			EntryID:  entryID,
+			Event:    msg.Data,
+			Metadata: msg.Metadata,
+		})
+		delivered[entryID] = message
+	}
+
+	res, err := consumer.bulkCallback(bulkMessage)
+	if err != nil {
+		return
+	}
+
+	failed := res.Errors()
+	for _, entry := range bulkMessage.Entries {
+		if _, ok := failed[entry.EntryID]; !ok {
+			session.MarkMessage(delivered[entry.EntryID], "")
+		}
+	}
+}
+
+// readMessage returns the message for a record, deserializing its value with the schema registry first
+// for the topics subscribed with a value schema type
+func (consumer *consumer) readMessage(topic string, message *sarama.ConsumerMessage) (*pubsub.NewMessage, error) {
+	if consumer.valueSchemaType[topic] != schemaregistry.None {
+		value, err := consumer.serializer.Deserialize(message.Value)
+		if err != nil {
+			return nil, fmt.Errorf("kafka error: cannot deserialize record at offset %d: %s", message.Offset, err)
+		}
+
+		deserialized := *message
+		deserialized.Value = value
+		message = &deserialized
+	}
+
+	return newMessage(topic, message, consumer.rawPayload[topic], consumer.maxExtensions)
+}
+
+// newMessage returns the message for a record, converting cloud events in binary content mode to structured ones
+// unless the raw payload is requested. The record headers are passed in the metadata.
+func newMessage(topic string, message *sarama.ConsumerMessage, rawPayload bool, maxExtensions int) (*pubsub.NewMessage, error) {
+	if rawPayload {
+		return &pubsub.NewMessage{
+			Topic:    topic,
+			Data:     message.Value,
+			Metadata: headersMetadata(message.Headers, false),
+		}, nil
+	}
+
+	var contentType string
+	headers := make(map[string]string, len(message.Headers))
+	for _, h := range message.Headers {
+		if string(h.Key) == contentTypeHeader {
+			contentType = string(h.Value)
+		}
+		headers[string(h.Key)] = string(h.Value)
+	}
+
+	data, binary, err := pubsub.FromBinaryMessage(headers, ceHeaderPrefix, contentType, message.Value, maxExtensions)
+	if err != nil {
+		return nil, fmt.Errorf("kafka error: invalid binary cloud event at offset %d: %s", message.Offset, err)
+	}
+
+	data, err = pubsub.DecompressCloudEvent(data)
+	if err != nil {
+		return nil, fmt.Errorf("kafka error: invalid cloud event at offset %d: %s", message.Offset, err)
+	}
+
+	return &pubsub.NewMessage{
+		Topic:    topic,
+		Data:     data,
+		Metadata: headersMetadata(message.Headers, binary),
+	}, nil
+}
+
+// headersMetadata returns the subscriber metadata of the record headers, nil if there are none.
+// The headers of the cloud event attributes are left out in binary content mode, they are in the event already.
+func headersMetadata(headers []*sarama.RecordHeader, binary bool) map[string]string {
+	var metadata map[string]string
+	for _, h := range headers {
+		key := string(h.Key)
+		if binary && (strings.HasPrefix(key, ceHeaderPrefix) || key == contentTypeHeader) {
+			continue
+		}
+		if metadata == nil {
+			metadata = make(map[string]string, len(headers))
+		}
+		metadata[headerMetadataPrefix+key] = string(h.Value)
+	}
+
+	return metadata
+}
+
+// addHeaders adds the headers of the publish metadata to the record
+func addHeaders(msg *sarama.ProducerMessage, metadata map[string]string) {
+	for name, value := range metadata {
+		if key := strings.TrimPrefix(name, headerMetadataPrefix); key != name && key != "" {
+			msg.Headers = append(msg.Headers, sarama.RecordHeader{Key: []byte(key), Value: []byte(value)})
+		}
+	}
+}
+
 func (consumer *consumer) Cleanup(sarama.ConsumerGroupSession) error {
 	return nil
 }
@@ -88,6 +384,16 @@ func (k *Kafka) Init(metadata pubsub.Metadata) error {
 		return err
 	}
 
+	k.authRequired = meta.AuthRequired
+	if meta.AuthRequired {
+		k.saslUsername = meta.SaslUsername
+		k.saslPassword = meta.SaslPassword
+		k.saslMechanism = meta.SaslMechanism
+		if meta.SaslMechanism == saslMechanismOAuthBearer {
+			k.tokenProvider = newOIDCTokenProvider(meta)
+		}
+	}
+
 	p, err := k.getSyncProducer(meta)
 	if err != nil {
 		return err
@@ -96,22 +402,38 @@ func (k *Kafka) Init(metadata pubsub.Metadata) error {
 	k.brokers = meta.Brokers
 	k.producer = p
 	k.consumerGroup = meta.ConsumerID
+	k.contentMode = meta.ContentMode
+	k.deadLetter = meta.DeadLetter
+	k.maxExtensions = meta.MaxExtensions
+	k.compression = meta.Compression
+	k.partitionKeyPath = meta.PartitionKeyPath
 
-	if meta.AuthRequired {
-		k.saslUsername = meta.SaslUsername
-		k.saslPassword = meta.SaslPassword
+	k.serializer, err = newSerializer(meta)
+	if err != nil {
+		return err
 	}
 
 	config := sarama.NewConfig()
 	config.Version = sarama.V2_0_0_0
 
 	if k.authRequired {
-		updateAuthInfo(config, k.saslUsername, k.saslPassword)
+		k.updateAuthInfo(config)
+	}
+
+	updateConsumerGroupInfo(config, meta)
+
+	if meta.ProducerCompression == sarama.CompressionZSTD {
+		// the records the component publishes with zstd are fetched with the Fetch v10 request of Kafka 2.1
+		requireVersion(config, sarama.V2_1_0_0)
 	}
 
 	k.config = config
+	k.initialOffset = meta.InitialOffset
 
 	k.topics = make(map[string]bool)
+	k.rawPayload = make(map[string]bool)
+	k.bulk = make(map[string]pubsub.BulkSubscribeConfig)
+	k.valueSchemaType = make(map[string]schemaregistry.SchemaType)
 
 	k.logger.Debug("Kafka message bus initialization complete")
 
@@ -121,10 +443,45 @@ func (k *Kafka) Init(metadata pubsub.Metadata) error {
 // Publish message to Kafka cluster
 func (k *Kafka) Publish(req *pubsub.PublishRequest) error {
 	k.logger.Debugf("Publishing topic %v with data: %v", req.Topic, req.Data)
-	partition, offset, err := k.producer.SendMessage(&sarama.ProducerMessage{
-		Topic: req.Topic,
-		Value: sarama.ByteEncoder(req.Data),
-	})
+	rawPayload, err := contrib_metadata.IsRawPayload(req.Metadata)
+	if err != nil {
+		return fmt.Errorf("kafka error: %s", err)
+	}
+
+	schemaType, err := k.parseSchemaType(req.Metadata)
+	if err != nil {
+		return err
+	}
+
+	compression, err := k.parseCompression(req.Metadata, rawPayload, schemaType)
+	if err != nil {
+		return err
+	}
+
+	key, err := k.partitionKey(req.Data, req.Metadata, rawPayload)
+	if err != nil {
+		return err
+	}
+
+	data, err := pubsub.CompressCloudEvent(req.Data, compression)
+	if err != nil {
+		return fmt.Errorf("kafka error: %s", err)
+	}
+
+	msg, err := k.newProducerMessage(req.Topic, data, rawPayload, schemaType)
+	if err != nil {
+		return err
+	}
+	if key != "" {
+		msg.Key = sarama.StringEncoder(key)
+	}
+	addHeaders(msg, req.Metadata)
+
+	if k.producer.IsTransactional() {
+		return k.sendMessages([]*sarama.ProducerMessage{msg})
+	}
+
+	partition, offset, err := k.producer.SendMessage(msg)
 
 	k.logger.Debugf("Partition: %v, offset: %v", partition, offset)
 
@@ -135,19 +492,227 @@ func (k *Kafka) Publish(req *pubsub.PublishRequest) error {
 	return nil
 }
 
-func (k *Kafka) addTopic(newTopic string) []string {
-	// Add topic to our map of topics
-	k.topics[newTopic] = true
+// BulkPublish sends the entries to the Kafka cluster in a single batch.
+// With a transactional producer, the entries are committed in a single transaction, so that either all or none are published.
+func (k *Kafka) BulkPublish(req *pubsub.BulkPublishRequest) (pubsub.BulkPublishResponse, error) {
+	rawPayload, err := contrib_metadata.IsRawPayload(req.Metadata)
+	if err != nil {
+		err = fmt.Errorf("kafka error: %s", err)
+
+		return pubsub.NewBulkPublishErrorResponse(req, err), err
+	}
 
-	topics := make([]string, len(k.topics))
+	schemaType, err := k.parseSchemaType(req.Metadata)
+	if err != nil {
+		return pubsub.NewBulkPublishErrorResponse(req, err), err
+	}
 
-	i := 0
-	for topic := range k.topics {
-		topics[i] = topic
-		i++
+	compression, err := k.parseCompression(req.Metadata, rawPayload, schemaType)
+	if err != nil {
+		return pubsub.NewBulkPublishErrorResponse(req, err), err
+	}
+
+	res := pubsub.BulkPublishResponse{}
+	msgs := make([]*sarama.ProducerMessage, 0, len(req.Entries))
+	for _, entry := range req.Entries {
+		// the entry metadata overrides the request metadata
+		metadata := make(map[string]string, len(req.Metadata)+len(entry.Metadata))
+		for name, value := range req.Metadata {
+			metadata[name] = value
+		}
+		for name, value := range entry.Metadata {
+			metadata[name] = value
+		}
+
+		key, err := k.partitionKey(entry.Event, metadata, rawPayload)
+		if err != nil {
+			res.FailedEntries = append(res.FailedEntries, pubsub.BulkPublishFailedEntry{EntryID: entry.EntryID, Error: err})
+
+			continue
+		}
+
+		data, err := pubsub.CompressCloudEvent(entry.Event, compression)
+		if err != nil {
+			res.FailedEntries = append(res.FailedEntries, pubsub.BulkPublishFailedEntry{EntryID: entry.EntryID, Error: fmt.Errorf("kafka error: %s", err)})
+
+			continue
+		}
+
+		msg, err := k.newProducerMessage(req.Topic, data, rawPayload, schemaType)
+		if err != nil {
+			res.FailedEntries = append(res.FailedEntries, pubsub.BulkPublishFailedEntry{EntryID: entry.EntryID, Error: err})
+
+			continue
+		}
+		if key != "" {
+			msg.Key = sarama.StringEncoder(key)
+		}
+		addHeaders(msg, metadata)
+		msg.Metadata = entry.EntryID
+		msgs = append(msgs, msg)
+	}
+
+	if len(res.FailedEntries) > 0 && k.producer.IsTransactional() {
+		failed := res.FailedEntries[0]
+		err = fmt.Errorf("kafka error: the transaction is not published, entry %s failed: %s", failed.EntryID, failed.Error)
+
+		return pubsub.NewBulkPublishErrorResponse(req, err), err
 	}
 
-	return topics
+	k.logger.Debugf("Publishing %d messages to topic %v", len(msgs), req.Topic)
+	err = k.sendMessages(msgs)
+	if err == nil {
+		return res, nil
+	}
+
+	var producerErrors sarama.ProducerErrors
+	if k.producer.IsTransactional() || !errors.As(err, &producerErrors) {
+		return pubsub.NewBulkPublishErrorResponse(req, err), err
+	}
+
+	for _, pe := range producerErrors {
+		entryID, _ := pe.Msg.Metadata.(string)
+		res.FailedEntries = append(res.FailedEntries, pubsub.BulkPublishFailedEntry{EntryID: entryID, Error: pe.Err})
+	}
+
+	return res, nil
+}
+
+// sendMessages sends the messages, in a transaction if the producer is transactional, which is aborted if any of them fails
+func (k *Kafka) sendMessages(msgs []*sarama.ProducerMessage) error {
+	if !k.producer.IsTransactional() {
+		return k.producer.SendMessages(msgs)
+	}
+
+	k.txnLock.Lock()
+	defer k.txnLock.Unlock()
+
+	if err := k.producer.BeginTxn(); err != nil {
+		return fmt.Errorf("kafka error: failed to begin the transaction: %s", err)
+	}
+
+	err := k.producer.SendMessages(msgs)
+	if err == nil {
+		err = k.producer.CommitTxn()
+		if err == nil {
+			return nil
+		}
+		err = fmt.Errorf("kafka error: failed to commit the transaction: %s", err)
+	}
+
+	if abortErr := k.producer.AbortTxn(); abortErr != nil {
+		k.logger.Warnf("kafka error: failed to abort the transaction: %s", abortErr)
+	}
+
+	return err
+}
+
+// newProducerMessage returns the message to publish the data to the topic with, according to the content mode.
+// Raw payloads are published as is. With a value schema type, the value, that is the raw payload or the data of
+// the cloud event in binary content mode, is serialized with the schema registry.
+func (k *Kafka) newProducerMessage(topic string, data []byte, rawPayload bool, schemaType schemaregistry.SchemaType) (*sarama.ProducerMessage, error) {
+	msg := &sarama.ProducerMessage{
+		Topic: topic,
+		Value: sarama.ByteEncoder(data),
+	}
+
+	if rawPayload || k.contentMode != pubsub.ContentModeBinary {
+		if schemaType == schemaregistry.None {
+			return msg, nil
+		}
+		if !rawPayload {
+			return nil, errors.New("kafka error: a value schema type requires a raw payload or the binary content mode")
+		}
+
+		value, err := k.serialize(topic, schemaType, data)
+		if err != nil {
+			return nil, err
+		}
+		msg.Value = sarama.ByteEncoder(value)
+
+		return msg, nil
+	}
+
+	binary, err := pubsub.ToBinaryMessage(data, ceHeaderPrefix)
+	if err != nil {
+		return nil, fmt.Errorf("kafka error: binary content mode requires a cloud event: %s", err)
+	}
+
+	if schemaType != schemaregistry.None {
+		binary.Data, err = k.serialize(topic, schemaType, binary.Data)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	msg.Value = sarama.ByteEncoder(binary.Data)
+	for key, value := range binary.Headers {
+		msg.Headers = append(msg.Headers, sarama.RecordHeader{Key: []byte(key), Value: []byte(value)})
+	}
+	if binary.ContentType != "" {
+		msg.Headers = append(msg.Headers, sarama.RecordHeader{Key: []byte(contentTypeHeader), Value: []byte(binary.ContentType)})
+	}
+
+	return msg, nil
+}
+
+func (k *Kafka) serialize(topic string, schemaType schemaregistry.SchemaType, data []byte) ([]byte, error) {
+	value, err := k.serializer.Serialize(topic, schemaType, data)
+	if err != nil {
+		return nil, fmt.Errorf("kafka error: cannot serialize value: %s", err)
+	}
+
+	return value, nil
+}
+
+// parseSchemaType returns the value schema type of the request metadata, which requires a schema registry
+func (k *Kafka) parseSchemaType(metadata map[string]string) (schemaregistry.SchemaType, error) {
+	schemaType, err := schemaregistry.ParseSchemaType(metadata)
+	if err != nil {
+		return schemaregistry.None, fmt.Errorf("kafka error: %s", err)
+	}
+	if schemaType != schemaregistry.None && k.serializer == nil {
+		return schemaregistry.None, fmt.Errorf("kafka error: %s requires a schema registry", schemaregistry.ValueSchemaTypeMetadataKey)
+	}
+
+	return schemaType, nil
+}
+
+// partitionKey returns the record key of a message, read from the cloud event data with the partition key path
+// unless it is set in the metadata. Raw payloads are only keyed by the metadata.
+func (k *Kafka) partitionKey(data []byte, metadata map[string]string, rawPayload bool) (string, error) {
+	if rawPayload {
+		return metadata[pubsub.PartitionKeyMetadataKey], nil
+	}
+
+	key, err := pubsub.PartitionKey(data, metadata, k.partitionKeyPath)
+	if err != nil {
+		return "", fmt.Errorf("kafka error: %s", err)
+	}
+
+	return key, nil
+}
+
+// parseCompression returns the compression of the cloud event data of a request, that of the component by default.
+// Raw payloads are not cloud events, so they are not compressed, and values serialized with a schema cannot be.
+func (k *Kafka) parseCompression(metadata map[string]string, rawPayload bool, schemaType schemaregistry.SchemaType) (string, error) {
+	compression, err := pubsub.ParseCompression(metadata, k.compression)
+	if err != nil {
+		return pubsub.CompressionNone, fmt.Errorf("kafka error: %s", err)
+	}
+	if rawPayload {
+		return pubsub.CompressionNone, nil
+	}
+	if compression != pubsub.CompressionNone && schemaType != schemaregistry.None {
+		return pubsub.CompressionNone, fmt.Errorf("kafka error: %s cannot be combined with %s", pubsub.CompressionMetadataKey, schemaregistry.ValueSchemaTypeMetadataKey)
+	}
+
+	return compression, nil
+}
+
+func (k *Kafka) addTopic(newTopic string) {
+	// Add topic to our map of topics
+	k.topics[newTopic] = true
 }
 
 // Close down consumer group resources, refresh once
@@ -169,11 +734,109 @@ func (k *Kafka) closeSubscripionResources() {
 // Subscribe to topic in the Kafka cluster
 // This call cannot block like its sibling in bindings/kafka because of where this is invoked in runtime.go
 func (k *Kafka) Subscribe(req pubsub.SubscribeRequest, handler func(msg *pubsub.NewMessage) error) error {
-	topics := k.addTopic(req.Topic)
+	rawPayload, err := contrib_metadata.IsRawPayload(req.Metadata)
+	if err != nil {
+		return fmt.Errorf("kafka error: %s", err)
+	}
+
+	schemaType, err := k.parseSchemaType(req.Metadata)
+	if err != nil {
+		return err
+	}
+
+	initialOffset, err := k.parseInitialOffset(req.Metadata)
+	if err != nil {
+		return err
+	}
+
+	k.callback = handler
+	delete(k.bulk, req.Topic)
+
+	return k.subscribe(req.Topic, rawPayload, schemaType, initialOffset)
+}
+
+// BulkSubscribe to topic in the Kafka cluster, the records of a partition are delivered as bulk messages
+func (k *Kafka) BulkSubscribe(req pubsub.SubscribeRequest, handler func(msg *pubsub.BulkMessage) (pubsub.BulkSubscribeResponse, error)) error {
+	rawPayload, err := contrib_metadata.IsRawPayload(req.Metadata)
+	if err != nil {
+		return fmt.Errorf("kafka error: %s", err)
+	}
+
+	config, err := pubsub.ParseBulkSubscribeConfig(req.Metadata)
+	if err != nil {
+		return fmt.Errorf("kafka error: %s", err)
+	}
+
+	schemaType, err := k.parseSchemaType(req.Metadata)
+	if err != nil {
+		return err
+	}
+
+	initialOffset, err := k.parseInitialOffset(req.Metadata)
+	if err != nil {
+		return err
+	}
+
+	k.bulkCallback = handler
+	k.bulk[req.Topic] = config
+
+	return k.subscribe(req.Topic, rawPayload, schemaType, initialOffset)
+}
+
+// parseInitialOffset returns the initial offset of the subscription metadata, that of the component by default
+func (k *Kafka) parseInitialOffset(metadata map[string]string) (int64, error) {
+	val, ok := metadata[initialOffsetMetadataKey]
+	if !ok || val == "" {
+		return k.initialOffset, nil
+	}
+
+	initialOffset, err := parseInitialOffset(val)
+	if err != nil {
+		return 0, fmt.Errorf("kafka error: %s", err)
+	}
+
+	return initialOffset, nil
+}
+
+// subscribe adds the topic to the topics of the consumer group, which is restarted
+func (k *Kafka) subscribe(newTopic string, rawPayload bool, schemaType schemaregistry.SchemaType, initialOffset int64) error {
+	k.addTopic(newTopic)
+	k.rawPayload[newTopic] = rawPayload
+	k.valueSchemaType[newTopic] = schemaType
 
 	// Close resources and reset synchronization primitives
 	k.closeSubscripionResources()
 
+	if initialOffset != k.config.Consumer.Offsets.Initial {
+		// the consumer group starts the partitions it has not consumed yet from the initial offset of the config,
+		// those of the topic are moved to the initial offset of the subscription first
+		if err := k.seek(newTopic, initialOffset, false); err != nil {
+			return err
+		}
+	}
+
+	return k.consume()
+}
+
+// consume starts the consumer group on the subscribed topics
+func (k *Kafka) consume() error {
+	topics := make([]string, 0, len(k.topics))
+	for topic := range k.topics {
+		topics = append(topics, topic)
+	}
+	consumerRawPayload := make(map[string]bool, len(k.rawPayload))
+	for topic, raw := range k.rawPayload {
+		consumerRawPayload[topic] = raw
+	}
+	consumerValueSchemaType := make(map[string]schemaregistry.SchemaType, len(k.valueSchemaType))
+	for topic, schemaType := range k.valueSchemaType {
+		consumerValueSchemaType[topic] = schemaType
+	}
+	consumerBulk := make(map[string]pubsub.BulkSubscribeConfig, len(k.bulk))
+	for topic, config := range k.bulk {
+		consumerBulk[topic] = config
+	}
+
 	cg, err := sarama.NewConsumerGroup(k.brokers, k.consumerGroup, k.config)
 	if err != nil {
 		return err
@@ -186,8 +849,17 @@ func (k *Kafka) Subscribe(req pubsub.SubscribeRequest, handler func(msg *pubsub.
 
 	ready := make(chan bool)
 	k.consumer = consumer{
-		ready:    ready,
-		callback: handler,
+		ready:         ready,
+		callback:      k.callback,
+		rawPayload:    consumerRawPayload,
+		deadLetter:    k.deadLetter,
+		maxExtensions: k.maxExtensions,
+		publisher:     k,
+		bulkCallback:  k.bulkCallback,
+		bulk:          consumerBulk,
+
+		valueSchemaType: consumerValueSchemaType,
+		serializer:      k.serializer,
 	}
 
 	go func() {
@@ -251,45 +923,351 @@ func (k *Kafka) getKafkaMetadata(metadata pubsub.Metadata) (*kafkaMetadata, erro
 
 	// ignore SASL properties if authRequired is false
 	if meta.AuthRequired {
-		if val, ok := metadata.Properties["saslUsername"]; ok && val != "" {
-			meta.SaslUsername = val
-		} else {
-			return nil, errors.New("kafka error: missing SASL Username")
+		meta.SaslMechanism = strings.ToUpper(metadata.Properties["saslMechanism"])
+		switch meta.SaslMechanism {
+		case "", saslMechanismPlain:
+			meta.SaslMechanism = saslMechanismPlain
+			if val, ok := metadata.Properties["saslUsername"]; ok && val != "" {
+				meta.SaslUsername = val
+			} else {
+				return nil, errors.New("kafka error: missing SASL Username")
+			}
+
+			if val, ok := metadata.Properties["saslPassword"]; ok && val != "" {
+				meta.SaslPassword = val
+			} else {
+				return nil, errors.New("kafka error: missing SASL Password")
+			}
+		case saslMechanismOAuthBearer:
+			if err := parseOIDCMetadata(metadata, &meta); err != nil {
+				return nil, err
+			}
+		default:
+			return nil, fmt.Errorf("kafka error: invalid value for 'saslMechanism' attribute, expected %s or %s", saslMechanismPlain, saslMechanismOAuthBearer)
 		}
+	}
 
-		if val, ok := metadata.Properties["saslPassword"]; ok && val != "" {
-			meta.SaslPassword = val
-		} else {
-			return nil, errors.New("kafka error: missing SASL Password")
+	meta.ContentMode, err = pubsub.ParseContentMode(metadata.Properties)
+	if err != nil {
+		return nil, fmt.Errorf("kafka error: %s", err)
+	}
+
+	meta.DeadLetter, err = pubsub.ParseDeadLetterConfig(metadata.Properties)
+	if err != nil {
+		return nil, fmt.Errorf("kafka error: %s", err)
+	}
+
+	meta.MaxExtensions, err = pubsub.ParseMaxExtensions(metadata.Properties)
+	if err != nil {
+		return nil, fmt.Errorf("kafka error: %s", err)
+	}
+
+	meta.SchemaRegistryURL = metadata.Properties["schemaRegistryURL"]
+	meta.SchemaRegistryAPIKey = metadata.Properties["schemaRegistryAPIKey"]
+	meta.SchemaRegistryAPISecret = metadata.Properties["schemaRegistryAPISecret"]
+	meta.SchemaRegistryNamespace = metadata.Properties["schemaRegistryNamespace"]
+	meta.SchemaRegistryGroup = metadata.Properties["schemaRegistryGroup"]
+	if meta.SchemaRegistryURL != "" && meta.SchemaRegistryNamespace != "" {
+		return nil, errors.New("kafka error: 'schemaRegistryURL' and 'schemaRegistryNamespace' are mutually exclusive")
+	}
+	if meta.SchemaRegistryNamespace != "" && meta.SchemaRegistryGroup == "" {
+		return nil, errors.New("kafka error: missing 'schemaRegistryGroup' attribute")
+	}
+
+	meta.Compression, err = pubsub.ParseCompression(metadata.Properties, pubsub.CompressionNone)
+	if err != nil {
+		return nil, fmt.Errorf("kafka error: %s", err)
+	}
+
+	meta.PartitionKeyPath, err = pubsub.ParseKeyPath(metadata.Properties[pubsub.PartitionKeyPathMetadataKey])
+	if err != nil {
+		return nil, fmt.Errorf("kafka error: %s", err)
+	}
+
+	meta.SchemaCacheTTL = defaultSchemaCacheTTL
+	if val, ok := metadata.Properties["schemaCacheTTL"]; ok && val != "" {
+		meta.SchemaCacheTTL, err = time.ParseDuration(val)
+		if err != nil {
+			return nil, fmt.Errorf("kafka error: invalid value for 'schemaCacheTTL' attribute: %s", err)
 		}
 	}
 
+	if err = parseConsumerGroupMetadata(metadata, &meta); err != nil {
+		return nil, err
+	}
+
+	meta.InitialOffset = sarama.OffsetNewest
+	if val, ok := metadata.Properties[initialOffsetMetadataKey]; ok && val != "" {
+		meta.InitialOffset, err = parseInitialOffset(val)
+		if err != nil {
+			return nil, fmt.Errorf("kafka error: %s", err)
+		}
+	}
+
+	if val, ok := metadata.Properties["enableIdempotence"]; ok && val != "" {
+		meta.EnableIdempotence, err = strconv.ParseBool(val)
+		if err != nil {
+			return nil, errors.New("kafka error: invalid value for 'enableIdempotence' attribute")
+		}
+	}
+
+	if val, ok := metadata.Properties["transactionalID"]; ok && val != "" {
+		// only an idempotent producer can be transactional
+		meta.TransactionalID = val
+		meta.EnableIdempotence = true
+	}
+
+	if err = parseProducerMetadata(metadata, &meta); err != nil {
+		return nil, err
+	}
+
 	return &meta, nil
 }
 
+// parseConsumerGroupMetadata parses the session, rebalance and membership settings of the consumer group,
+// which default to those of the Kafka client when not set
+func parseConsumerGroupMetadata(metadata pubsub.Metadata, meta *kafkaMetadata) error {
+	for name, d := range map[string]*time.Duration{
+		"sessionTimeout":    &meta.SessionTimeout,
+		"heartbeatInterval": &meta.HeartbeatInterval,
+		"maxPollInterval":   &meta.MaxPollInterval,
+	} {
+		val, ok := metadata.Properties[name]
+		if !ok || val == "" {
+			continue
+		}
+
+		var err error
+		*d, err = time.ParseDuration(val)
+		if err != nil || *d <= 0 {
+			return fmt.Errorf("kafka error: invalid value for '%s' attribute, expected a positive duration", name)
+		}
+	}
+
+	if meta.SessionTimeout != 0 && meta.HeartbeatInterval != 0 && meta.HeartbeatInterval >= meta.SessionTimeout {
+		return errors.New("kafka error: 'heartbeatInterval' must be lower than 'sessionTimeout'")
+	}
+
+	meta.RebalanceStrategy = strings.ToLower(metadata.Properties["rebalanceStrategy"])
+	switch meta.RebalanceStrategy {
+	case "", rebalanceStrategyRange, rebalanceStrategyRoundRobin, rebalanceStrategySticky:
+	default:
+		return fmt.Errorf("kafka error: invalid value for 'rebalanceStrategy' attribute, expected %s, %s or %s", rebalanceStrategyRange, rebalanceStrategyRoundRobin, rebalanceStrategySticky)
+	}
+
+	meta.GroupInstanceID = metadata.Properties["groupInstanceID"]
+
+	return nil
+}
+
+// parseProducerMetadata parses the compression and batching settings of the producer,
+// which send each record right away, uncompressed, when not set
+func parseProducerMetadata(metadata pubsub.Metadata, meta *kafkaMetadata) error {
+	if val, ok := metadata.Properties["producerCompression"]; ok && val != "" {
+		codec, ok := producerCompressionCodecs[strings.ToLower(val)]
+		if !ok {
+			return errors.New("kafka error: invalid value for 'producerCompression' attribute, expected none, gzip, snappy, lz4 or zstd")
+		}
+		meta.ProducerCompression = codec
+	}
+
+	// a batch size or an in flight request count of 0 would block the producer, whereas lingerMs 0 sends records right away
+	for name, n := range map[string]*int{
+		"batchSize":           &meta.BatchSize,
+		"maxInFlightRequests": &meta.MaxInFlightRequests,
+	} {
+		val, ok := metadata.Properties[name]
+		if !ok || val == "" {
+			continue
+		}
+
+		var err error
+		*n, err = strconv.Atoi(val)
+		if err != nil || *n <= 0 {
+			return fmt.Errorf("kafka error: invalid value for '%s' attribute, expected a positive integer", name)
+		}
+	}
+
+	if val, ok := metadata.Properties["lingerMs"]; ok && val != "" {
+		lingerMs, err := strconv.Atoi(val)
+		if err != nil || lingerMs < 0 {
+			return errors.New("kafka error: invalid value for 'lingerMs' attribute, expected a non-negative integer")
+		}
+		meta.Linger = time.Duration(lingerMs) * time.Millisecond
+	}
+
+	if meta.BatchSize > 0 && meta.Linger == 0 {
+		// the producer would otherwise wait for the batch to fill before sending the records of a publish
+		return errors.New("kafka error: 'batchSize' requires 'lingerMs'")
+	}
+
+	if meta.EnableIdempotence && meta.MaxInFlightRequests > 1 {
+		return errors.New("kafka error: 'enableIdempotence' and 'transactionalID' require 'maxInFlightRequests' to be 1")
+	}
+
+	return nil
+}
+
+// parseOIDCMetadata parses the OIDC client credentials of the OAUTHBEARER SASL mechanism
+func parseOIDCMetadata(metadata pubsub.Metadata, meta *kafkaMetadata) error {
+	if val, ok := metadata.Properties["oidcTokenEndpoint"]; ok && val != "" {
+		meta.OidcTokenEndpoint = val
+	} else {
+		return errors.New("kafka error: missing OIDC Token Endpoint")
+	}
+
+	if val, ok := metadata.Properties["oidcClientID"]; ok && val != "" {
+		meta.OidcClientID = val
+	} else {
+		return errors.New("kafka error: missing OIDC Client ID")
+	}
+
+	if val, ok := metadata.Properties["oidcClientSecret"]; ok && val != "" {
+		meta.OidcClientSecret = val
+	} else {
+		return errors.New("kafka error: missing OIDC Client Secret")
+	}
+
+	if val, ok := metadata.Properties["oidcScopes"]; ok && val != "" {
+		for _, scope := range strings.Split(val, ",") {
+			if scope = strings.TrimSpace(scope); scope != "" {
+				meta.OidcScopes = append(meta.OidcScopes, scope)
+			}
+		}
+	}
+
+	if val, ok := metadata.Properties["oidcExtensions"]; ok && val != "" {
+		if err := json.Unmarshal([]byte(val), &meta.OidcExtensions); err != nil {
+			return fmt.Errorf("kafka error: invalid value for 'oidcExtensions' attribute, expected a JSON object of strings: %s", err)
+		}
+	}
+
+	return nil
+}
+
+// newSerializer returns the serializer of the schema registry of the metadata, a Confluent compatible registry
+// or Azure Schema Registry, nil if there is none.
+// Azure Schema Registry is authorized with the credentials of the environment or the managed identity.
+func newSerializer(meta *kafkaMetadata) (*schemaregistry.Serializer, error) {
+	var client schemaregistry.Client
+	switch {
+	case meta.SchemaRegistryURL != "":
+		client = schemaregistry.NewConfluentClient(meta.SchemaRegistryURL, meta.SchemaRegistryAPIKey, meta.SchemaRegistryAPISecret)
+	case meta.SchemaRegistryNamespace != "":
+		authorizer, err := auth.NewAuthorizerFromEnvironmentWithResource(schemaregistry.AzureResource)
+		if err != nil {
+			return nil, fmt.Errorf("kafka error: cannot authorize with the schema registry: %s", err)
+		}
+		client = schemaregistry.NewAzureClient(meta.SchemaRegistryNamespace, meta.SchemaRegistryGroup, authorizer)
+	default:
+		return nil, nil
+	}
+
+	return schemaregistry.NewSerializer(schemaregistry.NewCachingClient(client, meta.SchemaCacheTTL)), nil
+}
+
 func (k *Kafka) getSyncProducer(meta *kafkaMetadata) (sarama.SyncProducer, error) {
+	producer, err := sarama.NewSyncProducer(meta.Brokers, k.getProducerConfig(meta))
+	if err != nil {
+		return nil, err
+	}
+
+	return producer, nil
+}
+
+func (k *Kafka) getProducerConfig(meta *kafkaMetadata) *sarama.Config {
 	config := sarama.NewConfig()
 	config.Producer.RequiredAcks = sarama.WaitForAll
 	config.Producer.Retry.Max = 5
 	config.Producer.Return.Successes = true
+	if meta.ContentMode == pubsub.ContentModeBinary {
+		// record headers require Kafka 0.11
+		config.Version = sarama.V2_0_0_0
+	}
+
+	if meta.EnableIdempotence {
+		// the broker deduplicates the retries of the producer, which requires Kafka 0.11
+		// and a single in flight request per broker so that records are not reordered
+		config.Version = sarama.V2_0_0_0
+		config.Producer.Idempotent = true
+		config.Net.MaxOpenRequests = 1
+	}
+	config.Producer.Transaction.ID = meta.TransactionalID
+
+	config.Producer.Compression = meta.ProducerCompression
+	config.Producer.Flush.Bytes = meta.BatchSize
+	config.Producer.Flush.Frequency = meta.Linger
+	if meta.MaxInFlightRequests > 0 {
+		config.Net.MaxOpenRequests = meta.MaxInFlightRequests
+	}
 
 	if k.authRequired {
-		updateAuthInfo(config, k.saslUsername, k.saslPassword)
+		k.updateAuthInfo(config)
 	}
 
-	producer, err := sarama.NewSyncProducer(meta.Brokers, config)
-	if err != nil {
-		return nil, err
+	if meta.ProducerCompression == sarama.CompressionZSTD {
+		// zstd record batches require Kafka 2.1
+		requireVersion(config, sarama.V2_1_0_0)
 	}
 
-	return producer, nil
+	return config
+}
+
+// requireVersion raises the Kafka version of the config to the given version, if lower
+func requireVersion(config *sarama.Config, version sarama.KafkaVersion) {
+	if !config.Version.IsAtLeast(version) {
+		config.Version = version
+	}
+}
+
+// updateConsumerGroupInfo applies the consumer group settings of the metadata to the consumer config
+func updateConsumerGroupInfo(config *sarama.Config, meta *kafkaMetadata) {
+	if meta.SessionTimeout != 0 {
+		config.Consumer.Group.Session.Timeout = meta.SessionTimeout
+		if meta.HeartbeatInterval == 0 && config.Consumer.Group.Heartbeat.Interval >= meta.SessionTimeout {
+			// heartbeats are usually sent a third of the session timeout
+			config.Consumer.Group.Heartbeat.Interval = meta.SessionTimeout / 3
+		}
+	}
+	if meta.HeartbeatInterval != 0 {
+		config.Consumer.Group.Heartbeat.Interval = meta.HeartbeatInterval
+	}
+	if meta.MaxPollInterval != 0 {
+		config.Consumer.Group.Rebalance.Timeout = meta.MaxPollInterval
+	}
+	if meta.InitialOffset == sarama.OffsetOldest {
+		config.Consumer.Offsets.Initial = sarama.OffsetOldest
+	}
+
+	switch meta.RebalanceStrategy {
+	case rebalanceStrategyRoundRobin:
+		config.Consumer.Group.Rebalance.Strategy = sarama.BalanceStrategyRoundRobin
+	case rebalanceStrategySticky:
+		config.Consumer.Group.Rebalance.Strategy = sarama.BalanceStrategySticky
+	case rebalanceStrategyRange:
+		config.Consumer.Group.Rebalance.Strategy = sarama.BalanceStrategyRange
+	}
+
+	if meta.GroupInstanceID != "" {
+		config.Consumer.Group.InstanceId = meta.GroupInstanceID
+		// static membership sends the JoinGroup v5 request of Kafka 2.3
+		requireVersion(config, sarama.V2_3_0_0)
+	}
 }
 
-func updateAuthInfo(config *sarama.Config, saslUsername, saslPassword string) {
+func (k *Kafka) updateAuthInfo(config *sarama.Config) {
 	config.Net.SASL.Enable = true
-	config.Net.SASL.User = saslUsername
-	config.Net.SASL.Password = saslPassword
-	config.Net.SASL.Mechanism = sarama.SASLTypePlaintext
+	if k.saslMechanism == saslMechanismOAuthBearer {
+		config.Net.SASL.Mechanism = sarama.SASLTypeOAuth
+		config.Net.SASL.TokenProvider = k.tokenProvider
+		// OAUTHBEARER requires the SASL handshake v1 of Kafka 1.0
+		config.Net.SASL.Version = sarama.SASLHandshakeV1
+		config.Version = sarama.V2_0_0_0
+	} else {
+		config.Net.SASL.User = k.saslUsername
+		config.Net.SASL.Password = k.saslPassword
+		config.Net.SASL.Mechanism = sarama.SASLTypePlaintext
+	}
 
 	config.Net.TLS.Enable = true
 	// nolint: gosec
@@ -6,9 +6,17 @@
 package kafka
 
 import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
 	"testing"
+	"time"
 
+	"github.com/Shopify/sarama"
+	"github.com/Shopify/sarama/mocks"
 	"github.com/dapr/components-contrib/pubsub"
+	"github.com/dapr/components-contrib/pubsub/schemaregistry"
 	"github.com/dapr/dapr/pkg/logger"
 	"github.com/stretchr/testify/assert"
 )
@@ -85,6 +93,458 @@ func TestPresentSaslValues(t *testing.T) {
 	assert.Equal(t, "sassapass", meta.SaslPassword)
 }
 
+func TestOIDCMetadata(t *testing.T) {
+	k := getKafkaPubsub()
+	properties := func() map[string]string {
+		return map[string]string{
+			"brokers":           "akfak.com:9092",
+			"authRequired":      "true",
+			"saslMechanism":     "oauthbearer",
+			"oidcTokenEndpoint": "https://login.example.com/token",
+			"oidcClientID":      "client",
+			"oidcClientSecret":  "secret",
+		}
+	}
+
+	t.Run("present values", func(t *testing.T) {
+		m := pubsub.Metadata{Properties: properties()}
+		m.Properties["oidcScopes"] = "kafka, api://cluster/.default"
+		m.Properties["oidcExtensions"] = `{"logicalCluster":"lkc-1","identityPoolId":"pool-1"}`
+		meta, err := k.getKafkaMetadata(m)
+		assert.NoError(t, err)
+		assert.Equal(t, saslMechanismOAuthBearer, meta.SaslMechanism)
+		assert.Equal(t, "https://login.example.com/token", meta.OidcTokenEndpoint)
+		assert.Equal(t, "client", meta.OidcClientID)
+		assert.Equal(t, "secret", meta.OidcClientSecret)
+		assert.Equal(t, []string{"kafka", "api://cluster/.default"}, meta.OidcScopes)
+		assert.Equal(t, map[string]string{"logicalCluster": "lkc-1", "identityPoolId": "pool-1"}, meta.OidcExtensions)
+		assert.Empty(t, meta.SaslUsername)
+	})
+
+	t.Run("missing values", func(t *testing.T) {
+		for key, message := range map[string]string{
+			"oidcTokenEndpoint": "kafka error: missing OIDC Token Endpoint",
+			"oidcClientID":      "kafka error: missing OIDC Client ID",
+			"oidcClientSecret":  "kafka error: missing OIDC Client Secret",
+		} {
+			m := pubsub.Metadata{Properties: properties()}
+			delete(m.Properties, key)
+			meta, err := k.getKafkaMetadata(m)
+			assert.Nil(t, meta)
+			assert.EqualError(t, err, message)
+		}
+	})
+
+	t.Run("invalid extensions", func(t *testing.T) {
+		m := pubsub.Metadata{Properties: properties()}
+		m.Properties["oidcExtensions"] = `["lkc-1"]`
+		_, err := k.getKafkaMetadata(m)
+		assert.Error(t, err)
+	})
+
+	t.Run("invalid mechanism", func(t *testing.T) {
+		m := pubsub.Metadata{Properties: properties()}
+		m.Properties["saslMechanism"] = "SCRAM-SHA-512"
+		_, err := k.getKafkaMetadata(m)
+		assert.Error(t, err)
+	})
+
+	t.Run("plain mechanism", func(t *testing.T) {
+		m := pubsub.Metadata{Properties: properties()}
+		m.Properties["saslMechanism"] = "plain"
+		_, err := k.getKafkaMetadata(m)
+		assert.EqualError(t, err, "kafka error: missing SASL Username")
+	})
+}
+
+func TestOIDCTokenProvider(t *testing.T) {
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		assert.NoError(t, r.ParseForm())
+		assert.Equal(t, "client_credentials", r.PostForm.Get("grant_type"))
+		assert.Equal(t, "kafka", r.PostForm.Get("scope"))
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"access_token":"t","token_type":"bearer","expires_in":3600}`))
+	}))
+	defer server.Close()
+
+	p := newOIDCTokenProvider(&kafkaMetadata{
+		OidcTokenEndpoint: server.URL,
+		OidcClientID:      "client",
+		OidcClientSecret:  "secret",
+		OidcScopes:        []string{"kafka"},
+		OidcExtensions:    map[string]string{"logicalCluster": "lkc-1"},
+	})
+
+	token, err := p.Token()
+	assert.NoError(t, err)
+	assert.Equal(t, "t", token.Token)
+	assert.Equal(t, map[string]string{"logicalCluster": "lkc-1"}, token.Extensions)
+
+	// the token is cached until it expires
+	_, err = p.Token()
+	assert.NoError(t, err)
+	assert.Equal(t, 1, requests)
+}
+
+func TestConsumerGroupMetadata(t *testing.T) {
+	k := getKafkaPubsub()
+	properties := func() map[string]string {
+		return map[string]string{"brokers": "akfak.com:9092", "authRequired": "false"}
+	}
+
+	t.Run("defaults", func(t *testing.T) {
+		meta, err := k.getKafkaMetadata(pubsub.Metadata{Properties: properties()})
+		assert.NoError(t, err)
+
+		config := sarama.NewConfig()
+		updateConsumerGroupInfo(config, meta)
+		assert.Equal(t, sarama.NewConfig().Consumer.Group, config.Consumer.Group)
+	})
+
+	t.Run("present values", func(t *testing.T) {
+		m := pubsub.Metadata{Properties: properties()}
+		m.Properties["sessionTimeout"] = "45s"
+		m.Properties["heartbeatInterval"] = "5s"
+		m.Properties["maxPollInterval"] = "5m"
+		m.Properties["rebalanceStrategy"] = "Sticky"
+		meta, err := k.getKafkaMetadata(m)
+		assert.NoError(t, err)
+
+		config := sarama.NewConfig()
+		updateConsumerGroupInfo(config, meta)
+		assert.Equal(t, 45*time.Second, config.Consumer.Group.Session.Timeout)
+		assert.Equal(t, 5*time.Second, config.Consumer.Group.Heartbeat.Interval)
+		assert.Equal(t, 5*time.Minute, config.Consumer.Group.Rebalance.Timeout)
+		assert.Equal(t, sarama.BalanceStrategySticky, config.Consumer.Group.Rebalance.Strategy)
+	})
+
+	t.Run("heartbeat follows a short session timeout", func(t *testing.T) {
+		m := pubsub.Metadata{Properties: properties()}
+		m.Properties["sessionTimeout"] = "3s"
+		meta, err := k.getKafkaMetadata(m)
+		assert.NoError(t, err)
+
+		config := sarama.NewConfig()
+		updateConsumerGroupInfo(config, meta)
+		assert.Equal(t, time.Second, config.Consumer.Group.Heartbeat.Interval)
+		assert.NoError(t, config.Validate())
+	})
+
+	t.Run("invalid values", func(t *testing.T) {
+		for key, val := range map[string]string{
+			"sessionTimeout":    "soon",
+			"maxPollInterval":   "-1m",
+			"rebalanceStrategy": "random",
+		} {
+			m := pubsub.Metadata{Properties: properties()}
+			m.Properties[key] = val
+			_, err := k.getKafkaMetadata(m)
+			assert.Error(t, err, key)
+		}
+
+		m := pubsub.Metadata{Properties: properties()}
+		m.Properties["sessionTimeout"] = "5s"
+		m.Properties["heartbeatInterval"] = "10s"
+		_, err := k.getKafkaMetadata(m)
+		assert.Error(t, err)
+	})
+
+	t.Run("cooperative sticky is not supported", func(t *testing.T) {
+		m := pubsub.Metadata{Properties: properties()}
+		m.Properties["rebalanceStrategy"] = "cooperative-sticky"
+		_, err := k.getKafkaMetadata(m)
+		assert.Error(t, err)
+	})
+
+	t.Run("static membership", func(t *testing.T) {
+		m := pubsub.Metadata{Properties: properties()}
+		m.Properties["groupInstanceID"] = "consumer-1"
+		meta, err := k.getKafkaMetadata(m)
+		assert.NoError(t, err)
+
+		config := sarama.NewConfig()
+		updateConsumerGroupInfo(config, meta)
+		assert.Equal(t, "consumer-1", config.Consumer.Group.InstanceId)
+		assert.True(t, config.Version.IsAtLeast(sarama.V2_3_0_0))
+		assert.NoError(t, config.Validate())
+	})
+}
+
+func TestIdempotentProducer(t *testing.T) {
+	k := getKafkaPubsub()
+	properties := func() map[string]string {
+		return map[string]string{"brokers": "akfak.com:9092", "authRequired": "false"}
+	}
+
+	t.Run("default", func(t *testing.T) {
+		meta, err := k.getKafkaMetadata(pubsub.Metadata{Properties: properties()})
+		assert.NoError(t, err)
+		assert.False(t, meta.EnableIdempotence)
+		assert.False(t, k.getProducerConfig(meta).Producer.Idempotent)
+	})
+
+	t.Run("enabled", func(t *testing.T) {
+		m := pubsub.Metadata{Properties: properties()}
+		m.Properties["enableIdempotence"] = "true"
+		meta, err := k.getKafkaMetadata(m)
+		assert.NoError(t, err)
+
+		config := k.getProducerConfig(meta)
+		assert.True(t, config.Producer.Idempotent)
+		assert.NoError(t, config.Validate())
+	})
+
+	t.Run("invalid value", func(t *testing.T) {
+		m := pubsub.Metadata{Properties: properties()}
+		m.Properties["enableIdempotence"] = "maybe"
+		_, err := k.getKafkaMetadata(m)
+		assert.Error(t, err)
+	})
+
+	t.Run("transactional", func(t *testing.T) {
+		m := pubsub.Metadata{Properties: properties()}
+		m.Properties["transactionalID"] = "orders"
+		meta, err := k.getKafkaMetadata(m)
+		assert.NoError(t, err)
+		assert.True(t, meta.EnableIdempotence)
+
+		config := k.getProducerConfig(meta)
+		assert.Equal(t, "orders", config.Producer.Transaction.ID)
+		assert.True(t, config.Producer.Idempotent)
+		assert.NoError(t, config.Validate())
+	})
+
+	t.Run("transactional with many in flight requests", func(t *testing.T) {
+		m := pubsub.Metadata{Properties: properties()}
+		m.Properties["transactionalID"] = "orders"
+		m.Properties["maxInFlightRequests"] = "5"
+		_, err := k.getKafkaMetadata(m)
+		assert.Error(t, err)
+	})
+}
+
+// txnProducer records the transactions of a transactional mock producer
+type txnProducer struct {
+	*mocks.SyncProducer
+	calls []string
+}
+
+func (p *txnProducer) BeginTxn() error {
+	p.calls = append(p.calls, "begin")
+
+	return p.SyncProducer.BeginTxn()
+}
+
+func (p *txnProducer) CommitTxn() error {
+	p.calls = append(p.calls, "commit")
+
+	return p.SyncProducer.CommitTxn()
+}
+
+func (p *txnProducer) AbortTxn() error {
+	p.calls = append(p.calls, "abort")
+
+	return p.SyncProducer.AbortTxn()
+}
+
+func TestTransactionalPublish(t *testing.T) {
+	newKafka := func(t *testing.T) (*Kafka, *txnProducer) {
+		k := getKafkaPubsub()
+		meta, err := k.getKafkaMetadata(pubsub.Metadata{Properties: map[string]string{
+			"brokers": "akfak.com:9092", "authRequired": "false", "transactionalID": "orders",
+		}})
+		assert.NoError(t, err)
+
+		producer := &txnProducer{SyncProducer: mocks.NewSyncProducer(t, k.getProducerConfig(meta))}
+		k.producer = producer
+
+		return k, producer
+	}
+	req := &pubsub.BulkPublishRequest{
+		Topic: "orders",
+		Entries: []pubsub.BulkMessageEntry{
+			{EntryID: "1", Event: []byte("a")},
+			{EntryID: "2", Event: []byte("b")},
+		},
+	}
+
+	t.Run("bulk publish commits the entries", func(t *testing.T) {
+		k, producer := newKafka(t)
+		producer.ExpectSendMessageAndSucceed()
+		producer.ExpectSendMessageAndSucceed()
+
+		res, err := k.BulkPublish(req)
+		assert.NoError(t, err)
+		assert.Empty(t, res.FailedEntries)
+		assert.Equal(t, []string{"begin", "commit"}, producer.calls)
+	})
+
+	t.Run("a failed entry aborts the transaction", func(t *testing.T) {
+		k, producer := newKafka(t)
+		producer.ExpectSendMessageAndSucceed()
+		producer.ExpectSendMessageAndFail(sarama.ErrNotEnoughReplicas)
+
+		res, err := k.BulkPublish(req)
+		assert.Error(t, err)
+		assert.Len(t, res.FailedEntries, 2)
+		assert.Equal(t, []string{"begin", "abort"}, producer.calls)
+	})
+
+	t.Run("publish", func(t *testing.T) {
+		k, producer := newKafka(t)
+		producer.ExpectSendMessageAndSucceed()
+
+		err := k.Publish(&pubsub.PublishRequest{Topic: "orders", Data: []byte("a")})
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"begin", "commit"}, producer.calls)
+	})
+}
+
+func TestProducerMetadata(t *testing.T) {
+	k := getKafkaPubsub()
+	properties := func() map[string]string {
+		return map[string]string{"brokers": "akfak.com:9092", "authRequired": "false"}
+	}
+
+	t.Run("default", func(t *testing.T) {
+		meta, err := k.getKafkaMetadata(pubsub.Metadata{Properties: properties()})
+		assert.NoError(t, err)
+
+		config := k.getProducerConfig(meta)
+		assert.Equal(t, sarama.CompressionNone, config.Producer.Compression)
+		assert.Equal(t, 0, config.Producer.Flush.Bytes)
+		assert.Equal(t, time.Duration(0), config.Producer.Flush.Frequency)
+		assert.Equal(t, sarama.NewConfig().Net.MaxOpenRequests, config.Net.MaxOpenRequests)
+	})
+
+	t.Run("compression and batching", func(t *testing.T) {
+		m := pubsub.Metadata{Properties: properties()}
+		m.Properties["producerCompression"] = "lz4"
+		m.Properties["batchSize"] = "65536"
+		m.Properties["lingerMs"] = "20"
+		m.Properties["maxInFlightRequests"] = "10"
+		meta, err := k.getKafkaMetadata(m)
+		assert.NoError(t, err)
+
+		config := k.getProducerConfig(meta)
+		assert.Equal(t, sarama.CompressionLZ4, config.Producer.Compression)
+		assert.Equal(t, 65536, config.Producer.Flush.Bytes)
+		assert.Equal(t, 20*time.Millisecond, config.Producer.Flush.Frequency)
+		assert.Equal(t, 10, config.Net.MaxOpenRequests)
+		assert.NoError(t, config.Validate())
+	})
+
+	t.Run("zstd requires Kafka 2.1", func(t *testing.T) {
+		m := pubsub.Metadata{Properties: properties()}
+		m.Properties["producerCompression"] = "zstd"
+		meta, err := k.getKafkaMetadata(m)
+		assert.NoError(t, err)
+
+		config := k.getProducerConfig(meta)
+		assert.Equal(t, sarama.CompressionZSTD, config.Producer.Compression)
+		assert.True(t, config.Version.IsAtLeast(sarama.V2_1_0_0))
+		assert.NoError(t, config.Validate())
+	})
+
+	t.Run("invalid values", func(t *testing.T) {
+		for _, property := range []struct{ name, val string }{
+			{"producerCompression", "brotli"},
+			{"batchSize", "-1"},
+			{"batchSize", "0"},
+			{"lingerMs", "soon"},
+			{"lingerMs", "-1"},
+			{"maxInFlightRequests", "many"},
+			{"maxInFlightRequests", "-1"},
+			{"maxInFlightRequests", "0"},
+		} {
+			m := pubsub.Metadata{Properties: properties()}
+			m.Properties[property.name] = property.val
+			_, err := k.getKafkaMetadata(m)
+			assert.Error(t, err, property.name+"="+property.val)
+		}
+	})
+
+	t.Run("no linger", func(t *testing.T) {
+		m := pubsub.Metadata{Properties: properties()}
+		m.Properties["lingerMs"] = "0"
+		meta, err := k.getKafkaMetadata(m)
+		assert.NoError(t, err)
+		assert.Equal(t, time.Duration(0), meta.Linger)
+	})
+
+	t.Run("batch size without linger", func(t *testing.T) {
+		m := pubsub.Metadata{Properties: properties()}
+		m.Properties["batchSize"] = "65536"
+		_, err := k.getKafkaMetadata(m)
+		assert.Error(t, err)
+	})
+
+	t.Run("idempotence with many in flight requests", func(t *testing.T) {
+		m := pubsub.Metadata{Properties: properties()}
+		m.Properties["enableIdempotence"] = "true"
+		m.Properties["maxInFlightRequests"] = "5"
+		_, err := k.getKafkaMetadata(m)
+		assert.Error(t, err)
+	})
+}
+
+func TestInitialOffset(t *testing.T) {
+	t.Run("parse", func(t *testing.T) {
+		for val, expected := range map[string]int64{
+			"earliest":             sarama.OffsetOldest,
+			"Oldest":               sarama.OffsetOldest,
+			"latest":               sarama.OffsetNewest,
+			"newest":               sarama.OffsetNewest,
+			"2021-03-04T05:06:07Z": time.Date(2021, 3, 4, 5, 6, 7, 0, time.UTC).UnixNano() / int64(time.Millisecond),
+		} {
+			offset, err := parseInitialOffset(val)
+			assert.NoError(t, err, val)
+			assert.Equal(t, expected, offset, val)
+		}
+
+		_, err := parseInitialOffset("yesterday")
+		assert.Error(t, err)
+	})
+
+	t.Run("component metadata", func(t *testing.T) {
+		k := getKafkaPubsub()
+		m := pubsub.Metadata{Properties: map[string]string{"brokers": "akfak.com:9092", "authRequired": "false"}}
+		meta, err := k.getKafkaMetadata(m)
+		assert.NoError(t, err)
+		assert.Equal(t, sarama.OffsetNewest, meta.InitialOffset)
+
+		m.Properties["initialOffset"] = "earliest"
+		meta, err = k.getKafkaMetadata(m)
+		assert.NoError(t, err)
+
+		config := sarama.NewConfig()
+		updateConsumerGroupInfo(config, meta)
+		assert.Equal(t, sarama.OffsetOldest, config.Consumer.Offsets.Initial)
+
+		m.Properties["initialOffset"] = "soon"
+		_, err = k.getKafkaMetadata(m)
+		assert.Error(t, err)
+	})
+
+	t.Run("subscription metadata", func(t *testing.T) {
+		k := getKafkaPubsub()
+		k.initialOffset = sarama.OffsetOldest
+
+		offset, err := k.parseInitialOffset(map[string]string{})
+		assert.NoError(t, err)
+		assert.Equal(t, sarama.OffsetOldest, offset)
+
+		offset, err = k.parseInitialOffset(map[string]string{"initialOffset": "latest"})
+		assert.NoError(t, err)
+		assert.Equal(t, sarama.OffsetNewest, offset)
+
+		_, err = k.parseInitialOffset(map[string]string{"initialOffset": "soon"})
+		assert.Error(t, err)
+	})
+}
+
 func TestInvalidAuthRequiredFlag(t *testing.T) {
 	m := pubsub.Metadata{}
 	m.Properties = map[string]string{"brokers": "akfak.com:9092", "authRequired": "maybe?????????????"}
@@ -95,3 +555,425 @@ func TestInvalidAuthRequiredFlag(t *testing.T) {
 
 	assert.Equal(t, "kafka error: invalid value for 'authRequired' attribute", err.Error())
 }
+
+func TestContentMode(t *testing.T) {
+	t.Run("structured by default", func(t *testing.T) {
+		m := pubsub.Metadata{}
+		m.Properties = map[string]string{"brokers": "a", "authRequired": "false"}
+		k := getKafkaPubsub()
+		meta, err := k.getKafkaMetadata(m)
+		assert.NoError(t, err)
+		assert.Equal(t, pubsub.ContentModeStructured, meta.ContentMode)
+	})
+
+	t.Run("binary", func(t *testing.T) {
+		m := pubsub.Metadata{}
+		m.Properties = map[string]string{"brokers": "a", "authRequired": "false", "contentMode": "binary"}
+		k := getKafkaPubsub()
+		meta, err := k.getKafkaMetadata(m)
+		assert.NoError(t, err)
+		assert.Equal(t, pubsub.ContentModeBinary, meta.ContentMode)
+	})
+
+	t.Run("invalid", func(t *testing.T) {
+		m := pubsub.Metadata{}
+		m.Properties = map[string]string{"brokers": "a", "authRequired": "false", "contentMode": "a"}
+		k := getKafkaPubsub()
+		meta, err := k.getKafkaMetadata(m)
+		assert.Error(t, err)
+		assert.Nil(t, meta)
+	})
+}
+
+func TestNewMessage(t *testing.T) {
+	t.Run("binary cloud event", func(t *testing.T) {
+		msg, err := newMessage("topic", &sarama.ConsumerMessage{
+			Headers: []*sarama.RecordHeader{
+				{Key: []byte("ce_specversion"), Value: []byte("1.0")},
+				{Key: []byte("ce_id"), Value: []byte("a")},
+				{Key: []byte("content-type"), Value: []byte("text/plain")},
+				{Key: []byte("op"), Value: []byte("c")},
+			},
+			Value: []byte("text"),
+		}, false, 0)
+		assert.NoError(t, err)
+		assert.Equal(t, "topic", msg.Topic)
+		assert.Equal(t, map[string]string{"header.op": "c"}, msg.Metadata)
+
+		e, err := pubsub.FromCloudEvent(msg.Data, "")
+		assert.NoError(t, err)
+		assert.Equal(t, "a", e[pubsub.IDField])
+		assert.Equal(t, "text/plain", e[pubsub.DataContentTypeField])
+		assert.Equal(t, "text", e[pubsub.DataField])
+	})
+
+	t.Run("structured cloud event", func(t *testing.T) {
+		msg, err := newMessage("topic", &sarama.ConsumerMessage{Value: []byte(`{"specversion":"1.0"}`)}, false, 0)
+		assert.NoError(t, err)
+		assert.Equal(t, `{"specversion":"1.0"}`, string(msg.Data))
+		assert.Nil(t, msg.Metadata)
+	})
+
+	t.Run("raw payload", func(t *testing.T) {
+		msg, err := newMessage("topic", &sarama.ConsumerMessage{
+			Headers: []*sarama.RecordHeader{
+				{Key: []byte("ce_specversion"), Value: []byte("1.0")},
+			},
+			Value: []byte("text"),
+		}, true, 0)
+		assert.NoError(t, err)
+		assert.Equal(t, "text", string(msg.Data))
+		assert.Equal(t, map[string]string{"header.ce_specversion": "1.0"}, msg.Metadata)
+	})
+
+	t.Run("too many extensions", func(t *testing.T) {
+		_, err := newMessage("topic", &sarama.ConsumerMessage{
+			Headers: []*sarama.RecordHeader{
+				{Key: []byte("ce_specversion"), Value: []byte("1.0")},
+				{Key: []byte("ce_tenantid"), Value: []byte("t1")},
+				{Key: []byte("ce_correlationid"), Value: []byte("c1")},
+			},
+			Value: []byte("text"),
+		}, false, 1)
+		assert.Error(t, err)
+		assert.Contains(t, err.Error(), pubsub.ErrTooManyExtensions.Error())
+	})
+}
+
+func TestAddHeaders(t *testing.T) {
+	msg := &sarama.ProducerMessage{}
+	addHeaders(msg, map[string]string{
+		"header.op":       "c",
+		"header.schemaId": "1",
+		"header.":         "empty",
+		"rawPayload":      "true",
+	})
+
+	headers := make(map[string]string, len(msg.Headers))
+	for _, h := range msg.Headers {
+		headers[string(h.Key)] = string(h.Value)
+	}
+	assert.Equal(t, map[string]string{"op": "c", "schemaId": "1"}, headers)
+}
+
+func TestNewProducerMessage(t *testing.T) {
+	t.Run("structured", func(t *testing.T) {
+		k := getKafkaPubsub()
+		msg, err := k.newProducerMessage("topic", []byte("data"), false, schemaregistry.None)
+		assert.NoError(t, err)
+		assert.Equal(t, "topic", msg.Topic)
+		assert.Equal(t, sarama.ByteEncoder("data"), msg.Value)
+		assert.Empty(t, msg.Headers)
+	})
+
+	t.Run("binary", func(t *testing.T) {
+		k := getKafkaPubsub()
+		k.contentMode = pubsub.ContentModeBinary
+		msg, err := k.newProducerMessage("topic", []byte(`{"specversion":"1.0","id":"a","datacontenttype":"text/plain","data":"text"}`), false, schemaregistry.None)
+		assert.NoError(t, err)
+		assert.Equal(t, sarama.ByteEncoder("text"), msg.Value)
+		assert.Contains(t, msg.Headers, sarama.RecordHeader{Key: []byte("ce_id"), Value: []byte("a")})
+		assert.Contains(t, msg.Headers, sarama.RecordHeader{Key: []byte("content-type"), Value: []byte("text/plain")})
+
+		_, err = k.newProducerMessage("topic", []byte("data"), false, schemaregistry.None)
+		assert.Error(t, err)
+	})
+
+	t.Run("binary raw payload", func(t *testing.T) {
+		k := getKafkaPubsub()
+		k.contentMode = pubsub.ContentModeBinary
+		msg, err := k.newProducerMessage("topic", []byte("data"), true, schemaregistry.None)
+		assert.NoError(t, err)
+		assert.Equal(t, sarama.ByteEncoder("data"), msg.Value)
+		assert.Empty(t, msg.Headers)
+	})
+}
+
+func TestSchemaRegistryMetadata(t *testing.T) {
+	t.Run("no schema registry", func(t *testing.T) {
+		m := pubsub.Metadata{}
+		m.Properties = map[string]string{"brokers": "a", "authRequired": "false"}
+		k := getKafkaPubsub()
+		meta, err := k.getKafkaMetadata(m)
+		assert.NoError(t, err)
+
+		serializer, err := newSerializer(meta)
+		assert.NoError(t, err)
+		assert.Nil(t, serializer)
+	})
+
+	t.Run("confluent", func(t *testing.T) {
+		m := pubsub.Metadata{}
+		m.Properties = map[string]string{"brokers": "a", "authRequired": "false", "schemaRegistryURL": "http://localhost:8081", "schemaCacheTTL": "1m"}
+		k := getKafkaPubsub()
+		meta, err := k.getKafkaMetadata(m)
+		assert.NoError(t, err)
+		assert.Equal(t, "http://localhost:8081", meta.SchemaRegistryURL)
+		assert.Equal(t, time.Minute, meta.SchemaCacheTTL)
+
+		serializer, err := newSerializer(meta)
+		assert.NoError(t, err)
+		assert.NotNil(t, serializer)
+	})
+
+	t.Run("invalid", func(t *testing.T) {
+		for _, properties := range []map[string]string{
+			{"schemaRegistryURL": "http://localhost:8081", "schemaRegistryNamespace": "a.servicebus.windows.net", "schemaRegistryGroup": "a"},
+			{"schemaRegistryNamespace": "a.servicebus.windows.net"},
+			{"schemaRegistryURL": "http://localhost:8081", "schemaCacheTTL": "a"},
+		} {
+			properties["brokers"] = "a"
+			properties["authRequired"] = "false"
+			m := pubsub.Metadata{}
+			m.Properties = properties
+			k := getKafkaPubsub()
+			meta, err := k.getKafkaMetadata(m)
+			assert.Error(t, err)
+			assert.Nil(t, meta)
+		}
+	})
+}
+
+func TestSchemaRegistrySerialization(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/subjects/topic-value/versions/latest":
+			w.Write([]byte(`{"id":1,"schema":"{\"type\":\"string\"}"}`))
+		case "/schemas/ids/1":
+			w.Write([]byte(`{"schema":"{\"type\":\"string\"}"}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	k := getKafkaPubsub()
+	k.serializer = schemaregistry.NewSerializer(schemaregistry.NewConfluentClient(server.URL, "", ""))
+	avro := map[string]string{"valueSchemaType": "Avro"}
+
+	t.Run("raw payload", func(t *testing.T) {
+		schemaType, err := k.parseSchemaType(avro)
+		assert.NoError(t, err)
+
+		msg, err := k.newProducerMessage("topic", []byte(`"text"`), true, schemaType)
+		assert.NoError(t, err)
+		assert.Equal(t, sarama.ByteEncoder{0, 0, 0, 0, 1, 8, 't', 'e', 'x', 't'}, msg.Value)
+
+		c := consumer{
+			rawPayload:      map[string]bool{"topic": true},
+			valueSchemaType: map[string]schemaregistry.SchemaType{"topic": schemaType},
+			serializer:      k.serializer,
+		}
+		read, err := c.readMessage("topic", &sarama.ConsumerMessage{Value: msg.Value.(sarama.ByteEncoder)})
+		assert.NoError(t, err)
+		assert.Equal(t, `"text"`, string(read.Data))
+
+		_, err = c.readMessage("topic", &sarama.ConsumerMessage{Value: []byte(`"text"`)})
+		assert.Error(t, err)
+	})
+
+	t.Run("binary", func(t *testing.T) {
+		k.contentMode = pubsub.ContentModeBinary
+		defer func() { k.contentMode = "" }()
+
+		// the data is the JSON text of the Avro string
+		msg, err := k.newProducerMessage("topic", []byte(`{"specversion":"1.0","id":"a","datacontenttype":"application/json","data":"\"text\""}`), false, schemaregistry.Avro)
+		assert.NoError(t, err)
+		assert.Equal(t, sarama.ByteEncoder{0, 0, 0, 0, 1, 8, 't', 'e', 'x', 't'}, msg.Value)
+	})
+
+	t.Run("structured", func(t *testing.T) {
+		_, err := k.newProducerMessage("topic", []byte(`{"specversion":"1.0","id":"a","data":"text"}`), false, schemaregistry.Avro)
+		assert.Error(t, err)
+	})
+
+	t.Run("no schema registry", func(t *testing.T) {
+		_, err := getKafkaPubsub().parseSchemaType(avro)
+		assert.Error(t, err)
+	})
+}
+
+func TestCompression(t *testing.T) {
+	t.Run("metadata", func(t *testing.T) {
+		m := pubsub.Metadata{}
+		m.Properties = map[string]string{"brokers": "a", "authRequired": "false", "compression": "gzip"}
+		k := getKafkaPubsub()
+		meta, err := k.getKafkaMetadata(m)
+		assert.NoError(t, err)
+		assert.Equal(t, pubsub.CompressionGzip, meta.Compression)
+
+		m.Properties["compression"] = "a"
+		meta, err = k.getKafkaMetadata(m)
+		assert.Error(t, err)
+		assert.Nil(t, meta)
+	})
+
+	t.Run("publish metadata", func(t *testing.T) {
+		k := getKafkaPubsub()
+		k.compression = pubsub.CompressionGzip
+
+		compression, err := k.parseCompression(map[string]string{}, false, schemaregistry.None)
+		assert.NoError(t, err)
+		assert.Equal(t, pubsub.CompressionGzip, compression)
+
+		compression, err = k.parseCompression(map[string]string{"compression": "snappy"}, false, schemaregistry.None)
+		assert.NoError(t, err)
+		assert.Equal(t, pubsub.CompressionSnappy, compression)
+
+		compression, err = k.parseCompression(map[string]string{}, true, schemaregistry.None)
+		assert.NoError(t, err)
+		assert.Equal(t, pubsub.CompressionNone, compression)
+
+		_, err = k.parseCompression(map[string]string{}, false, schemaregistry.Avro)
+		assert.Error(t, err)
+	})
+
+	t.Run("compressed records are decompressed", func(t *testing.T) {
+		event := `{"specversion":"1.0","id":"a","traceid":"","datacontenttype":"text/plain","data":"text"}`
+		compressed, err := pubsub.CompressCloudEvent([]byte(event), pubsub.CompressionZstd)
+		assert.NoError(t, err)
+
+		msg, err := newMessage("topic", &sarama.ConsumerMessage{Value: compressed}, false, 0)
+		assert.NoError(t, err)
+		assert.JSONEq(t, event, string(msg.Data))
+	})
+}
+
+func TestPartitionKey(t *testing.T) {
+	event := []byte(`{"specversion":"1.0","id":"a","datacontenttype":"application/json","data":{"customer":"c1"}}`)
+
+	t.Run("metadata", func(t *testing.T) {
+		m := pubsub.Metadata{}
+		m.Properties = map[string]string{"brokers": "a", "authRequired": "false", "partitionKeyPath": "$.customer"}
+		k := getKafkaPubsub()
+		meta, err := k.getKafkaMetadata(m)
+		assert.NoError(t, err)
+		k.partitionKeyPath = meta.PartitionKeyPath
+
+		key, err := k.partitionKey(event, map[string]string{}, false)
+		assert.NoError(t, err)
+		assert.Equal(t, "c1", key)
+
+		m.Properties["partitionKeyPath"] = "$.["
+		meta, err = k.getKafkaMetadata(m)
+		assert.Error(t, err)
+		assert.Nil(t, meta)
+	})
+
+	t.Run("raw payloads are keyed by the metadata", func(t *testing.T) {
+		k := getKafkaPubsub()
+		k.partitionKeyPath, _ = pubsub.ParseKeyPath("$.customer")
+
+		key, err := k.partitionKey([]byte("text"), map[string]string{}, true)
+		assert.NoError(t, err)
+		assert.Empty(t, key)
+
+		key, err = k.partitionKey([]byte("text"), map[string]string{"partitionKey": "p"}, true)
+		assert.NoError(t, err)
+		assert.Equal(t, "p", key)
+	})
+}
+
+func TestDeadLetterMetadata(t *testing.T) {
+	m := pubsub.Metadata{}
+	m.Properties = map[string]string{"brokers": "a", "authRequired": "false", "deadLetterTopic": "poison", "maxDeliveryAttempts": "5"}
+	k := getKafkaPubsub()
+	meta, err := k.getKafkaMetadata(m)
+	assert.NoError(t, err)
+	assert.Equal(t, pubsub.DeadLetterConfig{Topic: "poison", MaxDeliveryAttempts: 5}, meta.DeadLetter)
+
+	m.Properties["maxDeliveryAttempts"] = "a"
+	meta, err = k.getKafkaMetadata(m)
+	assert.Error(t, err)
+	assert.Nil(t, meta)
+}
+
+func TestConsumerDeliver(t *testing.T) {
+	failing := func(msg *pubsub.NewMessage) error {
+		return errors.New("failed")
+	}
+
+	t.Run("dead letter after max delivery attempts", func(t *testing.T) {
+		producer := mocks.NewSyncProducer(t, nil)
+		producer.ExpectSendMessageWithCheckerFunctionAndSucceed(func(val []byte) error {
+			if string(val) != "text" {
+				return fmt.Errorf("unexpected value %s", val)
+			}
+
+			return nil
+		})
+		k := getKafkaPubsub()
+		k.producer = producer
+
+		attempts := 0
+		c := consumer{
+			callback: func(msg *pubsub.NewMessage) error {
+				attempts++
+
+				return failing(msg)
+			},
+			deadLetter: pubsub.DeadLetterConfig{Topic: "poison", MaxDeliveryAttempts: 2},
+			publisher:  k,
+		}
+
+		err := c.deliver("topic", &sarama.ConsumerMessage{Value: []byte("text")})
+		assert.NoError(t, err)
+		assert.Equal(t, 2, attempts)
+		assert.NoError(t, producer.Close())
+	})
+
+	t.Run("no dead letter topic", func(t *testing.T) {
+		c := consumer{callback: failing}
+
+		err := c.deliver("topic", &sarama.ConsumerMessage{Value: []byte("text")})
+		assert.Error(t, err)
+	})
+}
+
+type fakeConsumerGroupSession struct {
+	sarama.ConsumerGroupSession
+	marked []int64
+}
+
+func (s *fakeConsumerGroupSession) MarkMessage(msg *sarama.ConsumerMessage, metadata string) {
+	s.marked = append(s.marked, msg.Offset)
+}
+
+func TestDeliverBulk(t *testing.T) {
+	messages := []*sarama.ConsumerMessage{
+		{Offset: 1, Value: []byte("a")},
+		{Offset: 2, Value: []byte("b")},
+		{Offset: 3, Value: []byte("c")},
+	}
+
+	t.Run("entries that did not fail are marked", func(t *testing.T) {
+		session := &fakeConsumerGroupSession{}
+		c := consumer{
+			bulkCallback: func(msg *pubsub.BulkMessage) (pubsub.BulkSubscribeResponse, error) {
+				assert.Equal(t, "topic", msg.Topic)
+				assert.Equal(t, []pubsub.BulkMessageEntry{
+					{EntryID: "1", Event: []byte("a")},
+					{EntryID: "2", Event: []byte("b")},
+					{EntryID: "3", Event: []byte("c")},
+				}, msg.Entries)
+
+				return pubsub.BulkSubscribeResponse{FailedEntries: []pubsub.BulkSubscribeFailedEntry{{EntryID: "2", Error: errors.New("failed")}}}, nil
+			},
+		}
+
+		c.deliverBulk(session, "topic", messages)
+		assert.Equal(t, []int64{1, 3}, session.marked)
+	})
+
+	t.Run("no entry is marked when the handler fails", func(t *testing.T) {
+		session := &fakeConsumerGroupSession{}
+		c := consumer{
+			bulkCallback: func(msg *pubsub.BulkMessage) (pubsub.BulkSubscribeResponse, error) {
+				return pubsub.BulkSubscribeResponse{}, errors.New("failed")
+			},
+		}
+
+		c.deliverBulk(session, "topic", messages)
+		assert.Empty(t, session.marked)
+	})
+}
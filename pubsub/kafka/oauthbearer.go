@@ -0,0 +1,50 @@
+// ------------------------------------------------------------
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT License.
+// ------------------------------------------------------------
+
+package kafka
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/Shopify/sarama"
+	"golang.org/x/oauth2"
+	"golang.org/x/oauth2/clientcredentials"
+)
+
+// oidcTokenProvider provides the SASL OAUTHBEARER tokens retrieved from an OIDC provider
+// with the client credentials flow. Tokens are cached until they are about to expire, then retrieved again.
+type oidcTokenProvider struct {
+	tokenSource oauth2.TokenSource
+	// extensions are the SASL extensions sent with the token, e.g. the logical cluster and identity pool of Confluent Cloud
+	extensions map[string]string
+}
+
+func newOIDCTokenProvider(meta *kafkaMetadata) *oidcTokenProvider {
+	config := clientcredentials.Config{
+		ClientID:     meta.OidcClientID,
+		ClientSecret: meta.OidcClientSecret,
+		TokenURL:     meta.OidcTokenEndpoint,
+		Scopes:       meta.OidcScopes,
+	}
+
+	return &oidcTokenProvider{
+		tokenSource: config.TokenSource(context.Background()),
+		extensions:  meta.OidcExtensions,
+	}
+}
+
+// Token returns the current token, retrieving a new one when it is expired.
+func (p *oidcTokenProvider) Token() (*sarama.AccessToken, error) {
+	token, err := p.tokenSource.Token()
+	if err != nil {
+		return nil, fmt.Errorf("kafka error: cannot get OAuth token: %s", err)
+	}
+
+	return &sarama.AccessToken{
+		Token:      token.AccessToken,
+		Extensions: p.extensions,
+	}, nil
+}
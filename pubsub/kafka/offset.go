@@ -0,0 +1,120 @@
+// ------------------------------------------------------------
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT License.
+// ------------------------------------------------------------
+
+package kafka
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/Shopify/sarama"
+)
+
+// initialOffsetMetadataKey is the component and subscription metadata key of the offset
+// the consumer group starts consuming a topic from when it has not consumed it yet
+const initialOffsetMetadataKey = "initialOffset"
+
+// parseInitialOffset parses an initial offset: earliest (or oldest) for the oldest records, latest (or newest)
+// for the records published from now on, or a RFC 3339 time for the first records published at or after it.
+// It returns sarama.OffsetOldest, sarama.OffsetNewest or the time in milliseconds, as the offset requests expect it.
+func parseInitialOffset(val string) (int64, error) {
+	switch strings.ToLower(val) {
+	case "earliest", "oldest":
+		return sarama.OffsetOldest, nil
+	case "latest", "newest":
+		return sarama.OffsetNewest, nil
+	}
+
+	t, err := time.Parse(time.RFC3339, val)
+	if err != nil {
+		return 0, fmt.Errorf("invalid value for '%s' attribute, expected earliest, latest or a RFC 3339 time", initialOffsetMetadataKey)
+	}
+
+	return t.UnixNano() / int64(time.Millisecond), nil
+}
+
+// SeekConsumerGroup moves the offsets of the consumer group on all the partitions of the topic to the given offset:
+// earliest, latest or a RFC 3339 time, so that the topic is consumed again from there, e.g. to replay historical events.
+// The consumer group of the component is stopped while the offsets are moved, then restarted.
+// The other members of the consumer group must be stopped too, the broker does not move the offsets of active groups.
+func (k *Kafka) SeekConsumerGroup(topic string, offset string) error {
+	target, err := parseInitialOffset(offset)
+	if err != nil {
+		return fmt.Errorf("kafka error: %s", err)
+	}
+
+	k.closeSubscripionResources()
+	if err = k.seek(topic, target, true); err != nil {
+		return err
+	}
+	if len(k.topics) == 0 {
+		return nil
+	}
+
+	return k.consume()
+}
+
+// seek moves the offsets of the consumer group on the partitions of the topic to the target offset.
+// Unless force is set, only the partitions the consumer group has no committed offset for are moved.
+func (k *Kafka) seek(topic string, target int64, force bool) error {
+	client, err := sarama.NewClient(k.brokers, k.config)
+	if err != nil {
+		return fmt.Errorf("kafka error: cannot seek topic %s: %s", topic, err)
+	}
+	defer client.Close()
+
+	partitions, err := client.Partitions(topic)
+	if err != nil {
+		return fmt.Errorf("kafka error: cannot seek topic %s: %s", topic, err)
+	}
+
+	offsetManager, err := sarama.NewOffsetManagerFromClient(k.consumerGroup, client)
+	if err != nil {
+		return fmt.Errorf("kafka error: cannot seek topic %s: %s", topic, err)
+	}
+
+	for _, partition := range partitions {
+		err = k.seekPartition(client, offsetManager, topic, partition, target, force)
+		if err != nil {
+			break
+		}
+	}
+
+	// the offsets are committed when the offset manager is closed
+	if closeErr := offsetManager.Close(); err == nil && closeErr != nil {
+		err = fmt.Errorf("kafka error: cannot commit the offsets of topic %s: %s", topic, closeErr)
+	}
+
+	return err
+}
+
+func (k *Kafka) seekPartition(client sarama.Client, offsetManager sarama.OffsetManager, topic string, partition int32, target int64, force bool) error {
+	partitionOffsetManager, err := offsetManager.ManagePartition(topic, partition)
+	if err != nil {
+		return fmt.Errorf("kafka error: cannot seek partition %d of topic %s: %s", partition, topic, err)
+	}
+	// the partition offset manager is released once its offset is committed by the offset manager
+	defer partitionOffsetManager.AsyncClose()
+
+	// the next offset is that of the consumer config when none was committed, which is negative
+	if next, _ := partitionOffsetManager.NextOffset(); next >= 0 && !force {
+		return nil
+	}
+
+	offset, err := client.GetOffset(topic, partition, target)
+	if err == nil && offset < 0 {
+		// no record was published at or after the time, the next records are consumed
+		offset, err = client.GetOffset(topic, partition, sarama.OffsetNewest)
+	}
+	if err != nil {
+		return fmt.Errorf("kafka error: cannot get the offset of partition %d of topic %s: %s", partition, topic, err)
+	}
+
+	k.logger.Debugf("Seeking partition %d of topic %s to offset %d", partition, topic, offset)
+	partitionOffsetManager.ResetOffset(offset, "")
+
+	return nil
+}
@@ -12,6 +12,9 @@ type metadata struct {
 	qos          byte
 	retain       bool
 	cleanSession bool
+	// sharedSubscription subscribes the instances of the consumer to the topics as a shared subscription group,
+	// so that each message is delivered to one of them
+	sharedSubscription bool
 }
 
 type tlsCfg struct {
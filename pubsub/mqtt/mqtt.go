@@ -13,6 +13,7 @@ import (
 	"fmt"
 	"net/url"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/dapr/components-contrib/pubsub"
@@ -31,6 +32,11 @@ const (
 	mqttCACert       = "caCert"
 	mqttClientCert   = "clientCert"
 	mqttClientKey    = "clientKey"
+	// mqttSharedSubscription is not supported by every broker, and requires MQTT 5 with some of them
+	mqttSharedSubscription = "sharedSubscription"
+
+	// sharedSubscriptionPrefix prefixes the topic filters of shared subscriptions: $share/<group>/<topic>
+	sharedSubscriptionPrefix = "$share/"
 
 	// errors
 	errorMsgPrefix = "mqtt pub sub error:"
@@ -98,6 +104,18 @@ func parseMQTTMetaData(md pubsub.Metadata) (*metadata, error) {
 		m.clientID = val
 	}
 
+	if val, ok := md.Properties[mqttSharedSubscription]; ok && val != "" {
+		var err error
+		m.sharedSubscription, err = strconv.ParseBool(val)
+		if err != nil {
+			return &m, fmt.Errorf("%s invalid shared subscription %s, %s", errorMsgPrefix, val, err)
+		}
+		// the shared subscription group is the consumer, it cannot be random
+		if m.sharedSubscription && md.Properties[mqttClientID] == "" {
+			return &m, fmt.Errorf("%s shared subscription requires %s", errorMsgPrefix, mqttClientID)
+		}
+	}
+
 	m.cleanSession = defaultCleanSession
 	if val, ok := md.Properties[mqttCleanSession]; ok && val != "" {
 		var err error
@@ -139,7 +157,7 @@ func (m *mqttPubSub) Init(metadata pubsub.Metadata) error {
 
 	// mqtt broker allows only one connection at a given time from a clientID.
 	producerClientID := fmt.Sprintf("%s-producer", m.metadata.clientID)
-	p, err := m.connect(producerClientID)
+	p, err := m.connect(producerClientID, nil)
 	if err != nil {
 		return err
 	}
@@ -166,7 +184,7 @@ func (m *mqttPubSub) Publish(req *pubsub.PublishRequest) error {
 
 // Subscribe to the mqtt pub sub topic.
 func (m *mqttPubSub) Subscribe(req pubsub.SubscribeRequest, handler func(msg *pubsub.NewMessage) error) error {
-	m.topics[req.Topic] = m.metadata.qos
+	m.topics[m.topicFilter(req.Topic)] = m.metadata.qos
 
 	// reset synchronization
 	if m.consumer != nil {
@@ -177,7 +195,15 @@ func (m *mqttPubSub) Subscribe(req pubsub.SubscribeRequest, handler func(msg *pu
 
 	// mqtt broker allows only one connection at a given time from a clientID.
 	consumerClientID := fmt.Sprintf("%s-consumer", m.metadata.clientID)
-	c, err := m.connect(consumerClientID)
+	if m.metadata.sharedSubscription {
+		// the instances of the consumer share the subscriptions, not their connection
+		consumerClientID = fmt.Sprintf("%s-%s", consumerClientID, uuid.New().String())
+	}
+	callback := func(client mqtt.Client, mqttMsg mqtt.Message) {
+		handler(&pubsub.NewMessage{Topic: req.Topic, Data: mqttMsg.Payload()})
+	}
+	// the client does not route the messages of shared subscriptions to their callback, they go to the default one
+	c, err := m.connect(consumerClientID, callback)
 	if err != nil {
 		return err
 	}
@@ -187,11 +213,7 @@ func (m *mqttPubSub) Subscribe(req pubsub.SubscribeRequest, handler func(msg *pu
 	m.cancel = cancel
 
 	go func() {
-		token := m.consumer.SubscribeMultiple(
-			m.topics,
-			func(client mqtt.Client, mqttMsg mqtt.Message) {
-				handler(&pubsub.NewMessage{Topic: req.Topic, Data: mqttMsg.Payload()})
-			})
+		token := m.consumer.SubscribeMultiple(m.topics, callback)
 		if err := token.Error(); err != nil {
 			m.logger.Errorf("mqtt error from subscribe: %v", err)
 		}
@@ -204,12 +226,25 @@ func (m *mqttPubSub) Subscribe(req pubsub.SubscribeRequest, handler func(msg *pu
 	return nil
 }
 
-func (m *mqttPubSub) connect(clientID string) (mqtt.Client, error) {
+// topicFilter returns the filter a topic is subscribed with, that of the shared subscription group of the consumer
+// unless the topic is a shared subscription filter already.
+func (m *mqttPubSub) topicFilter(topic string) string {
+	if !m.metadata.sharedSubscription || strings.HasPrefix(topic, sharedSubscriptionPrefix) {
+		return topic
+	}
+
+	return fmt.Sprintf("%s%s/%s", sharedSubscriptionPrefix, m.metadata.clientID, topic)
+}
+
+func (m *mqttPubSub) connect(clientID string, defaultHandler mqtt.MessageHandler) (mqtt.Client, error) {
 	uri, err := url.Parse(m.metadata.url)
 	if err != nil {
 		return nil, err
 	}
 	opts := m.createClientOptions(uri, clientID)
+	if defaultHandler != nil {
+		opts.SetDefaultPublishHandler(defaultHandler)
+	}
 	client := mqtt.NewClient(opts)
 	token := client.Connect()
 	for !token.WaitTimeout(defaultWait) {
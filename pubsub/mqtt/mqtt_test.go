@@ -90,6 +90,36 @@ func TestParseMetadata(t *testing.T) {
 		assert.Equal(t, fakeProperties[mqttURL], m.url)
 	})
 
+	t.Run("shared subscription", func(t *testing.T) {
+		fakeProperties := getFakeProperties()
+
+		fakeMetaData := pubsub.Metadata{
+			Properties: fakeProperties,
+		}
+		fakeMetaData.Properties[mqttClientID] = "fakeConsumer"
+		fakeMetaData.Properties[mqttSharedSubscription] = "true"
+
+		m, err := parseMQTTMetaData(fakeMetaData)
+
+		// assert
+		assert.NoError(t, err)
+		assert.True(t, m.sharedSubscription)
+	})
+
+	t.Run("shared subscription without consumerID", func(t *testing.T) {
+		fakeProperties := getFakeProperties()
+
+		fakeMetaData := pubsub.Metadata{
+			Properties: fakeProperties,
+		}
+		fakeMetaData.Properties[mqttSharedSubscription] = "true"
+
+		_, err := parseMQTTMetaData(fakeMetaData)
+
+		// assert
+		assert.Contains(t, err.Error(), "shared subscription requires")
+	})
+
 	t.Run("invalid ca certificate", func(t *testing.T) {
 		fakeProperties := getFakeProperties()
 		fakeMetaData := pubsub.Metadata{Properties: fakeProperties}
@@ -163,3 +193,12 @@ func TestParseMetadata(t *testing.T) {
 		assert.NotNil(t, m.tlsCfg.clientKey, "failed to parse valid client certificate key")
 	})
 }
+
+func TestTopicFilter(t *testing.T) {
+	m := &mqttPubSub{metadata: &metadata{clientID: "fakeConsumer"}}
+	assert.Equal(t, "orders", m.topicFilter("orders"))
+
+	m.metadata.sharedSubscription = true
+	assert.Equal(t, "$share/fakeConsumer/orders", m.topicFilter("orders"))
+	assert.Equal(t, "$share/other/orders", m.topicFilter("$share/other/orders"))
+}
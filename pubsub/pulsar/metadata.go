@@ -1,7 +1,16 @@
 package pulsar
 
+import "time"
+
 type pulsarMetadata struct {
-	Host       string `json:"host"`
-	ConsumerID string `json:"consumerID"`
-	EnableTLS  bool   `json:"enableTLS"`
+	Host             string        `json:"host"`
+	ConsumerID       string        `json:"consumerID"`
+	EnableTLS        bool          `json:"enableTLS"`
+	SubscriptionType string        `json:"subscriptionType"`
+	RedeliveryDelay  time.Duration `json:"redeliveryDelay"`
+	// AdminURL is the URL of the admin REST API of the cluster, the topic policies are set with
+	AdminURL               string `json:"adminURL"`
+	RetentionTimeInMinutes *int   `json:"retentionTimeInMinutes"`
+	RetentionSizeInMB      *int   `json:"retentionSizeInMB"`
+	MessageTTLInSeconds    *int   `json:"messageTTLInSeconds"`
 }
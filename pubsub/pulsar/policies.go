@@ -0,0 +1,136 @@
+package pulsar
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+const (
+	defaultTenant    = "public"
+	defaultNamespace = "default"
+)
+
+type retentionPolicies struct {
+	RetentionTimeInMinutes int `json:"retentionTimeInMinutes"`
+	RetentionSizeInMB      int `json:"retentionSizeInMB"`
+}
+
+// topicPolicies sets the retention and TTL policies of the component on topics with the admin REST API.
+// Topic level policies require Pulsar 2.6, with topicLevelPoliciesEnabled in the broker configuration.
+type topicPolicies struct {
+	adminURL   string
+	retention  *retentionPolicies
+	messageTTL *int
+	client     *http.Client
+}
+
+func newTopicPolicies(m *pulsarMetadata) *topicPolicies {
+	p := &topicPolicies{
+		adminURL:   strings.TrimSuffix(m.AdminURL, "/"),
+		messageTTL: m.MessageTTLInSeconds,
+		client:     &http.Client{Timeout: 30 * time.Second},
+	}
+
+	// messages are removed once either limit is exceeded, the unset one is infinite
+	if m.RetentionTimeInMinutes != nil || m.RetentionSizeInMB != nil {
+		p.retention = &retentionPolicies{RetentionTimeInMinutes: -1, RetentionSizeInMB: -1}
+		if m.RetentionTimeInMinutes != nil {
+			p.retention.RetentionTimeInMinutes = *m.RetentionTimeInMinutes
+		}
+		if m.RetentionSizeInMB != nil {
+			p.retention.RetentionSizeInMB = *m.RetentionSizeInMB
+		}
+	}
+
+	return p
+}
+
+// empty tells whether the component sets no policy
+func (p *topicPolicies) empty() bool {
+	return p.retention == nil && p.messageTTL == nil
+}
+
+// apply sets the policies on the topic, which must exist
+func (p *topicPolicies) apply(topic string) error {
+	path, err := topicPath(topic)
+	if err != nil {
+		return err
+	}
+
+	if p.retention != nil {
+		body, err := json.Marshal(p.retention)
+		if err != nil {
+			return err
+		}
+		if err = p.post(path+"/retention", body); err != nil {
+			return fmt.Errorf("pulsar error: could not set retention of topic %s: %s", topic, err)
+		}
+	}
+
+	if p.messageTTL != nil {
+		if err = p.post(fmt.Sprintf("%s/messageTTL?messageTTL=%d", path, *p.messageTTL), nil); err != nil {
+			return fmt.Errorf("pulsar error: could not set message TTL of topic %s: %s", topic, err)
+		}
+	}
+
+	return nil
+}
+
+func (p *topicPolicies) post(path string, body []byte) error {
+	req, err := http.NewRequest(http.MethodPost, p.adminURL+"/admin/v2/"+path, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= http.StatusMultipleChoices {
+		msg, _ := ioutil.ReadAll(resp.Body)
+
+		return fmt.Errorf("%s: %s", resp.Status, msg)
+	}
+
+	return nil
+}
+
+// topicPath returns the admin REST API path of a topic: {persistent|non-persistent}/{tenant}/{namespace}/{topic}.
+// Topics given by their short name belong to the public/default namespace.
+func topicPath(topic string) (string, error) {
+	domain := "persistent"
+	name := topic
+	if i := strings.Index(topic, "://"); i >= 0 {
+		domain = topic[:i]
+		name = topic[i+3:]
+	}
+	if domain != "persistent" && domain != "non-persistent" {
+		return "", fmt.Errorf("pulsar error: invalid topic %s", topic)
+	}
+
+	segments := strings.Split(name, "/")
+	switch len(segments) {
+	case 1:
+		segments = []string{defaultTenant, defaultNamespace, segments[0]}
+	case 3:
+	default:
+		return "", fmt.Errorf("pulsar error: invalid topic %s", topic)
+	}
+	for i, s := range segments {
+		if s == "" {
+			return "", fmt.Errorf("pulsar error: invalid topic %s", topic)
+		}
+		segments[i] = url.PathEscape(s)
+	}
+
+	return domain + "/" + strings.Join(segments, "/"), nil
+}
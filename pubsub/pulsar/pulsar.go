@@ -5,6 +5,7 @@ import (
 	"errors"
 	"fmt"
 	"strconv"
+	"sync"
 	"time"
 
 	"github.com/apache/pulsar-client-go/pulsar"
@@ -13,18 +14,47 @@ import (
 )
 
 const (
-	host      = "host"
-	enableTLS = "enableTLS"
+	host                   = "host"
+	enableTLS              = "enableTLS"
+	subscriptionType       = "subscriptionType"
+	redeliveryDelay        = "redeliveryDelay"
+	adminURL               = "adminURL"
+	retentionTimeInMinutes = "retentionTimeInMinutes"
+	retentionSizeInMB      = "retentionSizeInMB"
+	messageTTLInSeconds    = "messageTTLInSeconds"
+
+	defaultSubscriptionType = "failover"
+	defaultRedeliveryDelay  = time.Minute
 )
 
+// subscriptionTypes are the subscription types by their metadata value
+var subscriptionTypes = map[string]pulsar.SubscriptionType{
+	// exclusive lets a single consumer consume the subscription
+	"exclusive": pulsar.Exclusive,
+	// shared dispatches the messages round robin to the consumers, regardless of their order
+	"shared": pulsar.Shared,
+	// failover lets a single consumer consume each partition, the others take over when it fails
+	"failover": pulsar.Failover,
+	// key_shared dispatches the messages of a key to the same consumer, in order
+	"key_shared": pulsar.KeyShared,
+}
+
 type Pulsar struct {
 	logger   logger.Logger
 	client   pulsar.Client
 	metadata pulsarMetadata
+
+	policies *topicPolicies
+	// topics holds the topics the policies are set on
+	topics     map[string]struct{}
+	topicsLock sync.Mutex
 }
 
 func NewPulsar(l logger.Logger) pubsub.PubSub {
-	return &Pulsar{logger: l}
+	return &Pulsar{
+		logger: l,
+		topics: map[string]struct{}{},
+	}
 }
 
 func parsePulsarMetadata(meta pubsub.Metadata) (*pulsarMetadata, error) {
@@ -44,6 +74,43 @@ func parsePulsarMetadata(meta pubsub.Metadata) (*pulsarMetadata, error) {
 		m.EnableTLS = tls
 	}
 
+	m.SubscriptionType = defaultSubscriptionType
+	if val, ok := meta.Properties[subscriptionType]; ok && val != "" {
+		if _, ok := subscriptionTypes[val]; !ok {
+			return nil, fmt.Errorf("pulsar error: invalid value for %s: %s", subscriptionType, val)
+		}
+		m.SubscriptionType = val
+	}
+
+	m.RedeliveryDelay = defaultRedeliveryDelay
+	if val, ok := meta.Properties[redeliveryDelay]; ok && val != "" {
+		d, err := time.ParseDuration(val)
+		if err != nil || d <= 0 {
+			return nil, fmt.Errorf("pulsar error: invalid value for %s: %s", redeliveryDelay, val)
+		}
+		m.RedeliveryDelay = d
+	}
+
+	for key, field := range map[string]**int{
+		retentionTimeInMinutes: &m.RetentionTimeInMinutes,
+		retentionSizeInMB:      &m.RetentionSizeInMB,
+		messageTTLInSeconds:    &m.MessageTTLInSeconds,
+	} {
+		if val, ok := meta.Properties[key]; ok && val != "" {
+			i, err := strconv.Atoi(val)
+			// retention is infinite when -1
+			if err != nil || i < -1 || (i == -1 && key == messageTTLInSeconds) {
+				return nil, fmt.Errorf("pulsar error: invalid value for %s: %s", key, val)
+			}
+			*field = &i
+		}
+	}
+
+	m.AdminURL = meta.Properties[adminURL]
+	if m.AdminURL == "" && (m.RetentionTimeInMinutes != nil || m.RetentionSizeInMB != nil || m.MessageTTLInSeconds != nil) {
+		return nil, fmt.Errorf("pulsar error: missing %s, topic policies are set with the admin API", adminURL)
+	}
+
 	return &m, nil
 }
 
@@ -65,6 +132,7 @@ func (p *Pulsar) Init(metadata pubsub.Metadata) error {
 
 	p.client = client
 	p.metadata = *m
+	p.policies = newTopicPolicies(m)
 
 	return nil
 }
@@ -76,9 +144,16 @@ func (p *Pulsar) Publish(req *pubsub.PublishRequest) error {
 	if err != nil {
 		return err
 	}
+	if err = p.applyPolicies(req.Topic); err != nil {
+		producer.Close()
+
+		return err
+	}
 
+	// the messages of a key are delivered to the same consumer of key_shared subscriptions
 	_, err = producer.Send(context.Background(), &pulsar.ProducerMessage{
 		Payload: req.Data,
+		Key:     req.Metadata[pubsub.PartitionKeyMetadataKey],
 	})
 	if err != nil {
 		return err
@@ -92,16 +167,32 @@ func (p *Pulsar) Publish(req *pubsub.PublishRequest) error {
 func (p *Pulsar) Subscribe(req pubsub.SubscribeRequest, handler func(msg *pubsub.NewMessage) error) error {
 	channel := make(chan pulsar.ConsumerMessage, 100)
 
+	// the subscription type of the component may be overridden by the subscription
+	subType := p.metadata.SubscriptionType
+	if val, ok := req.Metadata[subscriptionType]; ok && val != "" {
+		subType = val
+	}
+	t, ok := subscriptionTypes[subType]
+	if !ok {
+		return fmt.Errorf("pulsar error: invalid value for %s: %s", subscriptionType, subType)
+	}
+
 	options := pulsar.ConsumerOptions{
-		Topic:            req.Topic,
-		SubscriptionName: p.metadata.ConsumerID,
-		Type:             pulsar.Failover,
+		Topic:               req.Topic,
+		SubscriptionName:    p.metadata.ConsumerID,
+		Type:                t,
+		NackRedeliveryDelay: p.metadata.RedeliveryDelay,
 	}
 
 	options.MessageChannel = channel
 	consumer, err := p.client.Subscribe(options)
 	if err != nil {
-		p.logger.Debugf("Could not subscribe %s", req.Topic)
+		return fmt.Errorf("pulsar error: could not subscribe %s: %s", req.Topic, err)
+	}
+	if err = p.applyPolicies(req.Topic); err != nil {
+		consumer.Close()
+
+		return err
 	}
 
 	go p.ListenMessage(consumer, req.Topic, handler)
@@ -121,12 +212,34 @@ func (p *Pulsar) HandleMessage(m pulsar.ConsumerMessage, topic string, handler f
 		Topic: topic,
 	})
 	if err != nil {
+		// the message is delivered again once the redelivery delay is over
 		p.logger.Debugf("Could not handle topic %s", topic)
+		m.Nack(m.Message)
 	} else {
 		m.Ack(m.Message)
 	}
 }
 
+// applyPolicies sets the policies of the component on the topic, once
+func (p *Pulsar) applyPolicies(topic string) error {
+	if p.policies == nil || p.policies.empty() {
+		return nil
+	}
+
+	p.topicsLock.Lock()
+	defer p.topicsLock.Unlock()
+
+	if _, ok := p.topics[topic]; ok {
+		return nil
+	}
+	if err := p.policies.apply(topic); err != nil {
+		return err
+	}
+	p.topics[topic] = struct{}{}
+
+	return nil
+}
+
 func (p *Pulsar) Close() error {
 	p.client.Close()
 
@@ -1,7 +1,11 @@
 package pulsar
 
 import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
 	"testing"
+	"time"
 
 	"github.com/dapr/components-contrib/pubsub"
 	"github.com/stretchr/testify/assert"
@@ -36,3 +40,127 @@ func TestInvalidTLSInput(t *testing.T) {
 	assert.Nil(t, meta)
 	assert.Equal(t, "pulsar error: invalid value for enableTLS", err.Error())
 }
+
+func TestParseSubscriptionMetadata(t *testing.T) {
+	t.Run("defaults", func(t *testing.T) {
+		m := pubsub.Metadata{}
+		m.Properties = map[string]string{"host": "a"}
+		meta, err := parsePulsarMetadata(m)
+
+		assert.Nil(t, err)
+		assert.Equal(t, "failover", meta.SubscriptionType)
+		assert.Equal(t, time.Minute, meta.RedeliveryDelay)
+	})
+
+	t.Run("key_shared with redelivery delay", func(t *testing.T) {
+		m := pubsub.Metadata{}
+		m.Properties = map[string]string{"host": "a", "subscriptionType": "key_shared", "redeliveryDelay": "10s"}
+		meta, err := parsePulsarMetadata(m)
+
+		assert.Nil(t, err)
+		assert.Equal(t, "key_shared", meta.SubscriptionType)
+		assert.Equal(t, 10*time.Second, meta.RedeliveryDelay)
+	})
+
+	t.Run("invalid subscription type", func(t *testing.T) {
+		m := pubsub.Metadata{}
+		m.Properties = map[string]string{"host": "a", "subscriptionType": "honk"}
+		meta, err := parsePulsarMetadata(m)
+
+		assert.Error(t, err)
+		assert.Nil(t, meta)
+	})
+
+	t.Run("invalid redelivery delay", func(t *testing.T) {
+		m := pubsub.Metadata{}
+		m.Properties = map[string]string{"host": "a", "redeliveryDelay": "honk"}
+		meta, err := parsePulsarMetadata(m)
+
+		assert.Error(t, err)
+		assert.Nil(t, meta)
+	})
+}
+
+func TestParsePoliciesMetadata(t *testing.T) {
+	t.Run("retention and TTL", func(t *testing.T) {
+		m := pubsub.Metadata{}
+		m.Properties = map[string]string{
+			"host":                   "a",
+			"adminURL":               "http://a:8080",
+			"retentionTimeInMinutes": "60",
+			"messageTTLInSeconds":    "3600",
+		}
+		meta, err := parsePulsarMetadata(m)
+
+		assert.Nil(t, err)
+		assert.Equal(t, 60, *meta.RetentionTimeInMinutes)
+		assert.Nil(t, meta.RetentionSizeInMB)
+		assert.Equal(t, 3600, *meta.MessageTTLInSeconds)
+
+		policies := newTopicPolicies(meta)
+		assert.Equal(t, &retentionPolicies{RetentionTimeInMinutes: 60, RetentionSizeInMB: -1}, policies.retention)
+	})
+
+	t.Run("missing admin URL", func(t *testing.T) {
+		m := pubsub.Metadata{}
+		m.Properties = map[string]string{"host": "a", "retentionSizeInMB": "-1"}
+		meta, err := parsePulsarMetadata(m)
+
+		assert.Error(t, err)
+		assert.Nil(t, meta)
+	})
+
+	t.Run("invalid TTL", func(t *testing.T) {
+		m := pubsub.Metadata{}
+		m.Properties = map[string]string{"host": "a", "adminURL": "http://a:8080", "messageTTLInSeconds": "-1"}
+		meta, err := parsePulsarMetadata(m)
+
+		assert.Error(t, err)
+		assert.Nil(t, meta)
+	})
+}
+
+func TestTopicPath(t *testing.T) {
+	for topic, expected := range map[string]string{
+		"orders":                           "persistent/public/default/orders",
+		"tenant/ns/orders":                 "persistent/tenant/ns/orders",
+		"persistent://tenant/ns/orders":    "persistent/tenant/ns/orders",
+		"non-persistent://tenant/ns/order": "non-persistent/tenant/ns/order",
+	} {
+		path, err := topicPath(topic)
+		assert.Nil(t, err)
+		assert.Equal(t, expected, path)
+	}
+
+	for _, topic := range []string{"ns/orders", "http://tenant/ns/orders", "tenant//orders"} {
+		_, err := topicPath(topic)
+		assert.Error(t, err)
+	}
+}
+
+func TestApplyPolicies(t *testing.T) {
+	var requests []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		requests = append(requests, r.Method+" "+r.URL.String()+" "+string(body))
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer server.Close()
+
+	ttl := 60
+	p := &Pulsar{
+		topics: map[string]struct{}{},
+		policies: newTopicPolicies(&pulsarMetadata{
+			AdminURL:            server.URL,
+			RetentionSizeInMB:   &ttl,
+			MessageTTLInSeconds: &ttl,
+		}),
+	}
+
+	assert.Nil(t, p.applyPolicies("orders"))
+	assert.Nil(t, p.applyPolicies("orders"))
+	assert.Equal(t, []string{
+		`POST /admin/v2/persistent/public/default/orders/retention {"retentionTimeInMinutes":-1,"retentionSizeInMB":60}`,
+		"POST /admin/v2/persistent/public/default/orders/messageTTL?messageTTL=60 ",
+	}, requests)
+}
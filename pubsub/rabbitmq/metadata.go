@@ -3,9 +3,11 @@ package rabbitmq
 import (
 	"fmt"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/dapr/components-contrib/pubsub"
+	"github.com/streadway/amqp"
 )
 
 type metadata struct {
@@ -14,9 +16,24 @@ type metadata struct {
 	deleteWhenUnused bool
 	autoAck          bool
 	requeueInFailure bool
-	deliveryMode     uint8 // Transient (0 or 1) or Persistent (2)
-	prefetchCount    uint8 // Prefetch deactivated if 0
+	deliveryMode     uint8  // Transient (0 or 1) or Persistent (2)
+	prefetchCount    uint8  // Prefetch deactivated if 0
+	maxPriority      uint8  // Priority queues deactivated if 0, existing queues must be deleted to change it
+	delayedExchange  bool   // Requires the delayed message exchange plugin, existing exchanges must be deleted to change it
+	exchangeKind     string // fanout by default, existing exchanges must be deleted to change it
 	reconnectWait    time.Duration
+	contentMode      string
+	compression      string     // Compression of the published cloud event data, publish metadata can override it
+	queueArgs        amqp.Table // Optional arguments of the queues, existing queues must be deleted to change them
+
+	// publisherConfirm makes publishes return once the broker acknowledged the message, or failed to within the timeout
+	publisherConfirm        bool
+	publisherConfirmTimeout time.Duration
+
+	// deadLetter is enforced with a dead letter exchange, existing queues must be deleted to change its topic
+	deadLetter pubsub.DeadLetterConfig
+	// maxExtensions caps the extension attributes of the binary mode cloud events received, 0 if there is no limit
+	maxExtensions int
 }
 
 // createMetadata creates a new instance from the pubsub metadata
@@ -25,6 +42,9 @@ func createMetadata(pubSubMetadata pubsub.Metadata) (*metadata, error) {
 		deleteWhenUnused: true,
 		autoAck:          false,
 		reconnectWait:    time.Duration(defaultReconnectWaitSeconds) * time.Second,
+		exchangeKind:     fanoutExchangeKind,
+
+		publisherConfirmTimeout: defaultPublisherConfirmTimeout,
 	}
 
 	if val, found := pubSubMetadata.Properties[metadataHostKey]; found && val != "" {
@@ -78,5 +98,121 @@ func createMetadata(pubSubMetadata pubsub.Metadata) (*metadata, error) {
 		}
 	}
 
+	if val, found := pubSubMetadata.Properties[metadataMaxPriority]; found && val != "" {
+		intVal, err := strconv.Atoi(val)
+		if err != nil || intVal < 0 || intVal > 255 {
+			return &result, fmt.Errorf("%s invalid RabbitMQ max priority, accepted values are between 0 and 255", errorMessagePrefix)
+		}
+		result.maxPriority = uint8(intVal)
+	}
+
+	if val, found := pubSubMetadata.Properties[metadataDelayedExchange]; found && val != "" {
+		if boolVal, err := strconv.ParseBool(val); err == nil {
+			result.delayedExchange = boolVal
+		}
+	}
+
+	deadLetter, err := pubsub.ParseDeadLetterConfig(pubSubMetadata.Properties)
+	if err != nil {
+		return &result, fmt.Errorf("%s %s", errorMessagePrefix, err)
+	}
+	result.deadLetter = deadLetter
+
+	maxExtensions, err := pubsub.ParseMaxExtensions(pubSubMetadata.Properties)
+	if err != nil {
+		return &result, fmt.Errorf("%s %s", errorMessagePrefix, err)
+	}
+	result.maxExtensions = maxExtensions
+
+	contentMode, err := pubsub.ParseContentMode(pubSubMetadata.Properties)
+	if err != nil {
+		return &result, fmt.Errorf("%s %s", errorMessagePrefix, err)
+	}
+	result.contentMode = contentMode
+
+	compression, err := pubsub.ParseCompression(pubSubMetadata.Properties, pubsub.CompressionNone)
+	if err != nil {
+		return &result, fmt.Errorf("%s %s", errorMessagePrefix, err)
+	}
+	result.compression = compression
+
+	if val, found := pubSubMetadata.Properties[metadataExchangeKind]; found && val != "" {
+		switch kind := strings.ToLower(val); kind {
+		case fanoutExchangeKind, topicExchangeKind, directExchangeKind, headersExchangeKind:
+			result.exchangeKind = kind
+		default:
+			return &result, fmt.Errorf("%s invalid RabbitMQ exchange kind, accepted values are %s, %s, %s and %s", errorMessagePrefix, fanoutExchangeKind, topicExchangeKind, directExchangeKind, headersExchangeKind)
+		}
+	}
+
+	if val, found := pubSubMetadata.Properties[metadataPublisherConfirm]; found && val != "" {
+		if boolVal, err := strconv.ParseBool(val); err == nil {
+			result.publisherConfirm = boolVal
+		}
+	}
+
+	if val, found := pubSubMetadata.Properties[metadataPublisherConfirmTimeout]; found && val != "" {
+		timeout, err := time.ParseDuration(val)
+		if err != nil || timeout <= 0 {
+			return &result, fmt.Errorf("%s invalid RabbitMQ publisher confirm timeout, expected a positive duration", errorMessagePrefix)
+		}
+		result.publisherConfirmTimeout = timeout
+	}
+
+	queueArgs, err := parseQueueArgs(pubSubMetadata.Properties)
+	if err != nil {
+		return &result, err
+	}
+	result.queueArgs = queueArgs
+
+	if queueArgs[argQueueType] == queueTypeQuorum {
+		// quorum queues are replicated, they cannot be deleted when unused or prioritized
+		if val := pubSubMetadata.Properties[metadataDeleteWhenUnusedKey]; val == "" {
+			result.deleteWhenUnused = false
+		} else if result.deleteWhenUnused {
+			return &result, fmt.Errorf("%s quorum queues cannot be deleted when unused, set %s to false", errorMessagePrefix, metadataDeleteWhenUnusedKey)
+		}
+		if result.maxPriority > 0 {
+			return &result, fmt.Errorf("%s quorum queues do not support %s", errorMessagePrefix, metadataMaxPriority)
+		}
+	}
+
 	return &result, nil
 }
+
+// parseQueueArgs returns the optional queue arguments of the metadata: the queue type,
+// and the metadata prefixed with x-, e.g. x-max-length, x-message-ttl or x-single-active-consumer.
+// Values are passed as integers or booleans when they parse as such, as strings otherwise.
+func parseQueueArgs(properties map[string]string) (amqp.Table, error) {
+	args := amqp.Table{}
+	for key, val := range properties {
+		if !strings.HasPrefix(key, argPrefix) || val == "" {
+			continue
+		}
+
+		if intVal, err := strconv.ParseInt(val, 10, 64); err == nil {
+			args[key] = intVal
+		} else if boolVal, err := strconv.ParseBool(val); err == nil {
+			args[key] = boolVal
+		} else {
+			args[key] = val
+		}
+	}
+
+	if val, found := properties[metadataQueueType]; found && val != "" {
+		queueType := strings.ToLower(val)
+		if queueType != queueTypeClassic && queueType != queueTypeQuorum {
+			return nil, fmt.Errorf("%s invalid RabbitMQ queue type, accepted values are %s and %s", errorMessagePrefix, queueTypeClassic, queueTypeQuorum)
+		}
+		if argType, found := args[argQueueType]; found && argType != queueType {
+			return nil, fmt.Errorf("%s %s and %s conflict", errorMessagePrefix, metadataQueueType, argQueueType)
+		}
+		args[argQueueType] = queueType
+	}
+
+	if len(args) == 0 {
+		return nil, nil
+	}
+
+	return args, nil
+}
@@ -3,8 +3,10 @@ package rabbitmq
 import (
 	"fmt"
 	"testing"
+	"time"
 
 	"github.com/dapr/components-contrib/pubsub"
+	"github.com/streadway/amqp"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -141,6 +143,40 @@ func TestCreateMetadata(t *testing.T) {
 		assert.Equal(t, uint8(1), m.prefetchCount)
 	})
 
+	t.Run("maxPriority is set", func(t *testing.T) {
+		fakeProperties := getFakeProperties()
+
+		fakeMetaData := pubsub.Metadata{
+			Properties: fakeProperties,
+		}
+		fakeMetaData.Properties[metadataMaxPriority] = "10"
+
+		// act
+		m, err := createMetadata(fakeMetaData)
+
+		// assert
+		assert.NoError(t, err)
+		assert.Equal(t, uint8(10), m.maxPriority)
+	})
+
+	for _, maxPriority := range []string{"256", "-1", "high"} {
+		maxPriority := maxPriority
+		t.Run(fmt.Sprintf("maxPriority %s is invalid", maxPriority), func(t *testing.T) {
+			fakeProperties := getFakeProperties()
+
+			fakeMetaData := pubsub.Metadata{
+				Properties: fakeProperties,
+			}
+			fakeMetaData.Properties[metadataMaxPriority] = maxPriority
+
+			// act
+			_, err := createMetadata(fakeMetaData)
+
+			// assert
+			assert.EqualError(t, err, "rabbitmq pub/sub error: invalid RabbitMQ max priority, accepted values are between 0 and 255")
+		})
+	}
+
 	for _, tt := range booleanFlagTests {
 		t.Run(fmt.Sprintf("autoAck value=%s", tt.in), func(t *testing.T) {
 			fakeProperties := getFakeProperties()
@@ -201,6 +237,24 @@ func TestCreateMetadata(t *testing.T) {
 		})
 	}
 
+	for _, tt := range booleanFlagTests {
+		t.Run(fmt.Sprintf("delayedExchange value=%s", tt.in), func(t *testing.T) {
+			fakeProperties := getFakeProperties()
+
+			fakeMetaData := pubsub.Metadata{
+				Properties: fakeProperties,
+			}
+			fakeMetaData.Properties[metadataDelayedExchange] = tt.in
+
+			// act
+			m, err := createMetadata(fakeMetaData)
+
+			// assert
+			assert.NoError(t, err)
+			assert.Equal(t, tt.expected, m.delayedExchange)
+		})
+	}
+
 	for _, tt := range booleanFlagTests {
 		t.Run(fmt.Sprintf("durable value=%s", tt.in), func(t *testing.T) {
 			fakeProperties := getFakeProperties()
@@ -218,4 +272,232 @@ func TestCreateMetadata(t *testing.T) {
 			assert.Equal(t, fakeProperties[metadataConsumerIDKey], m.consumerID)
 		})
 	}
+
+	t.Run("contentMode is structured by default", func(t *testing.T) {
+		fakeMetaData := pubsub.Metadata{
+			Properties: getFakeProperties(),
+		}
+
+		// act
+		m, err := createMetadata(fakeMetaData)
+
+		// assert
+		assert.NoError(t, err)
+		assert.Equal(t, pubsub.ContentModeStructured, m.contentMode)
+	})
+
+	t.Run("contentMode is set", func(t *testing.T) {
+		fakeProperties := getFakeProperties()
+
+		fakeMetaData := pubsub.Metadata{
+			Properties: fakeProperties,
+		}
+		fakeMetaData.Properties[pubsub.ContentModeMetadataKey] = "binary"
+
+		// act
+		m, err := createMetadata(fakeMetaData)
+
+		// assert
+		assert.NoError(t, err)
+		assert.Equal(t, pubsub.ContentModeBinary, m.contentMode)
+	})
+
+	t.Run("compression is set", func(t *testing.T) {
+		fakeProperties := getFakeProperties()
+
+		fakeMetaData := pubsub.Metadata{
+			Properties: fakeProperties,
+		}
+		fakeMetaData.Properties[pubsub.CompressionMetadataKey] = "snappy"
+
+		// act
+		m, err := createMetadata(fakeMetaData)
+
+		// assert
+		assert.NoError(t, err)
+		assert.Equal(t, pubsub.CompressionSnappy, m.compression)
+
+		fakeMetaData.Properties[pubsub.CompressionMetadataKey] = "lz4"
+		_, err = createMetadata(fakeMetaData)
+		assert.Error(t, err)
+	})
+
+	t.Run("queue arguments are set", func(t *testing.T) {
+		fakeProperties := getFakeProperties()
+
+		fakeMetaData := pubsub.Metadata{
+			Properties: fakeProperties,
+		}
+		fakeMetaData.Properties["x-max-length"] = "1000"
+		fakeMetaData.Properties["x-single-active-consumer"] = "true"
+		fakeMetaData.Properties["x-overflow"] = "reject-publish"
+
+		// act
+		m, err := createMetadata(fakeMetaData)
+
+		// assert
+		assert.NoError(t, err)
+		assert.Equal(t, amqp.Table{
+			"x-max-length":             int64(1000),
+			"x-single-active-consumer": true,
+			"x-overflow":               "reject-publish",
+		}, m.queueArgs)
+		assert.Equal(t, true, m.deleteWhenUnused)
+	})
+
+	t.Run("queueType is quorum", func(t *testing.T) {
+		fakeProperties := getFakeProperties()
+
+		fakeMetaData := pubsub.Metadata{
+			Properties: fakeProperties,
+		}
+		fakeMetaData.Properties[metadataQueueType] = "Quorum"
+
+		// act
+		m, err := createMetadata(fakeMetaData)
+
+		// assert
+		assert.NoError(t, err)
+		assert.Equal(t, amqp.Table{argQueueType: queueTypeQuorum}, m.queueArgs)
+		assert.Equal(t, false, m.deleteWhenUnused)
+	})
+
+	t.Run("quorum queues are not deleted when unused", func(t *testing.T) {
+		fakeProperties := getFakeProperties()
+
+		fakeMetaData := pubsub.Metadata{
+			Properties: fakeProperties,
+		}
+		fakeMetaData.Properties[argQueueType] = queueTypeQuorum
+		fakeMetaData.Properties[metadataDeleteWhenUnusedKey] = "true"
+
+		// act
+		_, err := createMetadata(fakeMetaData)
+
+		// assert
+		assert.EqualError(t, err, "rabbitmq pub/sub error: quorum queues cannot be deleted when unused, set deletedWhenUnused to false")
+	})
+
+	t.Run("quorum queues are not prioritized", func(t *testing.T) {
+		fakeProperties := getFakeProperties()
+
+		fakeMetaData := pubsub.Metadata{
+			Properties: fakeProperties,
+		}
+		fakeMetaData.Properties[metadataQueueType] = queueTypeQuorum
+		fakeMetaData.Properties[metadataMaxPriority] = "10"
+
+		// act
+		_, err := createMetadata(fakeMetaData)
+
+		// assert
+		assert.Error(t, err)
+	})
+
+	for _, properties := range []map[string]string{
+		{metadataQueueType: "stream"},
+		{metadataQueueType: queueTypeQuorum, argQueueType: queueTypeClassic},
+	} {
+		properties := properties
+		t.Run(fmt.Sprintf("queueType %v is invalid", properties), func(t *testing.T) {
+			fakeProperties := getFakeProperties()
+			for key, value := range properties {
+				fakeProperties[key] = value
+			}
+
+			fakeMetaData := pubsub.Metadata{
+				Properties: fakeProperties,
+			}
+
+			// act
+			_, err := createMetadata(fakeMetaData)
+
+			// assert
+			assert.Error(t, err)
+		})
+	}
+
+	t.Run("publisherConfirm is set", func(t *testing.T) {
+		fakeProperties := getFakeProperties()
+
+		fakeMetaData := pubsub.Metadata{
+			Properties: fakeProperties,
+		}
+
+		// act
+		m, err := createMetadata(fakeMetaData)
+
+		// assert
+		assert.NoError(t, err)
+		assert.Equal(t, false, m.publisherConfirm)
+		assert.Equal(t, defaultPublisherConfirmTimeout, m.publisherConfirmTimeout)
+
+		fakeMetaData.Properties[metadataPublisherConfirm] = "true"
+		fakeMetaData.Properties[metadataPublisherConfirmTimeout] = "500ms"
+
+		// act
+		m, err = createMetadata(fakeMetaData)
+
+		// assert
+		assert.NoError(t, err)
+		assert.Equal(t, true, m.publisherConfirm)
+		assert.Equal(t, 500*time.Millisecond, m.publisherConfirmTimeout)
+	})
+
+	for _, timeout := range []string{"0s", "-1s", "soon"} {
+		timeout := timeout
+		t.Run(fmt.Sprintf("publisherConfirmTimeout %s is invalid", timeout), func(t *testing.T) {
+			fakeProperties := getFakeProperties()
+
+			fakeMetaData := pubsub.Metadata{
+				Properties: fakeProperties,
+			}
+			fakeMetaData.Properties[metadataPublisherConfirmTimeout] = timeout
+
+			// act
+			_, err := createMetadata(fakeMetaData)
+
+			// assert
+			assert.EqualError(t, err, "rabbitmq pub/sub error: invalid RabbitMQ publisher confirm timeout, expected a positive duration")
+		})
+	}
+
+	t.Run("exchangeKind is set", func(t *testing.T) {
+		fakeProperties := getFakeProperties()
+
+		fakeMetaData := pubsub.Metadata{
+			Properties: fakeProperties,
+		}
+
+		// act
+		m, err := createMetadata(fakeMetaData)
+
+		// assert
+		assert.NoError(t, err)
+		assert.Equal(t, fanoutExchangeKind, m.exchangeKind)
+
+		fakeMetaData.Properties[metadataExchangeKind] = "Topic"
+		m, err = createMetadata(fakeMetaData)
+		assert.NoError(t, err)
+		assert.Equal(t, topicExchangeKind, m.exchangeKind)
+
+		fakeMetaData.Properties[metadataExchangeKind] = "x-consistent-hash"
+		_, err = createMetadata(fakeMetaData)
+		assert.Error(t, err)
+	})
+
+	t.Run("contentMode is invalid", func(t *testing.T) {
+		fakeProperties := getFakeProperties()
+
+		fakeMetaData := pubsub.Metadata{
+			Properties: fakeProperties,
+		}
+		fakeMetaData.Properties[pubsub.ContentModeMetadataKey] = "batched"
+
+		// act
+		_, err := createMetadata(fakeMetaData)
+
+		// assert
+		assert.Error(t, err)
+	})
 }
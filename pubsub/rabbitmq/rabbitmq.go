@@ -8,6 +8,7 @@ import (
 	"sync"
 	"time"
 
+	contrib_metadata "github.com/dapr/components-contrib/metadata"
 	"github.com/dapr/components-contrib/pubsub"
 	"github.com/dapr/dapr/pkg/logger"
 	"github.com/streadway/amqp"
@@ -15,6 +16,10 @@ import (
 
 const (
 	fanoutExchangeKind     = "fanout"
+	topicExchangeKind      = "topic"
+	directExchangeKind     = "direct"
+	headersExchangeKind    = "headers"
+	delayedExchangeKind    = "x-delayed-message"
 	logMessagePrefix       = "rabbitmq pub/sub:"
 	errorMessagePrefix     = "rabbitmq pub/sub error:"
 	errorChannelConnection = "channel/connection is not open"
@@ -29,6 +34,44 @@ const (
 
 	defaultReconnectWaitSeconds = 10
 	metadataprefetchCount       = "prefetchCount"
+	metadataMaxPriority         = "maxPriority"
+	metadataDelayedExchange     = "delayedExchange"
+	metadataQueueType           = "queueType"
+	metadataExchangeKind        = "exchangeKind"
+
+	// metadataRoutingKey is the publish metadata key of the routing key of the message, and the subscription
+	// metadata key of the comma separated routing keys the queue is bound with, * and # wildcards in topic exchanges
+	metadataRoutingKey = "routingKey"
+	// metadataHeaderPrefix prefixes the publish metadata of the message headers, and the subscription metadata
+	// of the headers the queue is bound with in headers exchanges
+	metadataHeaderPrefix = "header."
+	// argMatch tells whether all or any of the binding headers must match in headers exchanges
+	argMatch = "x-match"
+
+	metadataPublisherConfirm        = "publisherConfirm"
+	metadataPublisherConfirmTimeout = "publisherConfirmTimeout"
+	defaultPublisherConfirmTimeout  = 5 * time.Second
+
+	// queueTypeClassic declares classic queues, the default
+	queueTypeClassic = "classic"
+	// queueTypeQuorum declares replicated quorum queues, which replace the classic mirrored queues
+	queueTypeQuorum = "quorum"
+
+	// argPrefix prefixes the optional arguments of queues, component metadata with this prefix is passed as is
+	argPrefix = "x-"
+	// argQueueType is the type of the queue, classic or quorum
+	argQueueType = "x-queue-type"
+	// argMaxPriority declares priority queues
+	argMaxPriority = "x-max-priority"
+	// argDeadLetterExchange names the exchange rejected messages are published to
+	argDeadLetterExchange = "x-dead-letter-exchange"
+	// argDelayedType is the exchange kind routing messages once delayed by the delayed message exchange plugin
+	argDelayedType = "x-delayed-type"
+	// headerDelay delays a message by the given number of milliseconds in a delayed message exchange
+	headerDelay = "x-delay"
+
+	// ceHeaderPrefix prefixes the cloud event attributes in the message headers in binary content mode
+	ceHeaderPrefix = "cloudEvents_"
 )
 
 // RabbitMQ allows sending/receiving messages in pub/sub format
@@ -40,6 +83,10 @@ type rabbitMQ struct {
 	stopped           bool
 	metadata          *metadata
 	declaredExchanges map[string]bool
+	// confirms receives the publisher confirms of the channel in confirm mode
+	confirms <-chan amqp.Confirmation
+	// publishMutex serializes the publishes in confirm mode, so that confirms are matched with their message
+	publishMutex sync.Mutex
 
 	connectionDial func(host string) (rabbitMQConnectionBroker, rabbitMQChannelBroker, error)
 
@@ -56,6 +103,8 @@ type rabbitMQChannelBroker interface {
 	Ack(tag uint64, multiple bool) error
 	ExchangeDeclare(name string, kind string, durable bool, autoDelete bool, internal bool, noWait bool, args amqp.Table) error
 	Qos(prefetchCount, prefetchSize int, global bool) error
+	Confirm(noWait bool) error
+	NotifyPublish(confirm chan amqp.Confirmation) chan amqp.Confirmation
 }
 
 // interface used to allow unit testing
@@ -135,6 +184,16 @@ func (r *rabbitMQ) reconnect(connectionCount int) error {
 		return err
 	}
 
+	if r.metadata.publisherConfirm {
+		// the broker acknowledges each message published on the channel once it is responsible for it
+		if err = ch.Confirm(false); err != nil {
+			conn.Close()
+
+			return err
+		}
+		r.confirms = ch.NotifyPublish(make(chan amqp.Confirmation, 1))
+	}
+
 	r.connection = conn
 	r.channel = ch
 	r.connectionCount++
@@ -144,6 +203,13 @@ func (r *rabbitMQ) reconnect(connectionCount int) error {
 	return nil
 }
 
+func (r *rabbitMQ) getConfirmChannel() (rabbitMQChannelBroker, <-chan amqp.Confirmation, int) {
+	r.channelMutex.RLock()
+	defer r.channelMutex.RUnlock()
+
+	return r.channel, r.confirms, r.connectionCount
+}
+
 func (r *rabbitMQ) getChannelOrReconnect() (rabbitMQChannelBroker, int, error) {
 	channel, connectionCount := r.getChannel()
 	if channel != nil {
@@ -174,11 +240,78 @@ func (r *rabbitMQ) Publish(req *pubsub.PublishRequest) error {
 
 	r.logger.Debugf("%s publishing message to topic '%s'", logMessagePrefix, req.Topic)
 
-	err = channel.Publish(req.Topic, "", false, false, amqp.Publishing{
+	priority, _, err := contrib_metadata.TryGetPriority(req.Metadata)
+	if err != nil {
+		return fmt.Errorf("%s %s", errorMessagePrefix, err)
+	}
+
+	deliverAt, hasDeliverAt, err := contrib_metadata.TryGetDeliverAt(req.Metadata)
+	if err != nil {
+		return fmt.Errorf("%s %s", errorMessagePrefix, err)
+	}
+	if hasDeliverAt && !r.metadata.delayedExchange {
+		return fmt.Errorf("%s scheduled delivery requires the delayed message exchange, set %s to true", errorMessagePrefix, metadataDelayedExchange)
+	}
+
+	msg := amqp.Publishing{
 		ContentType:  "text/plain",
 		Body:         req.Data,
 		DeliveryMode: r.metadata.deliveryMode,
-	})
+		Priority:     priority,
+	}
+
+	rawPayload, err := contrib_metadata.IsRawPayload(req.Metadata)
+	if err != nil {
+		return fmt.Errorf("%s %s", errorMessagePrefix, err)
+	}
+
+	if !rawPayload {
+		compression, err := pubsub.ParseCompression(req.Metadata, r.metadata.compression)
+		if err != nil {
+			return fmt.Errorf("%s %s", errorMessagePrefix, err)
+		}
+		msg.Body, err = pubsub.CompressCloudEvent(req.Data, compression)
+		if err != nil {
+			return fmt.Errorf("%s %s", errorMessagePrefix, err)
+		}
+	}
+
+	if !rawPayload && r.metadata.contentMode == pubsub.ContentModeBinary {
+		binary, err := pubsub.ToBinaryMessage(msg.Body, ceHeaderPrefix)
+		if err != nil {
+			return fmt.Errorf("%s binary content mode requires a cloud event: %s", errorMessagePrefix, err)
+		}
+
+		msg.Body = binary.Data
+		msg.ContentType = binary.ContentType
+		msg.Headers = make(amqp.Table, len(binary.Headers))
+		for key, value := range binary.Headers {
+			msg.Headers[key] = value
+		}
+	}
+
+	for key, value := range req.Metadata {
+		if name := strings.TrimPrefix(key, metadataHeaderPrefix); name != key && name != "" {
+			if msg.Headers == nil {
+				msg.Headers = amqp.Table{}
+			}
+			msg.Headers[name] = value
+		}
+	}
+
+	if delay := time.Until(deliverAt); hasDeliverAt && delay > 0 {
+		if msg.Headers == nil {
+			msg.Headers = amqp.Table{}
+		}
+		msg.Headers[headerDelay] = delay.Milliseconds()
+	}
+
+	routingKey := req.Metadata[metadataRoutingKey]
+	if r.metadata.publisherConfirm {
+		return r.publishConfirmed(req.Topic, routingKey, msg)
+	}
+
+	err = channel.Publish(req.Topic, routingKey, false, false, msg)
 
 	if err != nil {
 		if mustReconnect(channel, err) {
@@ -192,10 +325,65 @@ func (r *rabbitMQ) Publish(req *pubsub.PublishRequest) error {
 	return nil
 }
 
+// publishConfirmed publishes the message and waits for the broker to confirm it.
+// The channel is re-established when the confirm is not received in time, so that a late confirm
+// is not matched with the next message.
+func (r *rabbitMQ) publishConfirmed(topic string, routingKey string, msg amqp.Publishing) error {
+	r.publishMutex.Lock()
+	defer r.publishMutex.Unlock()
+
+	channel, confirms, connectionCount := r.getConfirmChannel()
+	if channel == nil || confirms == nil {
+		return fmt.Errorf("%s %s", errorMessagePrefix, errorChannelConnection)
+	}
+
+	err := channel.Publish(topic, routingKey, false, false, msg)
+	if err != nil {
+		if mustReconnect(channel, err) {
+			r.logger.Warnf("%s pubsub publisher for %s is reconnecting ...", logMessagePrefix, topic)
+			r.reconnect(connectionCount)
+		}
+
+		return err
+	}
+
+	timer := time.NewTimer(r.metadata.publisherConfirmTimeout)
+	defer timer.Stop()
+
+	select {
+	case confirm, ok := <-confirms:
+		if !ok {
+			r.logger.Warnf("%s pubsub publisher for %s is reconnecting ...", logMessagePrefix, topic)
+			r.reconnect(connectionCount)
+
+			return fmt.Errorf("%s %s before the message to topic '%s' was confirmed", errorMessagePrefix, errorChannelConnection, topic)
+		}
+		if !confirm.Ack {
+			return fmt.Errorf("%s message to topic '%s' was rejected by the broker", errorMessagePrefix, topic)
+		}
+
+		return nil
+	case <-timer.C:
+		r.logger.Warnf("%s pubsub publisher for %s is reconnecting ...", logMessagePrefix, topic)
+		r.reconnect(connectionCount)
+
+		return fmt.Errorf("%s message to topic '%s' was not confirmed within %s", errorMessagePrefix, topic, r.metadata.publisherConfirmTimeout)
+	}
+}
+
 func (r *rabbitMQ) Subscribe(req pubsub.SubscribeRequest, handler func(msg *pubsub.NewMessage) error) error {
+	rawPayload, err := contrib_metadata.IsRawPayload(req.Metadata)
+	if err != nil {
+		return fmt.Errorf("%s %s", errorMessagePrefix, err)
+	}
+
+	if _, _, err = r.bindings(req.Metadata); err != nil {
+		return err
+	}
+
 	queueName := fmt.Sprintf("%s-%s", r.metadata.consumerID, req.Topic)
 
-	go r.subscribeForever(req, queueName, handler)
+	go r.subscribeForever(req, queueName, rawPayload, handler)
 
 	return nil
 }
@@ -206,8 +394,24 @@ func (r *rabbitMQ) prepareSubscription(channel rabbitMQChannelBroker, req pubsub
 		return nil, err
 	}
 
+	args := make(amqp.Table, len(r.metadata.queueArgs))
+	for key, value := range r.metadata.queueArgs {
+		args[key] = value
+	}
+	if r.metadata.maxPriority > 0 {
+		args[argMaxPriority] = int32(r.metadata.maxPriority)
+	}
+	if r.metadata.deadLetter.Enabled() {
+		err = r.ensureExchangeDeclared(channel, r.metadata.deadLetter.Topic)
+		if err != nil {
+			return nil, err
+		}
+		args[argDeadLetterExchange] = r.metadata.deadLetter.Topic
+	}
+
 	r.logger.Debugf("%s declaring queue '%s'", logMessagePrefix, queueName)
-	q, err := channel.QueueDeclare(queueName, true, r.metadata.deleteWhenUnused, false, false, nil)
+
+	q, err := channel.QueueDeclare(queueName, true, r.metadata.deleteWhenUnused, false, false, args)
 	if err != nil {
 		return nil, err
 	}
@@ -220,18 +424,73 @@ func (r *rabbitMQ) prepareSubscription(channel rabbitMQChannelBroker, req pubsub
 		}
 	}
 
-	r.logger.Debugf("%s binding queue '%s' to exchange '%s'", logMessagePrefix, q.Name, req.Topic)
-	err = channel.QueueBind(q.Name, "", req.Topic, false, nil)
+	keys, bindArgs, err := r.bindings(req.Metadata)
 	if err != nil {
 		return nil, err
 	}
 
+	for _, key := range keys {
+		r.logger.Debugf("%s binding queue '%s' to exchange '%s' with routing key '%s'", logMessagePrefix, q.Name, req.Topic, key)
+		err = channel.QueueBind(q.Name, key, req.Topic, false, bindArgs)
+		if err != nil {
+			return nil, err
+		}
+	}
+
 	return &q, nil
 }
 
+// bindings returns the routing keys and arguments the queue of a subscription is bound with, according to the
+// exchange kind. Queues are bound to all the messages of fanout exchanges, and of topic exchanges by default.
+func (r *rabbitMQ) bindings(metadata map[string]string) ([]string, amqp.Table, error) {
+	var keys []string
+	for _, key := range strings.Split(metadata[metadataRoutingKey], ",") {
+		if key = strings.TrimSpace(key); key != "" {
+			keys = append(keys, key)
+		}
+	}
+
+	switch r.metadata.exchangeKind {
+	case fanoutExchangeKind:
+		if len(keys) > 0 {
+			return nil, nil, fmt.Errorf("%s %s requires a %s, %s or %s exchange, set %s", errorMessagePrefix, metadataRoutingKey, topicExchangeKind, directExchangeKind, headersExchangeKind, metadataExchangeKind)
+		}
+
+		return []string{""}, nil, nil
+	case topicExchangeKind:
+		if len(keys) == 0 {
+			return []string{"#"}, nil, nil
+		}
+
+		return keys, nil, nil
+	case headersExchangeKind:
+		args := amqp.Table{}
+		for key, value := range metadata {
+			if name := strings.TrimPrefix(key, metadataHeaderPrefix); name != key && name != "" {
+				args[name] = value
+			}
+		}
+		if match, ok := metadata[argMatch]; ok {
+			if match != "all" && match != "any" {
+				return nil, nil, fmt.Errorf("%s invalid %s %s, accepted values are all and any", errorMessagePrefix, argMatch, match)
+			}
+			args[argMatch] = match
+		}
+
+		return []string{""}, args, nil
+	default:
+		if len(keys) == 0 {
+			return []string{""}, nil, nil
+		}
+
+		return keys, nil, nil
+	}
+}
+
 func (r *rabbitMQ) subscribeForever(
 	req pubsub.SubscribeRequest,
 	queueName string,
+	rawPayload bool,
 	handler func(msg *pubsub.NewMessage) error) {
 	var err error
 	var connectionCount int
@@ -268,7 +527,7 @@ func (r *rabbitMQ) subscribeForever(
 				break
 			}
 
-			err = r.listenMessages(channel, msgs, req.Topic, handler)
+			err = r.listenMessages(channel, msgs, req.Topic, rawPayload, handler)
 			if err != nil {
 				break
 			}
@@ -284,9 +543,9 @@ func (r *rabbitMQ) subscribeForever(
 	}
 }
 
-func (r *rabbitMQ) listenMessages(channel rabbitMQChannelBroker, msgs <-chan amqp.Delivery, topic string, handler func(msg *pubsub.NewMessage) error) error {
+func (r *rabbitMQ) listenMessages(channel rabbitMQChannelBroker, msgs <-chan amqp.Delivery, topic string, rawPayload bool, handler func(msg *pubsub.NewMessage) error) error {
 	for d := range msgs {
-		err := r.handleMessage(channel, d, topic, handler)
+		err := r.handleMessage(channel, d, topic, rawPayload, handler)
 		if (err != nil) && mustReconnect(channel, err) {
 			return err
 		}
@@ -295,22 +554,40 @@ func (r *rabbitMQ) listenMessages(channel rabbitMQChannelBroker, msgs <-chan amq
 	return nil
 }
 
-func (r *rabbitMQ) handleMessage(channel rabbitMQChannelBroker, d amqp.Delivery, topic string, handler func(msg *pubsub.NewMessage) error) error {
-	pubsubMsg := &pubsub.NewMessage{
+func (r *rabbitMQ) handleMessage(channel rabbitMQChannelBroker, d amqp.Delivery, topic string, rawPayload bool, handler func(msg *pubsub.NewMessage) error) error {
+	var err error
+	msg := &pubsub.NewMessage{
 		Data:  d.Body,
 		Topic: topic,
 	}
-
-	err := handler(pubsubMsg)
+	if !rawPayload {
+		var data []byte
+		data, err = structuredData(d, r.metadata.maxExtensions)
+		if err == nil {
+			msg.Data = data
+		}
+	}
+	if err == nil {
+		err = r.metadata.deadLetter.Deliver(msg, handler)
+	}
 	if err != nil {
 		r.logger.Errorf("%s error handling message from topic '%s', %s", logMessagePrefix, topic, err)
 	}
 
+	// auto acked messages cannot be rejected to the dead letter exchange, they are published to it instead
+	if err != nil && r.metadata.autoAck && r.metadata.deadLetter.Enabled() {
+		r.logger.Debugf("%s publishing message '%s' from topic '%s' to dead letter topic '%s'", logMessagePrefix, d.MessageId, topic, r.metadata.deadLetter.Topic)
+		if err = r.metadata.deadLetter.DeadLetter(r, msg); err != nil {
+			r.logger.Errorf("%s %s", logMessagePrefix, err)
+		}
+	}
+
 	//nolint:nestif
 	// if message is not auto acked we need to ack/nack
 	if !r.metadata.autoAck {
 		if err != nil {
-			requeue := r.metadata.requeueInFailure && !d.Redelivered
+			// messages which are not requeued are routed to the dead letter exchange, if there is one
+			requeue := r.metadata.requeueInFailure && !d.Redelivered && !r.metadata.deadLetter.Enabled()
 
 			r.logger.Debugf("%s nacking message '%s' from topic '%s', requeue=%t", logMessagePrefix, d.MessageId, topic, requeue)
 			if err = channel.Nack(d.DeliveryTag, false, requeue); err != nil {
@@ -327,10 +604,36 @@ func (r *rabbitMQ) handleMessage(channel rabbitMQChannelBroker, d amqp.Delivery,
 	return err
 }
 
+// structuredData returns the body of the delivery, converting cloud events in binary content mode to structured ones
+// and decompressing their data. Binary mode cloud events with more than maxExtensions extension attributes are rejected,
+// 0 disables the limit.
+func structuredData(d amqp.Delivery, maxExtensions int) ([]byte, error) {
+	headers := make(map[string]string, len(d.Headers))
+	for key, value := range d.Headers {
+		if s, ok := value.(string); ok {
+			headers[key] = s
+		}
+	}
+
+	data, _, err := pubsub.FromBinaryMessage(headers, ceHeaderPrefix, d.ContentType, d.Body, maxExtensions)
+	if err != nil {
+		return nil, err
+	}
+
+	return pubsub.DecompressCloudEvent(data)
+}
+
 func (r *rabbitMQ) ensureExchangeDeclared(channel rabbitMQChannelBroker, exchange string) error {
 	if !r.containsExchange(exchange) {
-		r.logger.Debugf("%s declaring exchange '%s' of kind '%s'", logMessagePrefix, exchange, fanoutExchangeKind)
-		err := channel.ExchangeDeclare(exchange, fanoutExchangeKind, true, false, false, false, nil)
+		kind := r.metadata.exchangeKind
+		var args amqp.Table
+		if r.metadata.delayedExchange {
+			kind = delayedExchangeKind
+			args = amqp.Table{argDelayedType: r.metadata.exchangeKind}
+		}
+
+		r.logger.Debugf("%s declaring exchange '%s' of kind '%s'", logMessagePrefix, exchange, kind)
+		err := channel.ExchangeDeclare(exchange, kind, true, false, false, false, args)
 		if err != nil {
 			return err
 		}
@@ -361,6 +664,7 @@ func (r *rabbitMQ) reset() error {
 	conn := r.connection
 	r.connection = nil
 	r.channel = nil
+	r.confirms = nil
 	if len(r.declaredExchanges) > 0 {
 		r.declaredExchanges = make(map[string]bool)
 	}
@@ -383,7 +687,19 @@ func (r *rabbitMQ) Close() error {
 }
 
 func (r *rabbitMQ) Features() []pubsub.Feature {
-	return nil
+	if r.metadata == nil {
+		return nil
+	}
+
+	var features []pubsub.Feature
+	if r.metadata.maxPriority > 0 {
+		features = append(features, pubsub.FeatureMessagePriority)
+	}
+	if r.metadata.delayedExchange {
+		features = append(features, pubsub.FeatureScheduledDelivery)
+	}
+
+	return features
 }
 
 func mustReconnect(channel rabbitMQChannelBroker, err error) bool {
@@ -1,9 +1,12 @@
 package rabbitmq
 
 import (
+	"encoding/json"
 	"errors"
+	"fmt"
 	"testing"
 
+	contrib_metadata "github.com/dapr/components-contrib/metadata"
 	"github.com/dapr/components-contrib/pubsub"
 	"github.com/dapr/dapr/pkg/logger"
 	"github.com/streadway/amqp"
@@ -93,6 +96,340 @@ func TestPublishAndSubscribe(t *testing.T) {
 	assert.Equal(t, "foo bar", lastMessage)
 }
 
+func TestPublishAndSubscribeBinaryContentMode(t *testing.T) {
+	broker := newBroker()
+	pubsubRabbitMQ := newRabbitMQTest(broker)
+	metadata := pubsub.Metadata{
+		Properties: map[string]string{
+			metadataHostKey:               "anyhost",
+			metadataConsumerIDKey:         "consumer",
+			pubsub.ContentModeMetadataKey: pubsub.ContentModeBinary,
+		},
+	}
+	err := pubsubRabbitMQ.Init(metadata)
+	assert.Nil(t, err)
+
+	topic := "binarytopic"
+
+	received := make(chan *pubsub.NewMessage)
+	handler := func(msg *pubsub.NewMessage) error {
+		received <- msg
+
+		return nil
+	}
+
+	err = pubsubRabbitMQ.Subscribe(pubsub.SubscribeRequest{Topic: topic}, handler)
+	assert.Nil(t, err)
+
+	envelope := pubsub.NewCloudEventsEnvelope("a", "", "", "", topic, "mypubsub", "text/plain", []byte("hello world"), "")
+	data, _ := json.Marshal(envelope)
+	err = pubsubRabbitMQ.Publish(&pubsub.PublishRequest{Topic: topic, Data: data})
+	assert.Nil(t, err)
+
+	msg := <-received
+	e, err := pubsub.FromCloudEvent(msg.Data, "")
+	assert.Nil(t, err)
+	assert.Equal(t, "a", e[pubsub.IDField])
+	assert.Equal(t, "text/plain", e[pubsub.DataContentTypeField])
+	assert.Equal(t, "hello world", e[pubsub.DataField])
+
+	err = pubsubRabbitMQ.Publish(&pubsub.PublishRequest{Topic: topic, Data: []byte("hello world")})
+	assert.Error(t, err)
+}
+
+func TestPublishAndSubscribeCompressed(t *testing.T) {
+	broker := newBroker()
+	pubsubRabbitMQ := newRabbitMQTest(broker)
+	metadata := pubsub.Metadata{
+		Properties: map[string]string{
+			metadataHostKey:               "anyhost",
+			metadataConsumerIDKey:         "consumer",
+			pubsub.ContentModeMetadataKey: pubsub.ContentModeBinary,
+			pubsub.CompressionMetadataKey: pubsub.CompressionGzip,
+		},
+	}
+	err := pubsubRabbitMQ.Init(metadata)
+	assert.Nil(t, err)
+
+	topic := "compressedtopic"
+
+	received := make(chan *pubsub.NewMessage)
+	handler := func(msg *pubsub.NewMessage) error {
+		received <- msg
+
+		return nil
+	}
+
+	err = pubsubRabbitMQ.Subscribe(pubsub.SubscribeRequest{Topic: topic}, handler)
+	assert.Nil(t, err)
+
+	envelope := pubsub.NewCloudEventsEnvelope("a", "", "", "", topic, "mypubsub", "text/plain", []byte("hello world"), "")
+	data, _ := json.Marshal(envelope)
+	for _, compression := range []string{"", pubsub.CompressionZstd, "none"} {
+		err = pubsubRabbitMQ.Publish(&pubsub.PublishRequest{Topic: topic, Data: data, Metadata: map[string]string{pubsub.CompressionMetadataKey: compression}})
+		assert.Nil(t, err)
+
+		msg := <-received
+		e, err := pubsub.FromCloudEvent(msg.Data, "")
+		assert.Nil(t, err)
+		assert.Equal(t, "a", e[pubsub.IDField])
+		assert.Equal(t, "hello world", e[pubsub.DataField])
+		assert.NotContains(t, e, pubsub.ContentEncodingField)
+	}
+}
+
+func TestPublishAndSubscribeRawPayload(t *testing.T) {
+	broker := newBroker()
+	pubsubRabbitMQ := newRabbitMQTest(broker)
+	metadata := pubsub.Metadata{
+		Properties: map[string]string{
+			metadataHostKey:               "anyhost",
+			metadataConsumerIDKey:         "consumer",
+			pubsub.ContentModeMetadataKey: pubsub.ContentModeBinary,
+		},
+	}
+	err := pubsubRabbitMQ.Init(metadata)
+	assert.Nil(t, err)
+
+	topic := "rawtopic"
+	rawPayload := map[string]string{contrib_metadata.RawPayloadMetadataKey: "true"}
+
+	received := make(chan *pubsub.NewMessage)
+	handler := func(msg *pubsub.NewMessage) error {
+		received <- msg
+
+		return nil
+	}
+
+	err = pubsubRabbitMQ.Subscribe(pubsub.SubscribeRequest{Topic: topic, Metadata: rawPayload}, handler)
+	assert.Nil(t, err)
+
+	err = pubsubRabbitMQ.Publish(&pubsub.PublishRequest{Topic: topic, Data: []byte("hello world"), Metadata: rawPayload})
+	assert.Nil(t, err)
+
+	msg := <-received
+	assert.Equal(t, "hello world", string(msg.Data))
+
+	err = pubsubRabbitMQ.Subscribe(pubsub.SubscribeRequest{Topic: topic, Metadata: map[string]string{contrib_metadata.RawPayloadMetadataKey: "a"}}, handler)
+	assert.Error(t, err)
+}
+
+func TestSubscribePriorityQueue(t *testing.T) {
+	broker := newBroker()
+	pubsubRabbitMQ := newRabbitMQTest(broker)
+	metadata := pubsub.Metadata{
+		Properties: map[string]string{
+			metadataHostKey:       "anyhost",
+			metadataConsumerIDKey: "consumer",
+			metadataMaxPriority:   "10",
+		},
+	}
+	err := pubsubRabbitMQ.Init(metadata)
+	assert.Nil(t, err)
+	assert.Equal(t, []pubsub.Feature{pubsub.FeatureMessagePriority}, pubsubRabbitMQ.Features())
+
+	_, err = pubsubRabbitMQ.(*rabbitMQ).prepareSubscription(broker, pubsub.SubscribeRequest{Topic: "prioritytopic"}, "consumer-prioritytopic")
+	assert.Nil(t, err)
+	assert.Equal(t, amqp.Table{argMaxPriority: int32(10)}, broker.queueArgs)
+}
+
+func TestSubscribeQuorumQueue(t *testing.T) {
+	broker := newBroker()
+	pubsubRabbitMQ := newRabbitMQTest(broker)
+	metadata := pubsub.Metadata{
+		Properties: map[string]string{
+			metadataHostKey:       "anyhost",
+			metadataConsumerIDKey: "consumer",
+			metadataQueueType:     queueTypeQuorum,
+			"x-delivery-limit":    "5",
+		},
+	}
+	err := pubsubRabbitMQ.Init(metadata)
+	assert.Nil(t, err)
+
+	_, err = pubsubRabbitMQ.(*rabbitMQ).prepareSubscription(broker, pubsub.SubscribeRequest{Topic: "quorumtopic"}, "consumer-quorumtopic")
+	assert.Nil(t, err)
+	assert.Equal(t, amqp.Table{argQueueType: queueTypeQuorum, "x-delivery-limit": int64(5)}, broker.queueArgs)
+}
+
+func TestSubscribeRoutingKeys(t *testing.T) {
+	for _, tt := range []struct {
+		kind     string
+		metadata map[string]string
+		keys     []string
+		args     amqp.Table
+	}{
+		{fanoutExchangeKind, map[string]string{}, []string{""}, nil},
+		{topicExchangeKind, map[string]string{}, []string{"#"}, nil},
+		{topicExchangeKind, map[string]string{metadataRoutingKey: "orders.*.eu, orders.#"}, []string{"orders.*.eu", "orders.#"}, nil},
+		{directExchangeKind, map[string]string{metadataRoutingKey: "eu"}, []string{"eu"}, nil},
+		{headersExchangeKind, map[string]string{"header.region": "eu", argMatch: "any"}, []string{""}, amqp.Table{"region": "eu", argMatch: "any"}},
+	} {
+		tt := tt
+		t.Run(fmt.Sprintf("%s %v", tt.kind, tt.metadata), func(t *testing.T) {
+			broker := newBroker()
+			pubsubRabbitMQ := newRabbitMQTest(broker)
+			metadata := pubsub.Metadata{
+				Properties: map[string]string{
+					metadataHostKey:       "anyhost",
+					metadataConsumerIDKey: "consumer",
+					metadataExchangeKind:  tt.kind,
+				},
+			}
+			err := pubsubRabbitMQ.Init(metadata)
+			assert.Nil(t, err)
+
+			_, err = pubsubRabbitMQ.(*rabbitMQ).prepareSubscription(broker, pubsub.SubscribeRequest{Topic: "orders", Metadata: tt.metadata}, "consumer-orders")
+			assert.Nil(t, err)
+			assert.Equal(t, tt.kind, broker.exchangeKind)
+			assert.Equal(t, tt.keys, broker.bindingKeys)
+			assert.Equal(t, tt.args, broker.bindingArgs)
+		})
+	}
+
+	t.Run("routing keys require an exchange kind", func(t *testing.T) {
+		broker := newBroker()
+		pubsubRabbitMQ := newRabbitMQTest(broker)
+		err := pubsubRabbitMQ.Init(pubsub.Metadata{Properties: map[string]string{metadataHostKey: "anyhost", metadataConsumerIDKey: "consumer"}})
+		assert.Nil(t, err)
+
+		err = pubsubRabbitMQ.Subscribe(pubsub.SubscribeRequest{Topic: "orders", Metadata: map[string]string{metadataRoutingKey: "eu"}}, func(msg *pubsub.NewMessage) error { return nil })
+		assert.Error(t, err)
+	})
+}
+
+func TestPublishRoutingKey(t *testing.T) {
+	broker := &rabbitMQInMemoryBroker{
+		buffer: make(chan amqp.Delivery, 1),
+	}
+	pubsubRabbitMQ := newRabbitMQTest(broker)
+	metadata := pubsub.Metadata{
+		Properties: map[string]string{
+			metadataHostKey:       "anyhost",
+			metadataConsumerIDKey: "consumer",
+			metadataExchangeKind:  topicExchangeKind,
+		},
+	}
+	err := pubsubRabbitMQ.Init(metadata)
+	assert.Nil(t, err)
+
+	err = pubsubRabbitMQ.Publish(&pubsub.PublishRequest{
+		Topic:    "orders",
+		Data:     []byte("hello world"),
+		Metadata: map[string]string{metadataRoutingKey: "orders.created.eu", "header.region": "eu"},
+	})
+	assert.Nil(t, err)
+
+	delivery := <-broker.buffer
+	assert.Equal(t, "orders.created.eu", delivery.RoutingKey)
+	assert.Equal(t, amqp.Table{"region": "eu"}, delivery.Headers)
+	assert.Equal(t, topicExchangeKind, broker.exchangeKind)
+}
+
+func TestPublishPriority(t *testing.T) {
+	broker := newBroker()
+	pubsubRabbitMQ := newRabbitMQTest(broker)
+	metadata := pubsub.Metadata{
+		Properties: map[string]string{
+			metadataHostKey:       "anyhost",
+			metadataConsumerIDKey: "consumer",
+			metadataMaxPriority:   "10",
+		},
+	}
+	err := pubsubRabbitMQ.Init(metadata)
+	assert.Nil(t, err)
+
+	// no subscriber, read the published message from the broker to inspect its properties
+	published := make(chan error)
+	go func() {
+		published <- pubsubRabbitMQ.Publish(&pubsub.PublishRequest{Topic: "prioritytopic", Data: []byte("hello world"), Metadata: map[string]string{contrib_metadata.PriorityMetadataKey: "5"}})
+	}()
+	delivery := <-broker.buffer
+	assert.Nil(t, <-published)
+	assert.Equal(t, uint8(5), delivery.Priority)
+
+	err = pubsubRabbitMQ.Publish(&pubsub.PublishRequest{Topic: "prioritytopic", Data: []byte("hello world"), Metadata: map[string]string{contrib_metadata.PriorityMetadataKey: "256"}})
+	assert.Error(t, err)
+}
+
+func TestPublishScheduledDelivery(t *testing.T) {
+	broker := newBroker()
+	pubsubRabbitMQ := newRabbitMQTest(broker)
+	metadata := pubsub.Metadata{
+		Properties: map[string]string{
+			metadataHostKey:         "anyhost",
+			metadataConsumerIDKey:   "consumer",
+			metadataDelayedExchange: "true",
+		},
+	}
+	err := pubsubRabbitMQ.Init(metadata)
+	assert.Nil(t, err)
+	assert.Equal(t, []pubsub.Feature{pubsub.FeatureScheduledDelivery}, pubsubRabbitMQ.Features())
+
+	// no subscriber, read the published message from the broker to inspect its properties
+	published := make(chan error)
+	go func() {
+		published <- pubsubRabbitMQ.Publish(&pubsub.PublishRequest{Topic: "delayedtopic", Data: []byte("hello world"), Metadata: map[string]string{contrib_metadata.DelaySecondsMetadataKey: "60"}})
+	}()
+	delivery := <-broker.buffer
+	assert.Nil(t, <-published)
+	assert.Equal(t, delayedExchangeKind, broker.exchangeKind)
+	assert.Equal(t, amqp.Table{argDelayedType: fanoutExchangeKind}, broker.exchangeArgs)
+	assert.InDelta(t, 60000, delivery.Headers[headerDelay], 1000)
+}
+
+func TestPublishScheduledDeliveryWithoutDelayedExchange(t *testing.T) {
+	broker := newBroker()
+	pubsubRabbitMQ := newRabbitMQTest(broker)
+	metadata := pubsub.Metadata{
+		Properties: map[string]string{
+			metadataHostKey:       "anyhost",
+			metadataConsumerIDKey: "consumer",
+		},
+	}
+	err := pubsubRabbitMQ.Init(metadata)
+	assert.Nil(t, err)
+	assert.Nil(t, pubsubRabbitMQ.Features())
+
+	err = pubsubRabbitMQ.Publish(&pubsub.PublishRequest{Topic: "delayedtopic", Data: []byte("hello world"), Metadata: map[string]string{contrib_metadata.DelaySecondsMetadataKey: "60"}})
+	assert.Error(t, err)
+}
+
+func TestDeadLetter(t *testing.T) {
+	broker := newBroker()
+	pubsubRabbitMQ := newRabbitMQTest(broker)
+	metadata := pubsub.Metadata{
+		Properties: map[string]string{
+			metadataHostKey:                       "anyhost",
+			metadataConsumerIDKey:                 "consumer",
+			metadataRequeueInFailureKey:           "true",
+			pubsub.DeadLetterTopicMetadataKey:     "poison",
+			pubsub.MaxDeliveryAttemptsMetadataKey: "2",
+		},
+	}
+	err := pubsubRabbitMQ.Init(metadata)
+	assert.Nil(t, err)
+
+	r := pubsubRabbitMQ.(*rabbitMQ)
+	_, err = r.prepareSubscription(broker, pubsub.SubscribeRequest{Topic: "mytopic"}, "consumer-mytopic")
+	assert.Nil(t, err)
+	assert.Equal(t, amqp.Table{argDeadLetterExchange: "poison"}, broker.queueArgs)
+	assert.True(t, r.containsExchange("poison"))
+
+	attempts := 0
+	handler := func(msg *pubsub.NewMessage) error {
+		attempts++
+
+		return errors.New("failed")
+	}
+
+	err = r.handleMessage(broker, createAMQPMessage([]byte("hello world")), "mytopic", true, handler)
+	assert.Nil(t, err)
+	assert.Equal(t, 2, attempts)
+	// rejected without requeue so that the broker routes it to the dead letter exchange
+	assert.Equal(t, []bool{false}, broker.nackRequeue)
+}
+
 func TestPublishReconnect(t *testing.T) {
 	broker := newBroker()
 	pubsubRabbitMQ := newRabbitMQTest(broker)
@@ -144,6 +481,43 @@ func TestPublishReconnect(t *testing.T) {
 	assert.Equal(t, "foo bar", lastMessage)
 }
 
+func TestPublishConfirm(t *testing.T) {
+	broker := &rabbitMQInMemoryBroker{
+		buffer: make(chan amqp.Delivery, 3),
+	}
+	pubsubRabbitMQ := newRabbitMQTest(broker)
+	metadata := pubsub.Metadata{
+		Properties: map[string]string{
+			metadataHostKey:                 "anyhost",
+			metadataConsumerIDKey:           "consumer",
+			metadataPublisherConfirm:        "true",
+			metadataPublisherConfirmTimeout: "50ms",
+		},
+	}
+	err := pubsubRabbitMQ.Init(metadata)
+	assert.Nil(t, err)
+
+	topic := "confirmtopic"
+
+	// acknowledged
+	err = pubsubRabbitMQ.Publish(&pubsub.PublishRequest{Topic: topic, Data: []byte("hello world")})
+	assert.Nil(t, err)
+	assert.Equal(t, uint64(1), broker.confirmCount)
+
+	// rejected
+	broker.nackPublishes = true
+	err = pubsubRabbitMQ.Publish(&pubsub.PublishRequest{Topic: topic, Data: []byte("hello world")})
+	assert.Error(t, err)
+	assert.Equal(t, 1, broker.connectCount)
+
+	// not confirmed in time, the channel is re-established
+	broker.withholdConfirms = true
+	err = pubsubRabbitMQ.Publish(&pubsub.PublishRequest{Topic: topic, Data: []byte("hello world")})
+	assert.Error(t, err)
+	assert.Equal(t, 2, broker.connectCount)
+	assert.Equal(t, 1, broker.closeCount)
+}
+
 func TestPublishReconnectAfterClose(t *testing.T) {
 	broker := newBroker()
 	pubsubRabbitMQ := newRabbitMQTest(broker)
@@ -252,6 +626,28 @@ type rabbitMQInMemoryBroker struct {
 
 	connectCount int
 	closeCount   int
+	queueArgs    amqp.Table
+	exchangeKind string
+	exchangeArgs amqp.Table
+	bindingKeys  []string
+	bindingArgs  amqp.Table
+	nackRequeue  []bool
+
+	// confirms receives the publisher confirms in confirm mode, unless they are withheld
+	confirms         chan amqp.Confirmation
+	confirmCount     uint64
+	nackPublishes    bool
+	withholdConfirms bool
+}
+
+func (r *rabbitMQInMemoryBroker) Confirm(noWait bool) error {
+	return nil
+}
+
+func (r *rabbitMQInMemoryBroker) NotifyPublish(confirm chan amqp.Confirmation) chan amqp.Confirmation {
+	r.confirms = confirm
+
+	return confirm
 }
 
 func (r *rabbitMQInMemoryBroker) Qos(prefetchCount, prefetchSize int, global bool) error {
@@ -263,16 +659,31 @@ func (r *rabbitMQInMemoryBroker) Publish(exchange string, key string, mandatory
 		return errors.New(errorChannelConnection)
 	}
 
-	r.buffer <- createAMQPMessage(msg.Body)
+	delivery := createAMQPMessage(msg.Body)
+	delivery.Headers = msg.Headers
+	delivery.ContentType = msg.ContentType
+	delivery.Priority = msg.Priority
+	delivery.RoutingKey = key
+	r.buffer <- delivery
+
+	if r.confirms != nil && !r.withholdConfirms {
+		r.confirmCount++
+		r.confirms <- amqp.Confirmation{DeliveryTag: r.confirmCount, Ack: !r.nackPublishes}
+	}
 
 	return nil
 }
 
 func (r *rabbitMQInMemoryBroker) QueueDeclare(name string, durable bool, autoDelete bool, exclusive bool, noWait bool, args amqp.Table) (amqp.Queue, error) {
+	r.queueArgs = args
+
 	return amqp.Queue{Name: name}, nil
 }
 
 func (r *rabbitMQInMemoryBroker) QueueBind(name string, key string, exchange string, noWait bool, args amqp.Table) error {
+	r.bindingKeys = append(r.bindingKeys, key)
+	r.bindingArgs = args
+
 	return nil
 }
 
@@ -281,6 +692,8 @@ func (r *rabbitMQInMemoryBroker) Consume(queue string, consumer string, autoAck
 }
 
 func (r *rabbitMQInMemoryBroker) Nack(tag uint64, multiple bool, requeue bool) error {
+	r.nackRequeue = append(r.nackRequeue, requeue)
+
 	return nil
 }
 
@@ -289,6 +702,9 @@ func (r *rabbitMQInMemoryBroker) Ack(tag uint64, multiple bool) error {
 }
 
 func (r *rabbitMQInMemoryBroker) ExchangeDeclare(name string, kind string, durable bool, autoDelete bool, internal bool, noWait bool, args amqp.Table) error {
+	r.exchangeKind = kind
+	r.exchangeArgs = args
+
 	return nil
 }
 
@@ -5,9 +5,25 @@
 
 package redis
 
+import (
+	"time"
+
+	"github.com/dapr/components-contrib/pubsub"
+)
+
 type metadata struct {
-	host       string
-	password   string
-	consumerID string
-	enableTLS  bool
+	// host is the address of the node, or the comma separated addresses of the cluster nodes or sentinels
+	host               string
+	username           string
+	password           string
+	consumerID         string
+	enableTLS          bool
+	redisType          string
+	failover           bool
+	sentinelMasterName string
+	// redeliverInterval is the interval between the reclaims of the pending messages, none are reclaimed when zero
+	redeliverInterval time.Duration
+	// processingTimeout is how long a message is pending before it is reclaimed
+	processingTimeout time.Duration
+	deadLetter        pubsub.DeadLetterConfig
 }
@@ -0,0 +1,116 @@
+// ------------------------------------------------------------
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT License.
+// ------------------------------------------------------------
+
+package redis
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/dapr/components-contrib/pubsub"
+	"github.com/go-redis/redis/v7"
+)
+
+// reclaimPendingMessages periodically claims the messages of the stream delivered to a consumer of the group
+// longer than the processing timeout ago and not acknowledged yet, e.g. because the consumer crashed,
+// and handles them again. It returns once the client is closed.
+func (r *redisStreams) reclaimPendingMessages(stream, consumerID string, handler func(msg *pubsub.NewMessage) error) {
+	for {
+		time.Sleep(r.metadata.redeliverInterval)
+
+		err := r.reclaim(stream, consumerID, handler)
+		if err == redis.ErrClosed {
+			return
+		}
+		if err != nil {
+			r.logger.Errorf("redis streams: error reclaiming the pending messages of stream %s: %s", stream, err)
+		}
+	}
+}
+
+// reclaim claims the pending messages of the stream idle for longer than the processing timeout with XAUTOCLAIM,
+// which requires Redis 6.2, and handles them.
+func (r *redisStreams) reclaim(stream, consumerID string, handler func(msg *pubsub.NewMessage) error) error {
+	minIdle := int64(r.metadata.processingTimeout / time.Millisecond)
+	start := "0-0"
+	for {
+		res, err := r.client.Do("XAUTOCLAIM", stream, consumerID, r.consumerName, minIdle, start).Result()
+		if err != nil {
+			return err
+		}
+
+		next, messages, deleted, err := parseAutoClaim(res)
+		if err != nil {
+			return err
+		}
+
+		if len(deleted) > 0 {
+			// the messages deleted from the stream are claimed with no values, they can only be acknowledged
+			// (Redis 7 removes them from the pending list by itself)
+			if err = r.client.XAck(stream, consumerID, deleted...).Err(); err != nil {
+				return err
+			}
+		}
+		if len(messages) > 0 {
+			r.logger.Debugf("redis streams: reclaimed %d pending messages of stream %s", len(messages), stream)
+			r.processStreams(consumerID, []redis.XStream{{Stream: stream, Messages: messages}}, handler)
+		}
+
+		if next == "0-0" {
+			return nil
+		}
+		start = next
+	}
+}
+
+// parseAutoClaim parses the reply of XAUTOCLAIM: the cursor of the next call, "0-0" once the pending list is scanned,
+// the claimed messages, and the IDs of those deleted from the stream.
+func parseAutoClaim(res interface{}) (string, []redis.XMessage, []string, error) {
+	reply, ok := res.([]interface{})
+	if !ok || len(reply) < 2 {
+		return "", nil, nil, fmt.Errorf("unexpected XAUTOCLAIM reply %v", res)
+	}
+
+	next, ok := reply[0].(string)
+	if !ok {
+		return "", nil, nil, fmt.Errorf("unexpected XAUTOCLAIM cursor %v", reply[0])
+	}
+
+	entries, ok := reply[1].([]interface{})
+	if !ok {
+		return "", nil, nil, fmt.Errorf("unexpected XAUTOCLAIM entries %v", reply[1])
+	}
+
+	var messages []redis.XMessage
+	var deleted []string
+	for _, e := range entries {
+		entry, ok := e.([]interface{})
+		if !ok || len(entry) != 2 {
+			return "", nil, nil, fmt.Errorf("unexpected XAUTOCLAIM entry %v", e)
+		}
+		id, ok := entry[0].(string)
+		if !ok {
+			return "", nil, nil, fmt.Errorf("unexpected XAUTOCLAIM entry ID %v", entry[0])
+		}
+		if entry[1] == nil {
+			deleted = append(deleted, id)
+
+			continue
+		}
+
+		fields, ok := entry[1].([]interface{})
+		if !ok || len(fields)%2 != 0 {
+			return "", nil, nil, fmt.Errorf("unexpected XAUTOCLAIM entry values %v", entry[1])
+		}
+		values := make(map[string]interface{}, len(fields)/2)
+		for i := 0; i < len(fields); i += 2 {
+			key, _ := fields[i].(string)
+			values[key] = fields[i+1]
+		}
+		messages = append(messages, redis.XMessage{ID: id, Values: values})
+	}
+
+	return next, messages, deleted, nil
+}
@@ -9,24 +9,47 @@ import (
 	"crypto/tls"
 	"errors"
 	"fmt"
+	"os"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/dapr/components-contrib/pubsub"
 	"github.com/dapr/dapr/pkg/logger"
 	"github.com/go-redis/redis/v7"
+	"github.com/google/uuid"
 )
 
 const (
-	host       = "redisHost"
-	password   = "redisPassword"
-	consumerID = "consumerID"
-	enableTLS  = "enableTLS"
+	host               = "redisHost"
+	username           = "redisUsername"
+	password           = "redisPassword"
+	consumerID         = "consumerID"
+	enableTLS          = "enableTLS"
+	redisType          = "redisType"
+	failover           = "failover"
+	sentinelMasterName = "sentinelMasterName"
+	redeliverInterval  = "redeliverInterval"
+	processingTimeout  = "processingTimeout"
+
+	// redisTypeNode connects to a single node, or to the master of the sentinels with failover
+	redisTypeNode = "node"
+	// redisTypeCluster connects to a Redis Cluster, following the redirections to the nodes owning the streams
+	redisTypeCluster = "cluster"
+
+	// readRetryInterval is the interval between the reads from a stream failing, e.g. during a failover
+	readRetryInterval = time.Second * 2
+
+	defaultRedeliverInterval = time.Minute
+	defaultProcessingTimeout = time.Minute
 )
 
 type redisStreams struct {
 	metadata metadata
-	client   *redis.Client
+	client   redis.UniversalClient
+	// consumerName is the name of the instance in the consumer group, the messages pending for it are reclaimed
+	// by the other instances when it crashes
+	consumerName string
 
 	logger logger.Logger
 }
@@ -44,6 +67,10 @@ func parseRedisMetadata(meta pubsub.Metadata) (metadata, error) {
 		return m, errors.New("redis streams error: missing host address")
 	}
 
+	if val, ok := meta.Properties[username]; ok && val != "" {
+		m.username = val
+	}
+
 	if val, ok := meta.Properties[password]; ok && val != "" {
 		m.password = val
 	}
@@ -56,12 +83,67 @@ func parseRedisMetadata(meta pubsub.Metadata) (metadata, error) {
 		m.enableTLS = tls
 	}
 
+	m.redisType = redisTypeNode
+	if val, ok := meta.Properties[redisType]; ok && val != "" {
+		if val != redisTypeNode && val != redisTypeCluster {
+			return m, fmt.Errorf("redis streams error: invalid redisType %s, expected %s or %s", val, redisTypeNode, redisTypeCluster)
+		}
+		m.redisType = val
+	}
+
+	if val, ok := meta.Properties[failover]; ok && val != "" {
+		failover, err := strconv.ParseBool(val)
+		if err != nil {
+			return m, fmt.Errorf("redis streams error: can't parse failover field: %s", err)
+		}
+		m.failover = failover
+	}
+
+	// set the sentinelMasterName only with failover == true.
+	if m.failover {
+		if m.redisType == redisTypeCluster {
+			return m, errors.New("redis streams error: failover is not supported with cluster, the cluster fails over by itself")
+		}
+		if val, ok := meta.Properties[sentinelMasterName]; ok && val != "" {
+			m.sentinelMasterName = val
+		} else {
+			return m, errors.New("redis streams error: missing sentinelMasterName")
+		}
+	}
+
+	m.redeliverInterval = defaultRedeliverInterval
+	if val, ok := meta.Properties[redeliverInterval]; ok && val != "" {
+		d, err := parseDuration(val)
+		if err != nil {
+			return m, fmt.Errorf("redis streams error: can't parse redeliverInterval field: %s", err)
+		}
+		m.redeliverInterval = d
+	}
+
+	m.processingTimeout = defaultProcessingTimeout
+	if val, ok := meta.Properties[processingTimeout]; ok && val != "" {
+		d, err := parseDuration(val)
+		if err != nil {
+			return m, fmt.Errorf("redis streams error: can't parse processingTimeout field: %s", err)
+		}
+		if d <= 0 {
+			return m, errors.New("redis streams error: processingTimeout must be positive")
+		}
+		m.processingTimeout = d
+	}
+
 	if val, ok := meta.Properties[consumerID]; ok && val != "" {
 		m.consumerID = val
 	} else {
 		return m, errors.New("redis streams error: missing consumerID")
 	}
 
+	deadLetter, err := pubsub.ParseDeadLetterConfig(meta.Properties)
+	if err != nil {
+		return m, fmt.Errorf("redis streams error: %s", err)
+	}
+	m.deadLetter = deadLetter
+
 	return m, nil
 }
 
@@ -72,22 +154,7 @@ func (r *redisStreams) Init(metadata pubsub.Metadata) error {
 	}
 	r.metadata = m
 
-	options := &redis.Options{
-		Addr:            m.host,
-		Password:        m.password,
-		DB:              0,
-		MaxRetries:      3,
-		MaxRetryBackoff: time.Second * 2,
-	}
-
-	/* #nosec */
-	if r.metadata.enableTLS {
-		options.TLSConfig = &tls.Config{
-			InsecureSkipVerify: r.metadata.enableTLS,
-		}
-	}
-
-	client := redis.NewClient(options)
+	client := newClient(m)
 
 	_, err = client.Ping().Result()
 	if err != nil {
@@ -96,9 +163,83 @@ func (r *redisStreams) Init(metadata pubsub.Metadata) error {
 
 	r.client = client
 
+	// the instances of the consumer group are told apart by their host name
+	r.consumerName, err = os.Hostname()
+	if err != nil || r.consumerName == "" {
+		r.consumerName = uuid.New().String()
+	}
+
 	return nil
 }
 
+// parseDuration parses a Go duration, or a number of milliseconds
+func parseDuration(val string) (time.Duration, error) {
+	if ms, err := strconv.Atoi(val); err == nil {
+		return time.Duration(ms) * time.Millisecond, nil
+	}
+
+	return time.ParseDuration(val)
+}
+
+// newClient returns the client of the node, of the master of the sentinels with failover, or of the cluster
+func newClient(m metadata) redis.UniversalClient {
+	addrs := strings.Split(m.host, ",")
+	for i := range addrs {
+		addrs[i] = strings.TrimSpace(addrs[i])
+	}
+
+	var tlsConfig *tls.Config
+	/* #nosec */
+	if m.enableTLS {
+		tlsConfig = &tls.Config{
+			InsecureSkipVerify: m.enableTLS,
+		}
+	}
+
+	// the password alone is sent on connection by the client, the AUTH command with the username of the ACL is sent then
+	pass := m.password
+	var onConnect func(*redis.Conn) error
+	if m.username != "" {
+		pass = ""
+		onConnect = func(conn *redis.Conn) error {
+			return conn.Process(redis.NewStatusCmd("auth", m.username, m.password))
+		}
+	}
+
+	switch {
+	case m.redisType == redisTypeCluster:
+		return redis.NewClusterClient(&redis.ClusterOptions{
+			Addrs:           addrs,
+			Password:        pass,
+			OnConnect:       onConnect,
+			MaxRetries:      3,
+			MaxRetryBackoff: time.Second * 2,
+			TLSConfig:       tlsConfig,
+		})
+	case m.failover:
+		return redis.NewFailoverClient(&redis.FailoverOptions{
+			MasterName:      m.sentinelMasterName,
+			SentinelAddrs:   addrs,
+			Password:        pass,
+			OnConnect:       onConnect,
+			DB:              0,
+			MaxRetries:      3,
+			MaxRetryBackoff: time.Second * 2,
+			TLSConfig:       tlsConfig,
+		})
+	default:
+		return redis.NewClient(&redis.Options{
+			Addr:            addrs[0],
+			Password:        pass,
+			OnConnect:       onConnect,
+			DB:              0,
+			MaxRetries:      3,
+			MaxRetryBackoff: time.Second * 2,
+			TLSConfig:       tlsConfig,
+		})
+	}
+}
+
 func (r *redisStreams) Publish(req *pubsub.PublishRequest) error {
 	_, err := r.client.XAdd(&redis.XAddArgs{
 		Stream: req.Topic,
@@ -117,6 +258,9 @@ func (r *redisStreams) Subscribe(req pubsub.SubscribeRequest, handler func(msg *
 		r.logger.Warnf("redis streams: %s", err)
 	}
 	go r.beginReadingFromStream(req.Topic, r.metadata.consumerID, handler)
+	if r.metadata.redeliverInterval > 0 {
+		go r.reclaimPendingMessages(req.Topic, r.metadata.consumerID, handler)
+	}
 
 	return nil
 }
@@ -124,7 +268,7 @@ func (r *redisStreams) Subscribe(req pubsub.SubscribeRequest, handler func(msg *
 func (r *redisStreams) readFromStream(stream, consumerID, start string) ([]redis.XStream, error) {
 	res, err := r.client.XReadGroup(&redis.XReadGroupArgs{
 		Group:    consumerID,
-		Consumer: consumerID,
+		Consumer: r.consumerName,
 		Streams:  []string{stream, start},
 		Block:    0,
 	}).Result()
@@ -147,7 +291,13 @@ func (r *redisStreams) processStreams(consumerID string, streams []redis.XStream
 					msg.Data = []byte(data.(string))
 				}
 
-				err := handler(&msg)
+				err := r.metadata.deadLetter.Deliver(&msg, handler)
+				if err != nil && r.metadata.deadLetter.Enabled() {
+					err = r.metadata.deadLetter.DeadLetter(r, &msg)
+					if err != nil {
+						r.logger.Errorf("redis streams: %s", err)
+					}
+				}
 				if err == nil {
 					r.client.XAck(stream, consumerID, message.ID).Result()
 				}
@@ -162,10 +312,24 @@ func (r *redisStreams) beginReadingFromStream(stream, consumerID string, handler
 
 	for {
 		streams, err := r.readFromStream(stream, consumerID, start)
+		if err == redis.ErrClosed {
+			return
+		}
 		if err != nil {
+			// the connection is lost while the master fails over, the client connects to the new master on the next read.
+			// The consumer group is created again when the new master lost it.
 			r.logger.Errorf("redis streams: error reading from stream %s: %s", stream, err)
+			if strings.HasPrefix(err.Error(), "NOGROUP") {
+				if err = r.client.XGroupCreateMkStream(stream, consumerID, "0").Err(); err != nil {
+					r.logger.Warnf("redis streams: %s", err)
+				}
+			}
+			time.Sleep(readRetryInterval)
 
-			return
+			// read the pending items again, those delivered before the failure are not acknowledged yet
+			start = "0"
+
+			continue
 		}
 		r.processStreams(consumerID, streams, handler)
 
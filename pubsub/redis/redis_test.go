@@ -63,6 +63,156 @@ func TestParseRedisMetadata(t *testing.T) {
 		assert.Empty(t, m.consumerID)
 	})
 
+	t.Run("dead letter topic is given", func(t *testing.T) {
+		fakeProperties := getFakeProperties()
+
+		fakeMetaData := pubsub.Metadata{
+			Properties: fakeProperties,
+		}
+		fakeMetaData.Properties[pubsub.DeadLetterTopicMetadataKey] = "poison"
+		fakeMetaData.Properties[pubsub.MaxDeliveryAttemptsMetadataKey] = "5"
+
+		// act
+		m, err := parseRedisMetadata(fakeMetaData)
+
+		// assert
+		assert.NoError(t, err)
+		assert.Equal(t, pubsub.DeadLetterConfig{Topic: "poison", MaxDeliveryAttempts: 5}, m.deadLetter)
+	})
+
+	t.Run("cluster and ACL username are given", func(t *testing.T) {
+		fakeProperties := getFakeProperties()
+
+		fakeMetaData := pubsub.Metadata{
+			Properties: fakeProperties,
+		}
+		fakeMetaData.Properties[host] = "node1.redis.com:6379,node2.redis.com:6379"
+		fakeMetaData.Properties[redisType] = redisTypeCluster
+		fakeMetaData.Properties[username] = "fakeUser"
+
+		// act
+		m, err := parseRedisMetadata(fakeMetaData)
+
+		// assert
+		assert.NoError(t, err)
+		assert.Equal(t, redisTypeCluster, m.redisType)
+		assert.Equal(t, "fakeUser", m.username)
+		assert.False(t, m.failover)
+	})
+
+	t.Run("failover is given", func(t *testing.T) {
+		fakeProperties := getFakeProperties()
+
+		fakeMetaData := pubsub.Metadata{
+			Properties: fakeProperties,
+		}
+		fakeMetaData.Properties[failover] = "true"
+		fakeMetaData.Properties[sentinelMasterName] = "master"
+
+		// act
+		m, err := parseRedisMetadata(fakeMetaData)
+
+		// assert
+		assert.NoError(t, err)
+		assert.Equal(t, redisTypeNode, m.redisType)
+		assert.True(t, m.failover)
+		assert.Equal(t, "master", m.sentinelMasterName)
+	})
+
+	t.Run("failover without sentinelMasterName", func(t *testing.T) {
+		fakeProperties := getFakeProperties()
+
+		fakeMetaData := pubsub.Metadata{
+			Properties: fakeProperties,
+		}
+		fakeMetaData.Properties[failover] = "true"
+
+		// act
+		_, err := parseRedisMetadata(fakeMetaData)
+
+		// assert
+		assert.Error(t, err)
+	})
+
+	t.Run("failover with cluster", func(t *testing.T) {
+		fakeProperties := getFakeProperties()
+
+		fakeMetaData := pubsub.Metadata{
+			Properties: fakeProperties,
+		}
+		fakeMetaData.Properties[redisType] = redisTypeCluster
+		fakeMetaData.Properties[failover] = "true"
+		fakeMetaData.Properties[sentinelMasterName] = "master"
+
+		// act
+		_, err := parseRedisMetadata(fakeMetaData)
+
+		// assert
+		assert.Error(t, err)
+	})
+
+	t.Run("invalid redisType", func(t *testing.T) {
+		fakeProperties := getFakeProperties()
+
+		fakeMetaData := pubsub.Metadata{
+			Properties: fakeProperties,
+		}
+		fakeMetaData.Properties[redisType] = "replicas"
+
+		// act
+		_, err := parseRedisMetadata(fakeMetaData)
+
+		// assert
+		assert.Error(t, err)
+	})
+
+	t.Run("default redelivery", func(t *testing.T) {
+		fakeMetaData := pubsub.Metadata{
+			Properties: getFakeProperties(),
+		}
+
+		// act
+		m, err := parseRedisMetadata(fakeMetaData)
+
+		// assert
+		assert.NoError(t, err)
+		assert.Equal(t, defaultRedeliverInterval, m.redeliverInterval)
+		assert.Equal(t, defaultProcessingTimeout, m.processingTimeout)
+	})
+
+	t.Run("redelivery is given", func(t *testing.T) {
+		fakeProperties := getFakeProperties()
+
+		fakeMetaData := pubsub.Metadata{
+			Properties: fakeProperties,
+		}
+		fakeMetaData.Properties[redeliverInterval] = "0"
+		fakeMetaData.Properties[processingTimeout] = "5m"
+
+		// act
+		m, err := parseRedisMetadata(fakeMetaData)
+
+		// assert
+		assert.NoError(t, err)
+		assert.Equal(t, time.Duration(0), m.redeliverInterval)
+		assert.Equal(t, 5*time.Minute, m.processingTimeout)
+	})
+
+	t.Run("invalid processingTimeout", func(t *testing.T) {
+		fakeProperties := getFakeProperties()
+
+		fakeMetaData := pubsub.Metadata{
+			Properties: fakeProperties,
+		}
+		fakeMetaData.Properties[processingTimeout] = "0"
+
+		// act
+		_, err := parseRedisMetadata(fakeMetaData)
+
+		// assert
+		assert.Error(t, err)
+	})
+
 	t.Run("consumerID is not given", func(t *testing.T) {
 		fakeProperties := getFakeProperties()
 
@@ -81,6 +231,29 @@ func TestParseRedisMetadata(t *testing.T) {
 	})
 }
 
+func TestNewClient(t *testing.T) {
+	t.Run("node", func(t *testing.T) {
+		client := newClient(metadata{host: "fake.redis.com:6379", redisType: redisTypeNode})
+		defer client.Close()
+
+		assert.IsType(t, &redis.Client{}, client)
+	})
+
+	t.Run("cluster", func(t *testing.T) {
+		client := newClient(metadata{host: "node1.redis.com:6379, node2.redis.com:6379", redisType: redisTypeCluster})
+		defer client.Close()
+
+		assert.IsType(t, &redis.ClusterClient{}, client)
+	})
+
+	t.Run("failover", func(t *testing.T) {
+		client := newClient(metadata{host: "sentinel.redis.com:26379", redisType: redisTypeNode, failover: true, sentinelMasterName: "master"})
+		defer client.Close()
+
+		assert.IsType(t, &redis.Client{}, client)
+	})
+}
+
 func TestProcessStreams(t *testing.T) {
 	fakeConsumerID := "fakeConsumer"
 	topicCount := 0
@@ -113,6 +286,70 @@ func TestProcessStreams(t *testing.T) {
 	assert.Equal(t, 3, messageCount)
 }
 
+func TestProcessStreamsMaxDeliveryAttempts(t *testing.T) {
+	attempts := 0
+	fakeHandler := func(msg *pubsub.NewMessage) error {
+		attempts++
+
+		// return fake error to skip executing redis client command
+		return errors.New("fake error")
+	}
+
+	// act
+	testRedisStream := &redisStreams{
+		logger:   logger.NewLogger("test"),
+		metadata: metadata{deadLetter: pubsub.DeadLetterConfig{MaxDeliveryAttempts: 3}},
+	}
+	testRedisStream.processStreams("fakeConsumer", generateRedisStreamTestData(1, 1, "testData"), fakeHandler)
+
+	// sleep for 10ms to give time to finish processing
+	time.Sleep(time.Millisecond * 10)
+
+	// assert
+	assert.Equal(t, 3, attempts)
+}
+
+func TestParseAutoClaim(t *testing.T) {
+	t.Run("claimed and deleted messages", func(t *testing.T) {
+		res := []interface{}{
+			"1-5",
+			[]interface{}{
+				[]interface{}{"1-1", []interface{}{"data", "a"}},
+				[]interface{}{"1-2", nil},
+			},
+		}
+
+		// act
+		next, messages, deleted, err := parseAutoClaim(res)
+
+		// assert
+		assert.NoError(t, err)
+		assert.Equal(t, "1-5", next)
+		assert.Equal(t, []redis.XMessage{{ID: "1-1", Values: map[string]interface{}{"data": "a"}}}, messages)
+		assert.Equal(t, []string{"1-2"}, deleted)
+	})
+
+	t.Run("redis 7 reply", func(t *testing.T) {
+		res := []interface{}{"0-0", []interface{}{}, []interface{}{"1-3"}}
+
+		// act
+		next, messages, deleted, err := parseAutoClaim(res)
+
+		// assert
+		assert.NoError(t, err)
+		assert.Equal(t, "0-0", next)
+		assert.Empty(t, messages)
+		assert.Empty(t, deleted)
+	})
+
+	t.Run("unexpected reply", func(t *testing.T) {
+		_, _, _, err := parseAutoClaim("OK")
+
+		// assert
+		assert.Error(t, err)
+	})
+}
+
 func generateRedisStreamTestData(topicCount, messageCount int, data string) []redis.XStream {
 	generateXMessage := func(id int) redis.XMessage {
 		return redis.XMessage{
@@ -25,3 +25,25 @@ type NewMessage struct {
 	Topic    string            `json:"topic"`
 	Metadata map[string]string `json:"metadata"`
 }
+
+// BulkMessageEntry is a message of a bulk publish request or of a bulk message
+type BulkMessageEntry struct {
+	EntryID  string            `json:"entryId"`
+	Event    []byte            `json:"event"`
+	Metadata map[string]string `json:"metadata"`
+}
+
+// BulkPublishRequest is the request to publish many messages to a topic at once
+type BulkPublishRequest struct {
+	Entries    []BulkMessageEntry `json:"entries"`
+	PubsubName string             `json:"pubsubname"`
+	Topic      string             `json:"topic"`
+	Metadata   map[string]string  `json:"metadata"`
+}
+
+// BulkMessage is a batch of events arriving from a message bus instance
+type BulkMessage struct {
+	Entries  []BulkMessageEntry `json:"entries"`
+	Topic    string             `json:"topic"`
+	Metadata map[string]string  `json:"metadata"`
+}
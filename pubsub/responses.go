@@ -21,3 +21,35 @@ const (
 type AppResponse struct {
 	Status AppResponseStatus `json:"status"`
 }
+
+// BulkPublishResponse is the response of a bulk publish request, listing the entries that could not be published
+type BulkPublishResponse struct {
+	FailedEntries []BulkPublishFailedEntry `json:"failedEntries"`
+}
+
+// BulkPublishFailedEntry is an entry of a bulk publish request that could not be published
+type BulkPublishFailedEntry struct {
+	EntryID string `json:"entryId"`
+	Error   error  `json:"-"`
+}
+
+// BulkSubscribeResponse is the response of a bulk subscription handler, listing the entries that could not be processed
+type BulkSubscribeResponse struct {
+	FailedEntries []BulkSubscribeFailedEntry `json:"failedEntries"`
+}
+
+// BulkSubscribeFailedEntry is an entry of a bulk message that could not be processed and is not acknowledged
+type BulkSubscribeFailedEntry struct {
+	EntryID string `json:"entryId"`
+	Error   error  `json:"-"`
+}
+
+// Errors returns the errors of the failed entries by entry ID
+func (r BulkSubscribeResponse) Errors() map[string]error {
+	errs := make(map[string]error, len(r.FailedEntries))
+	for _, entry := range r.FailedEntries {
+		errs[entry.EntryID] = entry.Error
+	}
+
+	return errs
+}
@@ -0,0 +1,136 @@
+// ------------------------------------------------------------
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT License.
+// ------------------------------------------------------------
+
+package schemaregistry
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/Azure/go-autorest/autorest"
+)
+
+const (
+	// AzureResource is the resource to request Azure AD tokens of Azure Schema Registry for
+	AzureResource = "https://eventhubs.azure.net"
+
+	azureAPIVersion          = "2020-09-01-preview"
+	azureFormatIndicatorSize = 4
+	azureSchemaIDSize        = 32
+	azureHeaderSize          = azureFormatIndicatorSize + azureSchemaIDSize
+	azureSchemaIDHeader      = "X-Schema-Id"
+	azureSchemaTypeHeader    = "X-Schema-Type"
+)
+
+// AzureClient is a client of Azure Schema Registry, the schema registry of Event Hubs namespaces.
+// Only Avro schemas are supported by the registry.
+type AzureClient struct {
+	endpoint   string
+	group      string
+	authorizer autorest.Authorizer
+	httpClient *http.Client
+}
+
+// NewAzureClient returns a client of the schemas of the group in the registry of the Event Hubs namespace,
+// e.g. mynamespace.servicebus.windows.net, authorized with Azure AD tokens for AzureResource.
+func NewAzureClient(namespace, group string, authorizer autorest.Authorizer) *AzureClient {
+	endpoint := strings.TrimSuffix(namespace, "/")
+	if !strings.Contains(endpoint, "://") {
+		endpoint = "https://" + endpoint
+	}
+
+	return &AzureClient{
+		endpoint:   endpoint,
+		group:      group,
+		authorizer: authorizer,
+		httpClient: &http.Client{Timeout: 30 * time.Second},
+	}
+}
+
+// GetSchemaByID returns the schema with the given ID.
+func (c *AzureClient) GetSchemaByID(id string) (*Schema, error) {
+	return c.get("/$schemagroups/getSchemaById/" + url.PathEscape(id))
+}
+
+// GetLatestSchema returns the latest version of the schema with the subject as name.
+func (c *AzureClient) GetLatestSchema(subject string) (*Schema, error) {
+	return c.get("/$schemagroups/" + url.PathEscape(c.group) + "/schemas/" + url.PathEscape(subject))
+}
+
+// Subject returns the name of the schema of the values of a topic, the topic itself.
+func (c *AzureClient) Subject(topic string) string {
+	return topic
+}
+
+// Frame prefixes a serialized payload with the format indicator and the schema ID.
+func (c *AzureClient) Frame(schema *Schema, payload []byte) ([]byte, error) {
+	if len(schema.ID) != azureSchemaIDSize {
+		return nil, fmt.Errorf("invalid schema ID %s: expected %d characters", schema.ID, azureSchemaIDSize)
+	}
+
+	data := make([]byte, azureFormatIndicatorSize, azureHeaderSize+len(payload))
+	data = append(data, schema.ID...)
+
+	return append(data, payload...), nil
+}
+
+// SchemaID returns the schema ID of framed data.
+func (c *AzureClient) SchemaID(data []byte) (string, error) {
+	if len(data) < azureHeaderSize {
+		return "", ErrInvalidFormat
+	}
+	for _, b := range data[:azureFormatIndicatorSize] {
+		if b != 0 {
+			return "", ErrInvalidFormat
+		}
+	}
+
+	return string(data[azureFormatIndicatorSize:azureHeaderSize]), nil
+}
+
+// Payload returns the serialized payload of framed data.
+func (c *AzureClient) Payload(schema *Schema, data []byte) ([]byte, error) {
+	if len(data) < azureHeaderSize {
+		return nil, ErrInvalidFormat
+	}
+
+	return data[azureHeaderSize:], nil
+}
+
+func (c *AzureClient) get(path string) (*Schema, error) {
+	req, err := http.NewRequest(http.MethodGet, c.endpoint+path+"?api-version="+azureAPIVersion, nil)
+	if err != nil {
+		return nil, err
+	}
+	req, err = autorest.Prepare(req, c.authorizer.WithAuthorization())
+	if err != nil {
+		return nil, fmt.Errorf("cannot authorize schema registry request: %s", err)
+	}
+
+	res, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+
+	body, err := ioutil.ReadAll(res.Body)
+	if err != nil {
+		return nil, err
+	}
+	if res.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("schema registry returned %s: %s", res.Status, body)
+	}
+
+	id := res.Header.Get(azureSchemaIDHeader)
+	if schemaType := res.Header.Get(azureSchemaTypeHeader); !strings.EqualFold(schemaType, string(Avro)) {
+		return nil, fmt.Errorf("unsupported type %s of schema %s", schemaType, id)
+	}
+
+	return &Schema{ID: id, Type: Avro, Definition: string(body)}, nil
+}
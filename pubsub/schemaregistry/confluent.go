@@ -0,0 +1,172 @@
+// ------------------------------------------------------------
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT License.
+// ------------------------------------------------------------
+
+package schemaregistry
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	confluentMagicByte  = 0
+	confluentHeaderSize = 5
+	confluentAvro       = "AVRO"
+	confluentProtobuf   = "PROTOBUF"
+)
+
+// ConfluentClient is a client of Confluent compatible schema registries.
+type ConfluentClient struct {
+	url        string
+	username   string
+	password   string
+	httpClient *http.Client
+}
+
+type confluentSchema struct {
+	ID         int    `json:"id"`
+	Schema     string `json:"schema"`
+	SchemaType string `json:"schemaType"`
+}
+
+// NewConfluentClient returns a client of the schema registry at url, authenticating with basic authentication
+// if username is set. With Confluent Cloud, username and password are the API key and secret.
+func NewConfluentClient(url, username, password string) *ConfluentClient {
+	return &ConfluentClient{
+		url:        strings.TrimSuffix(url, "/"),
+		username:   username,
+		password:   password,
+		httpClient: &http.Client{Timeout: 30 * time.Second},
+	}
+}
+
+// GetSchemaByID returns the schema with the given ID.
+func (c *ConfluentClient) GetSchemaByID(id string) (*Schema, error) {
+	var res confluentSchema
+	if err := c.get("/schemas/ids/"+url.PathEscape(id), &res); err != nil {
+		return nil, err
+	}
+
+	return c.schema(id, res)
+}
+
+// GetLatestSchema returns the latest version of the schema registered for the subject.
+func (c *ConfluentClient) GetLatestSchema(subject string) (*Schema, error) {
+	var res confluentSchema
+	if err := c.get("/subjects/"+url.PathEscape(subject)+"/versions/latest", &res); err != nil {
+		return nil, err
+	}
+
+	return c.schema(strconv.Itoa(res.ID), res)
+}
+
+// Subject returns the subject the schemas of the values of a topic are registered under,
+// following the topic name strategy.
+func (c *ConfluentClient) Subject(topic string) string {
+	return topic + "-value"
+}
+
+// Frame prefixes a serialized payload with the magic byte and the schema ID.
+// Protobuf payloads are also prefixed with the message indexes of the first message of the schema,
+// the only message supported.
+func (c *ConfluentClient) Frame(schema *Schema, payload []byte) ([]byte, error) {
+	id, err := strconv.ParseUint(schema.ID, 10, 32)
+	if err != nil {
+		return nil, fmt.Errorf("invalid schema ID %s: %s", schema.ID, err)
+	}
+
+	data := make([]byte, confluentHeaderSize, confluentHeaderSize+1+len(payload))
+	data[0] = confluentMagicByte
+	binary.BigEndian.PutUint32(data[1:], uint32(id))
+	if schema.Type == Protobuf {
+		data = append(data, 0)
+	}
+
+	return append(data, payload...), nil
+}
+
+// SchemaID returns the schema ID of framed data.
+func (c *ConfluentClient) SchemaID(data []byte) (string, error) {
+	if len(data) < confluentHeaderSize || data[0] != confluentMagicByte {
+		return "", ErrInvalidFormat
+	}
+
+	return strconv.FormatUint(uint64(binary.BigEndian.Uint32(data[1:confluentHeaderSize])), 10), nil
+}
+
+// Payload returns the serialized payload of framed data, skipping the message indexes of Protobuf payloads.
+func (c *ConfluentClient) Payload(schema *Schema, data []byte) ([]byte, error) {
+	if len(data) < confluentHeaderSize {
+		return nil, ErrInvalidFormat
+	}
+
+	payload := data[confluentHeaderSize:]
+	if schema.Type != Protobuf {
+		return payload, nil
+	}
+
+	// the message indexes are a zigzag encoded array length followed by the indexes
+	count, n := binary.Varint(payload)
+	if n <= 0 || count < 0 {
+		return nil, ErrInvalidFormat
+	}
+	payload = payload[n:]
+	for i := int64(0); i < count; i++ {
+		if _, n = binary.Varint(payload); n <= 0 {
+			return nil, ErrInvalidFormat
+		}
+		payload = payload[n:]
+	}
+
+	return payload, nil
+}
+
+func (c *ConfluentClient) get(path string, v interface{}) error {
+	req, err := http.NewRequest(http.MethodGet, c.url+path, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/vnd.schemaregistry.v1+json")
+	if c.username != "" {
+		req.SetBasicAuth(c.username, c.password)
+	}
+
+	res, err := c.httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+
+	body, err := ioutil.ReadAll(res.Body)
+	if err != nil {
+		return err
+	}
+	if res.StatusCode != http.StatusOK {
+		return fmt.Errorf("schema registry returned %s: %s", res.Status, body)
+	}
+
+	return json.Unmarshal(body, v)
+}
+
+func (c *ConfluentClient) schema(id string, res confluentSchema) (*Schema, error) {
+	schema := &Schema{ID: id, Definition: res.Schema}
+	switch res.SchemaType {
+	case "", confluentAvro:
+		schema.Type = Avro
+	case confluentProtobuf:
+		schema.Type = Protobuf
+	default:
+		return nil, fmt.Errorf("unsupported type %s of schema %s", res.SchemaType, id)
+	}
+
+	return schema, nil
+}
@@ -0,0 +1,246 @@
+// ------------------------------------------------------------
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT License.
+// ------------------------------------------------------------
+
+// Package schemaregistry serializes pubsub payloads with schemas resolved from a schema registry.
+package schemaregistry
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/linkedin/goavro/v2"
+)
+
+// ValueSchemaTypeMetadataKey is the publish and subscription metadata key selecting the schema type of the payload
+const ValueSchemaTypeMetadataKey = "valueSchemaType"
+
+// SchemaType is the type of the schemas a payload is serialized with
+type SchemaType string
+
+const (
+	// None means the payload is not serialized with a schema
+	None SchemaType = ""
+	// Avro serializes JSON payloads to Avro binary
+	Avro SchemaType = "Avro"
+	// Protobuf frames payloads already serialized to Protobuf binary
+	Protobuf SchemaType = "Protobuf"
+)
+
+// ErrInvalidFormat is returned when deserializing data which is not in the wire format of the registry
+var ErrInvalidFormat = errors.New("data is not in the schema registry wire format")
+
+// ParseSchemaType returns the schema type set in the metadata, None if it is not set.
+func ParseSchemaType(metadata map[string]string) (SchemaType, error) {
+	switch val := metadata[ValueSchemaTypeMetadataKey]; strings.ToLower(val) {
+	case "", "none":
+		return None, nil
+	case "avro":
+		return Avro, nil
+	case "protobuf":
+		return Protobuf, nil
+	default:
+		return None, fmt.Errorf("invalid %s %s, expected %s or %s", ValueSchemaTypeMetadataKey, val, Avro, Protobuf)
+	}
+}
+
+// Schema is a schema registered in a schema registry
+type Schema struct {
+	ID         string
+	Type       SchemaType
+	Definition string
+}
+
+// Client is a schema registry client. It also frames payloads with the schema ID
+// the way the serializers of the registry do, so that they interoperate with other clients.
+type Client interface {
+	// GetSchemaByID returns the schema with the given ID.
+	GetSchemaByID(id string) (*Schema, error)
+	// GetLatestSchema returns the latest version of the schema registered for the subject.
+	GetLatestSchema(subject string) (*Schema, error)
+	// Subject returns the subject the schemas of the values of a topic are registered under.
+	Subject(topic string) string
+	// Frame prefixes a serialized payload with the schema ID.
+	Frame(schema *Schema, payload []byte) ([]byte, error)
+	// SchemaID returns the schema ID of framed data.
+	SchemaID(data []byte) (string, error)
+	// Payload returns the serialized payload of framed data.
+	Payload(schema *Schema, data []byte) ([]byte, error)
+}
+
+// CachingClient caches the schemas resolved by another client.
+// Schemas are immutable so they are cached by ID for ever, while the latest schema of a subject is cached for a TTL.
+type CachingClient struct {
+	Client
+	ttl    time.Duration
+	lock   sync.RWMutex
+	byID   map[string]*Schema
+	latest map[string]cachedSchema
+	now    func() time.Time
+}
+
+type cachedSchema struct {
+	schema  *Schema
+	expires time.Time
+}
+
+// NewCachingClient returns a client caching the schemas resolved by the client, the latest schemas for ttl.
+func NewCachingClient(client Client, ttl time.Duration) *CachingClient {
+	return &CachingClient{
+		Client: client,
+		ttl:    ttl,
+		byID:   make(map[string]*Schema),
+		latest: make(map[string]cachedSchema),
+		now:    time.Now,
+	}
+}
+
+// GetSchemaByID returns the schema with the given ID.
+func (c *CachingClient) GetSchemaByID(id string) (*Schema, error) {
+	c.lock.RLock()
+	schema, ok := c.byID[id]
+	c.lock.RUnlock()
+	if ok {
+		return schema, nil
+	}
+
+	schema, err := c.Client.GetSchemaByID(id)
+	if err != nil {
+		return nil, err
+	}
+
+	c.lock.Lock()
+	c.byID[id] = schema
+	c.lock.Unlock()
+
+	return schema, nil
+}
+
+// GetLatestSchema returns the latest version of the schema registered for the subject.
+func (c *CachingClient) GetLatestSchema(subject string) (*Schema, error) {
+	c.lock.RLock()
+	cached, ok := c.latest[subject]
+	c.lock.RUnlock()
+	if ok && c.now().Before(cached.expires) {
+		return cached.schema, nil
+	}
+
+	schema, err := c.Client.GetLatestSchema(subject)
+	if err != nil {
+		return nil, err
+	}
+
+	c.lock.Lock()
+	c.latest[subject] = cachedSchema{schema: schema, expires: c.now().Add(c.ttl)}
+	c.byID[schema.ID] = schema
+	c.lock.Unlock()
+
+	return schema, nil
+}
+
+// Serializer serializes payloads with the schemas of a schema registry
+type Serializer struct {
+	client Client
+	lock   sync.RWMutex
+	codecs map[string]*goavro.Codec
+}
+
+// NewSerializer returns a serializer resolving schemas with the client.
+func NewSerializer(client Client) *Serializer {
+	return &Serializer{
+		client: client,
+		codecs: make(map[string]*goavro.Codec),
+	}
+}
+
+// Serialize serializes the payload with the latest schema of the values of the topic, which must be of the schema type.
+// Avro payloads are JSON, while Protobuf payloads are already serialized.
+func (s *Serializer) Serialize(topic string, schemaType SchemaType, data []byte) ([]byte, error) {
+	subject := s.client.Subject(topic)
+	schema, err := s.client.GetLatestSchema(subject)
+	if err != nil {
+		return nil, fmt.Errorf("cannot get the latest schema of subject %s: %s", subject, err)
+	}
+	if schema.Type != schemaType {
+		return nil, fmt.Errorf("the latest schema of subject %s is %s, not %s", subject, schema.Type, schemaType)
+	}
+
+	payload := data
+	if schemaType == Avro {
+		codec, err := s.codec(schema)
+		if err != nil {
+			return nil, err
+		}
+
+		native, _, err := codec.NativeFromTextual(data)
+		if err != nil {
+			return nil, fmt.Errorf("data does not match schema %s: %s", schema.ID, err)
+		}
+
+		payload, err = codec.BinaryFromNative(nil, native)
+		if err != nil {
+			return nil, fmt.Errorf("data does not match schema %s: %s", schema.ID, err)
+		}
+	}
+
+	return s.client.Frame(schema, payload)
+}
+
+// Deserialize returns the payload of serialized data, Avro payloads being converted to JSON.
+func (s *Serializer) Deserialize(data []byte) ([]byte, error) {
+	id, err := s.client.SchemaID(data)
+	if err != nil {
+		return nil, err
+	}
+
+	schema, err := s.client.GetSchemaByID(id)
+	if err != nil {
+		return nil, fmt.Errorf("cannot get schema %s: %s", id, err)
+	}
+
+	payload, err := s.client.Payload(schema, data)
+	if err != nil {
+		return nil, err
+	}
+
+	if schema.Type != Avro {
+		return payload, nil
+	}
+
+	codec, err := s.codec(schema)
+	if err != nil {
+		return nil, err
+	}
+
+	native, _, err := codec.NativeFromBinary(payload)
+	if err != nil {
+		return nil, fmt.Errorf("data does not match schema %s: %s", schema.ID, err)
+	}
+
+	return codec.TextualFromNative(nil, native)
+}
+
+// codec returns the Avro codec of a schema, codecs are compiled once per schema ID
+func (s *Serializer) codec(schema *Schema) (*goavro.Codec, error) {
+	s.lock.RLock()
+	codec, ok := s.codecs[schema.ID]
+	s.lock.RUnlock()
+	if ok {
+		return codec, nil
+	}
+
+	codec, err := goavro.NewCodec(schema.Definition)
+	if err != nil {
+		return nil, fmt.Errorf("invalid Avro schema %s: %s", schema.ID, err)
+	}
+
+	s.lock.Lock()
+	s.codecs[schema.ID] = codec
+	s.lock.Unlock()
+
+	return codec, nil
+}
@@ -0,0 +1,249 @@
+// ------------------------------------------------------------
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT License.
+// ------------------------------------------------------------
+
+package schemaregistry
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/Azure/go-autorest/autorest"
+	"github.com/stretchr/testify/assert"
+)
+
+const orderSchema = `{"type":"record","name":"order","fields":[{"name":"id","type":"string"},{"name":"quantity","type":"int"}]}`
+
+type fakeClient struct {
+	ConfluentClient
+	schemas  map[string]*Schema
+	requests int
+}
+
+func (c *fakeClient) GetSchemaByID(id string) (*Schema, error) {
+	c.requests++
+
+	return c.schemas[id], nil
+}
+
+func (c *fakeClient) GetLatestSchema(subject string) (*Schema, error) {
+	c.requests++
+
+	return c.schemas[subject], nil
+}
+
+func TestParseSchemaType(t *testing.T) {
+	for val, expected := range map[string]SchemaType{"": None, "None": None, "avro": Avro, "Protobuf": Protobuf} {
+		schemaType, err := ParseSchemaType(map[string]string{ValueSchemaTypeMetadataKey: val})
+		assert.NoError(t, err)
+		assert.Equal(t, expected, schemaType)
+	}
+
+	_, err := ParseSchemaType(map[string]string{ValueSchemaTypeMetadataKey: "json"})
+	assert.Error(t, err)
+}
+
+func TestCachingClient(t *testing.T) {
+	schema := &Schema{ID: "1", Type: Avro, Definition: orderSchema}
+	client := &fakeClient{schemas: map[string]*Schema{"1": schema, "orders-value": schema}}
+	now := time.Now()
+	c := NewCachingClient(client, time.Minute)
+	c.now = func() time.Time { return now }
+
+	s, err := c.GetLatestSchema("orders-value")
+	assert.NoError(t, err)
+	assert.Equal(t, schema, s)
+
+	// the schema is cached by subject and by ID
+	c.GetLatestSchema("orders-value")
+	s, _ = c.GetSchemaByID("1")
+	assert.Equal(t, schema, s)
+	assert.Equal(t, 1, client.requests)
+
+	now = now.Add(time.Minute)
+	c.GetLatestSchema("orders-value")
+	assert.Equal(t, 2, client.requests)
+}
+
+func TestSerializer(t *testing.T) {
+	avro := &Schema{ID: "1", Type: Avro, Definition: orderSchema}
+	protobuf := &Schema{ID: "2", Type: Protobuf}
+	s := NewSerializer(&fakeClient{schemas: map[string]*Schema{
+		"1": avro, "orders-value": avro,
+		"2": protobuf, "payments-value": protobuf,
+	}})
+
+	t.Run("avro", func(t *testing.T) {
+		data, err := s.Serialize("orders", Avro, []byte(`{"id":"a","quantity":2}`))
+		assert.NoError(t, err)
+		assert.Equal(t, []byte{0, 0, 0, 0, 1, 2, 'a', 4}, data)
+
+		json, err := s.Deserialize(data)
+		assert.NoError(t, err)
+		assert.JSONEq(t, `{"id":"a","quantity":2}`, string(json))
+	})
+
+	t.Run("protobuf", func(t *testing.T) {
+		data, err := s.Serialize("payments", Protobuf, []byte{8, 1})
+		assert.NoError(t, err)
+		assert.Equal(t, []byte{0, 0, 0, 0, 2, 0, 8, 1}, data)
+
+		payload, err := s.Deserialize(data)
+		assert.NoError(t, err)
+		assert.Equal(t, []byte{8, 1}, payload)
+	})
+
+	t.Run("schema type mismatch", func(t *testing.T) {
+		_, err := s.Serialize("orders", Protobuf, []byte{8, 1})
+		assert.Error(t, err)
+	})
+
+	t.Run("data not matching the schema", func(t *testing.T) {
+		_, err := s.Serialize("orders", Avro, []byte(`{"id":"a"}`))
+		assert.Error(t, err)
+	})
+
+	t.Run("data not in the wire format", func(t *testing.T) {
+		_, err := s.Deserialize([]byte(`{"id":"a"}`))
+		assert.Equal(t, ErrInvalidFormat, err)
+	})
+}
+
+func TestConfluentClient(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		username, password, _ := r.BasicAuth()
+		if username != "key" || password != "secret" {
+			w.WriteHeader(http.StatusUnauthorized)
+
+			return
+		}
+
+		switch r.URL.Path {
+		case "/schemas/ids/1":
+			w.Write([]byte(`{"schema":"{\"type\":\"string\"}"}`))
+		case "/subjects/payments-value/versions/latest":
+			w.Write([]byte(`{"subject":"payments-value","version":3,"id":2,"schemaType":"PROTOBUF","schema":"syntax = \"proto3\";"}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	c := NewConfluentClient(server.URL+"/", "key", "secret")
+
+	t.Run("get schema by id", func(t *testing.T) {
+		schema, err := c.GetSchemaByID("1")
+		assert.NoError(t, err)
+		assert.Equal(t, &Schema{ID: "1", Type: Avro, Definition: `{"type":"string"}`}, schema)
+	})
+
+	t.Run("get latest schema", func(t *testing.T) {
+		schema, err := c.GetLatestSchema(c.Subject("payments"))
+		assert.NoError(t, err)
+		assert.Equal(t, &Schema{ID: "2", Type: Protobuf, Definition: `syntax = "proto3";`}, schema)
+	})
+
+	t.Run("schema not found", func(t *testing.T) {
+		_, err := c.GetSchemaByID("3")
+		assert.Error(t, err)
+	})
+
+	t.Run("unauthorized", func(t *testing.T) {
+		_, err := NewConfluentClient(server.URL, "", "").GetSchemaByID("1")
+		assert.Error(t, err)
+	})
+}
+
+func TestConfluentFraming(t *testing.T) {
+	c := NewConfluentClient("", "", "")
+
+	t.Run("schema id", func(t *testing.T) {
+		id, err := c.SchemaID([]byte{0, 0, 0, 1, 0, 'a'})
+		assert.NoError(t, err)
+		assert.Equal(t, "256", id)
+
+		_, err = c.SchemaID([]byte{1, 0, 0, 1, 0, 'a'})
+		assert.Equal(t, ErrInvalidFormat, err)
+		_, err = c.SchemaID([]byte{0, 0})
+		assert.Equal(t, ErrInvalidFormat, err)
+	})
+
+	t.Run("protobuf message indexes", func(t *testing.T) {
+		schema := &Schema{ID: "1", Type: Protobuf}
+
+		// [0]
+		payload, err := c.Payload(schema, []byte{0, 0, 0, 0, 1, 0, 8, 1})
+		assert.NoError(t, err)
+		assert.Equal(t, []byte{8, 1}, payload)
+
+		// [1, 2]
+		payload, err = c.Payload(schema, []byte{0, 0, 0, 0, 1, 4, 2, 4, 8, 1})
+		assert.NoError(t, err)
+		assert.Equal(t, []byte{8, 1}, payload)
+
+		_, err = c.Payload(schema, []byte{0, 0, 0, 0, 1, 4, 2})
+		assert.Equal(t, ErrInvalidFormat, err)
+	})
+}
+
+func TestAzureClient(t *testing.T) {
+	const id = "0123456789abcdef0123456789abcdef"
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer token" || r.URL.Query().Get("api-version") != azureAPIVersion {
+			w.WriteHeader(http.StatusUnauthorized)
+
+			return
+		}
+
+		switch r.URL.Path {
+		case "/$schemagroups/getSchemaById/" + id, "/$schemagroups/group/schemas/orders":
+			w.Header().Set(azureSchemaIDHeader, id)
+			w.Header().Set(azureSchemaTypeHeader, "avro")
+			w.Write([]byte(orderSchema))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	c := NewAzureClient(server.URL, "group", autorest.NewBearerAuthorizer(staticToken("token")))
+	expected := &Schema{ID: id, Type: Avro, Definition: orderSchema}
+
+	t.Run("get schema by id", func(t *testing.T) {
+		schema, err := c.GetSchemaByID(id)
+		assert.NoError(t, err)
+		assert.Equal(t, expected, schema)
+	})
+
+	t.Run("get latest schema", func(t *testing.T) {
+		schema, err := c.GetLatestSchema(c.Subject("orders"))
+		assert.NoError(t, err)
+		assert.Equal(t, expected, schema)
+	})
+
+	t.Run("framing", func(t *testing.T) {
+		data, err := c.Frame(expected, []byte("a"))
+		assert.NoError(t, err)
+		assert.Equal(t, append(append([]byte{0, 0, 0, 0}, id...), 'a'), data)
+
+		schemaID, err := c.SchemaID(data)
+		assert.NoError(t, err)
+		assert.Equal(t, id, schemaID)
+
+		payload, err := c.Payload(expected, data)
+		assert.NoError(t, err)
+		assert.Equal(t, []byte("a"), payload)
+
+		_, err = c.SchemaID([]byte{1, 0, 0, 0})
+		assert.Equal(t, ErrInvalidFormat, err)
+	})
+}
+
+type staticToken string
+
+func (t staticToken) OAuthToken() string {
+	return string(t)
+}
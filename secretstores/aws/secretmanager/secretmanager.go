@@ -19,6 +19,9 @@ import (
 const (
 	VersionID    = "version_id"
 	VersionStage = "version_stage"
+
+	// maxListResults is the maximum page size of the list of secrets
+	maxListResults = 100
 )
 
 // NewSecretManager returns a new secret manager store
@@ -84,8 +87,14 @@ func (s *smSecretStore) GetSecret(req secretstores.GetSecretRequest) (secretstor
 	return resp, nil
 }
 
-// BulkGetSecret retrieves all secrets in the store and returns a map of decrypted string/string values
+// BulkGetSecret retrieves the secrets in the store matching the filters of the request and returns a map of decrypted
+// string/string values. The secrets are filtered on the entries of their list, which have their name and tags, so that
+// only the values of the matching secrets are read.
 func (s *smSecretStore) BulkGetSecret(req secretstores.BulkGetSecretRequest) (secretstores.GetSecretResponse, error) {
+	if err := secretstores.CheckBulkGetSecretRequest(&req); err != nil {
+		return secretstores.GetSecretResponse{Data: nil}, err
+	}
+
 	resp := secretstores.GetSecretResponse{
 		Data: map[string]string{},
 	}
@@ -95,7 +104,7 @@ func (s *smSecretStore) BulkGetSecret(req secretstores.BulkGetSecretRequest) (se
 
 	for search {
 		output, err := s.client.ListSecrets(&secretsmanager.ListSecretsInput{
-			MaxResults: nil,
+			MaxResults: listMaxResults(req.MaxResults),
 			NextToken:  nextToken,
 		})
 		if err != nil {
@@ -103,6 +112,13 @@ func (s *smSecretStore) BulkGetSecret(req secretstores.BulkGetSecretRequest) (se
 		}
 
 		for _, entry := range output.SecretList {
+			if entry.Name == nil || !req.MatchName(*entry.Name) || !req.MatchTags(tagsToMap(entry.Tags)) {
+				continue
+			}
+			if req.LimitReached(len(resp.Data)) {
+				return resp, nil
+			}
+
 			secrets, err := s.client.GetSecretValue(&secretsmanager.GetSecretValueInput{
 				SecretId: entry.Name,
 				// VersionId:    versionID,
@@ -112,7 +128,7 @@ func (s *smSecretStore) BulkGetSecret(req secretstores.BulkGetSecretRequest) (se
 				return secretstores.GetSecretResponse{Data: nil}, fmt.Errorf("couldn't get secret: %s", *entry.Name)
 			}
 
-			if entry.Name != nil && secrets.SecretString != nil {
+			if secrets.SecretString != nil {
 				resp.Data[*entry.Name] = *secrets.SecretString
 			}
 		}
@@ -124,6 +140,32 @@ func (s *smSecretStore) BulkGetSecret(req secretstores.BulkGetSecretRequest) (se
 	return resp, nil
 }
 
+// listMaxResults returns the page size of the list of secrets, the max results of the request up to the maximum of
+// Secrets Manager, or its default without max results
+func listMaxResults(maxResults int) *int64 {
+	if maxResults <= 0 {
+		return nil
+	}
+	if maxResults > maxListResults {
+		maxResults = maxListResults
+	}
+	size := int64(maxResults)
+
+	return &size
+}
+
+// tagsToMap returns the tags of a secret by key
+func tagsToMap(tags []*secretsmanager.Tag) map[string]string {
+	m := make(map[string]string, len(tags))
+	for _, tag := range tags {
+		if tag.Key != nil && tag.Value != nil {
+			m[*tag.Key] = *tag.Value
+		}
+	}
+
+	return m
+}
+
 func (s *smSecretStore) getClient(metadata *secretManagerMetaData) (*secretsmanager.SecretsManager, error) {
 	sess, err := aws_auth.GetClient(metadata.AccessKey, metadata.SecretKey, metadata.SessionToken, metadata.Region, "")
 	if err != nil {
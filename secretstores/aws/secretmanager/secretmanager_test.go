@@ -8,6 +8,7 @@ import (
 	"fmt"
 	"testing"
 
+	"github.com/aws/aws-sdk-go/aws"
 	"github.com/aws/aws-sdk-go/service/secretsmanager"
 	"github.com/aws/aws-sdk-go/service/secretsmanager/secretsmanageriface"
 	"github.com/dapr/components-contrib/secretstores"
@@ -19,6 +20,7 @@ const secretValue = "secret"
 
 type mockedSM struct {
 	GetSecretValueFn func(*secretsmanager.GetSecretValueInput) (*secretsmanager.GetSecretValueOutput, error)
+	ListSecretsFn    func(*secretsmanager.ListSecretsInput) (*secretsmanager.ListSecretsOutput, error)
 	secretsmanageriface.SecretsManagerAPI
 }
 
@@ -26,6 +28,10 @@ func (m *mockedSM) GetSecretValue(input *secretsmanager.GetSecretValueInput) (*s
 	return m.GetSecretValueFn(input)
 }
 
+func (m *mockedSM) ListSecrets(input *secretsmanager.ListSecretsInput) (*secretsmanager.ListSecretsOutput, error) {
+	return m.ListSecretsFn(input)
+}
+
 func TestInit(t *testing.T) {
 	m := secretstores.Metadata{}
 	s := NewSecretManager(logger.NewLogger("test"))
@@ -138,3 +144,88 @@ func TestGetSecret(t *testing.T) {
 		assert.NotNil(t, err)
 	})
 }
+
+func TestBulkGetSecret(t *testing.T) {
+	tag := func(key, value string) *secretsmanager.Tag {
+		return &secretsmanager.Tag{Key: aws.String(key), Value: aws.String(value)}
+	}
+	pages := map[string]*secretsmanager.ListSecretsOutput{
+		"": {
+			SecretList: []*secretsmanager.SecretListEntry{
+				{Name: aws.String("db-password"), Tags: []*secretsmanager.Tag{tag("env", "prod")}},
+				{Name: aws.String("api-key"), Tags: []*secretsmanager.Tag{tag("env", "prod")}},
+			},
+			NextToken: aws.String("page2"),
+		},
+		"page2": {
+			SecretList: []*secretsmanager.SecretListEntry{
+				{Name: aws.String("db-user"), Tags: []*secretsmanager.Tag{tag("env", "dev")}},
+				{Name: aws.String("db-host")},
+			},
+		},
+	}
+	newStore := func(read *[]string) smSecretStore {
+		return smSecretStore{
+			client: &mockedSM{
+				ListSecretsFn: func(input *secretsmanager.ListSecretsInput) (*secretsmanager.ListSecretsOutput, error) {
+					return pages[aws.StringValue(input.NextToken)], nil
+				},
+				GetSecretValueFn: func(input *secretsmanager.GetSecretValueInput) (*secretsmanager.GetSecretValueOutput, error) {
+					*read = append(*read, *input.SecretId)
+
+					return &secretsmanager.GetSecretValueOutput{
+						Name:         input.SecretId,
+						SecretString: aws.String(*input.SecretId + "-value"),
+					}, nil
+				},
+			},
+		}
+	}
+
+	t.Run("without filters", func(t *testing.T) {
+		var read []string
+		s := newStore(&read)
+
+		output, err := s.BulkGetSecret(secretstores.BulkGetSecretRequest{})
+		assert.Nil(t, err)
+		assert.Len(t, output.Data, 4)
+		assert.Equal(t, "db-user-value", output.Data["db-user"])
+	})
+
+	t.Run("with prefix and tags", func(t *testing.T) {
+		var read []string
+		s := newStore(&read)
+
+		output, err := s.BulkGetSecret(secretstores.BulkGetSecretRequest{
+			Prefix: "db-",
+			Tags:   map[string]string{"env": "prod"},
+		})
+		assert.Nil(t, err)
+		assert.Equal(t, map[string]string{"db-password": "db-password-value"}, output.Data)
+		assert.Equal(t, []string{"db-password"}, read, "only the values of the matching secrets are read")
+	})
+
+	t.Run("with max results", func(t *testing.T) {
+		var read []string
+		s := newStore(&read)
+
+		output, err := s.BulkGetSecret(secretstores.BulkGetSecretRequest{Prefix: "db-", MaxResults: 2})
+		assert.Nil(t, err)
+		assert.Equal(t, map[string]string{"db-password": "db-password-value", "db-user": "db-user-value"}, output.Data)
+		assert.Equal(t, []string{"db-password", "db-user"}, read)
+	})
+
+	t.Run("with negative max results", func(t *testing.T) {
+		var read []string
+		s := newStore(&read)
+
+		_, err := s.BulkGetSecret(secretstores.BulkGetSecretRequest{MaxResults: -1})
+		assert.Equal(t, secretstores.ErrNegativeMaxResults, err)
+	})
+}
+
+func TestListMaxResults(t *testing.T) {
+	assert.Nil(t, listMaxResults(0))
+	assert.Equal(t, int64(10), *listMaxResults(10))
+	assert.Equal(t, int64(maxListResults), *listMaxResults(1000))
+}
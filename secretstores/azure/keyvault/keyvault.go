@@ -8,8 +8,8 @@ package keyvault
 import (
 	"context"
 	"fmt"
+	"path"
 	"strconv"
-	"strings"
 
 	kv "github.com/Azure/azure-sdk-for-go/profiles/latest/keyvault/keyvault"
 	"github.com/dapr/components-contrib/secretstores"
@@ -27,6 +27,9 @@ const (
 	VersionID                       = "version_id"
 )
 
+// maxPageResults is the maximum number of secrets in a page of the list of secrets
+const maxPageResults = 25
+
 type keyvaultSecretStore struct {
 	vaultName   string
 	vaultClient kv.BaseClient
@@ -83,14 +86,24 @@ func (k *keyvaultSecretStore) GetSecret(req secretstores.GetSecretRequest) (secr
 	}, nil
 }
 
-// BulkGetSecret retrieves all secrets in the store and returns a map of decrypted string/string values
+// BulkGetSecret retrieves the secrets in the store matching the filters of the request and returns a map of decrypted
+// string/string values. The secrets are filtered on the items of their list, which have their id and tags, so that
+// only the values of the matching secrets are read.
 func (k *keyvaultSecretStore) BulkGetSecret(req secretstores.BulkGetSecretRequest) (secretstores.GetSecretResponse, error) {
+	if err := secretstores.CheckBulkGetSecretRequest(&req); err != nil {
+		return secretstores.GetSecretResponse{}, err
+	}
+
 	vaultURI := k.getVaultURI()
 
 	maxResults, err := k.getMaxResultsFromMetadata(req.Metadata)
 	if err != nil {
 		return secretstores.GetSecretResponse{}, err
 	}
+	if maxResults == nil && req.MaxResults > 0 && req.MaxResults <= maxPageResults {
+		pageResults := int32(req.MaxResults)
+		maxResults = &pageResults
+	}
 
 	secretsResp, err := k.vaultClient.GetSecretsComplete(context.Background(), vaultURI, maxResults)
 	if err != nil {
@@ -101,23 +114,28 @@ func (k *keyvaultSecretStore) BulkGetSecret(req secretstores.BulkGetSecretReques
 		Data: map[string]string{},
 	}
 
-	for secretsResp.NotDone() {
+	for secretsResp.NotDone() && !req.LimitReached(len(resp.Data)) {
 		secretItem := secretsResp.Value()
-		secretName := strings.TrimPrefix(*secretItem.ID, vaultURI)
+		// the id of a secret is its URI, ending with its name
+		secretName := path.Base(*secretItem.ID)
 
-		secretResp, err := k.vaultClient.GetSecret(context.Background(), vaultURI, secretName, "")
-		if err != nil {
-			return secretstores.GetSecretResponse{}, err
-		}
+		if req.MatchName(secretName) && req.MatchTags(tagsToMap(secretItem.Tags)) {
+			secretResp, err := k.vaultClient.GetSecret(context.Background(), vaultURI, secretName, "")
+			if err != nil {
+				return secretstores.GetSecretResponse{}, err
+			}
 
-		secretValue := ""
-		if secretResp.Value != nil {
-			secretValue = *secretResp.Value
-		}
+			secretValue := ""
+			if secretResp.Value != nil {
+				secretValue = *secretResp.Value
+			}
 
-		resp.Data[secretName] = secretValue
+			resp.Data[secretName] = secretValue
+		}
 
-		secretsResp.NextWithContext(context.Background())
+		if err = secretsResp.NextWithContext(context.Background()); err != nil {
+			return secretstores.GetSecretResponse{}, err
+		}
 	}
 
 	return resp, nil
@@ -142,3 +160,15 @@ func (k *keyvaultSecretStore) getMaxResultsFromMetadata(metadata map[string]stri
 
 	return nil, nil
 }
+
+// tagsToMap returns the tags of a secret with their values
+func tagsToMap(tags map[string]*string) map[string]string {
+	m := make(map[string]string, len(tags))
+	for k, v := range tags {
+		if v != nil {
+			m[k] = *v
+		}
+	}
+
+	return m
+}
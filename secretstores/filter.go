@@ -0,0 +1,71 @@
+// ------------------------------------------------------------
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT License.
+// ------------------------------------------------------------
+
+package secretstores
+
+import (
+	"errors"
+	"sort"
+	"strings"
+)
+
+// ErrNegativeMaxResults is returned when the max results of a bulk get secret request are negative
+var ErrNegativeMaxResults = errors.New("max results must not be negative")
+
+// CheckBulkGetSecretRequest checks the filters of a bulk get secret request
+func CheckBulkGetSecretRequest(req *BulkGetSecretRequest) error {
+	if req.MaxResults < 0 {
+		return ErrNegativeMaxResults
+	}
+
+	return nil
+}
+
+// MatchName returns whether the name of a secret has the prefix of the request
+func (r *BulkGetSecretRequest) MatchName(name string) bool {
+	return strings.HasPrefix(name, r.Prefix)
+}
+
+// MatchTags returns whether the tags of a secret have every tag of the request
+func (r *BulkGetSecretRequest) MatchTags(tags map[string]string) bool {
+	for k, v := range r.Tags {
+		if value, ok := tags[k]; !ok || value != v {
+			return false
+		}
+	}
+
+	return true
+}
+
+// LimitReached returns whether a number of secrets reaches the max results of the request
+func (r *BulkGetSecretRequest) LimitReached(count int) bool {
+	return r.MaxResults > 0 && count >= r.MaxResults
+}
+
+// FilterSecrets returns the secrets of a store without tags matching the filters of a request, the first secrets by
+// name up to its max results
+func FilterSecrets(req *BulkGetSecretRequest, secrets map[string]string) map[string]string {
+	filtered := map[string]string{}
+	if !req.MatchTags(nil) {
+		return filtered
+	}
+
+	names := make([]string, 0, len(secrets))
+	for name := range secrets {
+		if req.MatchName(name) {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		if req.LimitReached(len(filtered)) {
+			break
+		}
+		filtered[name] = secrets[name]
+	}
+
+	return filtered
+}
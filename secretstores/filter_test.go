@@ -0,0 +1,69 @@
+// ------------------------------------------------------------
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT License.
+// ------------------------------------------------------------
+
+package secretstores
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCheckBulkGetSecretRequest(t *testing.T) {
+	assert.NoError(t, CheckBulkGetSecretRequest(&BulkGetSecretRequest{}))
+	assert.NoError(t, CheckBulkGetSecretRequest(&BulkGetSecretRequest{MaxResults: 10}))
+	assert.Equal(t, ErrNegativeMaxResults, CheckBulkGetSecretRequest(&BulkGetSecretRequest{MaxResults: -1}))
+}
+
+func TestBulkGetSecretRequestFilters(t *testing.T) {
+	t.Run("without filters", func(t *testing.T) {
+		req := &BulkGetSecretRequest{}
+
+		assert.True(t, req.MatchName("db-password"))
+		assert.True(t, req.MatchTags(nil))
+		assert.False(t, req.LimitReached(1000))
+	})
+
+	t.Run("prefix", func(t *testing.T) {
+		req := &BulkGetSecretRequest{Prefix: "db-"}
+
+		assert.True(t, req.MatchName("db-password"))
+		assert.False(t, req.MatchName("api-key"))
+	})
+
+	t.Run("tags", func(t *testing.T) {
+		req := &BulkGetSecretRequest{Tags: map[string]string{"env": "prod", "team": "orders"}}
+
+		assert.True(t, req.MatchTags(map[string]string{"env": "prod", "team": "orders", "owner": "dapr"}))
+		assert.False(t, req.MatchTags(map[string]string{"env": "prod"}))
+		assert.False(t, req.MatchTags(map[string]string{"env": "dev", "team": "orders"}))
+		assert.False(t, req.MatchTags(nil))
+	})
+
+	t.Run("max results", func(t *testing.T) {
+		req := &BulkGetSecretRequest{MaxResults: 2}
+
+		assert.False(t, req.LimitReached(1))
+		assert.True(t, req.LimitReached(2))
+	})
+}
+
+func TestFilterSecrets(t *testing.T) {
+	secrets := map[string]string{"db-user": "u", "db-password": "p", "api-key": "k"}
+
+	t.Run("without filters", func(t *testing.T) {
+		assert.Equal(t, secrets, FilterSecrets(&BulkGetSecretRequest{}, secrets))
+	})
+
+	t.Run("prefix and max results", func(t *testing.T) {
+		filtered := FilterSecrets(&BulkGetSecretRequest{Prefix: "db-", MaxResults: 1}, secrets)
+		assert.Equal(t, map[string]string{"db-password": "p"}, filtered)
+	})
+
+	t.Run("tags", func(t *testing.T) {
+		filtered := FilterSecrets(&BulkGetSecretRequest{Tags: map[string]string{"env": "prod"}}, secrets)
+		assert.Empty(t, filtered)
+	})
+}
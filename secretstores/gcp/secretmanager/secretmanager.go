@@ -4,6 +4,7 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"path"
 
 	secretmanager "cloud.google.com/go/secretmanager/apiv1beta1"
 	"github.com/dapr/components-contrib/secretstores"
@@ -13,7 +14,12 @@ import (
 	secretmanagerpb "google.golang.org/genproto/googleapis/cloud/secretmanager/v1beta1"
 )
 
-const VersionID = "version_id"
+const (
+	VersionID = "version_id"
+
+	// maxListPageSize is the maximum page size of the list of secrets
+	maxListPageSize = 250
+)
 
 type gcpCredentials struct {
 	Type                string `json:"type"`
@@ -101,8 +107,18 @@ func (s *Store) GetSecret(req secretstores.GetSecretRequest) (secretstores.GetSe
 	return secretstores.GetSecretResponse{Data: map[string]string{req.Name: *secret}}, nil
 }
 
-// BulkGetSecret retrieves all secrets in the store and returns a map of decrypted string/string values
+// BulkGetSecret retrieves the secrets in the store matching the filters of the request and returns a map of decrypted
+// string/string values. The secrets are filtered on the secrets of their list, which have their name and labels, so
+// that only the versions of the matching secrets are accessed.
 func (s *Store) BulkGetSecret(req secretstores.BulkGetSecretRequest) (secretstores.GetSecretResponse, error) {
+	if s.client == nil {
+		return secretstores.GetSecretResponse{Data: nil}, fmt.Errorf("client is not initialized")
+	}
+
+	if err := secretstores.CheckBulkGetSecretRequest(&req); err != nil {
+		return secretstores.GetSecretResponse{Data: nil}, err
+	}
+
 	versionID := "latest"
 
 	response := map[string]string{}
@@ -110,11 +126,12 @@ func (s *Store) BulkGetSecret(req secretstores.BulkGetSecretRequest) (secretstor
 	ctx := context.Background()
 
 	request := &secretmanagerpb.ListSecretsRequest{
-		Parent: fmt.Sprintf("projects/%s", s.ProjectID),
+		Parent:   fmt.Sprintf("projects/%s", s.ProjectID),
+		PageSize: listPageSize(req.MaxResults),
 	}
 	it := s.client.ListSecrets(ctx, request)
 
-	for {
+	for !req.LimitReached(len(response)) {
 		resp, err := it.Next()
 
 		if err == iterator.Done {
@@ -125,7 +142,12 @@ func (s *Store) BulkGetSecret(req secretstores.BulkGetSecretRequest) (secretstor
 			return secretstores.GetSecretResponse{Data: nil}, fmt.Errorf("failed to list secrets: %v", err)
 		}
 
-		name := resp.GetName()
+		// the name of a secret is its resource name, projects/*/secrets/<name>
+		name := path.Base(resp.GetName())
+		if !req.MatchName(name) || !req.MatchTags(resp.GetLabels()) {
+			continue
+		}
+
 		secret, err := s.getSecret(name, versionID)
 		if err != nil {
 			return secretstores.GetSecretResponse{Data: nil}, fmt.Errorf("failed to access secret version: %v", err)
@@ -136,6 +158,16 @@ func (s *Store) BulkGetSecret(req secretstores.BulkGetSecretRequest) (secretstor
 	return secretstores.GetSecretResponse{Data: response}, nil
 }
 
+// listPageSize returns the page size of the list of secrets, the max results of the request up to the maximum of
+// Secret Manager, or its default without max results
+func listPageSize(maxResults int) int32 {
+	if maxResults > maxListPageSize {
+		return maxListPageSize
+	}
+
+	return int32(maxResults)
+}
+
 func (s *Store) getSecret(secretName string, versionID string) (*string, error) {
 	ctx := context.Background()
 	accessRequest := &secretmanagerpb.AccessSecretVersionRequest{
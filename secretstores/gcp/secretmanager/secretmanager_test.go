@@ -81,3 +81,20 @@ func TestGetSecret(t *testing.T) {
 		assert.Equal(t, secretstores.GetSecretResponse{Data: nil}, v)
 	})
 }
+
+func TestBulkGetSecret(t *testing.T) {
+	sm := NewSecreteManager(logger.NewLogger("test"))
+
+	t.Run("Bulk Get Secret - without Init", func(t *testing.T) {
+		v, err := sm.BulkGetSecret(secretstores.BulkGetSecretRequest{})
+		assert.NotNil(t, err)
+		assert.Equal(t, err, fmt.Errorf("client is not initialized"))
+		assert.Equal(t, secretstores.GetSecretResponse{Data: nil}, v)
+	})
+}
+
+func TestListPageSize(t *testing.T) {
+	assert.Equal(t, int32(0), listPageSize(0))
+	assert.Equal(t, int32(10), listPageSize(10))
+	assert.Equal(t, int32(maxListPageSize), listPageSize(1000))
+}
@@ -61,7 +61,10 @@ type tlsConfig struct {
 // vaultKVResponse is the response data from Vault KV.
 type vaultKVResponse struct {
 	Data struct {
-		Data map[string]string `json:"data"`
+		Data     map[string]string `json:"data"`
+		Metadata struct {
+			CustomMetadata map[string]string `json:"custom_metadata"`
+		} `json:"metadata"`
 	} `json:"data"`
 }
 
@@ -201,8 +204,13 @@ func (v *vaultSecretStore) GetSecret(req secretstores.GetSecretRequest) (secrets
 	return resp, nil
 }
 
-// BulkGetSecret retrieves all secrets in the store and returns a map of decrypted string/string values
+// BulkGetSecret retrieves the secrets in the store matching the filters of the request and returns a map of decrypted
+// string/string values
 func (v *vaultSecretStore) BulkGetSecret(req secretstores.BulkGetSecretRequest) (secretstores.GetSecretResponse, error) {
+	if err := secretstores.CheckBulkGetSecretRequest(&req); err != nil {
+		return secretstores.GetSecretResponse{Data: nil}, err
+	}
+
 	token, err := v.readVaultToken()
 	if err != nil {
 		return secretstores.GetSecretResponse{Data: nil}, err
@@ -245,11 +253,25 @@ func (v *vaultSecretStore) BulkGetSecret(req secretstores.BulkGetSecretRequest)
 		Data: map[string]string{},
 	}
 
+	count := 0
 	for _, key := range d.Data.Keys {
+		if req.LimitReached(count) {
+			break
+		}
+		// the secrets are filtered by name before they are read, and by tags, the custom metadata of their
+		// latest version, after
+		if !req.MatchName(key) {
+			continue
+		}
+
 		secrets, err := v.getSecret(key)
 		if err != nil {
 			return secretstores.GetSecretResponse{Data: nil}, err
 		}
+		if !req.MatchTags(secrets.Data.Metadata.CustomMetadata) {
+			continue
+		}
+		count++
 
 		for k, v := range secrets.Data.Data {
 			resp.Data[k] = v
@@ -7,9 +7,13 @@ package vault
 
 import (
 	"encoding/base64"
+	"encoding/json"
 	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
 	"os"
 	"strconv"
+	"strings"
 	"testing"
 
 	"github.com/dapr/components-contrib/secretstores"
@@ -84,6 +88,78 @@ func TestVaultTLSConfig(t *testing.T) {
 	})
 }
 
+func TestBulkGetSecret(t *testing.T) {
+	f, err := ioutil.TempFile(os.TempDir(), "vault-token")
+	assert.NoError(t, err)
+	defer os.Remove(f.Name())
+	_, err = f.WriteString("token")
+	assert.NoError(t, err)
+
+	secrets := map[string]vaultKVResponse{}
+	addSecret := func(name string, data map[string]string, tags map[string]string) {
+		var secret vaultKVResponse
+		secret.Data.Data = data
+		secret.Data.Metadata.CustomMetadata = tags
+		secrets[name] = secret
+	}
+	addSecret("db-password", map[string]string{"password": "p"}, map[string]string{"env": "prod"})
+	addSecret("db-user", map[string]string{"user": "u"}, map[string]string{"env": "dev"})
+	addSecret("api-key", map[string]string{"key": "k"}, map[string]string{"env": "prod"})
+
+	var read []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == "LIST" {
+			var list vaultListKVResponse
+			list.Data.Keys = []string{"api-key", "db-password", "db-user"}
+			json.NewEncoder(w).Encode(list)
+
+			return
+		}
+		name := strings.TrimPrefix(r.URL.Path, "/v1/secret/data/dapr/")
+		read = append(read, name)
+		json.NewEncoder(w).Encode(secrets[name])
+	}))
+	defer server.Close()
+
+	v := vaultSecretStore{
+		client:              server.Client(),
+		vaultAddress:        server.URL,
+		vaultTokenMountPath: f.Name(),
+		vaultKVPrefix:       defaultVaultKVPrefix,
+	}
+
+	t.Run("without filters", func(t *testing.T) {
+		read = nil
+		resp, err := v.BulkGetSecret(secretstores.BulkGetSecretRequest{})
+		assert.Nil(t, err)
+		assert.Equal(t, map[string]string{"password": "p", "user": "u", "key": "k"}, resp.Data)
+	})
+
+	t.Run("with prefix", func(t *testing.T) {
+		read = nil
+		resp, err := v.BulkGetSecret(secretstores.BulkGetSecretRequest{Prefix: "db-"})
+		assert.Nil(t, err)
+		assert.Equal(t, map[string]string{"password": "p", "user": "u"}, resp.Data)
+		assert.Equal(t, []string{"db-password", "db-user"}, read, "only the secrets with the prefix are read")
+	})
+
+	t.Run("with tags and max results", func(t *testing.T) {
+		read = nil
+		resp, err := v.BulkGetSecret(secretstores.BulkGetSecretRequest{
+			Tags:       map[string]string{"env": "prod"},
+			MaxResults: 1,
+		})
+		assert.Nil(t, err)
+		assert.Equal(t, map[string]string{"key": "k"}, resp.Data)
+		assert.Equal(t, []string{"api-key"}, read)
+	})
+
+	t.Run("with negative max results", func(t *testing.T) {
+		_, err := v.BulkGetSecret(secretstores.BulkGetSecretRequest{MaxResults: -1})
+		assert.Equal(t, secretstores.ErrNegativeMaxResults, err)
+	})
+}
+
 func getCertificate() []byte {
 	certificateBytes, _ := base64.StdEncoding.DecodeString(certificate)
 
@@ -14,6 +14,7 @@ import (
 	"github.com/dapr/components-contrib/secretstores"
 	"github.com/dapr/dapr/pkg/logger"
 	meta_v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
 	"k8s.io/client-go/kubernetes"
 )
 
@@ -60,22 +61,37 @@ func (k *kubernetesSecretStore) GetSecret(req secretstores.GetSecretRequest) (se
 	return resp, nil
 }
 
-// BulkGetSecret retrieves all secrets in the store and returns a map of decrypted string/string values
+// BulkGetSecret retrieves the secrets in the store matching the filters of the request and returns a map of decrypted
+// string/string values. The tags of the request select the secrets by their labels.
 func (k *kubernetesSecretStore) BulkGetSecret(req secretstores.BulkGetSecretRequest) (secretstores.GetSecretResponse, error) {
 	resp := secretstores.GetSecretResponse{
 		Data: map[string]string{},
 	}
+	if err := secretstores.CheckBulkGetSecretRequest(&req); err != nil {
+		return resp, err
+	}
 	namespace, err := k.getNamespaceFromMetadata(req.Metadata)
 	if err != nil {
 		return resp, err
 	}
 
-	secrets, err := k.kubeClient.CoreV1().Secrets(namespace).List(context.TODO(), meta_v1.ListOptions{})
+	secrets, err := k.kubeClient.CoreV1().Secrets(namespace).List(context.TODO(), meta_v1.ListOptions{
+		LabelSelector: labels.SelectorFromSet(req.Tags).String(),
+	})
 	if err != nil {
 		return resp, err
 	}
 
+	count := 0
 	for _, s := range secrets.Items {
+		if req.LimitReached(count) {
+			break
+		}
+		if !req.MatchName(s.Name) {
+			continue
+		}
+		count++
+
 		for k, v := range s.Data {
 			resp.Data[k] = string(v)
 		}
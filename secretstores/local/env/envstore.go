@@ -38,8 +38,13 @@ func (s *envSecretStore) GetSecret(req secretstores.GetSecretRequest) (secretsto
 	}, nil
 }
 
-// BulkGetSecret retrieves all secrets in the store and returns a map of decrypted string/string values
+// BulkGetSecret retrieves the secrets in the store matching the filters of the request and returns a map of decrypted
+// string/string values. The environment variables have no tags.
 func (s *envSecretStore) BulkGetSecret(req secretstores.BulkGetSecretRequest) (secretstores.GetSecretResponse, error) {
+	if err := secretstores.CheckBulkGetSecretRequest(&req); err != nil {
+		return secretstores.GetSecretResponse{}, err
+	}
+
 	r := map[string]string{}
 
 	for _, element := range os.Environ() {
@@ -48,6 +53,6 @@ func (s *envSecretStore) BulkGetSecret(req secretstores.BulkGetSecretRequest) (s
 	}
 
 	return secretstores.GetSecretResponse{
-		Data: r,
+		Data: secretstores.FilterSecrets(&req, r),
 	}, nil
 }
@@ -83,10 +83,15 @@ func (j *localSecretStore) GetSecret(req secretstores.GetSecretRequest) (secrets
 	}, nil
 }
 
-// BulkGetSecret retrieves all secrets in the store and returns a map of decrypted string/string values
+// BulkGetSecret retrieves the secrets in the store matching the filters of the request and returns a map of decrypted
+// string/string values. The secrets of the file have no tags.
 func (j *localSecretStore) BulkGetSecret(req secretstores.BulkGetSecretRequest) (secretstores.GetSecretResponse, error) {
+	if err := secretstores.CheckBulkGetSecretRequest(&req); err != nil {
+		return secretstores.GetSecretResponse{}, err
+	}
+
 	return secretstores.GetSecretResponse{
-		Data: j.secrets,
+		Data: secretstores.FilterSecrets(&req, j.secrets),
 	}, nil
 }
 
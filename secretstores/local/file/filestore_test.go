@@ -126,3 +126,34 @@ func TestGetSecret(t *testing.T) {
 		assert.Equal(t, err, fmt.Errorf("secret %s not found", req.Name))
 	})
 }
+
+func TestBulkGetSecret(t *testing.T) {
+	m := secretstores.Metadata{}
+	m.Properties = map[string]string{
+		"SecretsFile":     "a",
+		"NestedSeparator": ":",
+	}
+	s := localSecretStore{
+		logger: logger.NewLogger("test"),
+		readLocalFileFn: func(secretsFile string) (map[string]interface{}, error) {
+			secrets := make(map[string]interface{})
+			secrets["db"] = map[string]interface{}{"user": "u", "password": "p"}
+			secrets["apikey"] = "k"
+
+			return secrets, nil
+		},
+	}
+	s.Init(m)
+
+	t.Run("all secrets", func(t *testing.T) {
+		output, e := s.BulkGetSecret(secretstores.BulkGetSecretRequest{})
+		assert.Nil(t, e)
+		assert.Equal(t, map[string]string{"db:user": "u", "db:password": "p", "apikey": "k"}, output.Data)
+	})
+
+	t.Run("secrets with prefix", func(t *testing.T) {
+		output, e := s.BulkGetSecret(secretstores.BulkGetSecretRequest{Prefix: "db:", MaxResults: 1})
+		assert.Nil(t, e)
+		assert.Equal(t, map[string]string{"db:password": "p"}, output.Data)
+	})
+}
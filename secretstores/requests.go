@@ -11,7 +11,14 @@ type GetSecretRequest struct {
 	Metadata map[string]string `json:"metadata"`
 }
 
-// BulkGetSecretRequest describes a bulk get secret request from a secret store
+// BulkGetSecretRequest describes a bulk get secret request from a secret store, which returns the secrets matching
+// every filter of the request
 type BulkGetSecretRequest struct {
 	Metadata map[string]string `json:"metadata"`
+	// Prefix filters the secrets by the prefix of their name
+	Prefix string `json:"prefix,omitempty"`
+	// Tags filters the secrets by their tags, or labels: a secret matches when it has every tag, with its value
+	Tags map[string]string `json:"tags,omitempty"`
+	// MaxResults limits the number of secrets returned, without limit when it is 0
+	MaxResults int `json:"maxResults,omitempty"`
 }
@@ -98,6 +98,10 @@ func (aspike *Aerospike) Set(req *state.SetRequest) error {
 	if err != nil {
 		return err
 	}
+	err = state.RejectTTL(req.Metadata)
+	if err != nil {
+		return err
+	}
 	asKey, err := as.NewKey(aspike.namespace, aspike.set, req.Key)
 	if err != nil {
 		return err
@@ -3,29 +3,54 @@ package dynamodb
 import (
 	"encoding/json"
 	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
 
 	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
 	"github.com/aws/aws-sdk-go/service/dynamodb"
 	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbattribute"
 	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbiface"
 	aws_auth "github.com/dapr/components-contrib/authentication/aws"
 	"github.com/dapr/components-contrib/state"
+	"github.com/google/uuid"
 	jsoniterator "github.com/json-iterator/go"
 )
 
+const (
+	// maxBatchGetSize is the maximum number of keys of a BatchGetItem request
+	maxBatchGetSize = 100
+	// maxBatchWriteSize is the maximum number of items of a BatchWriteItem request
+	maxBatchWriteSize = 25
+	// maxBatchAttempts is the number of times the unprocessed items of a batch are sent
+	maxBatchAttempts  = 5
+	batchRetryBackoff = 50 * time.Millisecond
+	// maxTransactWriteSize is the maximum number of actions of a TransactWriteItems request
+	maxTransactWriteSize = 100
+
+	// etagAttributeName is the attribute holding the version of the items, which is their etag
+	etagAttributeName = "etag"
+)
+
 // StateStore is a DynamoDB state store
 type StateStore struct {
 	client dynamodbiface.DynamoDBAPI
 	table  string
+	// ttlAttributeName is the attribute holding the expiry time of the items, it must be the TTL attribute of the table
+	ttlAttributeName string
+	bulk             state.BulkConfig
 }
 
 type dynamoDBMetadata struct {
-	Region       string `json:"region"`
-	Endpoint     string `json:"endpoint"`
-	AccessKey    string `json:"accessKey"`
-	SecretKey    string `json:"secretKey"`
-	SessionToken string `json:"sessionToken"`
-	Table        string `json:"table"`
+	Region           string `json:"region"`
+	Endpoint         string `json:"endpoint"`
+	AccessKey        string `json:"accessKey"`
+	SecretKey        string `json:"secretKey"`
+	SessionToken     string `json:"sessionToken"`
+	Table            string `json:"table"`
+	TTLAttributeName string `json:"ttlAttributeName"`
 }
 
 // NewDynamoDBStateStore returns a new dynamoDB state store
@@ -47,6 +72,12 @@ func (d *StateStore) Init(metadata state.Metadata) error {
 
 	d.client = client
 	d.table = meta.Table
+	d.ttlAttributeName = meta.TTLAttributeName
+
+	d.bulk, err = state.ParseBulkConfig(metadata.Properties)
+	if err != nil {
+		return fmt.Errorf("dynamodb error: %s", err)
+	}
 
 	return nil
 }
@@ -68,7 +99,7 @@ func (d *StateStore) Get(req *state.GetRequest) (*state.GetResponse, error) {
 		return nil, err
 	}
 
-	if len(result.Item) == 0 {
+	if len(result.Item) == 0 || d.expired(result.Item) {
 		return &state.GetResponse{}, nil
 	}
 
@@ -79,75 +110,201 @@ func (d *StateStore) Get(req *state.GetRequest) (*state.GetResponse, error) {
 
 	return &state.GetResponse{
 		Data: []byte(output),
+		ETag: itemETag(result.Item),
 	}, nil
 }
 
-// BulkGet performs a bulk get operations
+// BulkGet retrieves the items with BatchGetItem, in batches of at most maxBulkBatchSize keys
 func (d *StateStore) BulkGet(req []state.GetRequest) (bool, []state.BulkGetResponse, error) {
-	// TODO: replace with dynamodb.BatchGetItem for performance
-	return false, nil, nil
-}
+	// BatchGetItem rejects the duplicate keys, and reads all the keys with the same consistency
+	keys := make([]string, 0, len(req))
+	seen := make(map[string]bool, len(req))
+	consistent := false
+	for i := range req {
+		if !seen[req[i].Key] {
+			seen[req[i].Key] = true
+			keys = append(keys, req[i].Key)
+		}
+		consistent = consistent || req[i].Options.Consistency == state.Strong
+	}
 
-// Set saves a dynamoDB item
-func (d *StateStore) Set(req *state.SetRequest) error {
-	value, err := d.marshalToString(req.Value)
+	var lock sync.Mutex
+	items := make(map[string]map[string]*dynamodb.AttributeValue, len(keys))
+	err := d.bulk.RunBatches(len(keys), maxBatchGetSize, func(b state.Batch) error {
+		found, err := d.getBatch(keys[b.Start:b.End], consistent)
+		if err != nil {
+			return err
+		}
+
+		lock.Lock()
+		defer lock.Unlock()
+		for _, item := range found {
+			if key, ok := item["key"]; ok && key.S != nil {
+				items[*key.S] = item
+			}
+		}
+
+		return nil
+	})
 	if err != nil {
-		return fmt.Errorf("dynamodb error: failed to set key %s: %s", req.Key, err)
+		return true, nil, err
 	}
 
-	item := map[string]*dynamodb.AttributeValue{
-		"key": {
-			S: aws.String(req.Key),
-		},
-		"value": {
-			S: aws.String(value),
+	res := make([]state.BulkGetResponse, len(req))
+	for i := range req {
+		res[i].Key = req[i].Key
+		item, ok := items[req[i].Key]
+		if !ok || d.expired(item) {
+			continue
+		}
+		var output string
+		if err = dynamodbattribute.Unmarshal(item["value"], &output); err != nil {
+			res[i].Error = err.Error()
+
+			continue
+		}
+		res[i].Data = []byte(output)
+		res[i].ETag = itemETag(item)
+	}
+
+	return true, res, nil
+}
+
+// getBatch reads a batch of keys, until DynamoDB has processed all of them
+func (d *StateStore) getBatch(keys []string, consistent bool) ([]map[string]*dynamodb.AttributeValue, error) {
+	attrs := make([]map[string]*dynamodb.AttributeValue, len(keys))
+	for i, key := range keys {
+		attrs[i] = map[string]*dynamodb.AttributeValue{
+			"key": {
+				S: aws.String(key),
+			},
+		}
+	}
+	requestItems := map[string]*dynamodb.KeysAndAttributes{
+		d.table: {
+			Keys:           attrs,
+			ConsistentRead: aws.Bool(consistent),
 		},
 	}
 
+	items := []map[string]*dynamodb.AttributeValue{}
+	err := retryUnprocessed(func() (int, error) {
+		output, err := d.client.BatchGetItem(&dynamodb.BatchGetItemInput{
+			RequestItems: requestItems,
+		})
+		if err != nil {
+			return 0, err
+		}
+		items = append(items, output.Responses[d.table]...)
+
+		unprocessed, ok := output.UnprocessedKeys[d.table]
+		if !ok || len(unprocessed.Keys) == 0 {
+			return 0, nil
+		}
+		requestItems = output.UnprocessedKeys
+
+		return len(unprocessed.Keys), nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return items, nil
+}
+
+// List returns a page of the keys with a prefix with Scan, whose last evaluated key is the token of the next page.
+// DynamoDB filters the keys with the prefix after reading limit items, so a page may have fewer items, or none,
+// before the last page.
+func (d *StateStore) List(req *state.ListRequest) (*state.ListResponse, error) {
+	input := &dynamodb.ScanInput{
+		TableName: aws.String(d.table),
+		Limit:     aws.Int64(int64(req.PageLimit())),
+	}
+	if req.Prefix != "" {
+		input.FilterExpression = aws.String("begins_with(#key, :prefix)")
+		input.ExpressionAttributeNames = map[string]*string{"#key": aws.String("key")}
+		input.ExpressionAttributeValues = map[string]*dynamodb.AttributeValue{":prefix": {S: aws.String(req.Prefix)}}
+	}
+	if req.Token != "" {
+		input.ExclusiveStartKey = map[string]*dynamodb.AttributeValue{"key": {S: aws.String(req.Token)}}
+	}
+
+	output, err := d.client.Scan(input)
+	if err != nil {
+		return nil, err
+	}
+
+	res := &state.ListResponse{Items: make([]state.QueryItem, 0, len(output.Items)), Metadata: req.Metadata}
+	for _, item := range output.Items {
+		key, ok := item["key"]
+		if !ok || key.S == nil || d.expired(item) {
+			continue
+		}
+		listItem := state.QueryItem{Key: *key.S, ETag: itemETag(item)}
+		var value string
+		if err = dynamodbattribute.Unmarshal(item["value"], &value); err != nil {
+			listItem.Error = err.Error()
+		} else {
+			listItem.Data = []byte(value)
+		}
+		res.Items = append(res.Items, listItem)
+	}
+	if key, ok := output.LastEvaluatedKey["key"]; ok && key.S != nil {
+		res.Token = *key.S
+	}
+
+	return res, nil
+}
+
+// Set saves a dynamoDB item
+func (d *StateStore) Set(req *state.SetRequest) error {
+	item, err := d.getItem(req)
+	if err != nil {
+		return err
+	}
+
 	input := &dynamodb.PutItemInput{
 		Item:      item,
 		TableName: &d.table,
 	}
+	input.ConditionExpression, input.ExpressionAttributeNames, input.ExpressionAttributeValues = etagCondition(req.ETag, req.Options.Concurrency)
 
-	_, e := d.client.PutItem(input)
+	_, err = d.client.PutItem(input)
 
-	return e
+	return etagError(req.Key, err)
 }
 
-// BulkSet performs a bulk set operation
+// BulkSet saves the items with BatchWriteItem, in batches of at most maxBulkBatchSize items. BatchWriteItem doesn't
+// check the etags, so the items with an etag are saved one by one.
 func (d *StateStore) BulkSet(req []state.SetRequest) error {
+	tasks := []func() error{}
 	writeRequests := []*dynamodb.WriteRequest{}
 
-	for _, r := range req {
-		value, err := d.marshalToString(r.Value)
+	for i := range req {
+		if req[i].ETag != "" && req[i].Options.Concurrency != state.LastWrite {
+			r := &req[i]
+			tasks = append(tasks, func() error {
+				return d.Set(r)
+			})
+
+			continue
+		}
+
+		item, err := d.getItem(&req[i])
 		if err != nil {
-			return fmt.Errorf("dynamodb error: failed to set key %s: %s", r.Key, err)
+			return err
 		}
 
 		writeRequest := &dynamodb.WriteRequest{
 			PutRequest: &dynamodb.PutRequest{
-				Item: map[string]*dynamodb.AttributeValue{
-					"key": {
-						S: aws.String(r.Key),
-					},
-					"value": {
-						S: aws.String(value),
-					},
-				},
+				Item: item,
 			},
 		}
 
 		writeRequests = append(writeRequests, writeRequest)
 	}
 
-	requestItems := map[string][]*dynamodb.WriteRequest{}
-	requestItems[d.table] = writeRequests
-
-	_, e := d.client.BatchWriteItem(&dynamodb.BatchWriteItemInput{
-		RequestItems: requestItems,
-	})
-
-	return e
+	return d.runWithBatchWrite(tasks, writeRequests)
 }
 
 // Delete performs a delete operation
@@ -160,16 +317,28 @@ func (d *StateStore) Delete(req *state.DeleteRequest) error {
 		},
 		TableName: aws.String(d.table),
 	}
+	input.ConditionExpression, input.ExpressionAttributeNames, input.ExpressionAttributeValues = etagCondition(req.ETag, req.Options.Concurrency)
 	_, err := d.client.DeleteItem(input)
 
-	return err
+	return etagError(req.Key, err)
 }
 
-// BulkDelete performs a bulk delete operation
+// BulkDelete performs a bulk delete operation. BatchWriteItem doesn't check the etags, so the items with an etag
+// are deleted one by one.
 func (d *StateStore) BulkDelete(req []state.DeleteRequest) error {
+	tasks := []func() error{}
 	writeRequests := []*dynamodb.WriteRequest{}
 
-	for _, r := range req {
+	for i, r := range req {
+		if r.ETag != "" && r.Options.Concurrency != state.LastWrite {
+			r := &req[i]
+			tasks = append(tasks, func() error {
+				return d.Delete(r)
+			})
+
+			continue
+		}
+
 		writeRequest := &dynamodb.WriteRequest{
 			DeleteRequest: &dynamodb.DeleteRequest{
 				Key: map[string]*dynamodb.AttributeValue{
@@ -182,14 +351,210 @@ func (d *StateStore) BulkDelete(req []state.DeleteRequest) error {
 		writeRequests = append(writeRequests, writeRequest)
 	}
 
-	requestItems := map[string][]*dynamodb.WriteRequest{}
-	requestItems[d.table] = writeRequests
+	return d.runWithBatchWrite(tasks, writeRequests)
+}
+
+// runWithBatchWrite runs the tasks of the items written one by one along with the batch writes of the others
+func (d *StateStore) runWithBatchWrite(tasks []func() error, writeRequests []*dynamodb.WriteRequest) error {
+	if len(writeRequests) > 0 {
+		tasks = append(tasks, func() error {
+			return d.batchWrite(writeRequests)
+		})
+	}
+
+	return d.bulk.Run(tasks)
+}
+
+// Multi performs the operations in a transaction with TransactWriteItems, which fails when the etag of one of them
+// does not match. A transaction has at most 100 operations, on distinct keys.
+func (d *StateStore) Multi(request *state.TransactionalStateRequest) error {
+	if len(request.Operations) == 0 {
+		return nil
+	}
+	if len(request.Operations) > maxTransactWriteSize {
+		return fmt.Errorf("dynamodb error: a transaction has at most %d operations, it has %d", maxTransactWriteSize, len(request.Operations))
+	}
+
+	items := make([]*dynamodb.TransactWriteItem, 0, len(request.Operations))
+	for _, o := range request.Operations {
+		switch o.Operation {
+		case state.Upsert:
+			req, ok := o.Request.(state.SetRequest)
+			if !ok {
+				return fmt.Errorf("expecting set request")
+			}
+			item, err := d.getItem(&req)
+			if err != nil {
+				return err
+			}
+			put := &dynamodb.Put{
+				Item:      item,
+				TableName: aws.String(d.table),
+			}
+			put.ConditionExpression, put.ExpressionAttributeNames, put.ExpressionAttributeValues = etagCondition(req.ETag, req.Options.Concurrency)
+			items = append(items, &dynamodb.TransactWriteItem{Put: put})
+
+		case state.Delete:
+			req, ok := o.Request.(state.DeleteRequest)
+			if !ok {
+				return fmt.Errorf("expecting delete request")
+			}
+			del := &dynamodb.Delete{
+				Key: map[string]*dynamodb.AttributeValue{
+					"key": {
+						S: aws.String(req.Key),
+					},
+				},
+				TableName: aws.String(d.table),
+			}
+			del.ConditionExpression, del.ExpressionAttributeNames, del.ExpressionAttributeValues = etagCondition(req.ETag, req.Options.Concurrency)
+			items = append(items, &dynamodb.TransactWriteItem{Delete: del})
 
-	_, e := d.client.BatchWriteItem(&dynamodb.BatchWriteItemInput{
-		RequestItems: requestItems,
+		default:
+			return fmt.Errorf("unsupported operation: %s", o.Operation)
+		}
+	}
+
+	_, err := d.client.TransactWriteItems(&dynamodb.TransactWriteItemsInput{
+		TransactItems: items,
+	})
+	if aerr, ok := err.(awserr.Error); ok && aerr.Code() == dynamodb.ErrCodeTransactionCanceledException &&
+		strings.Contains(aerr.Message(), "ConditionalCheckFailed") {
+		return fmt.Errorf("dynamodb error: transaction canceled by an etag mismatch: %s", aerr.Message())
+	}
+
+	return err
+}
+
+// batchWrite sends the write requests in batches, until DynamoDB has processed all of them
+func (d *StateStore) batchWrite(writeRequests []*dynamodb.WriteRequest) error {
+	return d.bulk.RunBatches(len(writeRequests), maxBatchWriteSize, func(b state.Batch) error {
+		requestItems := map[string][]*dynamodb.WriteRequest{}
+		requestItems[d.table] = writeRequests[b.Start:b.End]
+
+		return retryUnprocessed(func() (int, error) {
+			output, err := d.client.BatchWriteItem(&dynamodb.BatchWriteItemInput{
+				RequestItems: requestItems,
+			})
+			if err != nil {
+				return 0, err
+			}
+			requestItems = output.UnprocessedItems
+
+			return len(output.UnprocessedItems[d.table]), nil
+		})
 	})
+}
+
+// retryUnprocessed calls a batch operation until it has no unprocessed items, with an exponential backoff
+func retryUnprocessed(fn func() (int, error)) error {
+	backoff := batchRetryBackoff
+	for attempt := 1; ; attempt++ {
+		unprocessed, err := fn()
+		if err != nil {
+			return err
+		}
+		if unprocessed == 0 {
+			return nil
+		}
+		if attempt == maxBatchAttempts {
+			return fmt.Errorf("dynamodb error: %d items were not processed after %d attempts", unprocessed, attempt)
+		}
+		time.Sleep(backoff)
+		backoff *= 2
+	}
+}
+
+// Features returns the features of the DynamoDB state store, TTL requires the ttlAttributeName metadata
+func (d *StateStore) Features() []state.Feature {
+	if d.ttlAttributeName == "" {
+		return nil
+	}
+
+	return []state.Feature{state.FeatureTTL}
+}
+
+// getItem returns the attributes of the item of a set request, the expiry time of the items with a time to live
+// is held by the TTL attribute of the table, in seconds since the epoch
+func (d *StateStore) getItem(req *state.SetRequest) (map[string]*dynamodb.AttributeValue, error) {
+	value, err := d.marshalToString(req.Value)
+	if err != nil {
+		return nil, fmt.Errorf("dynamodb error: failed to set key %s: %s", req.Key, err)
+	}
+
+	item := map[string]*dynamodb.AttributeValue{
+		"key": {
+			S: aws.String(req.Key),
+		},
+		"value": {
+			S: aws.String(value),
+		},
+		etagAttributeName: {
+			S: aws.String(uuid.New().String()),
+		},
+	}
+
+	ttl, err := state.ParseTTL(req.Metadata)
+	if err != nil {
+		return nil, fmt.Errorf("dynamodb error: failed to set key %s: %s", req.Key, err)
+	}
+	if ttl != nil {
+		if d.ttlAttributeName == "" {
+			return nil, fmt.Errorf("dynamodb error: failed to set key %s: %s without the ttlAttributeName metadata", req.Key, state.ErrTTLNotSupported)
+		}
+		// the items without expiry time never expire
+		if *ttl != state.NoExpiry {
+			item[d.ttlAttributeName] = &dynamodb.AttributeValue{
+				N: aws.String(strconv.FormatInt(time.Now().Unix()+int64(*ttl), 10)),
+			}
+		}
+	}
+
+	return item, nil
+}
+
+// etagCondition returns the condition expression which compares the etag of an item to the etag of a request,
+// along with its attribute names and values. There is no condition without etag, or with the last-write concurrency.
+func etagCondition(etag string, concurrency string) (*string, map[string]*string, map[string]*dynamodb.AttributeValue) {
+	if etag == "" || concurrency == state.LastWrite {
+		return nil, nil, nil
+	}
+
+	return aws.String("#etag = :etag"),
+		map[string]*string{"#etag": aws.String(etagAttributeName)},
+		map[string]*dynamodb.AttributeValue{":etag": {S: aws.String(etag)}}
+}
+
+// etagError returns the etag mismatch error of a conditional write whose condition failed
+func etagError(key string, err error) error {
+	if aerr, ok := err.(awserr.Error); ok && aerr.Code() == dynamodb.ErrCodeConditionalCheckFailedException {
+		return fmt.Errorf("dynamodb error: etag mismatch of key %s", key)
+	}
+
+	return err
+}
+
+// itemETag returns the etag of an item, the items saved before the etags have none
+func itemETag(item map[string]*dynamodb.AttributeValue) string {
+	if attr, ok := item[etagAttributeName]; ok && attr.S != nil {
+		return *attr.S
+	}
+
+	return ""
+}
+
+// expired tells whether an item has expired, DynamoDB deletes the expired items up to 48 hours after they expire
+func (d *StateStore) expired(item map[string]*dynamodb.AttributeValue) bool {
+	if d.ttlAttributeName == "" {
+		return false
+	}
+	attr, ok := item[d.ttlAttributeName]
+	if !ok || attr.N == nil {
+		return false
+	}
+	expiry, err := strconv.ParseInt(*attr.N, 10, 64)
 
-	return e
+	return err == nil && expiry <= time.Now().Unix()
 }
 
 func (d *StateStore) getDynamoDBMetadata(metadata state.Metadata) (*dynamoDBMetadata, error) {
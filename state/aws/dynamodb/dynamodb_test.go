@@ -6,9 +6,13 @@ package dynamodb
 
 import (
 	"fmt"
+	"strconv"
+	"sync"
 	"testing"
+	"time"
 
 	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
 	"github.com/aws/aws-sdk-go/service/dynamodb"
 	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbiface"
 	"github.com/dapr/components-contrib/state"
@@ -16,10 +20,13 @@ import (
 )
 
 type mockedDynamoDB struct {
-	GetItemFn        func(input *dynamodb.GetItemInput) (*dynamodb.GetItemOutput, error)
-	PutItemFn        func(input *dynamodb.PutItemInput) (*dynamodb.PutItemOutput, error)
-	DeleteItemFn     func(input *dynamodb.DeleteItemInput) (*dynamodb.DeleteItemOutput, error)
-	BatchWriteItemFn func(input *dynamodb.BatchWriteItemInput) (*dynamodb.BatchWriteItemOutput, error)
+	GetItemFn            func(input *dynamodb.GetItemInput) (*dynamodb.GetItemOutput, error)
+	PutItemFn            func(input *dynamodb.PutItemInput) (*dynamodb.PutItemOutput, error)
+	DeleteItemFn         func(input *dynamodb.DeleteItemInput) (*dynamodb.DeleteItemOutput, error)
+	BatchWriteItemFn     func(input *dynamodb.BatchWriteItemInput) (*dynamodb.BatchWriteItemOutput, error)
+	BatchGetItemFn       func(input *dynamodb.BatchGetItemInput) (*dynamodb.BatchGetItemOutput, error)
+	TransactWriteItemsFn func(input *dynamodb.TransactWriteItemsInput) (*dynamodb.TransactWriteItemsOutput, error)
+	ScanFn               func(input *dynamodb.ScanInput) (*dynamodb.ScanOutput, error)
 	dynamodbiface.DynamoDBAPI
 }
 
@@ -39,6 +46,26 @@ func (m *mockedDynamoDB) BatchWriteItem(input *dynamodb.BatchWriteItemInput) (*d
 	return m.BatchWriteItemFn(input)
 }
 
+func (m *mockedDynamoDB) BatchGetItem(input *dynamodb.BatchGetItemInput) (*dynamodb.BatchGetItemOutput, error) {
+	return m.BatchGetItemFn(input)
+}
+
+func (m *mockedDynamoDB) TransactWriteItems(input *dynamodb.TransactWriteItemsInput) (*dynamodb.TransactWriteItemsOutput, error) {
+	return m.TransactWriteItemsFn(input)
+}
+
+func (m *mockedDynamoDB) Scan(input *dynamodb.ScanInput) (*dynamodb.ScanOutput, error) {
+	return m.ScanFn(input)
+}
+
+// withoutETag asserts that an item has an etag and returns the item without it
+func withoutETag(t *testing.T, item map[string]*dynamodb.AttributeValue) map[string]*dynamodb.AttributeValue {
+	assert.NotEmpty(t, itemETag(item))
+	delete(item, etagAttributeName)
+
+	return item
+}
+
 func TestInit(t *testing.T) {
 	m := state.Metadata{}
 	s := NewDynamoDBStateStore()
@@ -92,6 +119,31 @@ func TestGet(t *testing.T) {
 		assert.Nil(t, err)
 		assert.Equal(t, []byte("value"), out.Data)
 	})
+	t.Run("Expired item is not retrieved", func(t *testing.T) {
+		ss := StateStore{
+			client: &mockedDynamoDB{
+				GetItemFn: func(input *dynamodb.GetItemInput) (output *dynamodb.GetItemOutput, err error) {
+					return &dynamodb.GetItemOutput{
+						Item: map[string]*dynamodb.AttributeValue{
+							"key": {
+								S: aws.String("key"),
+							},
+							"value": {
+								S: aws.String("value"),
+							},
+							"expiresAt": {
+								N: aws.String(strconv.FormatInt(time.Now().Unix()-1, 10)),
+							},
+						},
+					}, nil
+				},
+			},
+			ttlAttributeName: "expiresAt",
+		}
+		out, err := ss.Get(&state.GetRequest{Key: "key"})
+		assert.Nil(t, err)
+		assert.Nil(t, out.Data)
+	})
 	t.Run("Unsuccessfully get item", func(t *testing.T) {
 		ss := StateStore{
 			client: &mockedDynamoDB{
@@ -168,6 +220,7 @@ func TestSet(t *testing.T) {
 		ss := StateStore{
 			client: &mockedDynamoDB{
 				PutItemFn: func(input *dynamodb.PutItemInput) (output *dynamodb.PutItemOutput, err error) {
+					assert.Nil(t, input.ConditionExpression)
 					assert.Equal(t, map[string]*dynamodb.AttributeValue{
 						"key": {
 							S: aws.String("key"),
@@ -175,7 +228,7 @@ func TestSet(t *testing.T) {
 						"value": {
 							S: aws.String(`{"Value":"value"}`),
 						},
-					}, input.Item)
+					}, withoutETag(t, input.Item))
 
 					return &dynamodb.PutItemOutput{
 						Attributes: map[string]*dynamodb.AttributeValue{
@@ -196,6 +249,107 @@ func TestSet(t *testing.T) {
 		err := ss.Set(req)
 		assert.Nil(t, err)
 	})
+	t.Run("Successfully set item with ttl", func(t *testing.T) {
+		ss := StateStore{
+			client: &mockedDynamoDB{
+				PutItemFn: func(input *dynamodb.PutItemInput) (output *dynamodb.PutItemOutput, err error) {
+					expiresAt, err := strconv.ParseInt(*input.Item["expiresAt"].N, 10, 64)
+					assert.Nil(t, err)
+					assert.InDelta(t, time.Now().Unix()+100, expiresAt, 5)
+
+					return &dynamodb.PutItemOutput{}, nil
+				},
+			},
+			ttlAttributeName: "expiresAt",
+		}
+		req := &state.SetRequest{
+			Key: "key",
+			Value: value{
+				Value: "value",
+			},
+			Metadata: map[string]string{"ttlInSeconds": "100"},
+		}
+		err := ss.Set(req)
+		assert.Nil(t, err)
+		assert.True(t, state.FeatureTTL.IsPresent(ss.Features()))
+	})
+	t.Run("Un-successfully set item with ttl without ttl attribute", func(t *testing.T) {
+		ss := StateStore{
+			client: &mockedDynamoDB{
+				PutItemFn: func(input *dynamodb.PutItemInput) (output *dynamodb.PutItemOutput, err error) {
+					t.Error("unexpected put")
+
+					return nil, nil
+				},
+			},
+		}
+		req := &state.SetRequest{
+			Key: "key",
+			Value: value{
+				Value: "value",
+			},
+			Metadata: map[string]string{"ttlInSeconds": "100"},
+		}
+		err := ss.Set(req)
+		assert.NotNil(t, err)
+		assert.False(t, state.FeatureTTL.IsPresent(ss.Features()))
+	})
+	t.Run("Successfully set item with etag", func(t *testing.T) {
+		ss := StateStore{
+			client: &mockedDynamoDB{
+				PutItemFn: func(input *dynamodb.PutItemInput) (output *dynamodb.PutItemOutput, err error) {
+					assert.Equal(t, "#etag = :etag", *input.ConditionExpression)
+					assert.Equal(t, "etag", *input.ExpressionAttributeNames["#etag"])
+					assert.Equal(t, "1", *input.ExpressionAttributeValues[":etag"].S)
+					assert.NotEqual(t, "1", itemETag(input.Item))
+
+					return &dynamodb.PutItemOutput{}, nil
+				},
+			},
+		}
+		req := &state.SetRequest{
+			Key:   "key",
+			Value: value{Value: "value"},
+			ETag:  "1",
+		}
+		err := ss.Set(req)
+		assert.Nil(t, err)
+	})
+	t.Run("Successfully set item with etag and last-write concurrency", func(t *testing.T) {
+		ss := StateStore{
+			client: &mockedDynamoDB{
+				PutItemFn: func(input *dynamodb.PutItemInput) (output *dynamodb.PutItemOutput, err error) {
+					assert.Nil(t, input.ConditionExpression)
+
+					return &dynamodb.PutItemOutput{}, nil
+				},
+			},
+		}
+		req := &state.SetRequest{
+			Key:     "key",
+			Value:   value{Value: "value"},
+			ETag:    "1",
+			Options: state.SetStateOption{Concurrency: state.LastWrite},
+		}
+		err := ss.Set(req)
+		assert.Nil(t, err)
+	})
+	t.Run("Un-successfully set item with etag mismatch", func(t *testing.T) {
+		ss := StateStore{
+			client: &mockedDynamoDB{
+				PutItemFn: func(input *dynamodb.PutItemInput) (output *dynamodb.PutItemOutput, err error) {
+					return nil, awserr.New(dynamodb.ErrCodeConditionalCheckFailedException, "The conditional request failed", nil)
+				},
+			},
+		}
+		req := &state.SetRequest{
+			Key:   "key",
+			Value: value{Value: "value"},
+			ETag:  "1",
+		}
+		err := ss.Set(req)
+		assert.EqualError(t, err, "dynamodb error: etag mismatch of key key")
+	})
 	t.Run("Un-successfully set item", func(t *testing.T) {
 		ss := StateStore{
 			client: &mockedDynamoDB{
@@ -225,6 +379,9 @@ func TestBulkSet(t *testing.T) {
 		ss := StateStore{
 			client: &mockedDynamoDB{
 				BatchWriteItemFn: func(input *dynamodb.BatchWriteItemInput) (output *dynamodb.BatchWriteItemOutput, err error) {
+					for _, r := range input.RequestItems[tableName] {
+						withoutETag(t, r.PutRequest.Item)
+					}
 					expected := map[string][]*dynamodb.WriteRequest{}
 					expected[tableName] = []*dynamodb.WriteRequest{
 						{
@@ -278,6 +435,43 @@ func TestBulkSet(t *testing.T) {
 		err := ss.BulkSet(req)
 		assert.Nil(t, err)
 	})
+	t.Run("Successfully set items with etag one by one", func(t *testing.T) {
+		var puts []string
+		var mu sync.Mutex
+		ss := StateStore{
+			client: &mockedDynamoDB{
+				PutItemFn: func(input *dynamodb.PutItemInput) (output *dynamodb.PutItemOutput, err error) {
+					mu.Lock()
+					defer mu.Unlock()
+					puts = append(puts, *input.Item["key"].S)
+					assert.NotNil(t, input.ConditionExpression)
+
+					return &dynamodb.PutItemOutput{}, nil
+				},
+				BatchWriteItemFn: func(input *dynamodb.BatchWriteItemInput) (output *dynamodb.BatchWriteItemOutput, err error) {
+					assert.Len(t, input.RequestItems["table_name"], 1)
+					assert.Equal(t, "key2", *input.RequestItems["table_name"][0].PutRequest.Item["key"].S)
+
+					return &dynamodb.BatchWriteItemOutput{}, nil
+				},
+			},
+			table: "table_name",
+		}
+		req := []state.SetRequest{
+			{
+				Key:   "key1",
+				Value: value{Value: "value1"},
+				ETag:  "1",
+			},
+			{
+				Key:   "key2",
+				Value: value{Value: "value2"},
+			},
+		}
+		err := ss.BulkSet(req)
+		assert.Nil(t, err)
+		assert.Equal(t, []string{"key1"}, puts)
+	})
 	t.Run("Un-successfully set items", func(t *testing.T) {
 		ss := StateStore{
 			client: &mockedDynamoDB{
@@ -299,6 +493,164 @@ func TestBulkSet(t *testing.T) {
 	})
 }
 
+func TestBulkGet(t *testing.T) {
+	t.Run("Successfully retrieve items", func(t *testing.T) {
+		tableName := "table_name"
+		ss := StateStore{
+			client: &mockedDynamoDB{
+				BatchGetItemFn: func(input *dynamodb.BatchGetItemInput) (output *dynamodb.BatchGetItemOutput, err error) {
+					assert.Equal(t, map[string]*dynamodb.KeysAndAttributes{
+						tableName: {
+							Keys: []map[string]*dynamodb.AttributeValue{
+								{"key": {S: aws.String("key1")}},
+								{"key": {S: aws.String("key2")}},
+								{"key": {S: aws.String("key3")}},
+							},
+							ConsistentRead: aws.Bool(true),
+						},
+					}, input.RequestItems)
+
+					return &dynamodb.BatchGetItemOutput{
+						Responses: map[string][]map[string]*dynamodb.AttributeValue{
+							tableName: {
+								{"key": {S: aws.String("key2")}, "value": {S: aws.String("value2")}},
+								{"key": {S: aws.String("key1")}, "value": {S: aws.String("value1")}},
+							},
+						},
+					}, nil
+				},
+			},
+			table: tableName,
+		}
+		supported, res, err := ss.BulkGet([]state.GetRequest{
+			{Key: "key1"},
+			{Key: "key2", Options: state.GetStateOption{Consistency: state.Strong}},
+			{Key: "key1"},
+			{Key: "key3"},
+		})
+		assert.Nil(t, err)
+		assert.True(t, supported)
+		assert.Equal(t, []state.BulkGetResponse{
+			{Key: "key1", Data: []byte("value1")},
+			{Key: "key2", Data: []byte("value2")},
+			{Key: "key1", Data: []byte("value1")},
+			{Key: "key3"},
+		}, res)
+	})
+	t.Run("Unprocessed keys are retried", func(t *testing.T) {
+		tableName := "table_name"
+		calls := 0
+		ss := StateStore{
+			client: &mockedDynamoDB{
+				BatchGetItemFn: func(input *dynamodb.BatchGetItemInput) (output *dynamodb.BatchGetItemOutput, err error) {
+					calls++
+					if calls == 1 {
+						assert.Len(t, input.RequestItems[tableName].Keys, 2)
+
+						return &dynamodb.BatchGetItemOutput{
+							Responses: map[string][]map[string]*dynamodb.AttributeValue{
+								tableName: {{"key": {S: aws.String("key1")}, "value": {S: aws.String("value1")}}},
+							},
+							UnprocessedKeys: map[string]*dynamodb.KeysAndAttributes{
+								tableName: {Keys: []map[string]*dynamodb.AttributeValue{{"key": {S: aws.String("key2")}}}},
+							},
+						}, nil
+					}
+					assert.Equal(t, []map[string]*dynamodb.AttributeValue{{"key": {S: aws.String("key2")}}}, input.RequestItems[tableName].Keys)
+
+					return &dynamodb.BatchGetItemOutput{
+						Responses: map[string][]map[string]*dynamodb.AttributeValue{
+							tableName: {{"key": {S: aws.String("key2")}, "value": {S: aws.String("value2")}}},
+						},
+					}, nil
+				},
+			},
+			table: tableName,
+		}
+		_, res, err := ss.BulkGet([]state.GetRequest{{Key: "key1"}, {Key: "key2"}})
+		assert.Nil(t, err)
+		assert.Equal(t, 2, calls)
+		assert.Equal(t, []state.BulkGetResponse{
+			{Key: "key1", Data: []byte("value1")},
+			{Key: "key2", Data: []byte("value2")},
+		}, res)
+	})
+	t.Run("Un-successfully retrieve items", func(t *testing.T) {
+		ss := StateStore{
+			client: &mockedDynamoDB{
+				BatchGetItemFn: func(input *dynamodb.BatchGetItemInput) (output *dynamodb.BatchGetItemOutput, err error) {
+					return nil, fmt.Errorf("failed to retrieve data")
+				},
+			},
+		}
+		_, _, err := ss.BulkGet([]state.GetRequest{{Key: "key"}})
+		assert.NotNil(t, err)
+	})
+}
+
+func TestBatchWrite(t *testing.T) {
+	tableName := "table_name"
+
+	t.Run("Requests are split in batches", func(t *testing.T) {
+		var sizes []int
+		ss := StateStore{
+			client: &mockedDynamoDB{
+				BatchWriteItemFn: func(input *dynamodb.BatchWriteItemInput) (output *dynamodb.BatchWriteItemOutput, err error) {
+					sizes = append(sizes, len(input.RequestItems[tableName]))
+
+					return &dynamodb.BatchWriteItemOutput{}, nil
+				},
+			},
+			table: tableName,
+		}
+		req := make([]state.DeleteRequest, 60)
+		for i := range req {
+			req[i].Key = strconv.Itoa(i)
+		}
+		err := ss.BulkDelete(req)
+		assert.Nil(t, err)
+		assert.Equal(t, []int{25, 25, 10}, sizes)
+	})
+	t.Run("Unprocessed items are retried", func(t *testing.T) {
+		calls := 0
+		ss := StateStore{
+			client: &mockedDynamoDB{
+				BatchWriteItemFn: func(input *dynamodb.BatchWriteItemInput) (output *dynamodb.BatchWriteItemOutput, err error) {
+					calls++
+					if calls == 1 {
+						return &dynamodb.BatchWriteItemOutput{
+							UnprocessedItems: map[string][]*dynamodb.WriteRequest{
+								tableName: input.RequestItems[tableName][1:],
+							},
+						}, nil
+					}
+					assert.Len(t, input.RequestItems[tableName], 1)
+
+					return &dynamodb.BatchWriteItemOutput{}, nil
+				},
+			},
+			table: tableName,
+		}
+		err := ss.BulkDelete([]state.DeleteRequest{{Key: "key1"}, {Key: "key2"}})
+		assert.Nil(t, err)
+		assert.Equal(t, 2, calls)
+	})
+	t.Run("Items never processed fail", func(t *testing.T) {
+		ss := StateStore{
+			client: &mockedDynamoDB{
+				BatchWriteItemFn: func(input *dynamodb.BatchWriteItemInput) (output *dynamodb.BatchWriteItemOutput, err error) {
+					return &dynamodb.BatchWriteItemOutput{
+						UnprocessedItems: input.RequestItems,
+					}, nil
+				},
+			},
+			table: tableName,
+		}
+		err := ss.BulkDelete([]state.DeleteRequest{{Key: "key1"}})
+		assert.NotNil(t, err)
+	})
+}
+
 func TestDelete(t *testing.T) {
 	t.Run("Successfully delete item", func(t *testing.T) {
 		req := &state.DeleteRequest{
@@ -322,6 +674,33 @@ func TestDelete(t *testing.T) {
 		assert.Nil(t, err)
 	})
 
+	t.Run("Successfully delete item with etag", func(t *testing.T) {
+		ss := StateStore{
+			client: &mockedDynamoDB{
+				DeleteItemFn: func(input *dynamodb.DeleteItemInput) (output *dynamodb.DeleteItemOutput, err error) {
+					assert.Equal(t, "#etag = :etag", *input.ConditionExpression)
+					assert.Equal(t, "1", *input.ExpressionAttributeValues[":etag"].S)
+
+					return nil, nil
+				},
+			},
+		}
+		err := ss.Delete(&state.DeleteRequest{Key: "key", ETag: "1"})
+		assert.Nil(t, err)
+	})
+
+	t.Run("Un-successfully delete item with etag mismatch", func(t *testing.T) {
+		ss := StateStore{
+			client: &mockedDynamoDB{
+				DeleteItemFn: func(input *dynamodb.DeleteItemInput) (output *dynamodb.DeleteItemOutput, err error) {
+					return nil, awserr.New(dynamodb.ErrCodeConditionalCheckFailedException, "The conditional request failed", nil)
+				},
+			},
+		}
+		err := ss.Delete(&state.DeleteRequest{Key: "key", ETag: "1"})
+		assert.EqualError(t, err, "dynamodb error: etag mismatch of key key")
+	})
+
 	t.Run("Un-successfully delete item", func(t *testing.T) {
 		ss := StateStore{
 			client: &mockedDynamoDB{
@@ -402,3 +781,147 @@ func TestBulkDelete(t *testing.T) {
 		assert.NotNil(t, err)
 	})
 }
+
+func TestMulti(t *testing.T) {
+	type value struct {
+		Value string
+	}
+
+	t.Run("Successfully perform operations in a transaction", func(t *testing.T) {
+		ss := StateStore{
+			client: &mockedDynamoDB{
+				TransactWriteItemsFn: func(input *dynamodb.TransactWriteItemsInput) (*dynamodb.TransactWriteItemsOutput, error) {
+					assert.Len(t, input.TransactItems, 2)
+
+					put := input.TransactItems[0].Put
+					assert.Equal(t, "table_name", *put.TableName)
+					assert.Equal(t, "key1", *put.Item["key"].S)
+					assert.Equal(t, `{"Value":"value1"}`, *put.Item["value"].S)
+					assert.NotEmpty(t, itemETag(put.Item))
+					assert.Nil(t, put.ConditionExpression)
+
+					del := input.TransactItems[1].Delete
+					assert.Equal(t, "key2", *del.Key["key"].S)
+					assert.Equal(t, "#etag = :etag", *del.ConditionExpression)
+					assert.Equal(t, "1", *del.ExpressionAttributeValues[":etag"].S)
+
+					return &dynamodb.TransactWriteItemsOutput{}, nil
+				},
+			},
+			table: "table_name",
+		}
+		err := ss.Multi(&state.TransactionalStateRequest{
+			Operations: []state.TransactionalStateOperation{
+				{Operation: state.Upsert, Request: state.SetRequest{Key: "key1", Value: value{Value: "value1"}}},
+				{Operation: state.Delete, Request: state.DeleteRequest{Key: "key2", ETag: "1"}},
+			},
+		})
+		assert.Nil(t, err)
+	})
+
+	t.Run("Un-successfully perform a transaction with etag mismatch", func(t *testing.T) {
+		ss := StateStore{
+			client: &mockedDynamoDB{
+				TransactWriteItemsFn: func(input *dynamodb.TransactWriteItemsInput) (*dynamodb.TransactWriteItemsOutput, error) {
+					return nil, awserr.New(dynamodb.ErrCodeTransactionCanceledException,
+						"Transaction cancelled, please refer cancellation reasons for specific reasons [ConditionalCheckFailed, None]", nil)
+				},
+			},
+		}
+		err := ss.Multi(&state.TransactionalStateRequest{
+			Operations: []state.TransactionalStateOperation{
+				{Operation: state.Upsert, Request: state.SetRequest{Key: "key1", Value: value{Value: "value1"}, ETag: "1"}},
+				{Operation: state.Delete, Request: state.DeleteRequest{Key: "key2"}},
+			},
+		})
+		assert.Error(t, err)
+		assert.Contains(t, err.Error(), "etag mismatch")
+	})
+
+	t.Run("Un-successfully perform invalid transactions", func(t *testing.T) {
+		ss := StateStore{
+			client: &mockedDynamoDB{
+				TransactWriteItemsFn: func(input *dynamodb.TransactWriteItemsInput) (*dynamodb.TransactWriteItemsOutput, error) {
+					t.Error("unexpected transaction")
+
+					return nil, nil
+				},
+			},
+		}
+		invalid := map[string][]state.TransactionalStateOperation{
+			"invalid set request":    {{Operation: state.Upsert, Request: state.DeleteRequest{Key: "key"}}},
+			"invalid delete request": {{Operation: state.Delete, Request: state.SetRequest{Key: "key"}}},
+			"invalid action":         {{Operation: "Something invalid", Request: state.SetRequest{Key: "key"}}},
+			"too many operations":    make([]state.TransactionalStateOperation, maxTransactWriteSize+1),
+		}
+		for name, operations := range invalid {
+			err := ss.Multi(&state.TransactionalStateRequest{Operations: operations})
+			assert.Error(t, err, name)
+		}
+	})
+}
+
+func TestList(t *testing.T) {
+	t.Run("Successfully list a page", func(t *testing.T) {
+		ss := StateStore{
+			client: &mockedDynamoDB{
+				ScanFn: func(input *dynamodb.ScanInput) (*dynamodb.ScanOutput, error) {
+					assert.Equal(t, "begins_with(#key, :prefix)", *input.FilterExpression)
+					assert.Equal(t, "order-", *input.ExpressionAttributeValues[":prefix"].S)
+					assert.Equal(t, int64(2), *input.Limit)
+					assert.Equal(t, "order-1", *input.ExclusiveStartKey["key"].S)
+
+					return &dynamodb.ScanOutput{
+						Items: []map[string]*dynamodb.AttributeValue{
+							{"key": {S: aws.String("order-2")}, "value": {S: aws.String("pending")}, etagAttributeName: {S: aws.String("etag2")}},
+							{"key": {S: aws.String("order-3")}, "value": {S: aws.String("shipped")}, "ttl": {N: aws.String("1")}},
+						},
+						LastEvaluatedKey: map[string]*dynamodb.AttributeValue{"key": {S: aws.String("order-3")}},
+					}, nil
+				},
+			},
+			table:            "table_name",
+			ttlAttributeName: "ttl",
+		}
+
+		res, err := ss.List(&state.ListRequest{Prefix: "order-", Limit: 2, Token: "order-1"})
+
+		assert.Nil(t, err)
+		assert.Equal(t, []state.QueryItem{{Key: "order-2", Data: []byte("pending"), ETag: "etag2"}}, res.Items, "the expired items are skipped")
+		assert.Equal(t, "order-3", res.Token)
+	})
+
+	t.Run("Last page without prefix", func(t *testing.T) {
+		ss := StateStore{
+			client: &mockedDynamoDB{
+				ScanFn: func(input *dynamodb.ScanInput) (*dynamodb.ScanOutput, error) {
+					assert.Nil(t, input.FilterExpression)
+					assert.Nil(t, input.ExclusiveStartKey)
+					assert.Equal(t, int64(state.DefaultListLimit), *input.Limit)
+
+					return &dynamodb.ScanOutput{}, nil
+				},
+			},
+		}
+
+		res, err := ss.List(&state.ListRequest{})
+
+		assert.Nil(t, err)
+		assert.Empty(t, res.Items)
+		assert.Empty(t, res.Token)
+	})
+
+	t.Run("Unsuccessfully list", func(t *testing.T) {
+		ss := StateStore{
+			client: &mockedDynamoDB{
+				ScanFn: func(input *dynamodb.ScanInput) (*dynamodb.ScanOutput, error) {
+					return nil, fmt.Errorf("failed to scan")
+				},
+			},
+		}
+
+		_, err := ss.List(&state.ListRequest{})
+
+		assert.NotNil(t, err)
+	})
+}
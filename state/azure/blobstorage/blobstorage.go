@@ -119,6 +119,9 @@ func (r *StateStore) Get(req *state.GetRequest) (*state.GetResponse, error) {
 // Set the state
 func (r *StateStore) Set(req *state.SetRequest) error {
 	r.logger.Debugf("saving %s", req.Key)
+	if err := state.RejectTTL(req.Metadata); err != nil {
+		return err
+	}
 
 	return r.writeFile(req)
 }
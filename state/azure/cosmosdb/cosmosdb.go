@@ -8,22 +8,26 @@ package cosmosdb
 import (
 	"bytes"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"strings"
 
 	"github.com/a8m/documentdb"
 	"github.com/dapr/components-contrib/state"
+	"github.com/dapr/components-contrib/state/query"
 	"github.com/dapr/dapr/pkg/logger"
 	jsoniter "github.com/json-iterator/go"
 )
 
 // StateStore is a CosmosDB state store
 type StateStore struct {
-	state.DefaultBulkStore
 	client     *documentdb.DocumentDB
 	collection *documentdb.Collection
 	db         *documentdb.Database
 	sp         *documentdb.Sproc
+	batch      *batchClient
+	bulk       state.BulkConfig
+	partition  partitioning
 
 	logger logger.Logger
 }
@@ -41,6 +45,9 @@ type CosmosItem struct {
 	ID           string      `json:"id"`
 	Value        interface{} `json:"value"`
 	PartitionKey string      `json:"partitionKey"`
+	// TTL is the time to live of the item in seconds, or -1 to never expire.
+	// It requires the time to live to be enabled on the collection.
+	TTL *int `json:"ttl,omitempty"`
 }
 
 // CosmosItemWithRawMessage is a version of CosmosItem with a Value of RawMessage so this field
@@ -51,6 +58,7 @@ type CosmosItemWithRawMessage struct {
 	ID           string              `json:"id"`
 	Value        jsoniter.RawMessage `json:"value"`
 	PartitionKey string              `json:"partitionKey"`
+	TTL          *int                `json:"ttl,omitempty"`
 }
 
 type storedProcedureDefinition struct {
@@ -58,16 +66,31 @@ type storedProcedureDefinition struct {
 	Body string `json:"body"`
 }
 
+// partitioning derives the partition keys of the items from their keys, unless the request metadata sets it
+type partitioning struct {
+	strategy     string
+	separator    string
+	metadataName string
+}
+
 const (
 	storedProcedureName  = "__dapr__"
 	metadataPartitionKey = "partitionKey"
-	unknownPartitionKey  = "__UNKNOWN__"
+
+	partitionKeyStrategyKey     = "partitionKeyStrategy"
+	partitionKeySeparatorKey    = "partitionKeySeparator"
+	partitionKeyMetadataNameKey = "partitionKeyMetadataName"
+
+	// keyStrategy uses the full key as partition key
+	keyStrategy = "key"
+	// keyPrefixStrategy uses the part of the key before its last separator as partition key
+	keyPrefixStrategy            = "keyPrefix"
+	defaultPartitionKeySeparator = "||"
 )
 
 // NewCosmosDBStateStore returns a new CosmosDB state store
 func NewCosmosDBStateStore(logger logger.Logger) *StateStore {
 	s := &StateStore{logger: logger}
-	s.DefaultBulkStore = state.NewDefaultBulkStore(s)
 
 	return s
 }
@@ -88,6 +111,21 @@ func (c *StateStore) Init(metadata state.Metadata) error {
 		return err
 	}
 
+	c.bulk, err = state.ParseBulkConfig(metadata.Properties)
+	if err != nil {
+		return err
+	}
+
+	c.partition, err = parsePartitioning(metadata.Properties)
+	if err != nil {
+		return err
+	}
+
+	c.batch, err = newBatchClient(creds)
+	if err != nil {
+		return err
+	}
+
 	client := documentdb.New(creds.URL, &documentdb.Config{
 		MasterKey: &documentdb.Key{
 			Key: creds.MasterKey,
@@ -157,7 +195,7 @@ func (c *StateStore) Init(metadata state.Metadata) error {
 func (c *StateStore) Get(req *state.GetRequest) (*state.GetResponse, error) {
 	key := req.Key
 
-	partitionKey := populatePartitionMetadata(req.Key, req.Metadata)
+	partitionKey := c.partitionKey(req.Key, req.Metadata)
 	items := []CosmosItem{}
 	options := []documentdb.CallOption{documentdb.PartitionKey(partitionKey)}
 	if req.Options.Consistency == state.Strong {
@@ -197,7 +235,12 @@ func (c *StateStore) Set(req *state.SetRequest) error {
 		return err
 	}
 
-	partitionKey := populatePartitionMetadata(req.Key, req.Metadata)
+	ttl, err := state.ParseTTL(req.Metadata)
+	if err != nil {
+		return err
+	}
+
+	partitionKey := c.partitionKey(req.Key, req.Metadata)
 	options := []documentdb.CallOption{documentdb.PartitionKey(partitionKey)}
 
 	if req.ETag != "" {
@@ -213,7 +256,7 @@ func (c *StateStore) Set(req *state.SetRequest) error {
 	b, ok := req.Value.([]uint8)
 	if ok {
 		// data arrived in bytes and already json.  Don't marshal the Value field again.
-		item := CosmosItemWithRawMessage{ID: req.Key, Value: b, PartitionKey: partitionKey}
+		item := CosmosItemWithRawMessage{ID: req.Key, Value: b, PartitionKey: partitionKey, TTL: ttl}
 		var marshalled []byte
 		marshalled, err = convertToJSONWithoutEscapes(item)
 		if err != nil {
@@ -222,7 +265,7 @@ func (c *StateStore) Set(req *state.SetRequest) error {
 		_, err = c.client.UpsertDocument(c.collection.Self, marshalled, options...)
 	} else {
 		// data arrived as non-bytes, just pass it through.
-		_, err = c.client.UpsertDocument(c.collection.Self, CosmosItem{ID: req.Key, Value: req.Value, PartitionKey: partitionKey}, options...)
+		_, err = c.client.UpsertDocument(c.collection.Self, CosmosItem{ID: req.Key, Value: req.Value, PartitionKey: partitionKey, TTL: ttl}, options...)
 	}
 
 	if err != nil {
@@ -239,7 +282,7 @@ func (c *StateStore) Delete(req *state.DeleteRequest) error {
 		return err
 	}
 
-	partitionKey := populatePartitionMetadata(req.Key, req.Metadata)
+	partitionKey := c.partitionKey(req.Key, req.Metadata)
 	options := []documentdb.CallOption{documentdb.PartitionKey(partitionKey)}
 
 	items := []CosmosItem{}
@@ -273,62 +316,227 @@ func (c *StateStore) Delete(req *state.DeleteRequest) error {
 	return err
 }
 
-// Multi performs a transactional operation. succeeds only if all operations succeed, and fails if one or more operations fail
+// Multi performs the operations in a transactional batch, which fails when one of the operations fails.
+// The items of a transaction must have the same partition key, and a transaction has at most 100 operations.
 func (c *StateStore) Multi(request *state.TransactionalStateRequest) error {
-	upserts := []CosmosItem{}
-	deletes := []CosmosItem{}
-
-	partitionKey := unknownPartitionKey
+	if len(request.Operations) == 0 {
+		return nil
+	}
+	if len(request.Operations) > maxTransactionalBatchSize {
+		return fmt.Errorf("a transaction has at most %d operations, it has %d", maxTransactionalBatchSize, len(request.Operations))
+	}
 
-	for _, o := range request.Operations {
-		t := o.Request.(state.KeyInt)
-		key := t.GetKey()
+	operations := make([]batchOperation, len(request.Operations))
+	partitionKey := ""
+	for i, o := range request.Operations {
+		var (
+			op               batchOperation
+			itemPartitionKey string
+			err              error
+		)
+		switch o.Operation {
+		case state.Upsert:
+			op, itemPartitionKey, err = c.upsertOperation(o.Request, request.Metadata)
+		case state.Delete:
+			op, itemPartitionKey, err = c.deleteOperation(o.Request, request.Metadata)
+		default:
+			err = fmt.Errorf("unsupported operation: %s", o.Operation)
+		}
+		if err != nil {
+			return err
+		}
 
-		partitionKey = populatePartitionMetadata(key, request.Metadata)
-		if o.Operation == state.Upsert {
-			req := o.Request.(state.SetRequest)
+		if i > 0 && itemPartitionKey != partitionKey {
+			return fmt.Errorf("the items of a transaction must have the same partition key, %s and %s differ", partitionKey, itemPartitionKey)
+		}
+		partitionKey = itemPartitionKey
+		operations[i] = op
+	}
 
-			// Value need not be marshaled here. It is handled by cosmosdb client.
-			upsertOperation := CosmosItem{
-				ID:           req.Key,
-				Value:        req.Value,
-				PartitionKey: partitionKey,
-			}
+	c.logger.Debugf("#operations=%d, partitionkey=%s", len(operations), partitionKey)
 
-			upserts = append(upserts, upsertOperation)
-		} else if o.Operation == state.Delete {
-			req := o.Request.(state.DeleteRequest)
+	for {
+		err := c.batch.execute(partitionKey, operations)
+		var opErr *batchOperationError
+		if !errors.As(err, &opErr) || !opErr.missingDelete() {
+			return err
+		}
 
-			deleteOperation := CosmosItem{
-				ID:           req.Key,
-				Value:        "", // Value does not need to be specified
-				PartitionKey: partitionKey,
-			}
-			deletes = append(deletes, deleteOperation)
+		// deleting a missing item succeeds, as with Delete, so the batch is executed again without its delete
+		operations = append(operations[:opErr.index], operations[opErr.index+1:]...)
+		if len(operations) == 0 {
+			return nil
 		}
 	}
+}
+
+// upsertOperation returns the batch operation of a set request, along with the partition key of its item
+func (c *StateStore) upsertOperation(request interface{}, metadata map[string]string) (batchOperation, string, error) {
+	req, ok := request.(state.SetRequest)
+	if !ok {
+		return batchOperation{}, "", fmt.Errorf("expecting set request")
+	}
+	if err := state.CheckRequestOptions(req.Options); err != nil {
+		return batchOperation{}, "", err
+	}
 
-	c.logger.Debugf("#upserts=%d,#deletes=%d, partitionkey=%s", len(upserts), len(deletes), partitionKey)
+	partitionKey := c.partitionKey(req.Key, req.Metadata, metadata)
+	item, err := upsertItem(&req, partitionKey)
+	if err != nil {
+		return batchOperation{}, "", err
+	}
 
-	options := []documentdb.CallOption{documentdb.PartitionKey(partitionKey)}
-	var retString string
+	return batchOperation{
+		OperationType: batchUpsert,
+		IfMatch:       req.ETag,
+		ResourceBody:  item,
+	}, partitionKey, nil
+}
+
+// deleteOperation returns the batch operation of a delete request, along with the partition key of its item
+func (c *StateStore) deleteOperation(request interface{}, metadata map[string]string) (batchOperation, string, error) {
+	req, ok := request.(state.DeleteRequest)
+	if !ok {
+		return batchOperation{}, "", fmt.Errorf("expecting delete request")
+	}
+	if err := state.CheckRequestOptions(req.Options); err != nil {
+		return batchOperation{}, "", err
+	}
+
+	return batchOperation{
+		OperationType: batchDelete,
+		ID:            req.Key,
+		IfMatch:       req.ETag,
+	}, c.partitionKey(req.Key, req.Metadata, metadata), nil
+}
+
+// Query returns the items matching a query. The query spans every partition, unless
+// metadata["partitionKey"] is present.
+func (c *StateStore) Query(req *state.QueryRequest) (*state.QueryResponse, error) {
+	q := &cosmosQuery{}
+	err := query.NewQueryBuilder(q).BuildQuery(&req.Query)
+	if err != nil {
+		return nil, err
+	}
+
+	options := []documentdb.CallOption{}
+	if partitionKey, found := req.Metadata[c.partition.metadataName]; found {
+		options = append(options, documentdb.PartitionKey(partitionKey))
+	} else {
+		options = append(options, documentdb.CrossPartition())
+	}
+	if q.limit > 0 {
+		options = append(options, documentdb.Limit(q.limit))
+	}
+	if q.token != "" {
+		options = append(options, documentdb.Continuation(q.token))
+	}
 
-	// The stored procedure throws if it failed, which sets err to non-nil.  It doesn't return anything else.
-	err := c.client.ExecuteStoredProcedure(c.sp.Self, [...]interface{}{upserts, deletes}, &retString, options...)
+	items := []CosmosItem{}
+	resp, err := c.client.QueryDocuments(c.collection.Self, &q.query, &items, options...)
 	if err != nil {
-		c.logger.Debugf("error=%e", err)
+		return nil, err
+	}
 
-		return err
+	return &state.QueryResponse{
+		Results:  queryItems(items),
+		Token:    resp.Continuation(),
+		Metadata: req.Metadata,
+	}, nil
+}
+
+// List returns a page of the keys with a prefix with a query on the ids of the items, whose continuation token is
+// the token of the next page. The query spans every partition, unless the partitionKey metadata is set.
+func (c *StateStore) List(req *state.ListRequest) (*state.ListResponse, error) {
+	q := &documentdb.Query{
+		Query:      "SELECT * FROM c WHERE STARTSWITH(c.id, @prefix)",
+		Parameters: []documentdb.Parameter{{Name: "@prefix", Value: req.Prefix}},
 	}
 
-	return nil
+	options := []documentdb.CallOption{documentdb.Limit(req.PageLimit())}
+	if partitionKey, found := req.Metadata[c.partition.metadataName]; found {
+		options = append(options, documentdb.PartitionKey(partitionKey))
+	} else {
+		options = append(options, documentdb.CrossPartition())
+	}
+	if req.Token != "" {
+		options = append(options, documentdb.Continuation(req.Token))
+	}
+
+	items := []CosmosItem{}
+	resp, err := c.client.QueryDocuments(c.collection.Self, q, &items, options...)
+	if err != nil {
+		return nil, err
+	}
+
+	return &state.ListResponse{
+		Items:    queryItems(items),
+		Token:    resp.Continuation(),
+		Metadata: req.Metadata,
+	}, nil
+}
+
+// queryItems returns the keys and values of the items read by a query
+func queryItems(items []CosmosItem) []state.QueryItem {
+	results := make([]state.QueryItem, len(items))
+	for i := range items {
+		results[i] = state.QueryItem{
+			Key:  items[i].ID,
+			ETag: items[i].Etag,
+		}
+		b, err := jsoniter.ConfigFastest.Marshal(&items[i].Value)
+		if err != nil {
+			results[i].Error = err.Error()
+		} else {
+			results[i].Data = b
+		}
+	}
+
+	return results
+}
+
+// Features returns the features of the CosmosDB state store
+func (c *StateStore) Features() []state.Feature {
+	return []state.Feature{state.FeatureTTL}
+}
+
+// parsePartitioning parses the partition key strategy of the component metadata
+func parsePartitioning(properties map[string]string) (partitioning, error) {
+	p := partitioning{
+		strategy:     keyStrategy,
+		separator:    defaultPartitionKeySeparator,
+		metadataName: metadataPartitionKey,
+	}
+
+	if val, ok := properties[partitionKeyStrategyKey]; ok && val != "" {
+		if val != keyStrategy && val != keyPrefixStrategy {
+			return p, fmt.Errorf("invalid %s %s, it must be %s or %s", partitionKeyStrategyKey, val, keyStrategy, keyPrefixStrategy)
+		}
+		p.strategy = val
+	}
+	if val, ok := properties[partitionKeySeparatorKey]; ok && val != "" {
+		p.separator = val
+	}
+	if val, ok := properties[partitionKeyMetadataNameKey]; ok && val != "" {
+		p.metadataName = val
+	}
+
+	return p, nil
 }
 
-// This is a helper to return the partition key to use.  If if metadata["partitionkey"] is present,
-// use that, otherwise use what's in "key".
-func populatePartitionMetadata(key string, requestMetadata map[string]string) string {
-	if val, found := requestMetadata[metadataPartitionKey]; found {
-		return val
+// partitionKey returns the partition key of an item: the value of the partition key metadata of the first
+// metadata setting it, otherwise the key or its prefix depending on the strategy.
+func (c *StateStore) partitionKey(key string, metadata ...map[string]string) string {
+	for _, m := range metadata {
+		if val, found := m[c.partition.metadataName]; found {
+			return val
+		}
+	}
+
+	if c.partition.strategy == keyPrefixStrategy {
+		if i := strings.LastIndex(key, c.partition.separator); i > 0 {
+			return key[:i]
+		}
 	}
 
 	return key
@@ -0,0 +1,95 @@
+// ------------------------------------------------------------
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT License.
+// ------------------------------------------------------------
+
+package cosmosdb
+
+import (
+	"testing"
+
+	"github.com/dapr/components-contrib/state"
+	"github.com/dapr/dapr/pkg/logger"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParsePartitioning(t *testing.T) {
+	t.Run("defaults", func(t *testing.T) {
+		p, err := parsePartitioning(map[string]string{})
+
+		require.NoError(t, err)
+		assert.Equal(t, partitioning{strategy: keyStrategy, separator: "||", metadataName: "partitionKey"}, p)
+	})
+
+	t.Run("key prefix", func(t *testing.T) {
+		p, err := parsePartitioning(map[string]string{
+			partitionKeyStrategyKey:     keyPrefixStrategy,
+			partitionKeySeparatorKey:    "|",
+			partitionKeyMetadataNameKey: "tenant",
+		})
+
+		require.NoError(t, err)
+		assert.Equal(t, partitioning{strategy: keyPrefixStrategy, separator: "|", metadataName: "tenant"}, p)
+	})
+
+	t.Run("invalid strategy", func(t *testing.T) {
+		_, err := parsePartitioning(map[string]string{partitionKeyStrategyKey: "hash"})
+
+		assert.Error(t, err)
+	})
+}
+
+func TestPartitionKey(t *testing.T) {
+	t.Run("full key", func(t *testing.T) {
+		c := &StateStore{partition: partitioning{strategy: keyStrategy, metadataName: metadataPartitionKey}}
+
+		assert.Equal(t, "app||order-1", c.partitionKey("app||order-1", nil))
+		assert.Equal(t, "tenant", c.partitionKey("app||order-1", map[string]string{"partitionKey": "tenant"}))
+	})
+
+	t.Run("key prefix", func(t *testing.T) {
+		c := &StateStore{partition: partitioning{strategy: keyPrefixStrategy, separator: "||", metadataName: metadataPartitionKey}}
+
+		assert.Equal(t, "app||order-1", c.partitionKey("app||order-1||item-2", nil))
+		assert.Equal(t, "app", c.partitionKey("app||order-1", nil))
+		assert.Equal(t, "order", c.partitionKey("order", nil))
+	})
+
+	t.Run("the first metadata setting it wins", func(t *testing.T) {
+		c := &StateStore{partition: partitioning{strategy: keyStrategy, metadataName: "tenant"}}
+
+		assert.Equal(t, "request", c.partitionKey("key", map[string]string{"tenant": "request"}, map[string]string{"tenant": "transaction"}))
+		assert.Equal(t, "transaction", c.partitionKey("key", map[string]string{"partitionKey": "ignored"}, map[string]string{"tenant": "transaction"}))
+	})
+}
+
+func TestMultiValidation(t *testing.T) {
+	c := &StateStore{
+		partition: partitioning{strategy: keyPrefixStrategy, separator: "||", metadataName: metadataPartitionKey},
+		logger:    logger.NewLogger("test"),
+	}
+
+	t.Run("no operations", func(t *testing.T) {
+		assert.NoError(t, c.Multi(&state.TransactionalStateRequest{}))
+	})
+
+	invalid := map[string][]state.TransactionalStateOperation{
+		"different partition keys": {
+			{Operation: state.Upsert, Request: state.SetRequest{Key: "app||order-1||items", Value: "value"}},
+			{Operation: state.Delete, Request: state.DeleteRequest{Key: "app||order-2||items"}},
+		},
+		"invalid set request":    {{Operation: state.Upsert, Request: state.DeleteRequest{Key: "key"}}},
+		"invalid delete request": {{Operation: state.Delete, Request: state.SetRequest{Key: "key"}}},
+		"invalid action":         {{Operation: "Something invalid", Request: state.SetRequest{Key: "key"}}},
+		"too many operations":    make([]state.TransactionalStateOperation, maxTransactionalBatchSize+1),
+	}
+	for name, operations := range invalid {
+		operations := operations
+		t.Run(name, func(t *testing.T) {
+			err := c.Multi(&state.TransactionalStateRequest{Operations: operations})
+
+			assert.Error(t, err)
+		})
+	}
+}
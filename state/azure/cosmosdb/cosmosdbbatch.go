@@ -0,0 +1,171 @@
+// ------------------------------------------------------------
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT License.
+// ------------------------------------------------------------
+
+package cosmosdb
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+const (
+	// batchAPIVersion is the version of the REST API of the transactional batches
+	batchAPIVersion = "2018-12-31"
+	// maxTransactionalBatchSize is the maximum number of operations of a transactional batch
+	maxTransactionalBatchSize = 100
+	batchTimeout              = 30 * time.Second
+
+	batchUpsert = "Upsert"
+	batchDelete = "Delete"
+
+	// statusFailedDependency is the status of the operations of a failed batch which did not fail themselves
+	statusFailedDependency = 424
+)
+
+// batchOperation is an operation of a transactional batch
+type batchOperation struct {
+	OperationType string      `json:"operationType"`
+	ID            string      `json:"id,omitempty"`
+	IfMatch       string      `json:"ifMatch,omitempty"`
+	ResourceBody  interface{} `json:"resourceBody,omitempty"`
+}
+
+// batchOperationResult is the result of an operation of a transactional batch
+type batchOperationResult struct {
+	StatusCode int `json:"statusCode"`
+}
+
+// batchClient executes the transactional batches of a collection, which the documentdb client doesn't support,
+// with the REST API
+type batchClient struct {
+	httpClient *http.Client
+	url        string
+	link       string
+	masterKey  []byte
+}
+
+func newBatchClient(creds credentials) (*batchClient, error) {
+	masterKey, err := base64.StdEncoding.DecodeString(creds.MasterKey)
+	if err != nil {
+		return nil, fmt.Errorf("invalid CosmosDB master key: %s", err)
+	}
+	link := fmt.Sprintf("dbs/%s/colls/%s", creds.Database, creds.Collection)
+
+	return &batchClient{
+		httpClient: &http.Client{Timeout: batchTimeout},
+		url:        strings.TrimSuffix(creds.URL, "/") + "/" + link + "/docs",
+		link:       link,
+		masterKey:  masterKey,
+	}, nil
+}
+
+// execute executes the operations of a partition key atomically: they all fail when one of them fails
+func (b *batchClient) execute(partitionKey string, operations []batchOperation) error {
+	body, err := json.Marshal(operations)
+	if err != nil {
+		return err
+	}
+	partitionKeyHeader, err := json.Marshal([]string{partitionKey})
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequest(http.MethodPost, b.url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	date := time.Now().UTC().Format(http.TimeFormat)
+	req.Header.Set("Authorization", masterKeyAuthorization(b.masterKey, http.MethodPost, "docs", b.link, date))
+	req.Header.Set("x-ms-date", date)
+	req.Header.Set("x-ms-version", batchAPIVersion)
+	req.Header.Set("x-ms-documentdb-partitionkey", string(partitionKeyHeader))
+	req.Header.Set("x-ms-cosmos-is-batch-request", "True")
+	req.Header.Set("x-ms-cosmos-batch-atomic", "True")
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := b.httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	respBody, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+
+	return batchError(resp.StatusCode, respBody, operations)
+}
+
+// batchError returns the error of the response of a transactional batch, which has the results of the operations
+// when one of them failed
+func batchError(statusCode int, body []byte, operations []batchOperation) error {
+	if statusCode == http.StatusOK {
+		return nil
+	}
+
+	results := []batchOperationResult{}
+	if statusCode != http.StatusMultiStatus || json.Unmarshal(body, &results) != nil {
+		return fmt.Errorf("transactional batch failed with status %d: %s", statusCode, string(body))
+	}
+
+	for i, result := range results {
+		if result.StatusCode < 300 || result.StatusCode == statusFailedDependency || i >= len(operations) {
+			continue
+		}
+
+		return &batchOperationError{index: i, operation: operations[i], statusCode: result.StatusCode}
+	}
+
+	return fmt.Errorf("transactional batch failed with status %d", statusCode)
+}
+
+// batchOperationError is the error of the operation which failed a transactional batch
+type batchOperationError struct {
+	index      int
+	operation  batchOperation
+	statusCode int
+}
+
+func (e *batchOperationError) Error() string {
+	if e.statusCode == http.StatusPreconditionFailed {
+		return fmt.Sprintf("transactional batch failed: etag mismatch of key %s", e.operation.key())
+	}
+
+	return fmt.Sprintf("transactional batch failed: %s of key %s failed with status %d", e.operation.OperationType, e.operation.key(), e.statusCode)
+}
+
+// missingDelete tells whether the operation is the delete of a missing item without etag, which is not an error
+func (e *batchOperationError) missingDelete() bool {
+	return e.statusCode == http.StatusNotFound && e.operation.OperationType == batchDelete && e.operation.IfMatch == ""
+}
+
+// key returns the key of the item of an operation
+func (o batchOperation) key() string {
+	if item, ok := o.ResourceBody.(CosmosItem); ok {
+		return item.ID
+	}
+
+	return o.ID
+}
+
+// masterKeyAuthorization returns the authorization of a request on a resource, signed with the master key
+func masterKeyAuthorization(masterKey []byte, verb, resourceType, resourceLink, date string) string {
+	payload := strings.ToLower(verb) + "\n" + strings.ToLower(resourceType) + "\n" + resourceLink + "\n" + strings.ToLower(date) + "\n" + "" + "\n"
+	mac := hmac.New(sha256.New, masterKey)
+	mac.Write([]byte(payload))
+	signature := base64.StdEncoding.EncodeToString(mac.Sum(nil))
+
+	return url.QueryEscape("type=master&ver=1.0&sig=" + signature)
+}
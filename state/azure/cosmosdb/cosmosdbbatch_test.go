@@ -0,0 +1,104 @@
+// ------------------------------------------------------------
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT License.
+// ------------------------------------------------------------
+
+package cosmosdb
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMasterKeyAuthorization(t *testing.T) {
+	// example of https://docs.microsoft.com/rest/api/cosmos-db/access-control-on-cosmosdb-resources
+	masterKey, err := base64.StdEncoding.DecodeString("dsZQi3KtZmCv1ljt3VNWNm7sQUF1y5rJfC6kv5JiwvW0EndXdDku/dkKBp8/ufDToSxLzR4y+O/0H/t4bQtVNw==")
+	require.NoError(t, err)
+
+	authorization := masterKeyAuthorization(masterKey, "GET", "dbs", "dbs/ToDoList", "Thu, 27 Apr 2017 00:51:12 GMT")
+
+	decoded, err := url.QueryUnescape(authorization)
+	require.NoError(t, err)
+	assert.Equal(t, "type=master&ver=1.0&sig=c09PEVJrgp2uQRkr934kFbTqhByc7TVr3OHyqlu+c+c=", decoded)
+}
+
+func TestBatchError(t *testing.T) {
+	operations := []batchOperation{
+		{OperationType: batchUpsert, ResourceBody: CosmosItem{ID: "weapon"}},
+		{OperationType: batchDelete, ID: "ship", IfMatch: "etag"},
+	}
+
+	t.Run("success", func(t *testing.T) {
+		assert.NoError(t, batchError(http.StatusOK, nil, operations))
+	})
+
+	t.Run("etag mismatch", func(t *testing.T) {
+		err := batchError(http.StatusMultiStatus, []byte(`[{"statusCode": 424}, {"statusCode": 412}]`), operations)
+
+		assert.EqualError(t, err, "transactional batch failed: etag mismatch of key ship")
+	})
+
+	t.Run("failed operation", func(t *testing.T) {
+		err := batchError(http.StatusMultiStatus, []byte(`[{"statusCode": 413}, {"statusCode": 424}]`), operations)
+
+		assert.EqualError(t, err, "transactional batch failed: Upsert of key weapon failed with status 413")
+	})
+
+	t.Run("missing item", func(t *testing.T) {
+		err := batchError(http.StatusMultiStatus, []byte(`[{"statusCode": 424}, {"statusCode": 404}]`), operations)
+
+		var opErr *batchOperationError
+		require.True(t, errors.As(err, &opErr))
+		assert.Equal(t, 1, opErr.index)
+		assert.False(t, opErr.missingDelete(), "the delete has an etag")
+
+		opErr.operation.IfMatch = ""
+		assert.True(t, opErr.missingDelete())
+	})
+
+	t.Run("failed batch", func(t *testing.T) {
+		err := batchError(http.StatusBadRequest, []byte(`{"message": "bad request"}`), operations)
+
+		assert.Error(t, err)
+	})
+}
+
+func TestBatchClientExecute(t *testing.T) {
+	var (
+		headers    http.Header
+		operations []map[string]interface{}
+	)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		headers = r.Header
+		body, _ := ioutil.ReadAll(r.Body)
+		json.Unmarshal(body, &operations)
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	b := &batchClient{httpClient: server.Client(), url: server.URL, link: "dbs/db/colls/coll", masterKey: []byte("key")}
+	err := b.execute("partition", []batchOperation{
+		{OperationType: batchUpsert, ResourceBody: CosmosItem{ID: "weapon", Value: "deathstar", PartitionKey: "partition"}},
+		{OperationType: batchDelete, ID: "ship", IfMatch: "etag"},
+	})
+
+	require.NoError(t, err)
+	assert.Equal(t, `["partition"]`, headers.Get("x-ms-documentdb-partitionkey"))
+	assert.Equal(t, "True", headers.Get("x-ms-cosmos-is-batch-request"))
+	assert.Equal(t, "True", headers.Get("x-ms-cosmos-batch-atomic"))
+	assert.NotEmpty(t, headers.Get("Authorization"))
+	require.Len(t, operations, 2)
+	assert.Equal(t, "Upsert", operations[0]["operationType"])
+	assert.Equal(t, "weapon", operations[0]["resourceBody"].(map[string]interface{})["id"])
+	assert.Equal(t, "Delete", operations[1]["operationType"])
+	assert.Equal(t, "etag", operations[1]["ifMatch"])
+}
@@ -0,0 +1,240 @@
+// ------------------------------------------------------------
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT License.
+// ------------------------------------------------------------
+
+package cosmosdb
+
+import (
+	"encoding/json"
+	"strconv"
+	"strings"
+
+	"github.com/a8m/documentdb"
+	"github.com/dapr/components-contrib/state"
+	jsoniter "github.com/json-iterator/go"
+)
+
+// maxBatchSize is the maximum number of items of the batches of the bulk operations, which are the calls
+// of the stored procedure and the queries of the bulk gets
+const maxBatchSize = 100
+
+// partitionGroup is the indexes of the requests of a bulk operation on the same partition key
+type partitionGroup struct {
+	partitionKey string
+	indexes      []int
+}
+
+// partitionGroups groups the indexes of the requests by partition key, in the order of the requests
+func partitionGroups(indexes []int, partitionKey func(i int) string) []partitionGroup {
+	groups := []partitionGroup{}
+	positions := map[string]int{}
+	for _, i := range indexes {
+		key := partitionKey(i)
+		pos, ok := positions[key]
+		if !ok {
+			pos = len(groups)
+			positions[key] = pos
+			groups = append(groups, partitionGroup{partitionKey: key})
+		}
+		groups[pos].indexes = append(groups[pos].indexes, i)
+	}
+
+	return groups
+}
+
+// batchTasks returns the tasks calling fn on the batches of every partition group
+func (c *StateStore) batchTasks(groups []partitionGroup, fn func(partitionKey string, indexes []int) error) []func() error {
+	tasks := []func() error{}
+	for _, group := range groups {
+		for _, b := range c.bulk.Batches(len(group.indexes), maxBatchSize) {
+			partitionKey := group.partitionKey
+			indexes := group.indexes[b.Start:b.End]
+			tasks = append(tasks, func() error {
+				return fn(partitionKey, indexes)
+			})
+		}
+	}
+
+	return tasks
+}
+
+// BulkGet retrieves the items with a query per batch of keys of the same partition key
+func (c *StateStore) BulkGet(req []state.GetRequest) (bool, []state.BulkGetResponse, error) {
+	indexes := make([]int, len(req))
+	for i := range req {
+		indexes[i] = i
+	}
+	groups := partitionGroups(indexes, func(i int) string {
+		return c.partitionKey(req[i].Key, req[i].Metadata)
+	})
+
+	res := make([]state.BulkGetResponse, len(req))
+	err := c.bulk.Run(c.batchTasks(groups, func(partitionKey string, indexes []int) error {
+		// the client binds the string parameters only, the keys are given with a parameter each
+		ids := make([]string, len(indexes))
+		params := make([]documentdb.Parameter, len(indexes))
+		strong := false
+		for j, i := range indexes {
+			ids[j] = "@id" + strconv.Itoa(j)
+			params[j] = documentdb.Parameter{Name: ids[j], Value: req[i].Key}
+			strong = strong || req[i].Options.Consistency == state.Strong
+		}
+		options := []documentdb.CallOption{documentdb.PartitionKey(partitionKey)}
+		if strong {
+			options = append(options, documentdb.ConsistencyLevel(documentdb.Strong))
+		}
+
+		items := []CosmosItem{}
+		_, err := c.client.QueryDocuments(
+			c.collection.Self,
+			documentdb.NewQuery("SELECT * FROM ROOT r WHERE r.id IN ("+strings.Join(ids, ", ")+")", params...),
+			&items,
+			options...,
+		)
+		if err != nil {
+			return err
+		}
+
+		found := make(map[string]*CosmosItem, len(items))
+		for k := range items {
+			found[items[k].ID] = &items[k]
+		}
+		for _, i := range indexes {
+			res[i].Key = req[i].Key
+			item, ok := found[req[i].Key]
+			if !ok {
+				continue
+			}
+			b, err := jsoniter.ConfigFastest.Marshal(&item.Value)
+			if err != nil {
+				res[i].Error = err.Error()
+
+				continue
+			}
+			res[i].Data = b
+			res[i].ETag = item.Etag
+		}
+
+		return nil
+	}))
+	if err != nil {
+		return true, nil, err
+	}
+
+	return true, res, nil
+}
+
+// BulkSet saves the items with a call of the stored procedure per batch of items of the same partition key,
+// each batch is saved transactionally. The stored procedure doesn't check the ETags, so the items with an ETag
+// are saved one by one.
+func (c *StateStore) BulkSet(req []state.SetRequest) error {
+	tasks := []func() error{}
+	batched := []int{}
+	for i := range req {
+		if err := state.CheckRequestOptions(req[i].Options); err != nil {
+			return err
+		}
+		if req[i].ETag != "" {
+			r := &req[i]
+			tasks = append(tasks, func() error {
+				return c.Set(r)
+			})
+
+			continue
+		}
+		batched = append(batched, i)
+	}
+
+	groups := partitionGroups(batched, func(i int) string {
+		return c.partitionKey(req[i].Key, req[i].Metadata)
+	})
+	tasks = append(tasks, c.batchTasks(groups, func(partitionKey string, indexes []int) error {
+		upserts := make([]CosmosItem, len(indexes))
+		for j, i := range indexes {
+			item, err := upsertItem(&req[i], partitionKey)
+			if err != nil {
+				return err
+			}
+			upserts[j] = item
+		}
+
+		return c.executeBatch(partitionKey, upserts, []CosmosItem{})
+	})...)
+
+	return c.bulk.Run(tasks)
+}
+
+// BulkDelete deletes the items with a call of the stored procedure per batch of items of the same partition key,
+// each batch is deleted transactionally. The stored procedure doesn't check the ETags, so the items with an ETag
+// are deleted one by one.
+func (c *StateStore) BulkDelete(req []state.DeleteRequest) error {
+	tasks := []func() error{}
+	batched := []int{}
+	for i := range req {
+		if err := state.CheckRequestOptions(req[i].Options); err != nil {
+			return err
+		}
+		if req[i].ETag != "" {
+			r := &req[i]
+			tasks = append(tasks, func() error {
+				return c.Delete(r)
+			})
+
+			continue
+		}
+		batched = append(batched, i)
+	}
+
+	groups := partitionGroups(batched, func(i int) string {
+		return c.partitionKey(req[i].Key, req[i].Metadata)
+	})
+	tasks = append(tasks, c.batchTasks(groups, func(partitionKey string, indexes []int) error {
+		deletes := make([]CosmosItem, len(indexes))
+		for j, i := range indexes {
+			deletes[j] = CosmosItem{
+				ID:           req[i].Key,
+				Value:        "", // Value does not need to be specified
+				PartitionKey: partitionKey,
+			}
+		}
+
+		return c.executeBatch(partitionKey, []CosmosItem{}, deletes)
+	})...)
+
+	return c.bulk.Run(tasks)
+}
+
+// executeBatch upserts and deletes items of a partition key in a transaction of the stored procedure
+func (c *StateStore) executeBatch(partitionKey string, upserts, deletes []CosmosItem) error {
+	c.logger.Debugf("#upserts=%d,#deletes=%d, partitionkey=%s", len(upserts), len(deletes), partitionKey)
+
+	var retString string
+	err := c.client.ExecuteStoredProcedure(c.sp.Self, [...]interface{}{upserts, deletes}, &retString, documentdb.PartitionKey(partitionKey))
+	if err != nil {
+		c.logger.Debugf("error=%e", err)
+	}
+
+	return err
+}
+
+// upsertItem returns the item of a set request for the stored procedure
+func upsertItem(req *state.SetRequest, partitionKey string) (CosmosItem, error) {
+	ttl, err := state.ParseTTL(req.Metadata)
+	if err != nil {
+		return CosmosItem{}, err
+	}
+
+	value := req.Value
+	if b, ok := value.([]uint8); ok {
+		// data arrived in bytes and already json, it is not to be marshalled again
+		value = json.RawMessage(b)
+	}
+
+	return CosmosItem{
+		ID:           req.Key,
+		Value:        value,
+		PartitionKey: partitionKey,
+		TTL:          ttl,
+	}, nil
+}
@@ -0,0 +1,75 @@
+// ------------------------------------------------------------
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT License.
+// ------------------------------------------------------------
+
+package cosmosdb
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/dapr/components-contrib/state"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPartitionGroups(t *testing.T) {
+	keys := []string{"a", "b", "a", "c", "b"}
+
+	groups := partitionGroups([]int{0, 1, 2, 3, 4}, func(i int) string {
+		return keys[i]
+	})
+
+	assert.Equal(t, []partitionGroup{
+		{partitionKey: "a", indexes: []int{0, 2}},
+		{partitionKey: "b", indexes: []int{1, 4}},
+		{partitionKey: "c", indexes: []int{3}},
+	}, groups)
+}
+
+func TestBatchTasks(t *testing.T) {
+	c := &StateStore{bulk: state.BulkConfig{MaxBatchSize: 2}}
+	groups := []partitionGroup{
+		{partitionKey: "a", indexes: []int{0, 2, 5}},
+		{partitionKey: "b", indexes: []int{1}},
+	}
+
+	batches := map[string][][]int{}
+	for _, task := range c.batchTasks(groups, func(partitionKey string, indexes []int) error {
+		batches[partitionKey] = append(batches[partitionKey], indexes)
+
+		return nil
+	}) {
+		assert.NoError(t, task())
+	}
+
+	assert.Equal(t, map[string][][]int{
+		"a": {{0, 2}, {5}},
+		"b": {{1}},
+	}, batches)
+}
+
+func TestUpsertItem(t *testing.T) {
+	t.Run("bytes are not marshalled again", func(t *testing.T) {
+		item, err := upsertItem(&state.SetRequest{
+			Key:      "key",
+			Value:    []byte(`{"name": "deathstar"}`),
+			Metadata: map[string]string{"ttlInSeconds": "10"},
+		}, "partition")
+
+		assert.NoError(t, err)
+		assert.Equal(t, json.RawMessage(`{"name": "deathstar"}`), item.Value)
+		assert.Equal(t, "partition", item.PartitionKey)
+		assert.Equal(t, 10, *item.TTL)
+	})
+
+	t.Run("invalid ttl", func(t *testing.T) {
+		_, err := upsertItem(&state.SetRequest{
+			Key:      "key",
+			Value:    "deathstar",
+			Metadata: map[string]string{"ttlInSeconds": "soon"},
+		}, "partition")
+
+		assert.Error(t, err)
+	})
+}
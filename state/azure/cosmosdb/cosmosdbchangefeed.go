@@ -0,0 +1,231 @@
+// ------------------------------------------------------------
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT License.
+// ------------------------------------------------------------
+
+package cosmosdb
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/dapr/components-contrib/state"
+)
+
+const (
+	changeFeedPollInterval = time.Second
+	changeFeedPageSize     = "100"
+	// changeFeedFromNow is the continuation of the change feed reading the changes made from now on
+	changeFeedFromNow = "*"
+)
+
+// errPartitionGone is returned when reading the change feed of a partition key range which was split
+var errPartitionGone = errors.New("the partition key range is gone")
+
+// partitionKeyRange is a partition key range of a collection, which has a change feed of its own
+type partitionKeyRange struct {
+	ID      string   `json:"id"`
+	Parents []string `json:"parents"`
+}
+
+// changeFeedDocument is a document of the change feed
+type changeFeedDocument struct {
+	ID    string          `json:"id"`
+	Value json.RawMessage `json:"value"`
+	Etag  string          `json:"_etag"`
+}
+
+// Watch notifies the upserts of the keys with the change feed of the collection, which is polled every second.
+// The change feed does not have the deletes, which are not notified.
+func (c *StateStore) Watch(req *state.WatchRequest, handler func(change *state.StateChange)) (func(), error) {
+	ranges, err := c.batch.partitionKeyRanges()
+	if err != nil {
+		return nil, fmt.Errorf("error in reading the partition key ranges: %s", err)
+	}
+	continuations := make(map[string]string, len(ranges))
+	for _, r := range ranges {
+		continuations[r.ID] = changeFeedFromNow
+	}
+	// the first poll turns the continuations into the current positions of the change feeds
+	if err = c.pollChangeFeed(continuations, req, handler); err != nil {
+		return nil, err
+	}
+
+	done := make(chan struct{})
+	go func() {
+		ticker := time.NewTicker(changeFeedPollInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+			}
+			if err := c.pollChangeFeed(continuations, req, handler); err != nil {
+				c.logger.Warnf("error in reading the change feed: %s", err)
+			}
+		}
+	}()
+
+	var once sync.Once
+
+	return func() {
+		once.Do(func() {
+			close(done)
+		})
+	}, nil
+}
+
+// pollChangeFeed notifies the changes of the change feeds of the partition key ranges since their continuations,
+// which are moved past the changes
+func (c *StateStore) pollChangeFeed(continuations map[string]string, req *state.WatchRequest, handler func(change *state.StateChange)) error {
+	ids := make([]string, 0, len(continuations))
+	for id := range continuations {
+		ids = append(ids, id)
+	}
+
+	for _, id := range ids {
+		for {
+			documents, next, err := c.batch.readChangeFeed(id, continuations[id])
+			if err == errPartitionGone {
+				ranges, err := c.batch.partitionKeyRanges()
+				if err != nil {
+					return err
+				}
+				splitRanges(continuations, ranges)
+
+				break
+			}
+			if err != nil {
+				return err
+			}
+			continuations[id] = next
+
+			for _, d := range documents {
+				if req.Watches(d.ID) {
+					handler(&state.StateChange{Key: d.ID, Operation: state.Upsert, Data: d.Value, ETag: d.Etag})
+				}
+			}
+			if len(documents) == 0 {
+				break
+			}
+		}
+	}
+
+	return nil
+}
+
+// splitRanges replaces the continuations of the split partition key ranges by the continuations of their children,
+// which continue from the continuation of their parent
+func splitRanges(continuations map[string]string, ranges []partitionKeyRange) {
+	current := make(map[string]bool, len(ranges))
+	for _, r := range ranges {
+		current[r.ID] = true
+		if _, ok := continuations[r.ID]; ok {
+			continue
+		}
+		continuation := changeFeedFromNow
+		for _, parent := range r.Parents {
+			if c, ok := continuations[parent]; ok {
+				continuation = c
+			}
+		}
+		continuations[r.ID] = continuation
+	}
+	for id := range continuations {
+		if !current[id] {
+			delete(continuations, id)
+		}
+	}
+}
+
+// partitionKeyRanges returns the partition key ranges of the collection
+func (b *batchClient) partitionKeyRanges() ([]partitionKeyRange, error) {
+	statusCode, _, body, err := b.get("pkranges", strings.TrimSuffix(b.url, "/docs")+"/pkranges", nil)
+	if err != nil {
+		return nil, err
+	}
+	if statusCode != http.StatusOK {
+		return nil, fmt.Errorf("reading the partition key ranges failed with status %d: %s", statusCode, string(body))
+	}
+
+	var res struct {
+		PartitionKeyRanges []partitionKeyRange `json:"PartitionKeyRanges"`
+	}
+	if err = json.Unmarshal(body, &res); err != nil {
+		return nil, err
+	}
+
+	return res.PartitionKeyRanges, nil
+}
+
+// readChangeFeed returns a page of the changed documents of the change feed of a partition key range from a
+// continuation, along with the continuation of the next page. There are no documents once the changes are read.
+func (b *batchClient) readChangeFeed(rangeID, continuation string) ([]changeFeedDocument, string, error) {
+	statusCode, header, body, err := b.get("docs", b.url, map[string]string{
+		"A-IM":                                "Incremental feed",
+		"If-None-Match":                       continuation,
+		"x-ms-documentdb-partitionkeyrangeid": rangeID,
+		"x-ms-max-item-count":                 changeFeedPageSize,
+	})
+	if err != nil {
+		return nil, "", err
+	}
+
+	next := header.Get("etag")
+	if next == "" {
+		next = continuation
+	}
+
+	switch statusCode {
+	case http.StatusNotModified:
+		return nil, next, nil
+	case http.StatusGone:
+		return nil, "", errPartitionGone
+	case http.StatusOK:
+		var res struct {
+			Documents []changeFeedDocument `json:"Documents"`
+		}
+		if err = json.Unmarshal(body, &res); err != nil {
+			return nil, "", err
+		}
+
+		return res.Documents, next, nil
+	}
+
+	return nil, "", fmt.Errorf("reading the change feed failed with status %d: %s", statusCode, string(body))
+}
+
+// get sends a GET request on a resource of the collection, signed with the master key
+func (b *batchClient) get(resourceType, url string, headers map[string]string) (int, http.Header, []byte, error) {
+	req, err := http.NewRequest(http.MethodGet, url, nil)
+	if err != nil {
+		return 0, nil, nil, err
+	}
+	date := time.Now().UTC().Format(http.TimeFormat)
+	req.Header.Set("Authorization", masterKeyAuthorization(b.masterKey, http.MethodGet, resourceType, b.link, date))
+	req.Header.Set("x-ms-date", date)
+	req.Header.Set("x-ms-version", batchAPIVersion)
+	for k, v := range headers {
+		req.Header.Set(k, v)
+	}
+
+	resp, err := b.httpClient.Do(req)
+	if err != nil {
+		return 0, nil, nil, err
+	}
+	defer resp.Body.Close()
+
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return 0, nil, nil, err
+	}
+
+	return resp.StatusCode, resp.Header, body, nil
+}
@@ -0,0 +1,98 @@
+// ------------------------------------------------------------
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT License.
+// ------------------------------------------------------------
+
+package cosmosdb
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/dapr/components-contrib/state"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSplitRanges(t *testing.T) {
+	continuations := map[string]string{"0": "10", "1": "20"}
+
+	splitRanges(continuations, []partitionKeyRange{{ID: "0"}, {ID: "2", Parents: []string{"1"}}, {ID: "3", Parents: []string{"1"}}})
+
+	assert.Equal(t, map[string]string{"0": "10", "2": "20", "3": "20"}, continuations)
+}
+
+func TestPollChangeFeed(t *testing.T) {
+	var split bool
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasSuffix(r.URL.Path, "/pkranges") {
+			if split {
+				w.Write([]byte(`{"PartitionKeyRanges": [{"id": "1", "parents": ["0"]}, {"id": "2", "parents": ["0"]}]}`))
+
+				return
+			}
+			w.Write([]byte(`{"PartitionKeyRanges": [{"id": "0"}]}`))
+
+			return
+		}
+
+		rangeID := r.Header.Get("x-ms-documentdb-partitionkeyrangeid")
+		switch {
+		case rangeID == "0" && split:
+			w.WriteHeader(http.StatusGone)
+		case r.Header.Get("If-None-Match") == "*":
+			w.Header().Set("etag", "1")
+			w.WriteHeader(http.StatusNotModified)
+		case r.Header.Get("If-None-Match") == "1" && rangeID != "2":
+			w.Header().Set("etag", "2")
+			w.Write([]byte(`{"Documents": [
+				{"id": "order-1", "value": {"status": "pending"}, "_etag": "e1"},
+				{"id": "cart-1", "value": {}, "_etag": "e2"}
+			]}`))
+		default:
+			w.WriteHeader(http.StatusNotModified)
+		}
+	}))
+	defer server.Close()
+
+	c := &StateStore{batch: &batchClient{httpClient: server.Client(), url: server.URL + "/dbs/db/colls/coll/docs", link: "dbs/db/colls/coll", masterKey: []byte("key")}}
+	req := &state.WatchRequest{Prefixes: []string{"order-"}}
+	var changes []*state.StateChange
+	handler := func(change *state.StateChange) {
+		changes = append(changes, change)
+	}
+
+	ranges, err := c.batch.partitionKeyRanges()
+	require.NoError(t, err)
+	continuations := map[string]string{ranges[0].ID: changeFeedFromNow}
+
+	t.Run("current position", func(t *testing.T) {
+		require.NoError(t, c.pollChangeFeed(continuations, req, handler))
+
+		assert.Empty(t, changes)
+		assert.Equal(t, map[string]string{"0": "1"}, continuations)
+	})
+
+	t.Run("changes", func(t *testing.T) {
+		require.NoError(t, c.pollChangeFeed(continuations, req, handler))
+
+		require.Len(t, changes, 1)
+		assert.Equal(t, &state.StateChange{Key: "order-1", Operation: state.Upsert, Data: []byte(`{"status": "pending"}`), ETag: "e1"}, changes[0])
+		assert.Equal(t, map[string]string{"0": "2"}, continuations)
+	})
+
+	t.Run("split", func(t *testing.T) {
+		split = true
+		continuations["0"] = "1"
+		changes = nil
+
+		require.NoError(t, c.pollChangeFeed(continuations, req, handler))
+		assert.Equal(t, map[string]string{"1": "1", "2": "1"}, continuations)
+
+		require.NoError(t, c.pollChangeFeed(continuations, req, handler))
+		require.Len(t, changes, 1, "the changes are read from the continuation of the parent")
+		assert.Equal(t, "2", continuations["1"])
+	})
+}
@@ -0,0 +1,90 @@
+// ------------------------------------------------------------
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT License.
+// ------------------------------------------------------------
+
+package cosmosdb
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/a8m/documentdb"
+	"github.com/dapr/components-contrib/state/query"
+)
+
+// cosmosQuery translates a query to the SQL of CosmosDB, where the keys of the query are the properties
+// of the item values. The pages are read with the continuation tokens of CosmosDB.
+type cosmosQuery struct {
+	query documentdb.Query
+	limit int
+	token string
+}
+
+func (q *cosmosQuery) VisitEQ(f *query.EQ) (string, error) {
+	return fmt.Sprintf("%s = %s", valueProperty(f.Key), q.param(f.Val)), nil
+}
+
+func (q *cosmosQuery) VisitIN(f *query.IN) (string, error) {
+	params := make([]string, len(f.Vals))
+	for i, val := range f.Vals {
+		params[i] = q.param(val)
+	}
+
+	return fmt.Sprintf("%s IN (%s)", valueProperty(f.Key), strings.Join(params, ", ")), nil
+}
+
+func (q *cosmosQuery) VisitAND(filters []string) (string, error) {
+	return "(" + strings.Join(filters, " AND ") + ")", nil
+}
+
+func (q *cosmosQuery) VisitOR(filters []string) (string, error) {
+	return "(" + strings.Join(filters, " OR ") + ")", nil
+}
+
+func (q *cosmosQuery) Finalize(filter string, qq *query.Query) error {
+	q.query.Query = "SELECT * FROM c"
+	if filter != "" {
+		q.query.Query += " WHERE " + filter
+	}
+	if len(qq.Sort) > 0 {
+		order := make([]string, len(qq.Sort))
+		for i, s := range qq.Sort {
+			order[i] = valueProperty(s.Key) + " " + s.Order
+		}
+		q.query.Query += " ORDER BY " + strings.Join(order, ", ")
+	}
+	q.limit = qq.Page.Limit
+	q.token = qq.Page.Token
+
+	return nil
+}
+
+// param returns the expression of a value in the query: the name of a parameter added to the query for a string,
+// as the client binds the string parameters only, else the JSON literal of the value, which is valid in CosmosDB.
+func (q *cosmosQuery) param(val interface{}) string {
+	s, ok := val.(string)
+	if !ok {
+		b, _ := json.Marshal(val)
+
+		return string(b)
+	}
+	name := "@__param__" + strconv.Itoa(len(q.query.Parameters)) + "__"
+	q.query.Parameters = append(q.query.Parameters, documentdb.Parameter{Name: name, Value: s})
+
+	return name
+}
+
+// valueProperty returns the property of a key in the item values, such as c["value"]["person"]["org"]
+func valueProperty(key string) string {
+	property := `c["value"]`
+	for _, part := range strings.Split(key, ".") {
+		// the JSON strings are valid string literals of CosmosDB
+		b, _ := json.Marshal(part)
+		property += "[" + string(b) + "]"
+	}
+
+	return property
+}
@@ -0,0 +1,45 @@
+// ------------------------------------------------------------
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT License.
+// ------------------------------------------------------------
+
+package cosmosdb
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/a8m/documentdb"
+	"github.com/dapr/components-contrib/state/query"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCosmosQuery(t *testing.T) {
+	t.Run("filter, sort and page", func(t *testing.T) {
+		var qq query.Query
+		require.NoError(t, json.Unmarshal([]byte(`{
+			"filter": {"OR": [{"EQ": {"person.org": "Dev Ops"}}, {"AND": [{"EQ": {"age": 30}}, {"IN": {"state": ["CA", "WA"]}}]}]},
+			"sort": [{"key": "state", "order": "DESC"}],
+			"page": {"limit": 10, "token": "continuation"}
+		}`), &qq))
+
+		q := &cosmosQuery{}
+		require.NoError(t, query.NewQueryBuilder(q).BuildQuery(&qq))
+
+		assert.Equal(t, `SELECT * FROM c WHERE (c["value"]["person"]["org"] = @__param__0__ OR `+
+			`(c["value"]["age"] = 30 AND c["value"]["state"] IN (@__param__1__, @__param__2__))) `+
+			`ORDER BY c["value"]["state"] DESC`, q.query.Query)
+		assert.Equal(t, []documentdb.Parameter{
+			{Name: "@__param__0__", Value: "Dev Ops"},
+			{Name: "@__param__1__", Value: "CA"},
+			{Name: "@__param__2__", Value: "WA"},
+		}, q.query.Parameters)
+		assert.Equal(t, 10, q.limit)
+		assert.Equal(t, "continuation", q.token)
+	})
+
+	t.Run("keys are escaped", func(t *testing.T) {
+		assert.Equal(t, `c["value"]["a\"b"]["c"]`, valueProperty(`a"b.c`))
+	})
+}
@@ -121,6 +121,9 @@ func (r *StateStore) Get(req *state.GetRequest) (*state.GetResponse, error) {
 
 func (r *StateStore) Set(req *state.SetRequest) error {
 	r.logger.Debugf("saving %s", req.Key)
+	if err := state.RejectTTL(req.Metadata); err != nil {
+		return err
+	}
 
 	return r.writeRow(req)
 }
@@ -0,0 +1,142 @@
+// ------------------------------------------------------------
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT License.
+// ------------------------------------------------------------
+
+package state
+
+import (
+	"fmt"
+	"strconv"
+	"sync"
+
+	"github.com/hashicorp/go-multierror"
+)
+
+const (
+	// MaxBulkBatchSizeMetadataKey is the component metadata key setting the maximum number of items
+	// sent to the store in one batch of a bulk operation
+	MaxBulkBatchSizeMetadataKey = "maxBulkBatchSize"
+	// BulkParallelismMetadataKey is the component metadata key setting how many batches of a bulk operation
+	// are sent to the store concurrently
+	BulkParallelismMetadataKey = "bulkParallelism"
+
+	// DefaultBulkParallelism is the default number of batches of a bulk operation sent concurrently
+	DefaultBulkParallelism = 1
+)
+
+// BulkConfig is the configuration of the batches of the bulk operations of a store
+type BulkConfig struct {
+	// MaxBatchSize is the maximum number of items of a batch, 0 leaves it to the limit of the store
+	MaxBatchSize int
+	// Parallelism is the number of batches sent concurrently. The batches of a bulk operation
+	// are not ordered when it is greater than 1.
+	Parallelism int
+}
+
+// ParseBulkConfig returns the bulk configuration set in the component metadata
+func ParseBulkConfig(metadata map[string]string) (BulkConfig, error) {
+	c := BulkConfig{
+		Parallelism: DefaultBulkParallelism,
+	}
+
+	if val, ok := metadata[MaxBulkBatchSizeMetadataKey]; ok && val != "" {
+		size, err := strconv.Atoi(val)
+		if err != nil || size <= 0 {
+			return c, fmt.Errorf("%s value must be a positive integer: actual is '%s'", MaxBulkBatchSizeMetadataKey, val)
+		}
+		c.MaxBatchSize = size
+	}
+
+	if val, ok := metadata[BulkParallelismMetadataKey]; ok && val != "" {
+		parallelism, err := strconv.Atoi(val)
+		if err != nil || parallelism <= 0 {
+			return c, fmt.Errorf("%s value must be a positive integer: actual is '%s'", BulkParallelismMetadataKey, val)
+		}
+		c.Parallelism = parallelism
+	}
+
+	return c, nil
+}
+
+// Batch is the range [Start, End) of the items of a bulk operation sent in one batch
+type Batch struct {
+	Start int
+	End   int
+}
+
+// Batches splits count items in batches of the maximum batch size, capped at the limit of the store.
+// A limit of 0 doesn't cap the batch size.
+func (c BulkConfig) Batches(count, limit int) []Batch {
+	size := c.MaxBatchSize
+	if size <= 0 || (limit > 0 && size > limit) {
+		size = limit
+	}
+	if size <= 0 {
+		size = count
+	}
+
+	batches := []Batch{}
+	for start := 0; start < count; start += size {
+		end := start + size
+		if end > count {
+			end = count
+		}
+		batches = append(batches, Batch{Start: start, End: end})
+	}
+
+	return batches
+}
+
+// RunBatches calls fn on the batches of count items, as many at a time as the parallelism,
+// and returns the errors of all the batches.
+func (c BulkConfig) RunBatches(count, limit int, fn func(b Batch) error) error {
+	batches := c.Batches(count, limit)
+	tasks := make([]func() error, len(batches))
+	for i := range batches {
+		b := batches[i]
+		tasks[i] = func() error {
+			return fn(b)
+		}
+	}
+
+	return c.Run(tasks)
+}
+
+// Run calls the tasks, as many at a time as the parallelism, and returns the errors of all the tasks
+func (c BulkConfig) Run(tasks []func() error) error {
+	if len(tasks) == 1 {
+		return tasks[0]()
+	}
+
+	parallelism := c.Parallelism
+	if parallelism <= 0 {
+		parallelism = 1
+	}
+
+	var (
+		wg   sync.WaitGroup
+		lock sync.Mutex
+		errs error
+	)
+	sem := make(chan struct{}, parallelism)
+	for _, task := range tasks {
+		sem <- struct{}{}
+		wg.Add(1)
+		go func(task func() error) {
+			defer func() {
+				<-sem
+				wg.Done()
+			}()
+
+			if err := task(); err != nil {
+				lock.Lock()
+				errs = multierror.Append(errs, err)
+				lock.Unlock()
+			}
+		}(task)
+	}
+	wg.Wait()
+
+	return errs
+}
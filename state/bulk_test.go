@@ -0,0 +1,141 @@
+// ------------------------------------------------------------
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT License.
+// ------------------------------------------------------------
+
+package state
+
+import (
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	"github.com/hashicorp/go-multierror"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseBulkConfig(t *testing.T) {
+	t.Run("defaults", func(t *testing.T) {
+		c, err := ParseBulkConfig(map[string]string{})
+
+		assert.NoError(t, err)
+		assert.Equal(t, BulkConfig{MaxBatchSize: 0, Parallelism: DefaultBulkParallelism}, c)
+	})
+
+	t.Run("configured", func(t *testing.T) {
+		c, err := ParseBulkConfig(map[string]string{
+			MaxBulkBatchSizeMetadataKey: "50",
+			BulkParallelismMetadataKey:  "8",
+		})
+
+		assert.NoError(t, err)
+		assert.Equal(t, BulkConfig{MaxBatchSize: 50, Parallelism: 8}, c)
+	})
+
+	for _, key := range []string{MaxBulkBatchSizeMetadataKey, BulkParallelismMetadataKey} {
+		for _, val := range []string{"0", "-1", "many"} {
+			key, val := key, val
+			t.Run("invalid "+key+" "+val, func(t *testing.T) {
+				_, err := ParseBulkConfig(map[string]string{key: val})
+
+				assert.Error(t, err)
+			})
+		}
+	}
+}
+
+func TestBatches(t *testing.T) {
+	t.Run("batch size is capped at the limit", func(t *testing.T) {
+		c := BulkConfig{MaxBatchSize: 100}
+
+		assert.Equal(t, []Batch{{0, 25}, {25, 50}, {50, 60}}, c.Batches(60, 25))
+	})
+
+	t.Run("batch size under the limit", func(t *testing.T) {
+		c := BulkConfig{MaxBatchSize: 10}
+
+		assert.Equal(t, []Batch{{0, 10}, {10, 20}, {20, 25}}, c.Batches(25, 25))
+	})
+
+	t.Run("no batch size nor limit", func(t *testing.T) {
+		c := BulkConfig{}
+
+		assert.Equal(t, []Batch{{0, 60}}, c.Batches(60, 0))
+	})
+
+	t.Run("no items", func(t *testing.T) {
+		c := BulkConfig{MaxBatchSize: 10}
+
+		assert.Empty(t, c.Batches(0, 0))
+	})
+}
+
+func TestRunBatches(t *testing.T) {
+	t.Run("all the items are run", func(t *testing.T) {
+		c := BulkConfig{MaxBatchSize: 3, Parallelism: 2}
+		var lock sync.Mutex
+		seen := make([]int, 10)
+
+		err := c.RunBatches(10, 0, func(b Batch) error {
+			lock.Lock()
+			defer lock.Unlock()
+			for i := b.Start; i < b.End; i++ {
+				seen[i]++
+			}
+
+			return nil
+		})
+
+		assert.NoError(t, err)
+		assert.Equal(t, []int{1, 1, 1, 1, 1, 1, 1, 1, 1, 1}, seen)
+	})
+
+	t.Run("parallelism is bounded", func(t *testing.T) {
+		c := BulkConfig{MaxBatchSize: 1, Parallelism: 3}
+		var running, max int32
+
+		err := c.RunBatches(20, 0, func(b Batch) error {
+			n := atomic.AddInt32(&running, 1)
+			for {
+				m := atomic.LoadInt32(&max)
+				if n <= m || atomic.CompareAndSwapInt32(&max, m, n) {
+					break
+				}
+			}
+			atomic.AddInt32(&running, -1)
+
+			return nil
+		})
+
+		assert.NoError(t, err)
+		assert.True(t, max <= 3)
+	})
+
+	t.Run("errors of all the batches are returned", func(t *testing.T) {
+		c := BulkConfig{MaxBatchSize: 2, Parallelism: 2}
+
+		err := c.RunBatches(6, 0, func(b Batch) error {
+			if b.Start == 2 {
+				return nil
+			}
+
+			return errors.New("failed")
+		})
+
+		require.Error(t, err)
+		assert.Len(t, err.(*multierror.Error).Errors, 2)
+	})
+
+	t.Run("single batch error is returned as is", func(t *testing.T) {
+		c := BulkConfig{}
+		failed := errors.New("failed")
+
+		err := c.RunBatches(6, 0, func(b Batch) error {
+			return failed
+		})
+
+		assert.Equal(t, failed, err)
+	})
+}
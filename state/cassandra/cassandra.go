@@ -24,12 +24,16 @@ const (
 	password                 = "password"
 	protoVersion             = "protoVersion"
 	consistency              = "consistency"
+	readConsistency          = "readConsistency"
+	writeConsistency         = "writeConsistency"
+	serialConsistency        = "serialConsistency"
 	table                    = "table"
 	keyspace                 = "keyspace"
 	replicationFactor        = "replicationFactor"
 	defaultProtoVersion      = 4
 	defaultReplicationFactor = 1
 	defaultConsistency       = gocql.All
+	defaultSerialConsistency = gocql.Serial
 	defaultTable             = "items"
 	defaultKeyspace          = "dapr"
 	defaultPort              = 9042
@@ -37,10 +41,13 @@ const (
 
 // Cassandra is a state store implementation for Apache Cassandra
 type Cassandra struct {
-	state.DefaultBulkStore
-	session *gocql.Session
-	cluster *gocql.ClusterConfig
-	table   string
+	session           *gocql.Session
+	cluster           *gocql.ClusterConfig
+	table             string
+	bulk              state.BulkConfig
+	readConsistency   gocql.Consistency
+	writeConsistency  gocql.Consistency
+	serialConsistency gocql.SerialConsistency
 
 	logger logger.Logger
 }
@@ -53,14 +60,17 @@ type cassandraMetadata struct {
 	username          string
 	password          string
 	consistency       string
+	readConsistency   string
+	writeConsistency  string
+	serialConsistency string
 	table             string
 	keyspace          string
+	bulk              state.BulkConfig
 }
 
 // NewCassandraStateStore returns a new cassandra state store
 func NewCassandraStateStore(logger logger.Logger) *Cassandra {
 	s := &Cassandra{logger: logger}
-	s.DefaultBulkStore = state.NewDefaultBulkStore(s)
 
 	return s
 }
@@ -72,6 +82,16 @@ func (c *Cassandra) Init(metadata state.Metadata) error {
 		return err
 	}
 
+	if c.readConsistency, err = getConsistency(meta.readConsistency); err != nil {
+		return err
+	}
+	if c.writeConsistency, err = getConsistency(meta.writeConsistency); err != nil {
+		return err
+	}
+	if c.serialConsistency, err = getSerialConsistency(meta.serialConsistency); err != nil {
+		return err
+	}
+
 	cluster, err := c.createClusterConfig(meta)
 	if err != nil {
 		return fmt.Errorf("error creating cluster config: %s", err)
@@ -94,7 +114,13 @@ func (c *Cassandra) Init(metadata state.Metadata) error {
 		return fmt.Errorf("error creating keyspace %s: %s", meta.table, err)
 	}
 
+	err = c.tryAddETagColumn(meta.table, meta.keyspace)
+	if err != nil {
+		return fmt.Errorf("error adding the etag column to table %s: %s", meta.table, err)
+	}
+
 	c.table = fmt.Sprintf("%s.%s", meta.keyspace, meta.table)
+	c.bulk = meta.bulk
 
 	return nil
 }
@@ -104,7 +130,20 @@ func (c *Cassandra) tryCreateKeyspace(keyspace string, replicationFactor int) er
 }
 
 func (c *Cassandra) tryCreateTable(table, keyspace string) error {
-	return c.session.Query(fmt.Sprintf("CREATE TABLE IF NOT EXISTS %s.%s (key text, value blob, PRIMARY KEY (key));", keyspace, table)).Exec()
+	return c.session.Query(fmt.Sprintf("CREATE TABLE IF NOT EXISTS %s.%s (key text, value blob, etag text, PRIMARY KEY (key));", keyspace, table)).Exec()
+}
+
+// tryAddETagColumn adds the etag column to the tables created without it
+func (c *Cassandra) tryAddETagColumn(table, keyspace string) error {
+	results, err := c.session.Query("SELECT column_name FROM system_schema.columns WHERE keyspace_name = ? AND table_name = ? AND column_name = 'etag'", keyspace, table).Iter().SliceMap()
+	if err != nil {
+		return err
+	}
+	if len(results) > 0 {
+		return nil
+	}
+
+	return c.session.Query(fmt.Sprintf("ALTER TABLE %s.%s ADD etag text;", keyspace, table)).Exec()
 }
 
 func (c *Cassandra) createClusterConfig(metadata *cassandraMetadata) (*gocql.ClusterConfig, error) {
@@ -114,17 +153,18 @@ func (c *Cassandra) createClusterConfig(metadata *cassandraMetadata) (*gocql.Clu
 	}
 	clusterConfig.Port = metadata.port
 	clusterConfig.ProtoVersion = metadata.protoVersion
-	cons, err := c.getConsistency(metadata.consistency)
+	cons, err := getConsistency(metadata.consistency)
 	if err != nil {
 		return nil, err
 	}
 
 	clusterConfig.Consistency = cons
+	clusterConfig.SerialConsistency = c.serialConsistency
 
 	return clusterConfig, nil
 }
 
-func (c *Cassandra) getConsistency(consistency string) (gocql.Consistency, error) {
+func getConsistency(consistency string) (gocql.Consistency, error) {
 	switch consistency {
 	case "All":
 		return gocql.All, nil
@@ -151,6 +191,49 @@ func (c *Cassandra) getConsistency(consistency string) (gocql.Consistency, error
 	return 0, fmt.Errorf("consistency mode %s not found", consistency)
 }
 
+// getSerialConsistency returns the consistency level of the lightweight transactions
+func getSerialConsistency(consistency string) (gocql.SerialConsistency, error) {
+	switch consistency {
+	case "Serial":
+		return gocql.Serial, nil
+	case "LocalSerial":
+		return gocql.LocalSerial, nil
+	case "":
+		return defaultSerialConsistency, nil
+	}
+
+	return 0, fmt.Errorf("serial consistency mode %s not found", consistency)
+}
+
+// requestConsistency returns the consistency level of an operation: the level of the consistency request metadata,
+// or the strong or eventual level of the consistency option, or the level of the component
+func requestConsistency(metadata map[string]string, option string, strong, eventual, level gocql.Consistency) (gocql.Consistency, error) {
+	if val, ok := metadata[consistency]; ok && val != "" {
+		return getConsistency(val)
+	}
+
+	switch option {
+	case state.Strong:
+		return strong, nil
+	case state.Eventual:
+		return eventual, nil
+	}
+
+	return level, nil
+}
+
+// getReadConsistency returns the consistency level of a read, All for the strong consistency and One for the
+// eventual consistency
+func (c *Cassandra) getReadConsistency(option string, metadata map[string]string) (gocql.Consistency, error) {
+	return requestConsistency(metadata, option, gocql.All, gocql.One, c.readConsistency)
+}
+
+// getWriteConsistency returns the consistency level of a write, Quorum for the strong consistency and Any for the
+// eventual consistency
+func (c *Cassandra) getWriteConsistency(option string, metadata map[string]string) (gocql.Consistency, error) {
+	return requestConsistency(metadata, option, gocql.Quorum, gocql.Any, c.writeConsistency)
+}
+
 func getCassandraMetadata(metadata state.Metadata) (*cassandraMetadata, error) {
 	meta := cassandraMetadata{
 		protoVersion:      defaultProtoVersion,
@@ -178,6 +261,20 @@ func getCassandraMetadata(metadata state.Metadata) (*cassandraMetadata, error) {
 	if val, ok := metadata.Properties[consistency]; ok && val != "" {
 		meta.consistency = val
 	}
+	meta.readConsistency = meta.consistency
+	meta.writeConsistency = meta.consistency
+
+	if val, ok := metadata.Properties[readConsistency]; ok && val != "" {
+		meta.readConsistency = val
+	}
+
+	if val, ok := metadata.Properties[writeConsistency]; ok && val != "" {
+		meta.writeConsistency = val
+	}
+
+	if val, ok := metadata.Properties[serialConsistency]; ok && val != "" {
+		meta.serialConsistency = val
+	}
 
 	if val, ok := metadata.Properties[table]; ok && val != "" {
 		meta.table = val
@@ -211,35 +308,38 @@ func getCassandraMetadata(metadata state.Metadata) (*cassandraMetadata, error) {
 		meta.replicationFactor = int(r)
 	}
 
+	bulk, err := state.ParseBulkConfig(metadata.Properties)
+	if err != nil {
+		return nil, err
+	}
+	meta.bulk = bulk
+
 	return &meta, nil
 }
 
-// Delete performs a delete operation
+// Delete performs a delete operation, which is a lightweight transaction conditioned on the etag of the request with
+// the first-write concurrency
 func (c *Cassandra) Delete(req *state.DeleteRequest) error {
-	return c.session.Query("DELETE FROM ? WHERE key = ?", c.table, req.Key).Exec()
+	cons, err := c.getWriteConsistency(req.Options.Consistency, req.Metadata)
+	if err != nil {
+		return err
+	}
+
+	if req.Options.Concurrency == state.FirstWrite && req.ETag != "" {
+		return c.execCAS(c.session.Query("DELETE FROM ? WHERE key = ? IF etag = ?", c.table, req.Key, req.ETag).Consistency(cons), req.Key)
+	}
+
+	return c.session.Query("DELETE FROM ? WHERE key = ?", c.table, req.Key).Consistency(cons).Exec()
 }
 
 // Get retrieves state from cassandra with a key
 func (c *Cassandra) Get(req *state.GetRequest) (*state.GetResponse, error) {
-	session := c.session
-
-	if req.Options.Consistency == state.Strong {
-		sess, err := c.createSession(gocql.All)
-		if err != nil {
-			return nil, err
-		}
-		defer sess.Close()
-		session = sess
-	} else if req.Options.Consistency == state.Eventual {
-		sess, err := c.createSession(gocql.One)
-		if err != nil {
-			return nil, err
-		}
-		defer sess.Close()
-		session = sess
+	cons, err := c.getReadConsistency(req.Options.Consistency, req.Metadata)
+	if err != nil {
+		return nil, err
 	}
 
-	results, err := session.Query("SELECT value FROM ? WHERE key = ?", c.table, req.Key).Iter().SliceMap()
+	results, err := c.session.Query("SELECT value, etag FROM ? WHERE key = ?", c.table, req.Key).Consistency(cons).Iter().SliceMap()
 	if err != nil {
 		return nil, err
 	}
@@ -248,13 +348,42 @@ func (c *Cassandra) Get(req *state.GetRequest) (*state.GetResponse, error) {
 		return &state.GetResponse{}, nil
 	}
 
+	etag, _ := results[0]["etag"].(string)
+
 	return &state.GetResponse{
 		Data: results[0]["value"].([]byte),
+		ETag: etag,
 	}, nil
 }
 
-// Set saves state into cassandra
+// Set saves state into cassandra, with a lightweight transaction for the first-write concurrency: the value is
+// inserted if it does not exist without etag, and updated if its etag matches otherwise
 func (c *Cassandra) Set(req *state.SetRequest) error {
+	stmt, args, err := c.setStatement(req)
+	if err != nil {
+		return err
+	}
+
+	cons, err := c.getWriteConsistency(req.Options.Consistency, req.Metadata)
+	if err != nil {
+		return err
+	}
+
+	q := c.session.Query(stmt, args...).Consistency(cons)
+	if req.Options.Concurrency == state.FirstWrite {
+		return c.execCAS(q, req.Key)
+	}
+
+	return q.Exec()
+}
+
+// setStatement returns the statement saving a value with a new etag, and its arguments
+func (c *Cassandra) setStatement(req *state.SetRequest) (string, []interface{}, error) {
+	ttl, err := state.ParseTTL(req.Metadata)
+	if err != nil {
+		return "", nil, fmt.Errorf("error parsing TTL from metadata: %s", err)
+	}
+
 	var bt []byte
 	b, ok := req.Value.([]byte)
 	if ok {
@@ -262,35 +391,54 @@ func (c *Cassandra) Set(req *state.SetRequest) error {
 	} else {
 		bt, _ = jsoniter.ConfigFastest.Marshal(req.Value)
 	}
-
-	session := c.session
-
-	if req.Options.Consistency == state.Strong {
-		sess, err := c.createSession(gocql.Quorum)
-		if err != nil {
-			return err
+	etag := gocql.TimeUUID().String()
+
+	using := ""
+	var ttlInSeconds int
+	if ttl != nil {
+		// a time to live of 0 never expires, even on a table with a default time to live
+		ttlInSeconds = *ttl
+		if ttlInSeconds == state.NoExpiry {
+			ttlInSeconds = 0
 		}
-		defer sess.Close()
-		session = sess
-	} else if req.Options.Consistency == state.Eventual {
-		sess, err := c.createSession(gocql.Any)
-		if err != nil {
-			return err
+		using = " USING TTL ?"
+	}
+
+	if req.Options.Concurrency == state.FirstWrite && req.ETag != "" {
+		args := []interface{}{c.table}
+		if ttl != nil {
+			args = append(args, ttlInSeconds)
 		}
-		defer sess.Close()
-		session = sess
+
+		return "UPDATE ?" + using + " SET value = ?, etag = ? WHERE key = ? IF etag = ?", append(args, bt, etag, req.Key, req.ETag), nil
+	}
+
+	stmt := "INSERT INTO ? (key, value, etag) VALUES (?, ?, ?)"
+	if req.Options.Concurrency == state.FirstWrite {
+		stmt += " IF NOT EXISTS"
+	}
+	args := []interface{}{c.table, req.Key, bt, etag}
+	if ttl != nil {
+		args = append(args, ttlInSeconds)
 	}
 
-	return session.Query("INSERT INTO ? (key, value) VALUES (?, ?)", c.table, req.Key, bt).Exec()
+	return stmt + using, args, nil
 }
 
-func (c *Cassandra) createSession(consistency gocql.Consistency) (*gocql.Session, error) {
-	session, err := c.cluster.CreateSession()
+// execCAS executes a lightweight transaction, which fails with an etag mismatch when it is not applied
+func (c *Cassandra) execCAS(q *gocql.Query, key string) error {
+	applied, err := q.SerialConsistency(c.serialConsistency).MapScanCAS(map[string]interface{}{})
 	if err != nil {
-		return nil, fmt.Errorf("error creating session: %s", err)
+		return err
+	}
+	if !applied {
+		return fmt.Errorf("etag mismatch of key %s", key)
 	}
 
-	session.SetConsistency(consistency)
+	return nil
+}
 
-	return session, nil
+// Features returns the features of the Cassandra state store
+func (c *Cassandra) Features() []state.Feature {
+	return []state.Feature{state.FeatureTTL}
 }
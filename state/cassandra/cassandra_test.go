@@ -9,6 +9,7 @@ import (
 	"testing"
 
 	"github.com/dapr/components-contrib/state"
+	"github.com/gocql/gocql"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -25,6 +26,9 @@ func TestGetCassandraMetadata(t *testing.T) {
 		assert.Nil(t, err)
 		assert.Equal(t, properties[hosts], metadata.hosts[0])
 		assert.Equal(t, "All", metadata.consistency)
+		assert.Equal(t, "All", metadata.readConsistency)
+		assert.Equal(t, "All", metadata.writeConsistency)
+		assert.Empty(t, metadata.serialConsistency)
 		assert.Equal(t, defaultKeyspace, metadata.keyspace)
 		assert.Equal(t, defaultProtoVersion, metadata.protoVersion)
 		assert.Equal(t, defaultReplicationFactor, metadata.replicationFactor)
@@ -43,6 +47,9 @@ func TestGetCassandraMetadata(t *testing.T) {
 			table:             "table",
 			username:          "username",
 			password:          "password",
+
+			state.MaxBulkBatchSizeMetadataKey: "50",
+			state.BulkParallelismMetadataKey:  "4",
 		}
 		m := state.Metadata{
 			Properties: properties,
@@ -50,8 +57,11 @@ func TestGetCassandraMetadata(t *testing.T) {
 
 		metadata, err := getCassandraMetadata(m)
 		assert.Nil(t, err)
+		assert.Equal(t, state.BulkConfig{MaxBatchSize: 50, Parallelism: 4}, metadata.bulk)
 		assert.Equal(t, properties[hosts], metadata.hosts[0])
 		assert.Equal(t, properties[consistency], metadata.consistency)
+		assert.Equal(t, properties[consistency], metadata.readConsistency)
+		assert.Equal(t, properties[consistency], metadata.writeConsistency)
 		assert.Equal(t, properties[keyspace], metadata.keyspace)
 		assert.Equal(t, 3, metadata.protoVersion)
 		assert.Equal(t, 2, metadata.replicationFactor)
@@ -61,6 +71,26 @@ func TestGetCassandraMetadata(t *testing.T) {
 		assert.Equal(t, 9043, metadata.port)
 	})
 
+	t.Run("With read and write consistencies", func(t *testing.T) {
+		properties := map[string]string{
+			hosts:             "127.0.0.1",
+			consistency:       "Quorum",
+			readConsistency:   "LocalOne",
+			writeConsistency:  "LocalQuorum",
+			serialConsistency: "LocalSerial",
+		}
+		m := state.Metadata{
+			Properties: properties,
+		}
+
+		metadata, err := getCassandraMetadata(m)
+		assert.Nil(t, err)
+		assert.Equal(t, "Quorum", metadata.consistency)
+		assert.Equal(t, "LocalOne", metadata.readConsistency)
+		assert.Equal(t, "LocalQuorum", metadata.writeConsistency)
+		assert.Equal(t, "LocalSerial", metadata.serialConsistency)
+	})
+
 	t.Run("Incorrect proto version", func(t *testing.T) {
 		properties := map[string]string{
 			hosts:             "127.0.0.1",
@@ -97,4 +127,82 @@ func TestGetCassandraMetadata(t *testing.T) {
 		_, err := getCassandraMetadata(m)
 		assert.NotNil(t, err)
 	})
+	t.Run("Incorrect bulk batch size", func(t *testing.T) {
+		properties := map[string]string{
+			hosts:                             "127.0.0.1",
+			state.MaxBulkBatchSizeMetadataKey: "none",
+		}
+		m := state.Metadata{
+			Properties: properties,
+		}
+
+		_, err := getCassandraMetadata(m)
+		assert.NotNil(t, err)
+	})
+}
+
+func TestGetSerialConsistency(t *testing.T) {
+	t.Run("Serial by default", func(t *testing.T) {
+		cons, err := getSerialConsistency("")
+		assert.Nil(t, err)
+		assert.Equal(t, gocql.Serial, cons)
+	})
+
+	t.Run("Local serial", func(t *testing.T) {
+		cons, err := getSerialConsistency("LocalSerial")
+		assert.Nil(t, err)
+		assert.Equal(t, gocql.LocalSerial, cons)
+	})
+
+	t.Run("Incorrect serial consistency", func(t *testing.T) {
+		_, err := getSerialConsistency("Quorum")
+		assert.NotNil(t, err)
+	})
+}
+
+func TestRequestConsistency(t *testing.T) {
+	c := &Cassandra{readConsistency: gocql.LocalOne, writeConsistency: gocql.LocalQuorum}
+
+	t.Run("Consistency of the component", func(t *testing.T) {
+		cons, err := c.getReadConsistency("", nil)
+		assert.Nil(t, err)
+		assert.Equal(t, gocql.LocalOne, cons)
+
+		cons, err = c.getWriteConsistency("", nil)
+		assert.Nil(t, err)
+		assert.Equal(t, gocql.LocalQuorum, cons)
+	})
+
+	t.Run("Strong and eventual consistency options", func(t *testing.T) {
+		cons, err := c.getReadConsistency(state.Strong, nil)
+		assert.Nil(t, err)
+		assert.Equal(t, gocql.All, cons)
+
+		cons, err = c.getReadConsistency(state.Eventual, nil)
+		assert.Nil(t, err)
+		assert.Equal(t, gocql.One, cons)
+
+		cons, err = c.getWriteConsistency(state.Strong, nil)
+		assert.Nil(t, err)
+		assert.Equal(t, gocql.Quorum, cons)
+
+		cons, err = c.getWriteConsistency(state.Eventual, nil)
+		assert.Nil(t, err)
+		assert.Equal(t, gocql.Any, cons)
+	})
+
+	t.Run("Consistency request metadata", func(t *testing.T) {
+		cons, err := c.getReadConsistency(state.Strong, map[string]string{consistency: "Two"})
+		assert.Nil(t, err)
+		assert.Equal(t, gocql.Two, cons)
+
+		cons, err = c.getWriteConsistency("", map[string]string{consistency: "EachQuorum"})
+		assert.Nil(t, err)
+		assert.Equal(t, gocql.EachQuorum, cons)
+	})
+
+	t.Run("Incorrect consistency request metadata", func(t *testing.T) {
+		_, err := c.getWriteConsistency("", map[string]string{consistency: "Most"})
+		assert.NotNil(t, err)
+	})
 }
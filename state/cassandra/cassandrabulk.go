@@ -0,0 +1,145 @@
+// ------------------------------------------------------------
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT License.
+// ------------------------------------------------------------
+
+package cassandra
+
+import (
+	"github.com/dapr/components-contrib/state"
+	"github.com/gocql/gocql"
+)
+
+// maxBatchSize is the maximum number of statements of the batches of the bulk operations,
+// which keeps the batches of small values under the batch size thresholds of Cassandra
+const maxBatchSize = 100
+
+// BulkGet retrieves the values with a query per batch of keys and consistency level
+func (c *Cassandra) BulkGet(req []state.GetRequest) (bool, []state.BulkGetResponse, error) {
+	res := make([]state.BulkGetResponse, len(req))
+	err := c.bulk.RunBatches(len(req), maxBatchSize, func(b state.Batch) error {
+		keys := map[gocql.Consistency][]string{}
+		for i := b.Start; i < b.End; i++ {
+			cons, err := c.getReadConsistency(req[i].Options.Consistency, req[i].Metadata)
+			if err != nil {
+				return err
+			}
+			keys[cons] = append(keys[cons], req[i].Key)
+		}
+
+		rows := make(map[string]map[string]interface{}, b.End-b.Start)
+		for cons, k := range keys {
+			results, err := c.session.Query("SELECT key, value, etag FROM ? WHERE key IN ?", c.table, k).Consistency(cons).Iter().SliceMap()
+			if err != nil {
+				return err
+			}
+			for _, result := range results {
+				rows[result["key"].(string)] = result
+			}
+		}
+		for i := b.Start; i < b.End; i++ {
+			data, _ := rows[req[i].Key]["value"].([]byte)
+			etag, _ := rows[req[i].Key]["etag"].(string)
+			res[i] = state.BulkGetResponse{
+				Key:  req[i].Key,
+				Data: data,
+				ETag: etag,
+			}
+		}
+
+		return nil
+	})
+	if err != nil {
+		return true, nil, err
+	}
+
+	return true, res, nil
+}
+
+// BulkSet saves the values with an unlogged batch per batch of values. The values with the first-write concurrency,
+// which are lightweight transactions, or with another consistency level than the component are saved one by one.
+func (c *Cassandra) BulkSet(req []state.SetRequest) error {
+	tasks := []func() error{}
+	batched := []state.SetRequest{}
+	for i := range req {
+		cons, err := c.getWriteConsistency(req[i].Options.Consistency, req[i].Metadata)
+		if err != nil {
+			return err
+		}
+		if req[i].Options.Concurrency == state.FirstWrite || cons != c.writeConsistency {
+			r := &req[i]
+			tasks = append(tasks, func() error {
+				return c.Set(r)
+			})
+
+			continue
+		}
+		batched = append(batched, req[i])
+	}
+
+	return c.runWithBatches(tasks, len(batched), func(b state.Batch) error {
+		batch := c.session.NewBatch(gocql.UnloggedBatch)
+		for i := b.Start; i < b.End; i++ {
+			if err := c.addSet(batch, &batched[i]); err != nil {
+				return err
+			}
+		}
+		batch.SetConsistency(c.writeConsistency)
+
+		return c.session.ExecuteBatch(batch)
+	})
+}
+
+// BulkDelete deletes the keys with an unlogged batch per batch of keys. The keys with an etag and the first-write
+// concurrency, or with another consistency level than the component, are deleted one by one.
+func (c *Cassandra) BulkDelete(req []state.DeleteRequest) error {
+	tasks := []func() error{}
+	batched := []state.DeleteRequest{}
+	for i := range req {
+		cons, err := c.getWriteConsistency(req[i].Options.Consistency, req[i].Metadata)
+		if err != nil {
+			return err
+		}
+		if (req[i].Options.Concurrency == state.FirstWrite && req[i].ETag != "") || cons != c.writeConsistency {
+			r := &req[i]
+			tasks = append(tasks, func() error {
+				return c.Delete(r)
+			})
+
+			continue
+		}
+		batched = append(batched, req[i])
+	}
+
+	return c.runWithBatches(tasks, len(batched), func(b state.Batch) error {
+		batch := c.session.NewBatch(gocql.UnloggedBatch)
+		for i := b.Start; i < b.End; i++ {
+			batch.Query("DELETE FROM ? WHERE key = ?", c.table, batched[i].Key)
+		}
+		batch.SetConsistency(c.writeConsistency)
+
+		return c.session.ExecuteBatch(batch)
+	})
+}
+
+// runWithBatches runs the tasks of the operations performed one by one along with the batches of the others
+func (c *Cassandra) runWithBatches(tasks []func() error, n int, fn func(b state.Batch) error) error {
+	if n > 0 {
+		tasks = append(tasks, func() error {
+			return c.bulk.RunBatches(n, maxBatchSize, fn)
+		})
+	}
+
+	return c.bulk.Run(tasks)
+}
+
+// addSet adds the statement saving a value to a batch
+func (c *Cassandra) addSet(batch *gocql.Batch, req *state.SetRequest) error {
+	stmt, args, err := c.setStatement(req)
+	if err != nil {
+		return err
+	}
+	batch.Query(stmt, args...)
+
+	return nil
+}
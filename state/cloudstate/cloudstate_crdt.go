@@ -561,7 +561,12 @@ func (c *CRDT) BulkDelete(req []state.DeleteRequest) error {
 
 // Set saves state into CloudState
 func (c *CRDT) Set(req *state.SetRequest) error {
-	err := c.createConnectionOnce()
+	err := state.RejectTTL(req.Metadata)
+	if err != nil {
+		return err
+	}
+
+	err = c.createConnectionOnce()
 	if err != nil {
 		return err
 	}
@@ -0,0 +1,121 @@
+// ------------------------------------------------------------
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT License.
+// ------------------------------------------------------------
+
+package cockroachdb
+
+import (
+	"fmt"
+
+	"github.com/dapr/components-contrib/state"
+	"github.com/dapr/dapr/pkg/logger"
+)
+
+// CockroachDB state store
+type CockroachDB struct {
+	logger   logger.Logger
+	dbaccess dbAccess
+}
+
+// NewCockroachDBStateStore creates a new instance of CockroachDB state store
+func NewCockroachDBStateStore(logger logger.Logger) *CockroachDB {
+	dba := newCockroachDBAccess(logger)
+
+	return newCockroachDBStateStore(logger, dba)
+}
+
+// newCockroachDBStateStore creates a CockroachDB state store on a dbAccess.
+// This unexported constructor allows injecting a dbAccess instance for unit testing.
+func newCockroachDBStateStore(logger logger.Logger, dba dbAccess) *CockroachDB {
+	return &CockroachDB{
+		logger:   logger,
+		dbaccess: dba,
+	}
+}
+
+// Init initializes the CockroachDB state store
+func (c *CockroachDB) Init(metadata state.Metadata) error {
+	return c.dbaccess.Init(metadata)
+}
+
+// Delete removes an entity from the store
+func (c *CockroachDB) Delete(req *state.DeleteRequest) error {
+	return c.dbaccess.Delete(req)
+}
+
+// BulkDelete removes multiple entries from the store in a transaction
+func (c *CockroachDB) BulkDelete(req []state.DeleteRequest) error {
+	return c.dbaccess.ExecuteMulti(nil, req)
+}
+
+// Get returns an entity from store
+func (c *CockroachDB) Get(req *state.GetRequest) (*state.GetResponse, error) {
+	return c.dbaccess.Get(req)
+}
+
+// BulkGet returns multiple entities from the store with one query
+func (c *CockroachDB) BulkGet(req []state.GetRequest) (bool, []state.BulkGetResponse, error) {
+	responses, err := c.dbaccess.BulkGet(req)
+	if err != nil {
+		return true, nil, err
+	}
+
+	return true, responses, nil
+}
+
+// Set adds/updates an entity on store
+func (c *CockroachDB) Set(req *state.SetRequest) error {
+	return c.dbaccess.Set(req)
+}
+
+// BulkSet adds/updates multiple entities on store in a transaction
+func (c *CockroachDB) BulkSet(req []state.SetRequest) error {
+	return c.dbaccess.ExecuteMulti(req, nil)
+}
+
+// Multi handles multiple transactions. Implements TransactionalStore.
+func (c *CockroachDB) Multi(request *state.TransactionalStateRequest) error {
+	var deletes []state.DeleteRequest
+	var sets []state.SetRequest
+	for _, req := range request.Operations {
+		switch req.Operation {
+		case state.Upsert:
+			if setReq, ok := req.Request.(state.SetRequest); ok {
+				sets = append(sets, setReq)
+			} else {
+				return fmt.Errorf("expecting set request")
+			}
+
+		case state.Delete:
+			if delReq, ok := req.Request.(state.DeleteRequest); ok {
+				deletes = append(deletes, delReq)
+			} else {
+				return fmt.Errorf("expecting delete request")
+			}
+
+		default:
+			return fmt.Errorf("unsupported operation: %s", req.Operation)
+		}
+	}
+
+	if len(sets) > 0 || len(deletes) > 0 {
+		return c.dbaccess.ExecuteMulti(sets, deletes)
+	}
+
+	return nil
+}
+
+// Features returns the features of the CockroachDB state store
+func (c *CockroachDB) Features() []state.Feature {
+	return []state.Feature{state.FeatureTTL}
+}
+
+// Close implements io.Closer
+func (c *CockroachDB) Close() error {
+	if c.dbaccess != nil {
+		return c.dbaccess.Close()
+	}
+
+	return nil
+}
@@ -0,0 +1,243 @@
+// ------------------------------------------------------------
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT License.
+// ------------------------------------------------------------
+package cockroachdb
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/dapr/components-contrib/state"
+	"github.com/dapr/dapr/pkg/logger"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	fakeConnectionString = "not a real connection"
+)
+
+// Fake implementation of interface cockroachdb.dbaccess
+type fakeDBaccess struct {
+	logger  logger.Logger
+	sets    []state.SetRequest
+	deletes []state.DeleteRequest
+}
+
+func (m *fakeDBaccess) Init(metadata state.Metadata) error {
+	return nil
+}
+
+func (m *fakeDBaccess) Set(req *state.SetRequest) error {
+	return nil
+}
+
+func (m *fakeDBaccess) Get(req *state.GetRequest) (*state.GetResponse, error) {
+	return nil, nil
+}
+
+func (m *fakeDBaccess) Delete(req *state.DeleteRequest) error {
+	return nil
+}
+
+func (m *fakeDBaccess) BulkGet(req []state.GetRequest) ([]state.BulkGetResponse, error) {
+	return nil, nil
+}
+
+func (m *fakeDBaccess) ExecuteMulti(sets []state.SetRequest, deletes []state.DeleteRequest) error {
+	m.sets = sets
+	m.deletes = deletes
+
+	return nil
+}
+
+func (m *fakeDBaccess) Close() error {
+	return nil
+}
+
+// sqlStateError is an error with a SQLSTATE, like the errors of the driver
+type sqlStateError struct {
+	code string
+}
+
+func (e *sqlStateError) Error() string {
+	return "sql error " + e.code
+}
+
+func (e *sqlStateError) SQLState() string {
+	return e.code
+}
+
+func TestMulti(t *testing.T) {
+	t.Parallel()
+
+	t.Run("no requests", func(t *testing.T) {
+		crdb, fake := createCockroachDBWithFake(t)
+		err := crdb.Multi(&state.TransactionalStateRequest{})
+		assert.Nil(t, err)
+		assert.Nil(t, fake.sets)
+	})
+
+	t.Run("sets and deletes are executed together", func(t *testing.T) {
+		crdb, fake := createCockroachDBWithFake(t)
+		err := crdb.Multi(&state.TransactionalStateRequest{
+			Operations: []state.TransactionalStateOperation{
+				{Operation: state.Upsert, Request: state.SetRequest{Key: "key1", Value: "value1"}},
+				{Operation: state.Delete, Request: state.DeleteRequest{Key: "key2"}},
+			},
+		})
+		assert.Nil(t, err)
+		assert.Len(t, fake.sets, 1)
+		assert.Len(t, fake.deletes, 1)
+	})
+
+	invalid := map[string]state.TransactionalStateOperation{
+		"invalid action":         {Operation: "Something invalid", Request: state.SetRequest{Key: "key"}},
+		"invalid set request":    {Operation: state.Upsert, Request: state.DeleteRequest{Key: "key"}},
+		"invalid delete request": {Operation: state.Delete, Request: state.SetRequest{Key: "key"}},
+	}
+	for name, operation := range invalid {
+		operation := operation
+		t.Run(name, func(t *testing.T) {
+			crdb := createCockroachDB(t)
+			err := crdb.Multi(&state.TransactionalStateRequest{
+				Operations: []state.TransactionalStateOperation{operation},
+			})
+			assert.NotNil(t, err)
+		})
+	}
+}
+
+func TestGetCockroachDBMetadata(t *testing.T) {
+	t.Parallel()
+
+	t.Run("defaults", func(t *testing.T) {
+		meta, err := getCockroachDBMetadata(state.Metadata{
+			Properties: map[string]string{connectionStringKey: fakeConnectionString},
+		})
+		require.NoError(t, err)
+		assert.Equal(t, fakeConnectionString, meta.connectionString)
+		assert.Equal(t, defaultTableName, meta.tableName)
+		assert.Equal(t, defaultMaxRetries, meta.maxRetries)
+	})
+
+	t.Run("all properties", func(t *testing.T) {
+		meta, err := getCockroachDBMetadata(state.Metadata{
+			Properties: map[string]string{
+				connectionStringKey: fakeConnectionString,
+				tableNameKey:        "dapr.state",
+				maxRetriesKey:       "2",
+			},
+		})
+		require.NoError(t, err)
+		assert.Equal(t, "dapr.state", meta.tableName)
+		assert.Equal(t, 2, meta.maxRetries)
+	})
+
+	invalid := map[string]map[string]string{
+		"missing connection string": {},
+		"invalid table name":        {connectionStringKey: fakeConnectionString, tableNameKey: "state; DROP TABLE state"},
+		"invalid max retries":       {connectionStringKey: fakeConnectionString, maxRetriesKey: "-1"},
+	}
+	for name, properties := range invalid {
+		properties := properties
+		t.Run(name, func(t *testing.T) {
+			_, err := getCockroachDBMetadata(state.Metadata{Properties: properties})
+			assert.Error(t, err)
+		})
+	}
+}
+
+func TestRetry(t *testing.T) {
+	t.Parallel()
+	c := &cockroachDBAccess{
+		logger:   logger.NewLogger("test"),
+		metadata: cockroachDBMetadata{maxRetries: 2},
+	}
+
+	t.Run("serialization failures are retried", func(t *testing.T) {
+		attempts := 0
+		err := c.retry(func() error {
+			attempts++
+			if attempts < 3 {
+				return fmt.Errorf("commit failed: %w", &sqlStateError{code: serializationFailure})
+			}
+
+			return nil
+		})
+		assert.NoError(t, err)
+		assert.Equal(t, 3, attempts)
+	})
+
+	t.Run("retries are limited", func(t *testing.T) {
+		attempts := 0
+		err := c.retry(func() error {
+			attempts++
+
+			return &sqlStateError{code: serializationFailure}
+		})
+		assert.Error(t, err)
+		assert.Equal(t, 3, attempts)
+	})
+
+	t.Run("other errors are not retried", func(t *testing.T) {
+		attempts := 0
+		err := c.retry(func() error {
+			attempts++
+
+			return errors.New("connection refused")
+		})
+		assert.Error(t, err)
+		assert.Equal(t, 1, attempts)
+	})
+}
+
+func TestIsSerializationFailure(t *testing.T) {
+	t.Parallel()
+	assert.True(t, isSerializationFailure(&sqlStateError{code: serializationFailure}))
+	assert.False(t, isSerializationFailure(&sqlStateError{code: "23505"}))
+	assert.False(t, isSerializationFailure(errors.New(serializationFailure)))
+}
+
+func TestExpireDate(t *testing.T) {
+	t.Parallel()
+	noExpiry := state.NoExpiry
+	ttl := 60
+
+	assert.Nil(t, expireDate(nil))
+	assert.Nil(t, expireDate(&noExpiry))
+	expiry, ok := expireDate(&ttl).(time.Time)
+	require.True(t, ok)
+	assert.WithinDuration(t, time.Now().Add(time.Minute), expiry, 5*time.Second)
+}
+
+func createCockroachDBWithFake(t *testing.T) (*CockroachDB, *fakeDBaccess) {
+	crdb := createCockroachDB(t)
+	fake := crdb.dbaccess.(*fakeDBaccess)
+
+	return crdb, fake
+}
+
+func createCockroachDB(t *testing.T) *CockroachDB {
+	logger := logger.NewLogger("test")
+
+	dba := &fakeDBaccess{
+		logger: logger,
+	}
+
+	crdb := newCockroachDBStateStore(logger, dba)
+	assert.NotNil(t, crdb)
+
+	metadata := &state.Metadata{
+		Properties: map[string]string{connectionStringKey: fakeConnectionString},
+	}
+
+	err := crdb.Init(*metadata)
+	assert.Nil(t, err)
+	assert.NotNil(t, crdb.dbaccess)
+
+	return crdb
+}
@@ -0,0 +1,416 @@
+// ------------------------------------------------------------
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT License.
+// ------------------------------------------------------------
+
+package cockroachdb
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"time"
+
+	"github.com/dapr/components-contrib/state"
+	"github.com/dapr/components-contrib/state/utils"
+	"github.com/dapr/dapr/pkg/logger"
+
+	// Blank import for the underlying PostgreSQL driver, CockroachDB speaks the PostgreSQL wire protocol
+	_ "github.com/jackc/pgx/v4/stdlib"
+)
+
+const (
+	connectionStringKey        = "connectionString"
+	tableNameKey               = "tableName"
+	maxRetriesKey              = "maxRetries"
+	errMissingConnectionString = "missing connection string"
+	defaultTableName           = "state"
+	defaultMaxRetries          = 5
+	retryBackoff               = 20 * time.Millisecond
+
+	// serializationFailure is the SQLSTATE of the transactions which CockroachDB aborts to keep
+	// their serializable isolation, they succeed when they are retried
+	serializationFailure = "40001"
+)
+
+var tableNameRegexp = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*(\.[A-Za-z_][A-Za-z0-9_]*)?$`)
+
+// execer executes the statements of the operations, either on the database or in a transaction
+type execer interface {
+	Exec(query string, args ...interface{}) (sql.Result, error)
+}
+
+type cockroachDBMetadata struct {
+	connectionString string
+	tableName        string
+	maxRetries       int
+}
+
+// cockroachDBAccess implements dbaccess
+type cockroachDBAccess struct {
+	logger   logger.Logger
+	metadata cockroachDBMetadata
+	db       *sql.DB
+}
+
+// newCockroachDBAccess creates a new instance of cockroachDBAccess
+func newCockroachDBAccess(logger logger.Logger) *cockroachDBAccess {
+	logger.Debug("Instantiating new CockroachDB state store")
+
+	return &cockroachDBAccess{
+		logger: logger,
+	}
+}
+
+func getCockroachDBMetadata(metadata state.Metadata) (cockroachDBMetadata, error) {
+	meta := cockroachDBMetadata{
+		tableName:  defaultTableName,
+		maxRetries: defaultMaxRetries,
+	}
+
+	if val, ok := metadata.Properties[connectionStringKey]; ok && val != "" {
+		meta.connectionString = val
+	} else {
+		return meta, errors.New(errMissingConnectionString)
+	}
+
+	if val, ok := metadata.Properties[tableNameKey]; ok && val != "" {
+		if !tableNameRegexp.MatchString(val) {
+			return meta, fmt.Errorf("invalid table name %s", val)
+		}
+		meta.tableName = val
+	}
+
+	if val, ok := metadata.Properties[maxRetriesKey]; ok && val != "" {
+		maxRetries, err := strconv.Atoi(val)
+		if err != nil || maxRetries < 0 {
+			return meta, fmt.Errorf("%s value must be a non negative integer: actual is '%s'", maxRetriesKey, val)
+		}
+		meta.maxRetries = maxRetries
+	}
+
+	return meta, nil
+}
+
+// Init sets up CockroachDB connection and ensures that the state table exists
+func (c *cockroachDBAccess) Init(metadata state.Metadata) error {
+	c.logger.Debug("Initializing CockroachDB state store")
+	meta, err := getCockroachDBMetadata(metadata)
+	if err != nil {
+		c.logger.Error(err)
+
+		return err
+	}
+	c.metadata = meta
+
+	db, err := sql.Open("pgx", meta.connectionString)
+	if err != nil {
+		c.logger.Error(err)
+
+		return err
+	}
+
+	c.db = db
+
+	if err = db.Ping(); err != nil {
+		return err
+	}
+
+	return c.ensureStateTable()
+}
+
+// ensureStateTable creates the state table unless it exists, and sets its row-level TTL
+func (c *cockroachDBAccess) ensureStateTable() error {
+	// Sprintf is required for table name because sql.DB does not substitute parameters for table names.
+	_, err := c.db.Exec(fmt.Sprintf(`CREATE TABLE IF NOT EXISTS %s (
+									key STRING NOT NULL PRIMARY KEY,
+									value JSONB NOT NULL,
+									etag INT NOT NULL DEFAULT 1,
+									insertdate TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+									updatedate TIMESTAMPTZ NULL,
+									expiredate TIMESTAMPTZ NULL);`, c.metadata.tableName))
+	if err != nil {
+		return err
+	}
+
+	// CockroachDB deletes the rows once they expire, the rows without expiredate never expire.
+	// Row-level TTL requires CockroachDB 22.2 or later.
+	_, err = c.db.Exec(fmt.Sprintf("ALTER TABLE %s SET (ttl_expiration_expression = 'expiredate');", c.metadata.tableName))
+	if err != nil {
+		return fmt.Errorf("error setting the row-level TTL of the %s table: %s", c.metadata.tableName, err)
+	}
+
+	return nil
+}
+
+// Set makes an insert or update to the database, retried on serialization failures.
+func (c *cockroachDBAccess) Set(req *state.SetRequest) error {
+	return c.retry(func() error {
+		return c.execSet(c.db, req)
+	})
+}
+
+// execSet makes an insert or update with the execer, which is the database or a transaction.
+// The etag of the row is incremented by every update.
+func (c *cockroachDBAccess) execSet(db execer, req *state.SetRequest) error {
+	c.logger.Debug("Setting state value in CockroachDB")
+
+	err := state.CheckRequestOptions(req.Options)
+	if err != nil {
+		return err
+	}
+
+	if req.Key == "" {
+		return fmt.Errorf("missing key in set operation")
+	}
+
+	ttl, err := state.ParseTTL(req.Metadata)
+	if err != nil {
+		return fmt.Errorf("error parsing TTL from metadata: %s", err)
+	}
+
+	// Convert to json string
+	bt, _ := utils.Marshal(req.Value, json.Marshal)
+	value := string(bt)
+
+	var result sql.Result
+	if req.ETag == "" {
+		result, err = db.Exec(fmt.Sprintf(
+			`INSERT INTO %[1]s (key, value, expiredate) VALUES ($1, $2, $3)
+			ON CONFLICT (key) DO UPDATE SET value = $2, expiredate = $3, etag = %[1]s.etag + 1, updatedate = NOW();`,
+			c.metadata.tableName), req.Key, value, expireDate(ttl))
+	} else {
+		var etag int
+		etag, err = strconv.Atoi(req.ETag)
+		if err != nil {
+			return err
+		}
+
+		// When an etag is provided do an update - no insert
+		result, err = db.Exec(fmt.Sprintf(
+			`UPDATE %s SET value = $1, expiredate = $2, etag = etag + 1, updatedate = NOW()
+			WHERE key = $3 AND etag = $4 AND (expiredate IS NULL OR expiredate > NOW());`,
+			c.metadata.tableName), value, expireDate(ttl), req.Key, etag)
+	}
+	if err != nil {
+		return err
+	}
+	if req.ETag == "" {
+		return nil
+	}
+
+	return c.returnSingleDBResult(result)
+}
+
+// Get returns data from the database. If data does not exist for the key, or has expired,
+// an empty state.GetResponse will be returned.
+func (c *cockroachDBAccess) Get(req *state.GetRequest) (*state.GetResponse, error) {
+	c.logger.Debug("Getting state value from CockroachDB")
+	if req.Key == "" {
+		return nil, fmt.Errorf("missing key in get operation")
+	}
+
+	var value string
+	var etag int
+	err := c.db.QueryRow(fmt.Sprintf(
+		"SELECT value, etag FROM %s WHERE key = $1 AND (expiredate IS NULL OR expiredate > NOW())",
+		c.metadata.tableName), req.Key).Scan(&value, &etag)
+	if err != nil {
+		// If no rows exist, return an empty response, otherwise return the error.
+		if err == sql.ErrNoRows {
+			return &state.GetResponse{}, nil
+		}
+
+		return nil, err
+	}
+
+	return &state.GetResponse{
+		Data:     []byte(value),
+		ETag:     strconv.Itoa(etag),
+		Metadata: req.Metadata,
+	}, nil
+}
+
+// BulkGet returns the data of the keys with one query. The keys without data have an empty response.
+func (c *cockroachDBAccess) BulkGet(req []state.GetRequest) ([]state.BulkGetResponse, error) {
+	c.logger.Debug("Getting state values from CockroachDB")
+	keys := make([]string, len(req))
+	for i := range req {
+		if req[i].Key == "" {
+			return nil, fmt.Errorf("missing key in bulk get operation")
+		}
+		keys[i] = req[i].Key
+	}
+
+	rows, err := c.db.Query(fmt.Sprintf(
+		"SELECT key, value, etag FROM %s WHERE key = ANY($1) AND (expiredate IS NULL OR expiredate > NOW())",
+		c.metadata.tableName), keys)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	found := make(map[string]state.BulkGetResponse, len(req))
+	for rows.Next() {
+		var key, value string
+		var etag int
+		if err = rows.Scan(&key, &value, &etag); err != nil {
+			return nil, err
+		}
+		found[key] = state.BulkGetResponse{
+			Key:  key,
+			Data: []byte(value),
+			ETag: strconv.Itoa(etag),
+		}
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	responses := make([]state.BulkGetResponse, len(req))
+	for i := range req {
+		response, ok := found[req[i].Key]
+		if !ok {
+			response = state.BulkGetResponse{Key: req[i].Key}
+		}
+		response.Metadata = req[i].Metadata
+		responses[i] = response
+	}
+
+	return responses, nil
+}
+
+// Delete removes an item from the state store, retried on serialization failures.
+func (c *cockroachDBAccess) Delete(req *state.DeleteRequest) error {
+	return c.retry(func() error {
+		return c.execDelete(c.db, req)
+	})
+}
+
+// execDelete removes an item with the execer, which is the database or a transaction.
+// Deleting a missing item succeeds, unless an etag is provided.
+func (c *cockroachDBAccess) execDelete(db execer, req *state.DeleteRequest) error {
+	c.logger.Debug("Deleting state value from CockroachDB")
+
+	err := state.CheckRequestOptions(req.Options)
+	if err != nil {
+		return err
+	}
+
+	if req.Key == "" {
+		return fmt.Errorf("missing key in delete operation")
+	}
+
+	if req.ETag == "" {
+		_, err = db.Exec(fmt.Sprintf("DELETE FROM %s WHERE key = $1", c.metadata.tableName), req.Key)
+
+		return err
+	}
+
+	etag, err := strconv.Atoi(req.ETag)
+	if err != nil {
+		return err
+	}
+	result, err := db.Exec(fmt.Sprintf("DELETE FROM %s WHERE key = $1 AND etag = $2", c.metadata.tableName), req.Key, etag)
+	if err != nil {
+		return err
+	}
+
+	return c.returnSingleDBResult(result)
+}
+
+// ExecuteMulti executes the deletes then the sets in a transaction, which is rolled back when one of them fails.
+// The whole transaction is retried on serialization failures.
+func (c *cockroachDBAccess) ExecuteMulti(sets []state.SetRequest, deletes []state.DeleteRequest) error {
+	c.logger.Debug("Executing multiple CockroachDB operations")
+
+	return c.retry(func() error {
+		tx, err := c.db.Begin()
+		if err != nil {
+			return err
+		}
+
+		for i := range deletes {
+			if err = c.execDelete(tx, &deletes[i]); err != nil {
+				tx.Rollback()
+
+				return err
+			}
+		}
+
+		for i := range sets {
+			if err = c.execSet(tx, &sets[i]); err != nil {
+				tx.Rollback()
+
+				return err
+			}
+		}
+
+		return tx.Commit()
+	})
+}
+
+// retry calls an operation again when CockroachDB aborts it with a serialization failure,
+// up to maxRetries times with an exponential backoff
+func (c *cockroachDBAccess) retry(operation func() error) error {
+	backoff := retryBackoff
+	for attempt := 0; ; attempt++ {
+		err := operation()
+		if err == nil || !isSerializationFailure(err) || attempt >= c.metadata.maxRetries {
+			return err
+		}
+
+		c.logger.Debugf("Retrying CockroachDB operation after serialization failure: %s", err)
+		time.Sleep(backoff)
+		backoff *= 2
+	}
+}
+
+// isSerializationFailure tells whether an error is a serialization failure, which is to be retried
+func isSerializationFailure(err error) bool {
+	var sqlErr interface {
+		SQLState() string
+	}
+
+	return errors.As(err, &sqlErr) && sqlErr.SQLState() == serializationFailure
+}
+
+// expireDate returns the expiredate of a time to live, nil when the row never expires
+func expireDate(ttl *int) interface{} {
+	if ttl == nil || *ttl == state.NoExpiry {
+		return nil
+	}
+
+	return time.Now().Add(time.Duration(*ttl) * time.Second).UTC()
+}
+
+// Verifies that the sql.Result affected one row, which the etag matched
+func (c *cockroachDBAccess) returnSingleDBResult(result sql.Result) error {
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		c.logger.Error(err)
+
+		return err
+	}
+
+	if rowsAffected == 0 {
+		noRowsErr := errors.New("database operation failed: no rows match given key and etag")
+		c.logger.Debug(noRowsErr)
+
+		return noRowsErr
+	}
+
+	return nil
+}
+
+// Close implements io.Close
+func (c *cockroachDBAccess) Close() error {
+	if c.db != nil {
+		return c.db.Close()
+	}
+
+	return nil
+}
@@ -0,0 +1,239 @@
+// ------------------------------------------------------------
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT License.
+// ------------------------------------------------------------
+
+package cockroachdb
+
+import (
+	"database/sql/driver"
+	"errors"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/dapr/components-contrib/state"
+	"github.com/dapr/dapr/pkg/logger"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	upsertQuery = "INSERT INTO state (key, value, expiredate) VALUES ($1, $2, $3) " +
+		"ON CONFLICT (key) DO UPDATE SET value = $2, expiredate = $3, etag = state.etag + 1, updatedate = NOW();"
+	updateQuery = "UPDATE state SET value = $1, expiredate = $2, etag = etag + 1, updatedate = NOW() " +
+		"WHERE key = $3 AND etag = $4 AND (expiredate IS NULL OR expiredate > NOW());"
+	getQuery        = "SELECT value, etag FROM state WHERE key = $1 AND (expiredate IS NULL OR expiredate > NOW())"
+	bulkGetQuery    = "SELECT key, value, etag FROM state WHERE key = ANY($1) AND (expiredate IS NULL OR expiredate > NOW())"
+	deleteQuery     = "DELETE FROM state WHERE key = $1"
+	etagDeleteQuery = "DELETE FROM state WHERE key = $1 AND etag = $2"
+)
+
+// keysConverter passes the keys of the ANY($1) queries to the mock driver as is
+type keysConverter struct{}
+
+func (keysConverter) ConvertValue(v interface{}) (driver.Value, error) {
+	if keys, ok := v.([]string); ok {
+		return keys, nil
+	}
+
+	return driver.DefaultParameterConverter.ConvertValue(v)
+}
+
+func newMockDBAccess(t *testing.T) (*cockroachDBAccess, sqlmock.Sqlmock) {
+	db, mock, err := sqlmock.New(
+		sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual),
+		sqlmock.ValueConverterOption(keysConverter{}))
+	require.NoError(t, err)
+
+	c := newCockroachDBAccess(logger.NewLogger("test"))
+	c.metadata = cockroachDBMetadata{tableName: defaultTableName, maxRetries: 1}
+	c.db = db
+
+	return c, mock
+}
+
+func TestSet(t *testing.T) {
+	t.Parallel()
+
+	t.Run("upsert without etag", func(t *testing.T) {
+		c, mock := newMockDBAccess(t)
+		mock.ExpectExec(upsertQuery).
+			WithArgs("key", `"value"`, nil).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+
+		err := c.Set(&state.SetRequest{Key: "key", Value: "value"})
+		assert.NoError(t, err)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("update with etag and ttl", func(t *testing.T) {
+		c, mock := newMockDBAccess(t)
+		mock.ExpectExec(updateQuery).
+			WithArgs(`{"n":1}`, sqlmock.AnyArg(), "key", 3).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+
+		err := c.Set(&state.SetRequest{
+			Key:      "key",
+			Value:    []byte(`{"n":1}`),
+			ETag:     "3",
+			Metadata: map[string]string{"ttlInSeconds": "60"},
+		})
+		assert.NoError(t, err)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("etag mismatch", func(t *testing.T) {
+		c, mock := newMockDBAccess(t)
+		mock.ExpectExec(updateQuery).
+			WithArgs(`"value"`, nil, "key", 3).
+			WillReturnResult(sqlmock.NewResult(0, 0))
+
+		err := c.Set(&state.SetRequest{Key: "key", Value: "value", ETag: "3"})
+		assert.Error(t, err)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("invalid etag", func(t *testing.T) {
+		c, mock := newMockDBAccess(t)
+
+		err := c.Set(&state.SetRequest{Key: "key", Value: "value", ETag: "not a number"})
+		assert.Error(t, err)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("serialization failure is retried", func(t *testing.T) {
+		c, mock := newMockDBAccess(t)
+		mock.ExpectExec(upsertQuery).
+			WithArgs("key", `"value"`, nil).
+			WillReturnError(&sqlStateError{code: serializationFailure})
+		mock.ExpectExec(upsertQuery).
+			WithArgs("key", `"value"`, nil).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+
+		err := c.Set(&state.SetRequest{Key: "key", Value: "value"})
+		assert.NoError(t, err)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+}
+
+func TestGet(t *testing.T) {
+	t.Parallel()
+
+	t.Run("found", func(t *testing.T) {
+		c, mock := newMockDBAccess(t)
+		mock.ExpectQuery(getQuery).
+			WithArgs("key").
+			WillReturnRows(sqlmock.NewRows([]string{"value", "etag"}).AddRow(`"value"`, 7))
+
+		res, err := c.Get(&state.GetRequest{Key: "key"})
+		assert.NoError(t, err)
+		assert.Equal(t, []byte(`"value"`), res.Data)
+		assert.Equal(t, "7", res.ETag)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("missing or expired", func(t *testing.T) {
+		c, mock := newMockDBAccess(t)
+		mock.ExpectQuery(getQuery).
+			WithArgs("key").
+			WillReturnRows(sqlmock.NewRows([]string{"value", "etag"}))
+
+		res, err := c.Get(&state.GetRequest{Key: "key"})
+		assert.NoError(t, err)
+		assert.Equal(t, &state.GetResponse{}, res)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("query error", func(t *testing.T) {
+		c, mock := newMockDBAccess(t)
+		mock.ExpectQuery(getQuery).
+			WithArgs("key").
+			WillReturnError(errors.New("connection reset"))
+
+		_, err := c.Get(&state.GetRequest{Key: "key"})
+		assert.Error(t, err)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+}
+
+func TestBulkGet(t *testing.T) {
+	t.Parallel()
+	c, mock := newMockDBAccess(t)
+	mock.ExpectQuery(bulkGetQuery).
+		WithArgs([]string{"a", "b"}).
+		WillReturnRows(sqlmock.NewRows([]string{"key", "value", "etag"}).AddRow("b", `"two"`, 2))
+
+	responses, err := c.BulkGet([]state.GetRequest{{Key: "a"}, {Key: "b"}})
+	assert.NoError(t, err)
+	assert.Equal(t, []state.BulkGetResponse{
+		{Key: "a"},
+		{Key: "b", Data: []byte(`"two"`), ETag: "2"},
+	}, responses)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestDelete(t *testing.T) {
+	t.Parallel()
+
+	t.Run("without etag", func(t *testing.T) {
+		c, mock := newMockDBAccess(t)
+		mock.ExpectExec(deleteQuery).
+			WithArgs("key").
+			WillReturnResult(sqlmock.NewResult(0, 0))
+
+		assert.NoError(t, c.Delete(&state.DeleteRequest{Key: "key"}))
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("etag mismatch", func(t *testing.T) {
+		c, mock := newMockDBAccess(t)
+		mock.ExpectExec(etagDeleteQuery).
+			WithArgs("key", 3).
+			WillReturnResult(sqlmock.NewResult(0, 0))
+
+		assert.Error(t, c.Delete(&state.DeleteRequest{Key: "key", ETag: "3"}))
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+}
+
+func TestExecuteMulti(t *testing.T) {
+	t.Parallel()
+
+	t.Run("commit", func(t *testing.T) {
+		c, mock := newMockDBAccess(t)
+		mock.ExpectBegin()
+		mock.ExpectExec(deleteQuery).WithArgs("b").WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectExec(upsertQuery).WithArgs("a", `"value"`, nil).WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectCommit()
+
+		err := c.ExecuteMulti([]state.SetRequest{{Key: "a", Value: "value"}}, []state.DeleteRequest{{Key: "b"}})
+		assert.NoError(t, err)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("etag mismatch rolls back", func(t *testing.T) {
+		c, mock := newMockDBAccess(t)
+		mock.ExpectBegin()
+		mock.ExpectExec(deleteQuery).WithArgs("b").WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectExec(updateQuery).WithArgs(`"value"`, nil, "a", 3).WillReturnResult(sqlmock.NewResult(0, 0))
+		mock.ExpectRollback()
+
+		err := c.ExecuteMulti([]state.SetRequest{{Key: "a", Value: "value", ETag: "3"}}, []state.DeleteRequest{{Key: "b"}})
+		assert.Error(t, err)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("serialization failure retries the transaction", func(t *testing.T) {
+		c, mock := newMockDBAccess(t)
+		mock.ExpectBegin()
+		mock.ExpectExec(upsertQuery).WithArgs("a", `"value"`, nil).WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectCommit().WillReturnError(&sqlStateError{code: serializationFailure})
+		mock.ExpectBegin()
+		mock.ExpectExec(upsertQuery).WithArgs("a", `"value"`, nil).WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectCommit()
+
+		err := c.ExecuteMulti([]state.SetRequest{{Key: "a", Value: "value"}}, nil)
+		assert.NoError(t, err)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+}
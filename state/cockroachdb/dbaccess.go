@@ -0,0 +1,21 @@
+// ------------------------------------------------------------
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT License.
+// ------------------------------------------------------------
+
+package cockroachdb
+
+import (
+	"github.com/dapr/components-contrib/state"
+)
+
+// dbAccess is a private interface which enables unit testing of CockroachDB
+type dbAccess interface {
+	Init(metadata state.Metadata) error
+	Set(req *state.SetRequest) error
+	Get(req *state.GetRequest) (*state.GetResponse, error)
+	Delete(req *state.DeleteRequest) error
+	BulkGet(req []state.GetRequest) ([]state.BulkGetResponse, error)
+	ExecuteMulti(sets []state.SetRequest, deletes []state.DeleteRequest) error
+	Close() error // io.Closer
+}
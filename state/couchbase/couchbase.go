@@ -132,6 +132,10 @@ func (cbs *Couchbase) Set(req *state.SetRequest) error {
 	if err != nil {
 		return err
 	}
+	err = state.RejectTTL(req.Metadata)
+	if err != nil {
+		return err
+	}
 	value, err := utils.Marshal(req.Value, cbs.json.Marshal)
 	if err != nil {
 		return fmt.Errorf("couchbase error: failed to convert value %v", err)
@@ -0,0 +1,300 @@
+// ------------------------------------------------------------
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT License.
+// ------------------------------------------------------------
+
+package state
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/dapr/components-contrib/state/utils"
+	jsoniter "github.com/json-iterator/go"
+)
+
+const (
+	// PrimaryEncryptionKeyMetadataKey is the component metadata key of the hex encoded AES key which encrypts the values.
+	// It is usually a reference to a secret store.
+	PrimaryEncryptionKeyMetadataKey = "primaryEncryptionKey"
+	// SecondaryEncryptionKeyMetadataKey is the component metadata key of the hex encoded AES key which decrypts
+	// the values encrypted before the primary key was rotated
+	SecondaryEncryptionKeyMetadataKey = "secondaryEncryptionKey"
+
+	// encryptedValueSeparator separates the version of the key from the encrypted value
+	encryptedValueSeparator = ":"
+)
+
+// ErrValueNotEncrypted is returned when reading a value which was not encrypted by a key of the store
+var ErrValueNotEncrypted = errors.New("value is not encrypted by a known key")
+
+// encryptionKey is an AES-GCM key, along with its version which prefixes the values it encrypts
+type encryptionKey struct {
+	version string
+	aead    cipher.AEAD
+}
+
+// encryption encrypts the values with the primary key, and decrypts them with the key of their version
+type encryption struct {
+	primary *encryptionKey
+	keys    map[string]*encryptionKey
+}
+
+// parseEncryptionKey returns the key of a hex encoded AES-128, AES-192 or AES-256 key.
+// Its version is derived from the key, so that rotating the key changes the version.
+func parseEncryptionKey(metadataKey, val string) (*encryptionKey, error) {
+	b, err := hex.DecodeString(strings.TrimSpace(val))
+	if err != nil {
+		return nil, fmt.Errorf("%s value must be a hex encoded key: %s", metadataKey, err)
+	}
+	block, err := aes.NewCipher(b)
+	if err != nil {
+		return nil, fmt.Errorf("%s value must be a 128, 192 or 256 bits key: %s", metadataKey, err)
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	sum := sha256.Sum256(b)
+
+	return &encryptionKey{
+		version: hex.EncodeToString(sum[:4]),
+		aead:    aead,
+	}, nil
+}
+
+// parseEncryption returns the encryption of the keys set in the component metadata, nil when there is no primary key
+func parseEncryption(metadata map[string]string) (*encryption, error) {
+	val, ok := metadata[PrimaryEncryptionKeyMetadataKey]
+	if !ok || val == "" {
+		if metadata[SecondaryEncryptionKeyMetadataKey] != "" {
+			return nil, fmt.Errorf("%s requires %s", SecondaryEncryptionKeyMetadataKey, PrimaryEncryptionKeyMetadataKey)
+		}
+
+		return nil, nil
+	}
+
+	primary, err := parseEncryptionKey(PrimaryEncryptionKeyMetadataKey, val)
+	if err != nil {
+		return nil, err
+	}
+	e := &encryption{
+		primary: primary,
+		keys:    map[string]*encryptionKey{primary.version: primary},
+	}
+
+	if val, ok := metadata[SecondaryEncryptionKeyMetadataKey]; ok && val != "" {
+		secondary, err := parseEncryptionKey(SecondaryEncryptionKeyMetadataKey, val)
+		if err != nil {
+			return nil, err
+		}
+		e.keys[secondary.version] = secondary
+	}
+
+	return e, nil
+}
+
+// encrypt returns the encrypted value of a key, as a JSON string so that every store accepts it.
+// The state key authenticates the value, which can't be copied to another key.
+func (e *encryption) encrypt(key string, value interface{}) ([]byte, error) {
+	plaintext, err := utils.Marshal(value, jsoniter.ConfigFastest.Marshal)
+	if err != nil {
+		return nil, err
+	}
+
+	nonce := make([]byte, e.primary.aead.NonceSize())
+	if _, err = io.ReadFull(rand.Reader, nonce); err != nil {
+		return nil, err
+	}
+	sealed := e.primary.aead.Seal(nonce, nonce, plaintext, []byte(key))
+
+	return json.Marshal(e.primary.version + encryptedValueSeparator + base64.StdEncoding.EncodeToString(sealed))
+}
+
+// decrypt returns the value of a key encrypted by encrypt, with the key of its version
+func (e *encryption) decrypt(key string, data []byte) ([]byte, error) {
+	var encrypted string
+	if err := json.Unmarshal(data, &encrypted); err != nil {
+		return nil, ErrValueNotEncrypted
+	}
+	parts := strings.SplitN(encrypted, encryptedValueSeparator, 2)
+	if len(parts) != 2 {
+		return nil, ErrValueNotEncrypted
+	}
+	k, ok := e.keys[parts[0]]
+	if !ok {
+		return nil, ErrValueNotEncrypted
+	}
+	sealed, err := base64.StdEncoding.DecodeString(parts[1])
+	if err != nil || len(sealed) < k.aead.NonceSize() {
+		return nil, ErrValueNotEncrypted
+	}
+
+	nonce, ciphertext := sealed[:k.aead.NonceSize()], sealed[k.aead.NonceSize():]
+	plaintext, err := k.aead.Open(nil, nonce, ciphertext, []byte(key))
+	if err != nil {
+		return nil, fmt.Errorf("failed to decrypt value of key %s: %s", key, err)
+	}
+
+	return plaintext, nil
+}
+
+// EncryptedStore encrypts the values of a store with AES-GCM, when the component metadata sets the
+// primaryEncryptionKey. The values are prefixed with the version of their key: rotating the key is done by
+// moving the previous primary key to the secondaryEncryptionKey, the values it encrypted are still decrypted
+// and encrypted with the new primary key when they are set again.
+type EncryptedStore struct {
+	store      Store
+	encryption *encryption
+}
+
+// encryptedTransactionalStore is an encrypted store of a TransactionalStore
+type encryptedTransactionalStore struct {
+	*EncryptedStore
+}
+
+// NewEncryptedStore wraps a store with the encryption of its values. The returned store implements
+// TransactionalStore when the wrapped store does, but none of Querier, Lister, Locker and StateWatcher:
+// the queries can't filter the encrypted values, and the other interfaces are turned off by the encryption.
+func NewEncryptedStore(store Store) Store {
+	s := &EncryptedStore{store: store}
+	if _, ok := store.(TransactionalStore); ok {
+		return &encryptedTransactionalStore{s}
+	}
+
+	return s
+}
+
+// Init parses the encryption keys and initializes the wrapped store
+func (s *EncryptedStore) Init(metadata Metadata) error {
+	e, err := parseEncryption(metadata.Properties)
+	if err != nil {
+		return err
+	}
+	s.encryption = e
+
+	return s.store.Init(metadata)
+}
+
+// Features returns the features of the wrapped store. The features of the interfaces which the encrypted
+// store does not implement, such as querying, are not available through it.
+func (s *EncryptedStore) Features() []Feature {
+	return Features(s.store)
+}
+
+// Get retrieves and decrypts a value
+func (s *EncryptedStore) Get(req *GetRequest) (*GetResponse, error) {
+	res, err := s.store.Get(req)
+	if err != nil || s.encryption == nil || res == nil || len(res.Data) == 0 {
+		return res, err
+	}
+
+	data, err := s.encryption.decrypt(req.Key, res.Data)
+	if err != nil {
+		return nil, err
+	}
+	res.Data = data
+
+	return res, nil
+}
+
+// Set encrypts and saves a value
+func (s *EncryptedStore) Set(req *SetRequest) error {
+	r, err := s.encryptRequest(req)
+	if err != nil {
+		return err
+	}
+
+	return s.store.Set(r)
+}
+
+// Delete deletes a value
+func (s *EncryptedStore) Delete(req *DeleteRequest) error {
+	return s.store.Delete(req)
+}
+
+// BulkGet retrieves and decrypts values, the values which fail to decrypt have an error
+func (s *EncryptedStore) BulkGet(req []GetRequest) (bool, []BulkGetResponse, error) {
+	bulkGet, res, err := s.store.BulkGet(req)
+	if err != nil || !bulkGet || s.encryption == nil {
+		return bulkGet, res, err
+	}
+
+	for i := range res {
+		if res[i].Error != "" || len(res[i].Data) == 0 {
+			continue
+		}
+		data, err := s.encryption.decrypt(res[i].Key, res[i].Data)
+		if err != nil {
+			res[i].Data = nil
+			res[i].Error = err.Error()
+
+			continue
+		}
+		res[i].Data = data
+	}
+
+	return true, res, nil
+}
+
+// BulkSet encrypts and saves values
+func (s *EncryptedStore) BulkSet(req []SetRequest) error {
+	encrypted := make([]SetRequest, len(req))
+	for i := range req {
+		r, err := s.encryptRequest(&req[i])
+		if err != nil {
+			return err
+		}
+		encrypted[i] = *r
+	}
+
+	return s.store.BulkSet(encrypted)
+}
+
+// BulkDelete deletes values
+func (s *EncryptedStore) BulkDelete(req []DeleteRequest) error {
+	return s.store.BulkDelete(req)
+}
+
+// Multi encrypts the values of the upserts and performs the transaction
+func (s *encryptedTransactionalStore) Multi(request *TransactionalStateRequest) error {
+	encrypted := *request
+	encrypted.Operations = make([]TransactionalStateOperation, len(request.Operations))
+	for i, o := range request.Operations {
+		if req, ok := o.Request.(SetRequest); ok && o.Operation == Upsert {
+			r, err := s.encryptRequest(&req)
+			if err != nil {
+				return err
+			}
+			o.Request = *r
+		}
+		encrypted.Operations[i] = o
+	}
+
+	return s.store.(TransactionalStore).Multi(&encrypted)
+}
+
+// encryptRequest returns a copy of a set request with its value encrypted
+func (s *EncryptedStore) encryptRequest(req *SetRequest) (*SetRequest, error) {
+	if s.encryption == nil {
+		return req, nil
+	}
+
+	value, err := s.encryption.encrypt(req.Key, req.Value)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encrypt value of key %s: %s", req.Key, err)
+	}
+	r := *req
+	r.Value = value
+
+	return &r, nil
+}
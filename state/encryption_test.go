@@ -0,0 +1,241 @@
+// ------------------------------------------------------------
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT License.
+// ------------------------------------------------------------
+
+package state
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	testPrimaryKey   = "000102030405060708090a0b0c0d0e0f101112131415161718191a1b1c1d1e1f"
+	testSecondaryKey = "1f1e1d1c1b1a191817161514131211100f0e0d0c0b0a09080706050403020100"
+)
+
+// mapStore is a store keeping the raw values it is given
+type mapStore struct {
+	DefaultBulkStore
+	values map[string][]byte
+}
+
+func newMapStore() *mapStore {
+	s := &mapStore{values: map[string][]byte{}}
+	s.DefaultBulkStore = NewDefaultBulkStore(s)
+
+	return s
+}
+
+func (s *mapStore) Init(metadata Metadata) error {
+	return nil
+}
+
+func (s *mapStore) Get(req *GetRequest) (*GetResponse, error) {
+	return &GetResponse{Data: s.values[req.Key]}, nil
+}
+
+func (s *mapStore) Set(req *SetRequest) error {
+	s.values[req.Key] = req.Value.([]byte)
+
+	return nil
+}
+
+func (s *mapStore) Delete(req *DeleteRequest) error {
+	delete(s.values, req.Key)
+
+	return nil
+}
+
+// transactionalMapStore is a mapStore which implements TransactionalStore
+type transactionalMapStore struct {
+	*mapStore
+}
+
+func (s *transactionalMapStore) Multi(request *TransactionalStateRequest) error {
+	for _, o := range request.Operations {
+		switch req := o.Request.(type) {
+		case SetRequest:
+			s.Set(&req)
+		case DeleteRequest:
+			s.Delete(&req)
+		}
+	}
+
+	return nil
+}
+
+func initEncryptedStore(t *testing.T, inner Store, properties map[string]string) Store {
+	s := NewEncryptedStore(inner)
+	require.NoError(t, s.Init(Metadata{Properties: properties}))
+
+	return s
+}
+
+func TestParseEncryption(t *testing.T) {
+	t.Run("no key", func(t *testing.T) {
+		e, err := parseEncryption(map[string]string{})
+
+		assert.NoError(t, err)
+		assert.Nil(t, e)
+	})
+
+	t.Run("primary and secondary keys", func(t *testing.T) {
+		e, err := parseEncryption(map[string]string{
+			PrimaryEncryptionKeyMetadataKey:   testPrimaryKey,
+			SecondaryEncryptionKeyMetadataKey: testSecondaryKey,
+		})
+
+		require.NoError(t, err)
+		assert.Len(t, e.keys, 2)
+		assert.Len(t, e.primary.version, 8)
+	})
+
+	invalid := map[string]map[string]string{
+		"not hex":                   {PrimaryEncryptionKeyMetadataKey: "not a key"},
+		"invalid size":              {PrimaryEncryptionKeyMetadataKey: "0001020304"},
+		"invalid secondary":         {PrimaryEncryptionKeyMetadataKey: testPrimaryKey, SecondaryEncryptionKeyMetadataKey: "0001"},
+		"secondary without primary": {SecondaryEncryptionKeyMetadataKey: testSecondaryKey},
+	}
+	for name, properties := range invalid {
+		properties := properties
+		t.Run(name, func(t *testing.T) {
+			_, err := parseEncryption(properties)
+
+			assert.Error(t, err)
+		})
+	}
+}
+
+func TestEncryptedStore(t *testing.T) {
+	t.Run("values are encrypted at rest", func(t *testing.T) {
+		inner := newMapStore()
+		s := initEncryptedStore(t, inner, map[string]string{PrimaryEncryptionKeyMetadataKey: testPrimaryKey})
+
+		require.NoError(t, s.Set(&SetRequest{Key: "weapon", Value: struct {
+			Name string `json:"name"`
+		}{Name: "deathstar"}}))
+		assert.NotContains(t, string(inner.values["weapon"]), "deathstar")
+
+		res, err := s.Get(&GetRequest{Key: "weapon"})
+		require.NoError(t, err)
+		assert.Equal(t, `{"name":"deathstar"}`, string(res.Data))
+	})
+
+	t.Run("bytes are encrypted as is", func(t *testing.T) {
+		s := initEncryptedStore(t, newMapStore(), map[string]string{PrimaryEncryptionKeyMetadataKey: testPrimaryKey})
+
+		require.NoError(t, s.Set(&SetRequest{Key: "weapon", Value: []byte("deathstar")}))
+
+		res, err := s.Get(&GetRequest{Key: "weapon"})
+		require.NoError(t, err)
+		assert.Equal(t, "deathstar", string(res.Data))
+	})
+
+	t.Run("missing values", func(t *testing.T) {
+		s := initEncryptedStore(t, newMapStore(), map[string]string{PrimaryEncryptionKeyMetadataKey: testPrimaryKey})
+
+		res, err := s.Get(&GetRequest{Key: "missing"})
+		require.NoError(t, err)
+		assert.Empty(t, res.Data)
+	})
+
+	t.Run("values can't be moved to another key", func(t *testing.T) {
+		inner := newMapStore()
+		s := initEncryptedStore(t, inner, map[string]string{PrimaryEncryptionKeyMetadataKey: testPrimaryKey})
+
+		require.NoError(t, s.Set(&SetRequest{Key: "weapon", Value: []byte("deathstar")}))
+		inner.values["other"] = inner.values["weapon"]
+
+		_, err := s.Get(&GetRequest{Key: "other"})
+		assert.Error(t, err)
+	})
+
+	t.Run("plain values are rejected", func(t *testing.T) {
+		inner := newMapStore()
+		s := initEncryptedStore(t, inner, map[string]string{PrimaryEncryptionKeyMetadataKey: testPrimaryKey})
+		inner.values["weapon"] = []byte(`"deathstar"`)
+
+		_, err := s.Get(&GetRequest{Key: "weapon"})
+		assert.Equal(t, ErrValueNotEncrypted, err)
+	})
+
+	t.Run("without key the values are not encrypted", func(t *testing.T) {
+		inner := newMapStore()
+		s := initEncryptedStore(t, inner, map[string]string{})
+
+		require.NoError(t, s.Set(&SetRequest{Key: "weapon", Value: []byte("deathstar")}))
+		assert.Equal(t, "deathstar", string(inner.values["weapon"]))
+	})
+}
+
+func TestEncryptionKeyRotation(t *testing.T) {
+	inner := newMapStore()
+	old := initEncryptedStore(t, inner, map[string]string{PrimaryEncryptionKeyMetadataKey: testPrimaryKey})
+	require.NoError(t, old.Set(&SetRequest{Key: "weapon", Value: []byte("deathstar")}))
+
+	rotated := initEncryptedStore(t, inner, map[string]string{
+		PrimaryEncryptionKeyMetadataKey:   testSecondaryKey,
+		SecondaryEncryptionKeyMetadataKey: testPrimaryKey,
+	})
+
+	res, err := rotated.Get(&GetRequest{Key: "weapon"})
+	require.NoError(t, err)
+	assert.Equal(t, "deathstar", string(res.Data))
+
+	// the values set again are encrypted with the new primary key
+	require.NoError(t, rotated.Set(&SetRequest{Key: "weapon", Value: []byte("deathstar")}))
+	version := strings.SplitN(strings.Trim(string(inner.values["weapon"]), `"`), ":", 2)[0]
+	assert.Equal(t, rotated.(*EncryptedStore).encryption.primary.version, version)
+
+	_, err = old.Get(&GetRequest{Key: "weapon"})
+	assert.Equal(t, ErrValueNotEncrypted, err)
+}
+
+func TestEncryptedBulkOperations(t *testing.T) {
+	inner := newMapStore()
+	s := initEncryptedStore(t, inner, map[string]string{PrimaryEncryptionKeyMetadataKey: testPrimaryKey})
+
+	require.NoError(t, s.BulkSet([]SetRequest{
+		{Key: "weapon1", Value: []byte("deathstar")},
+		{Key: "weapon2", Value: []byte("lightsaber")},
+	}))
+	assert.NotEqual(t, "deathstar", string(inner.values["weapon1"]))
+
+	res, err := s.Get(&GetRequest{Key: "weapon2"})
+	require.NoError(t, err)
+	assert.Equal(t, "lightsaber", string(res.Data))
+
+	require.NoError(t, s.BulkDelete([]DeleteRequest{{Key: "weapon1"}, {Key: "weapon2"}}))
+	assert.Empty(t, inner.values)
+}
+
+func TestEncryptedTransactionalStore(t *testing.T) {
+	t.Run("transactional stores stay transactional", func(t *testing.T) {
+		inner := &transactionalMapStore{newMapStore()}
+		s := initEncryptedStore(t, inner, map[string]string{PrimaryEncryptionKeyMetadataKey: testPrimaryKey})
+
+		ts, ok := s.(TransactionalStore)
+		require.True(t, ok)
+		require.NoError(t, ts.Multi(&TransactionalStateRequest{
+			Operations: []TransactionalStateOperation{
+				{Operation: Upsert, Request: SetRequest{Key: "weapon", Value: []byte("deathstar")}},
+			},
+		}))
+		assert.NotEqual(t, "deathstar", string(inner.values["weapon"]))
+
+		res, err := s.Get(&GetRequest{Key: "weapon"})
+		require.NoError(t, err)
+		assert.Equal(t, "deathstar", string(res.Data))
+	})
+
+	t.Run("other stores are not transactional", func(t *testing.T) {
+		_, ok := NewEncryptedStore(newMapStore()).(TransactionalStore)
+
+		assert.False(t, ok)
+	})
+}
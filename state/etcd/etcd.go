@@ -0,0 +1,355 @@
+// ------------------------------------------------------------
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT License.
+// ------------------------------------------------------------
+
+package etcd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/dapr/components-contrib/state"
+	"github.com/dapr/components-contrib/state/utils"
+	"github.com/dapr/dapr/pkg/logger"
+	jsoniter "github.com/json-iterator/go"
+	clientv3 "go.etcd.io/etcd/client/v3"
+)
+
+const (
+	endpointsKey     = "endpoints"
+	keyPrefixPathKey = "keyPrefixPath"
+	usernameKey      = "username"
+	passwordKey      = "password"
+	dialTimeoutKey   = "dialTimeout"
+
+	defaultDialTimeout = 5 * time.Second
+	operationTimeout   = 10 * time.Second
+)
+
+var (
+	errMissingEndpoints = errors.New("endpoints are required")
+	errETagMismatch     = errors.New("etag mismatch: the key was modified or deleted")
+)
+
+type config struct {
+	endpoints     []string
+	keyPrefixPath string
+	username      string
+	password      string
+	dialTimeout   time.Duration
+}
+
+func newConfig(metadata map[string]string) (*config, error) {
+	c := &config{
+		keyPrefixPath: metadata[keyPrefixPathKey],
+		username:      metadata[usernameKey],
+		password:      metadata[passwordKey],
+		dialTimeout:   defaultDialTimeout,
+	}
+
+	for _, endpoint := range strings.Split(metadata[endpointsKey], ",") {
+		if endpoint = strings.TrimSpace(endpoint); endpoint != "" {
+			c.endpoints = append(c.endpoints, endpoint)
+		}
+	}
+	if len(c.endpoints) == 0 {
+		return nil, errMissingEndpoints
+	}
+
+	if val, ok := metadata[dialTimeoutKey]; ok && val != "" {
+		dialTimeout, err := time.ParseDuration(val)
+		if err != nil || dialTimeout <= 0 {
+			return nil, fmt.Errorf("etcd error: invalid %s %s", dialTimeoutKey, val)
+		}
+		c.dialTimeout = dialTimeout
+	}
+
+	return c, nil
+}
+
+// Etcd is a state store on etcd v3. The etags are the mod revisions of the keys, the time to live of the
+// values is the lease they are attached to, and the transactions are etcd txns.
+type Etcd struct {
+	config *config
+	client *clientv3.Client
+	logger logger.Logger
+}
+
+// txn is the comparisons and operations of an etcd txn, along with the leases granted for its operations
+type txn struct {
+	cmps   []clientv3.Cmp
+	ops    []clientv3.Op
+	leases []clientv3.LeaseID
+}
+
+// NewEtcdStateStore returns a new etcd state store
+func NewEtcdStateStore(logger logger.Logger) *Etcd {
+	return &Etcd{logger: logger}
+}
+
+// Init connects to etcd
+func (e *Etcd) Init(metadata state.Metadata) error {
+	c, err := newConfig(metadata.Properties)
+	if err != nil {
+		return err
+	}
+
+	client, err := clientv3.New(clientv3.Config{
+		Endpoints:   c.endpoints,
+		DialTimeout: c.dialTimeout,
+		Username:    c.username,
+		Password:    c.password,
+	})
+	if err != nil {
+		return fmt.Errorf("etcd error: connecting to %s: %s", strings.Join(c.endpoints, ","), err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), c.dialTimeout)
+	defer cancel()
+	if _, err = client.Status(ctx, c.endpoints[0]); err != nil {
+		client.Close()
+
+		return fmt.Errorf("etcd error: connecting to %s: %s", c.endpoints[0], err)
+	}
+
+	e.config = c
+	e.client = client
+
+	return nil
+}
+
+// Features returns the features of the etcd state store
+func (e *Etcd) Features() []state.Feature {
+	return []state.Feature{state.FeatureTTL}
+}
+
+// Get retrieves a value, its etag is its mod revision
+func (e *Etcd) Get(req *state.GetRequest) (*state.GetResponse, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), operationTimeout)
+	defer cancel()
+
+	resp, err := e.client.Get(ctx, e.prefixedKey(req.Key))
+	if err != nil {
+		return nil, fmt.Errorf("etcd error: failed to get key %s: %s", req.Key, err)
+	}
+	if len(resp.Kvs) == 0 {
+		return &state.GetResponse{}, nil
+	}
+
+	return &state.GetResponse{
+		Data: resp.Kvs[0].Value,
+		ETag: strconv.FormatInt(resp.Kvs[0].ModRevision, 10),
+	}, nil
+}
+
+// BulkGet is not supported natively, the values are retrieved one at a time
+func (e *Etcd) BulkGet(req []state.GetRequest) (bool, []state.BulkGetResponse, error) {
+	return false, nil, nil
+}
+
+// Set saves a value, with a lease when it has a time to live
+func (e *Etcd) Set(req *state.SetRequest) error {
+	return e.BulkSet([]state.SetRequest{*req})
+}
+
+// BulkSet saves values in one txn
+func (e *Etcd) BulkSet(req []state.SetRequest) error {
+	ctx, cancel := context.WithTimeout(context.Background(), operationTimeout)
+	defer cancel()
+
+	t := &txn{}
+	for i := range req {
+		if err := e.addSet(ctx, t, &req[i]); err != nil {
+			e.revokeLeases(t)
+
+			return err
+		}
+	}
+
+	return e.commit(ctx, t)
+}
+
+// Delete deletes a value
+func (e *Etcd) Delete(req *state.DeleteRequest) error {
+	return e.BulkDelete([]state.DeleteRequest{*req})
+}
+
+// BulkDelete deletes values in one txn
+func (e *Etcd) BulkDelete(req []state.DeleteRequest) error {
+	t := &txn{}
+	for i := range req {
+		if err := e.addDelete(t, &req[i]); err != nil {
+			return err
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), operationTimeout)
+	defer cancel()
+
+	return e.commit(ctx, t)
+}
+
+// Multi performs the operations in one txn, which fails when the etag of one of them does not match.
+// etcd rejects the txns which set a key more than once, and limits their number of operations (128 by default).
+func (e *Etcd) Multi(request *state.TransactionalStateRequest) error {
+	ctx, cancel := context.WithTimeout(context.Background(), operationTimeout)
+	defer cancel()
+
+	t := &txn{}
+	for _, o := range request.Operations {
+		var err error
+		switch o.Operation {
+		case state.Upsert:
+			if req, ok := o.Request.(state.SetRequest); ok {
+				err = e.addSet(ctx, t, &req)
+			} else {
+				err = fmt.Errorf("expecting set request")
+			}
+
+		case state.Delete:
+			if req, ok := o.Request.(state.DeleteRequest); ok {
+				err = e.addDelete(t, &req)
+			} else {
+				err = fmt.Errorf("expecting delete request")
+			}
+
+		default:
+			err = fmt.Errorf("unsupported operation: %s", o.Operation)
+		}
+		if err != nil {
+			e.revokeLeases(t)
+
+			return err
+		}
+	}
+
+	return e.commit(ctx, t)
+}
+
+// addSet adds the put of a value to a txn. The values with a time to live are attached to a new lease,
+// the others are detached from their lease.
+func (e *Etcd) addSet(ctx context.Context, t *txn, req *state.SetRequest) error {
+	err := state.CheckRequestOptions(req.Options)
+	if err != nil {
+		return err
+	}
+
+	ttl, err := state.ParseTTL(req.Metadata)
+	if err != nil {
+		return fmt.Errorf("etcd error: failed to parse ttl from metadata: %s", err)
+	}
+
+	value, err := utils.Marshal(req.Value, jsoniter.ConfigFastest.Marshal)
+	if err != nil {
+		return fmt.Errorf("etcd error: failed to marshal value of key %s: %s", req.Key, err)
+	}
+
+	cmp, err := e.etagCmp(req.Key, req.ETag, req.Options.Concurrency)
+	if err != nil {
+		return err
+	}
+
+	var opts []clientv3.OpOption
+	if ttl != nil && *ttl != state.NoExpiry {
+		lease, err := e.client.Grant(ctx, int64(*ttl))
+		if err != nil {
+			return fmt.Errorf("etcd error: failed to grant lease of key %s: %s", req.Key, err)
+		}
+		t.leases = append(t.leases, lease.ID)
+		opts = append(opts, clientv3.WithLease(lease.ID))
+	}
+
+	t.cmps = append(t.cmps, cmp...)
+	t.ops = append(t.ops, clientv3.OpPut(e.prefixedKey(req.Key), string(value), opts...))
+
+	return nil
+}
+
+// addDelete adds the delete of a value to a txn
+func (e *Etcd) addDelete(t *txn, req *state.DeleteRequest) error {
+	err := state.CheckRequestOptions(req.Options)
+	if err != nil {
+		return err
+	}
+
+	cmp, err := e.etagCmp(req.Key, req.ETag, req.Options.Concurrency)
+	if err != nil {
+		return err
+	}
+
+	t.cmps = append(t.cmps, cmp...)
+	t.ops = append(t.ops, clientv3.OpDelete(e.prefixedKey(req.Key)))
+
+	return nil
+}
+
+// etagCmp returns the comparison of the mod revision of a key to its etag. The last-write concurrency and the
+// requests without etag overwrite the key unconditionally.
+func (e *Etcd) etagCmp(key string, etag string, concurrency string) ([]clientv3.Cmp, error) {
+	if concurrency == state.LastWrite || etag == "" {
+		return nil, nil
+	}
+
+	revision, err := strconv.ParseInt(etag, 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("etcd error: invalid etag %s of key %s", etag, key)
+	}
+
+	return []clientv3.Cmp{clientv3.Compare(clientv3.ModRevision(e.prefixedKey(key)), "=", revision)}, nil
+}
+
+// commit commits a txn, the leases it granted are revoked when it fails
+func (e *Etcd) commit(ctx context.Context, t *txn) error {
+	if len(t.ops) == 0 {
+		return nil
+	}
+
+	resp, err := e.client.Txn(ctx).If(t.cmps...).Then(t.ops...).Commit()
+	if err != nil {
+		e.revokeLeases(t)
+
+		return fmt.Errorf("etcd error: failed to commit txn: %s", err)
+	}
+	if !resp.Succeeded {
+		e.revokeLeases(t)
+
+		return errETagMismatch
+	}
+
+	return nil
+}
+
+// revokeLeases revokes the leases granted for a txn which was not committed, they would expire anyway
+func (e *Etcd) revokeLeases(t *txn) {
+	ctx, cancel := context.WithTimeout(context.Background(), operationTimeout)
+	defer cancel()
+
+	for _, lease := range t.leases {
+		if _, err := e.client.Revoke(ctx, lease); err != nil {
+			e.logger.Warnf("etcd error: failed to revoke lease %d: %s", lease, err)
+		}
+	}
+}
+
+func (e *Etcd) prefixedKey(key string) string {
+	if e.config == nil || e.config.keyPrefixPath == "" {
+		return key
+	}
+
+	return path.Join(e.config.keyPrefixPath, key)
+}
+
+// Close closes the connection to etcd
+func (e *Etcd) Close() error {
+	if e.client != nil {
+		return e.client.Close()
+	}
+
+	return nil
+}
@@ -0,0 +1,114 @@
+// ------------------------------------------------------------
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT License.
+// ------------------------------------------------------------
+
+package etcd
+
+import (
+	"testing"
+	"time"
+
+	"github.com/dapr/components-contrib/state"
+	"github.com/dapr/dapr/pkg/logger"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewConfig(t *testing.T) {
+	t.Run("defaults", func(t *testing.T) {
+		c, err := newConfig(map[string]string{endpointsKey: "localhost:2379"})
+
+		require.NoError(t, err)
+		assert.Equal(t, []string{"localhost:2379"}, c.endpoints)
+		assert.Equal(t, defaultDialTimeout, c.dialTimeout)
+		assert.Empty(t, c.keyPrefixPath)
+	})
+
+	t.Run("all properties", func(t *testing.T) {
+		c, err := newConfig(map[string]string{
+			endpointsKey:     "etcd-0:2379, etcd-1:2379",
+			keyPrefixPathKey: "/dapr",
+			usernameKey:      "dapr",
+			passwordKey:      "secret",
+			dialTimeoutKey:   "2s",
+		})
+
+		require.NoError(t, err)
+		assert.Equal(t, []string{"etcd-0:2379", "etcd-1:2379"}, c.endpoints)
+		assert.Equal(t, "/dapr", c.keyPrefixPath)
+		assert.Equal(t, "dapr", c.username)
+		assert.Equal(t, "secret", c.password)
+		assert.Equal(t, 2*time.Second, c.dialTimeout)
+	})
+
+	t.Run("missing endpoints", func(t *testing.T) {
+		_, err := newConfig(map[string]string{endpointsKey: " , "})
+
+		assert.Equal(t, errMissingEndpoints, err)
+	})
+
+	t.Run("invalid dial timeout", func(t *testing.T) {
+		_, err := newConfig(map[string]string{endpointsKey: "localhost:2379", dialTimeoutKey: "soon"})
+
+		assert.Error(t, err)
+	})
+}
+
+func TestETagCmp(t *testing.T) {
+	e := &Etcd{config: &config{keyPrefixPath: "/dapr"}}
+
+	t.Run("etag is compared to the mod revision", func(t *testing.T) {
+		cmps, err := e.etagCmp("key", "42", state.FirstWrite)
+
+		require.NoError(t, err)
+		require.Len(t, cmps, 1)
+		assert.Equal(t, "/dapr/key", string(cmps[0].KeyBytes()))
+	})
+
+	t.Run("without etag", func(t *testing.T) {
+		cmps, err := e.etagCmp("key", "", "")
+
+		assert.NoError(t, err)
+		assert.Empty(t, cmps)
+	})
+
+	t.Run("last write ignores the etag", func(t *testing.T) {
+		cmps, err := e.etagCmp("key", "42", state.LastWrite)
+
+		assert.NoError(t, err)
+		assert.Empty(t, cmps)
+	})
+
+	t.Run("invalid etag", func(t *testing.T) {
+		_, err := e.etagCmp("key", "not a revision", "")
+
+		assert.Error(t, err)
+	})
+}
+
+func TestPrefixedKey(t *testing.T) {
+	assert.Equal(t, "key", (&Etcd{}).prefixedKey("key"))
+	assert.Equal(t, "key", (&Etcd{config: &config{}}).prefixedKey("key"))
+	assert.Equal(t, "/dapr/key", (&Etcd{config: &config{keyPrefixPath: "/dapr"}}).prefixedKey("key"))
+}
+
+func TestInvalidMulti(t *testing.T) {
+	e := NewEtcdStateStore(logger.NewLogger("test"))
+	invalid := map[string]state.TransactionalStateOperation{
+		"invalid action":         {Operation: "Something invalid", Request: state.SetRequest{Key: "key"}},
+		"invalid set request":    {Operation: state.Upsert, Request: state.DeleteRequest{Key: "key"}},
+		"invalid delete request": {Operation: state.Delete, Request: state.SetRequest{Key: "key"}},
+		"invalid etag":           {Operation: state.Delete, Request: state.DeleteRequest{Key: "key", ETag: "not a revision"}},
+	}
+	for name, operation := range invalid {
+		operation := operation
+		t.Run(name, func(t *testing.T) {
+			err := e.Multi(&state.TransactionalStateRequest{
+				Operations: []state.TransactionalStateOperation{operation},
+			})
+
+			assert.Error(t, err)
+		})
+	}
+}
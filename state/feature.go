@@ -0,0 +1,39 @@
+// ------------------------------------------------------------
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT License.
+// ------------------------------------------------------------
+
+package state
+
+const (
+	// FeatureTTL is the feature to expire the values set with the ttlInSeconds metadata.
+	FeatureTTL Feature = "TTL"
+)
+
+// Feature names a feature that can be implemented by state stores.
+type Feature string
+
+// FeatureStore is implemented by the state stores which support optional features.
+type FeatureStore interface {
+	Features() []Feature
+}
+
+// IsPresent checks if a given feature is present in the list.
+func (f Feature) IsPresent(features []Feature) bool {
+	for _, feature := range features {
+		if feature == f {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Features returns the features of a store, none if it does not implement FeatureStore.
+func Features(store interface{}) []Feature {
+	if s, ok := store.(FeatureStore); ok {
+		return s.Features()
+	}
+
+	return nil
+}
@@ -102,6 +102,10 @@ func (f *Firestore) setValue(req *state.SetRequest) error {
 	if err != nil {
 		return err
 	}
+	err = state.RejectTTL(req.Metadata)
+	if err != nil {
+		return err
+	}
 
 	var v string
 	b, ok := req.Value.([]byte)
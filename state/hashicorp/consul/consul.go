@@ -109,6 +109,10 @@ func (c *Consul) Get(req *state.GetRequest) (*state.GetResponse, error) {
 
 // Set saves a Consul KV item
 func (c *Consul) Set(req *state.SetRequest) error {
+	if err := state.RejectTTL(req.Metadata); err != nil {
+		return err
+	}
+
 	var reqValByte []byte
 	b, ok := req.Value.([]byte)
 	if ok {
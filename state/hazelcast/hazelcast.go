@@ -77,6 +77,10 @@ func (store *Hazelcast) Set(req *state.SetRequest) error {
 	if err != nil {
 		return err
 	}
+	err = state.RejectTTL(req.Metadata)
+	if err != nil {
+		return err
+	}
 
 	var value string
 	b, ok := req.Value.([]byte)
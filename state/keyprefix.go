@@ -0,0 +1,235 @@
+// ------------------------------------------------------------
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT License.
+// ------------------------------------------------------------
+
+package state
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+const (
+	// KeyPrefixMetadataKey is the component metadata key of the strategy prefixing the keys of a store:
+	// appid (the default), name, namespace, none, or a template of the prefix such as {namespace}.{appid}
+	KeyPrefixMetadataKey = "keyPrefix"
+
+	// KeyPrefixAppID prefixes the keys with the ID of the application, so that applications sharing a store
+	// don't share their keys
+	KeyPrefixAppID = "appid"
+	// KeyPrefixName prefixes the keys with the name of the store, so that the applications using the store share
+	// their keys
+	KeyPrefixName = "name"
+	// KeyPrefixNamespace prefixes the keys with the namespace, so that the applications of a namespace share
+	// their keys
+	KeyPrefixNamespace = "namespace"
+	// KeyPrefixNone does not prefix the keys
+	KeyPrefixNone = "none"
+
+	// keyPrefixSeparator separates the prefix from the key
+	keyPrefixSeparator = "||"
+)
+
+// keyPrefixPlaceholder matches the placeholders of a key prefix template
+var keyPrefixPlaceholder = regexp.MustCompile(`\{[^{}]*\}`)
+
+// KeyPrefixScope holds the values of the placeholders of the key prefixes
+type KeyPrefixScope struct {
+	AppID     string
+	StoreName string
+	Namespace string
+}
+
+// keyPrefixTemplates are the templates of the key prefix strategies
+var keyPrefixTemplates = map[string]string{
+	KeyPrefixAppID:     "{appid}",
+	KeyPrefixName:      "{name}",
+	KeyPrefixNamespace: "{namespace}",
+	KeyPrefixNone:      "",
+}
+
+// ParseKeyPrefix returns the prefix of the keys of the strategy set in the component metadata, without its separator.
+// It returns an error when a placeholder of the template has no value, rather than not prefixing the keys, so that
+// the applications never share their keys by mistake, e.g. with the namespace strategy in standalone mode.
+func ParseKeyPrefix(metadata map[string]string, scope KeyPrefixScope) (string, error) {
+	strategy := KeyPrefixAppID
+	if val, ok := metadata[KeyPrefixMetadataKey]; ok && val != "" {
+		strategy = val
+	}
+	template, ok := keyPrefixTemplates[strings.ToLower(strategy)]
+	if !ok {
+		template = strategy
+	}
+
+	values := map[string]string{
+		"{appid}":     scope.AppID,
+		"{name}":      scope.StoreName,
+		"{namespace}": scope.Namespace,
+	}
+	var err error
+	prefix := keyPrefixPlaceholder.ReplaceAllStringFunc(template, func(placeholder string) string {
+		val, ok := values[strings.ToLower(placeholder)]
+		if err != nil {
+			return val
+		}
+		if !ok {
+			err = fmt.Errorf("unknown placeholder %s of the %s metadata", placeholder, KeyPrefixMetadataKey)
+		} else if val == "" {
+			err = fmt.Errorf("the placeholder %s of the %s metadata has no value", placeholder, KeyPrefixMetadataKey)
+		}
+
+		return val
+	})
+	if err != nil {
+		return "", err
+	}
+	if strings.Contains(prefix, keyPrefixSeparator) {
+		return "", fmt.Errorf("the key prefix %s contains the separator %s", prefix, keyPrefixSeparator)
+	}
+
+	return prefix, nil
+}
+
+// PrefixedStore prefixes the keys of a store with the prefix of the keyPrefix metadata and a separator, so that the
+// applications sharing a store don't share their keys, unless their prefix strategy makes them share them.
+type PrefixedStore struct {
+	store  Store
+	scope  KeyPrefixScope
+	prefix string
+}
+
+// prefixedTransactionalStore is a prefixed store of a TransactionalStore
+type prefixedTransactionalStore struct {
+	*PrefixedStore
+}
+
+// NewPrefixedStore wraps a store with the prefix of its keys. The returned store implements TransactionalStore when
+// the wrapped store does.
+func NewPrefixedStore(store Store, scope KeyPrefixScope) Store {
+	s := &PrefixedStore{store: store, scope: scope}
+	if _, ok := store.(TransactionalStore); ok {
+		return &prefixedTransactionalStore{s}
+	}
+
+	return s
+}
+
+// Init parses the key prefix and initializes the wrapped store
+func (s *PrefixedStore) Init(metadata Metadata) error {
+	prefix, err := ParseKeyPrefix(metadata.Properties, s.scope)
+	if err != nil {
+		return err
+	}
+	s.prefix = prefix
+
+	return s.store.Init(metadata)
+}
+
+// Features returns the features of the wrapped store
+func (s *PrefixedStore) Features() []Feature {
+	return Features(s.store)
+}
+
+// Get retrieves the value of a prefixed key
+func (s *PrefixedStore) Get(req *GetRequest) (*GetResponse, error) {
+	r := *req
+	r.Key = s.prefixed(req.Key)
+
+	return s.store.Get(&r)
+}
+
+// Set saves the value of a prefixed key
+func (s *PrefixedStore) Set(req *SetRequest) error {
+	r := *req
+	r.Key = s.prefixed(req.Key)
+
+	return s.store.Set(&r)
+}
+
+// Delete deletes the value of a prefixed key
+func (s *PrefixedStore) Delete(req *DeleteRequest) error {
+	r := *req
+	r.Key = s.prefixed(req.Key)
+
+	return s.store.Delete(&r)
+}
+
+// BulkGet retrieves the values of prefixed keys, the responses have the keys of the requests
+func (s *PrefixedStore) BulkGet(req []GetRequest) (bool, []BulkGetResponse, error) {
+	prefixed := make([]GetRequest, len(req))
+	for i := range req {
+		prefixed[i] = req[i]
+		prefixed[i].Key = s.prefixed(req[i].Key)
+	}
+
+	bulkGet, res, err := s.store.BulkGet(prefixed)
+	if err != nil || !bulkGet {
+		return bulkGet, res, err
+	}
+	for i := range res {
+		res[i].Key = s.unprefixed(res[i].Key)
+	}
+
+	return true, res, nil
+}
+
+// BulkSet saves the values of prefixed keys
+func (s *PrefixedStore) BulkSet(req []SetRequest) error {
+	prefixed := make([]SetRequest, len(req))
+	for i := range req {
+		prefixed[i] = req[i]
+		prefixed[i].Key = s.prefixed(req[i].Key)
+	}
+
+	return s.store.BulkSet(prefixed)
+}
+
+// BulkDelete deletes the values of prefixed keys
+func (s *PrefixedStore) BulkDelete(req []DeleteRequest) error {
+	prefixed := make([]DeleteRequest, len(req))
+	for i := range req {
+		prefixed[i] = req[i]
+		prefixed[i].Key = s.prefixed(req[i].Key)
+	}
+
+	return s.store.BulkDelete(prefixed)
+}
+
+// Multi prefixes the keys of the operations and performs the transaction
+func (s *prefixedTransactionalStore) Multi(request *TransactionalStateRequest) error {
+	prefixed := *request
+	prefixed.Operations = make([]TransactionalStateOperation, len(request.Operations))
+	for i, o := range request.Operations {
+		switch req := o.Request.(type) {
+		case SetRequest:
+			req.Key = s.prefixed(req.Key)
+			o.Request = req
+		case DeleteRequest:
+			req.Key = s.prefixed(req.Key)
+			o.Request = req
+		}
+		prefixed.Operations[i] = o
+	}
+
+	return s.store.(TransactionalStore).Multi(&prefixed)
+}
+
+// prefixed returns the key in the store of a key
+func (s *PrefixedStore) prefixed(key string) string {
+	if s.prefix == "" {
+		return key
+	}
+
+	return s.prefix + keyPrefixSeparator + key
+}
+
+// unprefixed returns the key of a key in the store
+func (s *PrefixedStore) unprefixed(key string) string {
+	if s.prefix == "" {
+		return key
+	}
+
+	return strings.TrimPrefix(key, s.prefix+keyPrefixSeparator)
+}
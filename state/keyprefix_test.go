@@ -0,0 +1,168 @@
+// ------------------------------------------------------------
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT License.
+// ------------------------------------------------------------
+
+package state
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseKeyPrefix(t *testing.T) {
+	scope := KeyPrefixScope{AppID: "orders", StoreName: "statestore", Namespace: "production"}
+
+	prefixes := map[string]string{
+		"":                         "orders",
+		"appid":                    "orders",
+		"AppID":                    "orders",
+		"name":                     "statestore",
+		"namespace":                "production",
+		"none":                     "",
+		"shared":                   "shared",
+		"{namespace}.{appid}":      "production.orders",
+		"v2-{NAME}":                "v2-statestore",
+		"{namespace}{appid}{name}": "productionordersstatestore",
+	}
+	for strategy, expected := range prefixes {
+		strategy, expected := strategy, expected
+		t.Run("strategy "+strategy, func(t *testing.T) {
+			prefix, err := ParseKeyPrefix(map[string]string{KeyPrefixMetadataKey: strategy}, scope)
+
+			require.NoError(t, err)
+			assert.Equal(t, expected, prefix)
+		})
+	}
+
+	t.Run("missing placeholder value", func(t *testing.T) {
+		_, err := ParseKeyPrefix(map[string]string{KeyPrefixMetadataKey: "{namespace}.{appid}"}, KeyPrefixScope{AppID: "orders"})
+
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "{namespace}")
+	})
+
+	t.Run("missing strategy value", func(t *testing.T) {
+		_, err := ParseKeyPrefix(map[string]string{KeyPrefixMetadataKey: KeyPrefixNamespace}, KeyPrefixScope{AppID: "orders"})
+
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "{namespace}")
+	})
+
+	t.Run("unknown placeholder", func(t *testing.T) {
+		_, err := ParseKeyPrefix(map[string]string{KeyPrefixMetadataKey: "{region}.{appid}"}, scope)
+
+		assert.Error(t, err)
+	})
+
+	t.Run("prefix with the separator", func(t *testing.T) {
+		_, err := ParseKeyPrefix(map[string]string{KeyPrefixMetadataKey: "a||b"}, scope)
+
+		assert.Error(t, err)
+	})
+}
+
+func initPrefixedStore(t *testing.T, inner Store, strategy string) Store {
+	s := NewPrefixedStore(inner, KeyPrefixScope{AppID: "orders", StoreName: "statestore"})
+	require.NoError(t, s.Init(Metadata{Properties: map[string]string{KeyPrefixMetadataKey: strategy}}))
+
+	return s
+}
+
+func TestPrefixedStore(t *testing.T) {
+	t.Run("keys are prefixed", func(t *testing.T) {
+		inner := newMapStore()
+		s := initPrefixedStore(t, inner, KeyPrefixAppID)
+
+		require.NoError(t, s.Set(&SetRequest{Key: "order-1", Value: []byte("pending")}))
+		assert.Equal(t, map[string][]byte{"orders||order-1": []byte("pending")}, inner.values)
+
+		res, err := s.Get(&GetRequest{Key: "order-1"})
+		require.NoError(t, err)
+		assert.Equal(t, []byte("pending"), res.Data)
+
+		require.NoError(t, s.Delete(&DeleteRequest{Key: "order-1"}))
+		assert.Empty(t, inner.values)
+	})
+
+	t.Run("keys are not prefixed with none", func(t *testing.T) {
+		inner := newMapStore()
+		s := initPrefixedStore(t, inner, KeyPrefixNone)
+
+		require.NoError(t, s.Set(&SetRequest{Key: "order-1", Value: []byte("pending")}))
+		assert.Equal(t, map[string][]byte{"order-1": []byte("pending")}, inner.values)
+	})
+
+	t.Run("applications share the keys of the store name", func(t *testing.T) {
+		inner := newMapStore()
+		writer := NewPrefixedStore(inner, KeyPrefixScope{AppID: "orders", StoreName: "statestore"})
+		require.NoError(t, writer.Init(Metadata{Properties: map[string]string{KeyPrefixMetadataKey: KeyPrefixName}}))
+		reader := NewPrefixedStore(inner, KeyPrefixScope{AppID: "billing", StoreName: "statestore"})
+		require.NoError(t, reader.Init(Metadata{Properties: map[string]string{KeyPrefixMetadataKey: KeyPrefixName}}))
+
+		require.NoError(t, writer.Set(&SetRequest{Key: "order-1", Value: []byte("pending")}))
+		res, err := reader.Get(&GetRequest{Key: "order-1"})
+		require.NoError(t, err)
+		assert.Equal(t, []byte("pending"), res.Data)
+	})
+
+	t.Run("bulk operations", func(t *testing.T) {
+		inner := &bulkGetMapStore{newMapStore()}
+		s := initPrefixedStore(t, inner, KeyPrefixAppID)
+
+		require.NoError(t, s.BulkSet([]SetRequest{
+			{Key: "order-1", Value: []byte("pending")},
+			{Key: "order-2", Value: []byte("shipped")},
+		}))
+		assert.Equal(t, map[string][]byte{"orders||order-1": []byte("pending"), "orders||order-2": []byte("shipped")}, inner.values)
+
+		bulkGet, res, err := s.BulkGet([]GetRequest{{Key: "order-1"}, {Key: "order-2"}})
+		require.NoError(t, err)
+		assert.True(t, bulkGet)
+		assert.Equal(t, []BulkGetResponse{
+			{Key: "order-1", Data: []byte("pending")},
+			{Key: "order-2", Data: []byte("shipped")},
+		}, res)
+
+		require.NoError(t, s.BulkDelete([]DeleteRequest{{Key: "order-1"}, {Key: "order-2"}}))
+		assert.Empty(t, inner.values)
+	})
+
+	t.Run("transactions", func(t *testing.T) {
+		inner := &transactionalMapStore{newMapStore()}
+		inner.values["orders||order-2"] = []byte("draft")
+		s := initPrefixedStore(t, inner, KeyPrefixAppID)
+
+		ts, ok := s.(TransactionalStore)
+		require.True(t, ok)
+		require.NoError(t, ts.Multi(&TransactionalStateRequest{
+			Operations: []TransactionalStateOperation{
+				{Operation: Upsert, Request: SetRequest{Key: "order-1", Value: []byte("pending")}},
+				{Operation: Delete, Request: DeleteRequest{Key: "order-2"}},
+			},
+		}))
+		assert.Equal(t, map[string][]byte{"orders||order-1": []byte("pending")}, inner.values)
+	})
+
+	t.Run("not transactional", func(t *testing.T) {
+		_, ok := NewPrefixedStore(newMapStore(), KeyPrefixScope{}).(TransactionalStore)
+
+		assert.False(t, ok)
+	})
+}
+
+// bulkGetMapStore is a mapStore which gets the values in bulk
+type bulkGetMapStore struct {
+	*mapStore
+}
+
+func (s *bulkGetMapStore) BulkGet(req []GetRequest) (bool, []BulkGetResponse, error) {
+	res := make([]BulkGetResponse, len(req))
+	for i := range req {
+		res[i] = BulkGetResponse{Key: req[i].Key, Data: s.values[req[i].Key]}
+	}
+
+	return true, res, nil
+}
@@ -0,0 +1,47 @@
+// ------------------------------------------------------------
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT License.
+// ------------------------------------------------------------
+
+package state
+
+import (
+	"errors"
+)
+
+// LockKeyPrefix prefixes the keys of the locks of the stores which save them along with the values
+const LockKeyPrefix = "lock||"
+
+var (
+	// ErrLockDoesNotExist is returned when unlocking a resource which isn't locked, or whose lock expired
+	ErrLockDoesNotExist = errors.New("the lock does not exist")
+	// ErrLockBelongsToOthers is returned when unlocking a resource locked by another owner
+	ErrLockBelongsToOthers = errors.New("the lock belongs to another owner")
+)
+
+// CheckLockRequest checks that a lock request has a resource, an owner and a positive expiry
+func CheckLockRequest(req *LockRequest) error {
+	if req.ResourceID == "" {
+		return errors.New("the lock request has no resource id")
+	}
+	if req.LockOwner == "" {
+		return errors.New("the lock request has no lock owner")
+	}
+	if req.ExpiryInSeconds <= 0 {
+		return errors.New("the expiry of the lock request must be a positive number of seconds")
+	}
+
+	return nil
+}
+
+// CheckUnlockRequest checks that an unlock request has a resource and an owner
+func CheckUnlockRequest(req *UnlockRequest) error {
+	if req.ResourceID == "" {
+		return errors.New("the unlock request has no resource id")
+	}
+	if req.LockOwner == "" {
+		return errors.New("the unlock request has no lock owner")
+	}
+
+	return nil
+}
@@ -0,0 +1,30 @@
+// ------------------------------------------------------------
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT License.
+// ------------------------------------------------------------
+
+package state
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCheckLockRequest(t *testing.T) {
+	t.Run("valid", func(t *testing.T) {
+		assert.NoError(t, CheckLockRequest(&LockRequest{ResourceID: "orders", LockOwner: "worker-1", ExpiryInSeconds: 10}))
+	})
+
+	t.Run("invalid", func(t *testing.T) {
+		assert.Error(t, CheckLockRequest(&LockRequest{LockOwner: "worker-1", ExpiryInSeconds: 10}))
+		assert.Error(t, CheckLockRequest(&LockRequest{ResourceID: "orders", ExpiryInSeconds: 10}))
+		assert.Error(t, CheckLockRequest(&LockRequest{ResourceID: "orders", LockOwner: "worker-1"}))
+	})
+}
+
+func TestCheckUnlockRequest(t *testing.T) {
+	assert.NoError(t, CheckUnlockRequest(&UnlockRequest{ResourceID: "orders", LockOwner: "worker-1"}))
+	assert.Error(t, CheckUnlockRequest(&UnlockRequest{ResourceID: "orders"}))
+	assert.Error(t, CheckUnlockRequest(&UnlockRequest{LockOwner: "worker-1"}))
+}
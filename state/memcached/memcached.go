@@ -18,14 +18,19 @@ const (
 	hosts              = "hosts"
 	maxIdleConnections = "maxIdleConnections"
 	timeout            = "timeout"
+	username           = "username"
+	password           = "password"
+	enableTLS          = "enableTLS"
 	// These defaults are already provided by gomemcache
 	defaultMaxIdleConnections = 2
 	defaultTimeout            = 1000 * time.Millisecond
+	// memcached takes the expirations longer than 30 days as unix timestamps
+	maxRelativeExpiration = 30 * 24 * 60 * 60
 )
 
 type Memcached struct {
 	state.DefaultBulkStore
-	client *memcache.Client
+	client memcachedClient
 	json   jsoniter.API
 	logger logger.Logger
 }
@@ -34,6 +39,17 @@ type memcachedMetadata struct {
 	hosts              []string
 	maxIdleConnections int
 	timeout            time.Duration
+	username           string
+	password           string
+	enableTLS          bool
+}
+
+// memcachedClient is a client of the memcached servers, with the text protocol of gomemcache or the binary protocol
+type memcachedClient interface {
+	Get(key string) (*memcache.Item, error)
+	Set(item *memcache.Item) error
+	Delete(key string) error
+	Ping() error
 }
 
 func NewMemCacheStateStore(logger logger.Logger) *Memcached {
@@ -52,13 +68,17 @@ func (m *Memcached) Init(metadata state.Metadata) error {
 		return err
 	}
 
-	client := memcache.New(meta.hosts...)
-	client.Timeout = meta.timeout
-	client.MaxIdleConns = meta.maxIdleConnections
-
-	m.client = client
+	// the text protocol has neither authentication nor TLS
+	if meta.username != "" || meta.enableTLS {
+		m.client = newBinaryClient(meta)
+	} else {
+		client := memcache.New(meta.hosts...)
+		client.Timeout = meta.timeout
+		client.MaxIdleConns = meta.maxIdleConnections
+		m.client = client
+	}
 
-	err = client.Ping()
+	err = m.client.Ping()
 	if err != nil {
 		return err
 	}
@@ -94,13 +114,32 @@ func getMemcachedMetadata(metadata state.Metadata) (*memcachedMetadata, error) {
 		meta.timeout = time.Duration(p) * time.Millisecond
 	}
 
+	meta.username = metadata.Properties[username]
+	meta.password = metadata.Properties[password]
+	if meta.password != "" && meta.username == "" {
+		return nil, errors.New("missing username for the password from metadata")
+	}
+
+	if val, ok := metadata.Properties[enableTLS]; ok && val != "" {
+		p, err := strconv.ParseBool(val)
+		if err != nil {
+			return nil, fmt.Errorf("error parsing enableTLS")
+		}
+		meta.enableTLS = p
+	}
+
 	return &meta, nil
 }
 
 func (m *Memcached) setValue(req *state.SetRequest) error {
+	ttl, err := state.ParseTTL(req.Metadata)
+	if err != nil {
+		return fmt.Errorf("failed to parse ttl from metadata: %s", err)
+	}
+
 	var bt []byte
 	bt, _ = utils.Marshal(req.Value, m.json.Marshal)
-	err := m.client.Set(&memcache.Item{Key: req.Key, Value: bt})
+	err = m.client.Set(&memcache.Item{Key: req.Key, Value: bt, Expiration: expiration(ttl, time.Now())})
 	if err != nil {
 		return fmt.Errorf("failed to set key %s: %s", req.Key, err)
 	}
@@ -108,6 +147,23 @@ func (m *Memcached) setValue(req *state.SetRequest) error {
 	return nil
 }
 
+// expiration returns the expiration of an item with the given time to live, 0 never expires
+func expiration(ttl *int, now time.Time) int32 {
+	if ttl == nil || *ttl == state.NoExpiry {
+		return 0
+	}
+	if *ttl > maxRelativeExpiration {
+		return int32(now.Unix() + int64(*ttl))
+	}
+
+	return int32(*ttl)
+}
+
+// Features returns the features of the memcached state store
+func (m *Memcached) Features() []state.Feature {
+	return []state.Feature{state.FeatureTTL}
+}
+
 func (m *Memcached) Delete(req *state.DeleteRequest) error {
 	err := m.client.Delete(req.Key)
 	if err != nil {
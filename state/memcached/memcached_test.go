@@ -64,4 +64,58 @@ func TestMemcachedMetadata(t *testing.T) {
 		assert.Equal(t, 10, metadata.maxIdleConnections)
 		assert.Equal(t, 5000*time.Millisecond, metadata.timeout)
 	})
+
+	t.Run("with authentication and TLS", func(t *testing.T) {
+		properties := map[string]string{
+			"hosts":     "memcached.example.com:11211",
+			"username":  "user",
+			"password":  "secret",
+			"enableTLS": "true",
+		}
+		m := state.Metadata{
+			Properties: properties,
+		}
+		metadata, err := getMemcachedMetadata(m)
+		assert.Nil(t, err)
+		assert.Equal(t, "user", metadata.username)
+		assert.Equal(t, "secret", metadata.password)
+		assert.True(t, metadata.enableTLS)
+	})
+
+	t.Run("with password without username", func(t *testing.T) {
+		properties := map[string]string{
+			"hosts":    "localhost:11211",
+			"password": "secret",
+		}
+		m := state.Metadata{
+			Properties: properties,
+		}
+		_, err := getMemcachedMetadata(m)
+		assert.NotNil(t, err)
+	})
+
+	t.Run("with invalid enableTLS", func(t *testing.T) {
+		properties := map[string]string{
+			"hosts":     "localhost:11211",
+			"enableTLS": "yes please",
+		}
+		m := state.Metadata{
+			Properties: properties,
+		}
+		_, err := getMemcachedMetadata(m)
+		assert.NotNil(t, err)
+	})
+}
+
+func TestExpiration(t *testing.T) {
+	now := time.Unix(1600000000, 0)
+	ttl := func(seconds int) *int {
+		return &seconds
+	}
+
+	assert.Equal(t, int32(0), expiration(nil, now))
+	assert.Equal(t, int32(0), expiration(ttl(state.NoExpiry), now))
+	assert.Equal(t, int32(60), expiration(ttl(60), now))
+	// the expirations longer than 30 days are unix timestamps
+	assert.Equal(t, int32(1600000000+31*24*60*60), expiration(ttl(31*24*60*60), now))
 }
@@ -0,0 +1,300 @@
+package memcached
+
+import (
+	"crypto/tls"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/bradfitz/gomemcache/memcache"
+)
+
+// opcodes and statuses of the binary protocol
+const (
+	magicRequest  = 0x80
+	magicResponse = 0x81
+
+	opGet      = 0x00
+	opSet      = 0x01
+	opDelete   = 0x04
+	opNoop     = 0x0a
+	opSASLAuth = 0x21
+
+	statusOK          = 0x00
+	statusKeyNotFound = 0x01
+	statusAuthError   = 0x20
+
+	headerSize    = 24
+	saslMechanism = "PLAIN"
+)
+
+// binaryClient is a client of the binary protocol of memcached, which authenticates its connections with SASL PLAIN
+// when it has a username, and connects with TLS when it is enabled. As with gomemcache, the keys are distributed
+// among the servers by the CRC-32 of the key.
+type binaryClient struct {
+	servers      []string
+	username     string
+	password     string
+	tlsConfig    *tls.Config
+	timeout      time.Duration
+	maxIdleConns int
+
+	lock      sync.Mutex
+	freeConns map[string][]net.Conn
+}
+
+// binaryRequest is a request of the binary protocol
+type binaryRequest struct {
+	opcode byte
+	key    string
+	extras []byte
+	value  []byte
+}
+
+// binaryResponse is a response of the binary protocol
+type binaryResponse struct {
+	status uint16
+	extras []byte
+	value  []byte
+}
+
+func newBinaryClient(meta *memcachedMetadata) *binaryClient {
+	c := &binaryClient{
+		servers:      meta.hosts,
+		username:     meta.username,
+		password:     meta.password,
+		timeout:      meta.timeout,
+		maxIdleConns: meta.maxIdleConnections,
+		freeConns:    map[string][]net.Conn{},
+	}
+	if meta.enableTLS {
+		c.tlsConfig = &tls.Config{MinVersion: tls.VersionTLS12}
+	}
+
+	return c
+}
+
+// Get returns the item of a key, memcache.ErrCacheMiss when the key is missing
+func (c *binaryClient) Get(key string) (*memcache.Item, error) {
+	res, err := c.do(c.pickServer(key), &binaryRequest{opcode: opGet, key: key})
+	if err != nil {
+		return nil, err
+	}
+	if err = statusError(res); err != nil {
+		return nil, err
+	}
+
+	item := &memcache.Item{Key: key, Value: res.value}
+	if len(res.extras) >= 4 {
+		item.Flags = binary.BigEndian.Uint32(res.extras)
+	}
+
+	return item, nil
+}
+
+// Set saves an item
+func (c *binaryClient) Set(item *memcache.Item) error {
+	extras := make([]byte, 8)
+	binary.BigEndian.PutUint32(extras, item.Flags)
+	binary.BigEndian.PutUint32(extras[4:], uint32(item.Expiration))
+
+	res, err := c.do(c.pickServer(item.Key), &binaryRequest{opcode: opSet, key: item.Key, extras: extras, value: item.Value})
+	if err != nil {
+		return err
+	}
+
+	return statusError(res)
+}
+
+// Delete deletes the item of a key, memcache.ErrCacheMiss when the key is missing
+func (c *binaryClient) Delete(key string) error {
+	res, err := c.do(c.pickServer(key), &binaryRequest{opcode: opDelete, key: key})
+	if err != nil {
+		return err
+	}
+
+	return statusError(res)
+}
+
+// Ping checks that every server is reachable, and accepts the credentials
+func (c *binaryClient) Ping() error {
+	for _, server := range c.servers {
+		res, err := c.do(server, &binaryRequest{opcode: opNoop})
+		if err != nil {
+			return err
+		}
+		if err = statusError(res); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// pickServer returns the server of a key
+func (c *binaryClient) pickServer(key string) string {
+	if len(c.servers) == 1 {
+		return c.servers[0]
+	}
+
+	return c.servers[crc32.ChecksumIEEE([]byte(key))%uint32(len(c.servers))]
+}
+
+// do sends a request to a server and reads its response
+func (c *binaryClient) do(server string, req *binaryRequest) (*binaryResponse, error) {
+	conn, err := c.getConn(server)
+	if err != nil {
+		return nil, err
+	}
+
+	res, err := roundTrip(conn, req, c.timeout)
+	if err != nil {
+		conn.Close()
+
+		return nil, err
+	}
+	c.putFreeConn(server, conn)
+
+	return res, nil
+}
+
+// getConn returns an idle connection to a server, or a new authenticated connection
+func (c *binaryClient) getConn(server string) (net.Conn, error) {
+	c.lock.Lock()
+	if conns := c.freeConns[server]; len(conns) > 0 {
+		conn := conns[len(conns)-1]
+		c.freeConns[server] = conns[:len(conns)-1]
+		c.lock.Unlock()
+
+		return conn, nil
+	}
+	c.lock.Unlock()
+
+	dialer := &net.Dialer{Timeout: c.timeout}
+	var (
+		conn net.Conn
+		err  error
+	)
+	if c.tlsConfig != nil {
+		conn, err = tls.DialWithDialer(dialer, "tcp", server, c.tlsConfig)
+	} else {
+		conn, err = dialer.Dial("tcp", server)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if c.username != "" {
+		if err = c.authenticate(conn); err != nil {
+			conn.Close()
+
+			return nil, err
+		}
+	}
+
+	return conn, nil
+}
+
+// putFreeConn keeps a connection for the next requests to its server, unless there are enough idle connections
+func (c *binaryClient) putFreeConn(server string, conn net.Conn) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	if len(c.freeConns[server]) >= c.maxIdleConns {
+		conn.Close()
+
+		return
+	}
+	c.freeConns[server] = append(c.freeConns[server], conn)
+}
+
+// authenticate authenticates a connection with the PLAIN mechanism of SASL
+func (c *binaryClient) authenticate(conn net.Conn) error {
+	res, err := roundTrip(conn, &binaryRequest{
+		opcode: opSASLAuth,
+		key:    saslMechanism,
+		value:  []byte("\x00" + c.username + "\x00" + c.password),
+	}, c.timeout)
+	if err != nil {
+		return err
+	}
+	if res.status != statusOK {
+		return fmt.Errorf("memcached SASL authentication failed with status %#x: %s", res.status, res.value)
+	}
+
+	return nil
+}
+
+// roundTrip writes a request on a connection and reads its response
+func roundTrip(conn net.Conn, req *binaryRequest, timeout time.Duration) (*binaryResponse, error) {
+	if err := conn.SetDeadline(time.Now().Add(timeout)); err != nil {
+		return nil, err
+	}
+	if _, err := conn.Write(req.bytes()); err != nil {
+		return nil, err
+	}
+
+	return readResponse(conn)
+}
+
+// bytes returns the packet of a request
+func (r *binaryRequest) bytes() []byte {
+	bodyLen := len(r.extras) + len(r.key) + len(r.value)
+	packet := make([]byte, headerSize, headerSize+bodyLen)
+	packet[0] = magicRequest
+	packet[1] = r.opcode
+	binary.BigEndian.PutUint16(packet[2:], uint16(len(r.key)))
+	packet[4] = byte(len(r.extras))
+	binary.BigEndian.PutUint32(packet[8:], uint32(bodyLen))
+	packet = append(packet, r.extras...)
+	packet = append(packet, r.key...)
+
+	return append(packet, r.value...)
+}
+
+// readResponse reads the packet of a response
+func readResponse(r io.Reader) (*binaryResponse, error) {
+	header := make([]byte, headerSize)
+	if _, err := io.ReadFull(r, header); err != nil {
+		return nil, err
+	}
+	if header[0] != magicResponse {
+		return nil, fmt.Errorf("memcached binary protocol: unexpected magic %#x", header[0])
+	}
+	keyLen := int(binary.BigEndian.Uint16(header[2:]))
+	extrasLen := int(header[4])
+	bodyLen := int(binary.BigEndian.Uint32(header[8:]))
+	if extrasLen+keyLen > bodyLen {
+		return nil, errors.New("memcached binary protocol: malformed response")
+	}
+
+	body := make([]byte, bodyLen)
+	if _, err := io.ReadFull(r, body); err != nil {
+		return nil, err
+	}
+
+	return &binaryResponse{
+		status: binary.BigEndian.Uint16(header[6:]),
+		extras: body[:extrasLen],
+		value:  body[extrasLen+keyLen:],
+	}, nil
+}
+
+// statusError returns the error of the status of a response, with the errors of gomemcache
+func statusError(res *binaryResponse) error {
+	switch res.status {
+	case statusOK:
+		return nil
+	case statusKeyNotFound:
+		return memcache.ErrCacheMiss
+	case statusAuthError:
+		return fmt.Errorf("memcached authentication error: %s", res.value)
+	}
+
+	return fmt.Errorf("%w: status %#x: %s", memcache.ErrServerError, res.status, res.value)
+}
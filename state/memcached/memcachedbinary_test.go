@@ -0,0 +1,155 @@
+package memcached
+
+import (
+	"encoding/binary"
+	"io"
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/bradfitz/gomemcache/memcache"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeServer is a memcached server of the binary protocol, which requires SASL PLAIN authentication
+type fakeServer struct {
+	listener net.Listener
+	lock     sync.Mutex
+	items    map[string][]byte
+	auths    int
+}
+
+func newFakeServer(t *testing.T) *fakeServer {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+
+	s := &fakeServer{listener: listener, items: map[string][]byte{}}
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go s.serve(conn)
+		}
+	}()
+
+	return s
+}
+
+func (s *fakeServer) serve(conn net.Conn) {
+	defer conn.Close()
+	authenticated := false
+	for {
+		header := make([]byte, headerSize)
+		if _, err := io.ReadFull(conn, header); err != nil {
+			return
+		}
+		keyLen := int(binary.BigEndian.Uint16(header[2:]))
+		extrasLen := int(header[4])
+		body := make([]byte, binary.BigEndian.Uint32(header[8:]))
+		if _, err := io.ReadFull(conn, body); err != nil {
+			return
+		}
+		key := string(body[extrasLen : extrasLen+keyLen])
+		value := body[extrasLen+keyLen:]
+
+		var (
+			status  uint16
+			extras  []byte
+			resBody []byte
+		)
+		s.lock.Lock()
+		switch {
+		case header[1] == opSASLAuth:
+			s.auths++
+			authenticated = key == saslMechanism && string(value) == "\x00user\x00secret"
+			if !authenticated {
+				status = statusAuthError
+			}
+		case !authenticated:
+			status = statusAuthError
+		case header[1] == opGet:
+			item, ok := s.items[key]
+			if !ok {
+				status = statusKeyNotFound
+			} else {
+				extras = make([]byte, 4)
+				resBody = item
+			}
+		case header[1] == opSet:
+			s.items[key] = value
+		case header[1] == opDelete:
+			if _, ok := s.items[key]; !ok {
+				status = statusKeyNotFound
+			}
+			delete(s.items, key)
+		}
+		s.lock.Unlock()
+
+		res := make([]byte, headerSize)
+		res[0] = magicResponse
+		res[1] = header[1]
+		res[4] = byte(len(extras))
+		binary.BigEndian.PutUint16(res[6:], status)
+		binary.BigEndian.PutUint32(res[8:], uint32(len(extras)+len(resBody)))
+		res = append(append(res, extras...), resBody...)
+		if _, err := conn.Write(res); err != nil {
+			return
+		}
+	}
+}
+
+func TestBinaryClient(t *testing.T) {
+	s := newFakeServer(t)
+	defer s.listener.Close()
+
+	meta := &memcachedMetadata{
+		hosts:              []string{s.listener.Addr().String()},
+		maxIdleConnections: 1,
+		timeout:            time.Second,
+		username:           "user",
+		password:           "secret",
+	}
+
+	t.Run("operations", func(t *testing.T) {
+		c := newBinaryClient(meta)
+		require.NoError(t, c.Ping())
+
+		require.NoError(t, c.Set(&memcache.Item{Key: "key1", Value: []byte(`"value1"`), Expiration: 60}))
+		item, err := c.Get("key1")
+		require.NoError(t, err)
+		assert.Equal(t, []byte(`"value1"`), item.Value)
+
+		require.NoError(t, c.Delete("key1"))
+		_, err = c.Get("key1")
+		assert.Equal(t, memcache.ErrCacheMiss, err)
+		assert.Equal(t, memcache.ErrCacheMiss, c.Delete("key1"))
+
+		assert.Equal(t, 1, s.auths, "the authenticated connection is reused")
+	})
+
+	t.Run("wrong password", func(t *testing.T) {
+		wrong := *meta
+		wrong.password = "wrong"
+		c := newBinaryClient(&wrong)
+
+		assert.Error(t, c.Ping())
+	})
+}
+
+func TestBinaryRequestBytes(t *testing.T) {
+	req := &binaryRequest{opcode: opSet, key: "k", extras: []byte{0, 0, 0, 0, 0, 0, 0, 60}, value: []byte("v")}
+
+	packet := req.bytes()
+
+	require.Len(t, packet, headerSize+10)
+	assert.Equal(t, byte(magicRequest), packet[0])
+	assert.Equal(t, byte(opSet), packet[1])
+	assert.Equal(t, uint16(1), binary.BigEndian.Uint16(packet[2:]))
+	assert.Equal(t, byte(8), packet[4])
+	assert.Equal(t, uint32(10), binary.BigEndian.Uint32(packet[8:]))
+	assert.Equal(t, []byte("kv"), packet[headerSize+8:])
+}
@@ -8,6 +8,7 @@ package mongodb
 // mongodb package is an implementation of StateStore interface to perform operations on store
 
 import (
+	"bytes"
 	"context"
 	"errors"
 	"fmt"
@@ -15,27 +16,34 @@ import (
 	"time"
 
 	"github.com/dapr/components-contrib/state"
+	"github.com/dapr/components-contrib/state/query"
 	"github.com/dapr/dapr/pkg/logger"
 	json "github.com/json-iterator/go"
 	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
 	"go.mongodb.org/mongo-driver/mongo"
 	"go.mongodb.org/mongo-driver/mongo/options"
 	"go.mongodb.org/mongo-driver/mongo/readconcern"
+	"go.mongodb.org/mongo-driver/mongo/readpref"
 	"go.mongodb.org/mongo-driver/mongo/writeconcern"
 )
 
 const (
-	host             = "host"
-	username         = "username"
-	password         = "password"
-	databaseName     = "databaseName"
-	collectionName   = "collectionName"
-	writeConcern     = "writeConcern"
-	readConcern      = "readConcern"
-	operationTimeout = "operationTimeout"
-	params           = "params"
-	id               = "_id"
-	value            = "value"
+	host              = "host"
+	username          = "username"
+	password          = "password"
+	databaseName      = "databaseName"
+	collectionName    = "collectionName"
+	writeConcern      = "writeConcern"
+	readConcern       = "readConcern"
+	readPreference    = "readPreference"
+	causalConsistency = "causalConsistency"
+	operationTimeout  = "operationTimeout"
+	params            = "params"
+	id                = "_id"
+	value             = "value"
+	// expiry holds the time the values set with a time to live expire at
+	expiry = "_expiry"
 
 	defaultTimeout        = 5 * time.Second
 	defaultDatabaseName   = "daprStore"
@@ -51,29 +59,34 @@ const (
 // MongoDB is a state store implementation for MongoDB
 type MongoDB struct {
 	state.DefaultBulkStore
-	client           *mongo.Client
-	collection       *mongo.Collection
-	operationTimeout time.Duration
+	client            *mongo.Client
+	collection        *mongo.Collection
+	operationTimeout  time.Duration
+	causalConsistency bool
+	causal            causalTimes
 
 	logger logger.Logger
 }
 
 type mongoDBMetadata struct {
-	host             string
-	username         string
-	password         string
-	databaseName     string
-	collectionName   string
-	writeconcern     string
-	readconcern      string
-	params           string
-	operationTimeout time.Duration
+	host              string
+	username          string
+	password          string
+	databaseName      string
+	collectionName    string
+	writeconcern      string
+	readconcern       string
+	readPreference    string
+	causalConsistency bool
+	params            string
+	operationTimeout  time.Duration
 }
 
-// Item is Mongodb document wrapper
+// Item is Mongodb document wrapper.
+// The JSON objects are stored as documents, so that they can be queried, the other values as strings.
 type Item struct {
-	Key   string `bson:"_id"`
-	Value string `bson:"value"`
+	Key   string      `bson:"_id"`
+	Value interface{} `bson:"value"`
 }
 
 // NewMongoDB returns a new MongoDB state store
@@ -92,6 +105,7 @@ func (m *MongoDB) Init(metadata state.Metadata) error {
 	}
 
 	m.operationTimeout = meta.operationTimeout
+	m.causalConsistency = meta.causalConsistency
 
 	client, err := getMongoDBClient(meta)
 	if err != nil {
@@ -116,11 +130,28 @@ func (m *MongoDB) Init(metadata state.Metadata) error {
 		return fmt.Errorf("error in getting read concern object: %s", err)
 	}
 
-	opts := options.Collection().SetWriteConcern(wc).SetReadConcern(rc)
+	// get the read preference
+	rp, err := getReadPreferenceObject(meta.readPreference)
+	if err != nil {
+		return fmt.Errorf("error in getting read preference object: %s", err)
+	}
+
+	opts := options.Collection().SetWriteConcern(wc).SetReadConcern(rc).SetReadPreference(rp)
 	collection := m.client.Database(meta.databaseName).Collection(meta.collectionName, opts)
 
 	m.collection = collection
 
+	// MongoDB removes the documents once their expiry time has passed
+	ctx, cancel := context.WithTimeout(context.Background(), m.operationTimeout)
+	defer cancel()
+	_, err = collection.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    bson.M{expiry: 1},
+		Options: options.Index().SetExpireAfterSeconds(0),
+	})
+	if err != nil {
+		return fmt.Errorf("error in creating the expiry index: %s", err)
+	}
+
 	return nil
 }
 
@@ -129,7 +160,9 @@ func (m *MongoDB) Set(req *state.SetRequest) error {
 	ctx, cancel := context.WithTimeout(context.Background(), m.operationTimeout)
 	defer cancel()
 
-	err := m.setInternal(ctx, req)
+	err := m.withSession(ctx, func(ctx context.Context) error {
+		return m.setInternal(ctx, req)
+	})
 	if err != nil {
 		return err
 	}
@@ -138,18 +171,27 @@ func (m *MongoDB) Set(req *state.SetRequest) error {
 }
 
 func (m *MongoDB) setInternal(ctx context.Context, req *state.SetRequest) error {
-	var vStr string
+	ttl, err := state.ParseTTL(req.Metadata)
+	if err != nil {
+		return fmt.Errorf("error in parsing ttl from metadata: %s", err)
+	}
+
 	b, ok := req.Value.([]byte)
-	if ok {
-		vStr = string(b)
-	} else {
-		vStr, _ = json.MarshalToString(req.Value)
+	if !ok {
+		b, _ = json.Marshal(req.Value)
 	}
 
 	// create a document based on request key and value
 	filter := bson.M{id: req.Key}
-	update := bson.M{"$set": bson.M{id: req.Key, value: vStr}}
-	_, err := m.collection.UpdateOne(ctx, filter, update, options.Update().SetUpsert(true))
+	set := bson.M{id: req.Key, value: documentValue(b)}
+	update := bson.M{"$set": set}
+	// the value replaces the existing one along with its expiry time, the values without ttl never expire
+	if ttl == nil || *ttl == state.NoExpiry {
+		update["$unset"] = bson.M{expiry: ""}
+	} else {
+		set[expiry] = time.Now().Add(time.Duration(*ttl) * time.Second)
+	}
+	_, err = m.collection.UpdateOne(ctx, filter, update, options.Update().SetUpsert(true))
 	if err != nil {
 		return err
 	}
@@ -164,25 +206,116 @@ func (m *MongoDB) Get(req *state.GetRequest) (*state.GetResponse, error) {
 	ctx, cancel := context.WithTimeout(context.Background(), m.operationTimeout)
 	defer cancel()
 
-	filter := bson.M{id: req.Key}
-	err := m.collection.FindOne(ctx, filter).Decode(&result)
+	filter := bson.M{id: req.Key, "$or": notExpired()}
+	err := m.withSession(ctx, func(ctx context.Context) error {
+		return m.collection.FindOne(ctx, filter).Decode(&result)
+	})
 	if err != nil {
 		return &state.GetResponse{}, err
 	}
 
-	value := []byte(result.Value)
+	value, err := itemData(result.Value)
+	if err != nil {
+		return &state.GetResponse{}, err
+	}
 
 	return &state.GetResponse{
 		Data: value,
 	}, nil
 }
 
+// notExpired returns the alternatives matching the documents which have not expired, MongoDB removes
+// the expired documents up to a minute after their expiry time
+func notExpired() bson.A {
+	return bson.A{
+		bson.M{expiry: bson.M{"$exists": false}},
+		bson.M{expiry: bson.M{"$gt": time.Now()}},
+	}
+}
+
+// Features returns the features of the MongoDB state store
+func (m *MongoDB) Features() []state.Feature {
+	return []state.Feature{state.FeatureTTL}
+}
+
+// documentValue returns the document of a JSON object, or else the value as a string
+func documentValue(b []byte) interface{} {
+	trimmed := bytes.TrimSpace(b)
+	if len(trimmed) > 0 && trimmed[0] == '{' {
+		var doc bson.D
+		if err := bson.UnmarshalExtJSON(trimmed, false, &doc); err == nil {
+			return doc
+		}
+	}
+
+	return string(b)
+}
+
+// itemData returns the data of a stored value, the documents are returned as JSON
+func itemData(v interface{}) ([]byte, error) {
+	switch val := v.(type) {
+	case string:
+		return []byte(val), nil
+	case primitive.D, primitive.M:
+		return bson.MarshalExtJSON(val, false, false)
+	default:
+		return nil, fmt.Errorf("unexpected value type %T", v)
+	}
+}
+
+// Query returns the values matching a query with an aggregation pipeline, sorted and paginated by offset.
+// The values stored as strings, which are not JSON objects, never match the filters of a query.
+func (m *MongoDB) Query(req *state.QueryRequest) (*state.QueryResponse, error) {
+	q := &mongoQuery{}
+	err := query.NewQueryBuilder(q).BuildQuery(&req.Query)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), m.operationTimeout)
+	defer cancel()
+
+	results := []state.QueryItem{}
+	err = m.withSession(ctx, func(ctx context.Context) error {
+		cursor, err := m.collection.Aggregate(ctx, q.pipeline())
+		if err != nil {
+			return err
+		}
+		defer cursor.Close(ctx)
+
+		for cursor.Next(ctx) {
+			var item Item
+			if err = cursor.Decode(&item); err != nil {
+				return err
+			}
+			result := state.QueryItem{Key: item.Key}
+			if result.Data, err = itemData(item.Value); err != nil {
+				result.Error = err.Error()
+			}
+			results = append(results, result)
+		}
+
+		return cursor.Err()
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &state.QueryResponse{
+		Results:  results,
+		Token:    query.NextOffsetToken(q.offset, len(results), q.limit),
+		Metadata: req.Metadata,
+	}, nil
+}
+
 // Delete performs a delete operation
 func (m *MongoDB) Delete(req *state.DeleteRequest) error {
 	ctx, cancel := context.WithTimeout(context.Background(), m.operationTimeout)
 	defer cancel()
 
-	err := m.deleteInternal(ctx, req)
+	err := m.withSession(ctx, func(ctx context.Context) error {
+		return m.deleteInternal(ctx, req)
+	})
 	if err != nil {
 		return err
 	}
@@ -202,15 +335,21 @@ func (m *MongoDB) deleteInternal(ctx context.Context, req *state.DeleteRequest)
 
 // Multi performs a transactional operation. succeeds only if all operations succeed, and fails if one or more operations fail
 func (m *MongoDB) Multi(request *state.TransactionalStateRequest) error {
-	sess, err := m.client.StartSession()
+	sess, err := m.client.StartSession(options.Session().SetCausalConsistency(m.causalConsistency))
 	txnOpts := options.Transaction().SetReadConcern(readconcern.Snapshot()).
 		SetWriteConcern(writeconcern.New(writeconcern.WMajority()))
 
-	defer sess.EndSession(context.Background())
-
 	if err != nil {
 		return fmt.Errorf("error in starting the transaction: %s", err)
 	}
+	defer sess.EndSession(context.Background())
+
+	if m.causalConsistency {
+		if err = m.causal.advance(sess); err != nil {
+			return err
+		}
+		defer m.causal.observe(sess)
+	}
 
 	sess.WithTransaction(context.Background(), func(sessCtx mongo.SessionContext) (interface{}, error) {
 		err = m.doTransaction(sessCtx, request.Operations)
@@ -305,11 +444,22 @@ func getMongoDBMetaData(metadata state.Metadata) (*mongoDBMetadata, error) {
 		meta.readconcern = val
 	}
 
+	if val, ok := metadata.Properties[readPreference]; ok && val != "" {
+		meta.readPreference = val
+	}
+
 	if val, ok := metadata.Properties[params]; ok && val != "" {
 		meta.params = val
 	}
 
 	var err error
+	if val, ok := metadata.Properties[causalConsistency]; ok && val != "" {
+		meta.causalConsistency, err = strconv.ParseBool(val)
+		if err != nil {
+			return nil, errors.New("incorrect causalConsistency field from metadata")
+		}
+	}
+
 	if val, ok := metadata.Properties[operationTimeout]; ok && val != "" {
 		meta.operationTimeout, err = time.ParseDuration(val)
 		if err != nil {
@@ -320,16 +470,17 @@ func getMongoDBMetaData(metadata state.Metadata) (*mongoDBMetadata, error) {
 	return &meta, nil
 }
 
+// getWriteConcernObject returns the write concern of a number of members, of the majority, or else of a custom
+// write concern of the tags of the replica set members
 func getWriteConcernObject(cn string) (*writeconcern.WriteConcern, error) {
 	var wc *writeconcern.WriteConcern
 	if cn != "" {
 		if cn == "majority" {
 			wc = writeconcern.New(writeconcern.WMajority(), writeconcern.J(true), writeconcern.WTimeout(defaultTimeout))
-		} else {
-			w, err := strconv.Atoi(cn)
+		} else if w, err := strconv.Atoi(cn); err == nil {
 			wc = writeconcern.New(writeconcern.W(w), writeconcern.J(true), writeconcern.WTimeout(defaultTimeout))
-
-			return wc, err
+		} else {
+			wc = writeconcern.New(writeconcern.WTagSet(cn), writeconcern.J(true), writeconcern.WTimeout(defaultTimeout))
 		}
 	} else {
 		wc = writeconcern.New(writeconcern.W(1), writeconcern.J(true), writeconcern.WTimeout(defaultTimeout))
@@ -356,3 +507,20 @@ func getReadConcernObject(cn string) (*readconcern.ReadConcern, error) {
 
 	return nil, fmt.Errorf("readConcern %s not found", cn)
 }
+
+func getReadPreferenceObject(rp string) (*readpref.ReadPref, error) {
+	switch rp {
+	case "primary", "":
+		return readpref.Primary(), nil
+	case "primaryPreferred":
+		return readpref.PrimaryPreferred(), nil
+	case "secondary":
+		return readpref.Secondary(), nil
+	case "secondaryPreferred":
+		return readpref.SecondaryPreferred(), nil
+	case "nearest":
+		return readpref.Nearest(), nil
+	}
+
+	return nil, fmt.Errorf("readPreference %s not found", rp)
+}
@@ -6,10 +6,16 @@
 package mongodb
 
 import (
+	"encoding/json"
 	"testing"
 
 	"github.com/dapr/components-contrib/state"
+	"github.com/dapr/components-contrib/state/query"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/readpref"
 )
 
 func TestGetMongoDBMetadata(t *testing.T) {
@@ -49,6 +55,39 @@ func TestGetMongoDBMetadata(t *testing.T) {
 		assert.Equal(t, properties[password], metadata.password)
 	})
 
+	t.Run("With replica set values", func(t *testing.T) {
+		properties := map[string]string{
+			host:              "127.0.0.2",
+			writeConcern:      "majority",
+			readConcern:       "majority",
+			readPreference:    "secondaryPreferred",
+			causalConsistency: "true",
+		}
+		m := state.Metadata{
+			Properties: properties,
+		}
+
+		metadata, err := getMongoDBMetaData(m)
+		assert.Nil(t, err)
+		assert.Equal(t, "majority", metadata.writeconcern)
+		assert.Equal(t, "majority", metadata.readconcern)
+		assert.Equal(t, "secondaryPreferred", metadata.readPreference)
+		assert.True(t, metadata.causalConsistency)
+	})
+
+	t.Run("Incorrect causal consistency", func(t *testing.T) {
+		properties := map[string]string{
+			host:              "127.0.0.2",
+			causalConsistency: "sometimes",
+		}
+		m := state.Metadata{
+			Properties: properties,
+		}
+
+		_, err := getMongoDBMetaData(m)
+		assert.NotNil(t, err)
+	})
+
 	t.Run("Missing hosts", func(t *testing.T) {
 		properties := map[string]string{
 			username: "username",
@@ -124,3 +163,162 @@ func TestGetMongoDBMetadata(t *testing.T) {
 		assert.Equal(t, expected, uri)
 	})
 }
+
+func TestMongoQuery(t *testing.T) {
+	build := func(t *testing.T, data string) *mongoQuery {
+		var qq query.Query
+		require.NoError(t, json.Unmarshal([]byte(data), &qq))
+
+		q := &mongoQuery{}
+		require.NoError(t, query.NewQueryBuilder(q).BuildQuery(&qq))
+
+		return q
+	}
+
+	t.Run("filter, sort and page", func(t *testing.T) {
+		q := build(t, `{
+			"filter": {"OR": [{"EQ": {"person.org": "Dev Ops"}}, {"AND": [{"EQ": {"age": 30}}, {"IN": {"state": ["CA", "WA"]}}]}]},
+			"sort": [{"key": "state", "order": "DESC"}, {"key": "person.id"}],
+			"page": {"limit": 10, "token": "20"}
+		}`)
+
+		filter, err := bson.MarshalExtJSON(q.filter, false, false)
+		require.NoError(t, err)
+		assert.JSONEq(t, `{"$or": [
+			{"value.person.org": "Dev Ops"},
+			{"$and": [{"value.age": 30}, {"value.state": {"$in": ["CA", "WA"]}}]}
+		]}`, string(filter))
+		assert.Equal(t, bson.D{{Key: "value.state", Value: -1}, {Key: "value.person.id", Value: 1}}, q.sort)
+
+		pipeline := q.pipeline()
+		require.Len(t, pipeline, 4)
+		assert.Equal(t, "$match", pipeline[0].(bson.D)[0].Key)
+		assert.Equal(t, bson.A{
+			bson.D{{Key: "$sort", Value: q.sort}},
+			bson.D{{Key: "$skip", Value: int64(20)}},
+			bson.D{{Key: "$limit", Value: int64(10)}},
+		}, pipeline[1:])
+	})
+
+	t.Run("no filter", func(t *testing.T) {
+		q := build(t, `{}`)
+
+		assert.Equal(t, bson.D{}, q.filter)
+		assert.Nil(t, q.sort)
+
+		pipeline := q.pipeline()
+		require.Len(t, pipeline, 1)
+		match := pipeline[0].(bson.D)[0]
+		assert.Equal(t, "$match", match.Key)
+		assert.Equal(t, bson.D{}, match.Value.(bson.D)[0].Value.(bson.A)[0])
+	})
+}
+
+func TestDocumentValue(t *testing.T) {
+	t.Run("objects are stored as documents", func(t *testing.T) {
+		v := documentValue([]byte(`{"person": {"org": "Dev Ops"}, "age": 30}`))
+
+		assert.IsType(t, bson.D{}, v)
+		data, err := itemData(v)
+		assert.NoError(t, err)
+		assert.JSONEq(t, `{"person": {"org": "Dev Ops"}, "age": 30}`, string(data))
+	})
+
+	t.Run("other values are stored as strings", func(t *testing.T) {
+		for _, data := range []string{`"text"`, `[1, 2]`, `30`, `not json`} {
+			v := documentValue([]byte(data))
+
+			assert.Equal(t, data, v)
+			b, err := itemData(v)
+			assert.NoError(t, err)
+			assert.Equal(t, data, string(b))
+		}
+	})
+}
+
+func TestGetReadPreferenceObject(t *testing.T) {
+	t.Run("primary by default", func(t *testing.T) {
+		rp, err := getReadPreferenceObject("")
+
+		assert.NoError(t, err)
+		assert.Equal(t, readpref.PrimaryMode, rp.Mode())
+	})
+
+	t.Run("secondary preferred", func(t *testing.T) {
+		rp, err := getReadPreferenceObject("secondaryPreferred")
+
+		assert.NoError(t, err)
+		assert.Equal(t, readpref.SecondaryPreferredMode, rp.Mode())
+	})
+
+	t.Run("unknown read preference", func(t *testing.T) {
+		_, err := getReadPreferenceObject("fastest")
+
+		assert.Error(t, err)
+	})
+}
+
+func TestGetWriteConcernObject(t *testing.T) {
+	for _, cn := range []string{"", "majority", "2", "multipleDataCenters"} {
+		wc, err := getWriteConcernObject(cn)
+
+		assert.NoError(t, err, cn)
+		assert.NotNil(t, wc, cn)
+	}
+}
+
+func TestTimestampAfter(t *testing.T) {
+	assert.True(t, timestampAfter(primitive.Timestamp{T: 2, I: 1}, primitive.Timestamp{T: 1, I: 5}))
+	assert.True(t, timestampAfter(primitive.Timestamp{T: 1, I: 2}, primitive.Timestamp{T: 1, I: 1}))
+	assert.False(t, timestampAfter(primitive.Timestamp{T: 1, I: 1}, primitive.Timestamp{T: 1, I: 1}))
+	assert.False(t, timestampAfter(primitive.Timestamp{T: 1, I: 5}, primitive.Timestamp{T: 2, I: 1}))
+}
+
+func TestClusterTimestamp(t *testing.T) {
+	clusterTime, err := bson.Marshal(bson.M{"$clusterTime": bson.M{"clusterTime": primitive.Timestamp{T: 10, I: 2}}})
+	require.NoError(t, err)
+
+	assert.Equal(t, primitive.Timestamp{T: 10, I: 2}, clusterTimestamp(clusterTime))
+}
+
+func TestEventChange(t *testing.T) {
+	decode := func(t *testing.T, doc bson.M) *changeEvent {
+		b, err := bson.Marshal(doc)
+		require.NoError(t, err)
+		var event changeEvent
+		require.NoError(t, bson.Unmarshal(b, &event))
+
+		return &event
+	}
+
+	t.Run("insert", func(t *testing.T) {
+		change := eventChange(decode(t, bson.M{
+			"operationType": "insert",
+			"documentKey":   bson.M{"_id": "order-1"},
+			"fullDocument":  bson.M{"_id": "order-1", "value": bson.D{{Key: "status", Value: "pending"}}},
+		}))
+
+		assert.Equal(t, "order-1", change.Key)
+		assert.Equal(t, state.Upsert, change.Operation)
+		assert.JSONEq(t, `{"status": "pending"}`, string(change.Data))
+	})
+
+	t.Run("update of a deleted document", func(t *testing.T) {
+		change := eventChange(decode(t, bson.M{
+			"operationType": "update",
+			"documentKey":   bson.M{"_id": "order-1"},
+			"fullDocument":  nil,
+		}))
+
+		assert.Equal(t, &state.StateChange{Key: "order-1", Operation: state.Upsert}, change)
+	})
+
+	t.Run("delete", func(t *testing.T) {
+		change := eventChange(decode(t, bson.M{
+			"operationType": "delete",
+			"documentKey":   bson.M{"_id": "order-1"},
+		}))
+
+		assert.Equal(t, &state.StateChange{Key: "order-1", Operation: state.Delete}, change)
+	})
+}
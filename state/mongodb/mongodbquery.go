@@ -0,0 +1,90 @@
+// ------------------------------------------------------------
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT License.
+// ------------------------------------------------------------
+
+package mongodb
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/dapr/components-contrib/state/query"
+	json "github.com/json-iterator/go"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// mongoQuery translates a query to the stages of a MongoDB aggregation pipeline, where the keys of the query
+// are the fields of the value documents. The filters are built as extended JSON.
+type mongoQuery struct {
+	filter bson.D
+	sort   bson.D
+	offset int
+	limit  int
+}
+
+func (q *mongoQuery) VisitEQ(f *query.EQ) (string, error) {
+	return fieldFilter(f.Key, f.Val)
+}
+
+func (q *mongoQuery) VisitIN(f *query.IN) (string, error) {
+	return fieldFilter(f.Key, map[string]interface{}{"$in": f.Vals})
+}
+
+func (q *mongoQuery) VisitAND(filters []string) (string, error) {
+	return fmt.Sprintf(`{"$and": [%s]}`, strings.Join(filters, ", ")), nil
+}
+
+func (q *mongoQuery) VisitOR(filters []string) (string, error) {
+	return fmt.Sprintf(`{"$or": [%s]}`, strings.Join(filters, ", ")), nil
+}
+
+func (q *mongoQuery) Finalize(filter string, qq *query.Query) error {
+	offset, err := query.ParseOffsetToken(qq.Page.Token)
+	if err != nil {
+		return err
+	}
+	q.offset = offset
+	q.limit = qq.Page.Limit
+
+	q.filter = bson.D{}
+	if filter != "" {
+		if err = bson.UnmarshalExtJSON([]byte(filter), false, &q.filter); err != nil {
+			return fmt.Errorf("error in building the mongodb filter: %s", err)
+		}
+	}
+
+	for _, s := range qq.Sort {
+		order := 1
+		if s.Order == query.DESC {
+			order = -1
+		}
+		q.sort = append(q.sort, bson.E{Key: value + "." + s.Key, Value: order})
+	}
+
+	return nil
+}
+
+// pipeline returns the aggregation pipeline matching the documents of the filter which have not expired,
+// then sorting and paginating them
+func (q *mongoQuery) pipeline() bson.A {
+	pipeline := bson.A{
+		bson.D{{Key: "$match", Value: bson.D{{Key: "$and", Value: bson.A{q.filter, bson.M{"$or": notExpired()}}}}}},
+	}
+	if len(q.sort) > 0 {
+		pipeline = append(pipeline, bson.D{{Key: "$sort", Value: q.sort}})
+	}
+	if q.offset > 0 {
+		pipeline = append(pipeline, bson.D{{Key: "$skip", Value: int64(q.offset)}})
+	}
+	if q.limit > 0 {
+		pipeline = append(pipeline, bson.D{{Key: "$limit", Value: int64(q.limit)}})
+	}
+
+	return pipeline
+}
+
+// fieldFilter returns the filter of a field of the value documents
+func fieldFilter(key string, val interface{}) (string, error) {
+	return json.MarshalToString(map[string]interface{}{value + "." + key: val})
+}
@@ -0,0 +1,96 @@
+// ------------------------------------------------------------
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT License.
+// ------------------------------------------------------------
+
+package mongodb
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// causalTimes holds the latest cluster and operation times seen by the sessions of the store. A session is not safe
+// for concurrent use, so each operation has its own causally consistent session, which is advanced to these times
+// to observe the operations which completed before it started.
+type causalTimes struct {
+	lock          sync.Mutex
+	clusterTime   bson.Raw
+	operationTime *primitive.Timestamp
+}
+
+// advance advances the cluster and operation times of a session to the latest times
+func (c *causalTimes) advance(sess mongo.Session) error {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	if c.clusterTime != nil {
+		if err := sess.AdvanceClusterTime(c.clusterTime); err != nil {
+			return err
+		}
+	}
+	if c.operationTime != nil {
+		if err := sess.AdvanceOperationTime(c.operationTime); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// observe keeps the cluster and operation times of a session when they are the latest times
+func (c *causalTimes) observe(sess mongo.Session) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	if clusterTime := sess.ClusterTime(); clusterTime != nil &&
+		(c.clusterTime == nil || timestampAfter(clusterTimestamp(clusterTime), clusterTimestamp(c.clusterTime))) {
+		c.clusterTime = clusterTime
+	}
+	if operationTime := sess.OperationTime(); operationTime != nil &&
+		(c.operationTime == nil || timestampAfter(*operationTime, *c.operationTime)) {
+		c.operationTime = operationTime
+	}
+}
+
+// clusterTimestamp returns the timestamp of a cluster time document
+func clusterTimestamp(clusterTime bson.Raw) primitive.Timestamp {
+	t, i, _ := clusterTime.Lookup("$clusterTime", "clusterTime").TimestampOK()
+
+	return primitive.Timestamp{T: t, I: i}
+}
+
+func timestampAfter(a, b primitive.Timestamp) bool {
+	return a.T > b.T || (a.T == b.T && a.I > b.I)
+}
+
+// withSession runs an operation in a causally consistent session with the causalConsistency metadata, or else
+// without session
+func (m *MongoDB) withSession(ctx context.Context, fn func(ctx context.Context) error) error {
+	if !m.causalConsistency {
+		return fn(ctx)
+	}
+
+	sess, err := m.client.StartSession(options.Session().SetCausalConsistency(true))
+	if err != nil {
+		return fmt.Errorf("error in starting the session: %s", err)
+	}
+	defer sess.EndSession(ctx)
+
+	if err = m.causal.advance(sess); err != nil {
+		return err
+	}
+
+	err = mongo.WithSession(ctx, sess, func(sessCtx mongo.SessionContext) error {
+		return fn(sessCtx)
+	})
+	m.causal.observe(sess)
+
+	return err
+}
@@ -0,0 +1,77 @@
+// ------------------------------------------------------------
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT License.
+// ------------------------------------------------------------
+
+package mongodb
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/dapr/components-contrib/state"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// changeEvent is an event of a change stream, with the full document of the inserts, updates and replacements
+type changeEvent struct {
+	OperationType string `bson:"operationType"`
+	DocumentKey   struct {
+		Key string `bson:"_id"`
+	} `bson:"documentKey"`
+	FullDocument *Item `bson:"fullDocument"`
+}
+
+// watchPipeline matches the events of the changes of the values
+var watchPipeline = bson.A{
+	bson.D{{Key: "$match", Value: bson.M{"operationType": bson.M{"$in": bson.A{"insert", "update", "replace", "delete"}}}}},
+}
+
+// Watch notifies the changes of the keys with a change stream, which requires a replica set or a sharded cluster.
+// The documents removed by the expiry index are notified as deletes.
+func (m *MongoDB) Watch(req *state.WatchRequest, handler func(change *state.StateChange)) (func(), error) {
+	ctx, cancel := context.WithCancel(context.Background())
+	stream, err := m.collection.Watch(ctx, watchPipeline, options.ChangeStream().SetFullDocument(options.UpdateLookup))
+	if err != nil {
+		cancel()
+
+		return nil, fmt.Errorf("error in opening the change stream: %s", err)
+	}
+
+	go func() {
+		defer stream.Close(context.Background())
+		for stream.Next(ctx) {
+			var event changeEvent
+			if err := stream.Decode(&event); err != nil {
+				m.logger.Warnf("ignoring the change event which can't be decoded: %s", err)
+
+				continue
+			}
+			if !req.Watches(event.DocumentKey.Key) {
+				continue
+			}
+			handler(eventChange(&event))
+		}
+		if err := stream.Err(); err != nil && ctx.Err() == nil {
+			m.logger.Errorf("stopped watching the change stream: %s", err)
+		}
+	}()
+
+	return cancel, nil
+}
+
+// eventChange returns the change of a change event. An update whose document was deleted before it was looked up
+// has no data.
+func eventChange(event *changeEvent) *state.StateChange {
+	if event.OperationType == "delete" {
+		return &state.StateChange{Key: event.DocumentKey.Key, Operation: state.Delete}
+	}
+
+	change := &state.StateChange{Key: event.DocumentKey.Key, Operation: state.Upsert}
+	if event.FullDocument != nil {
+		change.Data, _ = itemData(event.FullDocument.Value)
+	}
+
+	return change
+}
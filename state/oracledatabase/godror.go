@@ -0,0 +1,12 @@
+// ------------------------------------------------------------
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT License.
+// ------------------------------------------------------------
+
+// +build oracle
+
+package oracledatabase
+
+// The godror driver requires cgo to build and the Oracle Instant Client libraries at runtime,
+// so it is only built with the oracle build tag: go build -tags oracle
+import _ "github.com/godror/godror"
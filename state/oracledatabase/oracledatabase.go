@@ -0,0 +1,116 @@
+// ------------------------------------------------------------
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT License.
+// ------------------------------------------------------------
+
+package oracledatabase
+
+import (
+	"fmt"
+
+	"github.com/dapr/components-contrib/state"
+	"github.com/dapr/dapr/pkg/logger"
+)
+
+// OracleDatabase state store
+type OracleDatabase struct {
+	logger   logger.Logger
+	dbaccess dbAccess
+}
+
+// NewOracleDatabaseStateStore creates a new instance of Oracle Database state store
+func NewOracleDatabaseStateStore(logger logger.Logger) *OracleDatabase {
+	dba := newOracleDatabaseAccess(logger)
+
+	return newOracleDatabaseStateStore(logger, dba)
+}
+
+// newOracleDatabaseStateStore creates an Oracle Database state store on a dbAccess.
+// This unexported constructor allows injecting a dbAccess instance for unit testing.
+func newOracleDatabaseStateStore(logger logger.Logger, dba dbAccess) *OracleDatabase {
+	return &OracleDatabase{
+		logger:   logger,
+		dbaccess: dba,
+	}
+}
+
+// Init initializes the Oracle Database state store
+func (o *OracleDatabase) Init(metadata state.Metadata) error {
+	return o.dbaccess.Init(metadata)
+}
+
+// Delete removes an entity from the store
+func (o *OracleDatabase) Delete(req *state.DeleteRequest) error {
+	return o.dbaccess.Delete(req)
+}
+
+// BulkDelete removes multiple entries from the store in a transaction
+func (o *OracleDatabase) BulkDelete(req []state.DeleteRequest) error {
+	return o.dbaccess.ExecuteMulti(nil, req)
+}
+
+// Get returns an entity from store
+func (o *OracleDatabase) Get(req *state.GetRequest) (*state.GetResponse, error) {
+	return o.dbaccess.Get(req)
+}
+
+// BulkGet returns multiple entities from the store
+func (o *OracleDatabase) BulkGet(req []state.GetRequest) (bool, []state.BulkGetResponse, error) {
+	responses, err := o.dbaccess.BulkGet(req)
+	if err != nil {
+		return true, nil, err
+	}
+
+	return true, responses, nil
+}
+
+// Set adds/updates an entity on store
+func (o *OracleDatabase) Set(req *state.SetRequest) error {
+	return o.dbaccess.Set(req)
+}
+
+// BulkSet adds/updates multiple entities on store in a transaction
+func (o *OracleDatabase) BulkSet(req []state.SetRequest) error {
+	return o.dbaccess.ExecuteMulti(req, nil)
+}
+
+// Multi handles multiple transactions. Implements TransactionalStore.
+func (o *OracleDatabase) Multi(request *state.TransactionalStateRequest) error {
+	var deletes []state.DeleteRequest
+	var sets []state.SetRequest
+	for _, req := range request.Operations {
+		switch req.Operation {
+		case state.Upsert:
+			if setReq, ok := req.Request.(state.SetRequest); ok {
+				sets = append(sets, setReq)
+			} else {
+				return fmt.Errorf("expecting set request")
+			}
+
+		case state.Delete:
+			if delReq, ok := req.Request.(state.DeleteRequest); ok {
+				deletes = append(deletes, delReq)
+			} else {
+				return fmt.Errorf("expecting delete request")
+			}
+
+		default:
+			return fmt.Errorf("unsupported operation: %s", req.Operation)
+		}
+	}
+
+	if len(sets) > 0 || len(deletes) > 0 {
+		return o.dbaccess.ExecuteMulti(sets, deletes)
+	}
+
+	return nil
+}
+
+// Close implements io.Closer
+func (o *OracleDatabase) Close() error {
+	if o.dbaccess != nil {
+		return o.dbaccess.Close()
+	}
+
+	return nil
+}
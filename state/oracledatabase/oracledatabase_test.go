@@ -0,0 +1,186 @@
+// ------------------------------------------------------------
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT License.
+// ------------------------------------------------------------
+package oracledatabase
+
+import (
+	"testing"
+
+	"github.com/dapr/components-contrib/state"
+	"github.com/dapr/dapr/pkg/logger"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	fakeConnectionString = "not a real connection"
+)
+
+// Fake implementation of interface oracledatabase.dbaccess
+type fakeDBaccess struct {
+	logger  logger.Logger
+	sets    []state.SetRequest
+	deletes []state.DeleteRequest
+}
+
+func (m *fakeDBaccess) Init(metadata state.Metadata) error {
+	return nil
+}
+
+func (m *fakeDBaccess) Set(req *state.SetRequest) error {
+	return nil
+}
+
+func (m *fakeDBaccess) Get(req *state.GetRequest) (*state.GetResponse, error) {
+	return nil, nil
+}
+
+func (m *fakeDBaccess) Delete(req *state.DeleteRequest) error {
+	return nil
+}
+
+func (m *fakeDBaccess) BulkGet(req []state.GetRequest) ([]state.BulkGetResponse, error) {
+	return nil, nil
+}
+
+func (m *fakeDBaccess) ExecuteMulti(sets []state.SetRequest, deletes []state.DeleteRequest) error {
+	m.sets = sets
+	m.deletes = deletes
+
+	return nil
+}
+
+func (m *fakeDBaccess) Close() error {
+	return nil
+}
+
+func TestMulti(t *testing.T) {
+	t.Parallel()
+
+	t.Run("no requests", func(t *testing.T) {
+		odb, fake := createOracleDatabaseWithFake(t)
+		err := odb.Multi(&state.TransactionalStateRequest{})
+		assert.Nil(t, err)
+		assert.Nil(t, fake.sets)
+	})
+
+	t.Run("sets and deletes are executed together", func(t *testing.T) {
+		odb, fake := createOracleDatabaseWithFake(t)
+		err := odb.Multi(&state.TransactionalStateRequest{
+			Operations: []state.TransactionalStateOperation{
+				{Operation: state.Upsert, Request: state.SetRequest{Key: "key1", Value: "value1"}},
+				{Operation: state.Delete, Request: state.DeleteRequest{Key: "key2"}},
+			},
+		})
+		assert.Nil(t, err)
+		assert.Len(t, fake.sets, 1)
+		assert.Len(t, fake.deletes, 1)
+	})
+
+	invalid := map[string]state.TransactionalStateOperation{
+		"invalid action":         {Operation: "Something invalid", Request: state.SetRequest{Key: "key"}},
+		"invalid set request":    {Operation: state.Upsert, Request: state.DeleteRequest{Key: "key"}},
+		"invalid delete request": {Operation: state.Delete, Request: state.SetRequest{Key: "key"}},
+	}
+	for name, operation := range invalid {
+		operation := operation
+		t.Run(name, func(t *testing.T) {
+			odb := createOracleDatabase(t)
+			err := odb.Multi(&state.TransactionalStateRequest{
+				Operations: []state.TransactionalStateOperation{operation},
+			})
+			assert.NotNil(t, err)
+		})
+	}
+}
+
+func TestGetOracleDatabaseMetadata(t *testing.T) {
+	t.Parallel()
+
+	t.Run("defaults", func(t *testing.T) {
+		meta, err := getOracleDatabaseMetadata(state.Metadata{
+			Properties: map[string]string{connectionStringKey: fakeConnectionString},
+		})
+		require.NoError(t, err)
+		assert.Equal(t, fakeConnectionString, meta.connectionString)
+		assert.Equal(t, defaultTableName, meta.tableName)
+	})
+
+	t.Run("table name", func(t *testing.T) {
+		meta, err := getOracleDatabaseMetadata(state.Metadata{
+			Properties: map[string]string{connectionStringKey: fakeConnectionString, tableNameKey: "dapr_state"},
+		})
+		require.NoError(t, err)
+		assert.Equal(t, "dapr_state", meta.tableName)
+	})
+
+	invalid := map[string]map[string]string{
+		"missing connection string": {},
+		"invalid table name":        {connectionStringKey: fakeConnectionString, tableNameKey: "state; DROP TABLE state"},
+	}
+	for name, properties := range invalid {
+		properties := properties
+		t.Run(name, func(t *testing.T) {
+			_, err := getOracleDatabaseMetadata(state.Metadata{Properties: properties})
+			assert.Error(t, err)
+		})
+	}
+}
+
+func TestEncodeValue(t *testing.T) {
+	t.Parallel()
+
+	t.Run("JSON values", func(t *testing.T) {
+		value, binaryYN := encodeValue(struct {
+			Name string `json:"name"`
+		}{Name: "deathstar"})
+		assert.Equal(t, `{"name":"deathstar"}`, string(value))
+		assert.Equal(t, "N", binaryYN)
+	})
+
+	t.Run("JSON bytes", func(t *testing.T) {
+		value, binaryYN := encodeValue([]byte(`{"name":"deathstar"}`))
+		assert.Equal(t, `{"name":"deathstar"}`, string(value))
+		assert.Equal(t, "N", binaryYN)
+	})
+
+	t.Run("binary bytes", func(t *testing.T) {
+		value, binaryYN := encodeValue([]byte{0xff, 0x00})
+		assert.Equal(t, []byte{0xff, 0x00}, value)
+		assert.Equal(t, "Y", binaryYN)
+	})
+}
+
+func TestBulkGetQuery(t *testing.T) {
+	t.Parallel()
+	assert.Equal(t, "SELECT key, value, etag FROM state WHERE key IN (:1, :2, :3)", bulkGetQuery("state", 3))
+}
+
+func createOracleDatabaseWithFake(t *testing.T) (*OracleDatabase, *fakeDBaccess) {
+	odb := createOracleDatabase(t)
+	fake := odb.dbaccess.(*fakeDBaccess)
+
+	return odb, fake
+}
+
+func createOracleDatabase(t *testing.T) *OracleDatabase {
+	logger := logger.NewLogger("test")
+
+	dba := &fakeDBaccess{
+		logger: logger,
+	}
+
+	odb := newOracleDatabaseStateStore(logger, dba)
+	assert.NotNil(t, odb)
+
+	metadata := &state.Metadata{
+		Properties: map[string]string{connectionStringKey: fakeConnectionString},
+	}
+
+	err := odb.Init(*metadata)
+	assert.Nil(t, err)
+	assert.NotNil(t, odb.dbaccess)
+
+	return odb
+}
@@ -0,0 +1,406 @@
+// ------------------------------------------------------------
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT License.
+// ------------------------------------------------------------
+
+package oracledatabase
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/dapr/components-contrib/state"
+	"github.com/dapr/components-contrib/state/utils"
+	"github.com/dapr/dapr/pkg/logger"
+	"github.com/google/uuid"
+)
+
+const (
+	connectionStringKey        = "connectionString"
+	tableNameKey               = "tableName"
+	errMissingConnectionString = "missing connection string"
+	defaultTableName           = "state"
+
+	// driverName is the database/sql driver of Oracle Database, which is only built with the oracle build tag
+	driverName = "godror"
+
+	// maxBulkGetSize is the maximum number of expressions of an Oracle IN list
+	maxBulkGetSize = 1000
+)
+
+var tableNameRegexp = regexp.MustCompile(`^[A-Za-z][A-Za-z0-9_$#]{0,127}$`)
+
+// execer executes the statements of the operations, either on the database or in a transaction
+type execer interface {
+	Exec(query string, args ...interface{}) (sql.Result, error)
+}
+
+type oracleDatabaseMetadata struct {
+	connectionString string
+	tableName        string
+}
+
+// oracleDatabaseAccess implements dbaccess
+type oracleDatabaseAccess struct {
+	logger   logger.Logger
+	metadata oracleDatabaseMetadata
+	db       *sql.DB
+}
+
+// newOracleDatabaseAccess creates a new instance of oracleDatabaseAccess
+func newOracleDatabaseAccess(logger logger.Logger) *oracleDatabaseAccess {
+	logger.Debug("Instantiating new Oracle Database state store")
+
+	return &oracleDatabaseAccess{
+		logger: logger,
+	}
+}
+
+func getOracleDatabaseMetadata(metadata state.Metadata) (oracleDatabaseMetadata, error) {
+	meta := oracleDatabaseMetadata{
+		tableName: defaultTableName,
+	}
+
+	if val, ok := metadata.Properties[connectionStringKey]; ok && val != "" {
+		meta.connectionString = val
+	} else {
+		return meta, errors.New(errMissingConnectionString)
+	}
+
+	if val, ok := metadata.Properties[tableNameKey]; ok && val != "" {
+		if !tableNameRegexp.MatchString(val) {
+			return meta, fmt.Errorf("invalid table name %s", val)
+		}
+		meta.tableName = val
+	}
+
+	return meta, nil
+}
+
+// Init sets up Oracle Database connection and ensures that the state table exists
+func (o *oracleDatabaseAccess) Init(metadata state.Metadata) error {
+	o.logger.Debug("Initializing Oracle Database state store")
+	meta, err := getOracleDatabaseMetadata(metadata)
+	if err != nil {
+		o.logger.Error(err)
+
+		return err
+	}
+	o.metadata = meta
+
+	if !driverRegistered() {
+		return errors.New("the Oracle Database state store requires the godror driver, which is built with the oracle build tag")
+	}
+
+	db, err := sql.Open(driverName, meta.connectionString)
+	if err != nil {
+		o.logger.Error(err)
+
+		return err
+	}
+
+	o.db = db
+
+	if err = db.Ping(); err != nil {
+		return err
+	}
+
+	return o.ensureStateTable()
+}
+
+// driverRegistered tells whether the Oracle Database driver is built in
+func driverRegistered() bool {
+	for _, driver := range sql.Drivers() {
+		if driver == driverName {
+			return true
+		}
+	}
+
+	return false
+}
+
+// ensureStateTable creates the state table unless it exists. The values are stored in a BLOB, which is
+// constrained to be JSON unless the value is binary.
+func (o *oracleDatabaseAccess) ensureStateTable() error {
+	var count int
+	err := o.db.QueryRow("SELECT COUNT(1) FROM user_tables WHERE table_name = :1",
+		strings.ToUpper(o.metadata.tableName)).Scan(&count)
+	if err != nil {
+		return err
+	}
+	if count > 0 {
+		return nil
+	}
+
+	o.logger.Info("Creating Oracle Database state table")
+	// Sprintf is required for table name because sql.DB does not substitute parameters for table names.
+	_, err = o.db.Exec(fmt.Sprintf(`CREATE TABLE %[1]s (
+						key VARCHAR2(2000) NOT NULL PRIMARY KEY,
+						value BLOB NOT NULL,
+						binary_yn VARCHAR2(1) NOT NULL,
+						etag VARCHAR2(50) NOT NULL,
+						creation_time TIMESTAMP WITH TIME ZONE DEFAULT SYSTIMESTAMP NOT NULL,
+						update_time TIMESTAMP WITH TIME ZONE NULL,
+						CONSTRAINT %[1]s_value_json CHECK (binary_yn = 'Y' OR value IS JSON))`, o.metadata.tableName))
+
+	return err
+}
+
+// Set makes an insert or update to the database.
+func (o *oracleDatabaseAccess) Set(req *state.SetRequest) error {
+	return o.execSet(o.db, req)
+}
+
+// execSet makes an insert or update with the execer, which is the database or a transaction.
+// Every write gives the row a new etag.
+func (o *oracleDatabaseAccess) execSet(db execer, req *state.SetRequest) error {
+	o.logger.Debug("Setting state value in Oracle Database")
+
+	err := state.CheckRequestOptions(req.Options)
+	if err != nil {
+		return err
+	}
+
+	if req.Key == "" {
+		return fmt.Errorf("missing key in set operation")
+	}
+
+	err = state.RejectTTL(req.Metadata)
+	if err != nil {
+		return err
+	}
+
+	value, binaryYN := encodeValue(req.Value)
+	etag := uuid.New().String()
+
+	var result sql.Result
+	if req.ETag == "" {
+		result, err = db.Exec(fmt.Sprintf(
+			`MERGE INTO %s t
+			USING (SELECT :key AS key FROM dual) new_state ON (t.key = new_state.key)
+			WHEN MATCHED THEN UPDATE SET value = :value, binary_yn = :binary_yn, etag = :new_etag, update_time = SYSTIMESTAMP
+			WHEN NOT MATCHED THEN INSERT (key, value, binary_yn, etag) VALUES (new_state.key, :value, :binary_yn, :new_etag)`,
+			o.metadata.tableName),
+			sql.Named("key", req.Key), sql.Named("value", value), sql.Named("binary_yn", binaryYN), sql.Named("new_etag", etag))
+	} else {
+		// When an etag is provided do an update - no insert
+		result, err = db.Exec(fmt.Sprintf(
+			`UPDATE %s SET value = :value, binary_yn = :binary_yn, etag = :new_etag, update_time = SYSTIMESTAMP
+			WHERE key = :key AND etag = :etag`,
+			o.metadata.tableName),
+			sql.Named("value", value), sql.Named("binary_yn", binaryYN), sql.Named("new_etag", etag),
+			sql.Named("key", req.Key), sql.Named("etag", req.ETag))
+	}
+
+	return o.returnSingleDBResult(result, err)
+}
+
+// encodeValue returns the bytes stored for a value, and whether they are binary rather than JSON
+func encodeValue(value interface{}) ([]byte, string) {
+	bt, _ := utils.Marshal(value, json.Marshal)
+	if json.Valid(bt) {
+		return bt, "N"
+	}
+
+	return bt, "Y"
+}
+
+// Get returns data from the database. If data does not exist for the key an empty state.GetResponse will be returned.
+func (o *oracleDatabaseAccess) Get(req *state.GetRequest) (*state.GetResponse, error) {
+	o.logger.Debug("Getting state value from Oracle Database")
+	if req.Key == "" {
+		return nil, fmt.Errorf("missing key in get operation")
+	}
+
+	var value []byte
+	var etag string
+	err := o.db.QueryRow(fmt.Sprintf("SELECT value, etag FROM %s WHERE key = :key", o.metadata.tableName),
+		sql.Named("key", req.Key)).Scan(&value, &etag)
+	if err != nil {
+		// If no rows exist, return an empty response, otherwise return the error.
+		if err == sql.ErrNoRows {
+			return &state.GetResponse{}, nil
+		}
+
+		return nil, err
+	}
+
+	return &state.GetResponse{
+		Data:     value,
+		ETag:     etag,
+		Metadata: req.Metadata,
+	}, nil
+}
+
+// BulkGet returns the data of the keys, with one query per thousand keys. The keys without data have an empty response.
+func (o *oracleDatabaseAccess) BulkGet(req []state.GetRequest) ([]state.BulkGetResponse, error) {
+	o.logger.Debug("Getting state values from Oracle Database")
+	found := make(map[string]state.BulkGetResponse, len(req))
+	for start := 0; start < len(req); start += maxBulkGetSize {
+		end := start + maxBulkGetSize
+		if end > len(req) {
+			end = len(req)
+		}
+
+		keys := make([]interface{}, end-start)
+		for i := range keys {
+			if req[start+i].Key == "" {
+				return nil, fmt.Errorf("missing key in bulk get operation")
+			}
+			keys[i] = req[start+i].Key
+		}
+
+		if err := o.bulkGet(keys, found); err != nil {
+			return nil, err
+		}
+	}
+
+	responses := make([]state.BulkGetResponse, len(req))
+	for i := range req {
+		response, ok := found[req[i].Key]
+		if !ok {
+			response = state.BulkGetResponse{Key: req[i].Key}
+		}
+		response.Metadata = req[i].Metadata
+		responses[i] = response
+	}
+
+	return responses, nil
+}
+
+// bulkGet adds the responses of the keys found with one query
+func (o *oracleDatabaseAccess) bulkGet(keys []interface{}, found map[string]state.BulkGetResponse) error {
+	rows, err := o.db.Query(bulkGetQuery(o.metadata.tableName, len(keys)), keys...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var key, etag string
+		var value []byte
+		if err = rows.Scan(&key, &value, &etag); err != nil {
+			return err
+		}
+		found[key] = state.BulkGetResponse{
+			Key:  key,
+			Data: value,
+			ETag: etag,
+		}
+	}
+
+	return rows.Err()
+}
+
+// bulkGetQuery returns the query of the values of a number of keys, bound to positional parameters
+func bulkGetQuery(tableName string, count int) string {
+	params := make([]string, count)
+	for i := range params {
+		params[i] = fmt.Sprintf(":%d", i+1)
+	}
+
+	return fmt.Sprintf("SELECT key, value, etag FROM %s WHERE key IN (%s)", tableName, strings.Join(params, ", "))
+}
+
+// Delete removes an item from the state store.
+func (o *oracleDatabaseAccess) Delete(req *state.DeleteRequest) error {
+	return o.execDelete(o.db, req)
+}
+
+// execDelete removes an item with the execer, which is the database or a transaction.
+// Deleting a missing item succeeds, unless an etag is provided.
+func (o *oracleDatabaseAccess) execDelete(db execer, req *state.DeleteRequest) error {
+	o.logger.Debug("Deleting state value from Oracle Database")
+
+	err := state.CheckRequestOptions(req.Options)
+	if err != nil {
+		return err
+	}
+
+	if req.Key == "" {
+		return fmt.Errorf("missing key in delete operation")
+	}
+
+	if req.ETag == "" {
+		_, err = db.Exec(fmt.Sprintf("DELETE FROM %s WHERE key = :key", o.metadata.tableName), sql.Named("key", req.Key))
+
+		return err
+	}
+
+	result, err := db.Exec(fmt.Sprintf("DELETE FROM %s WHERE key = :key AND etag = :etag", o.metadata.tableName),
+		sql.Named("key", req.Key), sql.Named("etag", req.ETag))
+
+	return o.returnSingleDBResult(result, err)
+}
+
+// ExecuteMulti executes the deletes then the sets in a transaction, which is rolled back when one of them fails.
+func (o *oracleDatabaseAccess) ExecuteMulti(sets []state.SetRequest, deletes []state.DeleteRequest) error {
+	o.logger.Debug("Executing multiple Oracle Database operations")
+	tx, err := o.db.Begin()
+	if err != nil {
+		return err
+	}
+
+	for i := range deletes {
+		if err = o.execDelete(tx, &deletes[i]); err != nil {
+			tx.Rollback()
+
+			return err
+		}
+	}
+
+	for i := range sets {
+		if err = o.execSet(tx, &sets[i]); err != nil {
+			tx.Rollback()
+
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+// Verifies that the sql.Result affected one row and no errors exist
+func (o *oracleDatabaseAccess) returnSingleDBResult(result sql.Result, err error) error {
+	if err != nil {
+		o.logger.Debug(err)
+
+		return err
+	}
+
+	rowsAffected, resultErr := result.RowsAffected()
+	if resultErr != nil {
+		o.logger.Error(resultErr)
+
+		return resultErr
+	}
+
+	if rowsAffected == 0 {
+		noRowsErr := errors.New("database operation failed: no rows match given key and etag")
+		o.logger.Debug(noRowsErr)
+
+		return noRowsErr
+	}
+
+	if rowsAffected > 1 {
+		tooManyRowsErr := errors.New("database operation failed: more than one row affected, expected one")
+		o.logger.Debug(tooManyRowsErr)
+
+		return tooManyRowsErr
+	}
+
+	return nil
+}
+
+// Close implements io.Close
+func (o *oracleDatabaseAccess) Close() error {
+	if o.db != nil {
+		return o.db.Close()
+	}
+
+	return nil
+}
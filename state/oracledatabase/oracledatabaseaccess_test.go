@@ -0,0 +1,241 @@
+// ------------------------------------------------------------
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT License.
+// ------------------------------------------------------------
+
+package oracledatabase
+
+import (
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/dapr/components-contrib/state"
+	"github.com/dapr/dapr/pkg/logger"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	mergeQuery = "MERGE INTO state t USING (SELECT :key AS key FROM dual) new_state ON (t.key = new_state.key) " +
+		"WHEN MATCHED THEN UPDATE SET value = :value, binary_yn = :binary_yn, etag = :new_etag, update_time = SYSTIMESTAMP " +
+		"WHEN NOT MATCHED THEN INSERT (key, value, binary_yn, etag) VALUES (new_state.key, :value, :binary_yn, :new_etag)"
+	updateQuery = "UPDATE state SET value = :value, binary_yn = :binary_yn, etag = :new_etag, update_time = SYSTIMESTAMP " +
+		"WHERE key = :key AND etag = :etag"
+	getQuery        = "SELECT value, etag FROM state WHERE key = :key"
+	deleteQuery     = "DELETE FROM state WHERE key = :key"
+	etagDeleteQuery = "DELETE FROM state WHERE key = :key AND etag = :etag"
+)
+
+// newETag matches the etag generated by a write
+type newETag struct{}
+
+func (newETag) Match(v driver.Value) bool {
+	etag, ok := v.(string)
+	if !ok {
+		return false
+	}
+	_, err := uuid.Parse(etag)
+
+	return err == nil
+}
+
+func newMockDBAccess(t *testing.T) (*oracleDatabaseAccess, sqlmock.Sqlmock) {
+	db, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+	require.NoError(t, err)
+
+	o := newOracleDatabaseAccess(logger.NewLogger("test"))
+	o.metadata = oracleDatabaseMetadata{tableName: defaultTableName}
+	o.db = db
+
+	return o, mock
+}
+
+func TestSet(t *testing.T) {
+	t.Parallel()
+
+	t.Run("merge without etag", func(t *testing.T) {
+		o, mock := newMockDBAccess(t)
+		mock.ExpectExec(mergeQuery).
+			WithArgs(sql.Named("key", "key"), sql.Named("value", []byte(`"value"`)), sql.Named("binary_yn", "N"), newETag{}).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+
+		err := o.Set(&state.SetRequest{Key: "key", Value: "value"})
+		assert.NoError(t, err)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("update of binary value with etag", func(t *testing.T) {
+		o, mock := newMockDBAccess(t)
+		mock.ExpectExec(updateQuery).
+			WithArgs(sql.Named("value", []byte{0xff}), sql.Named("binary_yn", "Y"), newETag{},
+				sql.Named("key", "key"), sql.Named("etag", "etag-1")).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+
+		err := o.Set(&state.SetRequest{Key: "key", Value: []byte{0xff}, ETag: "etag-1"})
+		assert.NoError(t, err)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("etag mismatch", func(t *testing.T) {
+		o, mock := newMockDBAccess(t)
+		mock.ExpectExec(updateQuery).
+			WithArgs(sql.Named("value", []byte(`"value"`)), sql.Named("binary_yn", "N"), newETag{},
+				sql.Named("key", "key"), sql.Named("etag", "etag-1")).
+			WillReturnResult(sqlmock.NewResult(0, 0))
+
+		err := o.Set(&state.SetRequest{Key: "key", Value: "value", ETag: "etag-1"})
+		assert.Error(t, err)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("ttl is rejected", func(t *testing.T) {
+		o, mock := newMockDBAccess(t)
+
+		err := o.Set(&state.SetRequest{Key: "key", Value: "value", Metadata: map[string]string{"ttlInSeconds": "60"}})
+		assert.Error(t, err)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("exec error", func(t *testing.T) {
+		o, mock := newMockDBAccess(t)
+		mock.ExpectExec(mergeQuery).WillReturnError(errors.New("ORA-03113: end-of-file on communication channel"))
+
+		err := o.Set(&state.SetRequest{Key: "key", Value: "value"})
+		assert.Error(t, err)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+}
+
+func TestGet(t *testing.T) {
+	t.Parallel()
+
+	t.Run("found", func(t *testing.T) {
+		o, mock := newMockDBAccess(t)
+		mock.ExpectQuery(getQuery).
+			WithArgs(sql.Named("key", "key")).
+			WillReturnRows(sqlmock.NewRows([]string{"value", "etag"}).AddRow([]byte(`"value"`), "etag-1"))
+
+		res, err := o.Get(&state.GetRequest{Key: "key"})
+		assert.NoError(t, err)
+		assert.Equal(t, []byte(`"value"`), res.Data)
+		assert.Equal(t, "etag-1", res.ETag)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("missing", func(t *testing.T) {
+		o, mock := newMockDBAccess(t)
+		mock.ExpectQuery(getQuery).
+			WithArgs(sql.Named("key", "key")).
+			WillReturnRows(sqlmock.NewRows([]string{"value", "etag"}))
+
+		res, err := o.Get(&state.GetRequest{Key: "key"})
+		assert.NoError(t, err)
+		assert.Equal(t, &state.GetResponse{}, res)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+}
+
+func TestBulkGet(t *testing.T) {
+	t.Parallel()
+
+	t.Run("keys found and missing", func(t *testing.T) {
+		o, mock := newMockDBAccess(t)
+		mock.ExpectQuery(bulkGetQuery("state", 2)).
+			WithArgs("a", "b").
+			WillReturnRows(sqlmock.NewRows([]string{"key", "value", "etag"}).AddRow("b", []byte(`"two"`), "etag-2"))
+
+		responses, err := o.BulkGet([]state.GetRequest{{Key: "a"}, {Key: "b"}})
+		assert.NoError(t, err)
+		assert.Equal(t, []state.BulkGetResponse{
+			{Key: "a"},
+			{Key: "b", Data: []byte(`"two"`), ETag: "etag-2"},
+		}, responses)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("one query per thousand keys", func(t *testing.T) {
+		o, mock := newMockDBAccess(t)
+		req := make([]state.GetRequest, maxBulkGetSize+1)
+		for i := range req {
+			req[i].Key = uuid.New().String()
+		}
+		mock.ExpectQuery(bulkGetQuery("state", maxBulkGetSize)).
+			WillReturnRows(sqlmock.NewRows([]string{"key", "value", "etag"}))
+		mock.ExpectQuery(bulkGetQuery("state", 1)).
+			WithArgs(req[maxBulkGetSize].Key).
+			WillReturnRows(sqlmock.NewRows([]string{"key", "value", "etag"}))
+
+		responses, err := o.BulkGet(req)
+		assert.NoError(t, err)
+		assert.Len(t, responses, len(req))
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+}
+
+func TestDelete(t *testing.T) {
+	t.Parallel()
+
+	t.Run("without etag", func(t *testing.T) {
+		o, mock := newMockDBAccess(t)
+		mock.ExpectExec(deleteQuery).
+			WithArgs(sql.Named("key", "key")).
+			WillReturnResult(sqlmock.NewResult(0, 0))
+
+		assert.NoError(t, o.Delete(&state.DeleteRequest{Key: "key"}))
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("etag mismatch", func(t *testing.T) {
+		o, mock := newMockDBAccess(t)
+		mock.ExpectExec(etagDeleteQuery).
+			WithArgs(sql.Named("key", "key"), sql.Named("etag", "etag-1")).
+			WillReturnResult(sqlmock.NewResult(0, 0))
+
+		assert.Error(t, o.Delete(&state.DeleteRequest{Key: "key", ETag: "etag-1"}))
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+}
+
+func TestExecuteMulti(t *testing.T) {
+	t.Parallel()
+
+	t.Run("commit", func(t *testing.T) {
+		o, mock := newMockDBAccess(t)
+		mock.ExpectBegin()
+		mock.ExpectExec(deleteQuery).WithArgs(sql.Named("key", "b")).WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectExec(mergeQuery).WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectCommit()
+
+		err := o.ExecuteMulti([]state.SetRequest{{Key: "a", Value: "value"}}, []state.DeleteRequest{{Key: "b"}})
+		assert.NoError(t, err)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("etag mismatch rolls back", func(t *testing.T) {
+		o, mock := newMockDBAccess(t)
+		mock.ExpectBegin()
+		mock.ExpectExec(etagDeleteQuery).
+			WithArgs(sql.Named("key", "b"), sql.Named("etag", "etag-1")).
+			WillReturnResult(sqlmock.NewResult(0, 0))
+		mock.ExpectRollback()
+
+		err := o.ExecuteMulti([]state.SetRequest{{Key: "a", Value: "value"}}, []state.DeleteRequest{{Key: "b", ETag: "etag-1"}})
+		assert.Error(t, err)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+}
+
+func TestInitWithoutDriver(t *testing.T) {
+	t.Parallel()
+	if driverRegistered() {
+		t.Skip("the godror driver is built in")
+	}
+
+	o := newOracleDatabaseAccess(logger.NewLogger("test"))
+	err := o.Init(state.Metadata{Properties: map[string]string{connectionStringKey: fakeConnectionString}})
+	assert.Error(t, err)
+}
@@ -15,6 +15,11 @@ type dbAccess interface {
 	Set(req *state.SetRequest) error
 	Get(req *state.GetRequest) (*state.GetResponse, error)
 	Delete(req *state.DeleteRequest) error
+	BulkGet(req []state.GetRequest) ([]state.BulkGetResponse, error)
+	Query(req *state.QueryRequest) (*state.QueryResponse, error)
 	ExecuteMulti(sets []state.SetRequest, deletes []state.DeleteRequest) error
+	Watch(req *state.WatchRequest, handler func(change *state.StateChange)) (func(), error)
+	Lock(req *state.LockRequest) (*state.LockResponse, error)
+	Unlock(req *state.UnlockRequest) error
 	Close() error // io.Closer
 }
@@ -11,8 +11,10 @@ import (
 	"errors"
 	"fmt"
 	"strconv"
+	"sync"
 
 	"github.com/dapr/components-contrib/state"
+	"github.com/dapr/components-contrib/state/query"
 	"github.com/dapr/components-contrib/state/utils"
 	"github.com/dapr/dapr/pkg/logger"
 
@@ -22,16 +24,28 @@ import (
 
 const (
 	connectionStringKey        = "connectionString"
+	ginIndexKey                = "ginIndex"
 	errMissingConnectionString = "missing connection string"
 	tableName                  = "state"
 )
 
+// execer executes the statements of the operations, either on the database or in a transaction
+type execer interface {
+	Exec(query string, args ...interface{}) (sql.Result, error)
+}
+
 // postgresDBAccess implements dbaccess
 type postgresDBAccess struct {
 	logger           logger.Logger
 	metadata         state.Metadata
 	db               *sql.DB
 	connectionString string
+	// ginIndex indexes the values with a GIN index, which the queries use with the containment operator
+	ginIndex bool
+
+	// locks are the advisory locks held by the store, by resource id
+	locks     map[string]*advisoryLock
+	locksLock sync.Mutex
 }
 
 // newPostgresDBAccess creates a new instance of postgresAccess
@@ -40,6 +54,7 @@ func newPostgresDBAccess(logger logger.Logger) *postgresDBAccess {
 
 	return &postgresDBAccess{
 		logger: logger,
+		locks:  map[string]*advisoryLock{},
 	}
 }
 
@@ -56,6 +71,14 @@ func (p *postgresDBAccess) Init(metadata state.Metadata) error {
 		return fmt.Errorf(errMissingConnectionString)
 	}
 
+	if val, ok := metadata.Properties[ginIndexKey]; ok && val != "" {
+		ginIndex, err := strconv.ParseBool(val)
+		if err != nil {
+			return fmt.Errorf("error parsing %s field: %s", ginIndexKey, err)
+		}
+		p.ginIndex = ginIndex
+	}
+
 	db, err := sql.Open("pgx", p.connectionString)
 	if err != nil {
 		p.logger.Error(err)
@@ -75,6 +98,10 @@ func (p *postgresDBAccess) Init(metadata state.Metadata) error {
 		return err
 	}
 
+	if p.ginIndex {
+		return p.ensureGINIndex(tableName)
+	}
+
 	return nil
 }
 
@@ -85,6 +112,11 @@ func (p *postgresDBAccess) Set(req *state.SetRequest) error {
 
 // setValue is an internal implementation of set to enable passing the logic to state.SetWithRetries as a func.
 func (p *postgresDBAccess) setValue(req *state.SetRequest) error {
+	return p.execSet(p.db, req)
+}
+
+// execSet makes an insert or update with the execer, which is the database or a transaction.
+func (p *postgresDBAccess) execSet(db execer, req *state.SetRequest) error {
 	p.logger.Debug("Setting state value in PostgreSQL")
 
 	err := state.CheckRequestOptions(req.Options)
@@ -92,6 +124,11 @@ func (p *postgresDBAccess) setValue(req *state.SetRequest) error {
 		return err
 	}
 
+	err = state.RejectTTL(req.Metadata)
+	if err != nil {
+		return err
+	}
+
 	if req.Key == "" {
 		return fmt.Errorf("missing key in set operation")
 	}
@@ -105,7 +142,7 @@ func (p *postgresDBAccess) setValue(req *state.SetRequest) error {
 	// Sprintf is required for table name because sql.DB does not substitute parameters for table names.
 	// Other parameters use sql.DB parameter substitution.
 	if req.ETag == "" {
-		result, err = p.db.Exec(fmt.Sprintf(
+		result, err = db.Exec(fmt.Sprintf(
 			`INSERT INTO %s (key, value) VALUES ($1, $2)
 			ON CONFLICT (key) DO UPDATE SET value = $2, updatedate = NOW();`,
 			tableName), req.Key, value)
@@ -118,7 +155,7 @@ func (p *postgresDBAccess) setValue(req *state.SetRequest) error {
 		}
 
 		// When an etag is provided do an update - no insert
-		result, err = p.db.Exec(fmt.Sprintf(
+		result, err = db.Exec(fmt.Sprintf(
 			`UPDATE %s SET value = $1, updatedate = NOW() 
 			 WHERE key = $2 AND xmin = $3;`,
 			tableName), value, req.Key, etag)
@@ -162,6 +199,11 @@ func (p *postgresDBAccess) Delete(req *state.DeleteRequest) error {
 
 // deleteValue is an internal implementation of delete to enable passing the logic to state.DeleteWithRetries as a func.
 func (p *postgresDBAccess) deleteValue(req *state.DeleteRequest) error {
+	return p.execDelete(p.db, req)
+}
+
+// execDelete removes an item with the execer, which is the database or a transaction.
+func (p *postgresDBAccess) execDelete(db execer, req *state.DeleteRequest) error {
 	p.logger.Debug("Deleting state value from PostgreSQL")
 	if req.Key == "" {
 		return fmt.Errorf("missing key in delete operation")
@@ -171,7 +213,7 @@ func (p *postgresDBAccess) deleteValue(req *state.DeleteRequest) error {
 	var err error
 
 	if req.ETag == "" {
-		result, err = p.db.Exec("DELETE FROM state WHERE key = $1", req.Key)
+		result, err = db.Exec("DELETE FROM state WHERE key = $1", req.Key)
 	} else {
 		// Convert req.ETag to integer for postgres compatibility
 		etag, conversionError := strconv.Atoi(req.ETag)
@@ -179,12 +221,99 @@ func (p *postgresDBAccess) deleteValue(req *state.DeleteRequest) error {
 			return conversionError
 		}
 
-		result, err = p.db.Exec("DELETE FROM state WHERE key = $1 and xmin = $2", req.Key, etag)
+		result, err = db.Exec("DELETE FROM state WHERE key = $1 and xmin = $2", req.Key, etag)
 	}
 
 	return p.returnSingleDBResult(result, err)
 }
 
+// BulkGet returns the data of the keys with one query. The keys without data have an empty response.
+func (p *postgresDBAccess) BulkGet(req []state.GetRequest) ([]state.BulkGetResponse, error) {
+	p.logger.Debug("Getting state values from PostgreSQL")
+	keys := make([]string, len(req))
+	for i := range req {
+		if req[i].Key == "" {
+			return nil, fmt.Errorf("missing key in bulk get operation")
+		}
+		keys[i] = req[i].Key
+	}
+
+	rows, err := p.db.Query(fmt.Sprintf("SELECT key, value, xmin as etag FROM %s WHERE key = ANY($1)", tableName), keys)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	found := make(map[string]state.BulkGetResponse, len(req))
+	for rows.Next() {
+		var key, value string
+		var etag int
+		if err = rows.Scan(&key, &value, &etag); err != nil {
+			return nil, err
+		}
+		found[key] = state.BulkGetResponse{
+			Key:  key,
+			Data: []byte(value),
+			ETag: strconv.Itoa(etag),
+		}
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	responses := make([]state.BulkGetResponse, len(req))
+	for i := range req {
+		response, ok := found[req[i].Key]
+		if !ok {
+			response = state.BulkGetResponse{Key: req[i].Key}
+		}
+		response.Metadata = req[i].Metadata
+		responses[i] = response
+	}
+
+	return responses, nil
+}
+
+// Query returns the values matching a query, sorted and paginated by offset.
+func (p *postgresDBAccess) Query(req *state.QueryRequest) (*state.QueryResponse, error) {
+	p.logger.Debug("Querying state values from PostgreSQL")
+	q := &postgresQuery{containment: p.ginIndex}
+	err := query.NewQueryBuilder(q).BuildQuery(&req.Query)
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := p.db.Query(q.query, q.params...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	results := []state.QueryItem{}
+	for rows.Next() {
+		var key, value string
+		var etag int
+		if err = rows.Scan(&key, &value, &etag); err != nil {
+			return nil, err
+		}
+		results = append(results, state.QueryItem{
+			Key:  key,
+			Data: []byte(value),
+			ETag: strconv.Itoa(etag),
+		})
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return &state.QueryResponse{
+		Results:  results,
+		Token:    query.NextOffsetToken(q.offset, len(results), q.limit),
+		Metadata: req.Metadata,
+	}, nil
+}
+
+// ExecuteMulti executes the deletes then the sets in a transaction, which is rolled back when one of them fails.
 func (p *postgresDBAccess) ExecuteMulti(sets []state.SetRequest, deletes []state.DeleteRequest) error {
 	p.logger.Debug("Executing multiple PostgreSQL operations")
 	tx, err := p.db.Begin()
@@ -195,7 +324,7 @@ func (p *postgresDBAccess) ExecuteMulti(sets []state.SetRequest, deletes []state
 	if len(deletes) > 0 {
 		for _, d := range deletes {
 			da := d // Fix for gosec  G601: Implicit memory aliasing in for loop.
-			err = p.Delete(&da)
+			err = p.execDelete(tx, &da)
 			if err != nil {
 				tx.Rollback()
 
@@ -207,7 +336,7 @@ func (p *postgresDBAccess) ExecuteMulti(sets []state.SetRequest, deletes []state
 	if len(sets) > 0 {
 		for _, s := range sets {
 			sa := s // Fix for gosec  G601: Implicit memory aliasing in for loop.
-			err = p.Set(&sa)
+			err = p.execSet(tx, &sa)
 			if err != nil {
 				tx.Rollback()
 
@@ -256,6 +385,7 @@ func (p *postgresDBAccess) returnSingleDBResult(result sql.Result, err error) er
 
 // Close implements io.Close
 func (p *postgresDBAccess) Close() error {
+	p.releaseLocks()
 	if p.db != nil {
 		return p.db.Close()
 	}
@@ -271,9 +401,11 @@ func (p *postgresDBAccess) ensureStateTable(stateTableName string) error {
 
 	if !exists {
 		p.logger.Info("Creating PostgreSQL state table")
+		// jsonb normalizes the values, which are read back without their whitespace and duplicate keys,
+		// with the keys of their objects reordered
 		createTable := fmt.Sprintf(`CREATE TABLE %s (
 									key text NOT NULL PRIMARY KEY,
-									value json NOT NULL,
+									value jsonb NOT NULL,
 									insertdate TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
 									updatedate TIMESTAMP WITH TIME ZONE NULL);`, stateTableName)
 		_, err = p.db.Exec(createTable)
@@ -285,6 +417,31 @@ func (p *postgresDBAccess) ensureStateTable(stateTableName string) error {
 	return nil
 }
 
+// ensureGINIndex creates the GIN index of the values, unless it exists. It requires the jsonb values of the
+// tables created by this version, so the json values of the older tables are to be converted first.
+func (p *postgresDBAccess) ensureGINIndex(stateTableName string) error {
+	var dataType string
+	err := p.db.QueryRow(
+		"SELECT data_type FROM information_schema.columns WHERE table_name = $1 AND column_name = 'value'",
+		stateTableName).Scan(&dataType)
+	if err != nil {
+		return fmt.Errorf("error reading the type of the value column of the %s table: %s", stateTableName, err)
+	}
+	if dataType != "jsonb" {
+		return fmt.Errorf("the GIN index requires a jsonb value column, but the value column of the %s table is %s, "+
+			"convert it with ALTER TABLE %s ALTER COLUMN value TYPE jsonb USING value::jsonb", stateTableName, dataType, stateTableName)
+	}
+
+	_, err = p.db.Exec(fmt.Sprintf(
+		"CREATE INDEX IF NOT EXISTS %s_value_gin ON %s USING GIN (value jsonb_path_ops)",
+		stateTableName, stateTableName))
+	if err != nil {
+		return fmt.Errorf("error creating the GIN index of the %s table: %s", stateTableName, err)
+	}
+
+	return nil
+}
+
 func tableExists(db *sql.DB, tableName string) (bool, error) {
 	var exists bool = false
 	err := db.QueryRow("SELECT EXISTS (SELECT FROM pg_tables where tablename = $1)", tableName).Scan(&exists)
@@ -0,0 +1,113 @@
+// ------------------------------------------------------------
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT License.
+// ------------------------------------------------------------
+
+package postgresql
+
+import (
+	"database/sql/driver"
+	"errors"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/dapr/components-contrib/state"
+	"github.com/dapr/dapr/pkg/logger"
+	"github.com/stretchr/testify/assert"
+)
+
+// keysConverter passes the keys of the ANY($1) queries to the mock driver as is
+type keysConverter struct{}
+
+func (keysConverter) ConvertValue(v interface{}) (driver.Value, error) {
+	if keys, ok := v.([]string); ok {
+		return keys, nil
+	}
+
+	return driver.DefaultParameterConverter.ConvertValue(v)
+}
+
+func newMockDBAccess(t *testing.T) (*postgresDBAccess, sqlmock.Sqlmock) {
+	db, mock, err := sqlmock.New(
+		sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual),
+		sqlmock.ValueConverterOption(keysConverter{}))
+	assert.NoError(t, err)
+
+	p := newPostgresDBAccess(logger.NewLogger("test"))
+	p.db = db
+
+	return p, mock
+}
+
+func TestBulkGet(t *testing.T) {
+	bulkGetQuery := "SELECT key, value, xmin as etag FROM state WHERE key = ANY($1)"
+
+	t.Run("keys found and missing", func(t *testing.T) {
+		p, mock := newMockDBAccess(t)
+		mock.ExpectQuery(bulkGetQuery).
+			WithArgs([]string{"a", "b", "c"}).
+			WillReturnRows(sqlmock.NewRows([]string{"key", "value", "etag"}).
+				AddRow("c", `{"n":3}`, 12).
+				AddRow("a", `"one"`, 10))
+
+		responses, err := p.BulkGet([]state.GetRequest{
+			{Key: "a"},
+			{Key: "b", Metadata: map[string]string{"partitionKey": "p"}},
+			{Key: "c"},
+		})
+		assert.NoError(t, err)
+		assert.Equal(t, []state.BulkGetResponse{
+			{Key: "a", Data: []byte(`"one"`), ETag: "10"},
+			{Key: "b", Metadata: map[string]string{"partitionKey": "p"}},
+			{Key: "c", Data: []byte(`{"n":3}`), ETag: "12"},
+		}, responses)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("missing key", func(t *testing.T) {
+		p, mock := newMockDBAccess(t)
+
+		_, err := p.BulkGet([]state.GetRequest{{Key: "a"}, {Key: ""}})
+		assert.Error(t, err)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("query error", func(t *testing.T) {
+		p, mock := newMockDBAccess(t)
+		mock.ExpectQuery(bulkGetQuery).
+			WithArgs([]string{"a"}).
+			WillReturnError(errors.New("connection reset"))
+
+		_, err := p.BulkGet([]state.GetRequest{{Key: "a"}})
+		assert.Error(t, err)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+}
+
+func TestEnsureGINIndex(t *testing.T) {
+	columnTypeQuery := "SELECT data_type FROM information_schema.columns WHERE table_name = $1 AND column_name = 'value'"
+
+	t.Run("jsonb column", func(t *testing.T) {
+		p, mock := newMockDBAccess(t)
+		mock.ExpectQuery(columnTypeQuery).
+			WithArgs("state").
+			WillReturnRows(sqlmock.NewRows([]string{"data_type"}).AddRow("jsonb"))
+		mock.ExpectExec("CREATE INDEX IF NOT EXISTS state_value_gin ON state USING GIN (value jsonb_path_ops)").
+			WillReturnResult(sqlmock.NewResult(0, 0))
+
+		assert.NoError(t, p.ensureGINIndex("state"))
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("json column", func(t *testing.T) {
+		p, mock := newMockDBAccess(t)
+		mock.ExpectQuery(columnTypeQuery).
+			WithArgs("state").
+			WillReturnRows(sqlmock.NewRows([]string{"data_type"}).AddRow("json"))
+
+		err := p.ensureGINIndex("state")
+		assert.Error(t, err)
+		assert.Contains(t, err.Error(), "ALTER TABLE state ALTER COLUMN value TYPE jsonb USING value::jsonb")
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+}
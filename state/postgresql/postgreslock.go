@@ -0,0 +1,130 @@
+// ------------------------------------------------------------
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT License.
+// ------------------------------------------------------------
+
+package postgresql
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/dapr/components-contrib/state"
+)
+
+// advisoryLock is an advisory lock held by this store, on a connection of its own
+type advisoryLock struct {
+	owner string
+	conn  *sql.Conn
+	timer *time.Timer
+}
+
+// Lock locks a resource with a session advisory lock on the hash of its id, on a connection kept until the lock is
+// released, so that the lock is released when the store stops. The locks of distinct resources whose ids have the
+// same hash exclude each other.
+func (p *postgresDBAccess) Lock(req *state.LockRequest) (*state.LockResponse, error) {
+	if err := state.CheckLockRequest(req); err != nil {
+		return nil, err
+	}
+
+	p.locksLock.Lock()
+	defer p.locksLock.Unlock()
+
+	if _, ok := p.locks[req.ResourceID]; ok {
+		return &state.LockResponse{Success: false}, nil
+	}
+
+	conn, acquired, err := p.tryAdvisoryLock(req.ResourceID)
+	if err != nil || !acquired {
+		return &state.LockResponse{Success: false}, err
+	}
+
+	lock := &advisoryLock{owner: req.LockOwner, conn: conn}
+	lock.timer = time.AfterFunc(time.Duration(req.ExpiryInSeconds)*time.Second, func() {
+		p.locksLock.Lock()
+		defer p.locksLock.Unlock()
+
+		if p.locks[req.ResourceID] == lock {
+			p.releaseLock(req.ResourceID, lock)
+		}
+	})
+	p.locks[req.ResourceID] = lock
+
+	return &state.LockResponse{Success: true}, nil
+}
+
+// Unlock releases the advisory lock of a resource when it belongs to the owner. The locks held by the other
+// instances of the store belong to others.
+func (p *postgresDBAccess) Unlock(req *state.UnlockRequest) error {
+	if err := state.CheckUnlockRequest(req); err != nil {
+		return err
+	}
+
+	p.locksLock.Lock()
+	defer p.locksLock.Unlock()
+
+	lock, ok := p.locks[req.ResourceID]
+	if !ok {
+		// the resource is locked by another instance when its lock can't be acquired
+		conn, acquired, err := p.tryAdvisoryLock(req.ResourceID)
+		if err != nil {
+			return err
+		}
+		if !acquired {
+			return state.ErrLockBelongsToOthers
+		}
+		p.releaseLock(req.ResourceID, &advisoryLock{conn: conn})
+
+		return state.ErrLockDoesNotExist
+	}
+	if lock.owner != req.LockOwner {
+		return state.ErrLockBelongsToOthers
+	}
+	lock.timer.Stop()
+	p.releaseLock(req.ResourceID, lock)
+
+	return nil
+}
+
+// tryAdvisoryLock tries to acquire the advisory lock of a resource on a new connection, which is returned when the
+// lock is acquired
+func (p *postgresDBAccess) tryAdvisoryLock(resourceID string) (*sql.Conn, bool, error) {
+	ctx := context.Background()
+	conn, err := p.db.Conn(ctx)
+	if err != nil {
+		return nil, false, err
+	}
+
+	var acquired bool
+	err = conn.QueryRowContext(ctx, "SELECT pg_try_advisory_lock(hashtext($1))", resourceID).Scan(&acquired)
+	if err != nil || !acquired {
+		conn.Close()
+
+		return nil, false, err
+	}
+
+	return conn, true, nil
+}
+
+// releaseLock releases an advisory lock before its connection returns to the pool, the locks must be locked
+func (p *postgresDBAccess) releaseLock(resourceID string, lock *advisoryLock) {
+	delete(p.locks, resourceID)
+
+	_, err := lock.conn.ExecContext(context.Background(), "SELECT pg_advisory_unlock(hashtext($1))", resourceID)
+	if err != nil {
+		p.logger.Warnf("Failed to release the PostgreSQL advisory lock of %s: %s", resourceID, err)
+	}
+	lock.conn.Close()
+}
+
+// releaseLocks releases the advisory locks held by the store
+func (p *postgresDBAccess) releaseLocks() {
+	p.locksLock.Lock()
+	defer p.locksLock.Unlock()
+
+	for resourceID, lock := range p.locks {
+		lock.timer.Stop()
+		p.releaseLock(resourceID, lock)
+	}
+}
@@ -44,7 +44,7 @@ func (p *PostgreSQL) Delete(req *state.DeleteRequest) error {
 	return p.dbaccess.Delete(req)
 }
 
-// BulkDelete removes multiple entries from the store
+// BulkDelete removes multiple entries from the store in a transaction
 func (p *PostgreSQL) BulkDelete(req []state.DeleteRequest) error {
 	return p.dbaccess.ExecuteMulti(nil, req)
 }
@@ -54,10 +54,14 @@ func (p *PostgreSQL) Get(req *state.GetRequest) (*state.GetResponse, error) {
 	return p.dbaccess.Get(req)
 }
 
-// BulkGet performs a bulks get operations
+// BulkGet returns multiple entities from the store with one query
 func (p *PostgreSQL) BulkGet(req []state.GetRequest) (bool, []state.BulkGetResponse, error) {
-	// TODO: replace with ExecuteMulti for performance
-	return false, nil, nil
+	responses, err := p.dbaccess.BulkGet(req)
+	if err != nil {
+		return true, nil, err
+	}
+
+	return true, responses, nil
 }
 
 // Set adds/updates an entity on store
@@ -65,11 +69,31 @@ func (p *PostgreSQL) Set(req *state.SetRequest) error {
 	return p.dbaccess.Set(req)
 }
 
-// BulkSet adds/updates multiple entities on store
+// BulkSet adds/updates multiple entities on store in a transaction
 func (p *PostgreSQL) BulkSet(req []state.SetRequest) error {
 	return p.dbaccess.ExecuteMulti(req, nil)
 }
 
+// Query returns the values matching a query. Implements Querier.
+func (p *PostgreSQL) Query(req *state.QueryRequest) (*state.QueryResponse, error) {
+	return p.dbaccess.Query(req)
+}
+
+// Watch notifies the changes of the keys. Implements StateWatcher.
+func (p *PostgreSQL) Watch(req *state.WatchRequest, handler func(change *state.StateChange)) (func(), error) {
+	return p.dbaccess.Watch(req, handler)
+}
+
+// Lock locks a resource with an advisory lock. Implements Locker.
+func (p *PostgreSQL) Lock(req *state.LockRequest) (*state.LockResponse, error) {
+	return p.dbaccess.Lock(req)
+}
+
+// Unlock releases the advisory lock of a resource. Implements Locker.
+func (p *PostgreSQL) Unlock(req *state.UnlockRequest) error {
+	return p.dbaccess.Unlock(req)
+}
+
 // Multi handles multiple transactions. Implements TransactionalStore.
 func (p *PostgreSQL) Multi(request *state.TransactionalStateRequest) error {
 	var deletes []state.DeleteRequest
@@ -84,6 +84,16 @@ func TestPostgreSQLIntegration(t *testing.T) {
 		testBulkSetAndBulkDelete(t, pgs)
 	})
 
+	t.Run("Bulk get", func(t *testing.T) {
+		t.Parallel()
+		testBulkGet(t, pgs)
+	})
+
+	t.Run("Multi rolls back when an operation fails", func(t *testing.T) {
+		t.Parallel()
+		multiRollsBackOnFailure(t, pgs)
+	})
+
 	t.Run("Update and delete with etag succeeds", func(t *testing.T) {
 		t.Parallel()
 		updateAndDeleteWithEtagSucceeds(t, pgs)
@@ -128,6 +138,28 @@ func TestPostgreSQLIntegration(t *testing.T) {
 		t.Parallel()
 		multiWithSetOnly(t, pgs)
 	})
+
+	t.Run("Lock and unlock", func(t *testing.T) {
+		t.Parallel()
+		lockAndUnlock(t, pgs)
+	})
+}
+
+// lockAndUnlock validates that a locked resource can't be locked again until its owner unlocks it.
+func lockAndUnlock(t *testing.T, pgs *PostgreSQL) {
+	resourceID := randomKey()
+
+	res, err := pgs.Lock(&state.LockRequest{ResourceID: resourceID, LockOwner: "owner-1", ExpiryInSeconds: 60})
+	assert.Nil(t, err)
+	assert.True(t, res.Success)
+
+	res, err = pgs.Lock(&state.LockRequest{ResourceID: resourceID, LockOwner: "owner-2", ExpiryInSeconds: 60})
+	assert.Nil(t, err)
+	assert.False(t, res.Success)
+
+	assert.Equal(t, state.ErrLockBelongsToOthers, pgs.Unlock(&state.UnlockRequest{ResourceID: resourceID, LockOwner: "owner-2"}))
+	assert.Nil(t, pgs.Unlock(&state.UnlockRequest{ResourceID: resourceID, LockOwner: "owner-1"}))
+	assert.Equal(t, state.ErrLockDoesNotExist, pgs.Unlock(&state.UnlockRequest{ResourceID: resourceID, LockOwner: "owner-1"}))
 }
 
 // setGetUpdateDeleteOneItem validates setting one item, getting it, and deleting it.
@@ -457,6 +489,54 @@ func testBulkSetAndBulkDelete(t *testing.T, pgs *PostgreSQL) {
 	assert.False(t, storeItemExists(t, setReq[1].Key))
 }
 
+// Tests a bulk get of existing and missing items
+func testBulkGet(t *testing.T, pgs *PostgreSQL) {
+	key1 := randomKey()
+	key2 := randomKey()
+	missing := randomKey()
+	setItem(t, pgs, key1, &fakeItem{Color: "blue"}, "")
+	setItem(t, pgs, key2, &fakeItem{Color: "red"}, "")
+
+	supported, responses, err := pgs.BulkGet([]state.GetRequest{{Key: key1}, {Key: missing}, {Key: key2}})
+	assert.Nil(t, err)
+	assert.True(t, supported)
+	assert.Len(t, responses, 3)
+
+	assert.Equal(t, key1, responses[0].Key)
+	assert.JSONEq(t, `{"Color": "blue"}`, string(responses[0].Data))
+	assert.NotEmpty(t, responses[0].ETag)
+	assert.Equal(t, missing, responses[1].Key)
+	assert.Empty(t, responses[1].Data)
+	assert.Equal(t, key2, responses[2].Key)
+	assert.JSONEq(t, `{"Color": "red"}`, string(responses[2].Data))
+
+	deleteItem(t, pgs, key1, "")
+	deleteItem(t, pgs, key2, "")
+}
+
+// Tests that the operations of a transaction are rolled back when one of them fails
+func multiRollsBackOnFailure(t *testing.T, pgs *PostgreSQL) {
+	setReq := createSetRequest()
+	err := pgs.Multi(&state.TransactionalStateRequest{
+		Operations: []state.TransactionalStateOperation{
+			{
+				Operation: state.Upsert,
+				Request:   setReq,
+			},
+			{
+				Operation: state.Upsert,
+				Request: state.SetRequest{
+					Key:   randomKey(),
+					ETag:  "1234", // the item does not exist
+					Value: randomJSON(),
+				},
+			},
+		},
+	})
+	assert.NotNil(t, err)
+	assert.False(t, storeItemExists(t, setReq.Key))
+}
+
 // testInitConfiguration tests valid and invalid config settings
 func testInitConfiguration(t *testing.T) {
 	logger := logger.NewLogger("test")
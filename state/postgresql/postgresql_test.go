@@ -18,10 +18,15 @@ const (
 
 // Fake implementation of interface postgressql.dbaccess
 type fakeDBaccess struct {
-	logger       logger.Logger
-	initExecuted bool
-	setExecuted  bool
-	getExecuted  bool
+	logger          logger.Logger
+	initExecuted    bool
+	setExecuted     bool
+	getExecuted     bool
+	queryExecuted   bool
+	bulkGetExecuted bool
+	watchExecuted   bool
+	lockExecuted    bool
+	unlockExecuted  bool
 }
 
 func (m *fakeDBaccess) Init(metadata state.Metadata) error {
@@ -46,10 +51,40 @@ func (m *fakeDBaccess) Delete(req *state.DeleteRequest) error {
 	return nil
 }
 
+func (m *fakeDBaccess) BulkGet(req []state.GetRequest) ([]state.BulkGetResponse, error) {
+	m.bulkGetExecuted = true
+
+	return nil, nil
+}
+
+func (m *fakeDBaccess) Query(req *state.QueryRequest) (*state.QueryResponse, error) {
+	m.queryExecuted = true
+
+	return nil, nil
+}
+
 func (m *fakeDBaccess) ExecuteMulti(sets []state.SetRequest, deletes []state.DeleteRequest) error {
 	return nil
 }
 
+func (m *fakeDBaccess) Watch(req *state.WatchRequest, handler func(change *state.StateChange)) (func(), error) {
+	m.watchExecuted = true
+
+	return func() {}, nil
+}
+
+func (m *fakeDBaccess) Lock(req *state.LockRequest) (*state.LockResponse, error) {
+	m.lockExecuted = true
+
+	return &state.LockResponse{Success: true}, nil
+}
+
+func (m *fakeDBaccess) Unlock(req *state.UnlockRequest) error {
+	m.unlockExecuted = true
+
+	return nil
+}
+
 func (m *fakeDBaccess) Close() error {
 	return nil
 }
@@ -151,6 +186,49 @@ func TestInvalidMultiDeleteRequest(t *testing.T) {
 	assert.NotNil(t, err)
 }
 
+// Proves that the Query method runs the query method of dbaccess
+func TestQueryRunsDBAccessQuery(t *testing.T) {
+	t.Parallel()
+	pgs, fake := createPostgreSQLWithFake(t)
+	_, err := pgs.Query(&state.QueryRequest{})
+	assert.Nil(t, err)
+	assert.True(t, fake.queryExecuted)
+}
+
+// Proves that the Watch method runs the watch method of dbaccess
+func TestWatchRunsDBAccessWatch(t *testing.T) {
+	t.Parallel()
+	pgs, fake := createPostgreSQLWithFake(t)
+	stop, err := pgs.Watch(&state.WatchRequest{}, func(change *state.StateChange) {})
+	assert.Nil(t, err)
+	assert.NotNil(t, stop)
+	assert.True(t, fake.watchExecuted)
+}
+
+// Proves that the Lock and Unlock methods run the lock and unlock methods of dbaccess
+func TestLockRunsDBAccessLock(t *testing.T) {
+	t.Parallel()
+	pgs, fake := createPostgreSQLWithFake(t)
+	res, err := pgs.Lock(&state.LockRequest{ResourceID: "orders", LockOwner: "worker-1", ExpiryInSeconds: 10})
+	assert.Nil(t, err)
+	assert.True(t, res.Success)
+	assert.True(t, fake.lockExecuted)
+
+	err = pgs.Unlock(&state.UnlockRequest{ResourceID: "orders", LockOwner: "worker-1"})
+	assert.Nil(t, err)
+	assert.True(t, fake.unlockExecuted)
+}
+
+// Proves that the BulkGet method runs the bulk get method of dbaccess
+func TestBulkGetRunsDBAccessBulkGet(t *testing.T) {
+	t.Parallel()
+	pgs, fake := createPostgreSQLWithFake(t)
+	supported, _, err := pgs.BulkGet([]state.GetRequest{{Key: randomKey()}})
+	assert.Nil(t, err)
+	assert.True(t, supported)
+	assert.True(t, fake.bulkGetExecuted)
+}
+
 func createSetRequest() state.SetRequest {
 	return state.SetRequest{
 		Key:   randomKey(),
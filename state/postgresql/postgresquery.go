@@ -0,0 +1,150 @@
+// ------------------------------------------------------------
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT License.
+// ------------------------------------------------------------
+
+package postgresql
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/dapr/components-contrib/state/query"
+)
+
+// postgresQuery translates a query to SQL, where the keys of the query are extracted from the JSON values
+// with the -> and ->> operators. The filters compare the text of the keys, the sorting orders their JSON values.
+// With containment, the filters match the JSON values of the keys with the @> operator instead, which the
+// GIN index of the values speeds up.
+type postgresQuery struct {
+	containment bool
+
+	query  string
+	params []interface{}
+	offset int
+	limit  int
+}
+
+func (q *postgresQuery) VisitEQ(f *query.EQ) (string, error) {
+	if q.containment {
+		return q.contains(f.Key, f.Val)
+	}
+
+	return fmt.Sprintf("%s = %s", textField(f.Key), q.param(f.Val)), nil
+}
+
+func (q *postgresQuery) VisitIN(f *query.IN) (string, error) {
+	if q.containment {
+		filters := make([]string, len(f.Vals))
+		for i, val := range f.Vals {
+			filter, err := q.contains(f.Key, val)
+			if err != nil {
+				return "", err
+			}
+			filters[i] = filter
+		}
+
+		return q.VisitOR(filters)
+	}
+
+	params := make([]string, len(f.Vals))
+	for i, val := range f.Vals {
+		params[i] = q.param(val)
+	}
+
+	return fmt.Sprintf("%s IN (%s)", textField(f.Key), strings.Join(params, ", ")), nil
+}
+
+func (q *postgresQuery) VisitAND(filters []string) (string, error) {
+	return "(" + strings.Join(filters, " AND ") + ")", nil
+}
+
+func (q *postgresQuery) VisitOR(filters []string) (string, error) {
+	return "(" + strings.Join(filters, " OR ") + ")", nil
+}
+
+func (q *postgresQuery) Finalize(filter string, qq *query.Query) error {
+	offset, err := query.ParseOffsetToken(qq.Page.Token)
+	if err != nil {
+		return err
+	}
+	q.offset = offset
+	q.limit = qq.Page.Limit
+
+	// Sprintf is required for table name because sql.DB does not substitute parameters for table names.
+	q.query = fmt.Sprintf("SELECT key, value, xmin as etag FROM %s", tableName)
+	if filter != "" {
+		q.query += " WHERE " + filter
+	}
+	if len(qq.Sort) > 0 {
+		order := make([]string, len(qq.Sort))
+		for i, s := range qq.Sort {
+			// jsonb orders the numbers by value, unlike their text
+			order[i] = fmt.Sprintf("(%s)::jsonb %s", jsonField(s.Key), s.Order)
+		}
+		q.query += " ORDER BY " + strings.Join(order, ", ")
+	}
+	if q.limit > 0 {
+		q.query += " LIMIT " + strconv.Itoa(q.limit)
+	}
+	if q.offset > 0 {
+		q.query += " OFFSET " + strconv.Itoa(q.offset)
+	}
+
+	return nil
+}
+
+// param adds a parameter to the query and returns its placeholder
+func (q *postgresQuery) param(val interface{}) string {
+	text, ok := val.(string)
+	if !ok {
+		// the numbers and booleans are compared to the text of the JSON scalars
+		bt, _ := json.Marshal(val)
+		text = string(bt)
+	}
+	q.params = append(q.params, text)
+
+	return "$" + strconv.Itoa(len(q.params))
+}
+
+// contains returns the filter of the values containing a key with a value, such as value @> '{"person": {"org": "Dev Ops"}}'
+func (q *postgresQuery) contains(key string, val interface{}) (string, error) {
+	parts := strings.Split(key, ".")
+	doc := val
+	for i := len(parts) - 1; i >= 0; i-- {
+		doc = map[string]interface{}{parts[i]: doc}
+	}
+	bt, err := json.Marshal(doc)
+	if err != nil {
+		return "", err
+	}
+	q.params = append(q.params, string(bt))
+
+	return "value @> $" + strconv.Itoa(len(q.params)) + "::jsonb", nil
+}
+
+// jsonField returns the JSON value of a key, such as value->'person'->'org'
+func jsonField(key string) string {
+	return fieldPath(key, "->")
+}
+
+// textField returns the text of a key, such as value->'person'->>'org'
+func textField(key string) string {
+	return fieldPath(key, "->>")
+}
+
+func fieldPath(key, lastOperator string) string {
+	parts := strings.Split(key, ".")
+	field := "value"
+	for i, part := range parts {
+		operator := "->"
+		if i == len(parts)-1 {
+			operator = lastOperator
+		}
+		field += operator + "'" + strings.ReplaceAll(part, "'", "''") + "'"
+	}
+
+	return field
+}
@@ -0,0 +1,75 @@
+// ------------------------------------------------------------
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT License.
+// ------------------------------------------------------------
+package postgresql
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/dapr/components-contrib/state/query"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func buildPostgresQuery(t *testing.T, data string) *postgresQuery {
+	var qq query.Query
+	require.NoError(t, json.Unmarshal([]byte(data), &qq))
+
+	q := &postgresQuery{}
+	require.NoError(t, query.NewQueryBuilder(q).BuildQuery(&qq))
+
+	return q
+}
+
+func TestPostgresQuery(t *testing.T) {
+	t.Run("filter, sort and page", func(t *testing.T) {
+		q := buildPostgresQuery(t, `{
+			"filter": {"OR": [{"EQ": {"person.org": "Dev Ops"}}, {"AND": [{"EQ": {"age": 30}}, {"IN": {"state": ["CA", "WA"]}}]}]},
+			"sort": [{"key": "state", "order": "DESC"}, {"key": "person.id"}],
+			"page": {"limit": 10, "token": "20"}
+		}`)
+
+		assert.Equal(t, "SELECT key, value, xmin as etag FROM state "+
+			"WHERE (value->'person'->>'org' = $1 OR (value->>'age' = $2 AND value->>'state' IN ($3, $4))) "+
+			"ORDER BY (value->'state')::jsonb DESC, (value->'person'->'id')::jsonb ASC LIMIT 10 OFFSET 20", q.query)
+		assert.Equal(t, []interface{}{"Dev Ops", "30", "CA", "WA"}, q.params)
+		assert.Equal(t, 20, q.offset)
+		assert.Equal(t, 10, q.limit)
+	})
+
+	t.Run("no filter", func(t *testing.T) {
+		q := buildPostgresQuery(t, `{}`)
+
+		assert.Equal(t, "SELECT key, value, xmin as etag FROM state", q.query)
+		assert.Empty(t, q.params)
+	})
+
+	t.Run("quotes are escaped", func(t *testing.T) {
+		q := buildPostgresQuery(t, `{"filter": {"EQ": {"it's": "x"}}}`)
+
+		assert.Equal(t, "SELECT key, value, xmin as etag FROM state WHERE value->>'it''s' = $1", q.query)
+	})
+
+	t.Run("containment filters", func(t *testing.T) {
+		var qq query.Query
+		require.NoError(t, json.Unmarshal([]byte(`{
+			"filter": {"AND": [{"EQ": {"person.org": "Dev Ops"}}, {"IN": {"age": [30, 40]}}]}
+		}`), &qq))
+
+		q := &postgresQuery{containment: true}
+		require.NoError(t, query.NewQueryBuilder(q).BuildQuery(&qq))
+
+		assert.Equal(t, "SELECT key, value, xmin as etag FROM state "+
+			"WHERE (value @> $1::jsonb AND (value @> $2::jsonb OR value @> $3::jsonb))", q.query)
+		assert.Equal(t, []interface{}{`{"person":{"org":"Dev Ops"}}`, `{"age":30}`, `{"age":40}`}, q.params)
+	})
+
+	t.Run("invalid token", func(t *testing.T) {
+		var qq query.Query
+		require.NoError(t, json.Unmarshal([]byte(`{"page": {"limit": 10, "token": "next"}}`), &qq))
+
+		assert.Error(t, query.NewQueryBuilder(&postgresQuery{}).BuildQuery(&qq))
+	})
+}
@@ -0,0 +1,120 @@
+// ------------------------------------------------------------
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT License.
+// ------------------------------------------------------------
+
+package postgresql
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/dapr/components-contrib/state"
+	"github.com/jackc/pgx/v4"
+)
+
+// notifyFunction is the trigger function notifying the changes of the keys of the state table on its channel,
+// with the key and the operation only, as the payload of a notification is limited to 8000 bytes
+const notifyFunction = `CREATE OR REPLACE FUNCTION %[1]s_notify() RETURNS trigger AS $$
+BEGIN
+	IF TG_OP = 'DELETE' THEN
+		PERFORM pg_notify('%[1]s_changes', json_build_object('key', OLD.key, 'operation', 'delete')::text);
+	ELSE
+		PERFORM pg_notify('%[1]s_changes', json_build_object('key', NEW.key, 'operation', 'upsert')::text);
+	END IF;
+	RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;`
+
+// notification is the payload of a notification of the trigger function
+type notification struct {
+	Key       string              `json:"key"`
+	Operation state.OperationType `json:"operation"`
+}
+
+// Watch notifies the changes of the keys with LISTEN on a connection of its own, and a trigger on the state table
+// which notifies its changes. The value of an upsert is read when it is notified.
+func (p *postgresDBAccess) Watch(req *state.WatchRequest, handler func(change *state.StateChange)) (func(), error) {
+	if err := p.ensureNotifyTrigger(tableName); err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	conn, err := pgx.Connect(ctx, p.connectionString)
+	if err != nil {
+		cancel()
+
+		return nil, err
+	}
+	if _, err = conn.Exec(ctx, fmt.Sprintf("LISTEN %s_changes", tableName)); err != nil {
+		cancel()
+		conn.Close(context.Background())
+
+		return nil, err
+	}
+
+	go func() {
+		defer conn.Close(context.Background())
+		for {
+			n, err := conn.WaitForNotification(ctx)
+			if err != nil {
+				if ctx.Err() == nil {
+					p.logger.Errorf("Stopped watching the PostgreSQL state table: %s", err)
+				}
+
+				return
+			}
+			change, err := p.notificationChange(n.Payload)
+			if err != nil {
+				p.logger.Warnf("Ignoring the PostgreSQL state change notification %s: %s", n.Payload, err)
+
+				continue
+			}
+			if req.Watches(change.Key) {
+				handler(change)
+			}
+		}
+	}()
+
+	return cancel, nil
+}
+
+// notificationChange returns the change of the payload of a notification, along with the value of an upsert
+func (p *postgresDBAccess) notificationChange(payload string) (*state.StateChange, error) {
+	var n notification
+	if err := json.Unmarshal([]byte(payload), &n); err != nil {
+		return nil, err
+	}
+
+	change := &state.StateChange{Key: n.Key, Operation: n.Operation}
+	if n.Operation == state.Upsert {
+		res, err := p.Get(&state.GetRequest{Key: n.Key})
+		if err != nil {
+			return nil, err
+		}
+		change.Data = res.Data
+		change.ETag = res.ETag
+	}
+
+	return change, nil
+}
+
+// ensureNotifyTrigger creates the trigger notifying the changes of the state table, unless it exists
+func (p *postgresDBAccess) ensureNotifyTrigger(stateTableName string) error {
+	var exists bool
+	err := p.db.QueryRow("SELECT EXISTS (SELECT FROM pg_trigger WHERE tgname = $1)", stateTableName+"_notify").Scan(&exists)
+	if err != nil || exists {
+		return err
+	}
+
+	p.logger.Info("Creating the PostgreSQL trigger notifying the changes of the state table")
+	if _, err = p.db.Exec(fmt.Sprintf(notifyFunction, stateTableName)); err != nil {
+		return err
+	}
+	_, err = p.db.Exec(fmt.Sprintf(
+		"CREATE TRIGGER %[1]s_notify AFTER INSERT OR UPDATE OR DELETE ON %[1]s FOR EACH ROW EXECUTE PROCEDURE %[1]s_notify()",
+		stateTableName))
+
+	return err
+}
@@ -0,0 +1,158 @@
+// ------------------------------------------------------------
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT License.
+// ------------------------------------------------------------
+
+package query
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// Filter is one of EQ, IN, AND and OR
+type Filter interface {
+	isFilter()
+}
+
+// EQ matches the values whose key equals Val
+type EQ struct {
+	Key string
+	Val interface{}
+}
+
+// IN matches the values whose key equals one of Vals
+type IN struct {
+	Key  string
+	Vals []interface{}
+}
+
+// AND matches the values which match all of its filters
+type AND struct {
+	Filters []Filter
+}
+
+// OR matches the values which match any of its filters
+type OR struct {
+	Filters []Filter
+}
+
+func (*EQ) isFilter()  {}
+func (*IN) isFilter()  {}
+func (*AND) isFilter() {}
+func (*OR) isFilter()  {}
+
+// ParseFilter parses a filter unmarshaled from JSON, which is an object with a single operation
+func ParseFilter(obj interface{}) (Filter, error) {
+	m, ok := obj.(map[string]interface{})
+	if !ok || len(m) != 1 {
+		return nil, errors.New("invalid filter: it must be an object with a single operation")
+	}
+
+	for op, operand := range m {
+		switch op {
+		case "EQ":
+			key, val, err := parseKeyValue(op, operand)
+			if err != nil {
+				return nil, err
+			}
+			if !isScalar(val) {
+				return nil, fmt.Errorf("invalid filter: the value of EQ %s must be a string, a number or a boolean", key)
+			}
+
+			return &EQ{Key: key, Val: val}, nil
+		case "IN":
+			key, val, err := parseKeyValue(op, operand)
+			if err != nil {
+				return nil, err
+			}
+			vals, ok := val.([]interface{})
+			if !ok || len(vals) == 0 {
+				return nil, fmt.Errorf("invalid filter: the value of IN %s must be a non-empty array", key)
+			}
+			for _, v := range vals {
+				if !isScalar(v) {
+					return nil, fmt.Errorf("invalid filter: the values of IN %s must be strings, numbers or booleans", key)
+				}
+			}
+
+			return &IN{Key: key, Vals: vals}, nil
+		case "AND":
+			filters, err := parseFilters(op, operand)
+			if err != nil {
+				return nil, err
+			}
+
+			return &AND{Filters: filters}, nil
+		case "OR":
+			filters, err := parseFilters(op, operand)
+			if err != nil {
+				return nil, err
+			}
+
+			return &OR{Filters: filters}, nil
+		default:
+			return nil, fmt.Errorf("invalid filter: unsupported operation %s", op)
+		}
+	}
+
+	return nil, nil
+}
+
+func parseKeyValue(op string, operand interface{}) (string, interface{}, error) {
+	m, ok := operand.(map[string]interface{})
+	if !ok || len(m) != 1 {
+		return "", nil, fmt.Errorf("invalid filter: the operand of %s must be an object with a single key", op)
+	}
+
+	for key, val := range m {
+		if err := validateKey(key); err != nil {
+			return "", nil, fmt.Errorf("invalid filter: %s", err)
+		}
+
+		return key, val, nil
+	}
+
+	return "", nil, nil
+}
+
+func parseFilters(op string, operand interface{}) ([]Filter, error) {
+	arr, ok := operand.([]interface{})
+	if !ok || len(arr) == 0 {
+		return nil, fmt.Errorf("invalid filter: the operand of %s must be a non-empty array of filters", op)
+	}
+
+	filters := make([]Filter, len(arr))
+	for i, obj := range arr {
+		var err error
+		if filters[i], err = ParseFilter(obj); err != nil {
+			return nil, err
+		}
+	}
+
+	return filters, nil
+}
+
+// validateKey validates the dotted path of a key
+func validateKey(key string) error {
+	if key == "" {
+		return errors.New("the key is empty")
+	}
+	for _, part := range strings.Split(key, ".") {
+		if part == "" {
+			return fmt.Errorf("the key %s has an empty field", key)
+		}
+	}
+
+	return nil
+}
+
+func isScalar(val interface{}) bool {
+	switch val.(type) {
+	case string, bool, float64, float32, int, int32, int64:
+		return true
+	default:
+		return false
+	}
+}
@@ -0,0 +1,198 @@
+// ------------------------------------------------------------
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT License.
+// ------------------------------------------------------------
+
+/*
+Package query defines a portable query language on the values of a state store. A query filters, sorts and paginates
+the values, its keys are the dotted paths of fields in the JSON values, for example:
+
+	{
+		"filter": {
+			"OR": [
+				{"EQ": {"person.org": "Dev Ops"}},
+				{"AND": [{"EQ": {"person.org": "Finance"}}, {"IN": {"state": ["CA", "WA"]}}]}
+			]
+		},
+		"sort": [{"key": "state", "order": "DESC"}],
+		"page": {"limit": 10}
+	}
+
+The stores translate the queries to their native query language with a Visitor.
+*/
+package query
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+)
+
+const (
+	// ASC sorts in ascending order, it is the default order
+	ASC = "ASC"
+	// DESC sorts in descending order
+	DESC = "DESC"
+)
+
+// Query is a query on the values of a state store
+type Query struct {
+	Filters map[string]interface{} `json:"filter"`
+	Sort    []Sorting              `json:"sort"`
+	Page    Pagination             `json:"page"`
+
+	// Filter is parsed from Filters, it is nil when the query has no filter
+	Filter Filter `json:"-"`
+}
+
+// Sorting sorts the values on a key
+type Sorting struct {
+	Key   string `json:"key"`
+	Order string `json:"order,omitempty"`
+}
+
+// Pagination limits the number of values returned by a query.
+// Token is returned by the previous query to resume from, its format is specific to the store.
+type Pagination struct {
+	Limit int    `json:"limit"`
+	Token string `json:"token,omitempty"`
+}
+
+// UnmarshalJSON unmarshals and validates a query
+func (q *Query) UnmarshalJSON(data []byte) error {
+	type query Query
+	var raw query
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return err
+	}
+	*q = Query(raw)
+
+	return q.Validate()
+}
+
+// Validate parses the filter of a query and validates its sorting and pagination
+func (q *Query) Validate() error {
+	q.Filter = nil
+	if len(q.Filters) > 0 {
+		filter, err := ParseFilter(q.Filters)
+		if err != nil {
+			return err
+		}
+		q.Filter = filter
+	}
+
+	for i := range q.Sort {
+		if err := validateKey(q.Sort[i].Key); err != nil {
+			return fmt.Errorf("invalid sort: %s", err)
+		}
+		switch q.Sort[i].Order {
+		case "":
+			q.Sort[i].Order = ASC
+		case ASC, DESC:
+		default:
+			return fmt.Errorf("invalid sort order %s of key %s, it must be %s or %s", q.Sort[i].Order, q.Sort[i].Key, ASC, DESC)
+		}
+	}
+
+	if q.Page.Limit < 0 {
+		return fmt.Errorf("invalid page limit %d, it must be positive", q.Page.Limit)
+	}
+
+	return nil
+}
+
+// Visitor translates the filters of a query to the native query of a store
+type Visitor interface {
+	VisitEQ(f *EQ) (string, error)
+	VisitIN(f *IN) (string, error)
+	// VisitAND and VisitOR receive the translated filters of the operation
+	VisitAND(filters []string) (string, error)
+	VisitOR(filters []string) (string, error)
+	// Finalize receives the translated filter, empty if the query has none, and completes the native query
+	// with the sorting and pagination of the query
+	Finalize(filter string, q *Query) error
+}
+
+// Builder builds the native query of a store with its visitor
+type Builder struct {
+	visitor Visitor
+}
+
+// NewQueryBuilder returns a new builder of native queries
+func NewQueryBuilder(visitor Visitor) *Builder {
+	return &Builder{visitor: visitor}
+}
+
+// BuildQuery visits the filters of a query, then finalizes the native query
+func (b *Builder) BuildQuery(q *Query) error {
+	filter := ""
+	if q.Filter != nil {
+		var err error
+		if filter, err = b.buildFilter(q.Filter); err != nil {
+			return err
+		}
+	}
+
+	return b.visitor.Finalize(filter, q)
+}
+
+func (b *Builder) buildFilter(filter Filter) (string, error) {
+	switch f := filter.(type) {
+	case *EQ:
+		return b.visitor.VisitEQ(f)
+	case *IN:
+		return b.visitor.VisitIN(f)
+	case *AND:
+		filters, err := b.buildFilters(f.Filters)
+		if err != nil {
+			return "", err
+		}
+
+		return b.visitor.VisitAND(filters)
+	case *OR:
+		filters, err := b.buildFilters(f.Filters)
+		if err != nil {
+			return "", err
+		}
+
+		return b.visitor.VisitOR(filters)
+	default:
+		return "", fmt.Errorf("unsupported filter type %T", filter)
+	}
+}
+
+func (b *Builder) buildFilters(filters []Filter) ([]string, error) {
+	result := make([]string, len(filters))
+	for i, filter := range filters {
+		var err error
+		if result[i], err = b.buildFilter(filter); err != nil {
+			return nil, err
+		}
+	}
+
+	return result, nil
+}
+
+// ParseOffsetToken parses the pagination token of the stores which paginate by offset
+func ParseOffsetToken(token string) (int, error) {
+	if token == "" {
+		return 0, nil
+	}
+
+	offset, err := strconv.Atoi(token)
+	if err != nil || offset < 0 {
+		return 0, fmt.Errorf("invalid pagination token %s", token)
+	}
+
+	return offset, nil
+}
+
+// NextOffsetToken returns the pagination token following a page of count values read from offset,
+// or an empty token when there is no next page
+func NextOffsetToken(offset, count, limit int) string {
+	if limit <= 0 || count < limit {
+		return ""
+	}
+
+	return strconv.Itoa(offset + count)
+}
@@ -0,0 +1,136 @@
+// ------------------------------------------------------------
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT License.
+// ------------------------------------------------------------
+
+package query
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// printer translates the queries to a readable text
+type printer struct {
+	query string
+}
+
+func (p *printer) VisitEQ(f *EQ) (string, error) {
+	return fmt.Sprintf("%s == %v", f.Key, f.Val), nil
+}
+
+func (p *printer) VisitIN(f *IN) (string, error) {
+	return fmt.Sprintf("%s in %v", f.Key, f.Vals), nil
+}
+
+func (p *printer) VisitAND(filters []string) (string, error) {
+	return "(" + strings.Join(filters, " and ") + ")", nil
+}
+
+func (p *printer) VisitOR(filters []string) (string, error) {
+	return "(" + strings.Join(filters, " or ") + ")", nil
+}
+
+func (p *printer) Finalize(filter string, q *Query) error {
+	p.query = filter
+	for _, s := range q.Sort {
+		p.query += fmt.Sprintf(" sort %s %s", s.Key, s.Order)
+	}
+	if q.Page.Limit > 0 {
+		p.query += fmt.Sprintf(" limit %d", q.Page.Limit)
+	}
+
+	return nil
+}
+
+func TestQuery(t *testing.T) {
+	t.Run("full query", func(t *testing.T) {
+		var q Query
+		err := json.Unmarshal([]byte(`{
+			"filter": {
+				"OR": [
+					{"EQ": {"person.org": "Dev Ops"}},
+					{"AND": [{"EQ": {"person.org": "Finance"}}, {"IN": {"state": ["CA", "WA"]}}]}
+				]
+			},
+			"sort": [{"key": "state", "order": "DESC"}, {"key": "person.id"}],
+			"page": {"limit": 2, "token": "2"}
+		}`), &q)
+		require.NoError(t, err)
+
+		assert.Equal(t, &OR{Filters: []Filter{
+			&EQ{Key: "person.org", Val: "Dev Ops"},
+			&AND{Filters: []Filter{
+				&EQ{Key: "person.org", Val: "Finance"},
+				&IN{Key: "state", Vals: []interface{}{"CA", "WA"}},
+			}},
+		}}, q.Filter)
+		assert.Equal(t, []Sorting{{Key: "state", Order: DESC}, {Key: "person.id", Order: ASC}}, q.Sort)
+		assert.Equal(t, Pagination{Limit: 2, Token: "2"}, q.Page)
+
+		p := &printer{}
+		require.NoError(t, NewQueryBuilder(p).BuildQuery(&q))
+		assert.Equal(t, "(person.org == Dev Ops or (person.org == Finance and state in [CA WA])) sort state DESC sort person.id ASC limit 2", p.query)
+	})
+
+	t.Run("empty query", func(t *testing.T) {
+		var q Query
+		require.NoError(t, json.Unmarshal([]byte(`{}`), &q))
+		assert.Nil(t, q.Filter)
+
+		p := &printer{}
+		require.NoError(t, NewQueryBuilder(p).BuildQuery(&q))
+		assert.Equal(t, "", p.query)
+	})
+
+	invalid := map[string]string{
+		"several operations":  `{"filter": {"EQ": {"a": 1}, "IN": {"b": [1]}}}`,
+		"unknown operation":   `{"filter": {"GT": {"a": 1}}}`,
+		"EQ with an object":   `{"filter": {"EQ": {"a": {"b": 1}}}}`,
+		"EQ with two keys":    `{"filter": {"EQ": {"a": 1, "b": 2}}}`,
+		"IN without values":   `{"filter": {"IN": {"a": []}}}`,
+		"IN with a scalar":    `{"filter": {"IN": {"a": 1}}}`,
+		"AND without filters": `{"filter": {"AND": []}}`,
+		"OR with a scalar":    `{"filter": {"OR": [1]}}`,
+		"empty key":           `{"filter": {"EQ": {"": 1}}}`,
+		"empty field":         `{"filter": {"EQ": {"a..b": 1}}}`,
+		"sort order":          `{"sort": [{"key": "a", "order": "UP"}]}`,
+		"sort without key":    `{"sort": [{"order": "ASC"}]}`,
+		"negative limit":      `{"page": {"limit": -1}}`,
+	}
+	for name, data := range invalid {
+		data := data
+		t.Run("invalid "+name, func(t *testing.T) {
+			var q Query
+			assert.Error(t, json.Unmarshal([]byte(data), &q))
+		})
+	}
+}
+
+func TestOffsetToken(t *testing.T) {
+	t.Run("parse", func(t *testing.T) {
+		offset, err := ParseOffsetToken("")
+		assert.NoError(t, err)
+		assert.Equal(t, 0, offset)
+
+		offset, err = ParseOffsetToken("20")
+		assert.NoError(t, err)
+		assert.Equal(t, 20, offset)
+
+		_, err = ParseOffsetToken("-1")
+		assert.Error(t, err)
+		_, err = ParseOffsetToken("next")
+		assert.Error(t, err)
+	})
+
+	t.Run("next", func(t *testing.T) {
+		assert.Equal(t, "30", NextOffsetToken(20, 10, 10))
+		assert.Equal(t, "", NextOffsetToken(20, 5, 10))
+		assert.Equal(t, "", NextOffsetToken(0, 10, 0))
+	})
+}
@@ -5,14 +5,35 @@
 
 package redis
 
-import "time"
+import (
+	"strings"
+	"time"
+
+	"github.com/dapr/components-contrib/state"
+)
 
 type metadata struct {
 	host               string
+	redisType          string
 	password           string
 	sentinelMasterName string
 	maxRetries         int
 	maxRetryBackoff    time.Duration
 	enableTLS          bool
 	failover           bool
+	queryIndexName     string
+	queryIndexes       []queryIndex
+	bulk               state.BulkConfig
+}
+
+// hosts returns the addresses of the comma separated host, which lists the nodes of a cluster or the sentinels
+func (m metadata) hosts() []string {
+	var hosts []string
+	for _, h := range strings.Split(m.host, ",") {
+		if h = strings.TrimSpace(h); h != "" {
+			hosts = append(hosts, h)
+		}
+	}
+
+	return hosts
 }
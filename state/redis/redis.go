@@ -15,6 +15,7 @@ import (
 	"time"
 
 	"github.com/dapr/components-contrib/state"
+	"github.com/dapr/components-contrib/state/query"
 	"github.com/dapr/components-contrib/state/utils"
 	"github.com/dapr/dapr/pkg/logger"
 	redis "github.com/go-redis/redis/v7"
@@ -22,29 +23,43 @@ import (
 )
 
 const (
-	setQuery                 = "local var1 = redis.pcall(\"HGET\", KEYS[1], \"version\"); if type(var1) == \"table\" then redis.call(\"DEL\", KEYS[1]); end; if not var1 or type(var1)==\"table\" or var1 == \"\" or var1 == ARGV[1] or ARGV[1] == \"0\" then redis.call(\"HSET\", KEYS[1], \"data\", ARGV[2]) return redis.call(\"HINCRBY\", KEYS[1], \"version\", 1) else return error(\"failed to set key \" .. KEYS[1]) end"
+	setQuery                 = "local var1 = redis.pcall(\"HGET\", KEYS[1], \"version\"); if type(var1) == \"table\" then redis.call(\"DEL\", KEYS[1]); end; if not var1 or type(var1)==\"table\" or var1 == \"\" or var1 == ARGV[1] or ARGV[1] == \"0\" then redis.call(\"HSET\", KEYS[1], \"data\", ARGV[2]); local ver = redis.call(\"HINCRBY\", KEYS[1], \"version\", 1); local ttl = tonumber(ARGV[3]); if ttl > 0 then redis.call(\"EXPIRE\", KEYS[1], ttl) else redis.call(\"PERSIST\", KEYS[1]) end; return ver else return error(\"failed to set key \" .. KEYS[1]) end"
+	setIndexedQuery          = "local var1 = redis.pcall(\"HGET\", KEYS[1], \"version\"); if type(var1) == \"table\" then redis.call(\"DEL\", KEYS[1]); end; if not var1 or type(var1)==\"table\" or var1 == \"\" or var1 == ARGV[1] or ARGV[1] == \"0\" then for _, f in ipairs(redis.call(\"HKEYS\", KEYS[1])) do if string.sub(f, 1, 4) == \"idx:\" then redis.call(\"HDEL\", KEYS[1], f) end end; redis.call(\"HSET\", KEYS[1], \"data\", ARGV[2]); for i = 4, #ARGV, 2 do redis.call(\"HSET\", KEYS[1], ARGV[i], ARGV[i+1]) end; local ver = redis.call(\"HINCRBY\", KEYS[1], \"version\", 1); local ttl = tonumber(ARGV[3]); if ttl > 0 then redis.call(\"EXPIRE\", KEYS[1], ttl) else redis.call(\"PERSIST\", KEYS[1]) end; return ver else return error(\"failed to set key \" .. KEYS[1]) end"
 	delQuery                 = "local var1 = redis.pcall(\"HGET\", KEYS[1], \"version\"); if not var1 or type(var1)==\"table\" or var1 == ARGV[1] or var1 == \"\" or ARGV[1] == \"0\" then return redis.call(\"DEL\", KEYS[1]) else return error(\"failed to delete \" .. KEYS[1]) end"
 	connectedSlavesReplicas  = "connected_slaves:"
 	infoReplicationDelimiter = "\r\n"
 	host                     = "redisHost"
+	redisType                = "redisType"
 	password                 = "redisPassword"
 	enableTLS                = "enableTLS"
 	maxRetries               = "maxRetries"
 	maxRetryBackoff          = "maxRetryBackoff"
 	failover                 = "failover"
 	sentinelMasterName       = "sentinelMasterName"
+	queryIndexName           = "queryIndexName"
+	queryIndexes             = "queryIndexes"
 	defaultBase              = 10
 	defaultBitSize           = 0
 	defaultDB                = 0
 	defaultMaxRetries        = 3
 	defaultMaxRetryBackoff   = time.Second * 2
 	defaultEnableTLS         = false
+	defaultQueryIndexName    = "dapr-state-query"
+	nodeRedisType            = "node"
+	clusterRedisType         = "cluster"
 )
 
+// redisClient is the client of a single node, of the master of a sentinel deployment or of a cluster
+type redisClient interface {
+	redis.Cmdable
+	DoContext(ctx context.Context, args ...interface{}) *redis.Cmd
+	PSubscribe(channels ...string) *redis.PubSub
+	Close() error
+}
+
 // StateStore is a Redis state store
 type StateStore struct {
-	state.DefaultBulkStore
-	client   *redis.Client
+	client   redisClient
 	json     jsoniter.API
 	metadata metadata
 	replicas int
@@ -58,7 +73,6 @@ func NewRedisStateStore(logger logger.Logger) *StateStore {
 		json:   jsoniter.ConfigFastest,
 		logger: logger,
 	}
-	s.DefaultBulkStore = state.NewDefaultBulkStore(s)
 
 	return s
 }
@@ -76,6 +90,14 @@ func parseRedisMetadata(meta state.Metadata) (metadata, error) {
 		m.password = val
 	}
 
+	m.redisType = nodeRedisType
+	if val, ok := meta.Properties[redisType]; ok && val != "" {
+		if val != nodeRedisType && val != clusterRedisType {
+			return m, fmt.Errorf("redis store error: invalid redisType %s, it must be %s or %s", val, nodeRedisType, clusterRedisType)
+		}
+		m.redisType = val
+	}
+
 	m.enableTLS = defaultEnableTLS
 	if val, ok := meta.Properties[enableTLS]; ok && val != "" {
 		tls, err := strconv.ParseBool(val)
@@ -120,6 +142,34 @@ func parseRedisMetadata(meta state.Metadata) (metadata, error) {
 		}
 	}
 
+	m.queryIndexName = defaultQueryIndexName
+	if val, ok := meta.Properties[queryIndexName]; ok && val != "" {
+		m.queryIndexName = val
+	}
+
+	if val, ok := meta.Properties[queryIndexes]; ok && val != "" {
+		indexes, err := parseQueryIndexes(val)
+		if err != nil {
+			return m, err
+		}
+		m.queryIndexes = indexes
+	}
+
+	if m.redisType == clusterRedisType {
+		if m.failover {
+			return m, errors.New("redis store error: failover is not supported with the cluster redisType")
+		}
+		if len(m.queryIndexes) > 0 {
+			return m, errors.New("redis store error: query indexes are not supported with the cluster redisType")
+		}
+	}
+
+	bulk, err := state.ParseBulkConfig(meta.Properties)
+	if err != nil {
+		return m, fmt.Errorf("redis store error: %s", err)
+	}
+	m.bulk = bulk
+
 	return m, nil
 }
 
@@ -131,9 +181,12 @@ func (r *StateStore) Init(metadata state.Metadata) error {
 	}
 	r.metadata = m
 
-	if r.metadata.failover {
+	switch {
+	case r.metadata.redisType == clusterRedisType:
+		r.client = r.newClusterClient(m)
+	case r.metadata.failover:
 		r.client = r.newFailoverClient(m)
-	} else {
+	default:
 		r.client = r.newClient(m)
 	}
 
@@ -141,9 +194,31 @@ func (r *StateStore) Init(metadata state.Metadata) error {
 		return fmt.Errorf("redis store: error connecting to redis at %s: %s", m.host, err)
 	}
 
-	r.replicas, err = r.getConnectedSlaves()
+	// WAIT only waits for the replicas of the node it is sent to, which is not the node of the writes in a cluster
+	if r.metadata.redisType != clusterRedisType {
+		r.replicas, err = r.getConnectedSlaves()
+		if err != nil {
+			return err
+		}
+	}
+
+	if len(r.metadata.queryIndexes) > 0 {
+		return r.createQueryIndex()
+	}
 
-	return err
+	return nil
+}
+
+// createQueryIndex creates the RediSearch index of the query indexes, unless it exists.
+// The index is to be dropped when the query indexes change.
+func (r *StateStore) createQueryIndex() error {
+	args := createIndexArgs(r.metadata.queryIndexName, r.metadata.queryIndexes)
+	_, err := r.client.DoContext(context.Background(), args...).Result()
+	if err != nil && !strings.Contains(err.Error(), "Index already exists") {
+		return fmt.Errorf("redis store error: can't create query index %s: %s", r.metadata.queryIndexName, err)
+	}
+
+	return nil
 }
 
 func (r *StateStore) newClient(m metadata) *redis.Client {
@@ -166,10 +241,13 @@ func (r *StateStore) newClient(m metadata) *redis.Client {
 	return redis.NewClient(opts)
 }
 
+// newFailoverClient returns the client of the master monitored by the sentinels of the host. The client asks the
+// sentinels for the new master when it fails over.
 func (r *StateStore) newFailoverClient(m metadata) *redis.Client {
 	opts := &redis.FailoverOptions{
-		MasterName:      r.metadata.sentinelMasterName,
-		SentinelAddrs:   []string{r.metadata.host},
+		MasterName:      m.sentinelMasterName,
+		SentinelAddrs:   m.hosts(),
+		Password:        m.password,
 		DB:              defaultDB,
 		MaxRetries:      m.maxRetries,
 		MaxRetryBackoff: m.maxRetryBackoff,
@@ -185,6 +263,25 @@ func (r *StateStore) newFailoverClient(m metadata) *redis.Client {
 	return redis.NewFailoverClient(opts)
 }
 
+// newClusterClient returns the client of the cluster of the nodes of the host, which follows the moved slots
+func (r *StateStore) newClusterClient(m metadata) *redis.ClusterClient {
+	opts := &redis.ClusterOptions{
+		Addrs:           m.hosts(),
+		Password:        m.password,
+		MaxRetries:      m.maxRetries,
+		MaxRetryBackoff: m.maxRetryBackoff,
+	}
+
+	/* #nosec */
+	if m.enableTLS {
+		opts.TLSConfig = &tls.Config{
+			InsecureSkipVerify: m.enableTLS,
+		}
+	}
+
+	return redis.NewClusterClient(opts)
+}
+
 func (r *StateStore) getConnectedSlaves() (int, error) {
 	res, err := r.client.DoContext(context.Background(), "INFO", "replication").Result()
 	if err != nil {
@@ -262,6 +359,12 @@ func (r *StateStore) Get(req *state.GetRequest) (*state.GetResponse, error) {
 	if res == nil {
 		return &state.GetResponse{}, nil
 	}
+
+	return r.parseHash(res)
+}
+
+// parseHash returns the value of the result of the HGETALL command on a key
+func (r *StateStore) parseHash(res interface{}) (*state.GetResponse, error) {
 	vals := res.([]interface{})
 	if len(vals) == 0 {
 		return &state.GetResponse{}, nil
@@ -288,9 +391,14 @@ func (r *StateStore) setValue(req *state.SetRequest) error {
 		return err
 	}
 
+	ttl, err := state.ParseTTL(req.Metadata)
+	if err != nil {
+		return fmt.Errorf("failed to parse ttl from metadata: %s", err)
+	}
+
 	bt, _ := utils.Marshal(req.Value, r.json.Marshal)
 
-	_, err = r.client.DoContext(context.Background(), "EVAL", setQuery, 1, req.Key, ver, bt).Result()
+	_, err = r.client.DoContext(context.Background(), r.setArgs(req.Key, ver, bt, ttl)...).Result()
 	if err != nil {
 		return fmt.Errorf("failed to set key %s: %s", req.Key, err)
 	}
@@ -310,24 +418,25 @@ func (r *StateStore) Set(req *state.SetRequest) error {
 	return state.SetWithOptions(r.setValue, req)
 }
 
-// Multi performs a transactional operation. succeeds only if all operations succeed, and fails if one or more operations fail
+// Multi performs a transactional operation. succeeds only if all operations succeed, and fails if one or more operations fail.
+// In a cluster the keys of a transaction must be in the same hash slot, which hash tags such as {order-1} ensure.
 func (r *StateStore) Multi(request *state.TransactionalStateRequest) error {
+	if r.metadata.redisType == clusterRedisType {
+		if err := checkHashSlot(request.Operations); err != nil {
+			return err
+		}
+	}
+
 	pipe := r.client.TxPipeline()
 	for _, o := range request.Operations {
 		if o.Operation == state.Upsert {
 			req := o.Request.(state.SetRequest)
-			ver, err := r.parseETag(&req)
-			if err != nil {
+			if err := r.queueSet(pipe, &req); err != nil {
 				return err
 			}
-			bt, _ := utils.Marshal(req.Value, r.json.Marshal)
-			pipe.Do("EVAL", setQuery, 1, req.Key, ver, bt)
 		} else if o.Operation == state.Delete {
 			req := o.Request.(state.DeleteRequest)
-			if req.ETag == "" {
-				req.ETag = "0"
-			}
-			pipe.Do("EVAL", delQuery, 1, req.Key, req.ETag)
+			r.queueDelete(pipe, &req)
 		}
 	}
 
@@ -336,6 +445,124 @@ func (r *StateStore) Multi(request *state.TransactionalStateRequest) error {
 	return err
 }
 
+// queueSet adds the command which sets a value, and its time to live, to a pipeline
+func (r *StateStore) queueSet(pipe redis.Pipeliner, req *state.SetRequest) error {
+	ver, err := r.parseETag(req)
+	if err != nil {
+		return err
+	}
+	ttl, err := state.ParseTTL(req.Metadata)
+	if err != nil {
+		return fmt.Errorf("failed to parse ttl from metadata: %s", err)
+	}
+	bt, _ := utils.Marshal(req.Value, r.json.Marshal)
+	pipe.Do(r.setArgs(req.Key, ver, bt, ttl)...)
+
+	return nil
+}
+
+// queueDelete adds the command which deletes a value to a pipeline
+func (r *StateStore) queueDelete(pipe redis.Pipeliner, req *state.DeleteRequest) {
+	etag := req.ETag
+	if etag == "" {
+		etag = "0"
+	}
+	pipe.Do("EVAL", delQuery, 1, req.Key, etag)
+}
+
+// Features returns the features of the Redis state store
+func (r *StateStore) Features() []state.Feature {
+	return []state.Feature{state.FeatureTTL}
+}
+
+// setArgs returns the arguments of the EVAL command which sets a value, along with its indexed keys
+// when the store has query indexes. The script sets the time to live in the same step, so that a value is never
+// written without it. A nil ttl, like state.NoExpiry, removes the time to live of an existing value.
+func (r *StateStore) setArgs(key string, ver int, bt []byte, ttl *int) []interface{} {
+	ttlArg := state.NoExpiry
+	if ttl != nil {
+		ttlArg = *ttl
+	}
+
+	if len(r.metadata.queryIndexes) == 0 {
+		return []interface{}{"EVAL", setQuery, 1, key, ver, bt, ttlArg}
+	}
+
+	args := []interface{}{"EVAL", setIndexedQuery, 1, key, ver, bt, ttlArg}
+
+	return append(args, indexFields(r.metadata.queryIndexes, bt)...)
+}
+
+// Query returns the values matching a query with RediSearch, the query filters and sorts on the query indexes only
+func (r *StateStore) Query(req *state.QueryRequest) (*state.QueryResponse, error) {
+	if len(r.metadata.queryIndexes) == 0 {
+		return nil, errors.New("redis store error: queries require query indexes")
+	}
+
+	q := &redisQuery{indexes: r.metadata.queryIndexes}
+	err := query.NewQueryBuilder(q).BuildQuery(&req.Query)
+	if err != nil {
+		return nil, err
+	}
+
+	limit := q.limit
+	if limit == 0 {
+		// RediSearch returns 10 results by default, so the results are counted first to return them all
+		if limit, _, err = r.search(q, 0, 0); err != nil {
+			return nil, err
+		}
+	}
+	_, results, err := r.search(q, q.offset, limit)
+	if err != nil {
+		return nil, err
+	}
+
+	return &state.QueryResponse{
+		Results:  results,
+		Token:    query.NextOffsetToken(q.offset, len(results), q.limit),
+		Metadata: req.Metadata,
+	}, nil
+}
+
+// search returns the total number of results of a query and a page of its results
+func (r *StateStore) search(q *redisQuery, offset, limit int) (int, []state.QueryItem, error) {
+	res, err := r.client.DoContext(context.Background(), q.searchArgs(r.metadata.queryIndexName, offset, limit)...).Result()
+	if err != nil {
+		return 0, nil, fmt.Errorf("redis store error: query failed: %s", err)
+	}
+
+	return r.parseSearchResult(res)
+}
+
+// parseSearchResult parses the reply of FT.SEARCH, which is the total number of results followed by pairs
+// of key and fields
+func (r *StateStore) parseSearchResult(res interface{}) (int, []state.QueryItem, error) {
+	vals, ok := res.([]interface{})
+	if !ok || len(vals) == 0 {
+		return 0, nil, errors.New("redis store error: unexpected query result")
+	}
+	total, ok := vals[0].(int64)
+	if !ok {
+		return 0, nil, errors.New("redis store error: unexpected query result")
+	}
+
+	results := []state.QueryItem{}
+	for i := 1; i+1 < len(vals); i += 2 {
+		item := state.QueryItem{Key: fmt.Sprint(vals[i])}
+		fields, _ := vals[i+1].([]interface{})
+		data, version, err := r.getKeyVersion(fields)
+		if err != nil {
+			item.Error = err.Error()
+		} else {
+			item.Data = []byte(data)
+			item.ETag = version
+		}
+		results = append(results, item)
+	}
+
+	return int(total), results, nil
+}
+
 func (r *StateStore) getKeyVersion(vals []interface{}) (data string, version string, err error) {
 	seenData := false
 	seenVersion := false
@@ -8,6 +8,7 @@ package redis
 import (
 	"context"
 	"testing"
+	"time"
 
 	miniredis "github.com/alicebob/miniredis/v2"
 	"github.com/dapr/components-contrib/state"
@@ -205,6 +206,84 @@ func TestTransactionalDeleteNoEtag(t *testing.T) {
 	assert.Equal(t, 0, len(vals))
 }
 
+func TestSetWithTTL(t *testing.T) {
+	s, c := setupMiniredis()
+	defer s.Close()
+
+	ss := &StateStore{
+		client: c,
+		json:   jsoniter.ConfigFastest,
+		logger: logger.NewLogger("test"),
+	}
+
+	t.Run("ttl expires the key", func(t *testing.T) {
+		err := ss.Set(&state.SetRequest{
+			Key:      "weapon",
+			Value:    "deathstar",
+			Metadata: map[string]string{"ttlInSeconds": "100"},
+		})
+		assert.Equal(t, nil, err)
+		assert.Equal(t, 100*time.Second, s.TTL("weapon"))
+	})
+
+	t.Run("no expiry removes the ttl", func(t *testing.T) {
+		err := ss.Set(&state.SetRequest{
+			Key:      "weapon",
+			Value:    "deathstar",
+			Metadata: map[string]string{"ttlInSeconds": "-1"},
+		})
+		assert.Equal(t, nil, err)
+		assert.Equal(t, time.Duration(0), s.TTL("weapon"))
+	})
+
+	t.Run("no ttl removes the existing ttl", func(t *testing.T) {
+		s.SetTTL("weapon", 100*time.Second)
+		err := ss.Set(&state.SetRequest{
+			Key:   "weapon",
+			Value: "deathstar",
+		})
+		assert.Equal(t, nil, err)
+		assert.Equal(t, time.Duration(0), s.TTL("weapon"))
+	})
+
+	t.Run("failed set keeps the existing ttl", func(t *testing.T) {
+		s.SetTTL("weapon", 100*time.Second)
+		err := ss.Set(&state.SetRequest{
+			Key:      "weapon",
+			Value:    "deathstar",
+			ETag:     "1000",
+			Metadata: map[string]string{"ttlInSeconds": "10"},
+		})
+		assert.Error(t, err)
+		assert.Equal(t, 100*time.Second, s.TTL("weapon"))
+	})
+
+	t.Run("transactional upsert with ttl", func(t *testing.T) {
+		err := ss.Multi(&state.TransactionalStateRequest{
+			Operations: []state.TransactionalStateOperation{{
+				Operation: state.Upsert,
+				Request: state.SetRequest{
+					Key:      "weapon2",
+					Value:    "deathstar",
+					Metadata: map[string]string{"ttlInSeconds": "50"},
+				},
+			}},
+		})
+		assert.Equal(t, nil, err)
+		assert.Equal(t, 50*time.Second, s.TTL("weapon2"))
+	})
+
+	t.Run("invalid ttl", func(t *testing.T) {
+		err := ss.Set(&state.SetRequest{
+			Key:      "weapon3",
+			Value:    "deathstar",
+			Metadata: map[string]string{"ttlInSeconds": "soon"},
+		})
+		assert.Error(t, err)
+		assert.False(t, s.Exists("weapon3"))
+	})
+}
+
 func setupMiniredis() (*miniredis.Miniredis, *redis.Client) {
 	s, err := miniredis.Run()
 	if err != nil {
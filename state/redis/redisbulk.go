@@ -0,0 +1,120 @@
+// ------------------------------------------------------------
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT License.
+// ------------------------------------------------------------
+
+package redis
+
+import (
+	"github.com/dapr/components-contrib/state"
+	redis "github.com/go-redis/redis/v7"
+	"github.com/hashicorp/go-multierror"
+)
+
+// BulkGet retrieves the values of the keys with pipelines of HGETALL commands, in batches of at most maxBulkBatchSize keys
+func (r *StateStore) BulkGet(req []state.GetRequest) (bool, []state.BulkGetResponse, error) {
+	res := make([]state.BulkGetResponse, len(req))
+	err := r.metadata.bulk.RunBatches(len(req), 0, func(b state.Batch) error {
+		pipe := r.client.Pipeline()
+		cmds := make([]*redis.Cmd, 0, b.End-b.Start)
+		for i := b.Start; i < b.End; i++ {
+			cmds = append(cmds, pipe.Do("HGETALL", req[i].Key))
+		}
+		// the errors are those of the commands, which are handled one by one
+		pipe.Exec()
+
+		for j, cmd := range cmds {
+			i := b.Start + j
+			res[i] = r.bulkGetResponse(&req[i], cmd)
+		}
+
+		return nil
+	})
+
+	return true, res, err
+}
+
+func (r *StateStore) bulkGetResponse(req *state.GetRequest, cmd *redis.Cmd) state.BulkGetResponse {
+	res := state.BulkGetResponse{Key: req.Key}
+
+	var (
+		resp *state.GetResponse
+		err  error
+	)
+	if val, cmdErr := cmd.Result(); cmdErr != nil {
+		// the values saved before the ETags are plain strings
+		resp, err = r.directGet(req)
+	} else {
+		resp, err = r.parseHash(val)
+	}
+	if err != nil {
+		res.Error = err.Error()
+
+		return res
+	}
+	res.Data = resp.Data
+	res.ETag = resp.ETag
+
+	return res
+}
+
+// BulkSet saves the values with pipelines, in batches of at most maxBulkBatchSize values
+func (r *StateStore) BulkSet(req []state.SetRequest) error {
+	for i := range req {
+		if err := state.CheckRequestOptions(req[i].Options); err != nil {
+			return err
+		}
+	}
+
+	return r.metadata.bulk.RunBatches(len(req), 0, func(b state.Batch) error {
+		pipe := r.client.Pipeline()
+		strong := false
+		for i := b.Start; i < b.End; i++ {
+			if err := r.queueSet(pipe, &req[i]); err != nil {
+				pipe.Close()
+
+				return err
+			}
+			strong = strong || req[i].Options.Consistency == state.Strong
+		}
+		if strong && r.replicas > 0 {
+			pipe.Do("WAIT", r.replicas, 1000)
+		}
+
+		cmds, _ := pipe.Exec()
+
+		return pipelineErrors(cmds)
+	})
+}
+
+// BulkDelete deletes the keys with pipelines, in batches of at most maxBulkBatchSize keys
+func (r *StateStore) BulkDelete(req []state.DeleteRequest) error {
+	for i := range req {
+		if err := state.CheckRequestOptions(req[i].Options); err != nil {
+			return err
+		}
+	}
+
+	return r.metadata.bulk.RunBatches(len(req), 0, func(b state.Batch) error {
+		pipe := r.client.Pipeline()
+		for i := b.Start; i < b.End; i++ {
+			r.queueDelete(pipe, &req[i])
+		}
+
+		cmds, _ := pipe.Exec()
+
+		return pipelineErrors(cmds)
+	})
+}
+
+// pipelineErrors returns the errors of the commands of a pipeline, which doesn't stop on the first error
+func pipelineErrors(cmds []redis.Cmder) error {
+	var errs error
+	for _, cmd := range cmds {
+		if err := cmd.Err(); err != nil && err != redis.Nil {
+			errs = multierror.Append(errs, err)
+		}
+	}
+
+	return errs
+}
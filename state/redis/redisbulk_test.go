@@ -0,0 +1,80 @@
+// ------------------------------------------------------------
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT License.
+// ------------------------------------------------------------
+
+package redis
+
+import (
+	"testing"
+	"time"
+
+	"github.com/dapr/components-contrib/state"
+	"github.com/dapr/dapr/pkg/logger"
+	jsoniter "github.com/json-iterator/go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBulkOperations(t *testing.T) {
+	s, c := setupMiniredis()
+	defer s.Close()
+
+	ss := &StateStore{
+		client:   c,
+		json:     jsoniter.ConfigFastest,
+		metadata: metadata{bulk: state.BulkConfig{MaxBatchSize: 2, Parallelism: 2}},
+		logger:   logger.NewLogger("test"),
+	}
+
+	t.Run("bulk set", func(t *testing.T) {
+		err := ss.BulkSet([]state.SetRequest{
+			{Key: "weapon1", Value: "deathstar"},
+			{Key: "weapon2", Value: "lightsaber", Metadata: map[string]string{"ttlInSeconds": "100"}},
+			{Key: "weapon3", Value: "blaster"},
+		})
+
+		assert.NoError(t, err)
+		assert.Equal(t, 100*time.Second, s.TTL("weapon2"))
+	})
+
+	t.Run("bulk get", func(t *testing.T) {
+		require.NoError(t, s.Set("legacy", `"x-wing"`))
+
+		supported, res, err := ss.BulkGet([]state.GetRequest{
+			{Key: "weapon1"}, {Key: "weapon2"}, {Key: "weapon3"}, {Key: "missing"}, {Key: "legacy"},
+		})
+
+		assert.NoError(t, err)
+		assert.True(t, supported)
+		assert.Equal(t, []state.BulkGetResponse{
+			{Key: "weapon1", Data: []byte(`"deathstar"`), ETag: "1"},
+			{Key: "weapon2", Data: []byte(`"lightsaber"`), ETag: "1"},
+			{Key: "weapon3", Data: []byte(`"blaster"`), ETag: "1"},
+			{Key: "missing"},
+			{Key: "legacy", Data: []byte(`"x-wing"`)},
+		}, res)
+	})
+
+	t.Run("bulk set reports the etag mismatches", func(t *testing.T) {
+		err := ss.BulkSet([]state.SetRequest{
+			{Key: "weapon1", Value: "deathstar2", ETag: "1"},
+			{Key: "weapon2", Value: "lightsaber2", ETag: "5"},
+		})
+
+		assert.Error(t, err)
+		assert.Equal(t, "2", s.HGet("weapon1", "version"))
+		assert.Equal(t, "1", s.HGet("weapon2", "version"))
+	})
+
+	t.Run("bulk delete", func(t *testing.T) {
+		err := ss.BulkDelete([]state.DeleteRequest{
+			{Key: "weapon1"}, {Key: "weapon2", ETag: "1"}, {Key: "weapon3"},
+		})
+
+		assert.NoError(t, err)
+		assert.False(t, s.Exists("weapon1"))
+		assert.False(t, s.Exists("weapon2"))
+		assert.False(t, s.Exists("weapon3"))
+	})
+}
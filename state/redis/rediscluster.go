@@ -0,0 +1,67 @@
+// ------------------------------------------------------------
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT License.
+// ------------------------------------------------------------
+
+package redis
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/dapr/components-contrib/state"
+)
+
+// hashSlots is the number of hash slots of a Redis Cluster
+const hashSlots = 16384
+
+// checkHashSlot returns an error unless the keys of the operations of a transaction are in the same hash slot,
+// the cluster rejects the other transactions.
+func checkHashSlot(operations []state.TransactionalStateOperation) error {
+	first := ""
+	for _, o := range operations {
+		req, ok := o.Request.(state.KeyInt)
+		if !ok {
+			continue
+		}
+		key := req.GetKey()
+		if first == "" {
+			first = key
+
+			continue
+		}
+		if hashSlot(key) != hashSlot(first) {
+			return fmt.Errorf("redis store error: keys %s and %s of the transaction are in different hash slots of the cluster, hash tags such as {tag} put keys in the same slot", first, key)
+		}
+	}
+
+	return nil
+}
+
+// hashSlot returns the hash slot of a key, which is the hash of its hash tag when it has one
+func hashSlot(key string) int {
+	if start := strings.IndexByte(key, '{'); start >= 0 {
+		if end := strings.IndexByte(key[start+1:], '}'); end > 0 {
+			key = key[start+1 : start+1+end]
+		}
+	}
+
+	return int(crc16([]byte(key))) % hashSlots
+}
+
+// crc16 is the CRC16-CCITT (XMODEM) checksum of Redis Cluster
+func crc16(b []byte) uint16 {
+	var crc uint16
+	for _, c := range b {
+		crc ^= uint16(c) << 8
+		for i := 0; i < 8; i++ {
+			if crc&0x8000 != 0 {
+				crc = crc<<1 ^ 0x1021
+			} else {
+				crc <<= 1
+			}
+		}
+	}
+
+	return crc
+}
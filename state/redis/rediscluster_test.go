@@ -0,0 +1,111 @@
+// ------------------------------------------------------------
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT License.
+// ------------------------------------------------------------
+
+package redis
+
+import (
+	"testing"
+
+	"github.com/dapr/components-contrib/state"
+	"github.com/dapr/dapr/pkg/logger"
+	jsoniter "github.com/json-iterator/go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHashSlot(t *testing.T) {
+	assert.Equal(t, uint16(0x31C3), crc16([]byte("123456789")))
+	assert.Equal(t, 12182, hashSlot("foo"))
+	assert.Equal(t, 5061, hashSlot("bar"))
+
+	t.Run("the hash tag is hashed", func(t *testing.T) {
+		assert.Equal(t, hashSlot("user1000"), hashSlot("{user1000}.following"))
+		assert.Equal(t, hashSlot("user1000"), hashSlot("app||{user1000}.followers"))
+	})
+
+	t.Run("empty hash tags are ignored", func(t *testing.T) {
+		assert.Equal(t, int(crc16([]byte("foo{}{bar}"))%hashSlots), hashSlot("foo{}{bar}"))
+	})
+
+	t.Run("the first hash tag is hashed", func(t *testing.T) {
+		assert.Equal(t, hashSlot("{bar"), hashSlot("foo{{bar}}zap"))
+	})
+}
+
+func TestCheckHashSlot(t *testing.T) {
+	t.Run("same hash tag", func(t *testing.T) {
+		err := checkHashSlot([]state.TransactionalStateOperation{
+			{Operation: state.Upsert, Request: state.SetRequest{Key: "{order-1}.items"}},
+			{Operation: state.Delete, Request: state.DeleteRequest{Key: "{order-1}.draft"}},
+		})
+
+		assert.NoError(t, err)
+	})
+
+	t.Run("different hash slots", func(t *testing.T) {
+		err := checkHashSlot([]state.TransactionalStateOperation{
+			{Operation: state.Upsert, Request: state.SetRequest{Key: "foo"}},
+			{Operation: state.Upsert, Request: state.SetRequest{Key: "bar"}},
+		})
+
+		assert.Error(t, err)
+	})
+}
+
+func TestClusterMulti(t *testing.T) {
+	s, c := setupMiniredis()
+	defer s.Close()
+
+	ss := &StateStore{
+		client:   c,
+		json:     jsoniter.ConfigFastest,
+		metadata: metadata{redisType: clusterRedisType},
+		logger:   logger.NewLogger("test"),
+	}
+
+	err := ss.Multi(&state.TransactionalStateRequest{
+		Operations: []state.TransactionalStateOperation{
+			{Operation: state.Upsert, Request: state.SetRequest{Key: "foo", Value: "deathstar"}},
+			{Operation: state.Upsert, Request: state.SetRequest{Key: "bar", Value: "lightsaber"}},
+		},
+	})
+
+	assert.Error(t, err)
+	assert.False(t, s.Exists("foo"))
+}
+
+func TestParseRedisType(t *testing.T) {
+	t.Run("node by default", func(t *testing.T) {
+		m, err := parseRedisMetadata(state.Metadata{Properties: map[string]string{host: "localhost:6379"}})
+
+		require.NoError(t, err)
+		assert.Equal(t, nodeRedisType, m.redisType)
+	})
+
+	t.Run("cluster nodes", func(t *testing.T) {
+		m, err := parseRedisMetadata(state.Metadata{Properties: map[string]string{
+			host:      "redis-0:6379, redis-1:6379,redis-2:6379",
+			redisType: clusterRedisType,
+		}})
+
+		require.NoError(t, err)
+		assert.Equal(t, clusterRedisType, m.redisType)
+		assert.Equal(t, []string{"redis-0:6379", "redis-1:6379", "redis-2:6379"}, m.hosts())
+	})
+
+	invalid := map[string]map[string]string{
+		"unknown type":           {host: "localhost:6379", redisType: "ring"},
+		"cluster with failover":  {host: "localhost:6379", redisType: clusterRedisType, failover: "true", sentinelMasterName: "master"},
+		"cluster with the query": {host: "localhost:6379", redisType: clusterRedisType, queryIndexes: `[{"key": "age", "type": "NUMERIC"}]`},
+	}
+	for name, properties := range invalid {
+		properties := properties
+		t.Run(name, func(t *testing.T) {
+			_, err := parseRedisMetadata(state.Metadata{Properties: properties})
+
+			assert.Error(t, err)
+		})
+	}
+}
@@ -0,0 +1,87 @@
+// ------------------------------------------------------------
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT License.
+// ------------------------------------------------------------
+
+package redis
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+
+	"github.com/dapr/components-contrib/state"
+	"github.com/go-redis/redis/v7"
+)
+
+// List returns a page of the keys with a prefix with SCAN, whose cursor is the token of the next page. The limit is
+// the COUNT hint of SCAN, so a page has about limit keys, and may have none before the last page. The cluster
+// redisType is not supported, as SCAN enumerates the keys of a single node.
+func (r *StateStore) List(req *state.ListRequest) (*state.ListResponse, error) {
+	if r.metadata.redisType == clusterRedisType {
+		return nil, errors.New("redis store error: list is not supported with the cluster redisType")
+	}
+
+	var cursor uint64
+	if req.Token != "" {
+		var err error
+		if cursor, err = strconv.ParseUint(req.Token, 10, 64); err != nil {
+			return nil, fmt.Errorf("redis store error: invalid list token %s", req.Token)
+		}
+	}
+
+	keys, next, err := r.client.Scan(cursor, globEscaper.Replace(req.Prefix)+"*", int64(req.PageLimit())).Result()
+	if err != nil {
+		return nil, fmt.Errorf("redis store error: list failed: %s", err)
+	}
+	items, err := r.getValues(keys)
+	if err != nil {
+		return nil, fmt.Errorf("redis store error: list failed: %s", err)
+	}
+
+	res := &state.ListResponse{Items: items, Metadata: req.Metadata}
+	if next != 0 {
+		res.Token = strconv.FormatUint(next, 10)
+	}
+
+	return res, nil
+}
+
+// getValues reads the values of keys with a pipeline of HGETALL commands, without the keys deleted since they were
+// scanned. As with Get, the values which are not hashes are read with GET.
+func (r *StateStore) getValues(keys []string) ([]state.QueryItem, error) {
+	pipe := r.client.Pipeline()
+	cmds := make([]*redis.Cmd, len(keys))
+	for i, key := range keys {
+		cmds[i] = pipe.Do("HGETALL", key)
+	}
+	// the errors of the commands are handled one by one
+	pipe.Exec()
+
+	items := make([]state.QueryItem, 0, len(keys))
+	for i, key := range keys {
+		res, err := r.hashOrDirectGet(key, cmds[i])
+		if err != nil {
+			return nil, err
+		}
+		if res.Data == nil {
+			continue
+		}
+		items = append(items, state.QueryItem{Key: key, Data: res.Data, ETag: res.ETag})
+	}
+
+	return items, nil
+}
+
+// hashOrDirectGet returns the value of the result of HGETALL on a key, or the value read with GET when HGETALL failed
+func (r *StateStore) hashOrDirectGet(key string, cmd *redis.Cmd) (*state.GetResponse, error) {
+	res, err := cmd.Result()
+	if err != nil {
+		return r.directGet(&state.GetRequest{Key: key})
+	}
+	if res == nil {
+		return &state.GetResponse{}, nil
+	}
+
+	return r.parseHash(res)
+}
@@ -0,0 +1,61 @@
+// ------------------------------------------------------------
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT License.
+// ------------------------------------------------------------
+
+package redis
+
+import (
+	"sort"
+	"testing"
+
+	"github.com/dapr/components-contrib/state"
+	"github.com/dapr/dapr/pkg/logger"
+	jsoniter "github.com/json-iterator/go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestList(t *testing.T) {
+	s, c := setupMiniredis()
+	defer s.Close()
+
+	ss := &StateStore{
+		client:   c,
+		json:     jsoniter.ConfigFastest,
+		metadata: metadata{redisType: nodeRedisType},
+		logger:   logger.NewLogger("test"),
+	}
+	require.NoError(t, ss.Set(&state.SetRequest{Key: "order-1", Value: "pending"}))
+	require.NoError(t, ss.Set(&state.SetRequest{Key: "order-2", Value: "shipped"}))
+	require.NoError(t, ss.Set(&state.SetRequest{Key: "cart-1", Value: "empty"}))
+	// a value saved with SET by an earlier version
+	s.Set("order-3", `"delivered"`)
+
+	t.Run("prefix", func(t *testing.T) {
+		res, err := ss.List(&state.ListRequest{Prefix: "order-"})
+		require.NoError(t, err)
+
+		sort.Slice(res.Items, func(i, j int) bool { return res.Items[i].Key < res.Items[j].Key })
+		assert.Equal(t, []state.QueryItem{
+			{Key: "order-1", Data: []byte(`"pending"`), ETag: "1"},
+			{Key: "order-2", Data: []byte(`"shipped"`), ETag: "1"},
+			{Key: "order-3", Data: []byte(`"delivered"`)},
+		}, res.Items)
+		assert.Empty(t, res.Token)
+	})
+
+	t.Run("invalid token", func(t *testing.T) {
+		_, err := ss.List(&state.ListRequest{Token: "next"})
+
+		assert.Error(t, err)
+	})
+
+	t.Run("cluster", func(t *testing.T) {
+		cluster := &StateStore{metadata: metadata{redisType: clusterRedisType}}
+
+		_, err := cluster.List(&state.ListRequest{})
+
+		assert.Error(t, err)
+	})
+}
@@ -0,0 +1,61 @@
+// ------------------------------------------------------------
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT License.
+// ------------------------------------------------------------
+
+package redis
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/dapr/components-contrib/state"
+)
+
+// unlockScript deletes the lock of a resource when it belongs to the owner, it returns 1 when the lock is deleted,
+// 0 when there is no lock and -1 when the lock belongs to another owner
+const unlockScript = `local owner = redis.call("GET", KEYS[1])
+if not owner then
+	return 0
+elseif owner == ARGV[1] then
+	redis.call("DEL", KEYS[1])
+	return 1
+end
+return -1`
+
+// Lock locks a resource with SET NX on the key of its lock, whose value is the owner and which expires with the
+// lock. As with Redlock on a single instance, the lock is lost when a replica is promoted before it is replicated.
+func (r *StateStore) Lock(req *state.LockRequest) (*state.LockResponse, error) {
+	if err := state.CheckLockRequest(req); err != nil {
+		return nil, err
+	}
+
+	ok, err := r.client.SetNX(state.LockKeyPrefix+req.ResourceID, req.LockOwner, time.Duration(req.ExpiryInSeconds)*time.Second).Result()
+	if err != nil {
+		return nil, fmt.Errorf("redis store error: lock failed: %s", err)
+	}
+
+	return &state.LockResponse{Success: ok}, nil
+}
+
+// Unlock deletes the lock of a resource when it belongs to the owner, atomically with a script
+func (r *StateStore) Unlock(req *state.UnlockRequest) error {
+	if err := state.CheckUnlockRequest(req); err != nil {
+		return err
+	}
+
+	res, err := r.client.DoContext(context.Background(), "EVAL", unlockScript, 1, state.LockKeyPrefix+req.ResourceID, req.LockOwner).Int()
+	if err != nil {
+		return fmt.Errorf("redis store error: unlock failed: %s", err)
+	}
+
+	switch res {
+	case 0:
+		return state.ErrLockDoesNotExist
+	case -1:
+		return state.ErrLockBelongsToOthers
+	}
+
+	return nil
+}
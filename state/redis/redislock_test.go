@@ -0,0 +1,54 @@
+// ------------------------------------------------------------
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT License.
+// ------------------------------------------------------------
+
+package redis
+
+import (
+	"testing"
+	"time"
+
+	"github.com/dapr/components-contrib/state"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLock(t *testing.T) {
+	s, c := setupMiniredis()
+	defer s.Close()
+
+	ss := &StateStore{client: c}
+
+	t.Run("lock and unlock", func(t *testing.T) {
+		res, err := ss.Lock(&state.LockRequest{ResourceID: "orders", LockOwner: "worker-1", ExpiryInSeconds: 10})
+		require.NoError(t, err)
+		assert.True(t, res.Success)
+
+		res, err = ss.Lock(&state.LockRequest{ResourceID: "orders", LockOwner: "worker-2", ExpiryInSeconds: 10})
+		require.NoError(t, err)
+		assert.False(t, res.Success, "the resource is locked")
+
+		assert.Equal(t, state.ErrLockBelongsToOthers, ss.Unlock(&state.UnlockRequest{ResourceID: "orders", LockOwner: "worker-2"}))
+		assert.NoError(t, ss.Unlock(&state.UnlockRequest{ResourceID: "orders", LockOwner: "worker-1"}))
+		assert.Equal(t, state.ErrLockDoesNotExist, ss.Unlock(&state.UnlockRequest{ResourceID: "orders", LockOwner: "worker-1"}))
+	})
+
+	t.Run("expiry", func(t *testing.T) {
+		res, err := ss.Lock(&state.LockRequest{ResourceID: "payments", LockOwner: "worker-1", ExpiryInSeconds: 10})
+		require.NoError(t, err)
+		require.True(t, res.Success)
+
+		s.FastForward(11 * time.Second)
+
+		res, err = ss.Lock(&state.LockRequest{ResourceID: "payments", LockOwner: "worker-2", ExpiryInSeconds: 10})
+		require.NoError(t, err)
+		assert.True(t, res.Success, "the lock expired")
+	})
+
+	t.Run("invalid request", func(t *testing.T) {
+		_, err := ss.Lock(&state.LockRequest{ResourceID: "orders", LockOwner: "worker-1"})
+
+		assert.Error(t, err)
+	})
+}
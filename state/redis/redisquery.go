@@ -0,0 +1,258 @@
+// ------------------------------------------------------------
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT License.
+// ------------------------------------------------------------
+
+package redis
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/dapr/components-contrib/state/query"
+)
+
+const (
+	tagIndex     = "TAG"
+	numericIndex = "NUMERIC"
+
+	// indexFieldPrefix prefixes the hash fields which hold the indexed keys of a value
+	indexFieldPrefix = "idx:"
+)
+
+var nonAliasCharacters = regexp.MustCompile(`[^A-Za-z0-9_]`)
+
+// queryIndex is a key of the values indexed by RediSearch. The values are stored as JSON strings,
+// so the indexed keys are copied to fields of their hash when they are set.
+type queryIndex struct {
+	Key  string `json:"key"`
+	Type string `json:"type"`
+	// alias is the name of the key in the RediSearch queries
+	alias string
+}
+
+func parseQueryIndexes(val string) ([]queryIndex, error) {
+	var indexes []queryIndex
+	if err := json.Unmarshal([]byte(val), &indexes); err != nil {
+		return nil, fmt.Errorf("redis store error: can't parse queryIndexes field: %s", err)
+	}
+
+	aliases := make(map[string]string, len(indexes))
+	for i := range indexes {
+		index := &indexes[i]
+		if index.Key == "" {
+			return nil, errors.New("redis store error: missing key of query index")
+		}
+		switch strings.ToUpper(index.Type) {
+		case "", tagIndex:
+			index.Type = tagIndex
+		case numericIndex:
+			index.Type = numericIndex
+		default:
+			return nil, fmt.Errorf("redis store error: invalid type %s of query index %s, it must be %s or %s", index.Type, index.Key, tagIndex, numericIndex)
+		}
+		index.alias = nonAliasCharacters.ReplaceAllString(index.Key, "_")
+		if other, ok := aliases[index.alias]; ok {
+			return nil, fmt.Errorf("redis store error: query indexes %s and %s conflict", other, index.Key)
+		}
+		aliases[index.alias] = index.Key
+	}
+
+	return indexes, nil
+}
+
+// createIndexArgs returns the arguments of the FT.CREATE command which indexes the hash fields of the values
+func createIndexArgs(name string, indexes []queryIndex) []interface{} {
+	args := []interface{}{"FT.CREATE", name, "ON", "HASH", "SCHEMA"}
+	for _, index := range indexes {
+		args = append(args, indexFieldPrefix+index.Key, "AS", index.alias, index.Type, "SORTABLE")
+	}
+
+	return args
+}
+
+// indexFields returns the hash fields of the indexed keys of a value, as pairs of field name and value
+func indexFields(indexes []queryIndex, data []byte) []interface{} {
+	var value interface{}
+	if err := json.Unmarshal(data, &value); err != nil {
+		return nil
+	}
+
+	fields := []interface{}{}
+	for _, index := range indexes {
+		field, ok := keyValue(value, index.Key)
+		if !ok {
+			continue
+		}
+		switch val := field.(type) {
+		case float64:
+			fields = append(fields, indexFieldPrefix+index.Key, formatNumber(val))
+		case string:
+			if index.Type == tagIndex {
+				fields = append(fields, indexFieldPrefix+index.Key, val)
+			}
+		case bool:
+			if index.Type == tagIndex {
+				fields = append(fields, indexFieldPrefix+index.Key, strconv.FormatBool(val))
+			}
+		}
+	}
+
+	return fields
+}
+
+// keyValue returns the value of a dotted key in a JSON value
+func keyValue(value interface{}, key string) (interface{}, bool) {
+	for _, part := range strings.Split(key, ".") {
+		obj, ok := value.(map[string]interface{})
+		if !ok {
+			return nil, false
+		}
+		if value, ok = obj[part]; !ok {
+			return nil, false
+		}
+	}
+
+	return value, true
+}
+
+// redisQuery translates a query to the RediSearch query syntax, on the indexed keys only
+type redisQuery struct {
+	indexes []queryIndex
+	query   string
+	sortBy  []interface{}
+	offset  int
+	limit   int
+}
+
+func (q *redisQuery) VisitEQ(f *query.EQ) (string, error) {
+	index, err := q.index(f.Key)
+	if err != nil {
+		return "", err
+	}
+
+	return index.match([]interface{}{f.Val})
+}
+
+func (q *redisQuery) VisitIN(f *query.IN) (string, error) {
+	index, err := q.index(f.Key)
+	if err != nil {
+		return "", err
+	}
+
+	return index.match(f.Vals)
+}
+
+func (q *redisQuery) VisitAND(filters []string) (string, error) {
+	return "(" + strings.Join(filters, " ") + ")", nil
+}
+
+func (q *redisQuery) VisitOR(filters []string) (string, error) {
+	return "(" + strings.Join(filters, " | ") + ")", nil
+}
+
+func (q *redisQuery) Finalize(filter string, qq *query.Query) error {
+	offset, err := query.ParseOffsetToken(qq.Page.Token)
+	if err != nil {
+		return err
+	}
+	q.offset = offset
+	q.limit = qq.Page.Limit
+
+	q.query = filter
+	if q.query == "" {
+		q.query = "*"
+	}
+
+	q.sortBy = nil
+	switch len(qq.Sort) {
+	case 0:
+	case 1:
+		index, err := q.index(qq.Sort[0].Key)
+		if err != nil {
+			return err
+		}
+		q.sortBy = []interface{}{"SORTBY", index.alias, qq.Sort[0].Order}
+	default:
+		return errors.New("redis store error: queries can sort on one key only")
+	}
+
+	return nil
+}
+
+func (q *redisQuery) index(key string) (*queryIndex, error) {
+	for i := range q.indexes {
+		if q.indexes[i].Key == key {
+			return &q.indexes[i], nil
+		}
+	}
+
+	return nil, fmt.Errorf("redis store error: key %s is not in the query indexes", key)
+}
+
+// match returns the filter matching any of vals
+func (index *queryIndex) match(vals []interface{}) (string, error) {
+	if index.Type == tagIndex {
+		tags := make([]string, len(vals))
+		for i, val := range vals {
+			tags[i] = escapeTag(formatValue(val))
+		}
+
+		return fmt.Sprintf("@%s:{%s}", index.alias, strings.Join(tags, " | ")), nil
+	}
+
+	ranges := make([]string, len(vals))
+	for i, val := range vals {
+		number, err := strconv.ParseFloat(formatValue(val), 64)
+		if err != nil {
+			return "", fmt.Errorf("redis store error: numeric query index %s can't match %v", index.Key, val)
+		}
+		ranges[i] = fmt.Sprintf("@%s:[%s %s]", index.alias, formatNumber(number), formatNumber(number))
+	}
+	if len(ranges) == 1 {
+		return ranges[0], nil
+	}
+
+	return "(" + strings.Join(ranges, " | ") + ")", nil
+}
+
+// searchArgs returns the arguments of the FT.SEARCH command of the query, on a page of the results
+func (q *redisQuery) searchArgs(indexName string, offset, limit int) []interface{} {
+	args := []interface{}{"FT.SEARCH", indexName, q.query, "RETURN", 2, "data", "version"}
+	args = append(args, q.sortBy...)
+
+	return append(args, "LIMIT", offset, limit)
+}
+
+func formatValue(val interface{}) string {
+	switch v := val.(type) {
+	case string:
+		return v
+	case float64:
+		return formatNumber(v)
+	default:
+		return fmt.Sprint(v)
+	}
+}
+
+func formatNumber(val float64) string {
+	return strconv.FormatFloat(val, 'f', -1, 64)
+}
+
+// escapeTag escapes the punctuation and spaces of a tag
+func escapeTag(tag string) string {
+	var b strings.Builder
+	for _, r := range tag {
+		if !unicode.IsLetter(r) && !unicode.IsDigit(r) && r != '_' {
+			b.WriteRune('\\')
+		}
+		b.WriteRune(r)
+	}
+
+	return b.String()
+}
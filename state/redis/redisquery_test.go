@@ -0,0 +1,182 @@
+// ------------------------------------------------------------
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT License.
+// ------------------------------------------------------------
+
+package redis
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/dapr/components-contrib/state"
+	"github.com/dapr/components-contrib/state/query"
+	"github.com/dapr/dapr/pkg/logger"
+	jsoniter "github.com/json-iterator/go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testQueryIndexes = `[{"key": "person.org"}, {"key": "age", "type": "numeric"}, {"key": "state", "type": "TAG"}]`
+
+func TestParseQueryIndexes(t *testing.T) {
+	t.Run("valid indexes", func(t *testing.T) {
+		indexes, err := parseQueryIndexes(testQueryIndexes)
+
+		assert.NoError(t, err)
+		assert.Equal(t, []queryIndex{
+			{Key: "person.org", Type: tagIndex, alias: "person_org"},
+			{Key: "age", Type: numericIndex, alias: "age"},
+			{Key: "state", Type: tagIndex, alias: "state"},
+		}, indexes)
+		assert.Equal(t, []interface{}{
+			"FT.CREATE", "idx", "ON", "HASH", "SCHEMA",
+			"idx:person.org", "AS", "person_org", "TAG", "SORTABLE",
+			"idx:age", "AS", "age", "NUMERIC", "SORTABLE",
+			"idx:state", "AS", "state", "TAG", "SORTABLE",
+		}, createIndexArgs("idx", indexes))
+	})
+
+	invalid := map[string]string{
+		"not json":          `{"key": "a"}`,
+		"missing key":       `[{"type": "TAG"}]`,
+		"unknown type":      `[{"key": "a", "type": "TEXT"}]`,
+		"conflicting alias": `[{"key": "a.b"}, {"key": "a_b"}]`,
+	}
+	for name, val := range invalid {
+		val := val
+		t.Run("invalid "+name, func(t *testing.T) {
+			_, err := parseQueryIndexes(val)
+
+			assert.Error(t, err)
+		})
+	}
+}
+
+func TestIndexFields(t *testing.T) {
+	indexes, err := parseQueryIndexes(testQueryIndexes)
+	require.NoError(t, err)
+
+	t.Run("indexed keys are extracted", func(t *testing.T) {
+		fields := indexFields(indexes, []byte(`{"person": {"org": "Dev Ops"}, "age": 30, "state": true}`))
+
+		assert.Equal(t, []interface{}{"idx:person.org", "Dev Ops", "idx:age", "30", "idx:state", "true"}, fields)
+	})
+
+	t.Run("missing keys and mismatched types are skipped", func(t *testing.T) {
+		fields := indexFields(indexes, []byte(`{"person": "Dev Ops", "age": "thirty"}`))
+
+		assert.Empty(t, fields)
+	})
+
+	t.Run("values which are not JSON objects are not indexed", func(t *testing.T) {
+		assert.Empty(t, indexFields(indexes, []byte(`"deathstar"`)))
+		assert.Empty(t, indexFields(indexes, []byte(`not json`)))
+	})
+}
+
+func TestRedisQuery(t *testing.T) {
+	indexes, err := parseQueryIndexes(testQueryIndexes)
+	require.NoError(t, err)
+
+	build := func(data string) (*redisQuery, error) {
+		var qq query.Query
+		require.NoError(t, json.Unmarshal([]byte(data), &qq))
+
+		q := &redisQuery{indexes: indexes}
+
+		return q, query.NewQueryBuilder(q).BuildQuery(&qq)
+	}
+
+	t.Run("filter, sort and page", func(t *testing.T) {
+		q, err := build(`{
+			"filter": {"OR": [{"EQ": {"person.org": "Dev Ops"}}, {"AND": [{"IN": {"age": [30, 40]}}, {"IN": {"state": ["CA", "WA"]}}]}]},
+			"sort": [{"key": "age", "order": "DESC"}],
+			"page": {"limit": 10, "token": "20"}
+		}`)
+		require.NoError(t, err)
+
+		assert.Equal(t, `(@person_org:{Dev\ Ops} | ((@age:[30 30] | @age:[40 40]) @state:{CA | WA}))`, q.query)
+		assert.Equal(t, []interface{}{
+			"FT.SEARCH", "idx", q.query, "RETURN", 2, "data", "version", "SORTBY", "age", "DESC", "LIMIT", 20, 10,
+		}, q.searchArgs("idx", q.offset, q.limit))
+	})
+
+	t.Run("no filter", func(t *testing.T) {
+		q, err := build(`{}`)
+		require.NoError(t, err)
+
+		assert.Equal(t, "*", q.query)
+	})
+
+	invalid := map[string]string{
+		"unindexed key":       `{"filter": {"EQ": {"name": "a"}}}`,
+		"unindexed sort":      `{"sort": [{"key": "name"}]}`,
+		"several sorts":       `{"sort": [{"key": "age"}, {"key": "state"}]}`,
+		"numeric with string": `{"filter": {"EQ": {"age": "thirty"}}}`,
+	}
+	for name, data := range invalid {
+		data := data
+		t.Run("invalid "+name, func(t *testing.T) {
+			_, err := build(data)
+
+			assert.Error(t, err)
+		})
+	}
+}
+
+func TestParseSearchResult(t *testing.T) {
+	ss := &StateStore{}
+
+	total, results, err := ss.parseSearchResult([]interface{}{
+		int64(5),
+		"key1", []interface{}{"data", `{"age": 30}`, "version", "2"},
+		"key2", []interface{}{"data", `{"age": 40}`},
+	})
+
+	assert.NoError(t, err)
+	assert.Equal(t, 5, total)
+	require.Len(t, results, 2)
+	assert.Equal(t, state.QueryItem{Key: "key1", Data: []byte(`{"age": 30}`), ETag: "2"}, results[0])
+	assert.Equal(t, "key2", results[1].Key)
+	assert.NotEmpty(t, results[1].Error)
+
+	_, _, err = ss.parseSearchResult("OK")
+	assert.Error(t, err)
+}
+
+func TestIndexedSet(t *testing.T) {
+	s, c := setupMiniredis()
+	defer s.Close()
+
+	indexes, err := parseQueryIndexes(testQueryIndexes)
+	require.NoError(t, err)
+	ss := &StateStore{
+		client:   c,
+		json:     jsoniter.ConfigFastest,
+		metadata: metadata{queryIndexes: indexes},
+		logger:   logger.NewLogger("test"),
+	}
+
+	require.NoError(t, ss.Set(&state.SetRequest{
+		Key:   "person",
+		Value: map[string]interface{}{"person": map[string]interface{}{"org": "Dev Ops"}, "age": 30},
+	}))
+	assert.Equal(t, "Dev Ops", s.HGet("person", "idx:person.org"))
+	assert.Equal(t, "30", s.HGet("person", "idx:age"))
+
+	// the keys missing from the new value are no longer indexed
+	require.NoError(t, ss.Set(&state.SetRequest{
+		Key:   "person",
+		Value: map[string]interface{}{"age": 40},
+	}))
+	res, err := c.DoContext(context.Background(), "HGETALL", "person").Result()
+	require.NoError(t, err)
+	data, version, err := ss.getKeyVersion(res.([]interface{}))
+	assert.NoError(t, err)
+	assert.Equal(t, `{"age":40}`, data)
+	assert.Equal(t, "2", version)
+	assert.Equal(t, "", s.HGet("person", "idx:person.org"))
+	assert.Equal(t, "40", s.HGet("person", "idx:age"))
+}
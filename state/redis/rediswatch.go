@@ -0,0 +1,90 @@
+// ------------------------------------------------------------
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT License.
+// ------------------------------------------------------------
+
+package redis
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/dapr/components-contrib/state"
+)
+
+// keyspaceChannelPrefix is the prefix of the channels of the keyspace notifications of the keys of the database
+var keyspaceChannelPrefix = fmt.Sprintf("__keyspace@%d__:", defaultDB)
+
+// globEscaper escapes the special characters of the glob-style patterns of PSUBSCRIBE
+var globEscaper = strings.NewReplacer(`\`, `\\`, `*`, `\*`, `?`, `\?`, `[`, `\[`, `]`, `\]`)
+
+// Watch notifies the changes of the keys with the keyspace notifications, which require the notify-keyspace-events
+// configuration of the server to include the Khgxe classes. The cluster redisType is not supported, as the nodes of
+// a cluster notify the changes of their own keys only.
+func (r *StateStore) Watch(req *state.WatchRequest, handler func(change *state.StateChange)) (func(), error) {
+	if r.metadata.redisType == clusterRedisType {
+		return nil, errors.New("redis store error: watch is not supported with the cluster redisType")
+	}
+
+	ps := r.client.PSubscribe(watchPatterns(req)...)
+	if _, err := ps.Receive(); err != nil {
+		ps.Close()
+
+		return nil, fmt.Errorf("redis store error: can't subscribe to the keyspace notifications: %s", err)
+	}
+
+	go func() {
+		for msg := range ps.Channel() {
+			key := strings.TrimPrefix(msg.Channel, keyspaceChannelPrefix)
+			if !req.Watches(key) {
+				continue
+			}
+			if change := r.keyspaceChange(key, msg.Payload); change != nil {
+				handler(change)
+			}
+		}
+	}()
+
+	return func() {
+		ps.Close()
+	}, nil
+}
+
+// watchPatterns returns the patterns of the keyspace channels of the keys and prefixes of a watch request
+func watchPatterns(req *state.WatchRequest) []string {
+	if len(req.Keys) == 0 && len(req.Prefixes) == 0 {
+		return []string{keyspaceChannelPrefix + "*"}
+	}
+
+	patterns := make([]string, 0, len(req.Keys)+len(req.Prefixes))
+	for _, k := range req.Keys {
+		patterns = append(patterns, keyspaceChannelPrefix+globEscaper.Replace(k))
+	}
+	for _, p := range req.Prefixes {
+		patterns = append(patterns, keyspaceChannelPrefix+globEscaper.Replace(p)+"*")
+	}
+
+	return patterns
+}
+
+// keyspaceChange returns the change of a keyspace notification, nil for the events which don't change the value.
+// A set increments the version of the value after setting its data, so that the upsert is notified with its version
+// once the value is set.
+func (r *StateStore) keyspaceChange(key, event string) *state.StateChange {
+	switch event {
+	case "hincrby":
+		res, err := r.Get(&state.GetRequest{Key: key})
+		if err != nil {
+			r.logger.Warnf("redis store: can't get the value of the changed key %s: %s", key, err)
+
+			return nil
+		}
+
+		return &state.StateChange{Key: key, Operation: state.Upsert, Data: res.Data, ETag: res.ETag}
+	case "del", "expired", "evicted":
+		return &state.StateChange{Key: key, Operation: state.Delete}
+	}
+
+	return nil
+}
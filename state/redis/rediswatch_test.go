@@ -0,0 +1,77 @@
+// ------------------------------------------------------------
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT License.
+// ------------------------------------------------------------
+
+package redis
+
+import (
+	"testing"
+	"time"
+
+	"github.com/dapr/components-contrib/state"
+	"github.com/dapr/dapr/pkg/logger"
+	jsoniter "github.com/json-iterator/go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWatchPatterns(t *testing.T) {
+	t.Run("every key", func(t *testing.T) {
+		assert.Equal(t, []string{"__keyspace@0__:*"}, watchPatterns(&state.WatchRequest{}))
+	})
+
+	t.Run("keys and prefixes", func(t *testing.T) {
+		patterns := watchPatterns(&state.WatchRequest{Keys: []string{"config", "a*b"}, Prefixes: []string{"order-"}})
+
+		assert.Equal(t, []string{"__keyspace@0__:config", `__keyspace@0__:a\*b`, "__keyspace@0__:order-*"}, patterns)
+	})
+}
+
+func TestWatch(t *testing.T) {
+	s, c := setupMiniredis()
+	defer s.Close()
+
+	ss := &StateStore{
+		client:   c,
+		json:     jsoniter.ConfigFastest,
+		metadata: metadata{redisType: nodeRedisType},
+		logger:   logger.NewLogger("test"),
+	}
+
+	changes := make(chan *state.StateChange, 10)
+	stop, err := ss.Watch(&state.WatchRequest{Prefixes: []string{"order-"}}, func(change *state.StateChange) {
+		changes <- change
+	})
+	require.NoError(t, err)
+	defer stop()
+
+	require.NoError(t, ss.Set(&state.SetRequest{Key: "order-1", Value: "pending"}))
+	// miniredis doesn't send the keyspace notifications, which are published as redis does
+	s.Publish("__keyspace@0__:order-1", "hset")
+	s.Publish("__keyspace@0__:order-1", "hincrby")
+	s.Publish("__keyspace@0__:cart-1", "hincrby")
+	s.Publish("__keyspace@0__:order-1", "del")
+
+	select {
+	case change := <-changes:
+		assert.Equal(t, &state.StateChange{Key: "order-1", Operation: state.Upsert, Data: []byte(`"pending"`), ETag: "1"}, change)
+	case <-time.After(5 * time.Second):
+		t.Fatal("no upsert notified")
+	}
+	select {
+	case change := <-changes:
+		assert.Equal(t, &state.StateChange{Key: "order-1", Operation: state.Delete}, change)
+	case <-time.After(5 * time.Second):
+		t.Fatal("no delete notified")
+	}
+	assert.Empty(t, changes)
+}
+
+func TestWatchCluster(t *testing.T) {
+	ss := &StateStore{metadata: metadata{redisType: clusterRedisType}}
+
+	_, err := ss.Watch(&state.WatchRequest{}, func(change *state.StateChange) {})
+
+	assert.Error(t, err)
+}
@@ -5,6 +5,12 @@
 
 package state
 
+import (
+	"strings"
+
+	"github.com/dapr/components-contrib/state/query"
+)
+
 // GetRequest is the object describing a state fetch request
 type GetRequest struct {
 	Key      string            `json:"key"`
@@ -66,6 +72,75 @@ type SetStateOption struct {
 	Consistency string `json:"consistency"`           // "eventual, strong"
 }
 
+// QueryRequest is the object describing a query on the values of a store
+type QueryRequest struct {
+	Query    query.Query       `json:"query"`
+	Metadata map[string]string `json:"metadata,omitempty"`
+}
+
+// DefaultListLimit is the number of items of a page of a list request without limit
+const DefaultListLimit = 100
+
+// ListRequest is the object describing a page of the keys with a prefix. The token is the cursor returned with
+// the previous page, the first page has none.
+type ListRequest struct {
+	Prefix   string            `json:"prefix,omitempty"`
+	Limit    int               `json:"limit,omitempty"`
+	Token    string            `json:"token,omitempty"`
+	Metadata map[string]string `json:"metadata,omitempty"`
+}
+
+// PageLimit returns the limit of the number of items of the page, DefaultListLimit unless the request has one
+func (r *ListRequest) PageLimit() int {
+	if r.Limit <= 0 {
+		return DefaultListLimit
+	}
+
+	return r.Limit
+}
+
+// LockRequest is the object describing the lock of a resource by an owner, which expires after ExpiryInSeconds
+// unless it is unlocked before
+type LockRequest struct {
+	ResourceID      string            `json:"resourceId"`
+	LockOwner       string            `json:"lockOwner"`
+	ExpiryInSeconds int               `json:"expiryInSeconds"`
+	Metadata        map[string]string `json:"metadata,omitempty"`
+}
+
+// UnlockRequest is the object describing the unlock of a resource by the owner of its lock
+type UnlockRequest struct {
+	ResourceID string            `json:"resourceId"`
+	LockOwner  string            `json:"lockOwner"`
+	Metadata   map[string]string `json:"metadata,omitempty"`
+}
+
+// WatchRequest is the object describing the keys to watch, by key or by prefix
+type WatchRequest struct {
+	Keys     []string          `json:"keys,omitempty"`
+	Prefixes []string          `json:"prefixes,omitempty"`
+	Metadata map[string]string `json:"metadata,omitempty"`
+}
+
+// Watches tells whether a watch request watches a key, a request without keys nor prefixes watches every key
+func (r *WatchRequest) Watches(key string) bool {
+	if len(r.Keys) == 0 && len(r.Prefixes) == 0 {
+		return true
+	}
+	for _, k := range r.Keys {
+		if k == key {
+			return true
+		}
+	}
+	for _, p := range r.Prefixes {
+		if strings.HasPrefix(key, p) {
+			return true
+		}
+	}
+
+	return false
+}
+
 // OperationType describes a CRUD operation performed against a state store
 type OperationType string
 
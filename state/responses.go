@@ -19,3 +19,42 @@ type BulkGetResponse struct {
 	Metadata map[string]string `json:"metadata"`
 	Error    string            `json:"error,omitempty"`
 }
+
+// QueryResponse is the response object for querying state
+type QueryResponse struct {
+	Results []QueryItem `json:"results"`
+	// Token resumes the query from the next page, it is empty on the last page
+	Token    string            `json:"token,omitempty"`
+	Metadata map[string]string `json:"metadata,omitempty"`
+}
+
+// ListResponse is a page of the keys of a list request, with their values
+type ListResponse struct {
+	Items []QueryItem `json:"items"`
+	// Token is the cursor of the next page, it is empty on the last page
+	Token    string            `json:"token,omitempty"`
+	Metadata map[string]string `json:"metadata,omitempty"`
+}
+
+// LockResponse is the response object of a lock request, which succeeds unless the resource is already locked
+type LockResponse struct {
+	Success bool `json:"success"`
+}
+
+// QueryItem is a value returned by a query or a list
+type QueryItem struct {
+	Key   string `json:"key"`
+	Data  []byte `json:"data"`
+	ETag  string `json:"etag,omitempty"`
+	Error string `json:"error,omitempty"`
+}
+
+// StateChange is a change of the value of a key notified to a StateWatcher. The operation is Upsert or Delete,
+// the data and etag are the value of an upsert, when the store notifies it.
+type StateChange struct {
+	Key       string            `json:"key"`
+	Operation OperationType     `json:"operation"`
+	Data      []byte            `json:"data,omitempty"`
+	ETag      string            `json:"etag,omitempty"`
+	Metadata  map[string]string `json:"metadata,omitempty"`
+}
@@ -188,6 +188,9 @@ func (s *RethinkDB) Set(req *state.SetRequest) error {
 func (s *RethinkDB) BulkSet(req []state.SetRequest) error {
 	docs := make([]*stateRecord, len(req))
 	for i, v := range req {
+		if err := state.RejectTTL(v.Metadata); err != nil {
+			return err
+		}
 		docs[i] = &stateRecord{
 			ID:   v.Key,
 			TS:   time.Now().UTC().UnixNano(),
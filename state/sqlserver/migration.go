@@ -10,6 +10,9 @@ import (
 	"fmt"
 )
 
+// notExpiredCondition is the condition of the rows which have not expired
+const notExpiredCondition = "([ExpireDate] IS NULL OR [ExpireDate] > GETUTCDATE())"
+
 type migrator interface {
 	executeMigrations() (migrationResult, error)
 }
@@ -22,12 +25,15 @@ type migrationResult struct {
 	bulkDeleteProcName       string
 	bulkDeleteProcFullName   string
 	itemRefTableTypeName     string
+	upsertTableTypeName      string
 	upsertProcName           string
 	upsertProcFullName       string
 	pkColumnType             string
 	getCommand               string
+	bulkUpsertCommand        string
 	deleteWithETagCommand    string
 	deleteWithoutETagCommand string
+	deleteExpiredCommand     string
 }
 
 func newMigration(store *SQLServer) migrator {
@@ -41,11 +47,14 @@ func (m *migration) executeMigrations() (migrationResult, error) {
 	r := migrationResult{
 		bulkDeleteProcName:       fmt.Sprintf("sp_BulkDelete_%s", m.store.tableName),
 		itemRefTableTypeName:     fmt.Sprintf("[%s].%s_Table", m.store.schema, m.store.tableName),
+		upsertTableTypeName:      fmt.Sprintf("[%s].%s_UpsertTable", m.store.schema, m.store.tableName),
 		upsertProcName:           fmt.Sprintf("sp_Upsert_%s", m.store.tableName),
-		getCommand:               fmt.Sprintf("SELECT [Data], [RowVersion] FROM [%s].[%s] WHERE [Key] = @Key", m.store.schema, m.store.tableName),
+		getCommand:               fmt.Sprintf("SELECT [Data], [RowVersion] FROM [%s].[%s] WHERE [Key] = @Key AND %s", m.store.schema, m.store.tableName, notExpiredCondition),
 		deleteWithETagCommand:    fmt.Sprintf(`DELETE [%s].[%s] WHERE [Key]=@Key AND [RowVersion]=@RowVersion`, m.store.schema, m.store.tableName),
 		deleteWithoutETagCommand: fmt.Sprintf(`DELETE [%s].[%s] WHERE [Key]=@Key`, m.store.schema, m.store.tableName),
+		deleteExpiredCommand:     fmt.Sprintf(`DELETE [%s].[%s] WHERE [ExpireDate] <= GETUTCDATE()`, m.store.schema, m.store.tableName),
 	}
+	r.bulkUpsertCommand = m.bulkUpsertCommand(r.upsertTableTypeName)
 
 	r.bulkDeleteProcFullName = fmt.Sprintf("[%s].%s", m.store.schema, r.bulkDeleteProcName)
 	r.upsertProcFullName = fmt.Sprintf("[%s].%s", m.store.schema, r.upsertProcName)
@@ -79,6 +88,11 @@ func (m *migration) executeMigrations() (migrationResult, error) {
 		return r, fmt.Errorf("failed to create db table: %v", err)
 	}
 
+	err = m.ensureExpireDateColumnExists(db)
+	if err != nil {
+		return r, fmt.Errorf("failed to add the expiration column: %v", err)
+	}
+
 	err = m.ensureStoredProcedureExists(db, r)
 	if err != nil {
 		return r, fmt.Errorf("failed to create stored procedures: %v", err)
@@ -142,7 +156,8 @@ func (m *migration) ensureTableExists(db *sql.DB, r migrationResult) error {
 			[Key] 			%s CONSTRAINT PK_%s PRIMARY KEY,
 			[Data]			NVARCHAR(MAX) NOT NULL,
 			[InsertDate] 	DateTime2 NOT NULL DEFAULT(GETDATE()),
-			[UpdateDate] 	DateTime2 NULL,`,
+			[UpdateDate] 	DateTime2 NULL,
+			[ExpireDate] 	DateTime2 NULL,`,
 		m.store.schema, m.store.tableName, m.store.schema, m.store.tableName, r.pkColumnType, m.store.tableName)
 
 	if m.store.indexedProperties != nil {
@@ -162,6 +177,23 @@ func (m *migration) ensureTableExists(db *sql.DB, r migrationResult) error {
 	return runCommand(tsql, db)
 }
 
+// ensureExpireDateColumnExists adds the expiration column to the tables created before it, and indexes it so that
+// the expired rows are deleted without scanning the table
+/* #nosec */
+func (m *migration) ensureExpireDateColumnExists(db *sql.DB) error {
+	tsql := fmt.Sprintf(`
+	IF COL_LENGTH('[%s].[%s]', 'ExpireDate') IS NULL
+		ALTER TABLE [%s].[%s] ADD [ExpireDate] DateTime2 NULL`,
+		m.store.schema, m.store.tableName, m.store.schema, m.store.tableName)
+
+	err := runCommand(tsql, db)
+	if err != nil {
+		return err
+	}
+
+	return m.ensureIndexedPropertyExists(IndexedProperty{ColumnName: expireDateColumnName}, db)
+}
+
 /* #nosec */
 func (m *migration) ensureTypeExists(db *sql.DB, mr migrationResult) error {
 	tsql := fmt.Sprintf(`
@@ -173,9 +205,43 @@ func (m *migration) ensureTypeExists(db *sql.DB, mr migrationResult) error {
 		)
 	`, m.store.schema, m.store.tableName, m.store.schema, m.store.tableName, mr.pkColumnType)
 
+	err := runCommand(tsql, db)
+	if err != nil {
+		return err
+	}
+
+	tsql = fmt.Sprintf(`
+	IF type_id('%s') IS NULL
+		CREATE TYPE %s AS TABLE
+		(
+			[Key]           		%s NOT NULL PRIMARY KEY,
+			[Data]					NVARCHAR(MAX) NOT NULL,
+			[RowVersion]			BINARY(8),
+			[TTLInSeconds]			INT NOT NULL
+		)
+	`, mr.upsertTableTypeName, mr.upsertTableTypeName, mr.pkColumnType)
+
 	return runCommand(tsql, db)
 }
 
+// bulkUpsertCommand returns the MERGE statement which upserts the items of a table-valued parameter at once. The
+// items with a row version only update the row with this version, so that the statement affects fewer rows than
+// there are items when a row version doesn't match.
+/* #nosec */
+func (m *migration) bulkUpsertCommand(upsertTableTypeName string) string {
+	return fmt.Sprintf(`
+	MERGE [%s].[%s] WITH (HOLDLOCK) AS t
+	USING @items AS i ON t.[Key] = i.[Key]
+	WHEN MATCHED AND (i.[RowVersion] IS NULL OR t.[RowVersion] = i.[RowVersion]) THEN
+		UPDATE SET [Data]=i.[Data], [UpdateDate]=GETDATE(), [ExpireDate]=%s
+	WHEN NOT MATCHED BY TARGET AND i.[RowVersion] IS NULL THEN
+		INSERT ([Key], [Data], [ExpireDate]) VALUES (i.[Key], i.[Data], %s);`,
+		m.store.schema,
+		m.store.tableName,
+		expireDateExpression("i.[TTLInSeconds]"),
+		expireDateExpression("i.[TTLInSeconds]"))
+}
+
 /* #nosec */
 func (m *migration) ensureBulkDeleteStoredProcedureExists(db *sql.DB, mr migrationResult) error {
 	tsql := fmt.Sprintf(`
@@ -230,36 +296,56 @@ func (m *migration) createStoredProcedureIfNotExists(db *sql.DB, name string, es
 
 /* #nosec */
 func (m *migration) ensureUpsertStoredProcedureExists(db *sql.DB, mr migrationResult) error {
+	// the procedure created before the expiration column has no @TTLInSeconds parameter, it is created again
+	err := runCommand(fmt.Sprintf(`
+	IF OBJECT_ID(N'%s', N'P') IS NOT NULL
+		AND NOT EXISTS (SELECT * FROM sys.parameters WHERE object_id = OBJECT_ID(N'%s') AND name = '@TTLInSeconds')
+		DROP PROCEDURE %s`,
+		mr.upsertProcFullName, mr.upsertProcFullName, mr.upsertProcFullName), db)
+	if err != nil {
+		return err
+	}
+
 	tsql := fmt.Sprintf(`
 		CREATE PROCEDURE %s (
 			@Key 			%s,
 			@Data 			NVARCHAR(MAX),
-			@RowVersion 	BINARY(8))
+			@RowVersion 	BINARY(8),
+			@TTLInSeconds 	INT)
 		AS
 			IF (@RowVersion IS NOT NULL)
 			BEGIN
 				UPDATE [%s]
-				SET [Data]=@Data, UpdateDate=GETDATE()
+				SET [Data]=@Data, UpdateDate=GETDATE(), ExpireDate=%s
 				WHERE [Key]=@Key AND RowVersion = @RowVersion
 
 				RETURN
 			END
 			
 			BEGIN TRY
-				INSERT INTO [%s] ([Key], [Data]) VALUES (@Key, @Data);
+				INSERT INTO [%s] ([Key], [Data], [ExpireDate]) VALUES (@Key, @Data, %s);
 			END TRY
 
 			BEGIN CATCH
 				IF ERROR_NUMBER() IN (2601, 2627) 
 				UPDATE [%s]
-				SET [Data]=@Data, UpdateDate=GETDATE()
+				SET [Data]=@Data, UpdateDate=GETDATE(), ExpireDate=%s
 				WHERE [Key]=@Key AND RowVersion = ISNULL(@RowVersion, RowVersion)
 			END CATCH`,
 		mr.upsertProcFullName,
 		mr.pkColumnType,
 		m.store.tableName,
+		expireDateExpression("@TTLInSeconds"),
 		m.store.tableName,
-		m.store.tableName)
+		expireDateExpression("@TTLInSeconds"),
+		m.store.tableName,
+		expireDateExpression("@TTLInSeconds"))
 
 	return m.createStoredProcedureIfNotExists(db, mr.upsertProcName, tsql)
 }
+
+// expireDateExpression returns the expression of the expiration of a row with a time to live in seconds, which
+// never expires without a positive time to live
+func expireDateExpression(ttlInSeconds string) string {
+	return fmt.Sprintf("CASE WHEN %s > 0 THEN DATEADD(second, %s, GETUTCDATE()) END", ttlInSeconds, ttlInSeconds)
+}
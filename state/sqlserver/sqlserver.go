@@ -12,6 +12,7 @@ import (
 	"errors"
 	"fmt"
 	"strconv"
+	"time"
 	"unicode"
 
 	"github.com/dapr/components-contrib/state"
@@ -58,11 +59,14 @@ const (
 	keyTypeKey           = "keyType"
 	keyLengthKey         = "keyLength"
 	indexedPropertiesKey = "indexedProperties"
+	cleanupIntervalKey   = "cleanupIntervalInSeconds"
 	keyColumnName        = "Key"
 	rowVersionColumnName = "RowVersion"
+	expireDateColumnName = "ExpireDate"
 
-	defaultKeyLength = 200
-	defaultSchema    = "dbo"
+	defaultKeyLength       = 200
+	defaultSchema          = "dbo"
+	defaultCleanupInterval = time.Hour
 )
 
 // NewSQLServerStateStore creates a new instance of a Sql Server transaction store
@@ -89,16 +93,22 @@ type SQLServer struct {
 	keyLength         int
 	indexedProperties []IndexedProperty
 	migratorFactory   func(*SQLServer) migrator
+	// cleanupInterval is the interval of the deletes of the expired rows, they are not deleted when it is 0
+	cleanupInterval time.Duration
 
 	bulkDeleteCommand        string
 	itemRefTableTypeName     string
+	upsertTableTypeName      string
 	upsertCommand            string
+	bulkUpsertCommand        string
 	getCommand               string
 	deleteWithETagCommand    string
 	deleteWithoutETagCommand string
+	deleteExpiredCommand     string
 
-	logger logger.Logger
-	db     *sql.DB
+	logger      logger.Logger
+	db          *sql.DB
+	stopCleanup chan struct{}
 }
 
 func isLetterOrNumber(c rune) bool {
@@ -226,6 +236,15 @@ func (s *SQLServer) Init(metadata state.Metadata) error {
 		s.indexedProperties = indexedProperties
 	}
 
+	s.cleanupInterval = defaultCleanupInterval
+	if val, ok := metadata.Properties[cleanupIntervalKey]; ok && val != "" {
+		seconds, err := strconv.Atoi(val)
+		if err != nil || seconds < 0 {
+			return fmt.Errorf("invalid cleanup interval %s, it must be a number of seconds", val)
+		}
+		s.cleanupInterval = time.Duration(seconds) * time.Second
+	}
+
 	migration := s.migratorFactory(s)
 	mr, err := migration.executeMigrations()
 	if err != nil {
@@ -233,17 +252,61 @@ func (s *SQLServer) Init(metadata state.Metadata) error {
 	}
 
 	s.itemRefTableTypeName = mr.itemRefTableTypeName
+	s.upsertTableTypeName = mr.upsertTableTypeName
 	s.bulkDeleteCommand = fmt.Sprintf("exec %s @itemsToDelete;", mr.bulkDeleteProcFullName)
 	s.upsertCommand = mr.upsertProcFullName
+	s.bulkUpsertCommand = mr.bulkUpsertCommand
 	s.getCommand = mr.getCommand
 	s.deleteWithETagCommand = mr.deleteWithETagCommand
 	s.deleteWithoutETagCommand = mr.deleteWithoutETagCommand
+	s.deleteExpiredCommand = mr.deleteExpiredCommand
 
 	s.db, err = sql.Open("sqlserver", s.connectionString)
 	if err != nil {
 		return err
 	}
 
+	if s.cleanupInterval > 0 && s.deleteExpiredCommand != "" {
+		s.stopCleanup = make(chan struct{})
+		go s.cleanupExpired()
+	}
+
+	return nil
+}
+
+// cleanupExpired deletes the expired rows every cleanup interval, until the store is closed. The expired rows are
+// not read before they are deleted.
+func (s *SQLServer) cleanupExpired() {
+	ticker := time.NewTicker(s.cleanupInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-s.stopCleanup:
+			return
+		case <-ticker.C:
+			if _, err := s.db.Exec(s.deleteExpiredCommand); err != nil {
+				s.logger.Warnf("failed to delete the expired rows: %s", err)
+			}
+		}
+	}
+}
+
+// Features returns the features of the SQL Server state store
+func (s *SQLServer) Features() []state.Feature {
+	return []state.Feature{state.FeatureTTL}
+}
+
+// Close implements io.Closer
+func (s *SQLServer) Close() error {
+	if s.stopCleanup != nil {
+		close(s.stopCleanup)
+		s.stopCleanup = nil
+	}
+	if s.db != nil {
+		return s.db.Close()
+	}
+
 	return nil
 }
 
@@ -306,13 +369,11 @@ func (s *SQLServer) executeMulti(sets []state.SetRequest, deletes []state.Delete
 	}
 
 	if len(sets) > 0 {
-		for i := range sets {
-			err = s.executeSet(tx, &sets[i])
-			if err != nil {
-				tx.Rollback()
+		err = s.executeBulkSet(tx, sets)
+		if err != nil {
+			tx.Rollback()
 
-				return err
-			}
+			return err
 		}
 	}
 
@@ -462,7 +523,11 @@ type dbExecutor interface {
 }
 
 func (s *SQLServer) executeSet(db dbExecutor, req *state.SetRequest) error {
-	var err error
+	ttl, err := ttlInSeconds(req)
+	if err != nil {
+		return err
+	}
+
 	var bytes []byte
 	bytes, err = utils.Marshal(req.Value, json.Marshal)
 	if err != nil {
@@ -477,7 +542,7 @@ func (s *SQLServer) executeSet(db dbExecutor, req *state.SetRequest) error {
 		}
 		etag.Value = b
 	}
-	res, err := db.Exec(s.upsertCommand, sql.Named(keyColumnName, req.Key), sql.Named("Data", string(bytes)), etag)
+	res, err := db.Exec(s.upsertCommand, sql.Named(keyColumnName, req.Key), sql.Named("Data", string(bytes)), etag, sql.Named("TTLInSeconds", ttl))
 	if err != nil {
 		return err
 	}
@@ -494,23 +559,104 @@ func (s *SQLServer) executeSet(db dbExecutor, req *state.SetRequest) error {
 	return nil
 }
 
-// BulkSet adds/updates multiple entities on store
+// ttlInSeconds returns the time to live of a set request, which is 0 for the values which never expire
+func ttlInSeconds(req *state.SetRequest) (int, error) {
+	ttl, err := state.ParseTTL(req.Metadata)
+	if err != nil {
+		return 0, err
+	}
+	if ttl == nil || *ttl == state.NoExpiry {
+		return 0, nil
+	}
+
+	return *ttl, nil
+}
+
+// TvpUpsertTable defines the table type of the items of a bulk upsert
+type TvpUpsertTable struct {
+	Key          string
+	Data         string
+	RowVersion   []byte
+	TTLInSeconds int
+}
+
+// BulkSet adds/updates multiple entities on store with a single MERGE statement
 func (s *SQLServer) BulkSet(req []state.SetRequest) error {
 	tx, err := s.db.Begin()
 	if err != nil {
 		return err
 	}
 
+	err = s.executeBulkSet(tx, req)
+	if err != nil {
+		tx.Rollback()
+
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// executeBulkSet upserts the items with the MERGE statement of the table-valued parameter of their values. When
+// a key is set more than once, its last value is set.
+func (s *SQLServer) executeBulkSet(db dbExecutor, req []state.SetRequest) error {
+	values, err := upsertValues(req)
+	if err != nil {
+		return err
+	}
+
+	items := mssql.TVP{
+		TypeName: s.upsertTableTypeName,
+		Value:    values,
+	}
+
+	res, err := db.Exec(s.bulkUpsertCommand, sql.Named("items", items))
+	if err != nil {
+		return err
+	}
+
+	rows, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if int(rows) != len(values) {
+		return fmt.Errorf("upsert affected only %d rows, expected %d", rows, len(values))
+	}
+
+	return nil
+}
+
+// upsertValues returns the rows of the table-valued parameter of a bulk upsert, with the last value of each key
+func upsertValues(req []state.SetRequest) ([]TvpUpsertTable, error) {
+	values := make([]TvpUpsertTable, 0, len(req))
+	indexes := make(map[string]int, len(req))
 	for i := range req {
-		err = s.executeSet(tx, &req[i])
+		ttl, err := ttlInSeconds(&req[i])
 		if err != nil {
-			tx.Rollback()
+			return nil, err
+		}
+		data, err := utils.Marshal(req[i].Value, json.Marshal)
+		if err != nil {
+			return nil, err
+		}
+		var etag []byte
+		if req[i].ETag != "" {
+			etag, err = hex.DecodeString(req[i].ETag)
+			if err != nil {
+				return nil, err
+			}
+		}
 
-			return err
+		value := TvpUpsertTable{Key: req[i].Key, Data: string(data), RowVersion: etag, TTLInSeconds: ttl}
+		if index, ok := indexes[req[i].Key]; ok {
+			values[index] = value
+
+			continue
 		}
+		indexes[req[i].Key] = len(values)
+		values = append(values, value)
 	}
 
-	err = tx.Commit()
-
-	return err
+	return values, nil
 }
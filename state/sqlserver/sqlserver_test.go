@@ -6,6 +6,7 @@ package sqlserver
 
 import (
 	"testing"
+	"time"
 
 	"github.com/dapr/components-contrib/state"
 	"github.com/dapr/dapr/pkg/logger"
@@ -226,6 +227,11 @@ func TestInvalidConfiguration(t *testing.T) {
 			props:       map[string]string{connectionStringKey: sampleConnectionString, tableNameKey: "test", indexedPropertiesKey: `[{"column":"age", "property": "age", "type": "INT;"}]`},
 			expectedErr: "invalid indexed property type",
 		},
+		{
+			name:        "Invalid cleanup interval",
+			props:       map[string]string{connectionStringKey: sampleConnectionString, tableNameKey: "test", cleanupIntervalKey: "-1"},
+			expectedErr: "invalid cleanup interval",
+		},
 		{
 			name:        "Invalid index property type with space",
 			props:       map[string]string{connectionStringKey: sampleConnectionString, tableNameKey: "test", indexedPropertiesKey: `[{"column":"age", "property": "age", "type": "INT GO DROP DATABASE dapr_test"}]`},
@@ -250,3 +256,66 @@ func TestInvalidConfiguration(t *testing.T) {
 		})
 	}
 }
+
+func TestCleanupIntervalConfiguration(t *testing.T) {
+	tests := []struct {
+		name     string
+		value    string
+		expected time.Duration
+	}{
+		{"Default", "", defaultCleanupInterval},
+		{"Custom", "60", time.Minute},
+		{"Disabled", "0", 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sqlStore := NewSQLServerStateStore(logger.NewLogger("test"))
+			sqlStore.migratorFactory = func(s *SQLServer) migrator {
+				return &mockMigrator{}
+			}
+
+			err := sqlStore.Init(state.Metadata{
+				Properties: map[string]string{connectionStringKey: sampleConnectionString, tableNameKey: sampleUserTableName, cleanupIntervalKey: tt.value},
+			})
+			assert.Nil(t, err)
+			assert.Equal(t, tt.expected, sqlStore.cleanupInterval)
+			assert.Nil(t, sqlStore.Close())
+		})
+	}
+}
+
+func TestUpsertValues(t *testing.T) {
+	t.Run("Last value of each key", func(t *testing.T) {
+		values, err := upsertValues([]state.SetRequest{
+			{Key: "1", Value: "first", ETag: "0000000000000001"},
+			{Key: "2", Value: "second", Metadata: map[string]string{"ttlInSeconds": "60"}},
+			{Key: "1", Value: "third", Metadata: map[string]string{"ttlInSeconds": "-1"}},
+		})
+
+		assert.Nil(t, err)
+		assert.Equal(t, []TvpUpsertTable{
+			{Key: "1", Data: `"third"`},
+			{Key: "2", Data: `"second"`, TTLInSeconds: 60},
+		}, values)
+	})
+
+	t.Run("Row version", func(t *testing.T) {
+		values, err := upsertValues([]state.SetRequest{{Key: "1", Value: "first", ETag: "0000000000000001"}})
+
+		assert.Nil(t, err)
+		assert.Equal(t, []byte{0, 0, 0, 0, 0, 0, 0, 1}, values[0].RowVersion)
+	})
+
+	t.Run("Invalid etag", func(t *testing.T) {
+		_, err := upsertValues([]state.SetRequest{{Key: "1", Value: "first", ETag: "not hex"}})
+
+		assert.NotNil(t, err)
+	})
+
+	t.Run("Invalid time to live", func(t *testing.T) {
+		_, err := upsertValues([]state.SetRequest{{Key: "1", Value: "first", Metadata: map[string]string{"ttlInSeconds": "0"}}})
+
+		assert.NotNil(t, err)
+	})
+}
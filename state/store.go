@@ -21,6 +21,33 @@ type BulkStore interface {
 	BulkSet(req []SetRequest) error
 }
 
+// Querier is an interface to query the values of a store with the portable query language of the query package
+type Querier interface {
+	Query(req *QueryRequest) (*QueryResponse, error)
+}
+
+// Lister is an interface to enumerate the keys with a prefix by pages, with the cursors of the store, so that
+// large keyspaces are read without loading them at once. The keys are in the order of the store, and a page may
+// have fewer items than the limit before the last page.
+type Lister interface {
+	List(req *ListRequest) (*ListResponse, error)
+}
+
+// Locker is an interface to lock resources exclusively, so that applications coordinate their work. Lock doesn't
+// wait for a locked resource, it returns an unsuccessful response. Unlock returns ErrLockDoesNotExist or
+// ErrLockBelongsToOthers when the resource isn't locked by the owner.
+type Locker interface {
+	Lock(req *LockRequest) (*LockResponse, error)
+	Unlock(req *UnlockRequest) error
+}
+
+// StateWatcher is an interface to watch the changes of the values of a store with its native change notifications.
+// Watch calls the handler with the changes of the watched keys, from the start of the watch until it is stopped with
+// the returned function. The handler is called from a single goroutine.
+type StateWatcher interface {
+	Watch(req *WatchRequest, handler func(change *StateChange)) (stop func(), err error)
+}
+
 // DefaultBulkStore is a default implementation of BulkStore
 type DefaultBulkStore struct {
 	s Store
@@ -169,3 +169,26 @@ func (s *Store2) BulkDelete(req []DeleteRequest) error {
 
 	return nil
 }
+
+func TestWatchRequestWatches(t *testing.T) {
+	t.Run("every key without keys nor prefixes", func(t *testing.T) {
+		req := &WatchRequest{}
+
+		require.True(t, req.Watches("order-1"))
+	})
+
+	t.Run("keys and prefixes", func(t *testing.T) {
+		req := &WatchRequest{Keys: []string{"config"}, Prefixes: []string{"order-", "cart||"}}
+
+		require.True(t, req.Watches("config"))
+		require.True(t, req.Watches("order-1"))
+		require.True(t, req.Watches("cart||item-2"))
+		require.False(t, req.Watches("config-2"))
+		require.False(t, req.Watches("cart"))
+	})
+}
+
+func TestListRequestPageLimit(t *testing.T) {
+	require.Equal(t, DefaultListLimit, (&ListRequest{}).PageLimit())
+	require.Equal(t, 10, (&ListRequest{Limit: 10}).PageLimit())
+}
@@ -0,0 +1,46 @@
+// ------------------------------------------------------------
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT License.
+// ------------------------------------------------------------
+
+package state
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+
+	contrib_metadata "github.com/dapr/components-contrib/metadata"
+)
+
+// NoExpiry is the ttlInSeconds of the values which never expire, it removes the time to live of an existing value.
+const NoExpiry = -1
+
+// ErrTTLNotSupported is returned by the state stores without FeatureTTL when a set request has the ttlInSeconds metadata.
+var ErrTTLNotSupported = errors.New("the state store does not support the " + contrib_metadata.TTLMetadataKey + " metadata")
+
+// ParseTTL parses the ttlInSeconds metadata of a set request, which is either a positive number of seconds
+// or NoExpiry. It returns nil when the metadata is not set. A set replaces the time to live of an existing value
+// along with the value, so the stores with FeatureTTL handle nil like NoExpiry: the value does not expire.
+func ParseTTL(metadata map[string]string) (*int, error) {
+	val, ok := metadata[contrib_metadata.TTLMetadataKey]
+	if !ok || val == "" {
+		return nil, nil
+	}
+
+	ttl, err := strconv.Atoi(val)
+	if err != nil || (ttl <= 0 && ttl != NoExpiry) {
+		return nil, fmt.Errorf("invalid %s %s, it must be a positive number of seconds or %d", contrib_metadata.TTLMetadataKey, val, NoExpiry)
+	}
+
+	return &ttl, nil
+}
+
+// RejectTTL returns ErrTTLNotSupported when the ttlInSeconds metadata is set, for the state stores without FeatureTTL.
+func RejectTTL(metadata map[string]string) error {
+	if val, ok := metadata[contrib_metadata.TTLMetadataKey]; ok && val != "" {
+		return ErrTTLNotSupported
+	}
+
+	return nil
+}
@@ -0,0 +1,63 @@
+// ------------------------------------------------------------
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT License.
+// ------------------------------------------------------------
+
+package state
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseTTL(t *testing.T) {
+	t.Run("without ttl", func(t *testing.T) {
+		ttl, err := ParseTTL(map[string]string{})
+
+		assert.NoError(t, err)
+		assert.Nil(t, ttl)
+	})
+
+	t.Run("positive ttl", func(t *testing.T) {
+		ttl, err := ParseTTL(map[string]string{"ttlInSeconds": "60"})
+
+		assert.NoError(t, err)
+		assert.Equal(t, 60, *ttl)
+	})
+
+	t.Run("no expiry", func(t *testing.T) {
+		ttl, err := ParseTTL(map[string]string{"ttlInSeconds": "-1"})
+
+		assert.NoError(t, err)
+		assert.Equal(t, NoExpiry, *ttl)
+	})
+
+	for _, val := range []string{"0", "-2", "soon"} {
+		val := val
+		t.Run("invalid ttl "+val, func(t *testing.T) {
+			_, err := ParseTTL(map[string]string{"ttlInSeconds": val})
+
+			assert.Error(t, err)
+		})
+	}
+}
+
+func TestRejectTTL(t *testing.T) {
+	assert.NoError(t, RejectTTL(nil))
+	assert.NoError(t, RejectTTL(map[string]string{"ttlInSeconds": ""}))
+	assert.Equal(t, ErrTTLNotSupported, RejectTTL(map[string]string{"ttlInSeconds": "60"}))
+}
+
+type ttlStore struct {
+	Store1
+}
+
+func (s *ttlStore) Features() []Feature {
+	return []Feature{FeatureTTL}
+}
+
+func TestFeatures(t *testing.T) {
+	assert.True(t, FeatureTTL.IsPresent(Features(&ttlStore{})))
+	assert.False(t, FeatureTTL.IsPresent(Features(&Store1{})))
+}
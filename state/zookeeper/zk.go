@@ -298,6 +298,11 @@ func (s *StateStore) newSetDataRequest(req *state.SetRequest) (*zk.SetDataReques
 		return nil, err
 	}
 
+	err = state.RejectTTL(req.Metadata)
+	if err != nil {
+		return nil, err
+	}
+
 	data, err := s.marshalData(req.Value)
 	if err != nil {
 		return nil, err
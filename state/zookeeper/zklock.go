@@ -0,0 +1,124 @@
+// ------------------------------------------------------------
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT License.
+// ------------------------------------------------------------
+
+package zookeeper
+
+import (
+	"errors"
+	"time"
+
+	"github.com/dapr/components-contrib/state"
+	jsoniter "github.com/json-iterator/go"
+	"github.com/samuel/go-zookeeper/zk"
+)
+
+// maxLockAttempts is the number of times the node of a lock is created, when the lock it replaces expires
+const maxLockAttempts = 3
+
+// lockData is the data of the node of a lock
+type lockData struct {
+	Owner string `json:"owner"`
+	// Expiry is the expiry time of the lock, in milliseconds since the epoch
+	Expiry int64 `json:"expiry"`
+}
+
+// Lock locks a resource with an ephemeral node, which is deleted when the session of its owner ends. A node whose
+// lock expired is replaced.
+func (s *StateStore) Lock(req *state.LockRequest) (*state.LockResponse, error) {
+	if err := state.CheckLockRequest(req); err != nil {
+		return nil, err
+	}
+
+	lockPath := s.prefixedKey(state.LockKeyPrefix + req.ResourceID)
+	data, err := jsoniter.ConfigFastest.Marshal(&lockData{
+		Owner:  req.LockOwner,
+		Expiry: millis(time.Now().Add(time.Duration(req.ExpiryInSeconds) * time.Second)),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	for i := 0; i < maxLockAttempts; i++ {
+		_, err = s.conn.Create(lockPath, data, zk.FlagEphemeral, nil)
+		if err == nil {
+			return &state.LockResponse{Success: true}, nil
+		}
+		if !errors.Is(err, zk.ErrNodeExists) {
+			return nil, err
+		}
+
+		held, stat, err := s.getLock(lockPath)
+		if errors.Is(err, zk.ErrNoNode) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		if held.Expiry > millis(time.Now()) {
+			return &state.LockResponse{Success: false}, nil
+		}
+
+		// the lock expired, it is replaced unless another owner replaced it first
+		err = s.conn.Delete(lockPath, stat.Version)
+		if err != nil && !errors.Is(err, zk.ErrNoNode) && !errors.Is(err, zk.ErrBadVersion) {
+			return nil, err
+		}
+	}
+
+	return &state.LockResponse{Success: false}, nil
+}
+
+// Unlock deletes the node of the lock of a resource when it belongs to the owner
+func (s *StateStore) Unlock(req *state.UnlockRequest) error {
+	if err := state.CheckUnlockRequest(req); err != nil {
+		return err
+	}
+
+	lockPath := s.prefixedKey(state.LockKeyPrefix + req.ResourceID)
+	held, stat, err := s.getLock(lockPath)
+	if errors.Is(err, zk.ErrNoNode) {
+		return state.ErrLockDoesNotExist
+	}
+	if err != nil {
+		return err
+	}
+	if held.Expiry <= millis(time.Now()) {
+		return state.ErrLockDoesNotExist
+	}
+	if held.Owner != req.LockOwner {
+		return state.ErrLockBelongsToOthers
+	}
+
+	err = s.conn.Delete(lockPath, stat.Version)
+	switch {
+	case errors.Is(err, zk.ErrNoNode):
+		return state.ErrLockDoesNotExist
+	case errors.Is(err, zk.ErrBadVersion):
+		// the lock expired and was replaced since it was read
+		return state.ErrLockBelongsToOthers
+	}
+
+	return err
+}
+
+// getLock returns the data of the node of a lock
+func (s *StateStore) getLock(lockPath string) (*lockData, *zk.Stat, error) {
+	value, stat, err := s.conn.Get(lockPath)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var held lockData
+	if err = jsoniter.ConfigFastest.Unmarshal(value, &held); err != nil {
+		return nil, nil, err
+	}
+
+	return &held, stat, nil
+}
+
+// millis returns the milliseconds since the epoch of a time
+func millis(t time.Time) int64 {
+	return t.UnixNano() / int64(time.Millisecond)
+}
@@ -0,0 +1,101 @@
+// ------------------------------------------------------------
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT License.
+// ------------------------------------------------------------
+
+package zookeeper
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/dapr/components-contrib/state"
+	gomock "github.com/golang/mock/gomock"
+	"github.com/samuel/go-zookeeper/zk"
+	"github.com/stretchr/testify/assert"
+)
+
+func lockNode(owner string, expiry time.Time) []byte {
+	return []byte(fmt.Sprintf(`{"owner": %q, "expiry": %d}`, owner, millis(expiry)))
+}
+
+// Lock
+func TestLock(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	conn := NewMockConn(ctrl)
+	s := StateStore{conn: conn}
+	req := &state.LockRequest{ResourceID: "orders", LockOwner: "worker-1", ExpiryInSeconds: 10}
+
+	t.Run("With unlocked resource", func(t *testing.T) {
+		conn.EXPECT().Create("lock||orders", gomock.Any(), int32(zk.FlagEphemeral), gomock.Any()).Return("lock||orders", nil).Times(1)
+
+		res, err := s.Lock(req)
+		assert.NoError(t, err)
+		assert.True(t, res.Success, "Resource must be locked")
+	})
+
+	t.Run("With locked resource", func(t *testing.T) {
+		conn.EXPECT().Create("lock||orders", gomock.Any(), int32(zk.FlagEphemeral), gomock.Any()).Return("", zk.ErrNodeExists).Times(1)
+		conn.EXPECT().Get("lock||orders").Return(lockNode("worker-2", time.Now().Add(time.Minute)), &zk.Stat{Version: 1}, nil).Times(1)
+
+		res, err := s.Lock(req)
+		assert.NoError(t, err)
+		assert.False(t, res.Success, "Resource must be locked by another owner")
+	})
+
+	t.Run("With expired lock", func(t *testing.T) {
+		gomock.InOrder(
+			conn.EXPECT().Create("lock||orders", gomock.Any(), int32(zk.FlagEphemeral), gomock.Any()).Return("", zk.ErrNodeExists).Times(1),
+			conn.EXPECT().Get("lock||orders").Return(lockNode("worker-2", time.Now().Add(-time.Minute)), &zk.Stat{Version: 1}, nil).Times(1),
+			conn.EXPECT().Delete("lock||orders", int32(1)).Return(nil).Times(1),
+			conn.EXPECT().Create("lock||orders", gomock.Any(), int32(zk.FlagEphemeral), gomock.Any()).Return("lock||orders", nil).Times(1),
+		)
+
+		res, err := s.Lock(req)
+		assert.NoError(t, err)
+		assert.True(t, res.Success, "Expired lock must be replaced")
+	})
+
+	t.Run("With invalid request", func(t *testing.T) {
+		_, err := s.Lock(&state.LockRequest{ResourceID: "orders", LockOwner: "worker-1"})
+		assert.Error(t, err)
+	})
+}
+
+// Unlock
+func TestUnlock(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	conn := NewMockConn(ctrl)
+	s := StateStore{conn: conn}
+	req := &state.UnlockRequest{ResourceID: "orders", LockOwner: "worker-1"}
+
+	t.Run("With owned lock", func(t *testing.T) {
+		conn.EXPECT().Get("lock||orders").Return(lockNode("worker-1", time.Now().Add(time.Minute)), &zk.Stat{Version: 2}, nil).Times(1)
+		conn.EXPECT().Delete("lock||orders", int32(2)).Return(nil).Times(1)
+
+		assert.NoError(t, s.Unlock(req))
+	})
+
+	t.Run("With lock of another owner", func(t *testing.T) {
+		conn.EXPECT().Get("lock||orders").Return(lockNode("worker-2", time.Now().Add(time.Minute)), &zk.Stat{Version: 2}, nil).Times(1)
+
+		assert.Equal(t, state.ErrLockBelongsToOthers, s.Unlock(req))
+	})
+
+	t.Run("With missing lock", func(t *testing.T) {
+		conn.EXPECT().Get("lock||orders").Return(nil, nil, zk.ErrNoNode).Times(1)
+
+		assert.Equal(t, state.ErrLockDoesNotExist, s.Unlock(req))
+	})
+
+	t.Run("With expired lock", func(t *testing.T) {
+		conn.EXPECT().Get("lock||orders").Return(lockNode("worker-1", time.Now().Add(-time.Minute)), &zk.Stat{Version: 2}, nil).Times(1)
+
+		assert.Equal(t, state.ErrLockDoesNotExist, s.Unlock(req))
+	})
+}